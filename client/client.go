@@ -0,0 +1,285 @@
+// Package client is a Go client for the Tile38 server.
+//
+// The commands run on a pool of connections, and the search commands return
+// typed objects. Geofences and channel subscriptions are streamed on Go
+// channels, and they reconnect when the connection to the server is lost.
+//
+//	c, err := client.Dial("localhost:9851", nil)
+//	if err != nil {
+//		...
+//	}
+//	defer c.Close()
+//	c.Set("fleet", "truck1", client.Point(33.5123, -112.2693), nil)
+//	objs, err := c.SearchNearby("fleet", 33.5, -112.3, 5000)
+package client
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+)
+
+// ErrNotFound is returned by Get when the key or object doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Options are the options of a client.
+type Options struct {
+	// Password is sent with AUTH when the server has a requirepass.
+	Password string
+	// MaxIdle is the maximum number of idle connections in the pool. The
+	// default is 8.
+	MaxIdle int
+	// MaxActive is the maximum number of connections in the pool, or no
+	// limit when zero.
+	MaxActive int
+	// DialTimeout is the timeout for connecting to the server. The default is
+	// 5 seconds.
+	DialTimeout time.Duration
+	// ReconnectDelay is the delay between the reconnects of geofences and
+	// subscriptions. The default is one second.
+	ReconnectDelay time.Duration
+}
+
+// Client is a Tile38 client. It's safe for concurrent use.
+type Client struct {
+	addr string
+	opts Options
+	pool *redis.Pool
+}
+
+// Object is an object that was returned by the server.
+type Object struct {
+	ID     string
+	Object geojson.Object // nil for string objects
+	String string         // the object as it's returned by the server
+	Fields map[string]float64
+	// Distance is the distance in meters from the point of SearchNearby.
+	Distance float64
+}
+
+// Dial connects to a Tile38 server.
+func Dial(addr string, opts *Options) (*Client, error) {
+	c := &Client{addr: addr}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.MaxIdle == 0 {
+		c.opts.MaxIdle = 8
+	}
+	if c.opts.DialTimeout == 0 {
+		c.opts.DialTimeout = 5 * time.Second
+	}
+	if c.opts.ReconnectDelay == 0 {
+		c.opts.ReconnectDelay = time.Second
+	}
+	c.pool = &redis.Pool{
+		Dial:        c.dial,
+		MaxIdle:     c.opts.MaxIdle,
+		MaxActive:   c.opts.MaxActive,
+		IdleTimeout: 5 * time.Minute,
+		Wait:        c.opts.MaxActive > 0,
+		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
+			if time.Since(t) < time.Minute {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+	}
+	if _, err := c.do("PING"); err != nil {
+		c.pool.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// dial opens a new connection to the server.
+func (c *Client) dial() (redis.Conn, error) {
+	opts := []redis.DialOption{redis.DialConnectTimeout(c.opts.DialTimeout)}
+	if c.opts.Password != "" {
+		opts = append(opts, redis.DialPassword(c.opts.Password))
+	}
+	return redis.Dial("tcp", c.addr, opts...)
+}
+
+// Close closes the connections of the pool.
+func (c *Client) Close() error {
+	return c.pool.Close()
+}
+
+// Do runs a command on a pooled connection and returns the RESP reply.
+func (c *Client) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(cmd, args...)
+}
+
+func (c *Client) do(cmd string, args ...interface{}) (interface{}, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+	return conn.Do(cmd, args...)
+}
+
+// Point returns a point object.
+func Point(lat, lon float64) geojson.Object {
+	return geojson.NewPoint(geometry.Point{X: lon, Y: lat})
+}
+
+// Bounds returns a rectangle object.
+func Bounds(minLat, minLon, maxLat, maxLon float64) geojson.Object {
+	return geojson.NewRect(geometry.Rect{
+		Min: geometry.Point{X: minLon, Y: minLat},
+		Max: geometry.Point{X: maxLon, Y: maxLat},
+	})
+}
+
+// Set stores an object with its fields.
+func (c *Client) Set(key, id string, obj geojson.Object,
+	fields map[string]float64,
+) error {
+	args := []interface{}{key, id}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "FIELD", name, fields[name])
+	}
+	args = append(args, "OBJECT", obj.JSON())
+	_, err := c.do("SET", args...)
+	return err
+}
+
+// Get returns an object with its fields.
+func (c *Client) Get(key, id string) (*Object, error) {
+	v, err := c.do("GET", key, id, "WITHFIELDS")
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	vals, err := redis.Values(v, nil)
+	if err != nil || len(vals) == 0 {
+		return nil, errors.New("unexpected reply")
+	}
+	o := &Object{ID: id}
+	o.String, _ = redis.String(vals[0], nil)
+	o.Object = parseObject(o.String)
+	if len(vals) > 1 {
+		o.Fields = parseFields(vals[1])
+	}
+	return o, nil
+}
+
+// Del removes an object. Returns false when the object didn't exist.
+func (c *Client) Del(key, id string) (bool, error) {
+	n, err := redis.Int(c.do("DEL", key, id))
+	return n > 0, err
+}
+
+// Drop removes a key and all of its objects. Returns false when the key
+// didn't exist.
+func (c *Client) Drop(key string) (bool, error) {
+	n, err := redis.Int(c.do("DROP", key))
+	return n > 0, err
+}
+
+// SearchWithin returns the objects of a key that are fully contained within
+// an area.
+func (c *Client) SearchWithin(key string, area geojson.Object) ([]Object, error) {
+	return c.search("WITHIN", key, false, "OBJECT", area.JSON())
+}
+
+// SearchIntersects returns the objects of a key that intersect an area.
+func (c *Client) SearchIntersects(key string, area geojson.Object) ([]Object, error) {
+	return c.search("INTERSECTS", key, false, "OBJECT", area.JSON())
+}
+
+// SearchNearby returns the objects of a key that are within a number of
+// meters from a point, nearest first.
+func (c *Client) SearchNearby(key string, lat, lon, meters float64) ([]Object, error) {
+	return c.search("NEARBY", key, true, "POINT", lat, lon, meters)
+}
+
+// search runs a search command, and follows the cursor until all of the
+// objects are returned.
+func (c *Client) search(cmd, key string, distance bool, area ...interface{},
+) ([]Object, error) {
+	var objs []Object
+	var cursor int64
+	for {
+		args := []interface{}{key, "CURSOR", cursor}
+		if distance {
+			args = append(args, "DISTANCE")
+		}
+		args = append(args, area...)
+		vals, err := redis.Values(c.do(cmd, args...))
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != 2 {
+			return nil, errors.New("unexpected reply")
+		}
+		cursor, _ = redis.Int64(vals[0], nil)
+		items, _ := redis.Values(vals[1], nil)
+		for _, item := range items {
+			ivals, _ := redis.Values(item, nil)
+			if len(ivals) < 2 {
+				continue
+			}
+			var o Object
+			o.ID, _ = redis.String(ivals[0], nil)
+			o.String, _ = redis.String(ivals[1], nil)
+			o.Object = parseObject(o.String)
+			for _, v := range ivals[2:] {
+				if _, ok := v.([]interface{}); ok {
+					o.Fields = parseFields(v)
+				} else {
+					o.Distance, _ = redis.Float64(v, nil)
+				}
+			}
+			objs = append(objs, o)
+		}
+		if cursor == 0 {
+			return objs, nil
+		}
+	}
+}
+
+// parseObject returns the geojson of an object, or nil when it's not a
+// geometry.
+func parseObject(s string) geojson.Object {
+	obj, err := geojson.Parse(s, nil)
+	if err != nil {
+		return nil
+	}
+	return obj
+}
+
+// parseFields returns the fields of a RESP field/value array.
+func parseFields(v interface{}) map[string]float64 {
+	vals, _ := redis.Strings(v, nil)
+	fields := make(map[string]float64, len(vals)/2)
+	for i := 0; i+1 < len(vals); i += 2 {
+		fields[vals[i]], _ = strconv.ParseFloat(vals[i+1], 64)
+	}
+	return fields
+}
+
+func isNotFound(err error) bool {
+	if err, ok := err.(redis.Error); ok {
+		switch err.Error() {
+		case "ERR key not found", "ERR id not found":
+			return true
+		}
+	}
+	return false
+}
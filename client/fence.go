@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/gjson"
+)
+
+// Event is a geofence event, or a message of a channel.
+type Event struct {
+	Command string // "set", "del", "drop", ...
+	Detect  string // "enter", "exit", "inside", "outside", "cross", ...
+	Hook    string // the name of the hook or channel
+	Group   string
+	Key     string
+	ID      string
+	Time    time.Time
+	Object  geojson.Object
+	Fields  map[string]float64
+	// Channel is the channel that a subscription received the event on.
+	Channel string
+	// JSON is the message as it was sent by the server.
+	JSON string
+}
+
+// FenceOptions are the options of a geofence.
+type FenceOptions struct {
+	// Intersects matches the objects that intersect the area, instead of the
+	// objects that are within the area.
+	Intersects bool
+	// Detect are the detections that are sent, or all when empty.
+	Detect []string
+	// Commands are the commands that are sent, or all when empty.
+	Commands []string
+}
+
+// Fence streams the events of a live geofence on the objects of a key. The
+// fence is opened again when the connection is lost, and the channel is
+// closed when the context is done.
+func (c *Client) Fence(ctx context.Context, key string, area geojson.Object,
+	opts *FenceOptions,
+) (<-chan Event, error) {
+	cmd := "WITHIN"
+	args := []interface{}{key, "FENCE"}
+	if opts != nil {
+		if opts.Intersects {
+			cmd = "INTERSECTS"
+		}
+		if len(opts.Detect) > 0 {
+			args = append(args, "DETECT", strings.Join(opts.Detect, ","))
+		}
+		if len(opts.Commands) > 0 {
+			args = append(args, "COMMANDS", strings.Join(opts.Commands, ","))
+		}
+	}
+	args = append(args, "OBJECT", area.JSON())
+	start := func(conn redis.Conn) error {
+		_, err := conn.Do(cmd, args...)
+		return err
+	}
+	return c.stream(ctx, start, func(v interface{}) (Event, bool) {
+		msg, err := redis.String(v, nil)
+		if err != nil {
+			return Event{}, false
+		}
+		return parseEvent(msg), true
+	})
+}
+
+// Subscribe streams the messages of channels, see SETCHAN. The channels are
+// subscribed again when the connection is lost, but the messages that were
+// sent while the client was disconnected are not received.
+func (c *Client) Subscribe(ctx context.Context, channels ...string,
+) (<-chan Event, error) {
+	args := make([]interface{}, len(channels))
+	for i, channel := range channels {
+		args[i] = channel
+	}
+	start := func(conn redis.Conn) error {
+		if err := conn.Send("SUBSCRIBE", args...); err != nil {
+			return err
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+		for range channels {
+			if _, err := conn.Receive(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return c.stream(ctx, start, func(v interface{}) (Event, bool) {
+		vals, err := redis.Strings(v, nil)
+		if err != nil || len(vals) != 3 || vals[0] != "message" {
+			return Event{}, false
+		}
+		ev := parseEvent(vals[2])
+		ev.Channel = vals[1]
+		return ev, true
+	})
+}
+
+// stream opens a dedicated connection, starts the stream, and sends the
+// parsed replies on a channel. The stream is started again on a new
+// connection when there's an error. The first connection must succeed.
+func (c *Client) stream(ctx context.Context, start func(conn redis.Conn) error,
+	parse func(v interface{}) (Event, bool),
+) (<-chan Event, error) {
+	conn, err := c.dial()
+	if err == nil {
+		if err = start(conn); err != nil {
+			conn.Close()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		for {
+			done := make(chan struct{})
+			go func(conn redis.Conn) {
+				// unblock the receive when the context is done
+				select {
+				case <-ctx.Done():
+					conn.Close()
+				case <-done:
+				}
+			}(conn)
+			for {
+				v, err := conn.Receive()
+				if err != nil {
+					break
+				}
+				ev, ok := parse(v)
+				if !ok {
+					continue
+				}
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+				}
+				if ctx.Err() != nil {
+					break
+				}
+			}
+			close(done)
+			conn.Close()
+			// reconnect
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(c.opts.ReconnectDelay):
+				}
+				nconn, err := c.dial()
+				if err != nil {
+					continue
+				}
+				if err := start(nconn); err != nil {
+					nconn.Close()
+					continue
+				}
+				conn = nconn
+				break
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// parseEvent returns the event of a json message.
+func parseEvent(msg string) Event {
+	res := gjson.Parse(msg)
+	ev := Event{
+		Command: res.Get("command").String(),
+		Detect:  res.Get("detect").String(),
+		Hook:    res.Get("hook").String(),
+		Group:   res.Get("group").String(),
+		Key:     res.Get("key").String(),
+		ID:      res.Get("id").String(),
+		JSON:    msg,
+	}
+	ev.Time, _ = time.Parse(time.RFC3339Nano, res.Get("time").String())
+	if obj := res.Get("object"); obj.Exists() {
+		ev.Object = parseObject(obj.Raw)
+	}
+	if fields := res.Get("fields"); fields.IsObject() {
+		ev.Fields = make(map[string]float64)
+		fields.ForEach(func(k, v gjson.Result) bool {
+			ev.Fields[k.String()] = v.Float()
+			return true
+		})
+	}
+	return ev
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/redbench"
+	"github.com/tidwall/tile38/internal/histogram"
+)
+
+// The FLEET test simulates a fleet of vehicles that report their position
+// while other clients query for the vehicles around them. The vehicles
+// start at random points in a 50 km area and every update moves one
+// vehicle a short distance. The fences are channels with a NEARBY fence,
+// so every update is also checked against the fences.
+
+const (
+	fleetKey   = "key:fleet"
+	fleetLat   = 33.4484 // center of the fleet area
+	fleetLon   = -112.0740
+	fleetSpan  = 0.45   // width and height of the area in degrees, about 50 km
+	fleetStep  = 0.001  // largest move in degrees, about 100 m
+	fleetRange = "1000" // meters, the radius of fences and queries
+)
+
+var (
+	fleetObjects = 10000
+	fleetFences  = 100
+	fleetUpdates = 80 // percent
+)
+
+var fleetCommands = []string{"SET", "NEARBY", "WITHIN", "INTERSECTS"}
+
+func fleetPoint(rng *rand.Rand) (lat, lon float64) {
+	return fleetLat + (rng.Float64()-0.5)*fleetSpan,
+		fleetLon + (rng.Float64()-0.5)*fleetSpan
+}
+
+func fleetFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
+
+// readReply reads a single RESP reply and returns an error if it's an error
+// reply.
+func readReply(rd *bufio.Reader) error {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return errors.New("invalid reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return errors.New(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return nil
+		}
+		_, err = rd.Discard(n + 2)
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := readReply(rd); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid reply '%s'", line)
+}
+
+type fleetConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+	buf  []byte
+}
+
+func dialFleet() (*fleetConn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	prepFn(conn)
+	return &fleetConn{conn: conn, rd: bufio.NewReader(conn)}, nil
+}
+
+func (c *fleetConn) do(args ...string) error {
+	c.buf = redbench.AppendCommand(c.buf[:0], args...)
+	if _, err := c.conn.Write(c.buf); err != nil {
+		return err
+	}
+	return readReply(c.rd)
+}
+
+// runFleet runs the FLEET test.
+func runFleet() {
+	conn, err := dialFleet()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
+	defer conn.conn.Close()
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	points := make([][2]float64, fleetObjects)
+	for i := range points {
+		lat, lon := fleetPoint(rng)
+		points[i] = [2]float64{lat, lon}
+		err := conn.do("SET", fleetKey, "truck:"+strconv.Itoa(i),
+			"POINT", fleetFloat(lat), fleetFloat(lon))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return
+		}
+	}
+	defer conn.do("PDELCHAN", fleetKey+":fence:*")
+	for i := 0; i < fleetFences; i++ {
+		lat, lon := fleetPoint(rng)
+		err := conn.do("SETCHAN", fleetKey+":fence:"+strconv.Itoa(i),
+			"NEARBY", fleetKey, "FENCE",
+			"POINT", fleetFloat(lat), fleetFloat(lon), fleetRange)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return
+		}
+	}
+
+	hists := make([]map[string]*histogram.Histogram, clients)
+	errs := make([]error, clients)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for c := 0; c < clients; c++ {
+		hists[c] = make(map[string]*histogram.Histogram)
+		for _, cmd := range fleetCommands {
+			hists[c][cmd] = new(histogram.Histogram)
+		}
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			errs[c] = fleetClient(c, points, hists[c])
+		}(c)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	for _, err := range errs {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return
+		}
+	}
+
+	name := fmt.Sprintf("FLEET (%d objects, %d fences, %d%% updates)",
+		fleetObjects, fleetFences, fleetUpdates)
+	rps := float64(requests) / elapsed.Seconds()
+	switch {
+	case csv:
+		fmt.Printf("\"%s\",\"%.2f\"\n", name, rps)
+		for _, cmd := range fleetCommands {
+			h := mergeFleet(hists, cmd)
+			fmt.Printf("\"%s %s\",\"%d\",\"%.2f\",\"%.2f\",\"%.2f\",\"%.2f\",\"%.2f\"\n",
+				name, cmd, h.Count(), usecs(h.Percentile(50)),
+				usecs(h.Percentile(90)), usecs(h.Percentile(99)),
+				usecs(h.Percentile(99.9)), usecs(h.Max()))
+		}
+	case quiet:
+		fmt.Printf("%s: %.2f requests per second\n", name, rps)
+	default:
+		fmt.Printf("====== %s ======\n", name)
+		fmt.Printf("  %d requests completed in %.2f seconds\n", requests,
+			elapsed.Seconds())
+		fmt.Printf("  %d parallel clients\n", clients)
+		fmt.Printf("\n")
+		fmt.Printf("  %-12s %9s %9s %9s %9s %9s %9s\n",
+			"latency(us)", "count", "p50", "p90", "p99", "p99.9", "max")
+		for _, cmd := range fleetCommands {
+			h := mergeFleet(hists, cmd)
+			fmt.Printf("  %-12s %9d %9.1f %9.1f %9.1f %9.1f %9.1f\n",
+				cmd, h.Count(), usecs(h.Percentile(50)),
+				usecs(h.Percentile(90)), usecs(h.Percentile(99)),
+				usecs(h.Percentile(99.9)), usecs(h.Max()))
+		}
+		fmt.Printf("\n")
+		fmt.Printf("%.2f requests per second\n\n", rps)
+	}
+}
+
+func usecs(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
+
+func mergeFleet(hists []map[string]*histogram.Histogram, cmd string) *histogram.Histogram {
+	var h histogram.Histogram
+	for _, hs := range hists {
+		h.Merge(hs[cmd])
+	}
+	return &h
+}
+
+// fleetClient runs the share of the requests for client c. The client only
+// moves the vehicles where id % clients == c, so no two clients write to
+// the same vehicle.
+func fleetClient(c int, points [][2]float64, hists map[string]*histogram.Histogram) error {
+	conn, err := dialFleet()
+	if err != nil {
+		return err
+	}
+	defer conn.conn.Close()
+	rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(c)))
+	n := requests / clients
+	if c < requests%clients {
+		n++
+	}
+	owned := (len(points) - c + clients - 1) / clients
+	for i := 0; i < n; i++ {
+		var args []string
+		if rng.Intn(100) < fleetUpdates && owned > 0 {
+			idx := c + rng.Intn(owned)*clients
+			p := &points[idx]
+			p[0] = math.Max(fleetLat-fleetSpan/2, math.Min(fleetLat+fleetSpan/2,
+				p[0]+(rng.Float64()*2-1)*fleetStep))
+			p[1] = math.Max(fleetLon-fleetSpan/2, math.Min(fleetLon+fleetSpan/2,
+				p[1]+(rng.Float64()*2-1)*fleetStep))
+			args = []string{"SET", fleetKey, "truck:" + strconv.Itoa(idx),
+				"POINT", fleetFloat(p[0]), fleetFloat(p[1])}
+		} else {
+			lat, lon := fleetPoint(rng)
+			switch rng.Intn(3) {
+			case 0:
+				args = []string{"NEARBY", fleetKey, "LIMIT", "10", "COUNT",
+					"POINT", fleetFloat(lat), fleetFloat(lon)}
+			case 1:
+				args = []string{"WITHIN", fleetKey, "COUNT",
+					"CIRCLE", fleetFloat(lat), fleetFloat(lon), fleetRange}
+			case 2:
+				args = []string{"INTERSECTS", fleetKey, "COUNT", "BOUNDS",
+					fleetFloat(lat - fleetStep*10), fleetFloat(lon - fleetStep*10),
+					fleetFloat(lat + fleetStep*10), fleetFloat(lon + fleetStep*10)}
+			}
+		}
+		start := time.Now()
+		if err := conn.do(args...); err != nil {
+			return err
+		}
+		hists[args[0]].Record(time.Since(start))
+	}
+	return nil
+}
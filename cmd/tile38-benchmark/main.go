@@ -29,7 +29,7 @@ var (
 	pipeline = 1
 	csv      = false
 	json     = false
-	allTests = "PING,SET,GET,INTERSECTS,WITHIN,NEARBY,EVAL"
+	allTests = "PING,SET,GET,INTERSECTS,WITHIN,NEARBY,EVAL,FLEET"
 	tests    = allTests
 	redis    = false
 )
@@ -58,6 +58,10 @@ func showHelp() bool {
 	fmt.Fprintf(os.Stdout, " --csv              Output in CSV format.\n")
 	fmt.Fprintf(os.Stdout, " --json             Request JSON responses (default is RESP output)\n")
 	fmt.Fprintf(os.Stdout, " --redis            Runs against a Redis server\n")
+	fmt.Fprintf(os.Stdout, " --objects <num>    Number of moving objects for FLEET (default %d)\n", fleetObjects)
+	fmt.Fprintf(os.Stdout, " --fences <num>     Number of geofences for FLEET (default %d)\n", fleetFences)
+	fmt.Fprintf(os.Stdout, " --updates <pct>    Percent of FLEET requests that are updates, the\n")
+	fmt.Fprintf(os.Stdout, "                    rest are queries (default %d)\n", fleetUpdates)
 	fmt.Fprintf(os.Stdout, "\n")
 	return false
 }
@@ -135,6 +139,18 @@ func parseArgs() bool {
 			json = true
 		case "--redis":
 			redis = true
+		case "--objects":
+			fleetObjects = readIntArg(arg)
+			if fleetObjects <= 0 {
+				fleetObjects = 1
+			}
+		case "--fences":
+			fleetFences = readIntArg(arg)
+		case "--updates":
+			fleetUpdates = readIntArg(arg)
+			if fleetUpdates > 100 {
+				fleetUpdates = 100
+			}
 		}
 	}
 	return true
@@ -646,6 +662,10 @@ func main() {
 					},
 				)
 			}
+		case "FLEET":
+			if !redis {
+				runFleet()
+			}
 		case "EVAL":
 			if !redis {
 				var i int64
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/pretty"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/core"
+	"golang.org/x/term"
+)
+
+// The output modes of json replies, see --table and --geojson.
+const (
+	formatJSON    = ""
+	formatTable   = "table"
+	formatGeoJSON = "geojson"
+)
+
+// colorOutput returns true when the json replies are pretty printed with
+// colors, which is only for terminals.
+func colorOutput() bool {
+	return !raw && !tty && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// formatReply returns a json reply in the output mode.
+func formatReply(msg []byte) []byte {
+	if len(msg) == 0 || msg[0] != '{' || !jsonOK(msg) {
+		return msg
+	}
+	switch format {
+	case formatTable:
+		if table, ok := replyTable(msg); ok {
+			return table
+		}
+	case formatGeoJSON:
+		msg = replyGeoJSON(msg)
+	}
+	if colorOutput() {
+		msg = bytes.TrimSpace(pretty.Color(pretty.Pretty(msg), nil))
+	}
+	return msg
+}
+
+// replyFields returns the fields of an object of a search reply by name. The
+// fields are an array when the reply has the field names, otherwise they're
+// an object.
+func replyFields(names []gjson.Result, fields gjson.Result) [][2]string {
+	var kvs [][2]string
+	if fields.IsArray() {
+		for i, value := range fields.Array() {
+			if i < len(names) {
+				kvs = append(kvs, [2]string{names[i].String(), value.Raw})
+			}
+		}
+	} else if fields.IsObject() {
+		fields.ForEach(func(key, value gjson.Result) bool {
+			kvs = append(kvs, [2]string{key.String(), value.Raw})
+			return true
+		})
+	}
+	return kvs
+}
+
+// replyGeoJSON returns the objects of a search or GET reply as a GeoJSON
+// FeatureCollection, or Feature. The fields are the properties of the
+// features. Other replies are returned as is.
+func replyGeoJSON(msg []byte) []byte {
+	res := gjson.ParseBytes(msg)
+	names := res.Get("fields").Array()
+	feature := func(dst []byte, id string, obj, fields gjson.Result) []byte {
+		geometry := obj
+		if obj.Get("type").String() == "Feature" {
+			geometry = obj.Get("geometry")
+		}
+		dst = append(dst, `{"type":"Feature"`...)
+		if id != "" {
+			dst = append(dst, `,"id":`...)
+			dst = append(dst, strconv.Quote(id)...)
+		}
+		dst = append(dst, `,"geometry":`...)
+		dst = append(dst, geometry.Raw...)
+		dst = append(dst, `,"properties":{`...)
+		var n int
+		obj.Get("properties").ForEach(func(key, value gjson.Result) bool {
+			if n > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, key.Raw...)
+			dst = append(dst, ':')
+			dst = append(dst, value.Raw...)
+			n++
+			return true
+		})
+		for _, kv := range replyFields(names, fields) {
+			if n > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, strconv.Quote(kv[0])...)
+			dst = append(dst, ':')
+			dst = append(dst, kv[1]...)
+			n++
+		}
+		return append(dst, '}', '}')
+	}
+	if obj := res.Get("object"); obj.IsObject() {
+		return feature(nil, "", obj, res.Get("fields"))
+	}
+	objs := res.Get("objects")
+	if !objs.IsArray() {
+		return msg
+	}
+	dst := []byte(`{"type":"FeatureCollection","features":[`)
+	var n int
+	objs.ForEach(func(_, item gjson.Result) bool {
+		obj := item.Get("object")
+		if !obj.IsObject() {
+			// string objects aren't features
+			return true
+		}
+		if n > 0 {
+			dst = append(dst, ',')
+		}
+		dst = feature(dst, item.Get("id").String(), obj, item.Get("fields"))
+		n++
+		return true
+	})
+	return append(dst, ']', '}')
+}
+
+// replyTable returns the ids, objects, points, bounds or hashes of a search
+// reply as a text table, with a column for each field.
+func replyTable(msg []byte) ([]byte, bool) {
+	res := gjson.ParseBytes(msg)
+	var column string
+	var items gjson.Result
+	for _, name := range []string{"objects", "points", "bounds", "hashes", "ids"} {
+		if items = res.Get(name); items.IsArray() {
+			column = strings.TrimSuffix(name, "s")
+			if name == "bounds" {
+				column = "bounds"
+			}
+			break
+		}
+	}
+	if column == "" {
+		return nil, false
+	}
+	names := res.Get("fields").Array()
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprint(w, "ID")
+	for _, name := range names {
+		fmt.Fprint(w, "\t"+strings.ToUpper(name.String()))
+	}
+	if column != "id" {
+		fmt.Fprint(w, "\t"+strings.ToUpper(column))
+	}
+	fmt.Fprintln(w)
+	items.ForEach(func(_, item gjson.Result) bool {
+		if column == "id" && item.Type == gjson.String {
+			fmt.Fprintln(w, item.String())
+			return true
+		}
+		fmt.Fprint(w, item.Get("id").String())
+		fields := replyFields(names, item.Get("fields"))
+		for _, name := range names {
+			var value string
+			for _, kv := range fields {
+				if kv[0] == name.String() {
+					value = kv[1]
+				}
+			}
+			fmt.Fprint(w, "\t"+value)
+		}
+		if column != "id" {
+			value := item.Get(column)
+			if value.Type == gjson.String {
+				fmt.Fprint(w, "\t"+value.String())
+			} else {
+				fmt.Fprint(w, "\t"+string(pretty.Ugly([]byte(value.Raw))))
+			}
+		}
+		fmt.Fprintln(w)
+		return true
+	})
+	w.Flush()
+	fmt.Fprintf(&buf, "(%d rows)", len(items.Array()))
+	return buf.Bytes(), true
+}
+
+// commandKeyArg returns true when the first argument of a command is a key.
+func commandKeyArg(name string) bool {
+	command, ok := core.Commands[strings.ToUpper(name)]
+	if !ok || len(command.Arguments) == 0 {
+		return false
+	}
+	names, _ := command.Arguments[0].NameTypes()
+	return len(names) == 1 && names[0] == "key"
+}
+
+// completeKeys returns the keys on the server that start with a prefix.
+func completeKeys(conn *client, prefix string) []string {
+	if conn == nil || strings.ContainsAny(prefix, "*?\\\"' ") {
+		return nil
+	}
+	msg, err := conn.Do("keys " + prefix + "*")
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	if len(msg) > 0 && msg[0] == '{' {
+		for _, key := range gjson.GetBytes(msg, "keys").Array() {
+			keys = append(keys, key.String())
+		}
+		return keys
+	}
+	v, _, err := resp.NewReader(bytes.NewReader(msg)).ReadValue()
+	if err != nil {
+		return nil
+	}
+	for _, key := range v.Array() {
+		keys = append(keys, key.String())
+	}
+	return keys
+}
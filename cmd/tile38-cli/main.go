@@ -49,6 +49,9 @@ var (
 	raw        bool
 	noprompt   bool
 	tty        bool
+	format     = formatJSON
+	quiet      bool
+	pipe       bool
 )
 
 func showHelp() bool {
@@ -65,6 +68,10 @@ func showHelp() bool {
 	fmt.Fprintf(os.Stdout, " --noprompt         Do not display a prompt\n")
 	fmt.Fprintf(os.Stdout, " --resp             Use RESP output formatting (default is JSON output)\n")
 	fmt.Fprintf(os.Stdout, " --json             Use JSON output formatting (default is JSON output)\n")
+	fmt.Fprintf(os.Stdout, " --table            Show search results as a table\n")
+	fmt.Fprintf(os.Stdout, " --geojson          Show search results as a GeoJSON FeatureCollection\n")
+	fmt.Fprintf(os.Stdout, " -q, --quiet        Only show errors\n")
+	fmt.Fprintf(os.Stdout, " --pipe             Send the commands of stdin, one per line\n")
 	fmt.Fprintf(os.Stdout, " -h <hostname>      Server hostname (default: %s)\n", hostname)
 	fmt.Fprintf(os.Stdout, " -p <port>          Server port (default: %d)\n", port)
 	fmt.Fprintf(os.Stdout, "\n")
@@ -116,6 +123,14 @@ func parseArgs() bool {
 			output = "resp"
 		case "--json":
 			output = "json"
+		case "--table":
+			format = formatTable
+		case "--geojson":
+			format = formatGeoJSON
+		case "-q", "--quiet":
+			quiet = true
+		case "--pipe":
+			pipe = true
 		case "-h":
 			hostname = readArg(arg)
 		case "-p":
@@ -127,6 +142,14 @@ func parseArgs() bool {
 		}
 	}
 	oneCommand = strings.Join(args, " ")
+	if pipe && oneCommand != "" {
+		fmt.Fprintf(os.Stderr, "A command can't be used with --pipe\n")
+		return false
+	}
+	if format != formatJSON && output != "json" {
+		fmt.Fprintf(os.Stderr, "--%s requires JSON output formatting\n", format)
+		return false
+	}
 	return true
 }
 
@@ -171,6 +194,12 @@ func main() {
 		}
 	}
 	connDial()
+	if pipe {
+		if conn == nil || !runPipe(conn) {
+			os.Exit(1)
+		}
+		return
+	}
 	monitor := false
 	livemode := false
 	aof := false
@@ -245,6 +274,13 @@ func main() {
 						c = append(c, n)
 					}
 				}
+			} else if i := strings.IndexByte(line, ' '); i != -1 {
+				// the key of a command, such as "GET fl<tab>"
+				if strings.IndexByte(line[i+1:], ' ') == -1 && commandKeyArg(line[:i]) {
+					for _, key := range completeKeys(conn, line[i+1:]) {
+						c = append(c, line[:i+1]+key)
+					}
+				}
 			} else {
 				for _, n := range commands {
 					if strings.HasPrefix(strings.ToLower(n), strings.ToLower(line)) {
@@ -318,6 +354,13 @@ func main() {
 					}
 					continue
 				}
+				if command, err = expandFileArgs(command); err != nil {
+					fmt.Fprintln(os.Stderr, "(error) "+err.Error())
+					if oneCommand != "" {
+						os.Exit(1)
+					}
+					continue
+				}
 				aof = (command[0] == 'a' || command[0] == 'A') && strings.HasPrefix(strings.ToLower(command), "aof ")
 			tryAgain:
 				if conn == nil {
@@ -381,7 +424,10 @@ func main() {
 					fmt.Fprintln(os.Stderr, string(msg))
 					break // break out of prompt and just feed data to screen
 				}
-				if mustOutput {
+				if mustOutput && !(quiet && replyError(msg) == "") {
+					if !raw && output == "json" {
+						msg = formatReply(msg)
+					}
 					fmt.Fprintln(os.Stdout, string(msg))
 				}
 			}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/pretty"
+)
+
+// expandFileArgs replaces the arguments of a command that start with '@'
+// with the contents of the files, such as "SET fleet truck1 OBJECT
+// @truck1.geojson". The files must be json, which is compacted into one
+// argument. The command name and the groups of "help @group" are not
+// expanded.
+func expandFileArgs(command string) (string, error) {
+	args := strings.Split(command, " ")
+	if len(args) < 2 || strings.ToLower(args[0]) == "help" {
+		return command, nil
+	}
+	var expanded bool
+	for i := 1; i < len(args); i++ {
+		if len(args[i]) < 2 || args[i][0] != '@' {
+			continue
+		}
+		data, err := ioutil.ReadFile(args[i][1:])
+		if err != nil {
+			return "", err
+		}
+		if !gjson.ValidBytes(data) {
+			return "", fmt.Errorf("%s: invalid json", args[i][1:])
+		}
+		// quoted, because string values may have spaces
+		args[i] = strconv.Quote(string(pretty.Ugly(data)))
+		expanded = true
+	}
+	if !expanded {
+		return command, nil
+	}
+	return strings.Join(args, " "), nil
+}
+
+// replyError returns the error message of a reply, or an empty string.
+func replyError(msg []byte) string {
+	if len(msg) == 0 {
+		return ""
+	}
+	switch msg[0] {
+	case '-':
+		return strings.TrimSpace(string(msg[1:]))
+	case '{':
+		if !jsonOK(msg) {
+			return gjson.GetBytes(msg, "err").String()
+		}
+	}
+	return ""
+}
+
+// runPipe sends the commands of stdin, one per line, and prints the replies.
+// The commands are pipelined, so the next commands are written while the
+// replies are read. Returns false when there was an error.
+func runPipe(conn *client) bool {
+	pending := make(chan string, 4096)
+	werr := make(chan error, 1)
+	go func() {
+		defer close(pending)
+		rd := bufio.NewReader(os.Stdin)
+		wr := bufio.NewWriter(conn.wr)
+		for {
+			line, err := rd.ReadString('\n')
+			if command := strings.TrimSpace(line); command != "" {
+				if command, err = expandFileArgs(command); err != nil {
+					werr <- err
+					return
+				}
+				if _, err := wr.Write(plainToCompat(command)); err != nil {
+					werr <- err
+					return
+				}
+				if rd.Buffered() == 0 || len(pending) == cap(pending) {
+					if err := wr.Flush(); err != nil {
+						werr <- err
+						return
+					}
+				}
+				pending <- command
+			}
+			if err != nil {
+				if err != io.EOF {
+					werr <- err
+					return
+				}
+				werr <- wr.Flush()
+				return
+			}
+		}
+	}()
+	var replies, errs int
+	for command := range pending {
+		msg, err := conn.readResp()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return false
+		}
+		replies++
+		if output == "json" {
+			msg = convert2termjson(msg)
+		}
+		if e := replyError(msg); e != "" {
+			errs++
+			fmt.Fprintf(os.Stderr, "(error) %s: %s\n", command, e)
+			continue
+		}
+		if quiet {
+			continue
+		}
+		if !raw {
+			if output == "resp" {
+				msg = convert2termresp(msg)
+			} else {
+				msg = formatReply(msg)
+			}
+		}
+		fmt.Fprintln(os.Stdout, string(msg))
+	}
+	if err := <-werr; err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		errs++
+	}
+	fmt.Fprintf(os.Stderr, "errors: %d, replies: %d\n", errs, replies)
+	return errs == 0
+}
@@ -86,6 +86,11 @@ Advanced Options:
   --queuefilename path    : Event queue path (default:data/queue.db)
   --http-transport yes/no : HTTP transport (default: yes)
   --protected-mode yes/no : protected mode (default: yes)
+  --enable-debug mode     : DEBUG command and pprof, yes/no/local (default: no)
+  --web-ui yes/no         : web console at /ui, needs HTTP transport (default: no)
+  --cluster-discovery spec  : follow the leader of the peers in Kubernetes,
+                              dns:service[:port] or kubernetes:label-selector
+  --cluster-election yes/no : elect the leader of the peers (default: no)
   --nohup                 : do not exit on SIGHUP
 
 Developer Options:
@@ -169,6 +174,53 @@ Developer Options:
 			}
 			fmt.Fprintf(os.Stderr, "protected-mode must be 'yes' or 'no'\n")
 			os.Exit(1)
+		case "--enable-debug", "-enable-debug":
+			i++
+			if i < len(os.Args) {
+				switch strings.ToLower(os.Args[i]) {
+				case "no", "yes", "local":
+					core.EnableDebug = strings.ToLower(os.Args[i])
+					continue
+				}
+			}
+			fmt.Fprintf(os.Stderr, "enable-debug must be 'yes', 'no', or 'local'\n")
+			os.Exit(1)
+		case "--web-ui", "-web-ui":
+			i++
+			if i < len(os.Args) {
+				switch strings.ToLower(os.Args[i]) {
+				case "1", "true", "yes":
+					core.WebUI = true
+					continue
+				case "0", "false", "no":
+					core.WebUI = false
+					continue
+				}
+			}
+			fmt.Fprintf(os.Stderr, "web-ui must be 'yes' or 'no'\n")
+			os.Exit(1)
+		case "--cluster-discovery", "-cluster-discovery":
+			i++
+			if i == len(os.Args) || os.Args[i] == "" {
+				fmt.Fprintf(os.Stderr, "cluster-discovery must have a value\n")
+				os.Exit(1)
+			}
+			core.ClusterDiscovery = os.Args[i]
+			continue
+		case "--cluster-election", "-cluster-election":
+			i++
+			if i < len(os.Args) {
+				switch strings.ToLower(os.Args[i]) {
+				case "1", "true", "yes":
+					core.ClusterElection = true
+					continue
+				case "0", "false", "no":
+					core.ClusterElection = false
+					continue
+				}
+			}
+			fmt.Fprintf(os.Stderr, "cluster-election must be 'yes' or 'no'\n")
+			os.Exit(1)
 		case "--dev", "-dev":
 			devMode = true
 			continue
@@ -378,6 +430,7 @@ Developer Options:
 				continue
 			}
 			log.Warnf("signal: %v", s)
+			server.Shutdown()
 			pidcleanup()
 			pprofcleanup()
 			switch {
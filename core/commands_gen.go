@@ -184,6 +184,27 @@ var commandsJSON = `{
         "optional": true,
         "multiple": true
       },
+      {
+        "command": "SFIELD",
+        "name": ["name", "value"],
+        "type": ["string", "string"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "TAG",
+        "name": ["tag"],
+        "type": ["string"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "META",
+        "name": ["meta"],
+        "type": ["string"],
+        "optional": true,
+        "multiple": false
+      },
       {
         "command": "EX",
         "name": ["seconds"],
@@ -202,6 +223,35 @@ var commandsJSON = `{
             "name": "XX"
           }
 		]
+      },
+      {
+        "command": "IF FIELD",
+        "name": ["name","op","value"],
+        "type": ["string","string","double"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "MATCH",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "command": "REPAIR",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "command": "CRS",
+        "name": [
+          "epsg"
+        ],
+        "type": [
+          "string"
+        ],
+        "optional": true
       },
 	  {
 		"name": "value",
@@ -357,25 +407,20 @@ var commandsJSON = `{
         "type": ["string","double"],
         "multiple": true,
         "optional": true
-      }
-    ],
-    "since": "1.0.0",
-    "group": "keys"
-  },
-  "BOUNDS": {
-    "summary": "Get the combined bounds of all the objects in a key",
-    "complexity": "O(1)",
-    "arguments":[
+      },
       {
-        "name": "key",
-        "type": "string"
+        "command": "EX",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true,
+        "multiple": false
       }
     ],
-    "since": "1.3.0",
+    "since": "1.0.0",
     "group": "keys"
   },
-  "GET": {
-    "summary": "Get the object of an id",
+  "FINCRBY": {
+    "summary": "Increments the value of a field by an amount",
     "complexity": "O(1)",
     "arguments":[
       {
@@ -387,41 +432,19 @@ var commandsJSON = `{
         "type": "string"
       },
       {
-        "command": "WITHFIELDS",
-        "name": [],
-        "type": [],
-        "optional": true
+        "name": "field",
+        "type": "string"
       },
       {
-        "name": "type",
-        "optional": true,
-        "enumargs": [
-          {
-            "name": "OBJECT"
-          },
-          {
-            "name": "POINT"
-          },
-          {
-            "name": "BOUNDS"
-          },
-          {
-            "name": "HASH",
-            "arguments": [
-              {
-                "name": "geohash",
-                "type": "geohash"
-              }
-            ]
-          }
-        ]
+        "name": "amount",
+        "type": "double"
       }
     ],
-    "since": "1.0.0",
+    "since": "1.26.0",
     "group": "keys"
   },
-  "DEL": {
-    "summary": "Delete an id from a key",
+  "FDECRBY": {
+    "summary": "Decrements the value of a field by an amount",
     "complexity": "O(1)",
     "arguments":[
       {
@@ -431,371 +454,278 @@ var commandsJSON = `{
       {
         "name": "id",
         "type": "string"
+      },
+      {
+        "name": "field",
+        "type": "string"
+      },
+      {
+        "name": "amount",
+        "type": "double"
       }
     ],
-    "since": "1.0.0",
+    "since": "1.26.0",
     "group": "keys"
   },
-  "DROP": {
-    "summary": "Remove a key from the database",
+  "FMIN": {
+    "summary": "Sets a field to a value when the value is less than the current value",
     "complexity": "O(1)",
     "arguments":[
       {
         "name": "key",
         "type": "string"
-      }
-    ],
-    "since": "1.0.0",
-    "group": "keys"
-  },
-  "RENAME": {
-    "summary": "Rename a key to be stored under a different name.",
-    "complexity": "O(1)",
-    "arguments": [
+      },
       {
-        "name": "key",
+        "name": "id",
         "type": "string"
       },
       {
-        "name": "newkey",
+        "name": "field",
         "type": "string"
+      },
+      {
+        "name": "value",
+        "type": "double"
       }
     ],
-    "since": "1.14.5",
+    "since": "1.26.0",
     "group": "keys"
   },
-  "RENAMENX": {
-    "summary": "Rename a key to be stored under a different name, if a new key does not exist.",
+  "FMAX": {
+    "summary": "Sets a field to a value when the value is greater than the current value",
     "complexity": "O(1)",
-    "arguments": [
+    "arguments":[
       {
         "name": "key",
         "type": "string"
       },
       {
-        "name": "newkey",
+        "name": "id",
         "type": "string"
-      }
-    ],
-    "since": "1.14.5",
-    "group": "keys"
-  },
-  "KEYS": {
-    "summary": "Finds all keys matching the given pattern",
-    "complexity": "O(N) where N is the number of keys in the database",
-    "arguments":[
+      },
       {
-        "name": "pattern",
-        "type": "pattern"
-      }
-    ],
-    "since": "1.0.0",
-    "group": "keys"
-  },
-  "STATS": {
-    "summary": "Show stats for one or more keys",
-    "complexity": "O(N) where N is the number of keys being requested",
-    "arguments":[
+        "name": "field",
+        "type": "string"
+      },
       {
-        "name": "key",
-        "type": "string",
-        "variadic": true
+        "name": "value",
+        "type": "double"
       }
     ],
-    "since": "1.0.0",
+    "since": "1.26.0",
     "group": "keys"
   },
-  "SEARCH": {
-    "summary": "Search for string values in a key",
-    "complexity": "O(N) where N is the number of values in the key",
+  "MSET": {
+    "summary": "Sets the values of many ids in one key",
+    "complexity": "O(N) where N is the number of ids being set",
     "arguments":[
       {
         "name": "key",
         "type": "string"
       },
       {
-        "command": "CURSOR",
-        "name": "start",
-        "type": "integer",
-        "optional": true
-      },
-      {
-        "command": "LIMIT",
-        "name": "count",
-        "type": "integer",
-        "optional": true
-      },
-      {
-        "command": "MATCH",
-        "name": "pattern",
-        "type": "pattern",
-        "optional": true
-      },
-      {
-        "name": "order",
-        "optional": true,
-        "enumargs": [
-          {
-            "name": "ASC"
-          },
-          {
-            "name": "DESC"
-          }
-		]
-	  },
-      {
-        "command": "WHERE",
-        "name": ["field","min","max"],
-        "type": ["string","double","double"],
-        "optional": true,
+        "command": "ID",
+        "name": ["id"],
+        "type": ["string"],
         "multiple": true
       },
       {
-        "command": "WHEREIN",
-        "name": ["field","count","value"],
-        "type": ["string","integer","double"],
-        "optional": true,
-        "multiple": true,
-        "variadic": true
-      },
-      {
-        "command": "WHEREEVAL",
-        "name": ["script","numargs","arg"],
-        "type": ["string","integer","string"],
+        "command": "FIELD",
+        "name": ["name","value"],
+        "type": ["string","double"],
         "optional": true,
-        "multiple": true,
-        "variadic": true
+        "multiple": true
       },
       {
-        "command": "WHEREEVALSHA",
-        "name": ["sha1","numargs","arg"],
-        "type": ["string","integer","string"],
+        "command": "EX",
+        "name": ["seconds"],
+        "type": ["double"],
         "optional": true,
-        "multiple": true,
-        "variadic": true
-      },
-      {
-        "command": "NOFIELDS",
-        "name": [],
-        "type": [],
-        "optional": true
+        "multiple": false
       },
       {
         "name": "type",
         "optional": true,
         "enumargs": [
           {
-            "name": "COUNT"
+            "name": "NX"
           },
           {
-            "name": "IDS"
+            "name": "XX"
           }
         ]
-      }
-    ],
-    "since": "1.4.2",
-    "group": "search"
-  },
-  "SCAN": {
-    "summary": "Incrementally iterate though a key",
-    "complexity": "O(N) where N is the number of ids in the key",
-    "arguments":[
+      },
       {
-        "name": "key",
-        "type": "string"
+        "command": "IF FIELD",
+        "name": ["name","op","value"],
+        "type": ["string","string","double"],
+        "optional": true,
+        "multiple": true
       },
       {
-        "command": "CURSOR",
-        "name": "start",
-        "type": "integer",
+        "command": "MATCH",
+        "name": [],
+        "type": [],
         "optional": true
       },
       {
-        "command": "LIMIT",
-        "name": "count",
-        "type": "integer",
+        "command": "REPAIR",
+        "name": [],
+        "type": [],
         "optional": true
       },
       {
-        "command": "MATCH",
-        "name": "pattern",
-        "type": "pattern",
+        "command": "CRS",
+        "name": [
+          "epsg"
+        ],
+        "type": [
+          "string"
+        ],
         "optional": true
       },
       {
-        "name": "order",
-        "optional": true,
+        "name": "value",
         "enumargs": [
           {
-            "name": "ASC"
-          },
-          {
-            "name": "DESC"
-          }
-		]
-	  },
-      {
-        "command": "WHERE",
-        "name": ["field","min","max"],
-        "type": ["string","double","double"],
-        "optional": true,
-        "multiple": true
-      },
-      {
-        "command": "WHEREIN",
-        "name": ["field","count","value"],
-        "type": ["string","integer","double"],
-        "optional": true,
-        "multiple": true,
-        "variadic": true
-      },
-      {
-        "command": "WHEREEVAL",
-        "name": ["script","numargs","arg"],
-        "type": ["string","integer","string"],
-        "optional": true,
-        "multiple": true,
-        "variadic": true
-      },
-      {
-        "command": "WHEREEVALSHA",
-        "name": ["sha1","numargs","arg"],
-        "type": ["string","integer","string"],
-        "optional": true,
-        "multiple": true,
-        "variadic": true
-      },
-      {
-        "command": "NOFIELDS",
-        "name": [],
-        "type": [],
-        "optional": true
-      },
-      {
-        "name": "type",
-        "optional": true,
-        "enumargs": [
-          {
-            "name": "COUNT"
-          },
-          {
-            "name": "IDS"
+            "name": "OBJECT",
+            "arguments":[
+              {
+                "name": "geojson",
+                "type": "geojson"
+              }
+            ]
           },
           {
-            "name": "OBJECTS"
+            "name": "POINT",
+            "arguments":[
+              {
+                "name": "lat",
+                "type": "double"
+              },
+              {
+                "name": "lon",
+                "type": "double"
+              },
+              {
+                "name": "z",
+                "type": "double",
+                "optional": true
+              }
+            ]
           },
           {
-            "name": "POINTS"
+            "name": "BOUNDS",
+            "arguments":[
+              {
+                "name": "minlat",
+                "type": "double"
+              },
+              {
+                "name": "minlon",
+                "type": "double"
+              },
+              {
+                "name": "maxlat",
+                "type": "double"
+              },
+              {
+                "name": "maxlon",
+                "type": "double"
+              }
+            ]
           },
           {
-            "name": "BOUNDS"
+            "name": "HASH",
+            "arguments":[
+              {
+                "name": "geohash",
+                "type": "geohash"
+              }
+            ]
           },
           {
-            "name": "HASHES",
-            "arguments": [
+            "name": "STRING",
+            "arguments":[
               {
-                "name": "precision",
-                "type": "integer"
+                "name": "value",
+                "type": "string"
               }
             ]
           }
         ]
       }
     ],
-    "since": "1.0.0",
-    "group": "search"
+    "since": "1.26.0",
+    "group": "keys"
   },
-  "NEARBY": {
-    "summary": "Searches for ids that are nearby a point",
-    "complexity": "O(log(N)) where N is the number of ids in the area",
+  "MFSET": {
+    "summary": "Set the value for one or more fields of many ids in one key",
+    "complexity": "O(N) where N is the number of ids being updated",
     "arguments":[
       {
         "name": "key",
         "type": "string"
       },
       {
-        "command": "CURSOR",
-        "name": "start",
-        "type": "integer",
-        "optional": true
-      },
-      {
-        "command": "LIMIT",
-        "name": "count",
-        "type": "integer",
-        "optional": true
-      },
-      {
-        "command": "SPARSE",
-        "name": "spread",
-        "type": "integer",
-        "optional": true
-      },
-      {
-        "command": "MATCH",
-        "name": "pattern",
-        "type": "pattern",
-        "optional": true
-      },
-      {
-        "command": "DISTANCE",
+        "command": "XX",
         "name": [],
         "type": [],
         "optional": true
       },
       {
-        "command": "WHERE",
-        "name": ["field","min","max"],
-        "type": ["string","double","double"],
-        "optional": true,
+        "command": "ID",
+        "name": ["id"],
+        "type": ["string"],
         "multiple": true
       },
       {
-        "command": "WHEREIN",
-        "name": ["field","count","value"],
-        "type": ["string","integer","double"],
-        "optional": true,
-        "multiple": true,
-        "variadic": true
-      },
+        "name": ["field","value"],
+        "type": ["string","double"],
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "BOUNDS": {
+    "summary": "Get the combined bounds of all the objects in a key",
+    "complexity": "O(1)",
+    "arguments":[
       {
-        "command": "WHEREEVAL",
-        "name": ["script","numargs","arg"],
-        "type": ["string","integer","string"],
-        "optional": true,
-        "multiple": true,
-        "variadic": true
-      },
+        "name": "key",
+        "type": "string"
+      }
+    ],
+    "since": "1.3.0",
+    "group": "keys"
+  },
+  "GET": {
+    "summary": "Get the object of an id",
+    "complexity": "O(1)",
+    "arguments":[
       {
-        "command": "WHEREEVALSHA",
-        "name": ["sha1","numargs","arg"],
-        "type": ["string","integer","string"],
-        "optional": true,
-        "multiple": true,
-        "variadic": true
+        "name": "key",
+        "type": "string"
       },
       {
-        "command": "NOFIELDS",
-        "name": [],
-        "type": [],
-        "optional": true
+        "name": "id",
+        "type": "string"
       },
       {
-        "command": "FENCE",
+        "command": "WITHFIELDS",
         "name": [],
         "type": [],
         "optional": true
       },
       {
-        "command": "DETECT",
-        "name": ["what"],
-        "type": ["string"],
-        "optional": true
-      },
-      {
-        "command": "COMMANDS",
-        "name": ["which"],
-        "type": ["string"],
+        "command": "CRS",
+        "name": [
+          "epsg"
+        ],
+        "type": [
+          "string"
+        ],
         "optional": true
       },
       {
@@ -803,77 +733,149 @@ var commandsJSON = `{
         "optional": true,
         "enumargs": [
           {
-            "name": "COUNT"
-          },
-          {
-            "name": "IDS"
+            "name": "OBJECT"
           },
           {
-            "name": "OBJECTS"
+            "name": "POINT"
           },
           {
-            "name": "POINTS"
+            "name": "BOUNDS"
           },
           {
-            "name": "BOUNDS"
+            "name": "HISTORY"
           },
           {
-            "name": "HASHES",
+            "name": "HASH",
             "arguments": [
               {
-                "name": "precision",
-                "type": "integer"
+                "name": "geohash",
+                "type": "geohash"
               }
             ]
           }
         ]
+      }
+    ],
+    "since": "1.0.0",
+    "group": "keys"
+  },
+  "MGET": {
+    "summary": "Get the objects of many ids",
+    "complexity": "O(N) where N is the number of ids",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
       },
       {
-        "name": "area",
-        "enumargs": [
-          {
-            "name": "POINT",
-            "arguments": [
-              {
-                "name": "lat",
-                "type": "double"
-              },
-              {
-                "name": "lon",
-                "type": "double"
-              },
-              {
-                "name": "meters",
-                "type": "double"
-              }
-            ]
-          },
-          {
-            "name": "ROAM",
-            "arguments":[
-              {
-                "name": "key",
-                "type": "string"
-              },
-              {
-                "name": "pattern",
-                "type": "pattern"
-              },
-              {
-                "name": "meters",
-                "type": "double"
-              }
-            ]
-          }
-        ]
+        "command": "WITHFIELDS",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "command": "WITHTTL",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "name": "id",
+        "type": "string",
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "DEL": {
+    "summary": "Delete an id from a key",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
       }
     ],
     "since": "1.0.0",
-    "group": "search"
+    "group": "keys"
   },
-  "WITHIN": {
-    "summary": "Searches for ids that completely within the area",
-    "complexity": "O(log(N)) where N is the number of ids in the area",
+  "DROP": {
+    "summary": "Remove a key from the database",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      }
+    ],
+    "since": "1.0.0",
+    "group": "keys"
+  },
+  "RENAME": {
+    "summary": "Rename a key to be stored under a different name.",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "newkey",
+        "type": "string"
+      }
+    ],
+    "since": "1.14.5",
+    "group": "keys"
+  },
+  "RENAMENX": {
+    "summary": "Rename a key to be stored under a different name, if a new key does not exist.",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "newkey",
+        "type": "string"
+      }
+    ],
+    "since": "1.14.5",
+    "group": "keys"
+  },
+  "KEYS": {
+    "summary": "Finds all keys matching the given pattern",
+    "complexity": "O(N) where N is the number of keys in the database",
+    "arguments":[
+      {
+        "name": "pattern",
+        "type": "pattern"
+      }
+    ],
+    "since": "1.0.0",
+    "group": "keys"
+  },
+  "STATS": {
+    "summary": "Show stats for one or more keys",
+    "complexity": "O(N) where N is the number of keys being requested",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string",
+        "variadic": true
+      }
+    ],
+    "since": "1.0.0",
+    "group": "keys"
+  },
+  "SEARCH": {
+    "summary": "Search for string values in a key",
+    "complexity": "O(N) where N is the number of values in the key",
     "arguments":[
       {
         "name": "key",
@@ -891,18 +893,24 @@ var commandsJSON = `{
         "type": "integer",
         "optional": true
       },
-      {
-        "command": "SPARSE",
-        "name": "spread",
-        "type": "integer",
-        "optional": true
-      },
       {
         "command": "MATCH",
         "name": "pattern",
         "type": "pattern",
         "optional": true
       },
+      {
+        "name": "order",
+        "optional": true,
+        "enumargs": [
+          {
+            "name": "ASC"
+          },
+          {
+            "name": "DESC"
+          }
+		]
+	  },
       {
         "command": "WHERE",
         "name": ["field","min","max"],
@@ -941,21 +949,34 @@ var commandsJSON = `{
         "optional": true
       },
       {
-        "command": "FENCE",
+        "command": "WITHEXPIRES",
         "name": [],
         "type": [],
         "optional": true
       },
       {
-        "command": "DETECT",
-        "name": ["what"],
-        "type": ["string"],
+        "command": "WITHTTL",
+        "name": [],
+        "type": [],
         "optional": true
       },
       {
-        "command": "COMMANDS",
-        "name": ["which"],
+        "command": "MINTTL",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true
+      },
+      {
+        "command": "TAGGED",
+        "name": ["tag"],
         "type": ["string"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "WITHINCOMPLETE",
+        "name": ["milliseconds"],
+        "type": ["double"],
         "optional": true
       },
       {
@@ -967,122 +988,166 @@ var commandsJSON = `{
           },
           {
             "name": "IDS"
-          },
-          {
-            "name": "OBJECTS"
-          },
-          {
-            "name": "POINTS"
-          },
-          {
-            "name": "BOUNDS"
-          },
-          {
-            "name": "HASHES",
-            "arguments": [
-              {
-                "name": "precision",
-                "type": "integer"
-              }
-            ]
           }
         ]
+      }
+    ],
+    "since": "1.4.2",
+    "group": "search"
+  },
+  "SCAN": {
+    "summary": "Incrementally iterate though a key",
+    "complexity": "O(N) where N is the number of ids in the key",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
       },
       {
-        "name": "area",
+        "command": "CURSOR",
+        "name": "start",
+        "type": "integer",
+        "optional": true
+      },
+      {
+        "command": "LIMIT",
+        "name": "count",
+        "type": "integer",
+        "optional": true
+      },
+      {
+        "command": "MATCH",
+        "name": "pattern",
+        "type": "pattern",
+        "optional": true
+      },
+      {
+        "name": "order",
+        "optional": true,
         "enumargs": [
           {
-            "name": "GET",
-            "arguments": [
-              {
-                "name": "key",
-                "type": "string"
-              },
-              {
-                "name": "id",
-                "type": "string"
-              }
-            ]
-          },
-          {
-            "name": "BOUNDS",
-            "arguments":[
-              {
-                "name": "minlat",
-                "type": "double"
-              },
-              {
-                "name": "minlon",
-                "type": "double"
-              },
-              {
-                "name": "maxlat",
-                "type": "double"
-              },
-              {
-                "name": "maxlon",
-                "type": "double"
-              }
-            ]
-          },
-          {
-            "name": "OBJECT",
-            "arguments":[
-              {
-                "name": "geojson",
-                "type": "geojson"
-              }
-            ]
+            "name": "ASC"
           },
           {
-            "name": "CIRCLE",
-            "arguments": [
-              {
-                "name": "lat",
-                "type": "double"
-              },
-              {
-                "name": "lon",
-                "type": "double"
-              },
-              {
-                "name": "meters",
-                "type": "double"
-              }
-            ]
+            "name": "DESC"
+          }
+		]
+	  },
+      {
+        "command": "WHERE",
+        "name": ["field","min","max"],
+        "type": ["string","double","double"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "WHEREIN",
+        "name": ["field","count","value"],
+        "type": ["string","integer","double"],
+        "optional": true,
+        "multiple": true,
+        "variadic": true
+      },
+      {
+        "command": "WHEREEVAL",
+        "name": ["script","numargs","arg"],
+        "type": ["string","integer","string"],
+        "optional": true,
+        "multiple": true,
+        "variadic": true
+      },
+      {
+        "command": "WHEREEVALSHA",
+        "name": ["sha1","numargs","arg"],
+        "type": ["string","integer","string"],
+        "optional": true,
+        "multiple": true,
+        "variadic": true
+      },
+      {
+        "command": "NOFIELDS",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "command": "WITHEXPIRES",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "command": "WITHTTL",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "command": "MINTTL",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true
+      },
+      {
+        "command": "TAGGED",
+        "name": ["tag"],
+        "type": ["string"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "WITHINCOMPLETE",
+        "name": ["milliseconds"],
+        "type": ["double"],
+        "optional": true
+      },
+      {
+        "name": "type",
+        "optional": true,
+        "enumargs": [
+          {
+            "name": "COUNT"
           },
           {
-            "name": "TILE",
-            "arguments":[
-              {
-                "name": "x",
-                "type": "double"
-              },
-              {
-                "name": "y",
-                "type": "double"
-              },
+            "name": "IDS"
+          },
+          {
+            "name": "OBJECTS"
+          },
+          {
+            "name": "POINTS"
+          },
+          {
+            "name": "BOUNDS"
+          },
+          {
+            "name": "HASHES",
+            "arguments": [
               {
-                "name": "z",
-                "type": "double"
+                "name": "precision",
+                "type": "integer"
               }
             ]
           },
           {
-            "name": "QUADKEY",
-            "arguments":[
+            "name": "HULL",
+            "arguments": [
               {
-                "name": "quadkey",
-                "type": "string"
+                "command": "CONCAVE",
+                "name": "meters",
+                "type": "double",
+                "optional": true
               }
             ]
           },
           {
-            "name": "HASH",
+            "name": "CENTROID",
             "arguments": [
               {
-                "name": "geohash",
-                "type": "geohash"
+                "command": "WEIGHT",
+                "name": "field",
+                "type": "string",
+                "optional": true
               }
             ]
           }
@@ -1092,8 +1157,33 @@ var commandsJSON = `{
     "since": "1.0.0",
     "group": "search"
   },
-  "INTERSECTS": {
-    "summary": "Searches for ids that intersect an area",
+  "MREAD": {
+    "summary": "Runs several searches against a consistent copy of their keys",
+    "complexity": "O(N+M) where N is the number of objects in the keys and M is the complexity of the searches",
+    "arguments": [
+      {
+        "name": "command",
+        "type": "string"
+      },
+      {
+        "name": "arg",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": ";",
+        "name": ["command", "arg"],
+        "type": ["string", "string"],
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "search"
+  },
+  "NEARBY": {
+    "summary": "Searches for ids that are nearby a point",
     "complexity": "O(log(N)) where N is the number of ids in the area",
     "arguments":[
       {
@@ -1124,6 +1214,12 @@ var commandsJSON = `{
         "type": "pattern",
         "optional": true
       },
+      {
+        "command": "DISTANCE",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
       {
         "command": "WHERE",
         "name": ["field","min","max"],
@@ -1156,17 +1252,42 @@ var commandsJSON = `{
         "variadic": true
       },
       {
-        "command": "CLIP",
+        "command": "NOFIELDS",
         "name": [],
         "type": [],
         "optional": true
       },
       {
-        "command": "NOFIELDS",
+        "command": "WITHEXPIRES",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "command": "WITHTTL",
         "name": [],
         "type": [],
         "optional": true
       },
+      {
+        "command": "MINTTL",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true
+      },
+      {
+        "command": "TAGGED",
+        "name": ["tag"],
+        "type": ["string"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "WITHINCOMPLETE",
+        "name": ["milliseconds"],
+        "type": ["double"],
+        "optional": true
+      },
       {
         "command": "FENCE",
         "name": [],
@@ -1212,57 +1333,36 @@ var commandsJSON = `{
                 "type": "integer"
               }
             ]
-          }
-        ]
-      },
-      {
-        "name": "area",
-        "enumargs": [
-          {
-            "name": "GET",
-            "arguments": [
-              {
-                "name": "key",
-                "type": "string"
-              },
-              {
-                "name": "id",
-                "type": "string"
-              }
-            ]
           },
           {
-            "name": "BOUNDS",
-            "arguments":[
-              {
-                "name": "minlat",
-                "type": "double"
-              },
-              {
-                "name": "minlon",
-                "type": "double"
-              },
-              {
-                "name": "maxlat",
-                "type": "double"
-              },
+            "name": "HULL",
+            "arguments": [
               {
-                "name": "maxlon",
-                "type": "double"
+                "command": "CONCAVE",
+                "name": "meters",
+                "type": "double",
+                "optional": true
               }
             ]
           },
           {
-            "name": "OBJECT",
-            "arguments":[
+            "name": "CENTROID",
+            "arguments": [
               {
-                "name": "geojson",
-                "type": "geojson"
+                "command": "WEIGHT",
+                "name": "field",
+                "type": "string",
+                "optional": true
               }
             ]
-          },
+          }
+        ]
+      },
+      {
+        "name": "area",
+        "enumargs": [
           {
-            "name": "CIRCLE",
+            "name": "POINT",
             "arguments": [
               {
                 "name": "lat",
@@ -1279,39 +1379,21 @@ var commandsJSON = `{
             ]
           },
           {
-            "name": "TILE",
+            "name": "ROAM",
             "arguments":[
               {
-                "name": "x",
-                "type": "double"
+                "name": "key",
+                "type": "string"
               },
               {
-                "name": "y",
-                "type": "double"
+                "name": "pattern",
+                "type": "pattern"
               },
               {
-                "name": "z",
+                "name": "meters",
                 "type": "double"
               }
             ]
-          },
-          {
-            "name": "QUADKEY",
-            "arguments":[
-              {
-                "name": "quadkey",
-                "type": "string"
-              }
-            ]
-          },
-          {
-            "name": "HASH",
-            "arguments": [
-              {
-                "name": "geohash",
-                "type": "geohash"
-              }
-            ]
           }
         ]
       }
@@ -1319,208 +1401,111 @@ var commandsJSON = `{
     "since": "1.0.0",
     "group": "search"
   },
-  "CONFIG GET": {
-    "summary": "Get the value of a configuration parameter",
-    "arguments":[
-      {
-        "name": "parameter",
-        "type": "string"
-      }
-    ],
-    "group": "server"
-  },
-  "CONFIG SET": {
-    "summary": "Set a configuration parameter to the given value",
+  "WITHIN": {
+    "summary": "Searches for ids that completely within the area",
+    "complexity": "O(log(N)) where N is the number of ids in the area",
     "arguments":[
       {
-        "name": "parameter",
+        "name": "key",
         "type": "string"
       },
       {
-        "name": "value",
-        "type": "string",
+        "command": "CURSOR",
+        "name": "start",
+        "type": "integer",
         "optional": true
-      }
-    ],
-    "group": "server"
-  },
-  "CONFIG REWRITE": {
-    "summary": "Rewrite the configuration file with the in memory configuration",
-    "arguments":[],
-    "group": "server"
-  },
-  "SERVER": {
-    "summary":"Show server stats and details",
-    "complexity": "O(1)",
-    "arguments": [],
-    "since": "1.0.0",
-    "group": "server"
-  },
-  "GC": {
-    "summary":"Forces a garbage collection",
-    "complexity": "O(1)",
-    "arguments": [],
-    "since": "1.0.0",
-    "group": "server"
-  },
-  "READONLY": {
-    "summary": "Turns on or off readonly mode",
-    "complexity": "O(1)",
-    "arguments": [
-      {
-        "enum": ["yes","no"]
-      }
-    ],
-    "since": "1.0.0",
-    "group": "server"
-  },
-  "FLUSHDB": {
-    "summary":"Removes all keys",
-    "complexity": "O(1)",
-    "arguments": [],
-    "since": "1.0.0",
-    "group": "server"
-  },
-  "FOLLOW": {
-    "summary": "Follows a leader host",
-    "complexity": "O(1)",
-    "arguments": [
-      {
-        "name": "host",
-        "type": "string"
       },
       {
-        "name": "port",
-        "type": "integer"
-      }
-    ],
-    "since": "1.0.0",
-    "group": "replication"
-  },
-  "AOF": {
-    "summary": "Downloads the AOF starting from pos and keeps the connection alive",
-    "complexity": "O(1)",
-    "arguments": [
-      {
-        "name": "pos",
-        "type": "integer"
-      }
-    ],
-    "since": "1.0.0",
-    "group": "replication"
-  },
-  "AOFMD5": {
-    "summary": "Performs a checksum on a portion of the aof",
-    "complexity": "O(1)",
-    "arguments": [
-      {
-        "name": "pos",
-        "type": "integer"
+        "command": "LIMIT",
+        "name": "count",
+        "type": "integer",
+        "optional": true
       },
       {
-        "name": "size",
-        "type": "integer"
-      }
-    ],
-    "since": "1.0.0",
-    "group": "replication"
-  },
-  "AOFSHRINK": {
-    "summary": "Shrinks the aof in the background",
-    "group": "replication"
-  },
-  "PING": {
-    "summary": "Ping the server",
-    "group": "connection"
-  },
-  "QUIT": {
-    "summary": "Close the connection",
-    "group": "connection"
-  },
-  "AUTH": {
-    "summary": "Authenticate to the server",
-    "arguments": [
+        "command": "SPARSE",
+        "name": "spread",
+        "type": "integer",
+        "optional": true
+      },
       {
-        "name": "password",
-        "type": "string"
-      }
-    ],
-    "group": "connection"
-  },
-  "OUTPUT": {
-    "summary": "Gets or sets the output format for the current connection.",
-    "arguments": [
+        "command": "MATCH",
+        "name": "pattern",
+        "type": "pattern",
+        "optional": true
+      },
       {
-        "name": "format",
+        "command": "WHERE",
+        "name": ["field","min","max"],
+        "type": ["string","double","double"],
         "optional": true,
-        "enumargs": [
-          {
-            "name": "json"
-          },
-          {
-            "name": "resp"
-          }
-        ]
-      }
-    ],
-    "group": "connection"
-  },
-  "TIMEOUT": {
-    "summary": "Runs the following command with the timeout",
-    "arguments": [
+        "multiple": true
+      },
       {
-        "name": "seconds",
-        "type": "double"
+        "command": "WHEREIN",
+        "name": ["field","count","value"],
+        "type": ["string","integer","double"],
+        "optional": true,
+        "multiple": true,
+        "variadic": true
       },
       {
-        "name": "COMMAND",
-        "type": "string"
+        "command": "WHEREEVAL",
+        "name": ["script","numargs","arg"],
+        "type": ["string","integer","string"],
+        "optional": true,
+        "multiple": true,
+        "variadic": true
       },
       {
-        "command": "arg",
-        "type": "string",
+        "command": "WHEREEVALSHA",
+        "name": ["sha1","numargs","arg"],
+        "type": ["string","integer","string"],
+        "optional": true,
         "multiple": true,
-        "optional": true
-      }
-    ],
-    "group": "connection"
-  },
-  "SETHOOK": {
-    "summary": "Creates a webhook which points to geofenced search",
-    "arguments": [
+        "variadic": true
+      },
       {
-        "name": "name",
-        "type": "string"
+        "command": "NOFIELDS",
+        "name": [],
+        "type": [],
+        "optional": true
       },
       {
-        "name": "endpoint",
-        "type": "string"
+        "command": "WITHEXPIRES",
+        "name": [],
+        "type": [],
+        "optional": true
       },
       {
-        "command": "META",
-        "name": ["name", "value"],
-        "type": ["string", "string"],
-        "optional": true,
-		    "multiple": true
+        "command": "WITHTTL",
+        "name": [],
+        "type": [],
+        "optional": true
       },
       {
-        "command": "EX",
+        "command": "MINTTL",
         "name": ["seconds"],
         "type": ["double"],
-        "optional": true,
-        "multiple": false
+        "optional": true
       },
       {
-        "enum": ["NEARBY", "WITHIN", "INTERSECTS"]
+        "command": "TAGGED",
+        "name": ["tag"],
+        "type": ["string"],
+        "optional": true,
+        "multiple": true
       },
       {
-        "name": "key",
-        "type": "string"
+        "command": "WITHINCOMPLETE",
+        "name": ["milliseconds"],
+        "type": ["double"],
+        "optional": true
       },
       {
         "command": "FENCE",
         "name": [],
-        "type": []
+        "type": [],
+        "optional": true
       },
       {
         "command": "DETECT",
@@ -1535,373 +1520,723 @@ var commandsJSON = `{
         "optional": true
       },
       {
-        "name": "param",
-        "type": "string",
-        "variadic": true
-      }
-
-    ],
-    "group": "webhook"
-  },
-  "DELHOOK": {
-    "summary": "Removes a webhook",
-    "arguments": [
-      {
-        "name": "name",
-        "type": "string"
-      }
-    ],
-    "group": "webhook"
-  },
-  "HOOKS": {
-    "summary": "Finds all hooks matching a pattern",
-    "arguments":[
-      {
-        "name": "pattern",
-        "type": "pattern"
-      }
-    ],
-    "group": "webhook"
-  },
-  "PDELHOOK": {
-    "summary": "Removes all hooks matching a pattern",
-    "arguments":[
-      {
-        "name": "pattern",
-        "type": "pattern"
+        "name": "type",
+        "optional": true,
+        "enumargs": [
+          {
+            "name": "COUNT"
+          },
+          {
+            "name": "IDS"
+          },
+          {
+            "name": "OBJECTS"
+          },
+          {
+            "name": "POINTS"
+          },
+          {
+            "name": "BOUNDS"
+          },
+          {
+            "name": "HASHES",
+            "arguments": [
+              {
+                "name": "precision",
+                "type": "integer"
+              }
+            ]
+          },
+          {
+            "name": "HULL",
+            "arguments": [
+              {
+                "command": "CONCAVE",
+                "name": "meters",
+                "type": "double",
+                "optional": true
+              }
+            ]
+          },
+          {
+            "name": "CENTROID",
+            "arguments": [
+              {
+                "command": "WEIGHT",
+                "name": "field",
+                "type": "string",
+                "optional": true
+              }
+            ]
+          },
+          {
+            "name": "CLUSTER",
+            "arguments": [
+              {
+                "name": "zoom",
+                "type": "integer"
+              }
+            ]
+          }
+        ]
+      },
+      {
+        "name": "area",
+        "enumargs": [
+          {
+            "name": "GET",
+            "arguments": [
+              {
+                "name": "key",
+                "type": "string"
+              },
+              {
+                "name": "id",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "BOUNDS",
+            "arguments":[
+              {
+                "name": "minlat",
+                "type": "double"
+              },
+              {
+                "name": "minlon",
+                "type": "double"
+              },
+              {
+                "name": "maxlat",
+                "type": "double"
+              },
+              {
+                "name": "maxlon",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "OBJECT",
+            "arguments":[
+              {
+                "name": "geojson",
+                "type": "geojson"
+              }
+            ]
+          },
+          {
+            "name": "CIRCLE",
+            "arguments": [
+              {
+                "name": "lat",
+                "type": "double"
+              },
+              {
+                "name": "lon",
+                "type": "double"
+              },
+              {
+                "name": "meters",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "TILE",
+            "arguments":[
+              {
+                "name": "x",
+                "type": "double"
+              },
+              {
+                "name": "y",
+                "type": "double"
+              },
+              {
+                "name": "z",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "QUADKEY",
+            "arguments":[
+              {
+                "name": "quadkey",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "HASH",
+            "arguments": [
+              {
+                "name": "geohash",
+                "type": "geohash"
+              }
+            ]
+          }
+        ]
       }
     ],
-    "group": "webhook"
+    "since": "1.0.0",
+    "group": "search"
   },
-
-  "SETCHAN": {
-    "summary": "Creates a pubsub channel which points to geofenced search",
-    "arguments": [
+  "INTERSECTS": {
+    "summary": "Searches for ids that intersect an area",
+    "complexity": "O(log(N)) where N is the number of ids in the area",
+    "arguments":[
       {
-        "name": "name",
+        "name": "key",
         "type": "string"
       },
       {
-        "command": "META",
-        "name": ["name", "value"],
-        "type": ["string", "string"],
+        "command": "CURSOR",
+        "name": "start",
+        "type": "integer",
+        "optional": true
+      },
+      {
+        "command": "LIMIT",
+        "name": "count",
+        "type": "integer",
+        "optional": true
+      },
+      {
+        "command": "SPARSE",
+        "name": "spread",
+        "type": "integer",
+        "optional": true
+      },
+      {
+        "command": "MATCH",
+        "name": "pattern",
+        "type": "pattern",
+        "optional": true
+      },
+      {
+        "command": "WHERE",
+        "name": ["field","min","max"],
+        "type": ["string","double","double"],
         "optional": true,
-		    "multiple": true
+        "multiple": true
       },
       {
-        "command": "EX",
-        "name": ["seconds"],
-        "type": ["double"],
+        "command": "WHEREIN",
+        "name": ["field","count","value"],
+        "type": ["string","integer","double"],
         "optional": true,
-        "multiple": false
+        "multiple": true,
+        "variadic": true
       },
       {
-        "enum": ["NEARBY", "WITHIN", "INTERSECTS"]
+        "command": "WHEREEVAL",
+        "name": ["script","numargs","arg"],
+        "type": ["string","integer","string"],
+        "optional": true,
+        "multiple": true,
+        "variadic": true
       },
       {
-        "name": "key",
-        "type": "string"
+        "command": "WHEREEVALSHA",
+        "name": ["sha1","numargs","arg"],
+        "type": ["string","integer","string"],
+        "optional": true,
+        "multiple": true,
+        "variadic": true
       },
       {
-        "command": "FENCE",
+        "command": "CLIP",
         "name": [],
-        "type": []
+        "type": [],
+        "optional": true
       },
       {
-        "command": "DETECT",
-        "name": ["what"],
-        "type": ["string"],
+        "command": "NOFIELDS",
+        "name": [],
+        "type": [],
         "optional": true
       },
       {
-        "command": "COMMANDS",
-        "name": ["which"],
-        "type": ["string"],
+        "command": "WITHEXPIRES",
+        "name": [],
+        "type": [],
         "optional": true
       },
       {
-        "name": "param",
-        "type": "string",
-        "variadic": true
-      }
-
-    ],
-    "group": "pubsub"
-  },
-  "DELCHAN": {
-    "summary": "Removes a channel",
-    "arguments": [
+        "command": "WITHTTL",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
       {
-        "name": "name",
-        "type": "string"
-      }
-    ],
-    "group": "pubsub"
-  },
-  "CHANS": {
-    "summary": "Finds all channels matching a pattern",
-    "arguments":[
-      {
-        "name": "pattern",
-        "type": "pattern"
-      }
-    ],
-    "group": "pubsub"
-  },
-  "PDELCHAN": {
-    "summary": "Removes all channels matching a pattern",
-    "arguments":[
-      {
-        "name": "pattern",
-        "type": "pattern"
-      }
-    ],
-    "group": "pubsub"
-  },
-  "SUBSCRIBE": {
-    "summary": "Subscribe to a geofence channel",
-    "arguments":[
-      {
-        "name": "channel",
-        "type": "string",
-        "variadic": true
-      }
-    ],
-    "group": "pubsub"
-  },
-  "PSUBSCRIBE": {
-    "summary": "Subscribes the client to the given patterns",
-    "arguments":[
-      {
-        "name": "pattern",
-        "type": "pattern",
-        "variadic": true
-      }
-    ],
-    "group": "pubsub"
-  },
-  "PDEL": {
-    "summary": "Removes all objects matching a pattern",
-    "arguments":[
-      {
-        "name": "key",
-        "type": "string"
-      },
-	  {
-        "name": "pattern",
-        "type": "pattern"
-      }
-    ],
-    "group": "keys"
-  },
-  "JGET": {
-    "summary": "Get a value from a JSON document",
-    "complexity": "O(1)",
-    "arguments":[
-      {
-        "name": "key",
-        "type": "string"
+        "command": "MINTTL",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true
       },
       {
-        "name": "id",
-        "type": "string"
+        "command": "TAGGED",
+        "name": ["tag"],
+        "type": ["string"],
+        "optional": true,
+        "multiple": true
       },
       {
-        "name": "path",
-        "type": "string"
+        "command": "WITHINCOMPLETE",
+        "name": ["milliseconds"],
+        "type": ["double"],
+        "optional": true
       },
       {
-        "command": "RAW",
+        "command": "FENCE",
         "name": [],
         "type": [],
         "optional": true
-      }
-    ],
-    "group": "keys"
-  },
-  "JSET": {
-    "summary": "Set a value in a JSON document",
-    "complexity": "O(1)",
-    "arguments":[
-      {
-        "name": "key",
-        "type": "string"
       },
       {
-        "name": "id",
-        "type": "string"
+        "command": "DETECT",
+        "name": ["what"],
+        "type": ["string"],
+        "optional": true
       },
       {
-        "name": "path",
-        "type": "string"
+        "command": "COMMANDS",
+        "name": ["which"],
+        "type": ["string"],
+        "optional": true
       },
       {
-        "name": "value",
-        "type": "string"
+        "name": "type",
+        "optional": true,
+        "enumargs": [
+          {
+            "name": "COUNT"
+          },
+          {
+            "name": "IDS"
+          },
+          {
+            "name": "OBJECTS"
+          },
+          {
+            "name": "POINTS"
+          },
+          {
+            "name": "BOUNDS"
+          },
+          {
+            "name": "HASHES",
+            "arguments": [
+              {
+                "name": "precision",
+                "type": "integer"
+              }
+            ]
+          },
+          {
+            "name": "HULL",
+            "arguments": [
+              {
+                "command": "CONCAVE",
+                "name": "meters",
+                "type": "double",
+                "optional": true
+              }
+            ]
+          },
+          {
+            "name": "CENTROID",
+            "arguments": [
+              {
+                "command": "WEIGHT",
+                "name": "field",
+                "type": "string",
+                "optional": true
+              }
+            ]
+          },
+          {
+            "name": "CLUSTER",
+            "arguments": [
+              {
+                "name": "zoom",
+                "type": "integer"
+              }
+            ]
+          }
+        ]
       },
       {
-        "name": [],
-        "optional": true,
+        "name": "area",
         "enumargs": [
           {
-            "name": "RAW"
+            "name": "GET",
+            "arguments": [
+              {
+                "name": "key",
+                "type": "string"
+              },
+              {
+                "name": "id",
+                "type": "string"
+              }
+            ]
           },
           {
-            "name": "STR"
+            "name": "BOUNDS",
+            "arguments":[
+              {
+                "name": "minlat",
+                "type": "double"
+              },
+              {
+                "name": "minlon",
+                "type": "double"
+              },
+              {
+                "name": "maxlat",
+                "type": "double"
+              },
+              {
+                "name": "maxlon",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "OBJECT",
+            "arguments":[
+              {
+                "name": "geojson",
+                "type": "geojson"
+              }
+            ]
+          },
+          {
+            "name": "CIRCLE",
+            "arguments": [
+              {
+                "name": "lat",
+                "type": "double"
+              },
+              {
+                "name": "lon",
+                "type": "double"
+              },
+              {
+                "name": "meters",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "TILE",
+            "arguments":[
+              {
+                "name": "x",
+                "type": "double"
+              },
+              {
+                "name": "y",
+                "type": "double"
+              },
+              {
+                "name": "z",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "QUADKEY",
+            "arguments":[
+              {
+                "name": "quadkey",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "HASH",
+            "arguments": [
+              {
+                "name": "geohash",
+                "type": "geohash"
+              }
+            ]
           }
         ]
       }
     ],
-    "group": "keys"
+    "since": "1.0.0",
+    "group": "search"
   },
-  "JDEL": {
-    "summary": "Delete a value from a JSON document",
-    "complexity": "O(1)",
+  "CONFIG GET": {
+    "summary": "Get the value of a configuration parameter",
     "arguments":[
       {
-        "name": "key",
-        "type": "string"
-      },
-      {
-        "name": "id",
-        "type": "string"
-      },
-      {
-        "name": "path",
+        "name": "parameter",
         "type": "string"
       }
     ],
-    "group": "keys"
+    "group": "server"
   },
-  "EVAL":{
-    "summary": "Evaluates a Lua script",
-    "complexity": "Depends on the evaluated script",
-    "arguments": [
+  "CONFIG SET": {
+    "summary": "Set a configuration parameter to the given value",
+    "arguments":[
       {
-        "name": "script",
+        "name": "parameter",
         "type": "string"
       },
       {
-        "name": "numkeys",
-        "type": "integer"
-      },
-      {
-        "name": "key",
+        "name": "value",
         "type": "string",
-        "optional": true,
-        "multiple": true
-      },
+        "optional": true
+      }
+    ],
+    "group": "server"
+  },
+  "CONFIG REWRITE": {
+    "summary": "Rewrite the configuration file with the in memory configuration",
+    "arguments":[],
+    "group": "server"
+  },
+  "SERVER": {
+    "summary":"Show server stats and details",
+    "complexity": "O(1)",
+    "arguments": [],
+    "since": "1.0.0",
+    "group": "server"
+  },
+  "DEBUG": {
+    "summary":"Runs a diagnostic subcommand, requires --enable-debug",
+    "complexity": "O(1)",
+    "arguments": [
       {
-        "name": "arg",
-        "type": "string",
-        "optional": true,
-        "multiple": true
+        "name": "subcommand",
+        "enumargs": [
+          {
+            "name": "OBJECT",
+            "arguments": [
+              {
+                "name": "key",
+                "type": "string"
+              },
+              {
+                "name": "id",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "SLEEP",
+            "arguments": [
+              {
+                "name": "seconds",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "SET-ACTIVE-EXPIRE",
+            "arguments": [
+              {
+                "name": "enabled",
+                "type": "integer"
+              }
+            ]
+          }
+        ]
       }
     ],
-    "since": "1.10.0",
-    "group": "scripting"
+    "since": "1.26.0",
+    "group": "server"
   },
-  "EVALSHA":{
-    "summary": "Evaluates a Lua script cached on the server by its SHA1 digest",
-    "complexity": "Depends on the evaluated script",
+  "BENCH": {
+    "summary":"Runs a moving object benchmark on a scratch collection",
+    "complexity": "O(N) where N is the number of requests",
     "arguments": [
       {
-        "name": "sha1",
-        "type": "string"
+        "command": "OBJECTS",
+        "name": "count",
+        "type": "integer",
+        "optional": true
       },
       {
-        "name": "numkeys",
-        "type": "integer"
+        "command": "FENCES",
+        "name": "count",
+        "type": "integer",
+        "optional": true
       },
       {
-        "name": "key",
-        "type": "string",
-        "optional": true,
-        "multiple": true
+        "command": "CLIENTS",
+        "name": "count",
+        "type": "integer",
+        "optional": true
       },
       {
-        "name": "arg",
-        "type": "string",
-        "optional": true,
-        "multiple": true
+        "command": "REQUESTS",
+        "name": "count",
+        "type": "integer",
+        "optional": true
+      },
+      {
+        "command": "UPDATES",
+        "name": "percent",
+        "type": "integer",
+        "optional": true
       }
     ],
-    "since": "1.10.0",
-    "group": "scripting"
+    "group": "server"
   },
-  "EVALRO":{
-    "summary": "Evaluates a read-only Lua script",
-    "complexity": "Depends on the evaluated script",
+  "GC": {
+    "summary":"Forces a garbage collection",
+    "complexity": "O(1)",
+    "arguments": [],
+    "since": "1.0.0",
+    "group": "server"
+  },
+  "READONLY": {
+    "summary": "Turns on or off readonly mode",
+    "complexity": "O(1)",
     "arguments": [
       {
-        "name": "script",
-        "type": "string"
-      },
+        "enum": ["yes","no"]
+      }
+    ],
+    "since": "1.0.0",
+    "group": "server"
+  },
+  "WRITES PAUSE": {
+    "summary": "Pauses the writes of the clients, rejecting or queueing them",
+    "complexity": "O(1)",
+    "arguments": [
       {
-        "name": "numkeys",
-        "type": "integer"
+        "enum": ["REJECT","QUEUE"],
+        "optional": true
       },
       {
-        "name": "key",
-        "type": "string",
-        "optional": true,
-        "multiple": true
+        "command": "TIMEOUT",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true
       },
       {
-        "name": "arg",
-        "type": "string",
-        "optional": true,
-        "multiple": true
+        "command": "MESSAGE",
+        "name": ["text"],
+        "type": ["string"],
+        "optional": true
       }
     ],
-    "since": "1.10.0",
-    "group": "scripting"
+    "since": "1.26.0",
+    "group": "server"
   },
-  "EVALROSHA":{
-    "summary": "Evaluates a read-only Lua script cached on the server by its SHA1 digest",
-    "complexity": "Depends on the evaluated script",
+  "WRITES RESUME": {
+    "summary": "Resumes the writes of the clients",
+    "complexity": "O(N) where N is the number of queued writes",
+    "arguments": [],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "WRITES STATUS": {
+    "summary": "Returns whether the writes are paused",
+    "complexity": "O(1)",
+    "arguments": [],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "CURSORS LIST": {
+    "summary": "Returns the open cursors of the scans and searches of the clients",
+    "complexity": "O(N) where N is the number of open cursors",
+    "arguments": [],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "CURSORS KILL": {
+    "summary": "Kills open cursors, so that their next page fails",
+    "complexity": "O(N) where N is the number of open cursors",
     "arguments": [
       {
-        "name": "script",
-        "type": "string"
-      },
-      {
-        "name": "numkeys",
-        "type": "integer"
+        "command": "CLIENT",
+        "name": ["client-id"],
+        "type": ["integer"],
+        "optional": true
       },
       {
-        "name": "key",
-        "type": "string",
+        "name": "id",
+        "type": "integer",
         "optional": true,
         "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "QUERIES LIST": {
+    "summary": "Returns the searches that are running",
+    "complexity": "O(N) where N is the number of running searches",
+    "arguments": [],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "QUERIES KILL": {
+    "summary": "Cancels running searches, so that they fail with a canceled error",
+    "complexity": "O(N) where N is the number of running searches",
+    "arguments": [
+      {
+        "command": "CLIENT",
+        "name": ["client-id"],
+        "type": ["integer"],
+        "optional": true
       },
       {
-        "name": "arg",
-        "type": "string",
+        "name": "id",
+        "type": "integer",
         "optional": true,
         "multiple": true
       }
     ],
-    "since": "1.10.0",
-    "group": "scripting"
+    "since": "1.26.0",
+    "group": "server"
   },
-  "EVALNA":{
-    "summary": "Evaluates a Lua script in a non-atomic fashion",
-    "complexity": "Depends on the evaluated script",
+  "PREPARE": {
+    "summary": "Stores a search with $1, $2, ... placeholders for its arguments",
+    "complexity": "O(N) where N is the size of the OBJECT areas of the search",
     "arguments": [
       {
-        "name": "script",
+        "name": "name",
         "type": "string"
       },
       {
-        "name": "numkeys",
-        "type": "integer"
+        "name": "command",
+        "enumargs": [
+          {
+            "name": "SCAN",
+            "arguments": []
+          },
+          {
+            "name": "SEARCH",
+            "arguments": []
+          },
+          {
+            "name": "NEARBY",
+            "arguments": []
+          },
+          {
+            "name": "WITHIN",
+            "arguments": []
+          },
+          {
+            "name": "INTERSECTS",
+            "arguments": []
+          }
+        ]
       },
       {
         "name": "key",
-        "type": "string",
-        "optional": true,
-        "multiple": true
+        "type": "string"
       },
       {
         "name": "arg",
@@ -1910,317 +2245,2774 @@ var commandsJSON = `{
         "multiple": true
       }
     ],
-    "since": "1.10.0",
-    "group": "scripting"
+    "since": "1.26.0",
+    "group": "search"
   },
-  "EVALNASHA":{
-    "summary": "Evaluates, in a non-atomic fashion, a Lua script cached on the server by its SHA1 digest",
-    "complexity": "Depends on the evaluated script",
+  "EXECUTE": {
+    "summary": "Runs a prepared search with parameters for its placeholders",
+    "complexity": "The complexity of the prepared search",
     "arguments": [
       {
-        "name": "sha1",
+        "name": "name",
         "type": "string"
       },
       {
-        "name": "numkeys",
-        "type": "integer"
-      },
-      {
-        "name": "key",
-        "type": "string",
-        "optional": true,
-        "multiple": true
-      },
-      {
-        "name": "arg",
+        "name": "param",
         "type": "string",
         "optional": true,
         "multiple": true
       }
     ],
-    "since": "1.10.0",
-    "group": "scripting"
+    "since": "1.26.0",
+    "group": "search"
   },
-  "SCRIPT EXISTS":{
-    "summary": "Returns information about the existence of the scripts in server cache",
-    "complexity": "O(N) where N is the number of provided sha1 arguments",
+  "DEALLOCATE": {
+    "summary": "Removes a prepared search",
+    "complexity": "O(1)",
     "arguments": [
       {
-        "name": "sha1",
-        "type": "string",
-        "multiple": true
+        "name": "name",
+        "type": "string"
       }
     ],
-    "since": "1.10.0",
-    "group": "scripting"
+    "since": "1.26.0",
+    "group": "search"
   },
-  "SCRIPT LOAD":{
-    "summary": "Loads the compiled version of a script into the server cache, without executing",
-    "complexity": "O(N) where N is the number of bytes in the script",
+  "HOTKEYS": {
+    "summary": "Returns the most used keys or objects of the last minutes",
+    "complexity": "O(1)",
     "arguments": [
       {
-        "name": "script",
+        "name": "objects",
+        "enumargs": [
+          {
+            "name": "OBJECTS",
+            "arguments": []
+          },
+          {
+            "name": "RESET",
+            "arguments": []
+          }
+        ],
+        "optional": true
+      },
+      {
+        "command": "COUNT",
+        "name": ["count"],
+        "type": ["integer"],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "WARMUP": {
+    "summary": "Warms up the keys for the searches",
+    "complexity": "O(N) where N is the number of objects of the keys",
+    "arguments": [
+      {
+        "name": "pattern",
+        "type": "pattern",
+        "optional": true
+      },
+      {
+        "command": "CLUSTERS",
+        "name": [],
+        "type": [],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "FLUSHDB": {
+    "summary":"Removes all keys",
+    "complexity": "O(1)",
+    "arguments": [],
+    "since": "1.0.0",
+    "group": "server"
+  },
+  "FOLLOW": {
+    "summary": "Follows a leader host",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "host",
         "type": "string"
+      },
+      {
+        "name": "port",
+        "type": "integer"
       }
     ],
-    "since": "1.10.0",
-    "group": "scripting"
+    "since": "1.0.0",
+    "group": "replication"
   },
-  "SCRIPT FLUSH":{
-    "summary": "Flushes the server cache of Lua scripts",
+  "AOF": {
+    "summary": "Downloads the AOF starting from pos and keeps the connection alive",
     "complexity": "O(1)",
-    "since": "1.10.0",
-    "group": "scripting"
+    "arguments": [
+      {
+        "name": "pos",
+        "type": "integer"
+      }
+    ],
+    "since": "1.0.0",
+    "group": "replication"
   },
-  "TEST":{
-    "summary": "Performs spatial test",
-    "complexity": "One test per command, complexity depends on the test",
+  "AOFMD5": {
+    "summary": "Performs a checksum on a portion of the aof",
+    "complexity": "O(1)",
     "arguments": [
       {
-        "name": "area1",
-        "enumargs": [
-          {
-            "name": "POINT",
-            "arguments": [
-              {
-                "name": "lat",
-                "type": "double"
-              },
-              {
-                "name": "lon",
-                "type": "double"
-              }
-            ]
-          },
-          {
-            "name": "GET",
-            "arguments": [
-              {
-                "name": "key",
-                "type": "string"
-              },
-              {
-                "name": "id",
-                "type": "string"
-              }
-            ]
-          },
-          {
-            "name": "BOUNDS",
-            "arguments":[
-              {
-                "name": "minlat",
-                "type": "double"
-              },
-              {
-                "name": "minlon",
-                "type": "double"
-              },
-              {
-                "name": "maxlat",
-                "type": "double"
-              },
-              {
-                "name": "maxlon",
-                "type": "double"
-              }
-            ]
-          },
-          {
-            "name": "OBJECT",
-            "arguments":[
-              {
-                "name": "geojson",
-                "type": "geojson"
-              }
-            ]
-          },
-          {
-            "name": "CIRCLE",
-            "arguments": [
-              {
-                "name": "lat",
-                "type": "double"
-              },
-              {
-                "name": "lon",
-                "type": "double"
-              },
-              {
-                "name": "meters",
-                "type": "double"
-              }
-            ]
-          },
-          {
-            "name": "TILE",
-            "arguments":[
-              {
-                "name": "x",
-                "type": "double"
-              },
-              {
-                "name": "y",
-                "type": "double"
-              },
-              {
-                "name": "z",
-                "type": "double"
-              }
-            ]
-          },
-          {
-            "name": "QUADKEY",
-            "arguments":[
-              {
-                "name": "quadkey",
-                "type": "string"
-              }
-            ]
-          },
-          {
-            "name": "HASH",
-            "arguments": [
-              {
-                "name": "geohash",
-                "type": "geohash"
-              }
-            ]
-          }
-        ]
+        "name": "pos",
+        "type": "integer"
       },
       {
-        "name": "test",
-        "enumargs": [
-          {
-            "name": "INTERSECTS"
-          },
-          {
-            "name": "WITHIN"
-          }
-        ]
+        "name": "size",
+        "type": "integer"
+      }
+    ],
+    "since": "1.0.0",
+    "group": "replication"
+  },
+  "AOFSHRINK": {
+    "summary": "Shrinks the aof in the background",
+    "group": "replication"
+  },
+  "VERIFY": {
+    "summary": "Returns the digests of ranges of the ids of a key, or compares them with the leader",
+    "complexity": "O(N) where N is the number of objects in the range",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
       },
       {
-        "command": "CLIP",
+        "command": "RANGE",
+        "name": ["start", "end"],
+        "type": ["string", "string"],
+        "optional": true
+      },
+      {
+        "command": "SPLIT",
+        "name": ["n"],
+        "type": ["integer"],
+        "optional": true
+      },
+      {
+        "command": "LEADER",
         "name": [],
         "type": [],
         "optional": true
       },
       {
-        "name": "area2",
-        "enumargs": [
-          {
-            "name": "POINT",
-            "arguments": [
-              {
-                "name": "lat",
-                "type": "double"
-              },
-              {
-                "name": "lon",
-                "type": "double"
-              }
-            ]
-          },
-          {
-            "name": "GET",
-            "arguments": [
-              {
-                "name": "key",
-                "type": "string"
-              },
-              {
-                "name": "id",
-                "type": "string"
-              }
-            ]
-          },
-          {
-            "name": "BOUNDS",
-            "arguments":[
-              {
-                "name": "minlat",
-                "type": "double"
-              },
-              {
-                "name": "minlon",
-                "type": "double"
-              },
-              {
-                "name": "maxlat",
-                "type": "double"
-              },
-              {
-                "name": "maxlon",
-                "type": "double"
-              }
-            ]
-          },
-          {
-            "name": "OBJECT",
-            "arguments":[
-              {
-                "name": "geojson",
-                "type": "geojson"
-              }
-            ]
-          },
-          {
-            "name": "CIRCLE",
-            "arguments": [
-              {
-                "name": "lat",
-                "type": "double"
-              },
-              {
-                "name": "lon",
-                "type": "double"
-              },
-              {
-                "name": "meters",
-                "type": "double"
-              }
-            ]
-          },
-          {
-            "name": "TILE",
-            "arguments":[
-              {
-                "name": "x",
-                "type": "double"
-              },
-              {
-                "name": "y",
-                "type": "double"
-              },
-              {
-                "name": "z",
-                "type": "double"
-              }
-            ]
-          },
+        "command": "REPAIR",
+        "name": ["id"],
+        "type": ["string"],
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "replication"
+  },
+  "CDC": {
+    "summary": "Streams the changes after an offset of the aof as protobuf records",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "offset",
+        "type": "integer"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "replication"
+  },
+  "PING": {
+    "summary": "Ping the server",
+    "group": "connection"
+  },
+  "QUIT": {
+    "summary": "Close the connection",
+    "group": "connection"
+  },
+  "AUTH": {
+    "summary": "Authenticate to the server with a password or a JWT, to a namespace or a role, or as an LDAP user",
+    "arguments": [
+      {
+        "name": "namespace-role-or-user",
+        "type": "string",
+        "optional": true
+      },
+      {
+        "name": "password",
+        "type": "string"
+      }
+    ],
+    "group": "connection"
+  },
+  "SELECT": {
+    "summary": "Switches the namespace of the connection",
+    "arguments": [
+      {
+        "name": "namespace",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "connection"
+  },
+  "NSSET": {
+    "summary": "Creates a namespace, or sets its password and quotas",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "namespace",
+        "type": "string"
+      },
+      {
+        "command": "PASSWORD",
+        "name": ["password"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "command": "MAXMEMORY",
+        "name": ["size"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "command": "MAXOBJECTS",
+        "name": ["count"],
+        "type": ["integer"],
+        "optional": true
+      },
+      {
+        "command": "MAXHOOKS",
+        "name": ["count"],
+        "type": ["integer"],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "NSDEL": {
+    "summary": "Removes a namespace, but not its keys and hooks",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "namespace",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "NSINFO": {
+    "summary": "Returns the quotas and usage of namespaces",
+    "complexity": "O(N) where N is the number of keys in the namespaces",
+    "arguments":[
+      {
+        "name": "namespace",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "ROLESET": {
+    "summary": "Creates a read-only role, or sets its password and redaction rules",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "role",
+        "type": "string"
+      },
+      {
+        "command": "PASSWORD",
+        "name": ["password"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "command": "REDACT",
+        "name": ["pattern"],
+        "type": ["pattern"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "HIDE",
+        "name": ["field"],
+        "type": ["string"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "PRECISION",
+        "name": ["decimals"],
+        "type": ["integer"],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "ROLEDEL": {
+    "summary": "Removes a role",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "role",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "ROLES": {
+    "summary": "Returns the redaction rules of roles",
+    "complexity": "O(N) where N is the number of roles",
+    "arguments":[
+      {
+        "name": "role",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "server"
+  },
+  "OUTPUT": {
+    "summary": "Gets or sets the output format for the current connection.",
+    "arguments": [
+      {
+        "name": "format",
+        "optional": true,
+        "enumargs": [
           {
-            "name": "QUADKEY",
-            "arguments":[
-              {
-                "name": "quadkey",
-                "type": "string"
-              }
-            ]
+            "name": "json"
           },
           {
-            "name": "HASH",
-            "arguments": [
-              {
-                "name": "geohash",
-                "type": "geohash"
-              }
-            ]
+            "name": "resp"
           }
         ]
       }
     ],
-    "since": "1.16.0",
-    "group": "tests"
+    "group": "connection"
+  },
+  "TIMEOUT": {
+    "summary": "Runs the following command with the timeout",
+    "arguments": [
+      {
+        "name": "seconds",
+        "type": "double"
+      },
+      {
+        "name": "COMMAND",
+        "type": "string"
+      },
+      {
+        "command": "arg",
+        "type": "string",
+        "multiple": true,
+        "optional": true
+      }
+    ],
+    "group": "connection"
+  },
+  "IDEMPOTENT": {
+    "summary": "Runs the following write once for the token",
+    "since": "1.26.0",
+    "arguments": [
+      {
+        "name": "token",
+        "type": "string"
+      },
+      {
+        "name": "COMMAND",
+        "type": "string"
+      },
+      {
+        "command": "arg",
+        "type": "string",
+        "multiple": true,
+        "optional": true
+      }
+    ],
+    "group": "keys"
+  },
+  "SETHOOK": {
+    "summary": "Creates a webhook which points to geofenced search",
+    "arguments": [
+      {
+        "name": "name",
+        "type": "string"
+      },
+      {
+        "name": "endpoint",
+        "type": "string"
+      },
+      {
+        "command": "META",
+        "name": ["name", "value"],
+        "type": ["string", "string"],
+        "optional": true,
+		    "multiple": true
+      },
+      {
+        "command": "EX",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true,
+        "multiple": false
+      },
+      {
+        "command": "INACTIVE",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true,
+        "multiple": false
+      },
+      {
+        "command": "OCCUPANCY",
+        "name": ["minutes"],
+        "type": ["double"],
+        "optional": true,
+        "multiple": false
+      },
+      {
+        "command": "THRESHOLD",
+        "name": ["field", "above", "below"],
+        "type": ["string", "double", "double"],
+        "optional": true,
+        "multiple": false
+      },
+      {
+        "enum": ["NEARBY", "WITHIN", "INTERSECTS"]
+      },
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "command": "FENCE",
+        "name": [],
+        "type": []
+      },
+      {
+        "command": "DETECT",
+        "name": ["what"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "command": "COMMANDS",
+        "name": ["which"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "name": "param",
+        "type": "string",
+        "variadic": true
+      }
+
+    ],
+    "group": "webhook"
+  },
+  "DELHOOK": {
+    "summary": "Removes a webhook",
+    "arguments": [
+      {
+        "name": "name",
+        "type": "string"
+      }
+    ],
+    "group": "webhook"
+  },
+  "HOOKS": {
+    "summary": "Finds all hooks matching a pattern",
+    "arguments":[
+      {
+        "name": "pattern",
+        "type": "pattern"
+      }
+    ],
+    "group": "webhook"
+  },
+  "OCCUPANCY": {
+    "summary": "Returns the objects inside of the fences of the hooks with OCCUPANCY, and the ones that entered and exited them",
+    "complexity": "O(N) where N is the number of hooks",
+    "arguments":[
+      {
+        "name": "pattern",
+        "type": "pattern"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "webhook"
+  },
+  "HOOKEXPORT": {
+    "summary": "Exports the hooks and channels matching a pattern as JSON",
+    "complexity": "O(N) where N is the number of hooks",
+    "arguments":[
+      {
+        "name": "pattern",
+        "type": "pattern",
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "webhook"
+  },
+  "HOOKIMPORT": {
+    "summary": "Imports the hooks and channels of a HOOKEXPORT document",
+    "complexity": "O(N) where N is the number of hooks",
+    "arguments":[
+      {
+        "name": "document",
+        "type": "string"
+      },
+      {
+        "name": "conflict",
+        "optional": true,
+        "enumargs": [
+          {
+            "name": "FAIL"
+          },
+          {
+            "name": "SKIP"
+          },
+          {
+            "name": "REPLACE"
+          }
+        ]
+      }
+    ],
+    "since": "1.26.0",
+    "group": "webhook"
+  },
+  "PDELHOOK": {
+    "summary": "Removes all hooks matching a pattern",
+    "arguments":[
+      {
+        "name": "pattern",
+        "type": "pattern"
+      }
+    ],
+    "group": "webhook"
+  },
+
+  "SETCHAN": {
+    "summary": "Creates a pubsub channel which points to geofenced search",
+    "arguments": [
+      {
+        "name": "name",
+        "type": "string"
+      },
+      {
+        "command": "META",
+        "name": ["name", "value"],
+        "type": ["string", "string"],
+        "optional": true,
+		    "multiple": true
+      },
+      {
+        "command": "EX",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true,
+        "multiple": false
+      },
+      {
+        "command": "INACTIVE",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true,
+        "multiple": false
+      },
+      {
+        "command": "OCCUPANCY",
+        "name": ["minutes"],
+        "type": ["double"],
+        "optional": true,
+        "multiple": false
+      },
+      {
+        "command": "THRESHOLD",
+        "name": ["field", "above", "below"],
+        "type": ["string", "double", "double"],
+        "optional": true,
+        "multiple": false
+      },
+      {
+        "enum": ["NEARBY", "WITHIN", "INTERSECTS"]
+      },
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "command": "FENCE",
+        "name": [],
+        "type": []
+      },
+      {
+        "command": "DETECT",
+        "name": ["what"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "command": "COMMANDS",
+        "name": ["which"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "name": "param",
+        "type": "string",
+        "variadic": true
+      }
+
+    ],
+    "group": "pubsub"
+  },
+  "DELCHAN": {
+    "summary": "Removes a channel",
+    "arguments": [
+      {
+        "name": "name",
+        "type": "string"
+      }
+    ],
+    "group": "pubsub"
+  },
+  "CHANS": {
+    "summary": "Finds all channels matching a pattern",
+    "arguments":[
+      {
+        "name": "pattern",
+        "type": "pattern"
+      }
+    ],
+    "group": "pubsub"
+  },
+  "CHANACK": {
+    "summary": "Commits the last event of a channel that a consumer has handled",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "channel",
+        "type": "string"
+      },
+      {
+        "name": "consumer",
+        "type": "string"
+      },
+      {
+        "name": "seq",
+        "type": "integer"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "pubsub"
+  },
+  "CHANACKS": {
+    "summary": "Returns the last event of a channel and the events acked by its consumers",
+    "complexity": "O(N) where N is the number of consumers of the channel",
+    "arguments":[
+      {
+        "name": "channel",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "pubsub"
+  },
+  "PDELCHAN": {
+    "summary": "Removes all channels matching a pattern",
+    "arguments":[
+      {
+        "name": "pattern",
+        "type": "pattern"
+      }
+    ],
+    "group": "pubsub"
+  },
+  "SUBSCRIBE": {
+    "summary": "Subscribe to a geofence channel",
+    "arguments":[
+      {
+        "name": "channel",
+        "type": "string",
+        "variadic": true
+      },
+      {
+        "command": "FILTER",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "command": "MATCH",
+        "name": ["pattern"],
+        "type": ["pattern"],
+        "optional": true
+      },
+      {
+        "command": "DETECT",
+        "name": ["what"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "command": "WHERE",
+        "name": ["field","min","max"],
+        "type": ["string","double","double"],
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "group": "pubsub"
+  },
+  "PSUBSCRIBE": {
+    "summary": "Subscribes the client to the given patterns",
+    "arguments":[
+      {
+        "name": "pattern",
+        "type": "pattern",
+        "variadic": true
+      },
+      {
+        "command": "FILTER",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "command": "MATCH",
+        "name": ["pattern"],
+        "type": ["pattern"],
+        "optional": true
+      },
+      {
+        "command": "DETECT",
+        "name": ["what"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "command": "WHERE",
+        "name": ["field","min","max"],
+        "type": ["string","double","double"],
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "group": "pubsub"
+  },
+  "NOTIFY": {
+    "summary": "Sends a message to the hooks and channels matching a pattern",
+    "complexity": "O(N) where N is the number of hooks",
+    "arguments":[
+      {
+        "name": "pattern",
+        "type": "pattern"
+      },
+      {
+        "name": "type",
+        "type": "string"
+      },
+      {
+        "name": "message",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "pubsub"
+  },
+  "PDEL": {
+    "summary": "Removes all objects matching a pattern",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+	  {
+        "name": "pattern",
+        "type": "pattern"
+      }
+    ],
+    "group": "keys"
+  },
+  "DELWHERE": {
+    "summary": "Removes all objects matching a scan or a search, in batches",
+    "complexity": "O(N) where N is the number of objects searched",
+    "arguments": [
+      {
+        "command": "BATCH",
+        "name": ["size"],
+        "type": ["integer"],
+        "optional": true
+      },
+      {
+        "name": "dryrun",
+        "enumargs": [
+          {
+            "name": "DRYRUN",
+            "arguments": []
+          }
+        ],
+        "optional": true
+      },
+      {
+        "name": "command",
+        "enumargs": [
+          {
+            "name": "SCAN",
+            "arguments": []
+          },
+          {
+            "name": "WITHIN",
+            "arguments": []
+          },
+          {
+            "name": "INTERSECTS",
+            "arguments": []
+          }
+        ]
+      },
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "arg",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "COPY": {
+    "summary": "Copies the objects matching a scan or a search to another key, in batches",
+    "complexity": "O(N) where N is the number of objects searched",
+    "arguments": [
+      {
+        "name": "source",
+        "type": "string"
+      },
+      {
+        "name": "destination",
+        "type": "string"
+      },
+      {
+        "command": "BATCH",
+        "name": ["size"],
+        "type": ["integer"],
+        "optional": true
+      },
+      {
+        "name": "dryrun",
+        "enumargs": [
+          {
+            "name": "DRYRUN",
+            "arguments": []
+          }
+        ],
+        "optional": true
+      },
+      {
+        "name": "command",
+        "enumargs": [
+          {
+            "name": "SCAN",
+            "arguments": []
+          },
+          {
+            "name": "WITHIN",
+            "arguments": []
+          },
+          {
+            "name": "INTERSECTS",
+            "arguments": []
+          }
+        ]
+      },
+      {
+        "name": "arg",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "MOVE": {
+    "summary": "Moves the objects matching a scan or a search to another key, in batches",
+    "complexity": "O(N) where N is the number of objects searched",
+    "arguments": [
+      {
+        "name": "source",
+        "type": "string"
+      },
+      {
+        "name": "destination",
+        "type": "string"
+      },
+      {
+        "command": "BATCH",
+        "name": ["size"],
+        "type": ["integer"],
+        "optional": true
+      },
+      {
+        "name": "dryrun",
+        "enumargs": [
+          {
+            "name": "DRYRUN",
+            "arguments": []
+          }
+        ],
+        "optional": true
+      },
+      {
+        "name": "command",
+        "enumargs": [
+          {
+            "name": "SCAN",
+            "arguments": []
+          },
+          {
+            "name": "WITHIN",
+            "arguments": []
+          },
+          {
+            "name": "INTERSECTS",
+            "arguments": []
+          }
+        ]
+      },
+      {
+        "name": "arg",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "JGET": {
+    "summary": "Get a value from a JSON document",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "path",
+        "type": "string"
+      },
+      {
+        "command": "RAW",
+        "name": [],
+        "type": [],
+        "optional": true
+      }
+    ],
+    "group": "keys"
+  },
+  "JSET": {
+    "summary": "Set a value in a JSON document",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "path",
+        "type": "string"
+      },
+      {
+        "name": "value",
+        "type": "string"
+      },
+      {
+        "name": [],
+        "optional": true,
+        "enumargs": [
+          {
+            "name": "RAW"
+          },
+          {
+            "name": "STR"
+          }
+        ]
+      }
+    ],
+    "group": "keys"
+  },
+  "JDEL": {
+    "summary": "Delete a value from a JSON document",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "path",
+        "type": "string"
+      }
+    ],
+    "group": "keys"
+  },
+  "JINCRBY": {
+    "summary": "Increment a number in a JSON document",
+    "complexity": "O(1)",
+    "since": "1.26.0",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "path",
+        "type": "string"
+      },
+      {
+        "name": "amount",
+        "type": "double"
+      }
+    ],
+    "group": "keys"
+  },
+  "JDECRBY": {
+    "summary": "Decrement a number in a JSON document",
+    "complexity": "O(1)",
+    "since": "1.26.0",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "path",
+        "type": "string"
+      },
+      {
+        "name": "amount",
+        "type": "double"
+      }
+    ],
+    "group": "keys"
+  },
+  "JAPPEND": {
+    "summary": "Append a value to an array in a JSON document",
+    "complexity": "O(1)",
+    "since": "1.26.0",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "path",
+        "type": "string"
+      },
+      {
+        "name": "value",
+        "type": "string"
+      },
+      {
+        "name": [],
+        "optional": true,
+        "enumargs": [
+          {
+            "name": "RAW"
+          },
+          {
+            "name": "STR"
+          }
+        ]
+      }
+    ],
+    "group": "keys"
+  },
+  "JINSERT": {
+    "summary": "Insert a value into an array in a JSON document",
+    "complexity": "O(1)",
+    "since": "1.26.0",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "path",
+        "type": "string"
+      },
+      {
+        "name": "index",
+        "type": "integer"
+      },
+      {
+        "name": "value",
+        "type": "string"
+      },
+      {
+        "name": [],
+        "optional": true,
+        "enumargs": [
+          {
+            "name": "RAW"
+          },
+          {
+            "name": "STR"
+          }
+        ]
+      }
+    ],
+    "group": "keys"
+  },
+  "EVAL":{
+    "summary": "Evaluates a Lua script",
+    "complexity": "Depends on the evaluated script",
+    "arguments": [
+      {
+        "name": "script",
+        "type": "string"
+      },
+      {
+        "name": "numkeys",
+        "type": "integer"
+      },
+      {
+        "name": "key",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "name": "arg",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.10.0",
+    "group": "scripting"
+  },
+  "EVALSHA":{
+    "summary": "Evaluates a Lua script cached on the server by its SHA1 digest",
+    "complexity": "Depends on the evaluated script",
+    "arguments": [
+      {
+        "name": "sha1",
+        "type": "string"
+      },
+      {
+        "name": "numkeys",
+        "type": "integer"
+      },
+      {
+        "name": "key",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "name": "arg",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.10.0",
+    "group": "scripting"
+  },
+  "EVALRO":{
+    "summary": "Evaluates a read-only Lua script",
+    "complexity": "Depends on the evaluated script",
+    "arguments": [
+      {
+        "name": "script",
+        "type": "string"
+      },
+      {
+        "name": "numkeys",
+        "type": "integer"
+      },
+      {
+        "name": "key",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "name": "arg",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.10.0",
+    "group": "scripting"
+  },
+  "EVALROSHA":{
+    "summary": "Evaluates a read-only Lua script cached on the server by its SHA1 digest",
+    "complexity": "Depends on the evaluated script",
+    "arguments": [
+      {
+        "name": "script",
+        "type": "string"
+      },
+      {
+        "name": "numkeys",
+        "type": "integer"
+      },
+      {
+        "name": "key",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "name": "arg",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.10.0",
+    "group": "scripting"
+  },
+  "EVALNA":{
+    "summary": "Evaluates a Lua script in a non-atomic fashion",
+    "complexity": "Depends on the evaluated script",
+    "arguments": [
+      {
+        "name": "script",
+        "type": "string"
+      },
+      {
+        "name": "numkeys",
+        "type": "integer"
+      },
+      {
+        "name": "key",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "name": "arg",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.10.0",
+    "group": "scripting"
+  },
+  "EVALNASHA":{
+    "summary": "Evaluates, in a non-atomic fashion, a Lua script cached on the server by its SHA1 digest",
+    "complexity": "Depends on the evaluated script",
+    "arguments": [
+      {
+        "name": "sha1",
+        "type": "string"
+      },
+      {
+        "name": "numkeys",
+        "type": "integer"
+      },
+      {
+        "name": "key",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "name": "arg",
+        "type": "string",
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.10.0",
+    "group": "scripting"
+  },
+  "SCRIPT EXISTS":{
+    "summary": "Returns information about the existence of the scripts in server cache",
+    "complexity": "O(N) where N is the number of provided sha1 arguments",
+    "arguments": [
+      {
+        "name": "sha1",
+        "type": "string",
+        "multiple": true
+      }
+    ],
+    "since": "1.10.0",
+    "group": "scripting"
+  },
+  "SCRIPT LOAD":{
+    "summary": "Loads the compiled version of a script into the server cache, without executing",
+    "complexity": "O(N) where N is the number of bytes in the script",
+    "arguments": [
+      {
+        "name": "script",
+        "type": "string"
+      }
+    ],
+    "since": "1.10.0",
+    "group": "scripting"
+  },
+  "SCRIPT FLUSH":{
+    "summary": "Flushes the server cache of Lua scripts",
+    "complexity": "O(1)",
+    "since": "1.10.0",
+    "group": "scripting"
+  },
+  "TEST":{
+    "summary": "Performs spatial test",
+    "complexity": "One test per command, complexity depends on the test",
+    "arguments": [
+      {
+        "name": "area1",
+        "enumargs": [
+          {
+            "name": "POINT",
+            "arguments": [
+              {
+                "name": "lat",
+                "type": "double"
+              },
+              {
+                "name": "lon",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "GET",
+            "arguments": [
+              {
+                "name": "key",
+                "type": "string"
+              },
+              {
+                "name": "id",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "BOUNDS",
+            "arguments":[
+              {
+                "name": "minlat",
+                "type": "double"
+              },
+              {
+                "name": "minlon",
+                "type": "double"
+              },
+              {
+                "name": "maxlat",
+                "type": "double"
+              },
+              {
+                "name": "maxlon",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "OBJECT",
+            "arguments":[
+              {
+                "name": "geojson",
+                "type": "geojson"
+              }
+            ]
+          },
+          {
+            "name": "CIRCLE",
+            "arguments": [
+              {
+                "name": "lat",
+                "type": "double"
+              },
+              {
+                "name": "lon",
+                "type": "double"
+              },
+              {
+                "name": "meters",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "TILE",
+            "arguments":[
+              {
+                "name": "x",
+                "type": "double"
+              },
+              {
+                "name": "y",
+                "type": "double"
+              },
+              {
+                "name": "z",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "QUADKEY",
+            "arguments":[
+              {
+                "name": "quadkey",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "HASH",
+            "arguments": [
+              {
+                "name": "geohash",
+                "type": "geohash"
+              }
+            ]
+          }
+        ]
+      },
+      {
+        "name": "test",
+        "enumargs": [
+          {
+            "name": "INTERSECTS"
+          },
+          {
+            "name": "WITHIN"
+          }
+        ]
+      },
+      {
+        "command": "CLIP",
+        "name": [],
+        "type": [],
+        "optional": true
+      },
+      {
+        "name": "area2",
+        "enumargs": [
+          {
+            "name": "POINT",
+            "arguments": [
+              {
+                "name": "lat",
+                "type": "double"
+              },
+              {
+                "name": "lon",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "GET",
+            "arguments": [
+              {
+                "name": "key",
+                "type": "string"
+              },
+              {
+                "name": "id",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "BOUNDS",
+            "arguments":[
+              {
+                "name": "minlat",
+                "type": "double"
+              },
+              {
+                "name": "minlon",
+                "type": "double"
+              },
+              {
+                "name": "maxlat",
+                "type": "double"
+              },
+              {
+                "name": "maxlon",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "OBJECT",
+            "arguments":[
+              {
+                "name": "geojson",
+                "type": "geojson"
+              }
+            ]
+          },
+          {
+            "name": "CIRCLE",
+            "arguments": [
+              {
+                "name": "lat",
+                "type": "double"
+              },
+              {
+                "name": "lon",
+                "type": "double"
+              },
+              {
+                "name": "meters",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "TILE",
+            "arguments":[
+              {
+                "name": "x",
+                "type": "double"
+              },
+              {
+                "name": "y",
+                "type": "double"
+              },
+              {
+                "name": "z",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "QUADKEY",
+            "arguments":[
+              {
+                "name": "quadkey",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "HASH",
+            "arguments": [
+              {
+                "name": "geohash",
+                "type": "geohash"
+              }
+            ]
+          }
+        ]
+      }
+    ],
+    "since": "1.16.0",
+    "group": "tests"
+  },
+  "GEOHASH ENCODE": {
+    "summary": "Encodes a point into a geohash",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "lat",
+        "type": "double"
+      },
+      {
+        "name": "lon",
+        "type": "double"
+      },
+      {
+        "name": "precision",
+        "type": "integer",
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "tests"
+  },
+  "GEOHASH DECODE": {
+    "summary": "Decodes a geohash into the center and bounds of its cell",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "geohash",
+        "type": "geohash"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "tests"
+  },
+  "GEOHASH NEIGHBORS": {
+    "summary": "Returns the cells that surround a geohash cell",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "geohash",
+        "type": "geohash"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "tests"
+  },
+  "TRACKCONFIG": {
+    "summary": "Sets the retention and downsampling of the trajectories in a key",
+    "complexity": "O(N) where N is the number of positions in the key",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "command": "RETENTION",
+        "name": [
+          "seconds"
+        ],
+        "type": [
+          "double"
+        ],
+        "optional": true
+      },
+      {
+        "command": "INTERVAL",
+        "name": [
+          "seconds"
+        ],
+        "type": [
+          "double"
+        ],
+        "optional": true
+      },
+      {
+        "command": "MAXPOINTS",
+        "name": [
+          "count"
+        ],
+        "type": [
+          "integer"
+        ],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "TRACK": {
+    "summary": "Appends a timestamped position to the trajectory of an id",
+    "complexity": "O(log N) where N is the number of positions in the trajectory",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "command": "TIMESTAMP",
+        "name": [
+          "seconds"
+        ],
+        "type": [
+          "double"
+        ],
+        "optional": true
+      },
+      {
+        "command": "POINT",
+        "name": [
+          "lat",
+          "lon",
+          "z"
+        ],
+        "type": [
+          "double",
+          "double",
+          "double"
+        ]
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "TRACKDEL": {
+    "summary": "Deletes the trajectory of an id, or all trajectories in a key",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string",
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "TRAJECTORY": {
+    "summary": "Returns the positions of an id during a time window",
+    "complexity": "O(log N + M) where N is the number of positions in the trajectory and M is the number of positions returned",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "command": "START",
+        "name": [
+          "seconds"
+        ],
+        "type": [
+          "double"
+        ],
+        "optional": true
+      },
+      {
+        "command": "END",
+        "name": [
+          "seconds"
+        ],
+        "type": [
+          "double"
+        ],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "search"
+  },
+  "PASSED": {
+    "summary": "Returns the ids with a trajectory that passed through an area during a time window",
+    "complexity": "O(N) where N is the number of positions in the key",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "command": "START",
+        "name": [
+          "seconds"
+        ],
+        "type": [
+          "double"
+        ],
+        "optional": true
+      },
+      {
+        "command": "END",
+        "name": [
+          "seconds"
+        ],
+        "type": [
+          "double"
+        ],
+        "optional": true
+      },
+      {
+        "name": "area",
+        "enumargs": [
+          {
+            "name": "POINT",
+            "arguments":[
+              {
+                "name": "lat",
+                "type": "double"
+              },
+              {
+                "name": "lon",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "GET",
+            "arguments":[
+              {
+                "name": "key",
+                "type": "string"
+              },
+              {
+                "name": "id",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "BOUNDS",
+            "arguments":[
+              {
+                "name": "minlat",
+                "type": "double"
+              },
+              {
+                "name": "minlon",
+                "type": "double"
+              },
+              {
+                "name": "maxlat",
+                "type": "double"
+              },
+              {
+                "name": "maxlon",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "OBJECT",
+            "arguments":[
+              {
+                "name": "geojson",
+                "type": "geojson"
+              }
+            ]
+          },
+          {
+            "name": "CIRCLE",
+            "arguments":[
+              {
+                "name": "lat",
+                "type": "double"
+              },
+              {
+                "name": "lon",
+                "type": "double"
+              },
+              {
+                "name": "meters",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "TILE",
+            "arguments":[
+              {
+                "name": "x",
+                "type": "double"
+              },
+              {
+                "name": "y",
+                "type": "double"
+              },
+              {
+                "name": "z",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "QUADKEY",
+            "arguments":[
+              {
+                "name": "quadkey",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "HASH",
+            "arguments":[
+              {
+                "name": "geohash",
+                "type": "geohash"
+              }
+            ]
+          }
+        ]
+      }
+    ],
+    "since": "1.26.0",
+    "group": "search"
+  },
+  "DENSITY": {
+    "summary": "Returns a grid of the number of objects in each cell of an area",
+    "complexity": "O(log(N) + M + R*C) where N is the number of objects in the key, M is the number of objects in the area, and R*C is the number of cells",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "command": "FIELD",
+        "name": ["name"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "command": "WHERE",
+        "name": ["field","min","max"],
+        "type": ["string","double","double"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "GRID",
+        "name": ["rows","cols"],
+        "type": ["integer","integer"]
+      },
+      {
+        "name": "area",
+        "enumargs": [
+          {
+            "name": "BOUNDS",
+            "arguments":[
+              {
+                "name": "minlat",
+                "type": "double"
+              },
+              {
+                "name": "minlon",
+                "type": "double"
+              },
+              {
+                "name": "maxlat",
+                "type": "double"
+              },
+              {
+                "name": "maxlon",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "TILE",
+            "arguments":[
+              {
+                "name": "x",
+                "type": "double"
+              },
+              {
+                "name": "y",
+                "type": "double"
+              },
+              {
+                "name": "z",
+                "type": "double"
+              }
+            ]
+          },
+          {
+            "name": "QUADKEY",
+            "arguments":[
+              {
+                "name": "quadkey",
+                "type": "string"
+              }
+            ]
+          },
+          {
+            "name": "HASH",
+            "arguments":[
+              {
+                "name": "geohash",
+                "type": "geohash"
+              }
+            ]
+          }
+        ]
+      }
+    ],
+    "since": "1.26.0",
+    "group": "search"
+  },
+  "ROUTE DISTANCE": {
+    "summary": "Returns the great-circle distance and bearing between two objects",
+    "complexity": "O(log(N)) where N is the number of objects in the keys",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "key2",
+        "type": "string"
+      },
+      {
+        "name": "id2",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "search"
+  },
+  "ROUTE INTERPOLATE": {
+    "summary": "Returns the points of a LineString at distances from its start",
+    "complexity": "O(N*M) where N is the number of positions of the LineString and M is the number of points",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "distances",
+        "enumargs": [
+          {
+            "name": "AT",
+            "arguments":[
+              {
+                "name": "meters",
+                "type": "double",
+                "multiple": true
+              }
+            ]
+          },
+          {
+            "name": "STEP",
+            "arguments":[
+              {
+                "name": "meters",
+                "type": "double"
+              }
+            ]
+          }
+        ]
+      }
+    ],
+    "since": "1.26.0",
+    "group": "search"
+  },
+  "ROUTE ALONG": {
+    "summary": "Returns the distance of an object along a LineString",
+    "complexity": "O(N) where N is the number of positions of the LineString",
+    "arguments":[
+      {
+        "name": "routekey",
+        "type": "string"
+      },
+      {
+        "name": "routeid",
+        "type": "string"
+      },
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "search"
+  },
+  "ROUTE MATRIX": {
+    "summary": "Returns the distances between two sets of objects",
+    "complexity": "O(N*M) where N is the number of sources and M is the number of targets, or O(N*log(M)) with TOPK and all of the targets of a key",
+    "arguments":[
+      {
+        "name": "sourcekey",
+        "type": "string"
+      },
+      {
+        "command": "IDS",
+        "name": ["ids"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "name": "to",
+        "type": "enum",
+        "enum": ["TO"]
+      },
+      {
+        "name": "targetkey",
+        "type": "string"
+      },
+      {
+        "command": "IDS",
+        "name": ["ids"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "command": "TOPK",
+        "name": ["count"],
+        "type": ["integer"],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "search"
+  },
+  "LAYERS": {
+    "summary": "Lists the loaded reference layers",
+    "complexity": "O(N) where N is the number of layers",
+    "arguments": [],
+    "since": "1.26.0",
+    "group": "search"
+  },
+  "LOOKUP": {
+    "summary": "Returns the areas of a reference layer that contain a point",
+    "complexity": "O(log(N)) where N is the number of areas of the layer",
+    "arguments":[
+      {
+        "name": "layer",
+        "type": "string"
+      },
+      {
+        "name": "lat",
+        "type": "double"
+      },
+      {
+        "name": "lon",
+        "type": "double"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "search"
+  },
+  "AREACONFIG": {
+    "summary": "Annotates the objects of a key with the areas of a reference layer that contain them",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "layer",
+        "type": "string"
+      },
+      {
+        "command": "FIELD",
+        "name": [
+          "name"
+        ],
+        "type": [
+          "string"
+        ],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "AREADEL": {
+    "summary": "Stops annotating the objects of a key with the areas of a reference layer",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "HISTORYCONFIG": {
+    "summary": "Keeps the last versions of the objects of a key",
+    "complexity": "O(N) where N is the number of ids with a history",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "command": "VERSIONS",
+        "name": ["count"],
+        "type": ["integer"],
+        "optional": true
+      },
+      {
+        "command": "AGE",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "FLEETCONFIG": {
+    "summary": "Keeps a summary of the moving objects of a key",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "command": "WINDOW",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true
+      },
+      {
+        "command": "SPEED",
+        "name": ["field"],
+        "type": ["string"],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "FLEETSTATS": {
+    "summary": "Returns the summary of the moving objects of a key",
+    "complexity": "O(N) where N is the number of updates that left the window",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "WRITEBEHIND": {
+    "summary": "Mirrors the objects of a key to an external store",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "url",
+        "type": "string",
+        "optional": true
+      },
+      {
+        "command": "BATCH",
+        "name": ["count"],
+        "type": ["integer"],
+        "optional": true
+      },
+      {
+        "command": "INTERVAL",
+        "name": ["seconds"],
+        "type": ["double"],
+        "optional": true
+      },
+      {
+        "command": "RETRIES",
+        "name": ["count"],
+        "type": ["integer"],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "WRITEBEHINDSTATS": {
+    "summary": "Returns the counts of the writes that are mirrored for a key",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "READTHROUGH": {
+    "summary": "Loads the objects that a GET misses from an external store",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "url",
+        "type": "string",
+        "optional": true
+      },
+      {
+        "command": "TIMEOUT",
+        "name": [
+          "seconds"
+        ],
+        "type": [
+          "double"
+        ],
+        "optional": true
+      },
+      {
+        "command": "EX",
+        "name": [
+          "seconds"
+        ],
+        "type": [
+          "double"
+        ],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "READTHROUGHSTATS": {
+    "summary": "Returns the counts of the objects that are loaded for a key",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "KEYCONFIG": {
+    "summary": "Sets, gets, or removes the storage options of a key",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "subcommand",
+        "type": "enum",
+        "enum": ["SET", "GET", "DEL"]
+      },
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": ["option","value"],
+        "type": ["string","string"],
+        "optional": true,
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "ORDERCONFIG": {
+    "summary": "Sets the timestamp field that orders the updates of a key",
+    "complexity": "O(1)",
+    "arguments": [
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "command": "FIELD",
+        "name": ["name"],
+        "type": ["string"],
+        "optional": true
+      },
+      {
+        "name": "merge",
+        "type": "enum",
+        "enum": ["MERGE"],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "TTLCONFIG": {
+    "summary": "Sets a sliding TTL for the objects of a key",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "seconds",
+        "type": "double"
+      },
+      {
+        "name": "onread",
+        "type": "enum",
+        "enum": ["ONREAD"],
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "SCHEMASET": {
+    "summary": "Sets the field names and types, required fields, and geometry types that are allowed in a key",
+    "complexity": "O(N) where N is the number of fields",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "command": "FIELD",
+        "name": ["name", "type"],
+        "type": ["string", "string"],
+        "optional": true,
+        "multiple": true
+      },
+      {
+        "command": "GEOMETRY",
+        "name": ["type"],
+        "type": ["string"],
+        "optional": true,
+        "variadic": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "SCHEMAGET": {
+    "summary": "Returns the schema of a key",
+    "complexity": "O(N) where N is the number of fields",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "SCHEMADEL": {
+    "summary": "Removes the schema of a key",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "TOUCH": {
+    "summary": "Refreshes the sliding TTL of objects",
+    "complexity": "O(N) where N is the number of ids",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string",
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "TAG": {
+    "summary": "Adds tags to an object",
+    "complexity": "O(N) where N is the number of tags",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "tag",
+        "type": "string",
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "UNTAG": {
+    "summary": "Removes tags from an object",
+    "complexity": "O(N) where N is the number of tags",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "tag",
+        "type": "string",
+        "multiple": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "TAGS": {
+    "summary": "Returns the tags of an object",
+    "complexity": "O(N) where N is the number of tags of the object",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "SETMETA": {
+    "summary": "Sets the metadata of an object",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "meta",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "GETMETA": {
+    "summary": "Returns the metadata of an object",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "SFSET": {
+    "summary": "Sets the string fields of an object",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": ["field","value"],
+        "type": ["string","string"]
+      },
+      {
+        "name": ["field","value"],
+        "type": ["string","string"],
+        "multiple": true,
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "SFGET": {
+    "summary": "Returns the value of a string field of an object",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "field",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "UNDELETE": {
+    "summary": "Sets a deleted object again from its tombstone",
+    "complexity": "O(log N)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "TOMBSTONES": {
+    "summary": "Returns the ids of the deleted objects of a key",
+    "complexity": "O(N) where N is the number of tombstones of the key",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "pattern",
+        "type": "pattern",
+        "optional": true
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "PEXPIRE": {
+    "summary": "Set a timeout on an id in milliseconds",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "milliseconds",
+        "type": "double"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "EXPIREAT": {
+    "summary": "Set the expiration of an id as a unix timestamp",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "timestamp",
+        "type": "double"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "PEXPIREAT": {
+    "summary": "Set the expiration of an id as a unix timestamp in milliseconds",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      },
+      {
+        "name": "timestamp",
+        "type": "double"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
+  },
+  "PTTL": {
+    "summary": "Get a timeout on an id in milliseconds",
+    "complexity": "O(1)",
+    "arguments":[
+      {
+        "name": "key",
+        "type": "string"
+      },
+      {
+        "name": "id",
+        "type": "string"
+      }
+    ],
+    "since": "1.26.0",
+    "group": "keys"
   }
 }`
@@ -9,6 +9,13 @@ var ShowDebugMessages = false
 // ProtectedMode forces Tile38 to default in protected mode.
 var ProtectedMode = "no"
 
+// EnableDebug allows for the DEBUG command and the pprof endpoints of the
+// metrics server. It's "no", "yes", or "local" for loopback clients only.
+var EnableDebug = "no"
+
+// WebUI serves the web console at /ui of the HTTP transport.
+var WebUI = false
+
 // AppendOnly allows for disabling the appendonly file.
 var AppendOnly = true
 
@@ -17,3 +24,11 @@ var AppendFileName = ""
 
 // QueueFileName allows for custom queue.db file path
 var QueueFileName = ""
+
+// ClusterDiscovery finds the peers of the server in Kubernetes, and follows
+// their leader. It's "dns:service[:port]" or "kubernetes:label-selector".
+var ClusterDiscovery = ""
+
+// ClusterElection elects the leader of the peers, rather than following the
+// pod with the leader label, or the first pod of the StatefulSet.
+var ClusterElection = false
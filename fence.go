@@ -0,0 +1,246 @@
+package tile38
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+)
+
+// Event is a geofence notification. It matches the json events of the hooks
+// and channels of the server.
+type Event struct {
+	Command string // "set", "del" or "drop"
+	Detect  string // "enter", "exit", "inside", "outside" or "cross"
+	Key     string
+	ID      string
+	Object  geojson.Object
+	Fields  map[string]float64
+	Time    time.Time
+}
+
+// FenceOptions are the options of a fence.
+type FenceOptions struct {
+	// Intersects matches the objects that intersect the area, instead of the
+	// objects that are within the area.
+	Intersects bool
+	// Detect are the detections that are sent, or all when empty.
+	Detect []string
+}
+
+// Fence is a geofence on the objects of a key. The events are sent on C in
+// the order that the objects were changed. Events are queued while the
+// receiver is busy, so receiving never blocks the writers of the database.
+type Fence struct {
+	C <-chan Event
+
+	db     *DB
+	key    string
+	area   geojson.Object
+	opts   FenceOptions
+	detect map[string]bool
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []Event
+	closed bool
+	ch     chan Event
+	done   chan struct{}
+}
+
+// Fence starts a geofence on the objects of a key that are within, or
+// intersect, an area. Close the fence when it's no longer needed.
+func (db *DB) Fence(key string, area geojson.Object, opts *FenceOptions,
+) (*Fence, error) {
+	if area == nil {
+		return nil, ErrNotSpatial
+	}
+	f := &Fence{db: db, key: key, area: area}
+	if opts != nil {
+		f.opts = *opts
+	}
+	if len(f.opts.Detect) > 0 {
+		f.detect = make(map[string]bool)
+		for _, detect := range f.opts.Detect {
+			switch detect {
+			case "enter", "exit", "inside", "outside", "cross":
+				f.detect[detect] = true
+			default:
+				return nil, ErrInvalidDetect
+			}
+		}
+	}
+	f.cond = sync.NewCond(&f.mu)
+	f.ch = make(chan Event)
+	f.done = make(chan struct{})
+	f.C = f.ch
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return nil, ErrClosed
+	}
+	fences := db.fences[key]
+	if fences == nil {
+		fences = make(map[*Fence]bool)
+		db.fences[key] = fences
+	}
+	fences[f] = true
+	go f.run()
+	return f, nil
+}
+
+// Close stops the fence. The events that weren't received are dropped, and
+// C is closed.
+func (f *Fence) Close() {
+	db := f.db
+	db.mu.Lock()
+	if fences := db.fences[f.key]; fences[f] {
+		delete(fences, f)
+		if len(fences) == 0 {
+			delete(db.fences, f.key)
+		}
+	}
+	db.mu.Unlock()
+	f.stop()
+}
+
+// stop ends the delivery of the events.
+func (f *Fence) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.closed = true
+	f.queue = nil
+	close(f.done)
+	f.cond.Broadcast()
+}
+
+// push queues events for delivery.
+func (f *Fence) push(evs ...Event) {
+	f.mu.Lock()
+	if !f.closed {
+		f.queue = append(f.queue, evs...)
+		f.cond.Broadcast()
+	}
+	f.mu.Unlock()
+}
+
+// run delivers the queued events on the channel until the fence is closed.
+func (f *Fence) run() {
+	defer close(f.ch)
+	for {
+		f.mu.Lock()
+		for len(f.queue) == 0 && !f.closed {
+			f.cond.Wait()
+		}
+		if f.closed {
+			f.mu.Unlock()
+			return
+		}
+		ev := f.queue[0]
+		f.queue[0] = Event{}
+		f.queue = f.queue[1:]
+		f.mu.Unlock()
+		select {
+		case f.ch <- ev:
+		case <-f.done:
+			return
+		}
+	}
+}
+
+// match returns true when an object is inside of the fence.
+func (f *Fence) match(obj geojson.Object) bool {
+	if obj == nil {
+		return false
+	}
+	if f.opts.Intersects {
+		return obj.Intersects(f.area)
+	}
+	return obj.Within(f.area)
+}
+
+// events returns the events of an object that was set, the same way as the
+// geofences of the server do.
+func (f *Fence) events(id string, oldObj, obj geojson.Object,
+	fields map[string]float64, now time.Time,
+) []Event {
+	match1 := f.match(oldObj)
+	match2 := f.match(obj)
+	detect := "outside"
+	if match1 && match2 {
+		detect = "inside"
+	} else if match1 && !match2 {
+		detect = "exit"
+	} else if !match1 && match2 {
+		detect = "enter"
+	} else if oldObj != nil {
+		// the line between the old and new object may cross the fence
+		ls := geojson.NewLineString(geometry.NewLine(
+			[]geometry.Point{oldObj.Center(), obj.Center()}, nil))
+		if ls.Intersects(f.area) {
+			detect = "cross"
+		}
+	}
+	if f.detect != nil && !f.detect[detect] {
+		switch detect {
+		case "enter":
+			detect = "inside"
+		case "exit":
+			detect = "outside"
+		}
+		if !f.detect[detect] {
+			return nil
+		}
+	}
+	ev := Event{Command: "set", Detect: detect, Key: f.key, ID: id,
+		Object: obj, Fields: fields, Time: now}
+	evs := []Event{ev}
+	switch detect {
+	case "enter":
+		if f.detect == nil || f.detect["inside"] {
+			ev.Detect = "inside"
+			evs = append(evs, ev)
+		}
+	case "exit", "cross":
+		if f.detect == nil || f.detect["outside"] {
+			ev.Detect = "outside"
+			evs = append(evs, ev)
+		}
+	}
+	return evs
+}
+
+// fenceSet sends the events of an object that was set.
+func (db *DB) fenceSet(key, id string, oldObj, obj geojson.Object,
+	fields map[string]float64,
+) {
+	now := time.Now()
+	for f := range db.fences[key] {
+		if evs := f.events(id, oldObj, obj, fields, now); len(evs) > 0 {
+			f.push(evs...)
+		}
+	}
+}
+
+// fenceDelete sends the events of an object that was deleted.
+func (db *DB) fenceDelete(key, id string, obj geojson.Object,
+	fields map[string]float64,
+) {
+	now := time.Now()
+	for f := range db.fences[key] {
+		f.push(Event{Command: "del", Key: key, ID: id, Object: obj,
+			Fields: fields, Time: now})
+	}
+}
+
+// fenceDrop sends the events of a key that was dropped.
+func (db *DB) fenceDrop(key string) {
+	now := time.Now()
+	for f := range db.fences[key] {
+		f.push(Event{Command: "drop", Key: key, Time: now})
+	}
+}
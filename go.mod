@@ -9,6 +9,7 @@ require (
 	github.com/eclipse/paho.mqtt.golang v1.3.1
 	github.com/golang/protobuf v1.4.3
 	github.com/gomodule/redigo v1.8.3
+	github.com/klauspost/compress v1.11.12
 	github.com/mmcloughlin/geohash v0.10.0
 	github.com/nats-io/nats-server/v2 v2.2.0 // indirect
 	github.com/nats-io/nats.go v1.10.1-0.20210228004050-ed743748acac
@@ -25,13 +26,16 @@ require (
 	github.com/tidwall/redbench v0.1.0
 	github.com/tidwall/redcon v1.4.1
 	github.com/tidwall/resp v0.1.0
+	github.com/tidwall/rtred v0.1.2
 	github.com/tidwall/rtree v1.3.1
 	github.com/tidwall/sjson v1.1.7
 	github.com/xdg/scram v1.0.3
 	github.com/yuin/gopher-lua v0.0.0-20200816102855-ee81675732da
 	golang.org/x/net v0.0.0-20210226172049-e18ecbb05110
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
 	google.golang.org/api v0.20.0
 	google.golang.org/grpc v1.35.0
+	google.golang.org/protobuf v1.25.0
 	layeh.com/gopher-json v0.0.0-20201124131017-552bb3c4c3bf
 )
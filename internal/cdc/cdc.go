@@ -0,0 +1,404 @@
+// Package cdc is the change data capture stream of the server, for
+// consumers that follow its changes without parsing the AOF. The schema of
+// the records is in cdc.proto, which documents the stream. The records are
+// encoded and decoded here with protowire, so that there's no generated code
+// to keep up to date, and a Go consumer can use ReadRecord.
+package cdc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Version is the version of the schema.
+const Version = 1
+
+// maxRecordSize is the size of the largest record that ReadRecord reads.
+const maxRecordSize = 512 * 1024 * 1024
+
+// Record is a record of the stream. Only one of the changes is set.
+type Record struct {
+	Offset     int64
+	Header     *Header
+	Set        *Set
+	Del        *Del
+	FSet       *FSet
+	Expire     *Expire
+	Persist    *Persist
+	Drop       *Drop
+	Rename     *Rename
+	FlushDB    *FlushDB
+	Command    *Command
+	Checkpoint *Checkpoint
+}
+
+// Header is the first record of the stream.
+type Header struct {
+	Version uint32
+}
+
+// Set is an object that was set.
+type Set struct {
+	Key, ID string
+	Object  string // GeoJSON, or a JSON string
+	Fields  map[string]float64
+	Ex      float64 // seconds to live, if it expires
+}
+
+// Del is an object that was deleted.
+type Del struct {
+	Key, ID string
+}
+
+// FSet is fields of an object that were set.
+type FSet struct {
+	Key, ID string
+	Fields  map[string]float64
+}
+
+// Expire is an expiration that was set, in seconds to live or at a unix
+// time in milliseconds.
+type Expire struct {
+	Key, ID string
+	Seconds float64
+	At      int64
+}
+
+// Persist is an expiration that was removed.
+type Persist struct {
+	Key, ID string
+}
+
+// Drop is a key that was dropped.
+type Drop struct {
+	Key string
+}
+
+// Rename is a key that was renamed.
+type Rename struct {
+	Key, NewKey string
+}
+
+// FlushDB is all of the keys that were removed.
+type FlushDB struct{}
+
+// Command is any other change, as the arguments of its command.
+type Command struct {
+	Args []string
+}
+
+// Checkpoint is a point of the stream where a consumer can resume.
+type Checkpoint struct {
+	Time int64 // unix time in milliseconds
+}
+
+// AppendRecord appends a record to dst, after the varint of its size.
+func AppendRecord(dst []byte, rec Record) []byte {
+	msg := rec.marshal()
+	dst = protowire.AppendVarint(dst, uint64(len(msg)))
+	return append(dst, msg...)
+}
+
+// ReadRecord reads a record that was appended with AppendRecord.
+func ReadRecord(rd *bufio.Reader) (Record, error) {
+	size, err := binary.ReadUvarint(rd)
+	if err != nil {
+		return Record{}, err
+	}
+	if size > maxRecordSize {
+		return Record{}, errors.New("record is too large")
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(rd, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Record{}, err
+	}
+	var rec Record
+	if err := rec.unmarshal(msg); err != nil {
+		return Record{}, err
+	}
+	return rec, nil
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// appendMessage appends an embedded message, which is there even if it's
+// empty, for the oneof.
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// appendFields appends a map of fields, in the order of their names.
+func appendFields(b []byte, num protowire.Number, fields map[string]float64,
+) []byte {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var entry []byte
+		entry = appendString(entry, 1, name)
+		entry = appendDouble(entry, 2, fields[name])
+		b = appendMessage(b, num, entry)
+	}
+	return b
+}
+
+func (rec *Record) marshal() []byte {
+	b := appendVarint(nil, 1, uint64(rec.Offset))
+	switch {
+	case rec.Header != nil:
+		b = appendMessage(b, 2, appendVarint(nil, 1,
+			uint64(rec.Header.Version)))
+	case rec.Set != nil:
+		var m []byte
+		m = appendString(m, 1, rec.Set.Key)
+		m = appendString(m, 2, rec.Set.ID)
+		m = appendString(m, 3, rec.Set.Object)
+		m = appendFields(m, 4, rec.Set.Fields)
+		m = appendDouble(m, 5, rec.Set.Ex)
+		b = appendMessage(b, 3, m)
+	case rec.Del != nil:
+		b = appendMessage(b, 4, appendString(appendString(nil,
+			1, rec.Del.Key), 2, rec.Del.ID))
+	case rec.FSet != nil:
+		var m []byte
+		m = appendString(m, 1, rec.FSet.Key)
+		m = appendString(m, 2, rec.FSet.ID)
+		m = appendFields(m, 3, rec.FSet.Fields)
+		b = appendMessage(b, 5, m)
+	case rec.Expire != nil:
+		var m []byte
+		m = appendString(m, 1, rec.Expire.Key)
+		m = appendString(m, 2, rec.Expire.ID)
+		m = appendDouble(m, 3, rec.Expire.Seconds)
+		m = appendVarint(m, 4, uint64(rec.Expire.At))
+		b = appendMessage(b, 6, m)
+	case rec.Persist != nil:
+		b = appendMessage(b, 7, appendString(appendString(nil,
+			1, rec.Persist.Key), 2, rec.Persist.ID))
+	case rec.Drop != nil:
+		b = appendMessage(b, 8, appendString(nil, 1, rec.Drop.Key))
+	case rec.Rename != nil:
+		b = appendMessage(b, 9, appendString(appendString(nil,
+			1, rec.Rename.Key), 2, rec.Rename.NewKey))
+	case rec.FlushDB != nil:
+		b = appendMessage(b, 10, nil)
+	case rec.Command != nil:
+		var m []byte
+		for _, arg := range rec.Command.Args {
+			// the arguments that are empty are there too
+			m = protowire.AppendTag(m, 1, protowire.BytesType)
+			m = protowire.AppendString(m, arg)
+		}
+		b = appendMessage(b, 11, m)
+	case rec.Checkpoint != nil:
+		b = appendMessage(b, 12, appendVarint(nil, 1,
+			uint64(rec.Checkpoint.Time)))
+	}
+	return b
+}
+
+// consume calls fn with each field of a message, and the varint or fixed64
+// value, or the bytes, of the field. The fields that aren't known are
+// skipped by fn, so that records with the fields of later schemas can be
+// read.
+func consume(msg []byte,
+	fn func(num protowire.Number, v uint64, b []byte) error,
+) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		var v uint64
+		var b []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(msg)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(msg)
+		case protowire.BytesType:
+			b, n = protowire.ConsumeBytes(msg)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		if err := fn(num, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumeKeyID reads the key and the id of a message.
+func consumeKeyID(msg []byte, key, id *string) error {
+	return consume(msg, func(num protowire.Number, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			*key = string(b)
+		case 2:
+			*id = string(b)
+		}
+		return nil
+	})
+}
+
+// consumeField reads an entry of a map of fields.
+func consumeField(fields *map[string]float64, entry []byte) error {
+	var name string
+	var value float64
+	err := consume(entry, func(num protowire.Number, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			name = string(b)
+		case 2:
+			value = math.Float64frombits(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if *fields == nil {
+		*fields = make(map[string]float64)
+	}
+	(*fields)[name] = value
+	return nil
+}
+
+func (rec *Record) unmarshal(msg []byte) error {
+	return consume(msg, func(num protowire.Number, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			rec.Offset = int64(v)
+		case 2:
+			rec.Header = &Header{}
+			return consume(b, func(num protowire.Number, v uint64,
+				_ []byte) error {
+				if num == 1 {
+					rec.Header.Version = uint32(v)
+				}
+				return nil
+			})
+		case 3:
+			rec.Set = &Set{}
+			return consume(b, func(num protowire.Number, v uint64,
+				b []byte) error {
+				switch num {
+				case 1:
+					rec.Set.Key = string(b)
+				case 2:
+					rec.Set.ID = string(b)
+				case 3:
+					rec.Set.Object = string(b)
+				case 4:
+					return consumeField(&rec.Set.Fields, b)
+				case 5:
+					rec.Set.Ex = math.Float64frombits(v)
+				}
+				return nil
+			})
+		case 4:
+			rec.Del = &Del{}
+			return consumeKeyID(b, &rec.Del.Key, &rec.Del.ID)
+		case 5:
+			rec.FSet = &FSet{}
+			return consume(b, func(num protowire.Number, v uint64,
+				b []byte) error {
+				switch num {
+				case 1:
+					rec.FSet.Key = string(b)
+				case 2:
+					rec.FSet.ID = string(b)
+				case 3:
+					return consumeField(&rec.FSet.Fields, b)
+				}
+				return nil
+			})
+		case 6:
+			rec.Expire = &Expire{}
+			return consume(b, func(num protowire.Number, v uint64,
+				b []byte) error {
+				switch num {
+				case 1:
+					rec.Expire.Key = string(b)
+				case 2:
+					rec.Expire.ID = string(b)
+				case 3:
+					rec.Expire.Seconds = math.Float64frombits(v)
+				case 4:
+					rec.Expire.At = int64(v)
+				}
+				return nil
+			})
+		case 7:
+			rec.Persist = &Persist{}
+			return consumeKeyID(b, &rec.Persist.Key, &rec.Persist.ID)
+		case 8:
+			rec.Drop = &Drop{}
+			var id string
+			return consumeKeyID(b, &rec.Drop.Key, &id)
+		case 9:
+			rec.Rename = &Rename{}
+			return consumeKeyID(b, &rec.Rename.Key, &rec.Rename.NewKey)
+		case 10:
+			rec.FlushDB = &FlushDB{}
+		case 11:
+			rec.Command = &Command{}
+			return consume(b, func(num protowire.Number, _ uint64,
+				b []byte) error {
+				if num == 1 {
+					rec.Command.Args = append(rec.Command.Args, string(b))
+				}
+				return nil
+			})
+		case 12:
+			rec.Checkpoint = &Checkpoint{}
+			return consume(b, func(num protowire.Number, v uint64,
+				_ []byte) error {
+				if num == 1 {
+					rec.Checkpoint.Time = int64(v)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,71 @@
+package cdc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestRecords(t *testing.T) {
+	recs := []Record{
+		{Offset: 0, Header: &Header{Version: Version}},
+		{Offset: 100, Set: &Set{Key: "fleet", ID: "truck",
+			Object: `{"type":"Point","coordinates":[-115,33]}`,
+			Fields: map[string]float64{"speed": 10, "fuel": -0.5}, Ex: 1.5}},
+		{Offset: 100, Set: &Set{Key: "fleet", ID: "van", Object: `"hello"`}},
+		{Offset: 200, Del: &Del{Key: "fleet", ID: "truck"}},
+		{Offset: 300, FSet: &FSet{Key: "fleet", ID: "van",
+			Fields: map[string]float64{"speed": 20}}},
+		{Offset: 400, Expire: &Expire{Key: "fleet", ID: "van", Seconds: 10}},
+		{Offset: 500, Expire: &Expire{Key: "fleet", ID: "van",
+			At: 1600000000000}},
+		{Offset: 600, Persist: &Persist{Key: "fleet", ID: "van"}},
+		{Offset: 700, Rename: &Rename{Key: "fleet", NewKey: "trucks"}},
+		{Offset: 800, Drop: &Drop{Key: "trucks"}},
+		{Offset: 900, FlushDB: &FlushDB{}},
+		{Offset: 1000, Command: &Command{Args: []string{"sethook", "", "x"}}},
+		{Offset: 1000, Checkpoint: &Checkpoint{Time: 1600000000000}},
+	}
+	var buf []byte
+	for _, rec := range recs {
+		buf = AppendRecord(buf, rec)
+	}
+	rd := bufio.NewReader(bytes.NewReader(buf))
+	for i, expect := range recs {
+		rec, err := ReadRecord(rd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rec, expect) {
+			t.Fatalf("%d: expected %+v, got %+v", i, expect, rec)
+		}
+	}
+	if _, err := ReadRecord(rd); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if _, err := ReadRecord(bufio.NewReader(bytes.NewReader(buf[6:20]))); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestWireFormat(t *testing.T) {
+	// offset 5, and field 4 with the key and the id
+	buf := AppendRecord(nil, Record{Offset: 5, Del: &Del{Key: "a", ID: "b"}})
+	expect := []byte{10, 0x08, 5, 0x22, 6, 0x0a, 1, 'a', 0x12, 1, 'b'}
+	if !bytes.Equal(buf, expect) {
+		t.Fatalf("expected %v, got %v", expect, buf)
+	}
+	// fields of later schemas are skipped
+	msg := []byte{0x08, 5, 0x22, 9, 0x0a, 1, 'a', 0x12, 1, 'b', 0x18, 0x96, 0x01,
+		0xa8, 0x06, 1}
+	rec, err := ReadRecord(bufio.NewReader(bytes.NewReader(
+		append([]byte{byte(len(msg))}, msg...))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Offset != 5 || rec.Del == nil || *rec.Del != (Del{Key: "a", ID: "b"}) {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+}
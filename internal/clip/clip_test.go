@@ -121,3 +121,24 @@ func TestClipPolygon2(t *testing.T) {
 // 	println(clipped.String())
 
 // }
+
+func TestClipExtraCoordinates(t *testing.T) {
+	for _, tc := range [][2]string{
+		{`{"type":"LineString","coordinates":[[0,0,10,100],[4,0,50,500]]}`,
+			`{"type":"LineString","coordinates":[[1,0,20,200],[3,0,40,400]]}`},
+		{`{"type":"Polygon","coordinates":[[[0,0,0],[4,0,4],[4,4,8],[0,4,4],[0,0,0]]]}`,
+			`{"type":"Polygon","coordinates":[[[1,3,4],[1,0,1],[3,0,3],[3,3,6],[1,3,4],[1,0,1],[1,3,4]]]}`},
+		{`{"type":"MultiLineString","coordinates":[[[0,0,10],[4,0,50]]]}`,
+			`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":` +
+				`{"type":"LineString","coordinates":[[1,0,20],[3,0,40]]},"properties":{}}]}`},
+	} {
+		obj, err := geojson.Parse(tc[0], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clipped := Clip(obj, RO(1, -1, 3, 3), nil)
+		if clipped.JSON() != tc[1] {
+			t.Fatalf("expected %s, got %s", tc[1], clipped.JSON())
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package clip
+
+import (
+	"strconv"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/gjson"
+)
+
+// position is a point with its extra coordinates, such as Z and M. The
+// extra coordinates are kept by interpolating them along the clipped
+// segments.
+type position struct {
+	geometry.Point
+	extra []float64
+}
+
+// extraCoordinates returns the coordinates of an object that has positions
+// with more than two dimensions. The geometry types of the geojson package
+// cannot be built with extra coordinates, so these objects are clipped
+// using their json instead.
+func extraCoordinates(obj geojson.Object) (gjson.Result, bool) {
+	coords := gjson.Get(obj.JSON(), "coordinates")
+	first := coords
+	for first.IsArray() {
+		values := first.Array()
+		if len(values) == 0 {
+			return coords, false
+		}
+		if values[0].Type == gjson.Number {
+			return coords, len(values) > 2
+		}
+		first = values[0]
+	}
+	return coords, false
+}
+
+func parsePositions(series gjson.Result) []position {
+	var ps []position
+	series.ForEach(func(_, value gjson.Result) bool {
+		values := value.Array()
+		if len(values) < 2 {
+			return true
+		}
+		p := position{Point: geometry.Point{
+			X: values[0].Float(), Y: values[1].Float(),
+		}}
+		for _, v := range values[2:] {
+			p.extra = append(p.extra, v.Float())
+		}
+		ps = append(ps, p)
+		return true
+	})
+	return ps
+}
+
+func appendPositions(dst []byte, ps []position) []byte {
+	dst = append(dst, '[')
+	for i, p := range ps {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, '[')
+		dst = strconv.AppendFloat(dst, p.X, 'f', -1, 64)
+		dst = append(dst, ',')
+		dst = strconv.AppendFloat(dst, p.Y, 'f', -1, 64)
+		for _, v := range p.extra {
+			dst = append(dst, ',')
+			dst = strconv.AppendFloat(dst, v, 'f', -1, 64)
+		}
+		dst = append(dst, ']')
+	}
+	return append(dst, ']')
+}
+
+func parseExtra(json []byte, opts *geometry.IndexOptions) geojson.Object {
+	popts := *geojson.DefaultParseOptions
+	if opts != nil {
+		popts.IndexGeometry = opts.MinPoints
+		popts.IndexGeometryKind = opts.Kind
+	}
+	obj, err := geojson.Parse(string(json), &popts)
+	if err != nil {
+		return geojson.NewMultiPolygon(nil)
+	}
+	return obj
+}
+
+// intersectExtra is intersect for positions.
+func intersectExtra(bbox geometry.Rect, code uint8, start, end position,
+) position {
+	p := position{Point: intersect(bbox, code, start.Point, end.Point)}
+	var t float64
+	if code&(4|8) != 0 {
+		t = (p.Y - start.Y) / (end.Y - start.Y)
+	} else {
+		t = (p.X - start.X) / (end.X - start.X)
+	}
+	if len(start.extra) > 0 {
+		p.extra = make([]float64, len(start.extra))
+		for i, v := range start.extra {
+			if i < len(end.extra) {
+				v += (end.extra[i] - v) * t
+			}
+			p.extra[i] = v
+		}
+	}
+	return p
+}
+
+// clipSegmentExtra is clipSegment for positions.
+func clipSegmentExtra(a, b position, rect geometry.Rect) (
+	ra, rb position, rejected bool,
+) {
+	startCode := getCode(rect, a.Point)
+	endCode := getCode(rect, b.Point)
+	if (startCode | endCode) == 0 {
+		return a, b, false
+	} else if (startCode & endCode) != 0 {
+		return a, b, true
+	} else if startCode != 0 {
+		return clipSegmentExtra(intersectExtra(rect, startCode, a, b), b, rect)
+	}
+	return clipSegmentExtra(a, intersectExtra(rect, endCode, a, b), rect)
+}
+
+// clipRingExtra is clipRing for positions.
+func clipRingExtra(ring []position, bbox geometry.Rect) []position {
+	if len(ring) < 4 {
+		return nil
+	}
+	var resRing []position
+	for edge := uint8(1); edge <= 8; edge *= 2 {
+		prev := ring[len(ring)-2]
+		prevInside := (getCode(bbox, prev.Point) & edge) == 0
+		for _, p := range ring {
+			inside := (getCode(bbox, p.Point) & edge) == 0
+			if prevInside && inside {
+				resRing = append(resRing, p)
+			} else if prevInside && !inside {
+				resRing = append(resRing, intersectExtra(bbox, edge, prev, p))
+			} else if !prevInside && inside {
+				resRing = append(resRing, intersectExtra(bbox, edge, prev, p))
+				resRing = append(resRing, p)
+			}
+			prev, prevInside = p, inside
+		}
+		if len(resRing) > 0 &&
+			resRing[0].Point != resRing[len(resRing)-1].Point {
+			resRing = append(resRing, resRing[0])
+		}
+		ring, resRing = resRing, nil
+		if len(ring) == 0 {
+			break
+		}
+	}
+	return ring
+}
+
+func clipLineStringExtra(coords gjson.Result, clipper geojson.Object,
+	opts *geometry.IndexOptions,
+) geojson.Object {
+	bbox := clipper.Rect()
+	ps := parsePositions(coords)
+	var lines [][]position
+	var line []position
+	for i := 0; i+1 < len(ps); i++ {
+		a, b, rejected := clipSegmentExtra(ps[i], ps[i+1], bbox)
+		if rejected {
+			continue
+		}
+		if len(line) > 0 && line[len(line)-1].Point != a.Point {
+			lines = append(lines, line)
+			line = []position{a}
+		} else if len(line) == 0 {
+			line = append(line, a)
+		}
+		line = append(line, b)
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	var json []byte
+	if len(lines) == 1 {
+		json = append(json, `{"type":"LineString","coordinates":`...)
+		json = appendPositions(json, lines[0])
+	} else {
+		json = append(json, `{"type":"MultiLineString","coordinates":[`...)
+		for i, line := range lines {
+			if i > 0 {
+				json = append(json, ',')
+			}
+			json = appendPositions(json, line)
+		}
+		json = append(json, ']')
+	}
+	json = append(json, '}')
+	return parseExtra(json, opts)
+}
+
+func clipPolygonExtra(coords gjson.Result, clipper geojson.Object,
+	opts *geometry.IndexOptions,
+) geojson.Object {
+	rect := clipper.Rect()
+	var rings [][]position
+	coords.ForEach(func(_, ring gjson.Result) bool {
+		if clipped := clipRingExtra(parsePositions(ring), rect); len(clipped) > 0 {
+			rings = append(rings, clipped)
+		}
+		return true
+	})
+	if len(rings) == 0 {
+		return geojson.NewMultiPolygon(nil)
+	}
+	json := []byte(`{"type":"Polygon","coordinates":[`)
+	for i, ring := range rings {
+		if i > 0 {
+			json = append(json, ',')
+		}
+		json = appendPositions(json, ring)
+	}
+	json = append(json, "]}"...)
+	return parseExtra(json, opts)
+}
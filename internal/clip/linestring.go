@@ -9,6 +9,9 @@ func clipLineString(
 	lineString *geojson.LineString, clipper geojson.Object,
 	opts *geometry.IndexOptions,
 ) geojson.Object {
+	if coords, ok := extraCoordinates(lineString); ok {
+		return clipLineStringExtra(coords, clipper, opts)
+	}
 	bbox := clipper.Rect()
 	var newPoints [][]geometry.Point
 	var clipped geometry.Segment
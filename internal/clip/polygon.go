@@ -9,6 +9,9 @@ func clipPolygon(
 	polygon *geojson.Polygon, clipper geojson.Object,
 	opts *geometry.IndexOptions,
 ) geojson.Object {
+	if coords, ok := extraCoordinates(polygon); ok {
+		return clipPolygonExtra(coords, clipper, opts)
+	}
 	rect := clipper.Rect()
 	var newPoints [][]geometry.Point
 	base := polygon.Base()
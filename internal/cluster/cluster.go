@@ -0,0 +1,181 @@
+// Package cluster groups points into clusters for rendering on a web map.
+// An index holds the clusters of every zoom level, which are computed once
+// from the deepest level up, in the way of the supercluster library.
+package cluster
+
+import (
+	"math"
+
+	"github.com/tidwall/geojson/geometry"
+)
+
+// Options for building an index.
+type Options struct {
+	// Radius is the cluster radius, in pixels.
+	Radius float64
+	// Extent is the size of a tile, in pixels.
+	Extent float64
+	// MinZoom and MaxZoom are the range of zoom levels that are clustered.
+	// Zoom levels above MaxZoom have the points themselves.
+	MinZoom, MaxZoom int
+}
+
+// DefaultOptions are the options of the supercluster library.
+var DefaultOptions = Options{Radius: 40, Extent: 512, MinZoom: 0, MaxZoom: 16}
+
+// Point is a point that is clustered.
+type Point struct {
+	ID   string
+	X, Y float64 // longitude and latitude
+}
+
+// Cluster is a group of nearby points, or a single point that has no
+// neighbors at the zoom level.
+type Cluster struct {
+	// ID is the id of the point when Count is one.
+	ID string
+	// X and Y are the longitude and latitude of the center of the points.
+	X, Y float64
+	// Count is the number of points.
+	Count int
+	// Rect is the bounding rectangle of the points.
+	Rect geometry.Rect
+	// ExpansionZoom is the zoom level at which the cluster breaks apart
+	// into smaller clusters. It's zero when Count is one.
+	ExpansionZoom int
+}
+
+type node struct {
+	x, y  float64 // projected into the [0,1] range
+	rect  geometry.Rect
+	count int
+	point int // index of the point when count is one
+	zoom  int // expansion zoom
+}
+
+type level struct {
+	nodes []node
+	tree  *kdtree
+}
+
+// Index is the clusters of a set of points for every zoom level.
+type Index struct {
+	opts   Options
+	points []Point
+	levels []level // from MinZoom to MaxZoom+1
+}
+
+// New returns the cluster index of the points.
+func New(points []Point, opts Options) *Index {
+	if opts.MaxZoom < opts.MinZoom {
+		opts.MaxZoom = opts.MinZoom
+	}
+	idx := &Index{opts: opts, points: points}
+	nodes := make([]node, len(points))
+	for i, p := range points {
+		nodes[i] = node{
+			x: lngX(p.X), y: latY(p.Y),
+			rect:  geometry.Rect{Min: geometry.Point{X: p.X, Y: p.Y}, Max: geometry.Point{X: p.X, Y: p.Y}},
+			count: 1, point: i,
+		}
+	}
+	idx.levels = make([]level, opts.MaxZoom-opts.MinZoom+2)
+	idx.levels[len(idx.levels)-1] = newLevel(nodes)
+	for z := opts.MaxZoom; z >= opts.MinZoom; z-- {
+		idx.levels[z-opts.MinZoom] = newLevel(idx.cluster(
+			idx.levels[z-opts.MinZoom+1], z))
+	}
+	return idx
+}
+
+func newLevel(nodes []node) level {
+	return level{nodes: nodes, tree: newKDTree(len(nodes),
+		func(i int) (float64, float64) { return nodes[i].x, nodes[i].y })}
+}
+
+// cluster merges the nodes of the level below that are within the radius
+// of each other at the zoom.
+func (idx *Index) cluster(below level, zoom int) []node {
+	r := idx.opts.Radius / (idx.opts.Extent * math.Pow(2, float64(zoom)))
+	visited := make([]bool, len(below.nodes))
+	var nodes []node
+	for i := range below.nodes {
+		if visited[i] {
+			continue
+		}
+		visited[i] = true
+		n := below.nodes[i]
+		merged := n
+		wx, wy := n.x*float64(n.count), n.y*float64(n.count)
+		below.tree.within(n.x, n.y, r, func(j int) {
+			if visited[j] {
+				return
+			}
+			visited[j] = true
+			b := below.nodes[j]
+			wx += b.x * float64(b.count)
+			wy += b.y * float64(b.count)
+			merged.count += b.count
+			merged.rect = expand(merged.rect, b.rect)
+		})
+		if merged.count > n.count {
+			merged.x = wx / float64(merged.count)
+			merged.y = wy / float64(merged.count)
+			merged.point = -1
+			merged.zoom = zoom + 1
+		}
+		nodes = append(nodes, merged)
+	}
+	return nodes
+}
+
+// Clusters returns the clusters at the zoom level that have their center
+// inside of the rectangle.
+func (idx *Index) Clusters(rect geometry.Rect, zoom int) []Cluster {
+	if zoom < idx.opts.MinZoom {
+		zoom = idx.opts.MinZoom
+	} else if zoom > idx.opts.MaxZoom+1 {
+		zoom = idx.opts.MaxZoom + 1
+	}
+	lvl := idx.levels[zoom-idx.opts.MinZoom]
+	var clusters []Cluster
+	lvl.tree.search(lngX(rect.Min.X), latY(rect.Max.Y),
+		lngX(rect.Max.X), latY(rect.Min.Y), 0, func(i int) {
+			n := lvl.nodes[i]
+			c := Cluster{X: xLng(n.x), Y: yLat(n.y), Count: n.count,
+				Rect: n.rect, ExpansionZoom: n.zoom}
+			if n.count == 1 {
+				p := idx.points[n.point]
+				c.ID, c.X, c.Y = p.ID, p.X, p.Y
+			}
+			clusters = append(clusters, c)
+		})
+	return clusters
+}
+
+func expand(a, b geometry.Rect) geometry.Rect {
+	a.Min.X = math.Min(a.Min.X, b.Min.X)
+	a.Min.Y = math.Min(a.Min.Y, b.Min.Y)
+	a.Max.X = math.Max(a.Max.X, b.Max.X)
+	a.Max.Y = math.Max(a.Max.Y, b.Max.Y)
+	return a
+}
+
+// lngX and latY project a position into the [0,1] range of web mercator.
+func lngX(lng float64) float64 {
+	return lng/360 + 0.5
+}
+
+func latY(lat float64) float64 {
+	sin := math.Sin(lat * math.Pi / 180)
+	y := 0.5 - 0.25*math.Log((1+sin)/(1-sin))/math.Pi
+	return math.Max(0, math.Min(1, y))
+}
+
+func xLng(x float64) float64 {
+	return (x - 0.5) * 360
+}
+
+func yLat(y float64) float64 {
+	return math.Atan(math.Sinh((1-2*y)*math.Pi)) * 180 / math.Pi
+}
@@ -0,0 +1,121 @@
+package cluster
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/tidwall/geojson/geometry"
+)
+
+var world = geometry.Rect{
+	Min: geometry.Point{X: -180, Y: -90},
+	Max: geometry.Point{X: 180, Y: 90},
+}
+
+func total(clusters []Cluster) int {
+	var n int
+	for _, c := range clusters {
+		n += c.Count
+	}
+	return n
+}
+
+func TestClusters(t *testing.T) {
+	points := []Point{
+		{ID: "a", X: -112.001, Y: 33.001},
+		{ID: "b", X: -112.002, Y: 33.002},
+		{ID: "c", X: -112.003, Y: 33.003},
+		{ID: "d", X: 10, Y: 50},
+	}
+	idx := New(points, DefaultOptions)
+	clusters := idx.Clusters(world, 4)
+	if len(clusters) != 2 || total(clusters) != 4 {
+		t.Fatalf("expected 2 clusters of 4 points, got %v", clusters)
+	}
+	for _, c := range clusters {
+		switch c.Count {
+		case 3:
+			if c.ID != "" || c.ExpansionZoom <= 4 {
+				t.Fatalf("unexpected cluster %v", c)
+			}
+			if c.Rect.Min.X != -112.003 || c.Rect.Max.Y != 33.003 {
+				t.Fatalf("unexpected bounds %v", c.Rect)
+			}
+			if c.X < -112.003 || c.X > -112.001 {
+				t.Fatalf("unexpected center %v", c.X)
+			}
+			if got := idx.Clusters(world, c.ExpansionZoom); len(got) < 3 {
+				t.Fatalf("expected the cluster to expand at %d, got %v",
+					c.ExpansionZoom, got)
+			}
+		case 1:
+			if c.ID != "d" || c.X != 10 || c.Y != 50 {
+				t.Fatalf("unexpected point %v", c)
+			}
+		default:
+			t.Fatalf("unexpected cluster %v", c)
+		}
+	}
+	if clusters := idx.Clusters(world, 20); len(clusters) != 4 {
+		t.Fatalf("expected 4 points, got %v", clusters)
+	}
+	rect := geometry.Rect{
+		Min: geometry.Point{X: 0, Y: 40},
+		Max: geometry.Point{X: 20, Y: 60},
+	}
+	if clusters := idx.Clusters(rect, 4); len(clusters) != 1 ||
+		clusters[0].ID != "d" {
+		t.Fatalf("expected point d, got %v", clusters)
+	}
+}
+
+func TestClustersRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := make([]Point, 10000)
+	for i := range points {
+		points[i] = Point{ID: strconv.Itoa(i),
+			X: rng.Float64()*360 - 180, Y: rng.Float64()*170 - 85}
+	}
+	idx := New(points, DefaultOptions)
+	prev := 0
+	for z := 0; z <= DefaultOptions.MaxZoom+1; z++ {
+		clusters := idx.Clusters(world, z)
+		if total(clusters) != len(points) {
+			t.Fatalf("zoom %d: expected %d points, got %d", z, len(points),
+				total(clusters))
+		}
+		if len(clusters) < prev {
+			t.Fatalf("zoom %d: expected at least %d clusters, got %d", z, prev,
+				len(clusters))
+		}
+		prev = len(clusters)
+	}
+	if prev != len(points) {
+		t.Fatalf("expected %d points, got %d", len(points), prev)
+	}
+}
+
+func TestKDTree(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	xs := make([]float64, 1000)
+	ys := make([]float64, 1000)
+	for i := range xs {
+		xs[i], ys[i] = rng.Float64(), rng.Float64()
+	}
+	tr := newKDTree(len(xs), func(i int) (float64, float64) {
+		return xs[i], ys[i]
+	})
+	var got int
+	tr.within(0.5, 0.5, 0.1, func(int) { got++ })
+	var expect int
+	for i := range xs {
+		dx, dy := xs[i]-0.5, ys[i]-0.5
+		if dx*dx+dy*dy <= 0.01 {
+			expect++
+		}
+	}
+	if got != expect {
+		t.Fatalf("expected %d, got %d", expect, got)
+	}
+}
@@ -0,0 +1,143 @@
+package cluster
+
+import "math"
+
+const kdNodeSize = 64
+
+// kdtree is a static index of points that is built once and then queried
+// by range and by radius. The points are sorted in place, alternating
+// between the x and y axes, until the leaves hold kdNodeSize points.
+type kdtree struct {
+	ids    []int
+	coords []float64 // x and y pairs
+}
+
+func newKDTree(n int, at func(i int) (x, y float64)) *kdtree {
+	tr := &kdtree{
+		ids:    make([]int, n),
+		coords: make([]float64, n*2),
+	}
+	for i := 0; i < n; i++ {
+		tr.ids[i] = i
+		tr.coords[i*2], tr.coords[i*2+1] = at(i)
+	}
+	tr.sort(0, n-1, 0)
+	return tr
+}
+
+func (tr *kdtree) sort(left, right, axis int) {
+	if right-left <= kdNodeSize {
+		return
+	}
+	m := (left + right) >> 1
+	tr.selectK(m, left, right, axis)
+	tr.sort(left, m-1, 1-axis)
+	tr.sort(m+1, right, 1-axis)
+}
+
+// selectK rearranges the items so that the kth item is in its sorted
+// position, with smaller items to its left and larger items to its right.
+func (tr *kdtree) selectK(k, left, right, axis int) {
+	for right > left {
+		t := tr.coords[k*2+axis]
+		i, j := left, right
+		tr.swap(left, k)
+		if tr.coords[right*2+axis] > t {
+			tr.swap(left, right)
+		}
+		for i < j {
+			tr.swap(i, j)
+			i++
+			j--
+			for tr.coords[i*2+axis] < t {
+				i++
+			}
+			for tr.coords[j*2+axis] > t {
+				j--
+			}
+		}
+		if tr.coords[left*2+axis] == t {
+			tr.swap(left, j)
+		} else {
+			j++
+			tr.swap(j, right)
+		}
+		if j <= k {
+			left = j + 1
+		}
+		if k <= j {
+			right = j - 1
+		}
+	}
+}
+
+func (tr *kdtree) swap(i, j int) {
+	tr.ids[i], tr.ids[j] = tr.ids[j], tr.ids[i]
+	tr.coords[i*2], tr.coords[j*2] = tr.coords[j*2], tr.coords[i*2]
+	tr.coords[i*2+1], tr.coords[j*2+1] = tr.coords[j*2+1], tr.coords[i*2+1]
+}
+
+// search calls iter with the ids of the points that are inside of the
+// rectangle. A radius greater than zero limits the points to those that
+// are within that distance of the center of the rectangle.
+func (tr *kdtree) search(minX, minY, maxX, maxY, radius float64,
+	iter func(id int),
+) {
+	if len(tr.ids) == 0 {
+		return
+	}
+	cx, cy := (minX+maxX)/2, (minY+maxY)/2
+	r2 := radius * radius
+	stack := []int{0, len(tr.ids) - 1, 0}
+	for len(stack) > 0 {
+		axis := stack[len(stack)-1]
+		right := stack[len(stack)-2]
+		left := stack[len(stack)-3]
+		stack = stack[:len(stack)-3]
+		if right-left <= kdNodeSize {
+			for i := left; i <= right; i++ {
+				if tr.contains(i, minX, minY, maxX, maxY, cx, cy, r2) {
+					iter(tr.ids[i])
+				}
+			}
+			continue
+		}
+		m := (left + right) >> 1
+		if tr.contains(m, minX, minY, maxX, maxY, cx, cy, r2) {
+			iter(tr.ids[m])
+		}
+		v := tr.coords[m*2+axis]
+		lo, hi := minX, maxX
+		if axis == 1 {
+			lo, hi = minY, maxY
+		}
+		if lo <= v {
+			stack = append(stack, left, m-1, 1-axis)
+		}
+		if hi >= v {
+			stack = append(stack, m+1, right, 1-axis)
+		}
+	}
+}
+
+func (tr *kdtree) contains(i int, minX, minY, maxX, maxY, cx, cy, r2 float64,
+) bool {
+	x, y := tr.coords[i*2], tr.coords[i*2+1]
+	if x < minX || x > maxX || y < minY || y > maxY {
+		return false
+	}
+	if r2 > 0 {
+		dx, dy := x-cx, y-cy
+		return dx*dx+dy*dy <= r2
+	}
+	return true
+}
+
+// within calls iter with the ids of the points that are within the radius
+// of the point.
+func (tr *kdtree) within(x, y, radius float64, iter func(id int)) {
+	if radius <= 0 {
+		radius = math.SmallestNonzeroFloat64
+	}
+	tr.search(x-radius, y-radius, x+radius, y+radius, radius, iter)
+}
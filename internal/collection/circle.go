@@ -0,0 +1,160 @@
+package collection
+
+import (
+	"math"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geo"
+	"github.com/tidwall/geojson/geometry"
+)
+
+// Circles
+//
+// A circle is kept as its center and radius, as the geojson.Circle that's
+// parsed, and it's indexed with its bounding box on the sphere. The geojson
+// package tests circles with a polygon of 64 steps that's an ellipse in
+// degrees, which misses the edge of the circle at high latitudes, and so
+// the searches test them here instead: the distances from the center are
+// measured with the haversine, and the closest point of an edge is found in
+// a projection around the center.
+
+// circleRect returns the bounding box of a circle on the sphere.
+func circleRect(c *geojson.Circle) geometry.Rect {
+	center := c.Center()
+	if c.Meters() <= 0 {
+		return geometry.Rect{Min: center, Max: center}
+	}
+	minLat, minLon, maxLat, maxLon := geo.RectFromCenter(center.Y, center.X,
+		c.Meters())
+	return geometry.Rect{
+		Min: geometry.Point{X: minLon, Y: minLat},
+		Max: geometry.Point{X: maxLon, Y: maxLat},
+	}
+}
+
+// objRect returns the rect of an object in the index.
+func objRect(obj geojson.Object) geometry.Rect {
+	if c, ok := obj.(*geojson.Circle); ok {
+		return circleRect(c)
+	}
+	return obj.Rect()
+}
+
+// ObjectWithin returns true when an object is within the target. Circles are
+// tested on the sphere.
+func ObjectWithin(obj, target geojson.Object) bool {
+	if c, ok := obj.(*geojson.Circle); ok {
+		return circleWithin(c, target)
+	}
+	return obj.Within(target)
+}
+
+// ObjectIntersects returns true when an object intersects the target.
+// Circles are tested on the sphere.
+func ObjectIntersects(obj, target geojson.Object) bool {
+	if c, ok := obj.(*geojson.Circle); ok {
+		return circleIntersects(c, target)
+	}
+	return obj.Intersects(target)
+}
+
+func circleWithin(c *geojson.Circle, target geojson.Object) bool {
+	center := c.Center()
+	switch t := target.(type) {
+	case *geojson.Circle:
+		return pointDist(center, t.Center())+c.Meters() <= t.Meters()
+	case *geojson.Feature:
+		return circleWithin(c, t.Base())
+	case *geojson.Rect:
+		return t.Base().ContainsPoint(center) &&
+			seriesDist(center, t.Base()) >= c.Meters()
+	case *geojson.Polygon:
+		poly := t.Base()
+		return poly.ContainsPoint(center) &&
+			polyDist(center, poly) >= c.Meters()
+	case geojson.Collection:
+		for _, child := range t.Children() {
+			if circleWithin(c, child) {
+				return true
+			}
+		}
+		return false
+	}
+	// points and lines have no area
+	return c.Meters() <= 0 && target.Contains(geojson.NewPoint(center))
+}
+
+func circleIntersects(c *geojson.Circle, target geojson.Object) bool {
+	center := c.Center()
+	switch t := target.(type) {
+	case *geojson.Circle:
+		return pointDist(center, t.Center()) <= c.Meters()+t.Meters()
+	case *geojson.Point:
+		return pointDist(center, t.Base()) <= c.Meters()
+	case *geojson.SimplePoint:
+		return pointDist(center, t.Base()) <= c.Meters()
+	case *geojson.Feature:
+		return circleIntersects(c, t.Base())
+	case *geojson.Rect:
+		return t.Base().ContainsPoint(center) ||
+			seriesDist(center, t.Base()) <= c.Meters()
+	case *geojson.Polygon:
+		poly := t.Base()
+		return poly.ContainsPoint(center) ||
+			polyDist(center, poly) <= c.Meters()
+	case *geojson.LineString:
+		return seriesDist(center, t.Base()) <= c.Meters()
+	case geojson.Collection:
+		for _, child := range t.Children() {
+			if circleIntersects(c, child) {
+				return true
+			}
+		}
+		return false
+	}
+	return c.Intersects(target)
+}
+
+// pointDist returns the distance between two points, in meters.
+func pointDist(a, b geometry.Point) float64 {
+	return geo.DistanceTo(a.Y, a.X, b.Y, b.X)
+}
+
+// polyDist returns the distance from a point to the closest edge of a
+// polygon, in meters.
+func polyDist(p geometry.Point, poly *geometry.Poly) float64 {
+	dist := seriesDist(p, poly.Exterior)
+	for _, hole := range poly.Holes {
+		dist = math.Min(dist, seriesDist(p, hole))
+	}
+	return dist
+}
+
+// seriesDist returns the distance from a point to the closest segment of a
+// series, in meters.
+func seriesDist(p geometry.Point, series geometry.Series) float64 {
+	dist := math.Inf(1)
+	for i := 0; i < series.NumSegments(); i++ {
+		dist = math.Min(dist, segmentDist(p, series.SegmentAt(i)))
+	}
+	return dist
+}
+
+// segmentDist returns the distance from a point to the closest point of a
+// segment, in meters. The closest point is found in an equirectangular
+// projection around the point.
+func segmentDist(p geometry.Point, seg geometry.Segment) float64 {
+	scale := math.Cos(p.Y * math.Pi / 180)
+	ax, ay := (seg.A.X-p.X)*scale, seg.A.Y-p.Y
+	bx, by := (seg.B.X-p.X)*scale, seg.B.Y-p.Y
+	dx, dy := bx-ax, by-ay
+	var t float64
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+	}
+	q := geometry.Point{
+		X: seg.A.X + (seg.B.X-seg.A.X)*t,
+		Y: seg.A.Y + (seg.B.Y-seg.A.Y)*t,
+	}
+	return pointDist(p, q)
+}
@@ -1,19 +1,28 @@
 package collection
 
 import (
+	"math/rand"
 	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/tidwall/btree"
 	"github.com/tidwall/geoindex"
 	"github.com/tidwall/geojson"
 	"github.com/tidwall/geojson/geo"
 	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/rtred/base"
 	"github.com/tidwall/rtree"
 	"github.com/tidwall/tile38/internal/deadline"
 )
 
-// yieldStep forces the iterator to yield goroutine every 256 steps.
-const yieldStep = 256
+// yieldCheck is how often, in steps, that an iterator checks the clock and
+// the deadline.
+const yieldCheck = 64
+
+// yieldInterval is how long an iterator runs before it yields the goroutine.
+const yieldInterval = time.Millisecond
 
 // Cursor allows for quickly paging through Scan, Within, Intersects, and Nearby
 type Cursor interface {
@@ -22,7 +31,9 @@ type Cursor interface {
 }
 
 type itemT struct {
+	atime           int64 // unix nano of the last access, must be first for atomic alignment
 	id              string
+	meta            string // opaque metadata, see meta.go
 	obj             geojson.Object
 	expires         int64 // unix nano expiration
 	fieldValuesSlot fieldValuesSlot
@@ -69,8 +80,23 @@ type Collection struct {
 	fieldValues *fieldValues
 	weight      int
 	points      int
-	objects     int // geometry count
-	nobjects    int // non-geometry count
+	objects     int          // geometry count
+	nobjects    int          // non-geometry count
+	version     uint64       // increments on every change
+	shared      *sharedTable // interned geometries, see shared.go
+	codec       Codec        // storage format of new geometries, see packed.go
+
+	fieldExpires fieldExpires // expirations of fields, see fieldexpires.go
+	tags         tagIndex     // tags of the objects, see tags.go
+	strFields    stringFields // string field values, see strfields.go
+	fieldIndexes fieldIndexes // field values, see fieldindex.go
+	counts       nodeCounts   // objects under the rtree nodes, see count.go
+
+	zindex  *base.RTree          // items by altitude too, see zindex.go
+	zmu     sync.Mutex           // guards zvalues, which readers fill
+	zvalues map[*itemT][]float64 // z coordinates of the geometries
+
+	snapmu sync.Mutex // serializes the copies of the trees, see snapshot.go
 }
 
 // New creates an empty collection
@@ -117,43 +143,53 @@ func (c *Collection) Bounds() (minX, minY, maxX, maxY float64) {
 }
 
 func objIsSpatial(obj geojson.Object) bool {
-	_, ok := obj.(geojson.Spatial)
-	return ok
+	switch obj.(type) {
+	case geojson.Spatial, *packedGeom, *geojson.Circle:
+		return true
+	}
+	return false
 }
 
 func (c *Collection) objWeight(item *itemT) int {
 	var weight int
 	if objIsSpatial(item.obj) {
-		weight = item.obj.NumPoints() * 16
+		// a shared geometry is weighed once, by the shared table
+		if c.sharedGeom(item.obj) == nil {
+			weight = geomWeight(item.obj)
+		}
 	} else {
 		weight = len(item.obj.String())
 	}
-	return weight + len(c.fieldValues.get(item.fieldValuesSlot))*8 + len(item.id)
+	return weight + len(c.fieldValues.get(item.fieldValuesSlot))*8 +
+		len(item.id) + len(item.meta)
 }
 
 func (c *Collection) indexDelete(item *itemT) {
 	if !item.obj.Empty() {
-		rect := item.obj.Rect()
+		rect := objRect(item.obj)
 		c.index.Delete(
 			[2]float64{rect.Min.X, rect.Min.Y},
 			[2]float64{rect.Max.X, rect.Max.Y},
 			item)
+		c.zindexDelete(item)
 	}
+	c.forgetZ(item)
 }
 
 func (c *Collection) indexInsert(item *itemT) {
 	if !item.obj.Empty() {
-		rect := item.obj.Rect()
+		rect := objRect(item.obj)
 		c.index.Insert(
 			[2]float64{rect.Min.X, rect.Min.Y},
 			[2]float64{rect.Max.X, rect.Max.Y},
 			item)
+		c.zindexInsert(item)
 	}
 }
 
 // Set adds or replaces an object in the collection and returns the fields
 // array. If an item with the same id is already in the collection then the
-// new item will adopt the old item's fields.
+// new item will adopt the old item's fields and metadata.
 // The fields argument is optional.
 // The return values are the old object, the old fields, and the new fields
 func (c *Collection) Set(
@@ -161,7 +197,9 @@ func (c *Collection) Set(
 ) (
 	oldObject geojson.Object, oldFieldValues []float64, newFieldValues []float64,
 ) {
-	newItem := &itemT{id: id, obj: obj, fieldValuesSlot: nilValuesSlot, expires: ex}
+	c.version++
+	newItem := &itemT{id: id, obj: c.intern(c.pack(obj)), fieldValuesSlot: nilValuesSlot,
+		expires: ex, atime: time.Now().UnixNano()}
 
 	// add the new item to main btree and remove the old one if needed
 	oldItem := c.items.Set(newItem)
@@ -180,17 +218,22 @@ func (c *Collection) Set(
 			c.expires.Delete(oldItem)
 		}
 
+		// delete old item from the field indexes
+		c.fieldIndexDelete(oldItem)
+
 		// decrement the point count
 		c.points -= oldItem.obj.NumPoints()
 
 		// decrement the weights
 		c.weight -= c.objWeight(oldItem)
+		c.release(oldItem.obj)
 
 		// references
-		oldObject = oldItem.obj
+		oldObject = unpack(oldItem.obj)
 		oldFieldValues = c.fieldValues.get(oldItem.fieldValuesSlot)
 		newFieldValues = oldFieldValues
 		newItem.fieldValuesSlot = oldItem.fieldValuesSlot
+		newItem.meta = oldItem.meta
 	}
 
 	if fields == nil {
@@ -215,6 +258,7 @@ func (c *Collection) Set(
 	if newItem.expires != 0 {
 		c.expires.Set(newItem)
 	}
+	c.fieldIndexInsert(newItem)
 
 	// increment the point count
 	c.points += newItem.obj.NumPoints()
@@ -234,6 +278,7 @@ func (c *Collection) Delete(id string) (
 	if v == nil {
 		return nil, nil, false
 	}
+	c.version++
 	oldItem := v.(*itemT)
 	if objIsSpatial(oldItem.obj) {
 		if !oldItem.obj.Empty() {
@@ -248,12 +293,17 @@ func (c *Collection) Delete(id string) (
 	if oldItem.expires != 0 {
 		c.expires.Delete(oldItem)
 	}
+	c.deleteFieldExpires(id)
+	c.deleteTags(id)
+	c.deleteStringFields(id)
+	c.fieldIndexDelete(oldItem)
 	c.weight -= c.objWeight(oldItem)
+	c.release(oldItem.obj)
 	c.points -= oldItem.obj.NumPoints()
 
 	fields = c.fieldValues.get(oldItem.fieldValuesSlot)
 	c.fieldValues.remove(oldItem.fieldValuesSlot)
-	return oldItem.obj, fields, true
+	return unpack(oldItem.obj), fields, true
 }
 
 // Get returns an object.
@@ -266,7 +316,52 @@ func (c *Collection) Get(id string) (
 		return nil, nil, 0, false
 	}
 	item := itemV.(*itemT)
-	return item.obj, c.fieldValues.get(item.fieldValuesSlot), item.expires, true
+	atomic.StoreInt64(&item.atime, time.Now().UnixNano())
+	return unpack(item.obj), c.fieldValues.get(item.fieldValuesSlot), item.expires, true
+}
+
+// Expires returns the expiration of an object, which is zero when the object
+// doesn't expire. Unlike Get, the access time of the object is unchanged.
+func (c *Collection) Expires(id string) (ex int64, ok bool) {
+	itemV := c.items.Get(&itemT{id: id})
+	if itemV == nil {
+		return 0, false
+	}
+	return itemV.(*itemT).expires, true
+}
+
+// ObjectInfo describes how an object is stored in the collection.
+type ObjectInfo struct {
+	Encoding string // "geojson", "packed", "shared", or "string"
+	Weight   int    // in-memory cost that's counted by TotalWeight
+	Points   int
+	Fields   int // number of field values
+}
+
+// ObjectInfo returns how an object is stored. Unlike Get, the access time of
+// the object is unchanged.
+func (c *Collection) ObjectInfo(id string) (info ObjectInfo, ok bool) {
+	itemV := c.items.Get(&itemT{id: id})
+	if itemV == nil {
+		return info, false
+	}
+	item := itemV.(*itemT)
+	switch {
+	case !objIsSpatial(item.obj):
+		info.Encoding = "string"
+	case c.sharedGeom(item.obj) != nil:
+		info.Encoding = "shared"
+	default:
+		if _, packed := item.obj.(*packedGeom); packed {
+			info.Encoding = "packed"
+		} else {
+			info.Encoding = "geojson"
+		}
+	}
+	info.Weight = c.objWeight(item)
+	info.Points = item.obj.NumPoints()
+	info.Fields = len(c.fieldValues.get(item.fieldValuesSlot))
+	return info, true
 }
 
 func (c *Collection) SetExpires(id string, ex int64) bool {
@@ -274,6 +369,7 @@ func (c *Collection) SetExpires(id string, ex int64) bool {
 	if v == nil {
 		return false
 	}
+	c.version++
 	item := v.(*itemT)
 	if item.expires != 0 {
 		c.expires.Delete(item)
@@ -295,9 +391,11 @@ func (c *Collection) SetField(id, field string, value float64) (
 		return nil, nil, false, false
 	}
 	item := itemV.(*itemT)
+	c.fieldIndexDelete(item)
 	_, updateCount, weightDelta := c.setFieldValues(item, []string{field}, []float64{value})
+	c.fieldIndexInsert(item)
 	c.weight += weightDelta
-	return item.obj, c.fieldValues.get(item.fieldValuesSlot), updateCount > 0, true
+	return unpack(item.obj), c.fieldValues.get(item.fieldValuesSlot), updateCount > 0, true
 }
 
 // SetFields is similar to SetField, just setting multiple fields at once
@@ -309,9 +407,11 @@ func (c *Collection) SetFields(
 		return nil, nil, 0, false
 	}
 	item := itemV.(*itemT)
+	c.fieldIndexDelete(item)
 	newFieldValues, updateCount, weightDelta := c.setFieldValues(item, inFields, inValues)
+	c.fieldIndexInsert(item)
 	c.weight += weightDelta
-	return item.obj, newFieldValues, updateCount, true
+	return unpack(item.obj), newFieldValues, updateCount, true
 }
 
 func (c *Collection) setFieldValues(item *itemT, fields []string, updateValues []float64) (
@@ -319,6 +419,7 @@ func (c *Collection) setFieldValues(item *itemT, fields []string, updateValues [
 	updated int,
 	weightDelta int,
 ) {
+	c.version++
 	newValues = c.fieldValues.get(item.fieldValuesSlot)
 	for i, field := range fields {
 		fieldIdx, ok := c.fieldMap[field]
@@ -387,6 +488,7 @@ func (c *Collection) Scan(
 ) bool {
 	var keepon = true
 	var count uint64
+	var lastYield int64
 	var offset uint64
 	if cursor != nil {
 		offset = cursor.Offset()
@@ -395,11 +497,12 @@ func (c *Collection) Scan(
 	iter := func(item interface{}) bool {
 		count++
 		if count <= offset {
+			skipStep(count, deadline)
 			return true
 		}
-		nextStep(count, cursor, deadline)
+		nextStep(count, cursor, deadline, &lastYield)
 		iitm := item.(*itemT)
-		keepon = iterator(iitm.id, iitm.obj, c.fieldValues.get(iitm.fieldValuesSlot))
+		keepon = iterator(iitm.id, unpack(iitm.obj), c.fieldValues.get(iitm.fieldValuesSlot))
 		return keepon
 	}
 	if desc {
@@ -420,6 +523,7 @@ func (c *Collection) ScanRange(
 ) bool {
 	var keepon = true
 	var count uint64
+	var lastYield int64
 	var offset uint64
 	if cursor != nil {
 		offset = cursor.Offset()
@@ -429,9 +533,10 @@ func (c *Collection) ScanRange(
 		item := value.(*itemT)
 		count++
 		if count <= offset {
+			skipStep(count, deadline)
 			return true
 		}
-		nextStep(count, cursor, deadline)
+		nextStep(count, cursor, deadline, &lastYield)
 		if !desc {
 			if item.id >= end {
 				return false
@@ -442,7 +547,7 @@ func (c *Collection) ScanRange(
 			}
 		}
 		iitm := value.(*itemT)
-		keepon = iterator(iitm.id, iitm.obj, c.fieldValues.get(iitm.fieldValuesSlot))
+		keepon = iterator(iitm.id, unpack(iitm.obj), c.fieldValues.get(iitm.fieldValuesSlot))
 		return keepon
 	}
 
@@ -463,6 +568,7 @@ func (c *Collection) SearchValues(
 ) bool {
 	var keepon = true
 	var count uint64
+	var lastYield int64
 	var offset uint64
 	if cursor != nil {
 		offset = cursor.Offset()
@@ -471,9 +577,10 @@ func (c *Collection) SearchValues(
 	iter := func(item interface{}) bool {
 		count++
 		if count <= offset {
+			skipStep(count, deadline)
 			return true
 		}
-		nextStep(count, cursor, deadline)
+		nextStep(count, cursor, deadline, &lastYield)
 		iitm := item.(*itemT)
 		keepon = iterator(iitm.id, iitm.obj, c.fieldValues.get(iitm.fieldValuesSlot))
 		return keepon
@@ -494,6 +601,7 @@ func (c *Collection) SearchValuesRange(start, end string, desc bool,
 ) bool {
 	var keepon = true
 	var count uint64
+	var lastYield int64
 	var offset uint64
 	if cursor != nil {
 		offset = cursor.Offset()
@@ -502,9 +610,10 @@ func (c *Collection) SearchValuesRange(start, end string, desc bool,
 	iter := func(item interface{}) bool {
 		count++
 		if count <= offset {
+			skipStep(count, deadline)
 			return true
 		}
-		nextStep(count, cursor, deadline)
+		nextStep(count, cursor, deadline, &lastYield)
 		iitm := item.(*itemT)
 		keepon = iterator(iitm.id, iitm.obj, c.fieldValues.get(iitm.fieldValuesSlot))
 		return keepon
@@ -535,6 +644,7 @@ func (c *Collection) ScanGreaterOrEqual(id string, desc bool,
 ) bool {
 	var keepon = true
 	var count uint64
+	var lastYield int64
 	var offset uint64
 	if cursor != nil {
 		offset = cursor.Offset()
@@ -543,11 +653,12 @@ func (c *Collection) ScanGreaterOrEqual(id string, desc bool,
 	iter := func(v interface{}) bool {
 		count++
 		if count <= offset {
+			skipStep(count, deadline)
 			return true
 		}
-		nextStep(count, cursor, deadline)
+		nextStep(count, cursor, deadline, &lastYield)
 		item := v.(*itemT)
-		keepon = iterator(item.id, item.obj, c.fieldValues.get(item.fieldValuesSlot), item.expires)
+		keepon = iterator(item.id, unpack(item.obj), c.fieldValues.get(item.fieldValuesSlot), item.expires)
 		return keepon
 	}
 	if desc {
@@ -561,14 +672,41 @@ func (c *Collection) ScanGreaterOrEqual(id string, desc bool,
 func (c *Collection) geoSearch(
 	rect geometry.Rect,
 	iter func(id string, obj geojson.Object, fields []float64) bool,
+) bool {
+	return c.geoSearchZ(rect, nil, iter)
+}
+
+// geoSearchZ returns the objects in a rectangle, and in an altitude band
+// when zr isn't nil.
+func (c *Collection) geoSearchZ(
+	rect geometry.Rect, zr *zRange,
+	iter func(id string, obj geojson.Object, fields []float64) bool,
 ) bool {
 	alive := true
+	if zr != nil && c.zindex != nil {
+		c.zindex.Search(
+			[]float64{rect.Min.X, rect.Min.Y, zr.min},
+			[]float64{rect.Max.X, rect.Max.Y, zr.max},
+			func(itemv interface{}) bool {
+				item := itemv.(*itemT)
+				if !zr.match(c.itemZ(item)) {
+					return true
+				}
+				alive = iter(item.id, unpack(item.obj), c.fieldValues.get(item.fieldValuesSlot))
+				return alive
+			},
+		)
+		return alive
+	}
 	c.index.Search(
 		[2]float64{rect.Min.X, rect.Min.Y},
 		[2]float64{rect.Max.X, rect.Max.Y},
 		func(_, _ [2]float64, itemv interface{}) bool {
 			item := itemv.(*itemT)
-			alive = iter(item.id, item.obj, c.fieldValues.get(item.fieldValuesSlot))
+			if zr != nil && !zr.match(c.itemZ(item)) {
+				return true
+			}
+			alive = iter(item.id, unpack(item.obj), c.fieldValues.get(item.fieldValuesSlot))
 			return alive
 		},
 	)
@@ -576,12 +714,12 @@ func (c *Collection) geoSearch(
 }
 
 func (c *Collection) geoSparse(
-	obj geojson.Object, sparse uint8,
+	obj geojson.Object, zr *zRange, sparse uint8,
 	iter func(id string, obj geojson.Object, fields []float64) (match, ok bool),
 ) bool {
 	matches := make(map[string]bool)
 	alive := true
-	c.geoSparseInner(obj.Rect(), sparse,
+	c.geoSparseInner(objRect(obj), zr, sparse,
 		func(id string, o geojson.Object, fields []float64) (
 			match, ok bool,
 		) {
@@ -598,7 +736,7 @@ func (c *Collection) geoSparse(
 	return alive
 }
 func (c *Collection) geoSparseInner(
-	rect geometry.Rect, sparse uint8,
+	rect geometry.Rect, zr *zRange, sparse uint8,
 	iter func(id string, obj geojson.Object, fields []float64) (match, ok bool),
 ) bool {
 	if sparse > 0 {
@@ -623,14 +761,14 @@ func (c *Collection) geoSparseInner(
 			},
 		}
 		for _, quad := range quads {
-			if !c.geoSparseInner(quad, sparse-1, iter) {
+			if !c.geoSparseInner(quad, zr, sparse-1, iter) {
 				return false
 			}
 		}
 		return true
 	}
 	alive := true
-	c.geoSearch(rect,
+	c.geoSearchZ(rect, zr,
 		func(id string, obj geojson.Object, fields []float64) bool {
 			match, ok := iter(id, obj, fields)
 			if !ok {
@@ -651,38 +789,52 @@ func (c *Collection) Within(
 	cursor Cursor,
 	deadline *deadline.Deadline,
 	iter func(id string, obj geojson.Object, fields []float64) bool,
+) bool {
+	return c.within(obj, nil, sparse, cursor, deadline, iter)
+}
+
+func (c *Collection) within(
+	obj geojson.Object,
+	zr *zRange,
+	sparse uint8,
+	cursor Cursor,
+	deadline *deadline.Deadline,
+	iter func(id string, obj geojson.Object, fields []float64) bool,
 ) bool {
 	var count uint64
+	var lastYield int64
 	var offset uint64
 	if cursor != nil {
 		offset = cursor.Offset()
 		cursor.Step(offset)
 	}
 	if sparse > 0 {
-		return c.geoSparse(obj, sparse,
+		return c.geoSparse(obj, zr, sparse,
 			func(id string, o geojson.Object, fields []float64) (
 				match, ok bool,
 			) {
 				count++
 				if count <= offset {
+					skipStep(count, deadline)
 					return false, true
 				}
-				nextStep(count, cursor, deadline)
-				if match = o.Within(obj); match {
+				nextStep(count, cursor, deadline, &lastYield)
+				if match = ObjectWithin(o, obj); match {
 					ok = iter(id, o, fields)
 				}
 				return match, ok
 			},
 		)
 	}
-	return c.geoSearch(obj.Rect(),
+	return c.geoSearchZ(objRect(obj), zr,
 		func(id string, o geojson.Object, fields []float64) bool {
 			count++
 			if count <= offset {
+				skipStep(count, deadline)
 				return true
 			}
-			nextStep(count, cursor, deadline)
-			if o.Within(obj) {
+			nextStep(count, cursor, deadline, &lastYield)
+			if ObjectWithin(o, obj) {
 				return iter(id, o, fields)
 			}
 			return true
@@ -698,38 +850,52 @@ func (c *Collection) Intersects(
 	cursor Cursor,
 	deadline *deadline.Deadline,
 	iter func(id string, obj geojson.Object, fields []float64) bool,
+) bool {
+	return c.intersects(obj, nil, sparse, cursor, deadline, iter)
+}
+
+func (c *Collection) intersects(
+	obj geojson.Object,
+	zr *zRange,
+	sparse uint8,
+	cursor Cursor,
+	deadline *deadline.Deadline,
+	iter func(id string, obj geojson.Object, fields []float64) bool,
 ) bool {
 	var count uint64
+	var lastYield int64
 	var offset uint64
 	if cursor != nil {
 		offset = cursor.Offset()
 		cursor.Step(offset)
 	}
 	if sparse > 0 {
-		return c.geoSparse(obj, sparse,
+		return c.geoSparse(obj, zr, sparse,
 			func(id string, o geojson.Object, fields []float64) (
 				match, ok bool,
 			) {
 				count++
 				if count <= offset {
+					skipStep(count, deadline)
 					return false, true
 				}
-				nextStep(count, cursor, deadline)
-				if match = o.Intersects(obj); match {
+				nextStep(count, cursor, deadline, &lastYield)
+				if match = ObjectIntersects(o, obj); match {
 					ok = iter(id, o, fields)
 				}
 				return match, ok
 			},
 		)
 	}
-	return c.geoSearch(obj.Rect(),
+	return c.geoSearchZ(objRect(obj), zr,
 		func(id string, o geojson.Object, fields []float64) bool {
 			count++
 			if count <= offset {
+				skipStep(count, deadline)
 				return true
 			}
-			nextStep(count, cursor, deadline)
-			if o.Intersects(obj) {
+			nextStep(count, cursor, deadline, &lastYield)
+			if ObjectIntersects(o, obj) {
 				return iter(id, o, fields)
 			}
 			return true
@@ -771,6 +937,7 @@ func (c *Collection) Nearby(
 	alive := true
 	center := target.Center()
 	var count uint64
+	var lastYield int64
 	var offset uint64
 	if cursor != nil {
 		offset = cursor.Offset()
@@ -781,35 +948,77 @@ func (c *Collection) Nearby(
 		func(_, _ [2]float64, itemv interface{}, dist float64) bool {
 			count++
 			if count <= offset {
+				skipStep(count, deadline)
 				return true
 			}
-			nextStep(count, cursor, deadline)
+			nextStep(count, cursor, deadline, &lastYield)
 			item := itemv.(*itemT)
-			alive = iter(item.id, item.obj, c.fieldValues.get(item.fieldValuesSlot), dist)
+			alive = iter(item.id, unpack(item.obj), c.fieldValues.get(item.fieldValuesSlot), dist)
 			return alive
 		},
 	)
 	return alive
 }
 
-func nextStep(step uint64, cursor Cursor, deadline *deadline.Deadline) {
-	if step&(yieldStep-1) == (yieldStep - 1) {
-		runtime.Gosched()
+// nextStep is called for every step of an iterator. Every so often it checks
+// the deadline, which panics when the deadline is reached or the command is
+// canceled, and yields the goroutine when the iterator has been running for
+// longer than the yieldInterval since the last yield.
+func nextStep(step uint64, cursor Cursor, deadline *deadline.Deadline,
+	lastYield *int64,
+) {
+	if step&(yieldCheck-1) == 0 {
 		deadline.Check()
+		now := time.Now().UnixNano()
+		if *lastYield == 0 {
+			*lastYield = now
+		} else if now-*lastYield >= int64(yieldInterval) {
+			runtime.Gosched()
+			*lastYield = time.Now().UnixNano()
+		}
 	}
 	if cursor != nil {
 		cursor.Step(1)
 	}
 }
 
+// skipStep is called for every step of an iterator that's skipped by the
+// offset of a cursor. Like nextStep, it checks the deadline every so often.
+func skipStep(step uint64, deadline *deadline.Deadline) {
+	if step&(yieldCheck-1) == 0 {
+		deadline.Check()
+	}
+}
+
 type Expired struct {
 	ID     string
 	Obj    geojson.Object
 	Fields []float64
 }
 
+// Sample calls the iterator for up to n randomly chosen objects, along with
+// the time the object was last accessed by Set or Get.
+func (c *Collection) Sample(n int, iter func(id string, atime int64) bool) {
+	count := c.items.Len()
+	if n > count {
+		n = count
+	}
+	for i := 0; i < n; i++ {
+		item := c.items.GetAt(rand.Intn(count)).(*itemT)
+		if !iter(item.id, atomic.LoadInt64(&item.atime)) {
+			return
+		}
+	}
+}
+
 // Expired returns a list of all objects that have expired.
 func (c *Collection) Expired(now int64, buffer []string) (ids []string) {
+	return c.ExpiredLimit(now, buffer, 0)
+}
+
+// ExpiredLimit returns a list of objects that have expired, oldest first.
+// No more than limit ids are returned, unless the limit is zero.
+func (c *Collection) ExpiredLimit(now int64, buffer []string, limit int) (ids []string) {
 	ids = buffer[:0]
 	c.expires.Ascend(nil, func(v interface{}) bool {
 		item := v.(*itemT)
@@ -817,7 +1026,16 @@ func (c *Collection) Expired(now int64, buffer []string) (ids []string) {
 			return false
 		}
 		ids = append(ids, item.id)
-		return true
+		return limit == 0 || len(ids) < limit
 	})
 	return ids
 }
+
+// ScanExpires iterates over the objects that have an expiration, in the
+// order that they expire.
+func (c *Collection) ScanExpires(iter func(id string, expires int64) bool) {
+	c.expires.Ascend(nil, func(v interface{}) bool {
+		item := v.(*itemT)
+		return iter(item.id, item.expires)
+	})
+}
@@ -1,16 +1,23 @@
 package collection
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geo"
 	"github.com/tidwall/geojson/geometry"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/tile38/internal/deadline"
 )
 
 func PO(x, y float64) *geojson.Point {
@@ -682,6 +689,20 @@ func benchmarkInsert(t *testing.B, nFields int) {
 	}
 }
 
+func BenchmarkLoad(t *testing.B) {
+	rand.Seed(time.Now().UnixNano())
+	items := make([]ItemInput, t.N)
+	for i := 0; i < t.N; i++ {
+		items[i] = ItemInput{
+			ID:     fmt.Sprintf("%d", i),
+			Object: PO(rand.Float64()*360-180, rand.Float64()*180-90),
+		}
+	}
+	col := New()
+	t.ResetTimer()
+	col.Load(items)
+}
+
 func BenchmarkReplace_Fields(t *testing.B) {
 	benchmarkReplace(t, 1)
 }
@@ -806,3 +827,1044 @@ func benchmarkScan(t *testing.B, nFields int) {
 		})
 	}
 }
+
+func TestCollectionSnapshot(t *testing.T) {
+	c := New()
+	for i := 0; i < 100; i++ {
+		id := strconv.Itoa(i)
+		c.Set(id, PO(float64(i), float64(i)), []string{"a"}, []float64{float64(i)}, 0)
+	}
+	c.Set("str", String("hello"), nil, nil, 0)
+	c.SetExpires("1", 100)
+	snap := c.Snapshot()
+	expect(t, snap.Version() == c.Version())
+	expect(t, snap.Count() == c.Count())
+	expect(t, snap.TotalWeight() == c.TotalWeight())
+	expect(t, bounds(snap) == bounds(c))
+
+	// modify the original and make sure the snapshot is unchanged
+	c.Delete("0")
+	c.SetField("2", "a", 1000)
+	c.Set("3", PO(500, 500), nil, nil, 0)
+	c.Set("str", String("world"), nil, nil, 0)
+	expect(t, snap.Version() != c.Version())
+	expect(t, snap.Count() == 101)
+
+	obj, fields, ex, ok := snap.Get("2")
+	expect(t, ok && fields[0] == 2 && ex == 0)
+	_, _, ex, ok = snap.Get("1")
+	expect(t, ok && ex == 100)
+	obj, _, _, ok = snap.Get("3")
+	expect(t, ok && obj.Center() == geometry.Point{X: 3, Y: 3})
+	obj, _, _, ok = snap.Get("str")
+	expect(t, ok && obj.String() == "hello")
+	expect(t, snap.Expired(200, nil)[0] == "1")
+
+	var n int
+	snap.Within(PO(500, 500), 0, nil, nil,
+		func(id string, obj geojson.Object, fields []float64) bool {
+			n++
+			return true
+		},
+	)
+	expect(t, n == 0)
+	snap.Within(geojson.NewRect(geometry.Rect{
+		Min: geometry.Point{X: 0, Y: 0},
+		Max: geometry.Point{X: 10, Y: 10},
+	}), 0, nil, nil,
+		func(id string, obj geojson.Object, fields []float64) bool {
+			n++
+			return true
+		},
+	)
+	expect(t, n == 11)
+}
+
+func TestCollectionSnapshotCopy(t *testing.T) {
+	c := New()
+	for i := 0; i < 100; i++ {
+		id := strconv.Itoa(i)
+		c.Set(id, PO(float64(i), float64(i)), []string{"a"}, []float64{float64(i)}, 0)
+	}
+	c.CreateFieldIndex("a")
+	c.SetTags("1", []string{"red"})
+	c.SetMeta("1", "one")
+	cp := c.SnapshotCopy()
+
+	// modify the original before the snapshot is built
+	c.Delete("0")
+	c.SetField("2", "a", 1000)
+	c.SetMeta("1", "uno")
+	c.AddTags("2", []string{"red"})
+	snap := cp.Build()
+	expect(t, snap.Count() == 100)
+	_, fields, _, ok := snap.Get("2")
+	expect(t, ok && fields[0] == 2)
+	meta, _ := snap.Meta("1")
+	expect(t, meta == "one")
+	expect(t, snap.TagCount("red") == 1 && c.TagCount("red") == 2)
+	var n int
+	snap.SearchField("a", 1000, 1000,
+		func(id string, obj geojson.Object, fields []float64) bool {
+			n++
+			return true
+		},
+	)
+	expect(t, n == 0)
+
+	// and after, when the trees are no longer shared
+	c.SetTags("3", []string{"red"})
+	c.SetField("4", "a", 1000)
+	expect(t, snap.TagCount("red") == 1)
+	snap.SearchField("a", 1000, 1000,
+		func(id string, obj geojson.Object, fields []float64) bool {
+			n++
+			return true
+		},
+	)
+	expect(t, n == 0)
+}
+
+func TestCollectionExpiredLimit(t *testing.T) {
+	c := New()
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), PO(0, 0), nil, nil, int64(10-i))
+	}
+	c.Set("never", PO(0, 0), nil, nil, 0)
+	ids := c.ExpiredLimit(5, nil, 3)
+	expect(t, reflect.DeepEqual(ids, []string{"9", "8", "7"}))
+	ids = c.ExpiredLimit(5, nil, 0)
+	expect(t, len(ids) == 5)
+	ids = c.Expired(100, ids)
+	expect(t, len(ids) == 10)
+}
+
+func TestCollectionSample(t *testing.T) {
+	c := New()
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), PO(0, 0), nil, nil, 0)
+	}
+	var n int
+	c.Sample(100, func(id string, atime int64) bool {
+		n++
+		return true
+	})
+	expect(t, n == 10)
+	// reading an object makes it the most recently used
+	time.Sleep(time.Millisecond)
+	c.Get("5")
+	var newest string
+	var newestTime int64
+	for i := 0; i < 100; i++ {
+		c.Sample(10, func(id string, atime int64) bool {
+			if atime > newestTime {
+				newest, newestTime = id, atime
+			}
+			return true
+		})
+	}
+	expect(t, newest == "5")
+}
+
+func TestCollectionScanExpires(t *testing.T) {
+	c := New()
+	c.Set("a", PO(0, 0), nil, nil, 30)
+	c.Set("b", PO(0, 0), nil, nil, 10)
+	c.Set("c", PO(0, 0), nil, nil, 0)
+	c.Set("d", PO(0, 0), nil, nil, 20)
+	var ids []string
+	c.ScanExpires(func(id string, expires int64) bool {
+		ids = append(ids, id)
+		return true
+	})
+	expect(t, reflect.DeepEqual(ids, []string{"b", "d", "a"}))
+}
+
+func TestCollectionExpires(t *testing.T) {
+	c := New()
+	c.Set("a", PO(0, 0), nil, nil, 30)
+	c.Set("b", PO(0, 0), nil, nil, 0)
+	ex, ok := c.Expires("a")
+	expect(t, ok && ex == 30)
+	ex, ok = c.Expires("b")
+	expect(t, ok && ex == 0)
+	_, ok = c.Expires("c")
+	expect(t, !ok)
+}
+
+func TestCollectionObjectInfo(t *testing.T) {
+	c := New()
+	c.Set("a", PO(0, 0), []string{"f1"}, []float64{1}, 0)
+	c.Set("b", String("hello"), nil, nil, 0)
+	info, ok := c.ObjectInfo("a")
+	expect(t, ok && info.Encoding == "geojson" && info.Points == 1 &&
+		info.Fields == 1 && info.Weight == 16+8+1)
+	info, ok = c.ObjectInfo("b")
+	expect(t, ok && info.Encoding == "string" && info.Weight == 5+1)
+	_, ok = c.ObjectInfo("c")
+	expect(t, !ok)
+}
+
+func TestCollectionFieldExpires(t *testing.T) {
+	c := New()
+	c.Set("a", PO(0, 0), []string{"f1", "f2"}, []float64{1, 2}, 0)
+	c.Set("b", PO(0, 0), []string{"f1"}, []float64{1}, 0)
+	expect(t, c.SetFieldExpires("a", "f1", 20))
+	expect(t, c.SetFieldExpires("a", "f2", 10))
+	expect(t, c.SetFieldExpires("b", "f1", 30))
+	expect(t, !c.SetFieldExpires("c", "f1", 10))
+	exs := c.FieldExpires("a")
+	expect(t, len(exs) == 2 && exs["f1"] == 20 && exs["f2"] == 10)
+	fields := c.ExpiredFieldsLimit(25, nil, 0)
+	expect(t, len(fields) == 2 &&
+		fields[0] == ExpiredField{"a", "f2"} && fields[1] == ExpiredField{"a", "f1"})
+	expect(t, len(c.ExpiredFieldsLimit(25, nil, 1)) == 1)
+	c.SetFieldExpires("a", "f2", 0)
+	expect(t, len(c.FieldExpires("a")) == 1)
+	snap := c.Snapshot()
+	c.Delete("a")
+	expect(t, c.FieldExpires("a") == nil)
+	expect(t, len(c.ExpiredFieldsLimit(100, nil, 0)) == 1)
+	expect(t, len(snap.ExpiredFieldsLimit(100, nil, 0)) == 2)
+}
+
+func TestCollectionTags(t *testing.T) {
+	c := New()
+	c.Set("a", PO(0, 0), nil, nil, 0)
+	c.Set("b", PO(1, 1), nil, nil, 0)
+	c.Set("c", PO(2, 2), nil, nil, 0)
+	expect(t, c.SetTags("a", []string{"x", "y", "x"}))
+	expect(t, c.SetTags("c", []string{"y"}))
+	expect(t, !c.SetTags("d", []string{"y"}))
+	expect(t, strings.Join(c.Tags("a"), ",") == "x,y")
+	expect(t, c.HasTag("a", "x") && !c.HasTag("b", "x"))
+	n, ok := c.AddTags("b", []string{"y", "z"})
+	expect(t, ok && n == 2)
+	n, ok = c.AddTags("b", []string{"y"})
+	expect(t, ok && n == 0)
+	n, ok = c.RemoveTags("b", []string{"z", "w"})
+	expect(t, ok && n == 1)
+	expect(t, c.TagCount("y") == 3 && c.TagCount("z") == 0)
+	tagged := func(c *Collection, tag string, desc bool) string {
+		var ids []string
+		c.ScanTagged(tag, desc, nil, nil,
+			func(id string, obj geojson.Object, fields []float64) bool {
+				ids = append(ids, id)
+				return true
+			})
+		return strings.Join(ids, ",")
+	}
+	expect(t, tagged(c, "y", false) == "a,b,c")
+	expect(t, tagged(c, "y", true) == "c,b,a")
+
+	// the tags are kept when the object is set again
+	c.Set("a", PO(3, 3), nil, nil, 0)
+	expect(t, tagged(c, "x", false) == "a")
+	expect(t, c.SetTags("a", nil))
+	expect(t, c.Tags("a") == nil && tagged(c, "x", false) == "")
+	snap := c.Snapshot()
+	c.Delete("b")
+	expect(t, tagged(c, "y", false) == "c")
+	expect(t, tagged(snap, "y", false) == "b,c")
+}
+
+func TestCollectionMeta(t *testing.T) {
+	c := New()
+	c.Set("a", PO(0, 0), nil, nil, 0)
+	weight := c.TotalWeight()
+	expect(t, c.SetMeta("a", "hello"))
+	expect(t, !c.SetMeta("b", "hello"))
+	expect(t, c.TotalWeight() == weight+5)
+	meta, ok := c.Meta("a")
+	expect(t, ok && meta == "hello")
+	_, ok = c.Meta("b")
+	expect(t, !ok)
+
+	// the metadata is kept when the object is set again
+	c.Set("a", PO(1, 1), nil, nil, 0)
+	meta, _ = c.Meta("a")
+	expect(t, meta == "hello" && c.TotalWeight() == weight+5)
+	snap := c.Snapshot()
+	expect(t, c.SetMeta("a", ""))
+	meta, _ = c.Meta("a")
+	expect(t, meta == "" && c.TotalWeight() == weight)
+	meta, _ = snap.Meta("a")
+	expect(t, meta == "hello")
+	c.SetMeta("a", "hello")
+	c.Delete("a")
+	expect(t, c.TotalWeight() == 0)
+}
+
+func TestCollectionStringFields(t *testing.T) {
+	c := New()
+	c.Set("a", PO(0, 0), []string{"speed"}, []float64{90}, 0)
+	weight := c.TotalWeight()
+	updated, ok := c.SetStringField("a", "plate", "ABC123")
+	expect(t, ok && updated && c.TotalWeight() == weight+11)
+	updated, ok = c.SetStringField("a", "plate", "ABC123")
+	expect(t, ok && !updated)
+	_, ok = c.SetStringField("b", "plate", "ABC123")
+	expect(t, !ok)
+	c.SetStringField("a", "driver", "Sam")
+	value, ok := c.StringField("a", "plate")
+	expect(t, ok && value == "ABC123")
+	value, ok = c.StringField("a", "color")
+	expect(t, ok && value == "")
+	_, ok = c.StringField("b", "plate")
+	expect(t, !ok)
+	fields, values := c.StringFields("a")
+	expect(t, strings.Join(fields, ",") == "driver,plate" &&
+		strings.Join(values, ",") == "Sam,ABC123")
+
+	// the string fields are kept when the object is set again, and apart
+	// from the float64 fields
+	c.Set("a", PO(1, 1), []string{"speed"}, []float64{80}, 0)
+	value, _ = c.StringField("a", "plate")
+	expect(t, value == "ABC123")
+	_, fvals, _, _ := c.Get("a")
+	expect(t, len(fvals) == 1 && fvals[0] == 80)
+	snap := c.Snapshot()
+	updated, _ = c.SetStringField("a", "plate", "")
+	expect(t, updated)
+	value, _ = c.StringField("a", "plate")
+	expect(t, value == "" && c.TotalWeight() == weight+9)
+	value, _ = snap.StringField("a", "plate")
+	expect(t, value == "ABC123")
+	c.Delete("a")
+	expect(t, c.TotalWeight() == 0)
+	fields, _ = c.StringFields("a")
+	expect(t, fields == nil)
+}
+
+func TestCollectionFieldIndex(t *testing.T) {
+	c := New()
+	c.Set("a", PO(0, 0), []string{"speed"}, []float64{90}, 0)
+	c.Set("b", PO(0, 0), []string{"speed"}, []float64{50}, 0)
+	c.Set("c", PO(0, 0), nil, nil, 0)
+	search := func(c *Collection, min, max float64) string {
+		var ids []string
+		c.SearchField("speed", min, max, func(id string, _ geojson.Object,
+			_ []float64) bool {
+			ids = append(ids, id)
+			return true
+		})
+		return strings.Join(ids, ",")
+	}
+	expect(t, !c.SearchField("speed", 0, 100, nil))
+	expect(t, c.CreateFieldIndex("speed"))
+	expect(t, !c.CreateFieldIndex("speed"))
+	expect(t, c.HasFieldIndex("speed") && !c.HasFieldIndex("age"))
+	// without the field the value is zero
+	expect(t, search(c, 0, 100) == "c,b,a")
+	expect(t, search(c, 80, math.Inf(1)) == "a")
+
+	// the index follows the values
+	c.SetField("b", "speed", 85)
+	c.SetFields("c", []string{"age", "speed"}, []float64{3, 120})
+	c.Set("a", PO(1, 1), []string{"speed"}, []float64{10}, 0)
+	expect(t, search(c, 80, math.Inf(1)) == "b,c")
+	snap := c.Snapshot()
+	c.Set("a", PO(1, 1), nil, []float64{95}, 0)
+	c.Delete("c")
+	expect(t, search(c, 80, math.Inf(1)) == "b,a")
+	expect(t, search(snap, 80, math.Inf(1)) == "b,c")
+
+	// the iterator stops
+	var n int
+	expect(t, !c.SearchField("speed", 0, 100, func(string, geojson.Object,
+		[]float64) bool {
+		n++
+		return false
+	}))
+	expect(t, n == 1)
+	expect(t, c.DropFieldIndex("speed") && !c.DropFieldIndex("speed"))
+	expect(t, search(c, 0, 100) == "")
+}
+
+func TestCollectionLoad(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	var items []ItemInput
+	for i, n := range rand.Perm(5000) {
+		in := ItemInput{
+			ID:     strconv.Itoa(n),
+			Object: PO(rand.Float64()*360-180, rand.Float64()*180-90),
+		}
+		switch i % 4 {
+		case 1:
+			in.Fields, in.Values = []string{"speed"}, []float64{float64(i)}
+		case 2:
+			in.Object = String(strconv.Itoa(i))
+		case 3:
+			in.Ex = int64(i + 1)
+		}
+		items = append(items, in)
+	}
+	c1, c2 := New(), New()
+	for _, in := range items {
+		c1.Set(in.ID, in.Object, in.Fields, in.Values, in.Ex)
+	}
+	c2.Load(items)
+	expect(t, c1.Count() == c2.Count() && c1.StringCount() == c2.StringCount())
+	expect(t, c1.PointCount() == c2.PointCount())
+	expect(t, c1.TotalWeight() == c2.TotalWeight())
+	dump := func(c *Collection) string {
+		var buf []string
+		c.Scan(false, nil, nil, func(id string, obj geojson.Object,
+			fields []float64) bool {
+			ex, _ := c.Expires(id)
+			buf = append(buf, fmt.Sprint(id, obj, fields, ex))
+			return true
+		})
+		return strings.Join(buf, "\n")
+	}
+	expect(t, dump(c1) == dump(c2))
+	within := func(c *Collection) string {
+		var ids []string
+		c.Within(geojson.NewRect(geometry.Rect{
+			Min: geometry.Point{X: -50, Y: -30},
+			Max: geometry.Point{X: 50, Y: 30},
+		}), 0, nil, nil,
+			func(id string, _ geojson.Object, _ []float64) bool {
+				ids = append(ids, id)
+				return true
+			})
+		sort.Strings(ids)
+		return strings.Join(ids, ",")
+	}
+	expect(t, within(c1) == within(c2))
+	expired := func(c *Collection) string {
+		return strings.Join(c.Expired(math.MaxInt64, nil), ",")
+	}
+	expect(t, expired(c1) == expired(c2))
+
+	// a collection that isn't empty
+	c2.Load([]ItemInput{{ID: "0", Object: String("zero")}})
+	obj, _, _, _ := c2.Get("0")
+	expect(t, obj.String() == "zero")
+
+	// the last of the duplicates is kept
+	c3 := New()
+	c3.Load([]ItemInput{
+		{ID: "a", Object: String("1"), Values: []float64{1}},
+		{ID: "b", Object: String("2")},
+		{ID: "a", Object: String("3")},
+	})
+	obj, fields, _, _ := c3.Get("a")
+	expect(t, c3.Count() == 2 && obj.String() == "3" && len(fields) == 0)
+}
+
+func TestCollectionCircle(t *testing.T) {
+	c := New()
+	circle := geojson.NewCircle(geometry.Point{X: 10, Y: 70}, 100000, 64)
+	c.Set("circle", circle, nil, nil, 0)
+	expect(t, c.Count() == 1 && c.StringCount() == 0 && c.PointCount() == 1)
+	ids := func(search func(geojson.Object, uint8, Cursor, *deadline.Deadline,
+		func(string, geojson.Object, []float64) bool) bool, obj geojson.Object,
+	) string {
+		var ids []string
+		search(obj, 0, nil, nil, func(id string, _ geojson.Object,
+			_ []float64) bool {
+			ids = append(ids, id)
+			return true
+		})
+		return strings.Join(ids, ",")
+	}
+	rect := func(minX, minY, maxX, maxY float64) geojson.Object {
+		return geojson.NewRect(geometry.Rect{
+			Min: geometry.Point{X: minX, Y: minY},
+			Max: geometry.Point{X: maxX, Y: maxY},
+		})
+	}
+	// the widest point of the circle is north of its center, and past the
+	// point that's east of the center
+	_, minLon, _, maxLon := geo.RectFromCenter(70, 10, 100000)
+	lat := math.Asin(math.Sin(70*math.Pi/180)/math.Cos(100000/6371e3)) *
+		180 / math.Pi
+	edge := PO(maxLon-0.01, lat)
+	expect(t, geo.DistanceTo(70, 10, lat, maxLon-0.01) < 100000)
+	expect(t, ids(c.Intersects, edge) == "circle")
+	expect(t, ids(c.Intersects, PO(maxLon+0.01, lat)) == "")
+	expect(t, ids(c.Intersects, rect(maxLon-0.01, lat, 20, 80)) == "circle")
+	expect(t, ids(c.Within, rect(minLon-1, 60, maxLon+1, 80)) == "circle")
+	expect(t, ids(c.Within, rect(0, 60, maxLon-0.01, 80)) == "")
+	expect(t, ids(c.Within, geojson.NewCircle(geometry.Point{X: 10, Y: 70},
+		100500, 64)) == "circle")
+	expect(t, ids(c.Within, geojson.NewCircle(geometry.Point{X: 10, Y: 70},
+		99500, 64)) == "")
+	expect(t, ids(c.Intersects, geojson.NewCircle(geometry.Point{X: 10, Y: 72},
+		200000, 64)) == "circle")
+	c.Delete("circle")
+	expect(t, c.Count() == 0 && ids(c.Intersects, edge) == "")
+}
+
+func TestCollectionShared(t *testing.T) {
+	poly := func() geojson.Object {
+		o, err := geojson.Parse(`{"type":"Polygon","coordinates":[
+			[[0,0],[10,0],[10,10],[0,10],[0,0]]]}`, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	}
+	c := New()
+	for i := 0; i < 100; i++ {
+		c.Set(strconv.Itoa(i), poly(), nil, nil, 0)
+	}
+	expect(t, c.SharedCount() == 1)
+	expect(t, c.Count() == 100)
+	expect(t, c.PointCount() == 500)
+	a, _, _, _ := c.Get("1")
+	b, _, _, _ := c.Get("2")
+	expect(t, a == b)
+	// the geometry is weighed once, the ids every time
+	expect(t, c.TotalWeight() == 5*16+190)
+
+	// setting the same shared object again keeps a single reference
+	c.Set("1", a, nil, nil, 0)
+	expect(t, c.SharedCount() == 1)
+
+	// replacing one of them with another geometry
+	c.Set("1", PO(5, 5), nil, nil, 0)
+	expect(t, c.SharedCount() == 1)
+	c.Set("2", geojson.NewRect(geometry.Rect{
+		Min: geometry.Point{X: 1, Y: 1}, Max: geometry.Point{X: 2, Y: 2},
+	}), nil, nil, 0)
+	for i := 0; i < 100; i++ {
+		c.Delete(strconv.Itoa(i))
+	}
+	expect(t, c.SharedCount() == 0)
+	expect(t, c.TotalWeight() == 0)
+}
+
+func TestCollectionPacked(t *testing.T) {
+	coords := func(n int, decimals int) string {
+		var sb strings.Builder
+		sb.WriteByte('[')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			x := -112 + float64(i)*0.000123
+			y := 33 + float64(i%7)*0.000071
+			sb.WriteString(`[` + strconv.FormatFloat(x, 'f', decimals, 64) +
+				`,` + strconv.FormatFloat(y, 'f', decimals, 64) + `]`)
+		}
+		sb.WriteByte(']')
+		return sb.String()
+	}
+	route := func(n int, decimals int) string {
+		return `{"type":"LineString","coordinates":` + coords(n, decimals) + `}`
+	}
+	parse := func(s string) geojson.Object {
+		o, err := geojson.Parse(s, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	}
+	c := New()
+	c.SetCodec(CodecDelta)
+	line := parse(route(200, 6))
+	c.Set("route", line, nil, nil, 0)
+	_, packed := c.items.Get(&itemT{id: "route"}).(*itemT).obj.(*packedGeom)
+	expect(t, packed)
+	expect(t, c.TotalWeight() < 200*16/3)
+	expect(t, c.PointCount() == 200)
+	o, _, _, _ := c.Get("route")
+	_, ok := o.(*geojson.LineString)
+	expect(t, ok)
+	expect(t, o.String() == line.String())
+	var found bool
+	c.Intersects(PO(-112, 33), 0, nil, nil,
+		func(id string, obj geojson.Object, fields []float64) bool {
+			_, ok := obj.(*geojson.LineString)
+			found = id == "route" && ok
+			return true
+		},
+	)
+	expect(t, found)
+
+	// polygons with holes
+	ring := coords(100, 5)
+	ring = ring[:len(ring)-1] + `,[-112,33]]`
+	poly := parse(`{"type":"Polygon","coordinates":[` + ring + `,` +
+		`[[-111.99,33.0001],[-111.98,33.0001],[-111.98,33.0002],[-111.99,33.0001]]]}`)
+	c.Set("poly", poly, nil, nil, 0)
+	_, packed = c.items.Get(&itemT{id: "poly"}).(*itemT).obj.(*packedGeom)
+	expect(t, packed)
+	o, _, _, _ = c.Get("poly")
+	expect(t, o.String() == poly.String())
+
+	// too many decimals to pack without loss
+	precise := strings.Replace(route(100, 6), "-112.000000", "-112.000000001", 1)
+	c.Set("precise", parse(precise), nil, nil, 0)
+	_, packed = c.items.Get(&itemT{id: "precise"}).(*itemT).obj.(*packedGeom)
+	expect(t, !packed)
+
+	// too small
+	c.Set("small", parse(route(10, 6)), nil, nil, 0)
+	_, packed = c.items.Get(&itemT{id: "small"}).(*itemT).obj.(*packedGeom)
+	expect(t, !packed)
+
+	// changing the codec repacks the geometries that are there
+	weight := c.TotalWeight()
+	c.SetCodec(CodecNone)
+	for _, id := range []string{"route", "poly", "precise", "small"} {
+		_, packed = c.items.Get(&itemT{id: id}).(*itemT).obj.(*packedGeom)
+		expect(t, !packed)
+	}
+	expect(t, c.TotalWeight() > weight)
+	o, _, _, _ = c.Get("poly")
+	expect(t, o.String() == poly.String())
+	c.SetCodec(CodecDelta)
+	_, packed = c.items.Get(&itemT{id: "route"}).(*itemT).obj.(*packedGeom)
+	expect(t, packed && c.TotalWeight() == weight)
+	o, _, _, _ = c.Get("route")
+	expect(t, o.String() == line.String())
+
+	for _, id := range []string{"route", "poly", "precise", "small"} {
+		c.Delete(id)
+	}
+	expect(t, c.TotalWeight() == 0)
+	expect(t, c.PointCount() == 0)
+}
+
+func TestCollectionCanceled(t *testing.T) {
+	c := New()
+	for i := 0; i < 1000; i++ {
+		c.Set(strconv.Itoa(i), PO(float64(i%360)-180, 0), nil, nil, 0)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	dl := deadline.WithContext(ctx)
+	var count int
+	func() {
+		defer func() {
+			expect(t, recover() == "deadline")
+		}()
+		c.Scan(false, nil, dl, func(id string, obj geojson.Object, fields []float64) bool {
+			count++
+			if count == 100 {
+				cancel()
+			}
+			return true
+		})
+	}()
+	expect(t, dl.Hit() && dl.Canceled())
+	expect(t, count >= 100 && count < 100+yieldCheck)
+}
+
+func TestCollectionDeadline(t *testing.T) {
+	c := New()
+	for i := 0; i < 1000; i++ {
+		c.Set(strconv.Itoa(i), PO(float64(i%360)-180, 0), nil, nil, 0)
+	}
+	dl := deadline.New(time.Now().Add(time.Millisecond * 10))
+	var count int
+	func() {
+		defer func() {
+			expect(t, recover() == "deadline")
+		}()
+		c.Within(geojson.NewRect(geometry.Rect{
+			Min: geometry.Point{X: -180, Y: -1},
+			Max: geometry.Point{X: 180, Y: 1},
+		}), 0, nil, dl, func(id string, obj geojson.Object, fields []float64) bool {
+			count++
+			if count == 1 {
+				time.Sleep(time.Millisecond * 20)
+			}
+			return true
+		})
+	}()
+	expect(t, dl.Hit() && !dl.Canceled())
+	// the deadline is checked every yieldCheck steps
+	expect(t, count == yieldCheck-1)
+}
+
+func TestCollectionWarmup(t *testing.T) {
+	c := New()
+	objects, points := c.Warmup()
+	expect(t, objects == 0 && points == 0)
+	for i := 0; i < 1000; i++ {
+		c.Set(strconv.Itoa(i), PO(float64(i%360)-180, 0), nil, nil, 0)
+	}
+	c.Set("str", String("hello"), nil, nil, 0)
+	c.CreateFieldIndex("speed")
+	objects, points = c.Warmup()
+	expect(t, objects == c.Count())
+	expect(t, points == c.PointCount())
+}
+
+func TestCollectionNearbyWithin(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	c := New()
+	for i := 0; i < 10000; i++ {
+		c.Set(strconv.Itoa(i), PO(rand.Float64()*2-1, rand.Float64()*2-1),
+			nil, nil, 0)
+	}
+	c.Set("str", String("hello"), nil, nil, 0)
+	target := PO(0, 0)
+	const maxMeters = 20000
+	expected := make(map[string]float64)
+	c.Nearby(target, nil, nil, func(id string, obj geojson.Object,
+		fields []float64, dist float64) bool {
+		if dist > maxMeters {
+			return false
+		}
+		expected[id] = dist
+		return true
+	})
+	expect(t, len(expected) > 0 && len(expected) < 10000)
+	var last float64
+	var count int
+	c.NearbyWithin(target, maxMeters, nil, nil, func(id string,
+		obj geojson.Object, fields []float64, dist float64) bool {
+		expect(t, dist >= last && dist == expected[id])
+		last = dist
+		count++
+		return true
+	})
+	expect(t, count == len(expected))
+
+	// stopped by the iterator
+	count = 0
+	expect(t, !c.NearbyWithin(target, maxMeters, nil, nil, func(id string,
+		obj geojson.Object, fields []float64, dist float64) bool {
+		count++
+		return count < 10
+	}))
+	expect(t, count == 10)
+
+	// nothing in range
+	expect(t, c.NearbyWithin(PO(100, 50), maxMeters, nil, nil,
+		func(id string, obj geojson.Object, fields []float64, dist float64) bool {
+			t.Fatal("unexpected object")
+			return true
+		}))
+}
+
+func BenchmarkNearby(t *testing.B) {
+	benchmarkNearby(t, false)
+}
+
+func BenchmarkNearbyWithin(t *testing.B) {
+	benchmarkNearby(t, true)
+}
+
+func benchmarkNearby(t *testing.B, within bool) {
+	rand.Seed(time.Now().UnixNano())
+	col := New()
+	for i := 0; i < 100000; i++ {
+		col.Set(strconv.Itoa(i), PO(rand.Float64()*2-1, rand.Float64()*2-1),
+			nil, nil, 0)
+	}
+	const maxMeters = 1000
+	t.ResetTimer()
+	for i := 0; i < t.N; i++ {
+		target := PO(rand.Float64()*2-1, rand.Float64()*2-1)
+		iter := func(id string, obj geojson.Object, fields []float64,
+			dist float64) bool {
+			return dist <= maxMeters
+		}
+		if within {
+			col.NearbyWithin(target, maxMeters, nil, nil, iter)
+		} else {
+			col.Nearby(target, nil, nil, iter)
+		}
+	}
+}
+
+func TestCollectionCountRect(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	c := New()
+	world := geometry.Rect{
+		Min: geometry.Point{X: -180, Y: -90},
+		Max: geometry.Point{X: 180, Y: 90},
+	}
+	expect(t, c.CountRect(world) == 0)
+	for i := 0; i < 10000; i++ {
+		c.Set(strconv.Itoa(i), PO(rand.Float64()*360-180, rand.Float64()*180-90),
+			nil, nil, 0)
+	}
+	c.Set("str", String("hello"), nil, nil, 0)
+	expect(t, c.CountRect(world) == 10000)
+	count := func(rect geometry.Rect) int {
+		var n int
+		c.Intersects(geojson.NewRect(rect), 0, nil, nil,
+			func(string, geojson.Object, []float64) bool {
+				n++
+				return true
+			},
+		)
+		return n
+	}
+	for i := 0; i < 100; i++ {
+		x, y := rand.Float64()*360-180, rand.Float64()*180-90
+		rect := geometry.Rect{
+			Min: geometry.Point{X: x, Y: y},
+			Max: geometry.Point{X: x + rand.Float64()*90, Y: y + rand.Float64()*45},
+		}
+		expect(t, c.CountRect(rect) == count(rect))
+		if i%10 == 0 {
+			// the counts of the nodes are dropped by the changes
+			c.Delete(strconv.Itoa(i))
+			c.Set(strconv.Itoa(10000+i), PO(x, y), nil, nil, 0)
+			expect(t, c.CountRect(rect) == count(rect))
+		}
+	}
+}
+
+func TestCollectionCountRectWrites(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	c := New()
+	rects := make([]geometry.Rect, 10)
+	for i := range rects {
+		x, y := rand.Float64()*360-180, rand.Float64()*180-90
+		rects[i] = geometry.Rect{
+			Min: geometry.Point{X: x, Y: y},
+			Max: geometry.Point{X: x + rand.Float64()*90, Y: y + rand.Float64()*45},
+		}
+	}
+	count := func(rect geometry.Rect) int {
+		var n int
+		c.Intersects(geojson.NewRect(rect), 0, nil, nil,
+			func(string, geojson.Object, []float64) bool {
+				n++
+				return true
+			},
+		)
+		return n
+	}
+	// the same rectangles are counted between inserts, moves, and deletes
+	for i := 0; i < 5000; i++ {
+		id := strconv.Itoa(rand.Intn(2000))
+		if rand.Intn(4) == 0 {
+			c.Delete(id)
+		} else {
+			c.Set(id, PO(rand.Float64()*360-180, rand.Float64()*180-90),
+				nil, nil, 0)
+		}
+		if i%50 == 0 {
+			// the second count of a rectangle uses the kept numbers
+			for _, rect := range rects {
+				n := count(rect)
+				expect(t, c.CountRect(rect) == n && c.CountRect(rect) == n)
+			}
+		}
+	}
+}
+
+func TestCollectionDeleteWithin(t *testing.T) {
+	c := New()
+	for i := 0; i < 100; i++ {
+		c.Set(strconv.Itoa(i), PO(float64(i), 0), nil, nil, 0)
+	}
+	c.Set("line", geojson.NewLineString(geometry.NewLine(
+		[]geometry.Point{{X: 5.5, Y: -1}, {X: 60.5, Y: 1}}, nil)), nil, nil, 0)
+	c.Set("str", String("hello"), nil, nil, 0)
+	area := geojson.NewRect(geometry.Rect{
+		Min: geometry.Point{X: 10, Y: -1},
+		Max: geometry.Point{X: 19, Y: 1},
+	})
+	ids := c.DeleteWithin(area)
+	sort.Strings(ids)
+	expect(t, len(ids) == 10 && ids[0] == "10" && ids[9] == "19")
+	expect(t, c.Count() == 92)
+	_, _, _, ok := c.Get("15")
+	expect(t, !ok)
+	expect(t, len(c.DeleteWithin(area)) == 0)
+
+	// the line intersects and isn't within
+	ids = c.DeleteIntersects(geojson.NewRect(geometry.Rect{
+		Min: geometry.Point{X: 20, Y: -1},
+		Max: geometry.Point{X: 29, Y: 1},
+	}))
+	sort.Strings(ids)
+	expect(t, len(ids) == 11 && ids[0] == "20" && ids[10] == "line")
+	expect(t, c.Count() == 81)
+	var n int
+	c.Intersects(area, 0, nil, nil, func(string, geojson.Object, []float64) bool {
+		n++
+		return true
+	})
+	expect(t, n == 0)
+}
+
+func TestCollectionBatch(t *testing.T) {
+	a, b := New(), New()
+	a.Set("truck1", PO(1, 1), nil, nil, 0)
+	a.Set("truck2", PO(2, 2), nil, nil, 0)
+
+	// an error applies none of the changes
+	errStop := errors.New("stop")
+	err := a.Batch(func(tx *Tx) error {
+		expect(t, tx.Set("truck3", PO(3, 3), nil, nil, 0) == nil)
+		expect(t, tx.Delete("truck1") == nil)
+		expect(t, tx.Delete("truck1") == ErrNotFound)
+		expect(t, tx.SetField("truck3", "speed", 10) == nil)
+		return errStop
+	})
+	expect(t, err == errStop)
+	expect(t, a.Count() == 2)
+	_, _, _, ok := a.Get("truck3")
+	expect(t, !ok)
+
+	// move an object between two collections
+	var saved *Tx
+	err = a.Batch(func(tx *Tx) error {
+		obj, ok := tx.Get("truck1")
+		expect(t, ok)
+		expect(t, tx.Delete("truck1") == nil)
+		other := tx.With(b)
+		expect(t, other.Set("truck1", obj, []string{"speed"}, []float64{5}, 0) == nil)
+		expect(t, other.SetField("truck1", "speed", 10) == nil)
+		expect(t, other.SetField("truck9", "speed", 10) == ErrNotFound)
+		saved = tx
+		return nil
+	})
+	expect(t, err == nil)
+	expect(t, a.Count() == 1 && b.Count() == 1)
+	obj, fields, _, ok := b.Get("truck1")
+	expect(t, ok && obj.Center() == geometry.Point{X: 1, Y: 1})
+	expect(t, len(fields) == 1 && fields[0] == 10)
+	expect(t, saved.Delete("truck2") == ErrTxClosed)
+	expect(t, a.Count() == 1)
+}
+
+func TestCollectionIterator(t *testing.T) {
+	a, b := New(), New()
+	for i := 0; i < 100; i++ {
+		c := a
+		if i%3 == 0 {
+			c = b
+		}
+		c.Set(fmt.Sprintf("%03d", i), PO(float64(i), 0), nil, nil, 0)
+	}
+
+	// merge the ids of two collections
+	ia, ib := a.ScanIterator(false), b.ScanIterator(false)
+	ida, _, _, oka := ia.Next()
+	idb, _, _, okb := ib.Next()
+	var ids []string
+	for oka || okb {
+		if oka && (!okb || ida < idb) {
+			ids = append(ids, ida)
+			ida, _, _, oka = ia.Next()
+		} else {
+			ids = append(ids, idb)
+			idb, _, _, okb = ib.Next()
+		}
+	}
+	expect(t, len(ids) == 100 && sort.StringsAreSorted(ids))
+	ia.Close()
+	ib.Close()
+
+	// stop early
+	it := a.WithinIterator(geojson.NewRect(geometry.Rect{
+		Min: geometry.Point{X: 10, Y: -1},
+		Max: geometry.Point{X: 20, Y: 1},
+	}))
+	var n int
+	for _, obj, _, ok := it.Next(); ok; _, obj, _, ok = it.Next() {
+		expect(t, obj.Center().X >= 10 && obj.Center().X <= 20)
+		if n++; n == 3 {
+			break
+		}
+	}
+	it.Close()
+	it.Close()
+	_, _, _, ok := it.Next()
+	expect(t, !ok && n == 3)
+
+	it = b.NearbyIterator(PO(50, 0))
+	id, _, _, ok := it.Next()
+	expect(t, ok && id == "051" && it.Dist() > 0)
+	last := it.Dist()
+	for n = 1; ; n++ {
+		if _, _, _, ok = it.Next(); !ok {
+			break
+		}
+		expect(t, it.Dist() >= last)
+		last = it.Dist()
+	}
+	expect(t, n == b.Count())
+	it.Close()
+
+	it = a.IntersectsIterator(PO(1, 0))
+	id, _, _, ok = it.Next()
+	expect(t, ok && id == "001")
+	_, _, _, ok = it.Next()
+	expect(t, !ok)
+}
+
+func TestCollectionZIndex(t *testing.T) {
+	c := New()
+	set := func(id, json string) {
+		obj, err := geojson.Parse(json, nil)
+		expect(t, err == nil)
+		c.Set(id, obj, nil, nil, 0)
+	}
+	for i := 0; i < 100; i++ {
+		set(fmt.Sprintf("p%03d", i),
+			fmt.Sprintf(`{"type":"Point","coordinates":[%d,0,%d]}`, i%10, i))
+	}
+	set("flat", `{"type":"Point","coordinates":[5,0]}`)
+	set("path", `{"type":"LineString","coordinates":[[1,0,10],[2,0,50]]}`)
+	band := func(minZ, maxZ float64) []string {
+		var ids []string
+		c.IntersectsZ(geojson.NewRect(geometry.Rect{
+			Min: geometry.Point{X: 0, Y: -1},
+			Max: geometry.Point{X: 10, Y: 1},
+		}), minZ, maxZ, 0, nil, nil,
+			func(id string, obj geojson.Object, fields []float64) bool {
+				ids = append(ids, id)
+				return true
+			},
+		)
+		sort.Strings(ids)
+		return ids
+	}
+	expect(t, strings.Join(band(20, 22.5), ",") == "p020,p021,p022")
+	// the path has positions at 10 and 50, and none between them
+	expect(t, strings.Join(band(9, 11), ",") == "p009,p010,p011,path")
+	expect(t, strings.Join(band(15, 25), ",") ==
+		"p015,p016,p017,p018,p019,p020,p021,p022,p023,p024,p025")
+	expect(t, strings.Join(band(-1, 0), ",") == "flat,p000")
+
+	c.SetZIndex(true)
+	expect(t, c.ZIndexed() && c.zindex.Count() == 102)
+	expect(t, strings.Join(band(9, 11), ",") == "p009,p010,p011,path")
+	expect(t, strings.Join(band(-1, 0), ",") == "flat,p000")
+
+	// moved and removed objects leave the index
+	set("path", `{"type":"LineString","coordinates":[[1,0,30],[2,0,31]]}`)
+	c.Delete("p010")
+	expect(t, c.zindex.Count() == 101 && len(c.zvalues) == 1)
+	expect(t, strings.Join(band(9, 11), ",") == "p009,p011")
+	expect(t, strings.Join(band(30, 30), ",") == "p030,path")
+
+	var n int
+	c.WithinZ(geojson.NewRect(geometry.Rect{
+		Min: geometry.Point{X: 0, Y: -1},
+		Max: geometry.Point{X: 3, Y: 1},
+	}), 0, 100, 0, nil, nil,
+		func(id string, obj geojson.Object, fields []float64) bool {
+			n++
+			return true
+		},
+	)
+	expect(t, n == 40)
+
+	c.SetZIndex(false)
+	expect(t, !c.ZIndexed())
+	expect(t, strings.Join(band(30, 30), ",") == "p030,path")
+
+	// the z coordinates of a geometry are read once
+	obj, _, _, _ := c.Get("path")
+	zs := c.ZValues("path", obj)
+	expect(t, len(zs) == 2 && zs[0] == 30 && zs[1] == 31)
+	expect(t, &c.ZValues("path", obj)[0] == &zs[0])
+	other, _ := geojson.Parse(`{"type":"LineString","coordinates":[[1,0,7],[2,0,8]]}`, nil)
+	expect(t, c.ZValues("path", other)[0] == 7)
+	c.Delete("path")
+	expect(t, len(c.zvalues) == 0)
+}
@@ -0,0 +1,116 @@
+package collection
+
+import (
+	"sync"
+
+	"github.com/tidwall/geoindex/child"
+	"github.com/tidwall/geojson/geometry"
+)
+
+// Counts of a rectangle
+//
+// The rtree doesn't keep the number of objects under its nodes, so counting
+// the objects in a rectangle means visiting every one of them. CountRect
+// counts the nodes that are inside of the rectangle as a whole, by their
+// number of objects, and only opens the nodes on the edges of the
+// rectangle. The number of objects of a node is counted once and kept until
+// the collection changes, which makes the counts of a dashboard that asks
+// for the same areas again cheap. A rectangle that covers the whole
+// collection is answered without visiting any node.
+//
+// The numbers are kept by version, so any write drops all of them, and a
+// collection that's written to between counts gains nothing from them.
+// Keeping them up to date on inserts and deletes would need the rtree to
+// report the nodes that it splits, joins, and reinserts, which it doesn't.
+
+// nodeCounts are the numbers of objects under the nodes of the rtree, for a
+// version of the collection.
+type nodeCounts struct {
+	mu      sync.Mutex
+	version uint64
+	counts  map[interface{}]int
+}
+
+// get returns the number of objects under a node.
+func (nc *nodeCounts) get(version uint64, node interface{}) (int, bool) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	if nc.version != version {
+		return 0, false
+	}
+	n, ok := nc.counts[node]
+	return n, ok
+}
+
+// set keeps the number of objects under a node, and drops the numbers of
+// the other versions.
+func (nc *nodeCounts) set(version uint64, node interface{}, n int) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	if nc.version != version || nc.counts == nil {
+		nc.version = version
+		nc.counts = make(map[interface{}]int)
+	}
+	nc.counts[node] = n
+}
+
+// CountRect returns the number of objects whose bounding boxes intersect a
+// rectangle. The count is exact for the points, and for the rectangles. The
+// other geometries are counted when their bounding boxes intersect, even if
+// they don't.
+func (c *Collection) CountRect(rect geometry.Rect) int {
+	if c.objects == 0 {
+		return 0
+	}
+	minX, minY, maxX, maxY := c.Bounds()
+	if rectContains(rect, [2]float64{minX, minY}, [2]float64{maxX, maxY}) {
+		return c.objects
+	}
+	return c.countRect(rect, nil)
+}
+
+// countRect counts the objects under a node, nil for the root, whose
+// bounding boxes intersect a rectangle.
+func (c *Collection) countRect(rect geometry.Rect, parent interface{}) int {
+	var n int
+	for _, ch := range c.index.Children(parent, nil) {
+		if !rectIntersects(rect, ch.Min, ch.Max) {
+			continue
+		}
+		if ch.Item {
+			n++
+		} else if rectContains(rect, ch.Min, ch.Max) {
+			n += c.nodeCount(ch)
+		} else {
+			n += c.countRect(rect, ch.Data)
+		}
+	}
+	return n
+}
+
+// nodeCount returns the number of objects under a node.
+func (c *Collection) nodeCount(node child.Child) int {
+	if n, ok := c.counts.get(c.version, node.Data); ok {
+		return n
+	}
+	var n int
+	for _, ch := range c.index.Children(node.Data, nil) {
+		if ch.Item {
+			n++
+		} else {
+			n += c.nodeCount(ch)
+		}
+	}
+	c.counts.set(c.version, node.Data, n)
+	return n
+}
+
+func rectContains(rect geometry.Rect, min, max [2]float64) bool {
+	return min[0] >= rect.Min.X && min[1] >= rect.Min.Y &&
+		max[0] <= rect.Max.X && max[1] <= rect.Max.Y
+}
+
+func rectIntersects(rect geometry.Rect, min, max [2]float64) bool {
+	return min[0] <= rect.Max.X && min[1] <= rect.Max.Y &&
+		max[0] >= rect.Min.X && max[1] >= rect.Min.Y
+}
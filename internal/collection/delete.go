@@ -0,0 +1,43 @@
+package collection
+
+import "github.com/tidwall/geojson"
+
+// Deletes of a search
+//
+// DeleteWithin and DeleteIntersects remove the objects that a Within or an
+// Intersects finds, with a single search of the rtree, rather than a search
+// and then a Delete of each id by the caller. The objects are found first
+// and deleted after the search, as the rtree can't change while it's
+// searched.
+
+// DeleteWithin removes the objects that are within an object, and returns
+// their ids.
+func (c *Collection) DeleteWithin(obj geojson.Object) (ids []string) {
+	c.Within(obj, 0, nil, nil,
+		func(id string, _ geojson.Object, _ []float64) bool {
+			ids = append(ids, id)
+			return true
+		},
+	)
+	return c.deleteIDs(ids)
+}
+
+// DeleteIntersects removes the objects that intersect an object, and
+// returns their ids.
+func (c *Collection) DeleteIntersects(obj geojson.Object) (ids []string) {
+	c.Intersects(obj, 0, nil, nil,
+		func(id string, _ geojson.Object, _ []float64) bool {
+			ids = append(ids, id)
+			return true
+		},
+	)
+	return c.deleteIDs(ids)
+}
+
+// deleteIDs removes the objects of the ids and returns the ids.
+func (c *Collection) deleteIDs(ids []string) []string {
+	for _, id := range ids {
+		c.Delete(id)
+	}
+	return ids
+}
@@ -0,0 +1,135 @@
+package collection
+
+import "github.com/tidwall/btree"
+
+// fieldExpT is the expiration of one field of an object.
+type fieldExpT struct {
+	expires int64 // unix nano expiration
+	id      string
+	field   string
+}
+
+func byFieldExpires(a, b interface{}) bool {
+	item1 := a.(*fieldExpT)
+	item2 := b.(*fieldExpT)
+	if item1.expires < item2.expires {
+		return true
+	}
+	if item1.expires > item2.expires {
+		return false
+	}
+	if item1.id < item2.id {
+		return true
+	}
+	if item1.id > item2.id {
+		return false
+	}
+	return item1.field < item2.field
+}
+
+// fieldExpires are the field expirations of a collection. The tree is sorted
+// by ex+id+field, and the map has the expirations by id and field name.
+type fieldExpires struct {
+	tree *btree.BTree
+	ids  map[string]map[string]int64
+}
+
+// ExpiredField is a field of an object that has expired.
+type ExpiredField struct {
+	ID    string
+	Field string
+}
+
+// SetFieldExpires sets the expiration of a field of an object, or removes
+// it when ex is zero.
+// If the object does not exist then the return value will be false.
+func (c *Collection) SetFieldExpires(id, field string, ex int64) bool {
+	if c.items.Get(&itemT{id: id}) == nil {
+		return false
+	}
+	c.version++
+	fe := &c.fieldExpires
+	if old, ok := fe.ids[id][field]; ok {
+		fe.tree.Delete(&fieldExpT{expires: old, id: id, field: field})
+		delete(fe.ids[id], field)
+		if len(fe.ids[id]) == 0 {
+			delete(fe.ids, id)
+		}
+	}
+	if ex == 0 {
+		return true
+	}
+	if fe.tree == nil {
+		fe.tree = btree.NewNonConcurrent(byFieldExpires)
+		fe.ids = make(map[string]map[string]int64)
+	}
+	fields := fe.ids[id]
+	if fields == nil {
+		fields = make(map[string]int64)
+		fe.ids[id] = fields
+	}
+	fields[field] = ex
+	fe.tree.Set(&fieldExpT{expires: ex, id: id, field: field})
+	return true
+}
+
+// FieldExpires returns the expirations of the fields of an object, by field
+// name, or nil when none of the fields expire.
+func (c *Collection) FieldExpires(id string) map[string]int64 {
+	fields := c.fieldExpires.ids[id]
+	if len(fields) == 0 {
+		return nil
+	}
+	exs := make(map[string]int64, len(fields))
+	for field, ex := range fields {
+		exs[field] = ex
+	}
+	return exs
+}
+
+// ExpiredFieldsLimit returns a list of fields that have expired, oldest
+// first. No more than limit fields are returned, unless the limit is zero.
+func (c *Collection) ExpiredFieldsLimit(now int64, buffer []ExpiredField, limit int,
+) (fields []ExpiredField) {
+	fields = buffer[:0]
+	if c.fieldExpires.tree == nil {
+		return fields
+	}
+	c.fieldExpires.tree.Ascend(nil, func(v interface{}) bool {
+		item := v.(*fieldExpT)
+		if now < item.expires {
+			return false
+		}
+		fields = append(fields, ExpiredField{ID: item.id, Field: item.field})
+		return limit == 0 || len(fields) < limit
+	})
+	return fields
+}
+
+// deleteFieldExpires removes the field expirations of a deleted object.
+func (c *Collection) deleteFieldExpires(id string) {
+	fe := &c.fieldExpires
+	for field, ex := range fe.ids[id] {
+		fe.tree.Delete(&fieldExpT{expires: ex, id: id, field: field})
+	}
+	delete(fe.ids, id)
+}
+
+// clone returns a copy of the field expirations. The tree is shared with the
+// copy until either one changes.
+func (fe *fieldExpires) clone() fieldExpires {
+	var cp fieldExpires
+	if fe.tree == nil {
+		return cp
+	}
+	cp.tree = fe.tree.Copy()
+	cp.ids = make(map[string]map[string]int64, len(fe.ids))
+	for id, fields := range fe.ids {
+		cfields := make(map[string]int64, len(fields))
+		for field, ex := range fields {
+			cfields[field] = ex
+		}
+		cp.ids[id] = cfields
+	}
+	return cp
+}
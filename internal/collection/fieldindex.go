@@ -0,0 +1,136 @@
+package collection
+
+import (
+	"github.com/tidwall/btree"
+	"github.com/tidwall/geojson"
+)
+
+// fieldIdxT is the value of an indexed field of an object.
+type fieldIdxT struct {
+	value float64
+	id    string
+}
+
+func byFieldValue(a, b interface{}) bool {
+	item1 := a.(*fieldIdxT)
+	item2 := b.(*fieldIdxT)
+	if item1.value < item2.value {
+		return true
+	}
+	if item1.value > item2.value {
+		return false
+	}
+	// the values match so we'll compare IDs, which are always unique.
+	return item1.id < item2.id
+}
+
+// fieldIndexes are the secondary indexes of the fields of a collection, by
+// field name. Each tree has every object sorted by value+id, and an object
+// that doesn't have the field is indexed with zero, which is its value.
+type fieldIndexes map[string]*btree.BTree
+
+// CreateFieldIndex indexes the values of a field, and returns false when the
+// field is already indexed.
+func (c *Collection) CreateFieldIndex(name string) bool {
+	if c.fieldIndexes[name] != nil {
+		return false
+	}
+	if c.fieldIndexes == nil {
+		c.fieldIndexes = make(fieldIndexes)
+	}
+	tr := btree.NewNonConcurrent(byFieldValue)
+	c.items.Ascend(nil, func(v interface{}) bool {
+		item := v.(*itemT)
+		tr.Set(&fieldIdxT{value: c.fieldValue(item, name), id: item.id})
+		return true
+	})
+	c.fieldIndexes[name] = tr
+	return true
+}
+
+// DropFieldIndex removes the index of a field, and returns false when the
+// field isn't indexed.
+func (c *Collection) DropFieldIndex(name string) bool {
+	if c.fieldIndexes[name] == nil {
+		return false
+	}
+	delete(c.fieldIndexes, name)
+	return true
+}
+
+// HasFieldIndex returns true when a field is indexed.
+func (c *Collection) HasFieldIndex(name string) bool {
+	return c.fieldIndexes[name] != nil
+}
+
+// SearchField iterates though the objects that have a value of an indexed
+// field from min to max, inclusive, in the order of the values. It returns
+// false when the iterator stops, or when the field isn't indexed.
+func (c *Collection) SearchField(
+	name string,
+	min, max float64,
+	iterator func(id string, obj geojson.Object, fields []float64) bool,
+) bool {
+	tr := c.fieldIndexes[name]
+	if tr == nil {
+		return false
+	}
+	var keepon = true
+	tr.Ascend(&fieldIdxT{value: min}, func(v interface{}) bool {
+		fitem := v.(*fieldIdxT)
+		if fitem.value > max {
+			return false
+		}
+		itemV := c.items.Get(&itemT{id: fitem.id})
+		if itemV == nil {
+			return true
+		}
+		item := itemV.(*itemT)
+		keepon = iterator(item.id, unpack(item.obj),
+			c.fieldValues.get(item.fieldValuesSlot))
+		return keepon
+	})
+	return keepon
+}
+
+// fieldValue returns the value of a field of an object, which is zero when
+// the object doesn't have it.
+func (c *Collection) fieldValue(item *itemT, name string) float64 {
+	idx, ok := c.fieldMap[name]
+	if !ok {
+		return 0
+	}
+	values := c.fieldValues.get(item.fieldValuesSlot)
+	if idx >= len(values) {
+		return 0
+	}
+	return values[idx]
+}
+
+// fieldIndexDelete removes an object from the field indexes. It must be
+// called before the values of the object change.
+func (c *Collection) fieldIndexDelete(item *itemT) {
+	for name, tr := range c.fieldIndexes {
+		tr.Delete(&fieldIdxT{value: c.fieldValue(item, name), id: item.id})
+	}
+}
+
+// fieldIndexInsert adds an object to the field indexes, with its values.
+func (c *Collection) fieldIndexInsert(item *itemT) {
+	for name, tr := range c.fieldIndexes {
+		tr.Set(&fieldIdxT{value: c.fieldValue(item, name), id: item.id})
+	}
+}
+
+// clone returns a copy of the field indexes. The trees are shared with the
+// copy until either one changes.
+func (fi fieldIndexes) clone() fieldIndexes {
+	if fi == nil {
+		return nil
+	}
+	cp := make(fieldIndexes, len(fi))
+	for name, tr := range fi {
+		cp[name] = tr.Copy()
+	}
+	return cp
+}
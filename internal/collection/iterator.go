@@ -0,0 +1,134 @@
+package collection
+
+import "github.com/tidwall/geojson"
+
+// Iterators
+//
+// The searches of a collection push their objects to a callback, which
+// can't be paused, and so the objects of several searches can't be merged
+// without keeping all of them. An Iterator pulls the objects of a search
+// one at a time instead. The search runs in its own goroutine, which waits
+// for each Next, and so only one of the iterator and its caller runs at a
+// time. The collection must not change until the Iterator is done or
+// closed, which is the lock of the caller, and an Iterator that isn't done
+// must be closed, or its goroutine is never released.
+
+// iterItem is an object of an Iterator.
+type iterItem struct {
+	id     string
+	obj    geojson.Object
+	fields []float64
+	dist   float64
+}
+
+// Iterator pulls the objects of a search.
+type Iterator struct {
+	next  chan struct{} // asks the search for the next object
+	items chan iterItem // closed when the search is done
+	dist  float64
+	done  bool
+}
+
+// newIterator returns an Iterator of a search, which starts with the first
+// Next.
+func newIterator(
+	search func(yield func(id string, obj geojson.Object, fields []float64,
+		dist float64) bool),
+) *Iterator {
+	it := &Iterator{next: make(chan struct{}), items: make(chan iterItem)}
+	go func() {
+		defer close(it.items)
+		if _, ok := <-it.next; !ok {
+			return
+		}
+		search(func(id string, obj geojson.Object, fields []float64,
+			dist float64,
+		) bool {
+			it.items <- iterItem{id: id, obj: obj, fields: fields, dist: dist}
+			_, ok := <-it.next
+			return ok
+		})
+	}()
+	return it
+}
+
+// Next returns the next object, or false when there are no more objects.
+func (it *Iterator) Next() (id string, obj geojson.Object, fields []float64,
+	ok bool,
+) {
+	if it.done {
+		return "", nil, nil, false
+	}
+	it.next <- struct{}{}
+	item, ok := <-it.items
+	if !ok {
+		it.done = true
+		return "", nil, nil, false
+	}
+	it.dist = item.dist
+	return item.id, item.obj, item.fields, true
+}
+
+// Dist returns the distance of the last object of a NearbyIterator, in
+// meters.
+func (it *Iterator) Dist() float64 {
+	return it.dist
+}
+
+// Close stops the search, and returns when its goroutine is done.
+func (it *Iterator) Close() {
+	if it.done {
+		return
+	}
+	it.done = true
+	close(it.next)
+	for range it.items {
+	}
+}
+
+// ScanIterator returns an Iterator of the objects by id, like Scan.
+func (c *Collection) ScanIterator(desc bool) *Iterator {
+	return newIterator(func(yield func(string, geojson.Object, []float64,
+		float64) bool) {
+		c.Scan(desc, nil, nil,
+			func(id string, obj geojson.Object, fields []float64) bool {
+				return yield(id, obj, fields, 0)
+			},
+		)
+	})
+}
+
+// WithinIterator returns an Iterator of the objects that are within an
+// object, like Within.
+func (c *Collection) WithinIterator(obj geojson.Object) *Iterator {
+	return newIterator(func(yield func(string, geojson.Object, []float64,
+		float64) bool) {
+		c.Within(obj, 0, nil, nil,
+			func(id string, obj geojson.Object, fields []float64) bool {
+				return yield(id, obj, fields, 0)
+			},
+		)
+	})
+}
+
+// IntersectsIterator returns an Iterator of the objects that intersect an
+// object, like Intersects.
+func (c *Collection) IntersectsIterator(obj geojson.Object) *Iterator {
+	return newIterator(func(yield func(string, geojson.Object, []float64,
+		float64) bool) {
+		c.Intersects(obj, 0, nil, nil,
+			func(id string, obj geojson.Object, fields []float64) bool {
+				return yield(id, obj, fields, 0)
+			},
+		)
+	})
+}
+
+// NearbyIterator returns an Iterator of the objects by their distance to a
+// target, like Nearby. The distance of each object is returned by Dist.
+func (c *Collection) NearbyIterator(target geojson.Object) *Iterator {
+	return newIterator(func(yield func(string, geojson.Object, []float64,
+		float64) bool) {
+		c.Nearby(target, nil, nil, yield)
+	})
+}
@@ -0,0 +1,86 @@
+package collection
+
+import (
+	"sort"
+
+	"github.com/tidwall/geojson"
+)
+
+// Bulk loading
+//
+// Load fills an empty collection with many objects at once. The objects are
+// sorted by id, and the strings by value, which allows for appending them
+// to the btrees without searching and rebalancing them for each one, and
+// there are no old objects to look for and replace.
+//
+// The rtree is vendored and has no bulk load, so the geometries are inserted
+// one at a time. Inserting them in the order of a Sort-Tile-Recursive or a
+// Hilbert packing was tried, and it's slower with this rtree than the order
+// of the ids, which are random places on the map for most datasets.
+
+// ItemInput is an object for Load.
+type ItemInput struct {
+	ID     string
+	Object geojson.Object
+	Fields []string  // optional, the names of the values
+	Values []float64 // the field values, in the order of Fields
+	Ex     int64     // unix nano expiration, or zero
+}
+
+// Load adds many objects to the collection. When there are duplicate ids
+// the last one is kept, and unlike with Set, it doesn't have the fields of
+// the others. A collection that isn't empty has the objects set one at a
+// time.
+func (c *Collection) Load(items []ItemInput) {
+	if c.Count() > 0 {
+		for _, in := range items {
+			c.Set(in.ID, in.Object, in.Fields, in.Values, in.Ex)
+		}
+		return
+	}
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return items[order[a]].ID < items[order[b]].ID
+	})
+	c.version++
+	var strs []interface{}
+	for i, idx := range order {
+		in := &items[idx]
+		if i+1 < len(order) && items[order[i+1]].ID == in.ID {
+			// the last one is kept
+			continue
+		}
+		item := &itemT{id: in.ID, obj: c.intern(c.pack(in.Object)),
+			fieldValuesSlot: nilValuesSlot, expires: in.Ex}
+		if in.Fields == nil {
+			if len(in.Values) > 0 {
+				item.fieldValuesSlot = c.fieldValues.set(nilValuesSlot, in.Values)
+			}
+		} else {
+			c.setFieldValues(item, in.Fields, in.Values)
+		}
+		c.items.Load(item)
+		if objIsSpatial(item.obj) {
+			c.indexInsert(item)
+			c.objects++
+		} else {
+			strs = append(strs, item)
+			c.nobjects++
+		}
+		if item.expires != 0 {
+			c.expires.Set(item)
+		}
+		c.points += item.obj.NumPoints()
+		c.weight += c.objWeight(item)
+		c.fieldIndexInsert(item)
+	}
+	sort.Slice(strs, func(i, j int) bool {
+		return byValue(strs[i], strs[j])
+	})
+	for _, item := range strs {
+		c.values.Load(item)
+	}
+}
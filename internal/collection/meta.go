@@ -0,0 +1,25 @@
+package collection
+
+// SetMeta replaces the metadata of an object, and removes it when it's
+// empty. If the object does not exist then the return value will be false.
+func (c *Collection) SetMeta(id, meta string) bool {
+	itemV := c.items.Get(&itemT{id: id})
+	if itemV == nil {
+		return false
+	}
+	item := itemV.(*itemT)
+	c.version++
+	c.weight += len(meta) - len(item.meta)
+	item.meta = meta
+	return true
+}
+
+// Meta returns the metadata of an object, which is empty when it has none.
+// If the object does not exist then the 'ok' return value will be false.
+func (c *Collection) Meta(id string) (meta string, ok bool) {
+	itemV := c.items.Get(&itemT{id: id})
+	if itemV == nil {
+		return "", false
+	}
+	return itemV.(*itemT).meta, true
+}
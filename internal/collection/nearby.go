@@ -0,0 +1,122 @@
+package collection
+
+import (
+	"github.com/tidwall/geoindex/child"
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/tile38/internal/deadline"
+)
+
+// Nearby with a maximum distance
+//
+// Nearby visits the rtree from the closest node to the farthest, and every
+// node that it opens has its children queued, however far they are, until
+// the caller stops. NearbyWithin doesn't queue the nodes and the objects
+// that are farther than the maximum distance, which are most of the tree
+// for a small radius, and it's done when the queue is empty.
+
+// nearbyNode is a node or an object of the rtree in the queue of
+// NearbyWithin, with its distance from the target.
+type nearbyNode struct {
+	dist  float64
+	child child.Child
+}
+
+// nearbyQueue is a min-heap of nodes by distance.
+type nearbyQueue []nearbyNode
+
+func (q *nearbyQueue) push(n nearbyNode) {
+	*q = append(*q, n)
+	nodes := *q
+	i := len(nodes) - 1
+	for i > 0 {
+		parent := (i - 1) / 2
+		if nodes[parent].dist <= nodes[i].dist {
+			break
+		}
+		nodes[parent], nodes[i] = nodes[i], nodes[parent]
+		i = parent
+	}
+}
+
+func (q *nearbyQueue) pop() (nearbyNode, bool) {
+	nodes := *q
+	if len(nodes) == 0 {
+		return nearbyNode{}, false
+	}
+	n := nodes[0]
+	nodes[0] = nodes[len(nodes)-1]
+	nodes = nodes[:len(nodes)-1]
+	*q = nodes
+	i := 0
+	for {
+		smallest := i
+		left, right := i*2+1, i*2+2
+		if left < len(nodes) && nodes[left].dist < nodes[smallest].dist {
+			smallest = left
+		}
+		if right < len(nodes) && nodes[right].dist < nodes[smallest].dist {
+			smallest = right
+		}
+		if smallest == i {
+			break
+		}
+		nodes[smallest], nodes[i] = nodes[i], nodes[smallest]
+		i = smallest
+	}
+	return n, true
+}
+
+// NearbyWithin iterates through the objects that are no farther than
+// maxMeters from the center of the target, from the closest to the
+// farthest, like Nearby. The distance is the distance to the bounding box
+// of an object.
+func (c *Collection) NearbyWithin(
+	target geojson.Object,
+	maxMeters float64,
+	cursor Cursor,
+	deadline *deadline.Deadline,
+	iter func(id string, obj geojson.Object, fields []float64, dist float64) bool,
+) bool {
+	center := target.Center()
+	algo := geodeticDistAlgo([2]float64{center.X, center.Y})
+	var count uint64
+	var lastYield int64
+	var offset uint64
+	if cursor != nil {
+		offset = cursor.Offset()
+		cursor.Step(offset)
+	}
+	var q nearbyQueue
+	var children []child.Child
+	var parent interface{}
+	for {
+		children = c.index.Children(parent, children[:0])
+		for _, ch := range children {
+			dist := algo(ch.Min, ch.Max, ch.Data, ch.Item)
+			if dist <= maxMeters {
+				q.push(nearbyNode{dist: dist, child: ch})
+			}
+		}
+		for {
+			n, ok := q.pop()
+			if !ok {
+				return true
+			}
+			if !n.child.Item {
+				parent = n.child.Data
+				break
+			}
+			count++
+			if count <= offset {
+				skipStep(count, deadline)
+				continue
+			}
+			nextStep(count, cursor, deadline, &lastYield)
+			item := n.child.Data.(*itemT)
+			if !iter(item.id, unpack(item.obj),
+				c.fieldValues.get(item.fieldValuesSlot), n.dist) {
+				return false
+			}
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package collection
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+)
+
+// Packed geometries
+//
+// With the delta codec, large LineStrings and Polygons are kept as a block
+// of coordinates rather than as geojson objects. Each coordinate is
+// quantized to 1e-7 degrees, stored as the difference from the previous
+// coordinate, zigzag encoded, and written as a varint. Neighboring points in
+// a route or a boundary are close together, so most coordinates take two or
+// three bytes instead of eight.
+//
+// Packing is lossless. A geometry is only packed when every coordinate
+// survives the quantization exactly and the packed form reproduces the same
+// GeoJSON, otherwise it's stored as is.
+//
+// The geometry is decoded every time that it leaves the collection, which
+// trades some CPU on reads for the memory savings. A packed geometry never
+// escapes the collection.
+
+// Codec is the in-memory storage format for geometries.
+type Codec int
+
+const (
+	// CodecNone stores geometries as is.
+	CodecNone Codec = iota
+	// CodecDelta packs large geometries as delta encoded coordinates.
+	CodecDelta
+)
+
+// packMinPoints is the smallest number of points that a geometry must have
+// to be packed.
+const packMinPoints = 64
+
+// packScale is the quantization of the packed coordinates.
+const packScale = 1e7
+
+const (
+	packLineString byte = iota + 1
+	packPolygon
+)
+
+// noIndex is used for the decoded geometries, which are short lived and not
+// worth indexing.
+var noIndex = &geometry.IndexOptions{}
+
+// packedGeom is a LineString or Polygon in packed form. It implements
+// geojson.Object by decoding itself, but the collection unpacks it before it
+// gets to a caller.
+type packedGeom struct {
+	rect      geometry.Rect
+	numPoints int
+	data      []byte // kind, ring count, ring sizes, and coordinates
+}
+
+// SetCodec sets the storage format of the geometries. The geometries
+// already in the collection are packed or unpacked when the format changes,
+// which keeps their rectangles, because packing is lossless.
+func (c *Collection) SetCodec(codec Codec) {
+	if codec == c.codec {
+		return
+	}
+	c.codec = codec
+	c.items.Ascend(nil, func(v interface{}) bool {
+		item := v.(*itemT)
+		if !objIsSpatial(item.obj) {
+			return true
+		}
+		var obj geojson.Object
+		if p, ok := item.obj.(*packedGeom); ok {
+			// the decoded geometry isn't indexed, see noIndex
+			obj = p.unpack()
+			if o, err := geojson.Parse(obj.JSON(), nil); err == nil {
+				obj = o
+			}
+		} else if obj = c.pack(item.obj); obj == item.obj {
+			return true
+		}
+		c.version++
+		c.weight -= c.objWeight(item)
+		c.release(item.obj)
+		item.obj = c.intern(obj)
+		c.weight += c.objWeight(item)
+		return true
+	})
+}
+
+// pack returns the packed form of the object, or the object itself when it
+// can't be packed.
+func (c *Collection) pack(obj geojson.Object) geojson.Object {
+	if c.codec != CodecDelta || obj.NumPoints() < packMinPoints {
+		return obj
+	}
+	var kind byte
+	var rings []geometry.Series
+	switch g := obj.(type) {
+	case *geojson.LineString:
+		kind = packLineString
+		rings = append(rings, g.Base())
+	case *geojson.Polygon:
+		kind = packPolygon
+		poly := g.Base()
+		rings = append(rings, poly.Exterior)
+		for _, hole := range poly.Holes {
+			rings = append(rings, hole)
+		}
+	default:
+		return obj
+	}
+	data := make([]byte, 0, obj.NumPoints()*4)
+	data = append(data, kind)
+	data = appendUvarint(data, uint64(len(rings)))
+	for _, ring := range rings {
+		data = appendUvarint(data, uint64(ring.NumPoints()))
+	}
+	var px, py int64
+	for _, ring := range rings {
+		n := ring.NumPoints()
+		for i := 0; i < n; i++ {
+			pt := ring.PointAt(i)
+			x, ok := quantize(pt.X)
+			if !ok {
+				return obj
+			}
+			y, ok := quantize(pt.Y)
+			if !ok {
+				return obj
+			}
+			data = appendVarint(data, x-px)
+			data = appendVarint(data, y-py)
+			px, py = x, y
+		}
+	}
+	p := &packedGeom{
+		rect:      obj.Rect(),
+		numPoints: obj.NumPoints(),
+		data:      append([]byte(nil), data...),
+	}
+	// Extra dimensions and members, such as "bbox", are not packed. Make
+	// sure that nothing was lost.
+	if p.unpack().String() != obj.String() {
+		return obj
+	}
+	return p
+}
+
+func appendUvarint(dst []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(dst, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendVarint(dst []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(dst, buf[:binary.PutVarint(buf[:], v)]...)
+}
+
+func quantize(v float64) (int64, bool) {
+	q := math.Round(v * packScale)
+	if math.Abs(q) > 1<<53 || q/packScale != v {
+		return 0, false
+	}
+	return int64(q), true
+}
+
+// unpack decodes the geometry.
+func (p *packedGeom) unpack() geojson.Object {
+	data := p.data[1:]
+	nrings, n := binary.Uvarint(data)
+	data = data[n:]
+	sizes := make([]int, nrings)
+	for i := range sizes {
+		size, n := binary.Uvarint(data)
+		data = data[n:]
+		sizes[i] = int(size)
+	}
+	var px, py int64
+	rings := make([][]geometry.Point, nrings)
+	for i, size := range sizes {
+		points := make([]geometry.Point, size)
+		for j := range points {
+			dx, n := binary.Varint(data)
+			data = data[n:]
+			dy, n := binary.Varint(data)
+			data = data[n:]
+			px, py = px+dx, py+dy
+			points[j] = geometry.Point{
+				X: float64(px) / packScale,
+				Y: float64(py) / packScale,
+			}
+		}
+		rings[i] = points
+	}
+	if p.data[0] == packLineString {
+		return geojson.NewLineString(geometry.NewLine(rings[0], noIndex))
+	}
+	return geojson.NewPolygon(geometry.NewPoly(rings[0], rings[1:], noIndex))
+}
+
+// unpack returns the object that's stored in the item as a geojson object.
+func unpack(obj geojson.Object) geojson.Object {
+	if p, ok := obj.(*packedGeom); ok {
+		return p.unpack()
+	}
+	return obj
+}
+
+// geomWeight returns the in-memory cost of a geometry.
+func geomWeight(obj geojson.Object) int {
+	if p, ok := obj.(*packedGeom); ok {
+		return len(p.data)
+	}
+	return obj.NumPoints() * 16
+}
+
+// Empty ...
+func (p *packedGeom) Empty() bool {
+	return p.numPoints == 0
+}
+
+// Valid ...
+func (p *packedGeom) Valid() bool {
+	return p.unpack().Valid()
+}
+
+// Rect ...
+func (p *packedGeom) Rect() geometry.Rect {
+	return p.rect
+}
+
+// Center ...
+func (p *packedGeom) Center() geometry.Point {
+	return p.rect.Center()
+}
+
+// Contains ...
+func (p *packedGeom) Contains(other geojson.Object) bool {
+	return p.unpack().Contains(other)
+}
+
+// Within ...
+func (p *packedGeom) Within(other geojson.Object) bool {
+	return p.unpack().Within(other)
+}
+
+// Intersects ...
+func (p *packedGeom) Intersects(other geojson.Object) bool {
+	return p.unpack().Intersects(other)
+}
+
+// AppendJSON ...
+func (p *packedGeom) AppendJSON(dst []byte) []byte {
+	return p.unpack().AppendJSON(dst)
+}
+
+// JSON ...
+func (p *packedGeom) JSON() string {
+	return p.unpack().JSON()
+}
+
+// String ...
+func (p *packedGeom) String() string {
+	return p.unpack().String()
+}
+
+// MarshalJSON ...
+func (p *packedGeom) MarshalJSON() ([]byte, error) {
+	return p.unpack().MarshalJSON()
+}
+
+// Distance ...
+func (p *packedGeom) Distance(obj geojson.Object) float64 {
+	return p.unpack().Distance(obj)
+}
+
+// NumPoints ...
+func (p *packedGeom) NumPoints() int {
+	return p.numPoints
+}
+
+// ForEach ...
+func (p *packedGeom) ForEach(iter func(geom geojson.Object) bool) bool {
+	return p.unpack().ForEach(iter)
+}
+
+// Spatial ...
+func (p *packedGeom) Spatial() geojson.Spatial {
+	return p.unpack().Spatial()
+}
@@ -0,0 +1,151 @@
+package collection
+
+import (
+	"bytes"
+	"hash/fnv"
+
+	"github.com/tidwall/geojson"
+)
+
+// Shared geometries
+//
+// Identical geometries, such as the same polygon stored under thousands of
+// ids, are interned by the collection. The first object that is set is kept
+// and every later object with the same content is swapped for it, which
+// leaves just one instance of the geometry in memory. The instance is
+// reference counted and released when the last item that uses it is
+// deleted.
+//
+// Geometries are compared by their GeoJSON representation. Objects that are
+// too small to be worth the hashing, such as points, are not interned.
+
+// sharedMinPoints is the smallest number of points that a geometry must
+// have to be interned.
+const sharedMinPoints = 5
+
+type sharedGeom struct {
+	obj  geojson.Object
+	hash uint64
+	refs int
+}
+
+// sharedTable holds the interned geometries of a collection.
+type sharedTable struct {
+	byHash map[uint64][]*sharedGeom
+	byObj  map[geojson.Object]*sharedGeom
+}
+
+func shareable(obj geojson.Object) bool {
+	if !objIsSpatial(obj) || obj.NumPoints() < sharedMinPoints {
+		return false
+	}
+	switch obj.(type) {
+	case *geojson.Point, *geojson.SimplePoint:
+		return false
+	}
+	return true
+}
+
+// intern returns the shared instance for the object, which may be the
+// object itself.
+func (c *Collection) intern(obj geojson.Object) geojson.Object {
+	if !shareable(obj) {
+		return obj
+	}
+	if c.shared == nil {
+		c.shared = &sharedTable{
+			byHash: make(map[uint64][]*sharedGeom),
+			byObj:  make(map[geojson.Object]*sharedGeom),
+		}
+	}
+	if g := c.shared.byObj[obj]; g != nil {
+		// the object is already the shared instance
+		g.refs++
+		return obj
+	}
+	key := sharedKey(obj)
+	h := fnv.New64a()
+	h.Write(key)
+	hash := h.Sum64()
+	for _, g := range c.shared.byHash[hash] {
+		if bytes.Equal(sharedKey(g.obj), key) {
+			g.refs++
+			return g.obj
+		}
+	}
+	g := &sharedGeom{obj: obj, hash: hash, refs: 1}
+	c.shared.byHash[hash] = append(c.shared.byHash[hash], g)
+	c.shared.byObj[obj] = g
+	c.weight += geomWeight(obj)
+	return obj
+}
+
+// sharedKey returns the content of the geometry that's compared. Packed
+// geometries are compared by their packed form.
+func sharedKey(obj geojson.Object) []byte {
+	if p, ok := obj.(*packedGeom); ok {
+		return p.data
+	}
+	return []byte(obj.String())
+}
+
+// release drops a reference to the object.
+func (c *Collection) release(obj geojson.Object) {
+	g := c.sharedGeom(obj)
+	if g == nil {
+		return
+	}
+	g.refs--
+	if g.refs > 0 {
+		return
+	}
+	c.weight -= geomWeight(obj)
+	delete(c.shared.byObj, obj)
+	geoms := c.shared.byHash[g.hash]
+	for i := range geoms {
+		if geoms[i] == g {
+			geoms = append(geoms[:i], geoms[i+1:]...)
+			break
+		}
+	}
+	if len(geoms) == 0 {
+		delete(c.shared.byHash, g.hash)
+	} else {
+		c.shared.byHash[g.hash] = geoms
+	}
+}
+
+func (c *Collection) sharedGeom(obj geojson.Object) *sharedGeom {
+	if c.shared == nil || !shareable(obj) {
+		return nil
+	}
+	return c.shared.byObj[obj]
+}
+
+func (t *sharedTable) clone() *sharedTable {
+	if t == nil {
+		return nil
+	}
+	clone := &sharedTable{
+		byHash: make(map[uint64][]*sharedGeom, len(t.byHash)),
+		byObj:  make(map[geojson.Object]*sharedGeom, len(t.byObj)),
+	}
+	for hash, geoms := range t.byHash {
+		cgeoms := make([]*sharedGeom, len(geoms))
+		for i, g := range geoms {
+			cg := *g
+			cgeoms[i] = &cg
+			clone.byObj[cg.obj] = &cg
+		}
+		clone.byHash[hash] = cgeoms
+	}
+	return clone
+}
+
+// SharedCount returns the number of distinct interned geometries.
+func (c *Collection) SharedCount() int {
+	if c.shared == nil {
+		return 0
+	}
+	return len(c.shared.byObj)
+}
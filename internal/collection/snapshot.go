@@ -0,0 +1,116 @@
+package collection
+
+import (
+	"sync/atomic"
+
+	"github.com/tidwall/btree"
+	"github.com/tidwall/geoindex"
+	"github.com/tidwall/rtree"
+)
+
+// Version returns a number that changes every time the collection is
+// modified.
+func (c *Collection) Version() uint64 {
+	return c.version
+}
+
+// Snapshot returns a deep copy of the collection. The copy shares nothing
+// that the original collection mutates, which allows for the copy to be
+// searched without locking while the original is being written to.
+// The snapshot must be treated as read-only.
+func (c *Collection) Snapshot() *Collection {
+	return c.SnapshotCopy().Build()
+}
+
+// SnapshotCopy is a snapshot that's not built yet. Only the objects are
+// copied by SnapshotCopy, which is what needs the lock of the collection,
+// and its trees are built by Build, which doesn't.
+type SnapshotCopy struct {
+	snap  *Collection // without the items
+	items []itemT     // in order
+}
+
+// SnapshotCopy copies the objects of the collection, which are then made
+// into a snapshot by Build, once the collection is unlocked. The copy is
+// flat, and the indexes of the tags and of the fields are shared with the
+// collection until either one changes, see btree.Copy.
+func (c *Collection) SnapshotCopy() *SnapshotCopy {
+	snap := &Collection{
+		fieldMap: make(map[string]int, len(c.fieldMap)),
+		fieldArr: append([]string(nil), c.fieldArr...),
+		fieldValues: &fieldValues{
+			freelist: append([]fieldValuesSlot(nil), c.fieldValues.freelist...),
+			data:     make([][]float64, len(c.fieldValues.data)),
+		},
+		weight:   c.weight,
+		points:   c.points,
+		objects:  c.objects,
+		nobjects: c.nobjects,
+		version:  c.version,
+		shared:   c.shared.clone(),
+	}
+	for field, idx := range c.fieldMap {
+		snap.fieldMap[field] = idx
+	}
+	// The field values are copied into a single array.
+	var n int
+	for _, values := range c.fieldValues.data {
+		n += len(values)
+	}
+	all := make([]float64, 0, n)
+	for i, values := range c.fieldValues.data {
+		if values != nil {
+			start := len(all)
+			all = append(all, values...)
+			snap.fieldValues.data[i] = all[start:len(all):len(all)]
+		}
+	}
+	// The copies of the trees write to the trees of the collection, and so
+	// they can't be made by two readers at once.
+	c.snapmu.Lock()
+	snap.fieldExpires = c.fieldExpires.clone()
+	snap.tags = c.tags.clone()
+	snap.strFields = c.strFields.clone()
+	snap.fieldIndexes = c.fieldIndexes.clone()
+	c.snapmu.Unlock()
+
+	items := make([]itemT, 0, c.items.Len())
+	c.items.Ascend(nil, func(v interface{}) bool {
+		src := v.(*itemT)
+		items = append(items, itemT{
+			atime:           atomic.LoadInt64(&src.atime),
+			id:              src.id,
+			meta:            src.meta,
+			obj:             src.obj,
+			expires:         src.expires,
+			fieldValuesSlot: src.fieldValuesSlot,
+		})
+		return true
+	})
+	return &SnapshotCopy{snap: snap, items: items}
+}
+
+// Build returns the snapshot of the copy. It must be called once.
+func (cp *SnapshotCopy) Build() *Collection {
+	snap := cp.snap
+	snap.items = btree.NewNonConcurrent(byID)
+	snap.index = geoindex.Wrap(&rtree.RTree{})
+	snap.values = btree.NewNonConcurrent(byValue)
+	snap.expires = btree.NewNonConcurrent(byExpires)
+	// The items are in order, which allows for loading the tree with the
+	// faster append path.
+	for i := range cp.items {
+		item := &cp.items[i]
+		snap.items.Load(item)
+		if objIsSpatial(item.obj) {
+			snap.indexInsert(item)
+		} else {
+			snap.values.Set(item)
+		}
+		if item.expires != 0 {
+			snap.expires.Set(item)
+		}
+	}
+	cp.snap, cp.items = nil, nil
+	return snap
+}
@@ -0,0 +1,92 @@
+package collection
+
+import "sort"
+
+// stringFields are the string values of the fields of the objects, by id.
+// They're kept apart from the float64 field values, which most objects only
+// have. The map of an object is replaced rather than changed, which allows
+// for a snapshot to share it.
+type stringFields map[string]map[string]string
+
+// SetStringField sets the string value of a field of an object, and removes
+// it when the value is empty. It returns true when the value changed. If the
+// object does not exist then the 'ok' return value will be false.
+func (c *Collection) SetStringField(id, field, value string) (
+	updated, ok bool,
+) {
+	if c.items.Get(&itemT{id: id}) == nil {
+		return false, false
+	}
+	old := c.strFields[id]
+	ovalue, had := old[field]
+	if ovalue == value && (had || value == "") {
+		return false, true
+	}
+	c.version++
+	fields := make(map[string]string, len(old)+1)
+	for name, v := range old {
+		fields[name] = v
+	}
+	if had {
+		c.weight -= len(field) + len(ovalue)
+	}
+	if value == "" {
+		delete(fields, field)
+	} else {
+		fields[field] = value
+		c.weight += len(field) + len(value)
+	}
+	if c.strFields == nil {
+		c.strFields = make(stringFields)
+	}
+	if len(fields) == 0 {
+		delete(c.strFields, id)
+	} else {
+		c.strFields[id] = fields
+	}
+	return true, true
+}
+
+// StringField returns the string value of a field of an object, which is
+// empty when it has none. If the object does not exist then the 'ok' return
+// value will be false.
+func (c *Collection) StringField(id, field string) (value string, ok bool) {
+	if c.items.Get(&itemT{id: id}) == nil {
+		return "", false
+	}
+	return c.strFields[id][field], true
+}
+
+// StringFields returns the names and the string values of the fields of an
+// object, sorted by name, or nil when it has none.
+func (c *Collection) StringFields(id string) (fields, values []string) {
+	for field := range c.strFields[id] {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		values = append(values, c.strFields[id][field])
+	}
+	return fields, values
+}
+
+// deleteStringFields removes the string fields of a deleted object.
+func (c *Collection) deleteStringFields(id string) {
+	for field, value := range c.strFields[id] {
+		c.weight -= len(field) + len(value)
+	}
+	delete(c.strFields, id)
+}
+
+// clone returns a copy of the string fields, which shares the maps of the
+// objects.
+func (sf stringFields) clone() stringFields {
+	if sf == nil {
+		return nil
+	}
+	cp := make(stringFields, len(sf))
+	for id, fields := range sf {
+		cp[id] = fields
+	}
+	return cp
+}
@@ -0,0 +1,213 @@
+package collection
+
+import (
+	"sort"
+
+	"github.com/tidwall/btree"
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/tile38/internal/deadline"
+)
+
+func byTagID(a, b interface{}) bool {
+	return a.(string) < b.(string)
+}
+
+// tagIndex are the tags of the objects of a collection. The map has the
+// sorted tags by id, and the inverted index has the ids by tag, sorted.
+type tagIndex struct {
+	ids  map[string][]string
+	tags map[string]*btree.BTree
+}
+
+func (ti *tagIndex) add(id, tag string) bool {
+	tags := ti.ids[id]
+	i := sort.SearchStrings(tags, tag)
+	if i < len(tags) && tags[i] == tag {
+		return false
+	}
+	if ti.ids == nil {
+		ti.ids = make(map[string][]string)
+		ti.tags = make(map[string]*btree.BTree)
+	}
+	tags = append(tags, "")
+	copy(tags[i+1:], tags[i:])
+	tags[i] = tag
+	ti.ids[id] = tags
+	tr := ti.tags[tag]
+	if tr == nil {
+		tr = btree.NewNonConcurrent(byTagID)
+		ti.tags[tag] = tr
+	}
+	tr.Set(id)
+	return true
+}
+
+func (ti *tagIndex) remove(id, tag string) bool {
+	tags := ti.ids[id]
+	i := sort.SearchStrings(tags, tag)
+	if i == len(tags) || tags[i] != tag {
+		return false
+	}
+	if len(tags) == 1 {
+		delete(ti.ids, id)
+	} else {
+		ti.ids[id] = append(tags[:i:i], tags[i+1:]...)
+	}
+	if tr := ti.tags[tag]; tr != nil {
+		tr.Delete(id)
+		if tr.Len() == 0 {
+			delete(ti.tags, tag)
+		}
+	}
+	return true
+}
+
+// SetTags replaces the tags of an object, and removes them when there are
+// none. If the object does not exist then the return value will be false.
+func (c *Collection) SetTags(id string, tags []string) bool {
+	if c.items.Get(&itemT{id: id}) == nil {
+		return false
+	}
+	c.version++
+	c.deleteTags(id)
+	for _, tag := range tags {
+		c.tags.add(id, tag)
+	}
+	return true
+}
+
+// AddTags adds tags to an object, and returns the number of tags that it
+// didn't have. If the object does not exist then the 'ok' return value will
+// be false.
+func (c *Collection) AddTags(id string, tags []string) (n int, ok bool) {
+	if c.items.Get(&itemT{id: id}) == nil {
+		return 0, false
+	}
+	for _, tag := range tags {
+		if c.tags.add(id, tag) {
+			n++
+		}
+	}
+	if n > 0 {
+		c.version++
+	}
+	return n, true
+}
+
+// RemoveTags removes tags from an object, and returns the number of tags
+// that it had. If the object does not exist then the 'ok' return value will
+// be false.
+func (c *Collection) RemoveTags(id string, tags []string) (n int, ok bool) {
+	if c.items.Get(&itemT{id: id}) == nil {
+		return 0, false
+	}
+	for _, tag := range tags {
+		if c.tags.remove(id, tag) {
+			n++
+		}
+	}
+	if n > 0 {
+		c.version++
+	}
+	return n, true
+}
+
+// Tags returns the sorted tags of an object, or nil when it has none.
+func (c *Collection) Tags(id string) []string {
+	tags := c.tags.ids[id]
+	if len(tags) == 0 {
+		return nil
+	}
+	return append([]string(nil), tags...)
+}
+
+// HasTag returns true when an object has a tag.
+func (c *Collection) HasTag(id, tag string) bool {
+	tags := c.tags.ids[id]
+	i := sort.SearchStrings(tags, tag)
+	return i < len(tags) && tags[i] == tag
+}
+
+// TagCount returns the number of objects that have a tag.
+func (c *Collection) TagCount(tag string) int {
+	if tr := c.tags.tags[tag]; tr != nil {
+		return tr.Len()
+	}
+	return 0
+}
+
+// ScanTagged iterates though the ids of the objects that have a tag, with
+// the inverted index of the tag.
+func (c *Collection) ScanTagged(
+	tag string,
+	desc bool,
+	cursor Cursor,
+	deadline *deadline.Deadline,
+	iterator func(id string, obj geojson.Object, fields []float64) bool,
+) bool {
+	var keepon = true
+	tr := c.tags.tags[tag]
+	if tr == nil {
+		return keepon
+	}
+	var count uint64
+	var lastYield int64
+	var offset uint64
+	if cursor != nil {
+		offset = cursor.Offset()
+		cursor.Step(offset)
+	}
+	iter := func(v interface{}) bool {
+		count++
+		if count <= offset {
+			skipStep(count, deadline)
+			return true
+		}
+		nextStep(count, cursor, deadline, &lastYield)
+		itemV := c.items.Get(&itemT{id: v.(string)})
+		if itemV == nil {
+			return true
+		}
+		iitm := itemV.(*itemT)
+		keepon = iterator(iitm.id, unpack(iitm.obj),
+			c.fieldValues.get(iitm.fieldValuesSlot))
+		return keepon
+	}
+	if desc {
+		tr.Descend(nil, iter)
+	} else {
+		tr.Ascend(nil, iter)
+	}
+	return keepon
+}
+
+// deleteTags removes the tags of a deleted object.
+func (c *Collection) deleteTags(id string) {
+	for _, tag := range c.tags.ids[id] {
+		if tr := c.tags.tags[tag]; tr != nil {
+			tr.Delete(id)
+			if tr.Len() == 0 {
+				delete(c.tags.tags, tag)
+			}
+		}
+	}
+	delete(c.tags.ids, id)
+}
+
+// clone returns a copy of the tags. The trees are shared with the copy until
+// either one changes.
+func (ti *tagIndex) clone() tagIndex {
+	var cp tagIndex
+	if ti.ids == nil {
+		return cp
+	}
+	cp.ids = make(map[string][]string, len(ti.ids))
+	for id, tags := range ti.ids {
+		cp.ids[id] = append([]string(nil), tags...)
+	}
+	cp.tags = make(map[string]*btree.BTree, len(ti.tags))
+	for tag, tr := range ti.tags {
+		cp.tags[tag] = tr.Copy()
+	}
+	return cp
+}
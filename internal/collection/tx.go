@@ -0,0 +1,160 @@
+package collection
+
+import (
+	"errors"
+
+	"github.com/tidwall/geojson"
+)
+
+// Transactions
+//
+// Batch applies the Sets, Deletes and SetFields of a Tx together, or none
+// of them. The changes are kept by the Tx until the function returns, and
+// they're applied when it returns nil. Each change is checked when it's
+// made, against the collection and the changes before it, and so applying
+// them can't fail part of the way. A Tx of another collection, from With,
+// is applied with the Tx, which moves an object between two collections at
+// once. The collections must not change until Batch returns, which is the
+// lock of the caller.
+
+var (
+	// ErrNotFound is returned by a Tx for an id that's not in the
+	// collection.
+	ErrNotFound = errors.New("not found")
+	// ErrTxClosed is returned by a Tx that's used after its Batch returned.
+	ErrTxClosed = errors.New("tx closed")
+)
+
+const (
+	txSet = iota
+	txDelete
+	txSetField
+)
+
+// txOp is a change of a Tx.
+type txOp struct {
+	kind   int
+	id     string
+	obj    geojson.Object
+	fields []string
+	values []float64
+	ex     int64
+}
+
+// Tx are the changes of a Batch.
+type Tx struct {
+	c      *Collection
+	ops    []txOp
+	objs   map[string]geojson.Object // the objects of the changes, nil if deleted
+	others []*Tx
+	closed bool
+}
+
+// Batch calls fn with a Tx, and applies its changes when fn returns nil.
+// The error of fn is returned, and then none of the changes are applied.
+func (c *Collection) Batch(fn func(tx *Tx) error) error {
+	tx := &Tx{c: c}
+	err := fn(tx)
+	if err == nil {
+		tx.commit()
+	}
+	tx.close()
+	return err
+}
+
+// With returns the Tx of another collection, which is applied with this
+// one.
+func (tx *Tx) With(c *Collection) *Tx {
+	if c == tx.c {
+		return tx
+	}
+	for _, other := range tx.others {
+		if other.c == c {
+			return other
+		}
+	}
+	other := &Tx{c: c, closed: tx.closed}
+	tx.others = append(tx.others, other)
+	return other
+}
+
+// Get returns an object, with the changes of the Tx.
+func (tx *Tx) Get(id string) (obj geojson.Object, ok bool) {
+	if obj, ok := tx.objs[id]; ok {
+		return obj, obj != nil
+	}
+	obj, _, _, ok = tx.c.Get(id)
+	return obj, ok
+}
+
+// Set adds or replaces an object, like Collection.Set.
+func (tx *Tx) Set(
+	id string, obj geojson.Object, fields []string, values []float64, ex int64,
+) error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+	tx.add(txOp{kind: txSet, id: id, obj: obj, fields: fields,
+		values: values, ex: ex}, obj)
+	return nil
+}
+
+// Delete removes an object, and returns ErrNotFound when there's no object.
+func (tx *Tx) Delete(id string) error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+	if _, ok := tx.Get(id); !ok {
+		return ErrNotFound
+	}
+	tx.add(txOp{kind: txDelete, id: id}, nil)
+	return nil
+}
+
+// SetField sets a field of an object, and returns ErrNotFound when there's
+// no object.
+func (tx *Tx) SetField(id, field string, value float64) error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+	obj, ok := tx.Get(id)
+	if !ok {
+		return ErrNotFound
+	}
+	tx.add(txOp{kind: txSetField, id: id, fields: []string{field},
+		values: []float64{value}}, obj)
+	return nil
+}
+
+func (tx *Tx) add(op txOp, obj geojson.Object) {
+	if tx.objs == nil {
+		tx.objs = make(map[string]geojson.Object)
+	}
+	tx.objs[op.id] = obj
+	tx.ops = append(tx.ops, op)
+}
+
+// commit applies the changes of the Tx and of the other collections.
+func (tx *Tx) commit() {
+	for _, op := range tx.ops {
+		switch op.kind {
+		case txSet:
+			tx.c.Set(op.id, op.obj, op.fields, op.values, op.ex)
+		case txDelete:
+			tx.c.Delete(op.id)
+		case txSetField:
+			tx.c.SetFields(op.id, op.fields, op.values)
+		}
+	}
+	for _, other := range tx.others {
+		other.commit()
+	}
+}
+
+func (tx *Tx) close() {
+	tx.closed = true
+	tx.ops, tx.objs = nil, nil
+	for _, other := range tx.others {
+		other.close()
+	}
+}
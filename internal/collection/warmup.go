@@ -0,0 +1,22 @@
+package collection
+
+// Warmup reads every node of the trees of the collection, and the objects
+// that they point to, which brings the memory of a collection that hasn't
+// been read in a while, such as after the AOF was loaded or the pages were
+// swapped out, back into the caches before the first searches need it. It
+// returns the number of objects and of their points.
+func (c *Collection) Warmup() (objects, points int) {
+	c.items.Ascend(nil, func(v interface{}) bool {
+		item := v.(*itemT)
+		objects++
+		points += item.obj.NumPoints()
+		return true
+	})
+	c.index.Scan(func(_, _ [2]float64, _ interface{}) bool { return true })
+	c.values.Ascend(nil, func(interface{}) bool { return true })
+	c.expires.Ascend(nil, func(interface{}) bool { return true })
+	for _, tr := range c.fieldIndexes {
+		tr.Ascend(nil, func(interface{}) bool { return true })
+	}
+	return objects, points
+}
@@ -0,0 +1,221 @@
+package collection
+
+import (
+	"sort"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/rtred/base"
+	"github.com/tidwall/tile38/internal/deadline"
+)
+
+// Altitude index
+//
+// The rtree of a collection indexes the objects by longitude and latitude.
+// SetZIndex adds a second rtree, of three dimensions, which also indexes
+// the lowest and highest z coordinates of the positions of each object, so
+// that WithinZ and IntersectsZ only visit the objects of an altitude band.
+// Without it, WithinZ and IntersectsZ filter the objects of the 2D search
+// instead. Positions without a z coordinate are at zero.
+//
+// Only a point has its z as a field. The z coordinates of the other
+// geometries are read from their GeoJSON, once, and kept by the collection
+// until the object is replaced or deleted, for the index and for the WHERE z
+// of the searches, see ZValues. A packed geometry has no z coordinates.
+
+// zIndexMaxEntries is the number of entries of a node of the altitude rtree.
+const zIndexMaxEntries = 16
+
+// zRange is the altitude band of a search.
+type zRange struct {
+	min, max float64
+}
+
+// match returns true when any of the z coordinates, in order, is in the
+// band.
+func (zr *zRange) match(zs []float64) bool {
+	i := sort.SearchFloat64s(zs, zr.min)
+	return i < len(zs) && zs[i] <= zr.max
+}
+
+// ObjectZ returns the z coordinates of the positions of an object, in order
+// and without duplicates.
+func ObjectZ(obj geojson.Object) []float64 {
+	if point, ok := obj.(*geojson.Point); ok {
+		return []float64{point.Z()}
+	}
+	if _, ok := obj.(*packedGeom); ok || !objIsSpatial(obj) {
+		return []float64{0}
+	}
+	var zs []float64
+	var walk func(json gjson.Result)
+	walk = func(json gjson.Result) {
+		if json.IsArray() {
+			values := json.Array()
+			if len(values) > 0 && values[0].Type == gjson.Number {
+				var z float64
+				if len(values) > 2 {
+					z = values[2].Float()
+				}
+				zs = append(zs, z)
+				return
+			}
+			for _, value := range values {
+				walk(value)
+			}
+			return
+		}
+		if !json.IsObject() {
+			return
+		}
+		for _, key := range []string{"coordinates", "geometry", "geometries",
+			"features"} {
+			walk(json.Get(key))
+		}
+	}
+	walk(gjson.Parse(obj.JSON()))
+	if len(zs) == 0 {
+		return []float64{0}
+	}
+	sort.Float64s(zs)
+	n := 1
+	for i := 1; i < len(zs); i++ {
+		if zs[i] != zs[n-1] {
+			zs[n] = zs[i]
+			n++
+		}
+	}
+	return zs[:n]
+}
+
+// SetZIndex adds or removes the altitude index of the collection. The
+// objects already in the collection are indexed when it's added.
+func (c *Collection) SetZIndex(on bool) {
+	if on == (c.zindex != nil) {
+		return
+	}
+	if !on {
+		c.zindex = nil
+		return
+	}
+	c.zindex = base.New(3, zIndexMaxEntries)
+	c.items.Ascend(nil, func(v interface{}) bool {
+		item := v.(*itemT)
+		if objIsSpatial(item.obj) {
+			c.zindexInsert(item)
+		}
+		return true
+	})
+}
+
+// ZIndexed returns true when the collection has the altitude index.
+func (c *Collection) ZIndexed() bool {
+	return c.zindex != nil
+}
+
+// ZValues returns the z coordinates of an object of the collection, like
+// ObjectZ, but without reading its GeoJSON every time. The object must be
+// the object of the id, or the object that was set for it, otherwise its
+// GeoJSON is read.
+func (c *Collection) ZValues(id string, obj geojson.Object) []float64 {
+	if point, ok := obj.(*geojson.Point); ok {
+		return []float64{point.Z()}
+	}
+	v := c.items.Get(&itemT{id: id})
+	if v == nil {
+		return ObjectZ(obj)
+	}
+	item := v.(*itemT)
+	if p, ok := item.obj.(*packedGeom); ok {
+		if obj.NumPoints() != p.numPoints || obj.Rect() != p.rect {
+			return ObjectZ(obj)
+		}
+	} else if item.obj != obj {
+		return ObjectZ(obj)
+	}
+	return c.itemZ(item)
+}
+
+// itemZ returns the z coordinates of an object, and keeps them for the
+// geometries other than points. It's called by readers too, and so the kept
+// coordinates have their own lock.
+func (c *Collection) itemZ(item *itemT) []float64 {
+	switch item.obj.(type) {
+	case *geojson.Point, *packedGeom:
+		return ObjectZ(item.obj)
+	}
+	if item.obj.Empty() {
+		return ObjectZ(item.obj)
+	}
+	c.zmu.Lock()
+	defer c.zmu.Unlock()
+	zs, ok := c.zvalues[item]
+	if !ok {
+		zs = ObjectZ(item.obj)
+		if c.zvalues == nil {
+			c.zvalues = make(map[*itemT][]float64)
+		}
+		c.zvalues[item] = zs
+	}
+	return zs
+}
+
+// forgetZ drops the z coordinates of an object that's replaced or deleted.
+func (c *Collection) forgetZ(item *itemT) {
+	c.zmu.Lock()
+	delete(c.zvalues, item)
+	c.zmu.Unlock()
+}
+
+func (c *Collection) zindexInsert(item *itemT) {
+	if c.zindex == nil || item.obj.Empty() {
+		return
+	}
+	zs := c.itemZ(item)
+	rect := objRect(item.obj)
+	c.zindex.Insert(
+		[]float64{rect.Min.X, rect.Min.Y, zs[0]},
+		[]float64{rect.Max.X, rect.Max.Y, zs[len(zs)-1]},
+		item)
+}
+
+func (c *Collection) zindexDelete(item *itemT) {
+	if c.zindex == nil || item.obj.Empty() {
+		return
+	}
+	zs := c.itemZ(item)
+	rect := objRect(item.obj)
+	c.zindex.Remove(
+		[]float64{rect.Min.X, rect.Min.Y, zs[0]},
+		[]float64{rect.Max.X, rect.Max.Y, zs[len(zs)-1]},
+		item)
+}
+
+// WithinZ returns the objects that are within an object, like Within, and
+// that have a position with a z coordinate between minZ and maxZ.
+func (c *Collection) WithinZ(
+	obj geojson.Object,
+	minZ, maxZ float64,
+	sparse uint8,
+	cursor Cursor,
+	deadline *deadline.Deadline,
+	iter func(id string, obj geojson.Object, fields []float64) bool,
+) bool {
+	return c.within(obj, &zRange{min: minZ, max: maxZ}, sparse, cursor,
+		deadline, iter)
+}
+
+// IntersectsZ returns the objects that intersect an object, like
+// Intersects, and that have a position with a z coordinate between minZ and
+// maxZ.
+func (c *Collection) IntersectsZ(
+	obj geojson.Object,
+	minZ, maxZ float64,
+	sparse uint8,
+	cursor Cursor,
+	deadline *deadline.Deadline,
+	iter func(id string, obj geojson.Object, fields []float64) bool,
+) bool {
+	return c.intersects(obj, &zRange{min: minZ, max: maxZ}, sparse, cursor,
+		deadline, iter)
+}
@@ -0,0 +1,253 @@
+// Package crs transforms coordinates between WGS84 and other coordinate
+// reference systems, which are identified by their EPSG codes.
+package crs
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// WGS84 is the EPSG code of the reference system that is used for all
+// stored geometries.
+const WGS84 = 4326
+
+// CRS is a coordinate reference system.
+type CRS struct {
+	code    int
+	forward func(lon, lat float64) (x, y float64)
+	inverse func(x, y float64) (lon, lat float64)
+}
+
+// Code returns the EPSG code.
+func (c *CRS) Code() int {
+	return c.code
+}
+
+// String returns the EPSG code in the "EPSG:4326" format.
+func (c *CRS) String() string {
+	return "EPSG:" + strconv.Itoa(c.code)
+}
+
+// Forward transforms WGS84 coordinates into the reference system.
+func (c *CRS) Forward(lon, lat float64) (x, y float64) {
+	return c.forward(lon, lat)
+}
+
+// Inverse transforms coordinates of the reference system into WGS84.
+func (c *CRS) Inverse(x, y float64) (lon, lat float64) {
+	return c.inverse(x, y)
+}
+
+// Lookup returns the reference system for an EPSG code. The supported
+// systems are:
+//
+//	4326         WGS84
+//	3857         Web Mercator
+//	32601-32660  WGS84 / UTM zones 1N-60N
+//	32701-32760  WGS84 / UTM zones 1S-60S
+//	25828-25838  ETRS89 / UTM zones 28N-38N
+//	27700        OSGB36 / British National Grid
+//
+// ETRS89 is treated as WGS84, which is accurate to about a meter.
+func Lookup(code int) (*CRS, bool) {
+	switch {
+	case code == WGS84:
+		return &CRS{code: code,
+			forward: func(lon, lat float64) (float64, float64) { return lon, lat },
+			inverse: func(x, y float64) (float64, float64) { return x, y },
+		}, true
+	case code == 3857:
+		return &CRS{code: code,
+			forward: mercatorForward, inverse: mercatorInverse}, true
+	case code >= 32601 && code <= 32660:
+		return tmCRS(code, utm(wgs84, code-32600, false)), true
+	case code >= 32701 && code <= 32760:
+		return tmCRS(code, utm(wgs84, code-32700, true)), true
+	case code >= 25828 && code <= 25838:
+		return tmCRS(code, utm(grs80, code-25800, false)), true
+	case code == 27700:
+		return &CRS{code: code,
+			forward: func(lon, lat float64) (float64, float64) {
+				lon, lat = osgb36.fromWGS84(lon, lat)
+				return britishGrid.forward(lon, lat)
+			},
+			inverse: func(x, y float64) (float64, float64) {
+				lon, lat := britishGrid.inverse(x, y)
+				return osgb36.toWGS84(lon, lat)
+			},
+		}, true
+	}
+	return nil, false
+}
+
+// Parse returns the reference system for an EPSG code in the "EPSG:32633"
+// or "32633" format.
+func Parse(s string) (*CRS, error) {
+	str := s
+	if len(str) > 5 && strings.EqualFold(str[:5], "epsg:") {
+		str = str[5:]
+	}
+	code, err := strconv.Atoi(str)
+	if err != nil {
+		return nil, errors.New("invalid crs '" + s + "'")
+	}
+	c, ok := Lookup(code)
+	if !ok {
+		return nil, errors.New("unsupported crs '" + s + "'")
+	}
+	return c, nil
+}
+
+const mercatorRadius = 6378137.0
+
+func mercatorForward(lon, lat float64) (x, y float64) {
+	lat = math.Max(math.Min(lat, 85.06), -85.06)
+	x = mercatorRadius * lon * math.Pi / 180
+	y = mercatorRadius * math.Log(math.Tan(math.Pi/4+lat*math.Pi/360))
+	return x, y
+}
+
+func mercatorInverse(x, y float64) (lon, lat float64) {
+	lon = x / mercatorRadius * 180 / math.Pi
+	lat = (2*math.Atan(math.Exp(y/mercatorRadius)) - math.Pi/2) * 180 / math.Pi
+	return lon, lat
+}
+
+func tmCRS(code int, tm *transverseMercator) *CRS {
+	return &CRS{code: code, forward: tm.forward, inverse: tm.inverse}
+}
+
+// AppendJSON appends the GeoJSON to dst with the positions of all
+// "coordinates" and "bbox" members passed through fn. All other members are
+// copied as-is.
+func AppendJSON(dst []byte, json []byte,
+	fn func(x, y float64) (float64, float64),
+) []byte {
+	return appendObject(dst, gjson.ParseBytes(json), fn)
+}
+
+func appendObject(dst []byte, obj gjson.Result,
+	fn func(x, y float64) (float64, float64),
+) []byte {
+	switch {
+	case obj.IsArray():
+		dst = append(dst, '[')
+		var i int
+		obj.ForEach(func(_, value gjson.Result) bool {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendObject(dst, value, fn)
+			i++
+			return true
+		})
+		return append(dst, ']')
+	case !obj.IsObject():
+		return append(dst, obj.Raw...)
+	}
+	dst = append(dst, '{')
+	var i int
+	obj.ForEach(func(key, value gjson.Result) bool {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, key.Raw...)
+		dst = append(dst, ':')
+		switch key.String() {
+		case "coordinates":
+			dst = appendCoordinates(dst, value, fn)
+		case "bbox":
+			dst = appendBBox(dst, value, fn)
+		case "geometry", "geometries", "features":
+			dst = appendObject(dst, value, fn)
+		default:
+			dst = append(dst, value.Raw...)
+		}
+		i++
+		return true
+	})
+	return append(dst, '}')
+}
+
+// appendCoordinates transforms nested arrays of positions. A position is an
+// array of numbers, where only the first two are transformed.
+func appendCoordinates(dst []byte, coords gjson.Result,
+	fn func(x, y float64) (float64, float64),
+) []byte {
+	if !coords.IsArray() {
+		return append(dst, coords.Raw...)
+	}
+	values := coords.Array()
+	if len(values) >= 2 && values[0].Type == gjson.Number {
+		x, y := fn(values[0].Float(), values[1].Float())
+		dst = append(dst, '[')
+		dst = strconv.AppendFloat(dst, x, 'f', -1, 64)
+		dst = append(dst, ',')
+		dst = strconv.AppendFloat(dst, y, 'f', -1, 64)
+		for _, value := range values[2:] {
+			dst = append(dst, ',')
+			dst = append(dst, value.Raw...)
+		}
+		return append(dst, ']')
+	}
+	dst = append(dst, '[')
+	for i, value := range values {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendCoordinates(dst, value, fn)
+	}
+	return append(dst, ']')
+}
+
+// appendBBox transforms a two dimensional bbox into the bounds of its four
+// transformed corners. Other bboxes are copied as-is.
+func appendBBox(dst []byte, bbox gjson.Result,
+	fn func(x, y float64) (float64, float64),
+) []byte {
+	values := bbox.Array()
+	if len(values) != 4 {
+		return append(dst, bbox.Raw...)
+	}
+	min, max := TransformBounds(
+		[2]float64{values[0].Float(), values[1].Float()},
+		[2]float64{values[2].Float(), values[3].Float()}, fn)
+	dst = append(dst, '[')
+	for i, v := range [4]float64{min[0], min[1], max[0], max[1]} {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = strconv.AppendFloat(dst, v, 'f', -1, 64)
+	}
+	return append(dst, ']')
+}
+
+// TransformBounds transforms a rectangle into the bounds of its four
+// transformed corners.
+func TransformBounds(min, max [2]float64,
+	fn func(x, y float64) (float64, float64),
+) (tmin, tmax [2]float64) {
+	corners := [4][2]float64{
+		{min[0], min[1]}, {max[0], min[1]}, {max[0], max[1]}, {min[0], max[1]},
+	}
+	for i, c := range corners {
+		x, y := fn(c[0], c[1])
+		if i == 0 || x < tmin[0] {
+			tmin[0] = x
+		}
+		if i == 0 || y < tmin[1] {
+			tmin[1] = y
+		}
+		if i == 0 || x > tmax[0] {
+			tmax[0] = x
+		}
+		if i == 0 || y > tmax[1] {
+			tmax[1] = y
+		}
+	}
+	return tmin, tmax
+}
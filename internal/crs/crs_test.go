@@ -0,0 +1,104 @@
+package crs
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"4326", "EPSG:3857", "epsg:32633", "32760",
+		"25832", "27700"} {
+		if _, err := Parse(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "EPSG:", "epsg:abc", "2154", "32661"} {
+		if _, err := Parse(s); err == nil {
+			t.Fatalf("%s: expected an error", s)
+		}
+	}
+	c, _ := Parse("epsg:32633")
+	if c.Code() != 32633 || c.String() != "EPSG:32633" {
+		t.Fatalf("expected EPSG:32633, got %s", c)
+	}
+}
+
+func TestMercator(t *testing.T) {
+	c, _ := Lookup(3857)
+	x, y := c.Forward(180, 0)
+	if !near(x, 20037508.34, 0.01) || !near(y, 0, 1e-9) {
+		t.Fatalf("expected 20037508.34 0, got %v %v", x, y)
+	}
+	lon, lat := c.Inverse(c.Forward(-73.9857, 40.7484))
+	if !near(lon, -73.9857, 1e-9) || !near(lat, 40.7484, 1e-9) {
+		t.Fatalf("expected -73.9857 40.7484, got %v %v", lon, lat)
+	}
+}
+
+func TestUTM(t *testing.T) {
+	c, _ := Lookup(32633)
+	x, y := c.Forward(15, 0)
+	if !near(x, 500000, 1e-6) || !near(y, 0, 1e-6) {
+		t.Fatalf("expected 500000 0, got %v %v", x, y)
+	}
+	// the meridian arc from the equator to 1 degree is 110574.389 meters
+	_, y = c.Forward(15, 1)
+	if !near(y, 110574.389*0.9996, 0.01) {
+		t.Fatalf("expected %v, got %v", 110574.389*0.9996, y)
+	}
+	s, _ := Lookup(32733)
+	_, y = s.Forward(15, -1)
+	if !near(y, 10000000-110574.389*0.9996, 0.01) {
+		t.Fatalf("expected %v, got %v", 10000000-110574.389*0.9996, y)
+	}
+	for _, code := range []int{32633, 32733, 25832} {
+		c, _ := Lookup(code)
+		for _, p := range [][2]float64{{13.405, 52.52}, {11, -33.9}, {9, 0}} {
+			lon, lat := c.Inverse(c.Forward(p[0], p[1]))
+			if !near(lon, p[0], 1e-8) || !near(lat, p[1], 1e-8) {
+				t.Fatalf("%d: expected %v, got %v %v", code, p, lon, lat)
+			}
+		}
+	}
+}
+
+func TestBritishGrid(t *testing.T) {
+	// worked example from the Ordnance Survey guide to coordinate systems
+	lat := 52 + 39.0/60 + 27.2531/3600
+	lon := 1 + 43.0/60 + 4.5177/3600
+	x, y := britishGrid.forward(lon, lat)
+	if !near(x, 651409.903, 0.001) || !near(y, 313177.270, 0.001) {
+		t.Fatalf("expected 651409.903 313177.270, got %v %v", x, y)
+	}
+	lon2, lat2 := britishGrid.inverse(x, y)
+	if !near(lon2, lon, 1e-8) || !near(lat2, lat, 1e-8) {
+		t.Fatalf("expected %v %v, got %v %v", lon, lat, lon2, lat2)
+	}
+	c, _ := Lookup(27700)
+	lon, lat = c.Inverse(c.Forward(-0.1276, 51.5072))
+	if !near(lon, -0.1276, 1e-6) || !near(lat, 51.5072, 1e-6) {
+		t.Fatalf("expected -0.1276 51.5072, got %v %v", lon, lat)
+	}
+}
+
+func TestAppendJSON(t *testing.T) {
+	double := func(x, y float64) (float64, float64) { return x * 2, y * 2 }
+	for _, tc := range [][2]string{
+		{`{"type":"Point","coordinates":[1,2,3]}`,
+			`{"type":"Point","coordinates":[2,4,3]}`},
+		{`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`,
+			`{"type":"Polygon","coordinates":[[[0,0],[2,0],[2,2],[0,0]]]}`},
+		{`{"type":"Feature","geometry":{"type":"LineString","coordinates":` +
+			`[[1,1],[2,2]]},"bbox":[1,1,2,2],"properties":{"coordinates":1}}`,
+			`{"type":"Feature","geometry":{"type":"LineString","coordinates":` +
+				`[[2,2],[4,4]]},"bbox":[2,2,4,4],"properties":{"coordinates":1}}`},
+	} {
+		if got := string(AppendJSON(nil, []byte(tc[0]), double)); got != tc[1] {
+			t.Fatalf("expected %s, got %s", tc[1], got)
+		}
+	}
+}
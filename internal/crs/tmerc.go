@@ -0,0 +1,184 @@
+package crs
+
+import "math"
+
+const deg = math.Pi / 180
+
+// ellipsoid is the reference ellipsoid of a datum.
+type ellipsoid struct {
+	a float64 // semi-major axis, meters
+	f float64 // flattening
+}
+
+var (
+	wgs84 = ellipsoid{a: 6378137, f: 1 / 298.257223563}
+	grs80 = ellipsoid{a: 6378137, f: 1 / 298.257222101}
+	airy  = ellipsoid{a: 6377563.396, f: 1 / 299.3249646}
+)
+
+// transverseMercator is a projection that uses the Krüger series, which
+// is accurate to a millimeter within a few thousand kilometers of the
+// central meridian.
+type transverseMercator struct {
+	lon0   float64 // central meridian, degrees
+	k0     float64 // scale factor on the central meridian
+	e0, n0 float64 // false easting and northing, meters
+	nlat0  float64 // northing of the latitude of origin, before scaling
+
+	n     float64 // third flattening
+	a     float64 // rectifying radius
+	alpha [3]float64
+	beta  [3]float64
+	delta [3]float64
+}
+
+func newTransverseMercator(ell ellipsoid, lat0, lon0, k0, e0, n0 float64,
+) *transverseMercator {
+	n := ell.f / (2 - ell.f)
+	n2, n3 := n*n, n*n*n
+	tm := &transverseMercator{
+		lon0: lon0, k0: k0, e0: e0, n0: n0, n: n,
+		a: ell.a / (1 + n) * (1 + n2/4 + n2*n2/64),
+		alpha: [3]float64{
+			n/2 - 2*n2/3 + 5*n3/16,
+			13*n2/48 - 3*n3/5,
+			61 * n3 / 240,
+		},
+		beta: [3]float64{
+			n/2 - 2*n2/3 + 37*n3/96,
+			n2/48 + n3/15,
+			17 * n3 / 480,
+		},
+		delta: [3]float64{
+			2*n - 2*n2/3 - 2*n3,
+			7*n2/3 - 8*n3/5,
+			56 * n3 / 15,
+		},
+	}
+	if lat0 != 0 {
+		_, tm.nlat0 = tm.project(lon0, lat0)
+	}
+	return tm
+}
+
+// utm returns the projection of a UTM zone.
+func utm(ell ellipsoid, zone int, south bool) *transverseMercator {
+	var n0 float64
+	if south {
+		n0 = 10000000
+	}
+	return newTransverseMercator(ell, 0, float64(zone)*6-183, 0.9996,
+		500000, n0)
+}
+
+// britishGrid is the projection of the British National Grid.
+var britishGrid = newTransverseMercator(airy, 49, -2, 0.9996012717,
+	400000, -100000)
+
+// project returns the unscaled easting and northing.
+func (tm *transverseMercator) project(lon, lat float64) (x, y float64) {
+	phi := lat * deg
+	dlon := (lon - tm.lon0) * deg
+	k := 2 * math.Sqrt(tm.n) / (1 + tm.n)
+	t := math.Sinh(math.Atanh(math.Sin(phi)) - k*math.Atanh(k*math.Sin(phi)))
+	xi := math.Atan2(t, math.Cos(dlon))
+	eta := math.Atanh(math.Sin(dlon) / math.Sqrt(1+t*t))
+	x, y = eta, xi
+	for j, alpha := range tm.alpha {
+		j2 := float64(2 * (j + 1))
+		x += alpha * math.Cos(j2*xi) * math.Sinh(j2*eta)
+		y += alpha * math.Sin(j2*xi) * math.Cosh(j2*eta)
+	}
+	return x * tm.a, y * tm.a
+}
+
+func (tm *transverseMercator) forward(lon, lat float64) (x, y float64) {
+	x, y = tm.project(lon, lat)
+	return tm.e0 + tm.k0*x, tm.n0 + tm.k0*(y-tm.nlat0)
+}
+
+func (tm *transverseMercator) inverse(x, y float64) (lon, lat float64) {
+	xi := ((y-tm.n0)/tm.k0 + tm.nlat0) / tm.a
+	eta := (x - tm.e0) / tm.k0 / tm.a
+	xi1, eta1 := xi, eta
+	for j, beta := range tm.beta {
+		j2 := float64(2 * (j + 1))
+		xi1 -= beta * math.Sin(j2*xi) * math.Cosh(j2*eta)
+		eta1 -= beta * math.Cos(j2*xi) * math.Sinh(j2*eta)
+	}
+	chi := math.Asin(math.Sin(xi1) / math.Cosh(eta1))
+	phi := chi
+	for j, delta := range tm.delta {
+		phi += delta * math.Sin(float64(2*(j+1))*chi)
+	}
+	lon = tm.lon0 + math.Atan2(math.Sinh(eta1), math.Cos(xi1))/deg
+	return lon, phi / deg
+}
+
+// datum is a datum with a seven parameter Helmert transformation from
+// WGS84.
+type datum struct {
+	ell        ellipsoid
+	tx, ty, tz float64 // translation, meters
+	s          float64 // scale, parts per million
+	rx, ry, rz float64 // rotation, arcseconds
+}
+
+// osgb36 is the datum of the British National Grid, which is accurate to
+// about five meters with a Helmert transformation.
+var osgb36 = datum{
+	ell: airy,
+	tx:  -446.448, ty: 125.157, tz: -542.060,
+	s:  20.4894,
+	rx: -0.1502, ry: -0.2470, rz: -0.8421,
+}
+
+func (d datum) fromWGS84(lon, lat float64) (float64, float64) {
+	x, y, z := toCartesian(wgs84, lon, lat)
+	x, y, z = d.helmert(x, y, z, 1)
+	return fromCartesian(d.ell, x, y, z)
+}
+
+func (d datum) toWGS84(lon, lat float64) (float64, float64) {
+	x, y, z := toCartesian(d.ell, lon, lat)
+	x, y, z = d.helmert(x, y, z, -1)
+	return fromCartesian(wgs84, x, y, z)
+}
+
+// helmert applies the transformation, or its approximate inverse when sign
+// is negative.
+func (d datum) helmert(x, y, z, sign float64) (float64, float64, float64) {
+	s := 1 + sign*d.s/1e6
+	rx := sign * d.rx / 3600 * deg
+	ry := sign * d.ry / 3600 * deg
+	rz := sign * d.rz / 3600 * deg
+	return sign*d.tx + s*x - rz*y + ry*z,
+		sign*d.ty + rz*x + s*y - rx*z,
+		sign*d.tz - ry*x + rx*y + s*z
+}
+
+func toCartesian(ell ellipsoid, lon, lat float64) (x, y, z float64) {
+	phi, lambda := lat*deg, lon*deg
+	e2 := ell.f * (2 - ell.f)
+	nu := ell.a / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+	x = nu * math.Cos(phi) * math.Cos(lambda)
+	y = nu * math.Cos(phi) * math.Sin(lambda)
+	z = nu * (1 - e2) * math.Sin(phi)
+	return x, y, z
+}
+
+func fromCartesian(ell ellipsoid, x, y, z float64) (lon, lat float64) {
+	e2 := ell.f * (2 - ell.f)
+	p := math.Sqrt(x*x + y*y)
+	phi := math.Atan2(z, p*(1-e2))
+	for i := 0; i < 10; i++ {
+		nu := ell.a / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+		next := math.Atan2(z+e2*nu*math.Sin(phi), p)
+		if math.Abs(next-phi) < 1e-12 {
+			phi = next
+			break
+		}
+		phi = next
+	}
+	return math.Atan2(y, x) / deg, phi / deg
+}
@@ -1,11 +1,19 @@
 package deadline
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
-// Deadline allows for commands to expire when they run too long
+// Deadline allows for commands to expire when they run too long, or to stop
+// early when they are canceled
 type Deadline struct {
 	unixNano int64
+	parent   context.Context
+	ctx      context.Context
+	cancel   context.CancelFunc
 	hit      bool
+	canceled bool
 }
 
 // New returns a new deadline object
@@ -13,15 +21,75 @@ func New(dl time.Time) *Deadline {
 	return &Deadline{unixNano: dl.UnixNano()}
 }
 
+// WithContext returns a new deadline object that has no time limit and is
+// reached when the context is done
+func WithContext(ctx context.Context) *Deadline {
+	return &Deadline{parent: ctx}
+}
+
+// SetContext sets the context that cancels the deadline
+func (dl *Deadline) SetContext(ctx context.Context) {
+	dl.parent = ctx
+	if dl.cancel != nil {
+		dl.cancel()
+	}
+	dl.ctx, dl.cancel = nil, nil
+}
+
+// Parent returns the context that cancels the deadline
+func (dl *Deadline) Parent() context.Context {
+	if dl == nil || dl.parent == nil {
+		return context.Background()
+	}
+	return dl.parent
+}
+
+// Context returns a context that is done when the deadline is reached or
+// when the command is canceled. It's passed to the things that run outside
+// of the collection iterators, such as scripts and endpoint sends.
+func (dl *Deadline) Context() context.Context {
+	if dl == nil {
+		return context.Background()
+	}
+	if dl.ctx == nil {
+		parent := dl.parent
+		if parent == nil {
+			parent = context.Background()
+		}
+		if dl.unixNano != 0 {
+			dl.ctx, dl.cancel = context.WithDeadline(parent,
+				time.Unix(0, dl.unixNano))
+		} else {
+			dl.ctx = parent
+		}
+	}
+	return dl.ctx
+}
+
+// Close releases the resources held by the deadline context
+func (dl *Deadline) Close() {
+	if dl != nil && dl.cancel != nil {
+		dl.cancel()
+	}
+}
+
 // Check the deadline and panic when reached
 //go:noinline
 func (dl *Deadline) Check() {
-	if dl == nil || dl.unixNano == 0 {
+	if dl == nil || dl.hit {
 		return
 	}
-	if !dl.hit && time.Now().UnixNano() > dl.unixNano {
+	if dl.unixNano == 0 && dl.parent == nil {
+		return
+	}
+	select {
+	case <-dl.Context().Done():
 		dl.hit = true
+		if dl.parent != nil && dl.parent.Err() != nil {
+			dl.canceled = true
+		}
 		panic("deadline")
+	default:
 	}
 }
 
@@ -30,6 +98,12 @@ func (dl *Deadline) Hit() bool {
 	return dl.hit
 }
 
+// Canceled returns true if the deadline was hit because the context was
+// canceled
+func (dl *Deadline) Canceled() bool {
+	return dl.canceled
+}
+
 // GetDeadlineTime returns the time object for the deadline, and an
 // "empty" boolean
 func (dl *Deadline) GetDeadlineTime() time.Time {
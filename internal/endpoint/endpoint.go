@@ -1,6 +1,7 @@
 package endpoint
 
 import (
+	"context"
 	"errors"
 	"net/url"
 	"strconv"
@@ -174,9 +175,25 @@ func (epc *Manager) Validate(url string) error {
 	return err
 }
 
+// contextConn is an endpoint connection that can abandon a send when the
+// context is done
+type contextConn interface {
+	SendContext(ctx context.Context, val string) error
+}
+
 // Send send a message to an endpoint
 func (epc *Manager) Send(endpoint, msg string) error {
+	return epc.SendContext(context.Background(), endpoint, msg)
+}
+
+// SendContext send a message to an endpoint. The send is abandoned when the
+// context is done.
+func (epc *Manager) SendContext(ctx context.Context, endpoint, msg string,
+) error {
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		epc.mu.Lock()
 		conn, exists := epc.conns[endpoint]
 		if !exists || conn.Expired() {
@@ -214,7 +231,12 @@ func (epc *Manager) Send(endpoint, msg string) error {
 			epc.conns[endpoint] = conn
 		}
 		epc.mu.Unlock()
-		err := conn.Send(msg)
+		var err error
+		if cconn, ok := conn.(contextConn); ok {
+			err = cconn.SendContext(ctx, msg)
+		} else {
+			err = conn.Send(msg)
+		}
 		if err != nil {
 			if err == errExpired {
 				// it's possible that the connection has expired in-between
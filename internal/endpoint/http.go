@@ -2,6 +2,7 @@ package endpoint
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -41,7 +42,14 @@ func (conn *HTTPConn) Expired() bool {
 
 // Send sends a message
 func (conn *HTTPConn) Send(msg string) error {
-	req, err := http.NewRequest("POST", conn.ep.Original, bytes.NewBufferString(msg))
+	return conn.SendContext(context.Background(), msg)
+}
+
+// SendContext sends a message, the request is canceled when the context is
+// done
+func (conn *HTTPConn) SendContext(ctx context.Context, msg string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", conn.ep.Original,
+		bytes.NewBufferString(msg))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,190 @@
+package flatgeobuf
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// builder is a minimal FlatBuffers encoder that writes front-to-back.
+// Each table is written as vtable, then the table itself, and then all of
+// the objects the table references. This keeps every uoffset pointing
+// forward as required by the format. Alignment is relative to the start
+// of the buffer.
+type builder struct {
+	buf []byte
+}
+
+// field is a single table field. Scalars have a size of 1, 2, 4, or 8 bytes
+// and their value is stored in bits. References have a non-nil ref function
+// that writes the referenced object and returns its position.
+type field struct {
+	slot int
+	size int
+	bits uint64
+	ref  func(b *builder) int
+}
+
+func scalarField(slot, size int, bits uint64) field {
+	return field{slot: slot, size: size, bits: bits}
+}
+
+func refField(slot int, ref func(b *builder) int) field {
+	return field{slot: slot, size: 4, ref: ref}
+}
+
+func (b *builder) pad(align int) {
+	for len(b.buf)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *builder) putUint16(pos int, v uint16) {
+	binary.LittleEndian.PutUint16(b.buf[pos:], v)
+}
+
+func (b *builder) putUint32(pos int, v uint32) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], v)
+}
+
+func (b *builder) alloc(n int) int {
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, n)...)
+	return pos
+}
+
+// finish writes the root uoffset followed by the root table.
+func (b *builder) finish(fields []field) []byte {
+	b.buf = b.buf[:0]
+	root := b.alloc(4)
+	b.putUint32(root, uint32(b.table(fields)-root))
+	return b.buf
+}
+
+// table writes a table and returns its position.
+func (b *builder) table(fields []field) int {
+	// inline fields are ordered by size to keep them aligned
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].size > fields[j].size
+	})
+	var nslots int
+	inline := 4 // soffset
+	for _, f := range fields {
+		if f.slot+1 > nslots {
+			nslots = f.slot + 1
+		}
+		if f.size == 8 && inline == 4 {
+			inline += 4
+		}
+		inline += f.size
+	}
+	// vtable
+	b.pad(2)
+	vtable := b.alloc(4 + nslots*2)
+	b.putUint16(vtable, uint16(4+nslots*2))
+	b.putUint16(vtable+2, uint16(inline))
+	// table
+	b.pad(8)
+	table := b.alloc(inline)
+	b.putUint32(table, uint32(int32(table-vtable)))
+	off := 4
+	refs := make([]int, len(fields))
+	for i, f := range fields {
+		if f.size == 8 && off == 4 {
+			off += 4
+		}
+		b.putUint16(vtable+4+f.slot*2, uint16(off))
+		pos := table + off
+		switch f.size {
+		case 1:
+			b.buf[pos] = byte(f.bits)
+		case 2:
+			b.putUint16(pos, uint16(f.bits))
+		case 4:
+			b.putUint32(pos, uint32(f.bits))
+		case 8:
+			binary.LittleEndian.PutUint64(b.buf[pos:], f.bits)
+		}
+		refs[i] = pos
+		off += f.size
+	}
+	// referenced objects
+	for i, f := range fields {
+		if f.ref != nil {
+			b.putUint32(refs[i], uint32(f.ref(b)-refs[i]))
+		}
+	}
+	return table
+}
+
+// vectorStart aligns and writes the length of a vector where each element
+// has the provided size. Returns the position of the length.
+func (b *builder) vectorStart(n, elemSize int) int {
+	align := elemSize
+	if align < 4 {
+		align = 4
+	}
+	for (len(b.buf)+4)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+	pos := b.alloc(4)
+	b.putUint32(pos, uint32(n))
+	return pos
+}
+
+func (b *builder) doubles(vals []float64) int {
+	pos := b.vectorStart(len(vals), 8)
+	for _, v := range vals {
+		b.buf = appendFloat64(b.buf, v)
+	}
+	return pos
+}
+
+func (b *builder) uint32s(vals []uint32) int {
+	pos := b.vectorStart(len(vals), 4)
+	for _, v := range vals {
+		b.buf = appendUint32(b.buf, v)
+	}
+	return pos
+}
+
+func (b *builder) bytes(vals []byte) int {
+	pos := b.vectorStart(len(vals), 1)
+	b.buf = append(b.buf, vals...)
+	return pos
+}
+
+func (b *builder) string(s string) int {
+	pos := b.vectorStart(len(s), 1)
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return pos
+}
+
+// tables writes a vector of tables.
+func (b *builder) tables(tables [][]field) int {
+	pos := b.vectorStart(len(tables), 4)
+	elems := b.alloc(len(tables) * 4)
+	for i, fields := range tables {
+		elem := elems + i*4
+		b.putUint32(elem, uint32(b.table(fields)-elem))
+	}
+	return pos
+}
+
+func appendUint16(dst []byte, v uint16) []byte {
+	return append(dst, byte(v), byte(v>>8))
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	return append(dst, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	dst = appendUint32(dst, uint32(v))
+	return appendUint32(dst, uint32(v>>32))
+}
+
+func appendFloat64(dst []byte, v float64) []byte {
+	return appendUint64(dst, math.Float64bits(v))
+}
@@ -0,0 +1,321 @@
+// Package flatgeobuf encodes geojson objects as FlatGeobuf, including the
+// packed Hilbert R-tree index that allows for random spatial access.
+//
+// https://flatgeobuf.org
+package flatgeobuf
+
+import (
+	"sort"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+)
+
+// NodeSize is the branching factor of the spatial index.
+const NodeSize = 16
+
+var magic = []byte{0x66, 0x67, 0x62, 0x03, 0x66, 0x67, 0x62, 0x00}
+
+// Geometry types
+const (
+	typeUnknown            = 0
+	typePoint              = 1
+	typeLineString         = 2
+	typePolygon            = 3
+	typeMultiPoint         = 4
+	typeMultiLineString    = 5
+	typeMultiPolygon       = 6
+	typeGeometryCollection = 7
+)
+
+// Column types
+const (
+	columnDouble = 10
+	columnString = 11
+)
+
+type feature struct {
+	id     string
+	geom   *geom
+	values []float64
+	bbox   nodeItem
+}
+
+// Writer collects objects and encodes them as a FlatGeobuf file.
+// The first column of every feature is the "id" string column, followed by
+// one double column for each field name.
+type Writer struct {
+	name     string
+	fields   []string
+	features []feature
+}
+
+// NewWriter returns a new Writer for a layer with the provided name and
+// field columns.
+func NewWriter(name string, fields []string) *Writer {
+	return &Writer{name: name, fields: fields}
+}
+
+// Add adds an object. Values are the field values in the same order as the
+// fields passed to NewWriter. Objects that have no geometry, such as
+// strings, are ignored.
+func (w *Writer) Add(id string, o geojson.Object, values []float64) {
+	g := toGeom(o)
+	if g == nil {
+		return
+	}
+	r := o.Rect()
+	w.features = append(w.features, feature{
+		id:     id,
+		geom:   g,
+		values: values,
+		bbox: nodeItem{
+			minX: r.Min.X, minY: r.Min.Y,
+			maxX: r.Max.X, maxY: r.Max.Y,
+		},
+	})
+}
+
+// Len returns the number of features.
+func (w *Writer) Len() int {
+	return len(w.features)
+}
+
+// Bytes returns the encoded file. The features are reordered along a
+// Hilbert curve so that the index can be packed.
+func (w *Writer) Bytes() []byte {
+	extent := newNodeItem(0)
+	for _, f := range w.features {
+		extent.expand(f.bbox)
+	}
+	hvals := make([]uint32, len(w.features))
+	for i, f := range w.features {
+		hvals[i] = hilbert(f.bbox, extent)
+	}
+	idxs := make([]int, len(w.features))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.SliceStable(idxs, func(i, j int) bool {
+		return hvals[idxs[i]] > hvals[idxs[j]]
+	})
+
+	// features and index leaves
+	var data []byte
+	var b builder
+	leaves := make([]nodeItem, len(idxs))
+	for i, idx := range idxs {
+		f := &w.features[idx]
+		leaves[i] = f.bbox
+		leaves[i].offset = uint64(len(data))
+		fbuf := b.finish(w.featureFields(f))
+		data = appendUint32(data, uint32(len(fbuf)))
+		data = append(data, fbuf...)
+	}
+
+	var out []byte
+	out = append(out, magic...)
+	hbuf := b.finish(w.headerFields(extent))
+	out = appendUint32(out, uint32(len(hbuf)))
+	out = append(out, hbuf...)
+	if len(leaves) > 0 {
+		for _, n := range packedRTree(leaves, NodeSize) {
+			out = appendFloat64(out, n.minX)
+			out = appendFloat64(out, n.minY)
+			out = appendFloat64(out, n.maxX)
+			out = appendFloat64(out, n.maxY)
+			out = appendUint64(out, n.offset)
+		}
+	}
+	return append(out, data...)
+}
+
+func (w *Writer) headerFields(extent nodeItem) []field {
+	name := w.name
+	fields := []field{
+		refField(0, func(b *builder) int { return b.string(name) }),
+		scalarField(2, 1, uint64(w.geometryType())),
+		refField(7, func(b *builder) int {
+			cols := [][]field{columnFields("id", columnString)}
+			for _, name := range w.fields {
+				cols = append(cols, columnFields(name, columnDouble))
+			}
+			return b.tables(cols)
+		}),
+		scalarField(8, 8, uint64(len(w.features))),
+		scalarField(9, 2, NodeSize),
+	}
+	if len(w.features) > 0 {
+		fields = append(fields, refField(1, func(b *builder) int {
+			return b.doubles([]float64{
+				extent.minX, extent.minY, extent.maxX, extent.maxY,
+			})
+		}))
+	}
+	return fields
+}
+
+// geometryType returns the type shared by all features, or Unknown when
+// the features are mixed.
+func (w *Writer) geometryType() byte {
+	if len(w.features) == 0 {
+		return typeUnknown
+	}
+	typ := w.features[0].geom.typ
+	for _, f := range w.features[1:] {
+		if f.geom.typ != typ {
+			return typeUnknown
+		}
+	}
+	return typ
+}
+
+func columnFields(name string, typ byte) []field {
+	return []field{
+		refField(0, func(b *builder) int { return b.string(name) }),
+		scalarField(1, 1, uint64(typ)),
+	}
+}
+
+func (w *Writer) featureFields(f *feature) []field {
+	var props []byte
+	props = appendUint16(props, 0)
+	props = appendUint32(props, uint32(len(f.id)))
+	props = append(props, f.id...)
+	for i := range w.fields {
+		if i < len(f.values) && f.values[i] != 0 {
+			props = appendUint16(props, uint16(i+1))
+			props = appendFloat64(props, f.values[i])
+		}
+	}
+	return []field{
+		refField(0, func(b *builder) int {
+			return b.table(f.geom.fields())
+		}),
+		refField(1, func(b *builder) int { return b.bytes(props) }),
+	}
+}
+
+// geom is a flattened geometry. Collections and multi polygons store their
+// children as parts.
+type geom struct {
+	typ   byte
+	xy    []float64
+	ends  []uint32
+	parts []*geom
+}
+
+func (g *geom) fields() []field {
+	var fields []field
+	if len(g.ends) > 0 {
+		ends := g.ends
+		fields = append(fields, refField(0, func(b *builder) int {
+			return b.uint32s(ends)
+		}))
+	}
+	if len(g.xy) > 0 {
+		xy := g.xy
+		fields = append(fields, refField(1, func(b *builder) int {
+			return b.doubles(xy)
+		}))
+	}
+	// the type is always written so that each feature is self-describing,
+	// even when the header declares a single geometry type.
+	fields = append(fields, scalarField(6, 1, uint64(g.typ)))
+	if len(g.parts) > 0 {
+		parts := g.parts
+		fields = append(fields, refField(7, func(b *builder) int {
+			tables := make([][]field, len(parts))
+			for i, p := range parts {
+				tables[i] = p.fields()
+			}
+			return b.tables(tables)
+		}))
+	}
+	return fields
+}
+
+func (g *geom) appendSeries(s geometry.Series) {
+	n := s.NumPoints()
+	for i := 0; i < n; i++ {
+		p := s.PointAt(i)
+		g.xy = append(g.xy, p.X, p.Y)
+	}
+}
+
+// appendRings appends the polygon rings, recording the end of each ring.
+func (g *geom) appendRings(poly *geometry.Poly) {
+	g.appendSeries(poly.Exterior)
+	g.ends = append(g.ends, uint32(len(g.xy)/2))
+	for _, hole := range poly.Holes {
+		g.appendSeries(hole)
+		g.ends = append(g.ends, uint32(len(g.xy)/2))
+	}
+	if len(g.ends) == 1 {
+		// a single ring does not need ends
+		g.ends = nil
+	}
+}
+
+func toGeom(o geojson.Object) *geom {
+	switch o := o.(type) {
+	case *geojson.Feature:
+		return toGeom(o.Base())
+	case *geojson.Point:
+		p := o.Base()
+		return &geom{typ: typePoint, xy: []float64{p.X, p.Y}}
+	case *geojson.SimplePoint:
+		p := o.Base()
+		return &geom{typ: typePoint, xy: []float64{p.X, p.Y}}
+	case *geojson.LineString:
+		g := &geom{typ: typeLineString}
+		g.appendSeries(o.Base())
+		return g
+	case *geojson.Polygon:
+		g := &geom{typ: typePolygon}
+		g.appendRings(o.Base())
+		return g
+	case *geojson.Rect:
+		r := o.Base()
+		return &geom{typ: typePolygon, xy: []float64{
+			r.Min.X, r.Min.Y, r.Max.X, r.Min.Y, r.Max.X, r.Max.Y,
+			r.Min.X, r.Max.Y, r.Min.X, r.Min.Y,
+		}}
+	case *geojson.Circle:
+		return toGeom(o.Primative())
+	case *geojson.MultiPoint:
+		g := &geom{typ: typeMultiPoint}
+		for _, child := range o.Children() {
+			if c := toGeom(child); c != nil {
+				g.xy = append(g.xy, c.xy...)
+			}
+		}
+		return g
+	case *geojson.MultiLineString:
+		g := &geom{typ: typeMultiLineString}
+		for _, child := range o.Children() {
+			if c := toGeom(child); c != nil {
+				g.xy = append(g.xy, c.xy...)
+				g.ends = append(g.ends, uint32(len(g.xy)/2))
+			}
+		}
+		return g
+	case *geojson.MultiPolygon:
+		return toParts(typeMultiPolygon, o.Children())
+	case *geojson.GeometryCollection:
+		return toParts(typeGeometryCollection, o.Children())
+	case *geojson.FeatureCollection:
+		return toParts(typeGeometryCollection, o.Children())
+	}
+	return nil
+}
+
+func toParts(typ byte, children []geojson.Object) *geom {
+	g := &geom{typ: typ}
+	for _, child := range children {
+		if c := toGeom(child); c != nil {
+			g.parts = append(g.parts, c)
+		}
+	}
+	return g
+}
@@ -0,0 +1,191 @@
+package flatgeobuf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+)
+
+// table is a tiny flatbuffers table reader used to verify the output.
+type table struct {
+	buf []byte
+	pos int
+}
+
+func rootTable(buf []byte) table {
+	return table{buf, int(binary.LittleEndian.Uint32(buf))}
+}
+
+func (t table) field(slot int) int {
+	vtable := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	vsize := int(binary.LittleEndian.Uint16(t.buf[vtable:]))
+	if 4+slot*2 >= vsize {
+		return 0
+	}
+	off := int(binary.LittleEndian.Uint16(t.buf[vtable+4+slot*2:]))
+	if off == 0 {
+		return 0
+	}
+	return t.pos + off
+}
+
+func (t table) deref(slot int) int {
+	pos := t.field(slot)
+	return pos + int(binary.LittleEndian.Uint32(t.buf[pos:]))
+}
+
+func (t table) uint64(slot int) uint64 {
+	return binary.LittleEndian.Uint64(t.buf[t.field(slot):])
+}
+
+func (t table) byte(slot int) byte {
+	return t.buf[t.field(slot)]
+}
+
+func (t table) string(slot int) string {
+	pos := t.deref(slot)
+	n := int(binary.LittleEndian.Uint32(t.buf[pos:]))
+	return string(t.buf[pos+4 : pos+4+n])
+}
+
+func (t table) doubles(slot int) []float64 {
+	pos := t.deref(slot)
+	n := int(binary.LittleEndian.Uint32(t.buf[pos:]))
+	vals := make([]float64, n)
+	for i := range vals {
+		vals[i] = math.Float64frombits(
+			binary.LittleEndian.Uint64(t.buf[pos+4+i*8:]))
+	}
+	return vals
+}
+
+func (t table) tables(slot int) []table {
+	pos := t.deref(slot)
+	n := int(binary.LittleEndian.Uint32(t.buf[pos:]))
+	tables := make([]table, n)
+	for i := range tables {
+		elem := pos + 4 + i*4
+		tables[i] = table{t.buf,
+			elem + int(binary.LittleEndian.Uint32(t.buf[elem:]))}
+	}
+	return tables
+}
+
+func TestWriter(t *testing.T) {
+	w := NewWriter("fleet", []string{"speed"})
+	for i := 0; i < 40; i++ {
+		x, y := float64(i%8), float64(i/8)
+		w.Add("truck"+strconv.Itoa(i),
+			geojson.NewPoint(geometry.Point{X: x, Y: y}),
+			[]float64{float64(i)})
+	}
+	data := w.Bytes()
+	if !bytes.Equal(data[:8], magic) {
+		t.Fatalf("bad magic %v", data[:8])
+	}
+	hsize := int(binary.LittleEndian.Uint32(data[8:]))
+	header := rootTable(data[12 : 12+hsize])
+	if name := header.string(0); name != "fleet" {
+		t.Fatalf("expected 'fleet', got '%s'", name)
+	}
+	if typ := header.byte(2); typ != typePoint {
+		t.Fatalf("expected %d, got %d", typePoint, typ)
+	}
+	if n := header.uint64(8); n != 40 {
+		t.Fatalf("expected 40, got %d", n)
+	}
+	env := header.doubles(1)
+	if len(env) != 4 || env[0] != 0 || env[1] != 0 || env[2] != 7 ||
+		env[3] != 4 {
+		t.Fatalf("bad envelope %v", env)
+	}
+	cols := header.tables(7)
+	if len(cols) != 2 || cols[0].string(0) != "id" ||
+		cols[1].string(0) != "speed" || cols[1].byte(1) != columnDouble {
+		t.Fatalf("bad columns")
+	}
+
+	// 40 leaves, 3 leaf nodes, 1 root
+	index := data[12+hsize:]
+	const numNodes = 44
+	node := func(i int) nodeItem {
+		b := index[i*40:]
+		return nodeItem{
+			minX:   math.Float64frombits(binary.LittleEndian.Uint64(b[0:])),
+			minY:   math.Float64frombits(binary.LittleEndian.Uint64(b[8:])),
+			maxX:   math.Float64frombits(binary.LittleEndian.Uint64(b[16:])),
+			maxY:   math.Float64frombits(binary.LittleEndian.Uint64(b[24:])),
+			offset: binary.LittleEndian.Uint64(b[32:]),
+		}
+	}
+	root := node(0)
+	if root.minX != 0 || root.minY != 0 || root.maxX != 7 || root.maxY != 4 {
+		t.Fatalf("bad root %v", root)
+	}
+	if root.offset != 1 {
+		t.Fatalf("expected 1, got %d", root.offset)
+	}
+
+	// every leaf points at a feature with a matching point
+	features := index[numNodes*40:]
+	for i := 4; i < numNodes; i++ {
+		leaf := node(i)
+		fsize := binary.LittleEndian.Uint32(features[leaf.offset:])
+		fbuf := features[leaf.offset+4 : leaf.offset+4+uint64(fsize)]
+		f := rootTable(fbuf)
+		g := table{fbuf, f.deref(0)}
+		xy := g.doubles(1)
+		if len(xy) != 2 || xy[0] != leaf.minX || xy[1] != leaf.minY {
+			t.Fatalf("leaf %d: expected %v, got %v", i, leaf, xy)
+		}
+	}
+}
+
+func TestWriterPolygon(t *testing.T) {
+	o, err := geojson.Parse(`{"type":"Polygon","coordinates":[
+		[[0,0],[10,0],[10,10],[0,10],[0,0]],
+		[[2,2],[4,2],[4,4],[2,4],[2,2]]
+	]}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWriter("", nil)
+	w.Add("poly", o, nil)
+	data := w.Bytes()
+	hsize := int(binary.LittleEndian.Uint32(data[8:]))
+	features := data[12+hsize+2*40:]
+	fsize := binary.LittleEndian.Uint32(features)
+	fbuf := features[4 : 4+fsize]
+	g := table{fbuf, rootTable(fbuf).deref(0)}
+	if typ := g.byte(6); typ != typePolygon {
+		t.Fatalf("expected %d, got %d", typePolygon, typ)
+	}
+	if xy := g.doubles(1); len(xy) != 20 {
+		t.Fatalf("expected 20, got %d", len(xy))
+	}
+	pos := g.deref(0)
+	if n := binary.LittleEndian.Uint32(fbuf[pos:]); n != 2 {
+		t.Fatalf("expected 2, got %d", n)
+	}
+	if end := binary.LittleEndian.Uint32(fbuf[pos+4:]); end != 5 {
+		t.Fatalf("expected 5, got %d", end)
+	}
+}
+
+func TestLevelBounds(t *testing.T) {
+	bounds := levelBounds(40, 16)
+	expect := [][2]int{{4, 44}, {1, 4}, {0, 1}}
+	if len(bounds) != len(expect) {
+		t.Fatalf("expected %v, got %v", expect, bounds)
+	}
+	for i := range bounds {
+		if bounds[i] != expect[i] {
+			t.Fatalf("expected %v, got %v", expect, bounds)
+		}
+	}
+}
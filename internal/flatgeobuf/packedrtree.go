@@ -0,0 +1,107 @@
+package flatgeobuf
+
+import "math"
+
+const hilbertMax = (1 << 16) - 1
+
+// nodeItem is a single item in the packed R-tree. For leaves the offset is
+// the byte offset of the feature in the data section. For all other nodes
+// it's the index of the first child node.
+type nodeItem struct {
+	minX, minY, maxX, maxY float64
+	offset                 uint64
+}
+
+func newNodeItem(offset uint64) nodeItem {
+	return nodeItem{
+		minX: math.Inf(+1), minY: math.Inf(+1),
+		maxX: math.Inf(-1), maxY: math.Inf(-1),
+		offset: offset,
+	}
+}
+
+func (n *nodeItem) expand(r nodeItem) {
+	n.minX = math.Min(n.minX, r.minX)
+	n.minY = math.Min(n.minY, r.minY)
+	n.maxX = math.Max(n.maxX, r.maxX)
+	n.maxY = math.Max(n.maxY, r.maxY)
+}
+
+// levelBounds returns the [start,end) node ranges for each level of the
+// tree, from the leaves up to the root. Nodes are stored top-down, with the
+// root at index zero.
+func levelBounds(numItems, nodeSize int) [][2]int {
+	n := numItems
+	numNodes := n
+	levelNumNodes := []int{n}
+	for {
+		n = (n + nodeSize - 1) / nodeSize
+		numNodes += n
+		levelNumNodes = append(levelNumNodes, n)
+		if n == 1 {
+			break
+		}
+	}
+	bounds := make([][2]int, len(levelNumNodes))
+	n = numNodes
+	for i, size := range levelNumNodes {
+		n -= size
+		bounds[i] = [2]int{n, n + size}
+	}
+	return bounds
+}
+
+// packedRTree builds the nodes of a packed R-tree for the leaves, which
+// must already be in their final order.
+func packedRTree(leaves []nodeItem, nodeSize int) []nodeItem {
+	bounds := levelBounds(len(leaves), nodeSize)
+	nodes := make([]nodeItem, bounds[0][1])
+	copy(nodes[bounds[0][0]:], leaves)
+	for i := 0; i < len(bounds)-1; i++ {
+		pos, end := bounds[i][0], bounds[i][1]
+		newpos := bounds[i+1][0]
+		for pos < end {
+			node := newNodeItem(uint64(pos))
+			for j := 0; j < nodeSize && pos < end; j++ {
+				node.expand(nodes[pos])
+				pos++
+			}
+			nodes[newpos] = node
+			newpos++
+		}
+	}
+	return nodes
+}
+
+// hilbert returns the position of the center of the rect along the hilbert
+// curve that fills the extent.
+func hilbert(r, extent nodeItem) uint32 {
+	var x, y uint32
+	if w := extent.maxX - extent.minX; w > 0 {
+		x = uint32(hilbertMax * ((r.minX+r.maxX)/2 - extent.minX) / w)
+	}
+	if h := extent.maxY - extent.minY; h > 0 {
+		y = uint32(hilbertMax * ((r.minY+r.maxY)/2 - extent.minY) / h)
+	}
+	const n = hilbertMax + 1
+	var d uint32
+	for s := uint32(n / 2); s > 0; s /= 2 {
+		var rx, ry uint32
+		if x&s > 0 {
+			rx = 1
+		}
+		if y&s > 0 {
+			ry = 1
+		}
+		d += s * s * ((3 * rx) ^ ry)
+		// rotate
+		if ry == 0 {
+			if rx == 1 {
+				x = n - 1 - x
+				y = n - 1 - y
+			}
+			x, y = y, x
+		}
+	}
+	return d
+}
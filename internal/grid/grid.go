@@ -0,0 +1,85 @@
+// Package grid aggregates values into the cells of a regular grid.
+package grid
+
+import "github.com/tidwall/geojson/geometry"
+
+// Grid is a rectangle that is divided into rows and columns of equal size.
+// The first row is the northernmost, which is the order of the pixels of an
+// image.
+type Grid struct {
+	rect       geometry.Rect
+	rows, cols int
+	cells      []float64
+}
+
+// New returns an empty grid over the rectangle.
+func New(rect geometry.Rect, rows, cols int) *Grid {
+	return &Grid{rect: rect, rows: rows, cols: cols,
+		cells: make([]float64, rows*cols)}
+}
+
+// Rect returns the rectangle of the grid.
+func (g *Grid) Rect() geometry.Rect {
+	return g.rect
+}
+
+// Rows returns the number of rows.
+func (g *Grid) Rows() int {
+	return g.rows
+}
+
+// Cols returns the number of columns.
+func (g *Grid) Cols() int {
+	return g.cols
+}
+
+// Cell returns the row and column of the cell that has the point. Points on
+// the edge between two cells are in the eastern or southern cell, except for
+// the edges of the grid itself.
+func (g *Grid) Cell(p geometry.Point) (row, col int, ok bool) {
+	if p.X < g.rect.Min.X || p.X > g.rect.Max.X ||
+		p.Y < g.rect.Min.Y || p.Y > g.rect.Max.Y {
+		return 0, 0, false
+	}
+	w := g.rect.Max.X - g.rect.Min.X
+	h := g.rect.Max.Y - g.rect.Min.Y
+	if w > 0 {
+		col = int((p.X - g.rect.Min.X) / w * float64(g.cols))
+	}
+	if h > 0 {
+		row = int((g.rect.Max.Y - p.Y) / h * float64(g.rows))
+	}
+	if col >= g.cols {
+		col = g.cols - 1
+	}
+	if row >= g.rows {
+		row = g.rows - 1
+	}
+	return row, col, true
+}
+
+// Add adds the value to the cell that has the point, and returns false when
+// the point is outside of the grid.
+func (g *Grid) Add(p geometry.Point, value float64) bool {
+	row, col, ok := g.Cell(p)
+	if ok {
+		g.cells[row*g.cols+col] += value
+	}
+	return ok
+}
+
+// Value returns the value of a cell.
+func (g *Grid) Value(row, col int) float64 {
+	return g.cells[row*g.cols+col]
+}
+
+// Max returns the largest value of all cells.
+func (g *Grid) Max() float64 {
+	var max float64
+	for i, v := range g.cells {
+		if i == 0 || v > max {
+			max = v
+		}
+	}
+	return max
+}
@@ -0,0 +1,46 @@
+package grid
+
+import (
+	"testing"
+
+	"github.com/tidwall/geojson/geometry"
+)
+
+func TestGrid(t *testing.T) {
+	g := New(geometry.Rect{
+		Min: geometry.Point{X: 0, Y: 0},
+		Max: geometry.Point{X: 4, Y: 2},
+	}, 2, 4)
+	for _, tc := range []struct {
+		p        geometry.Point
+		row, col int
+		ok       bool
+	}{
+		{geometry.Point{X: 0.5, Y: 1.5}, 0, 0, true},
+		{geometry.Point{X: 0.5, Y: 0.5}, 1, 0, true},
+		{geometry.Point{X: 3.5, Y: 0.5}, 1, 3, true},
+		{geometry.Point{X: 4, Y: 0}, 1, 3, true},
+		{geometry.Point{X: 0, Y: 2}, 0, 0, true},
+		{geometry.Point{X: 1, Y: 1}, 1, 1, true},
+		{geometry.Point{X: 4.1, Y: 1}, 0, 0, false},
+		{geometry.Point{X: 1, Y: -0.1}, 0, 0, false},
+	} {
+		row, col, ok := g.Cell(tc.p)
+		if row != tc.row || col != tc.col || ok != tc.ok {
+			t.Fatalf("%v: expected %d %d %t, got %d %d %t", tc.p,
+				tc.row, tc.col, tc.ok, row, col, ok)
+		}
+	}
+	g.Add(geometry.Point{X: 0.5, Y: 1.5}, 1)
+	g.Add(geometry.Point{X: 0.6, Y: 1.6}, 2.5)
+	g.Add(geometry.Point{X: 3.5, Y: 0.5}, 1)
+	if g.Add(geometry.Point{X: 5, Y: 5}, 1) {
+		t.Fatal("expected false")
+	}
+	if g.Value(0, 0) != 3.5 || g.Value(1, 3) != 1 || g.Value(1, 0) != 0 {
+		t.Fatalf("unexpected values %v", g.cells)
+	}
+	if g.Max() != 3.5 {
+		t.Fatalf("expected 3.5, got %v", g.Max())
+	}
+}
@@ -0,0 +1,131 @@
+// Package histogram records latencies in a fixed amount of memory.
+//
+// Values are placed into buckets that are linear within each power of two,
+// with 16 buckets per power. This keeps the relative error of a reported
+// percentile under 1/16 no matter how large the value is.
+package histogram
+
+import (
+	"math/bits"
+	"time"
+)
+
+const (
+	subBits    = 4
+	subBuckets = 1 << subBits
+	numBuckets = (64 - subBits + 1) * subBuckets
+)
+
+// Histogram is a latency histogram. The zero value is ready for use.
+// A histogram is not safe for concurrent use, use a histogram per goroutine
+// and Merge them when done.
+type Histogram struct {
+	counts [numBuckets]uint64
+	count  uint64
+	sum    uint64
+	min    uint64
+	max    uint64
+}
+
+func bucketOf(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	exp := bits.Len64(v) - subBits - 1
+	return (exp+1)*subBuckets + int(v>>uint(exp)) - subBuckets
+}
+
+// upperBound returns the largest value that falls into the bucket.
+func upperBound(idx int) uint64 {
+	if idx < subBuckets {
+		return uint64(idx)
+	}
+	exp := uint(idx/subBuckets - 1)
+	sub := uint64(idx%subBuckets + subBuckets)
+	return (sub+1)<<exp - 1
+}
+
+// Record adds a duration to the histogram.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	v := uint64(d)
+	h.counts[bucketOf(v)]++
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.count++
+	h.sum += v
+}
+
+// Merge adds all of the durations in other to the histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// Count returns the number of recorded durations.
+func (h *Histogram) Count() int {
+	return int(h.count)
+}
+
+// Min returns the smallest recorded duration.
+func (h *Histogram) Min() time.Duration {
+	return time.Duration(h.min)
+}
+
+// Max returns the largest recorded duration.
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max)
+}
+
+// Mean returns the average of the recorded durations.
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / h.count)
+}
+
+// Percentile returns the duration that p percent of the recorded durations
+// are at or below, where p is between 0 and 100.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(p / 100 * float64(h.count))
+	if rank >= h.count {
+		rank = h.count - 1
+	}
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen > rank {
+			v := upperBound(i)
+			if v > h.max {
+				v = h.max
+			}
+			if v < h.min {
+				v = h.min
+			}
+			return time.Duration(v)
+		}
+	}
+	return time.Duration(h.max)
+}
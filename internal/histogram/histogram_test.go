@@ -0,0 +1,69 @@
+package histogram
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestBuckets(t *testing.T) {
+	for _, v := range []uint64{0, 1, 15, 16, 17, 31, 32, 33, 1000, 123456789,
+		1<<63 - 1, 1 << 63} {
+		idx := bucketOf(v)
+		if idx < 0 || idx >= numBuckets {
+			t.Fatalf("%d: bucket %d out of range", v, idx)
+		}
+		if upperBound(idx) < v {
+			t.Fatalf("%d: upper bound %d is too small", v, upperBound(idx))
+		}
+		if idx > 0 && upperBound(idx-1) >= v {
+			t.Fatalf("%d: belongs in an earlier bucket", v)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var h Histogram
+	if h.Percentile(50) != 0 || h.Mean() != 0 {
+		t.Fatal("expected zero")
+	}
+	rand.Seed(time.Now().UnixNano())
+	vals := make([]time.Duration, 10000)
+	for i := range vals {
+		vals[i] = time.Duration(rand.Int63n(int64(time.Second)))
+		h.Record(vals[i])
+	}
+	sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+	if h.Count() != len(vals) {
+		t.Fatalf("expected %d, got %d", len(vals), h.Count())
+	}
+	if h.Min() != vals[0] || h.Max() != vals[len(vals)-1] {
+		t.Fatalf("bad min/max")
+	}
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		exact := vals[int(p/100*float64(len(vals)))]
+		got := h.Percentile(p)
+		if got < exact || float64(got-exact) > float64(exact)/16 {
+			t.Fatalf("p%v: expected about %v, got %v", p, exact, got)
+		}
+	}
+	if h.Percentile(100) != h.Max() {
+		t.Fatalf("expected %v, got %v", h.Max(), h.Percentile(100))
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var a, b Histogram
+	a.Record(time.Millisecond)
+	b.Record(time.Microsecond)
+	b.Record(time.Second)
+	a.Merge(&b)
+	if a.Count() != 3 || a.Min() != time.Microsecond || a.Max() != time.Second {
+		t.Fatalf("bad merge")
+	}
+	if a.Percentile(50) < time.Millisecond*15/16 ||
+		a.Percentile(50) > time.Millisecond*17/16 {
+		t.Fatalf("expected about 1ms, got %v", a.Percentile(50))
+	}
+}
@@ -0,0 +1,203 @@
+// Package hull computes the convex and the concave hulls of points, which
+// outline the area that a set of objects covers.
+package hull
+
+import (
+	"sort"
+
+	"github.com/tidwall/geojson/geo"
+	"github.com/tidwall/geojson/geometry"
+)
+
+// cross returns the z of the cross product of the vectors o->a and o->b,
+// which is positive when o, a, b turn counterclockwise.
+func cross(o, a, b geometry.Point) float64 {
+	return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+}
+
+// Convex returns the convex hull of the points, counterclockwise and without
+// the closing point, using the monotone chain algorithm. Points on the
+// edges of the hull are left out. The hull has fewer than three points when
+// the points are on a line.
+func Convex(points []geometry.Point) []geometry.Point {
+	pts := append([]geometry.Point(nil), points...)
+	sort.Slice(pts, func(i, j int) bool {
+		if pts[i].X != pts[j].X {
+			return pts[i].X < pts[j].X
+		}
+		return pts[i].Y < pts[j].Y
+	})
+	var n int
+	for i := range pts {
+		if i == 0 || pts[i] != pts[n-1] {
+			pts[n] = pts[i]
+			n++
+		}
+	}
+	pts = pts[:n]
+	if len(pts) < 3 {
+		return pts
+	}
+	hull := make([]geometry.Point, 0, 2*len(pts))
+	for _, p := range pts {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(pts) - 2; i >= 0; i-- {
+		p := pts[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return hull[:len(hull)-1]
+}
+
+// epsilon is the relative rounding error of the distances that are
+// compared.
+const epsilon = 1e-9
+
+// node is a point of the ring of a concave hull.
+type node struct {
+	p          geometry.Point
+	prev, next *node
+}
+
+// segDist returns the squared distance from p to the segment a-b.
+func segDist(p, a, b geometry.Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	x, y := a.X, a.Y
+	if dx != 0 || dy != 0 {
+		t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / (dx*dx + dy*dy)
+		if t > 1 {
+			x, y = b.X, b.Y
+		} else if t > 0 {
+			x, y = a.X+dx*t, a.Y+dy*t
+		}
+	}
+	dx, dy = p.X-x, p.Y-y
+	return dx*dx + dy*dy
+}
+
+// inside returns true when p is on the inner side of the edge a-b of a
+// counterclockwise ring, or on the edge between a and b.
+func inside(p, a, b geometry.Point) bool {
+	if c := cross(a, b, p); c != 0 {
+		return c > 0
+	}
+	return p != a && p != b &&
+		(geometry.Segment{A: a, B: b}).ContainsPoint(p)
+}
+
+// inTriangle returns true when p is strictly inside the counterclockwise
+// triangle a, b, c.
+func inTriangle(p, a, b, c geometry.Point) bool {
+	return cross(a, b, p) > 0 && cross(b, c, p) > 0 && cross(c, a, p) > 0
+}
+
+// Concave returns a concave hull of the points, counterclockwise and without
+// the closing point. Starting from the convex hull, each edge that's longer
+// than meters is dug in to its nearest inner point, as in the concaveman
+// library, as long as the hull stays a simple polygon that has all of the
+// points. Smaller meters follow the points more closely, and a hull that
+// can't be dug in is the convex hull.
+func Concave(points []geometry.Point, meters float64) []geometry.Point {
+	hull := Convex(points)
+	if len(hull) < 3 {
+		return hull
+	}
+	onHull := make(map[geometry.Point]bool, len(hull))
+	for _, p := range hull {
+		onHull[p] = true
+	}
+	var inner []geometry.Point
+	for _, p := range points {
+		if !onHull[p] {
+			onHull[p] = true // once
+			inner = append(inner, p)
+		}
+	}
+	// the ring, and the edges that start at its nodes
+	nodes := make([]*node, len(hull))
+	for i, p := range hull {
+		nodes[i] = &node{p: p}
+	}
+	for i, n := range nodes {
+		n.next = nodes[(i+1)%len(nodes)]
+		n.next.prev = n
+	}
+	first := nodes[0]
+	queue := nodes
+	for len(queue) > 0 && len(inner) > 0 {
+		a := queue[0]
+		queue = queue[1:]
+		b := a.next
+		if geo.DistanceTo(a.p.Y, a.p.X, b.p.Y, b.p.X) <= meters {
+			continue
+		}
+		// the nearest inner point that's inside of the edge, and no farther
+		// from the edge than from the edges beside it, give or take the
+		// rounding of the distances
+		best := -1
+		var bestDist float64
+		for i, p := range inner {
+			d := segDist(p, a.p, b.p)
+			if (best == -1 || d < bestDist) && inside(p, a.p, b.p) &&
+				d <= segDist(p, a.prev.p, a.p)*(1+epsilon) &&
+				d <= segDist(p, b.p, b.next.p)*(1+epsilon) {
+				best, bestDist = i, d
+			}
+		}
+		if best == -1 {
+			continue
+		}
+		p := inner[best]
+		if !canDig(first, a, b, p, inner) {
+			continue
+		}
+		n := &node{p: p, prev: a, next: b}
+		a.next, b.prev = n, n
+		inner[best] = inner[len(inner)-1]
+		inner = inner[:len(inner)-1]
+		queue = append(queue, a, n)
+	}
+	hull = hull[:0]
+	for n := first; ; {
+		hull = append(hull, n.p)
+		if n = n.next; n == first {
+			break
+		}
+	}
+	return hull
+}
+
+// canDig returns true when replacing the edge a-b of the ring with a-p and
+// p-b leaves the other inner points inside, and doesn't cross the ring.
+func canDig(first, a, b *node, p geometry.Point, inner []geometry.Point) bool {
+	for _, q := range inner {
+		if q != p && inTriangle(q, a.p, b.p, p) {
+			return false
+		}
+	}
+	ap := geometry.Segment{A: a.p, B: p}
+	pb := geometry.Segment{A: p, B: b.p}
+	for c := first; ; {
+		d := c.next
+		if c != a {
+			seg := geometry.Segment{A: c.p, B: d.p}
+			if c != a.prev && ap.IntersectsSegment(seg) {
+				return false
+			}
+			if c != b && pb.IntersectsSegment(seg) {
+				return false
+			}
+		}
+		if c = d; c == first {
+			break
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package hull
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/tidwall/geojson/geometry"
+)
+
+// covers returns true when the points are inside or on the ring.
+func covers(ring []geometry.Point, points []geometry.Point) bool {
+	poly := geometry.NewPoly(append(ring, ring[0]), nil, nil)
+	for _, p := range points {
+		if !poly.IntersectsPoint(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestConvex(t *testing.T) {
+	points := []geometry.Point{
+		{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 0}, {X: 2, Y: 2}, {X: 0, Y: 2},
+		{X: 1, Y: 0}, {X: 0, Y: 0}, {X: 0.5, Y: 1.5},
+	}
+	hull := Convex(points)
+	expect := []geometry.Point{{X: 0, Y: 0}, {X: 2, Y: 0}, {X: 2, Y: 2}, {X: 0, Y: 2}}
+	if len(hull) != len(expect) {
+		t.Fatalf("expected %v, got %v", expect, hull)
+	}
+	for i := range expect {
+		if hull[i] != expect[i] {
+			t.Fatalf("expected %v, got %v", expect, hull)
+		}
+	}
+	line := Convex([]geometry.Point{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 1, Y: 1}})
+	if len(line) != 2 {
+		t.Fatalf("expected 2 points, got %v", line)
+	}
+	if len(Convex(nil)) != 0 {
+		t.Fatal("expected no points")
+	}
+}
+
+func TestConcave(t *testing.T) {
+	// a U of points, 0.1 degrees apart, which is about 11km
+	var points []geometry.Point
+	for i := 0; i <= 10; i++ {
+		x := float64(i) / 10
+		points = append(points, geometry.Point{X: x, Y: 0})
+		if i <= 2 || i >= 8 {
+			for j := 1; j <= 10; j++ {
+				points = append(points, geometry.Point{X: x, Y: float64(j) / 10})
+			}
+		}
+	}
+	notch := geometry.Point{X: 0.5, Y: 0.8}
+	convex := Convex(points)
+	if !covers(convex, []geometry.Point{notch}) {
+		t.Fatal("expected the convex hull to cover the notch")
+	}
+	hull := Concave(points, 20000)
+	if !covers(hull, points) {
+		t.Fatalf("expected the hull to cover the points, got %v", hull)
+	}
+	if covers(hull, []geometry.Point{notch}) {
+		t.Fatalf("expected the hull to leave out the notch, got %v", hull)
+	}
+	if hull := Concave(points, 1e7); len(hull) != len(convex) {
+		t.Fatalf("expected the convex hull, got %v", hull)
+	}
+}
+
+func TestConcaveRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		points := make([]geometry.Point, 200)
+		for j := range points {
+			points[j] = geometry.Point{X: rng.Float64(), Y: rng.Float64()}
+		}
+		hull := Concave(points, 5000)
+		if len(hull) < len(Convex(points)) {
+			t.Fatalf("expected at least the points of the convex hull")
+		}
+		if !covers(hull, points) {
+			t.Fatalf("expected the hull to cover the points")
+		}
+		if !geometry.NewPoly(append(hull, hull[0]), nil, nil).Valid() {
+			t.Fatalf("expected a simple polygon, got %v", hull)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package kube
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir has the credentials of the pod, in a cluster.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// apiDiscoverer finds the pods of a label selector with the Kubernetes API.
+type apiDiscoverer struct {
+	endpoint  string // https://host:port
+	namespace string
+	selector  string
+	port      int
+	tokenPath string // read for each request, the token is rotated
+	client    *http.Client
+}
+
+// newInCluster returns the discoverer of the cluster of the pod, with the
+// credentials of its service account.
+func newInCluster(selector string, port int) (*apiDiscoverer, error) {
+	host := os.Getenv("KUBERNETES_SERVICE_HOST")
+	sport := os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || sport == "" {
+		return nil, errors.New("kubernetes discovery must run in a pod")
+	}
+	namespace, err := ioutil.ReadFile(filepath.Join(serviceAccountDir,
+		"namespace"))
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid ca.crt of the service account")
+	}
+	return &apiDiscoverer{
+		endpoint:  "https://" + net.JoinHostPort(host, sport),
+		namespace: strings.TrimSpace(string(namespace)),
+		selector:  selector,
+		port:      port,
+		tokenPath: filepath.Join(serviceAccountDir, "token"),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name              string            `json:"name"`
+			Labels            map[string]string `json:"labels"`
+			DeletionTimestamp string            `json:"deletionTimestamp"`
+		} `json:"metadata"`
+		Status struct {
+			Phase string `json:"phase"`
+			PodIP string `json:"podIP"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+func (d *apiDiscoverer) Peers(ctx context.Context) ([]Peer, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf(
+		"%s/api/v1/namespaces/%s/pods?labelSelector=%s", d.endpoint,
+		url.PathEscape(d.namespace), url.QueryEscape(d.selector)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if d.tokenPath != "" {
+		token, err := ioutil.ReadFile(d.tokenPath)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization",
+			"Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubernetes: %s: %s", resp.Status,
+			strings.TrimSpace(string(body)))
+	}
+	var pods podList
+	if err := json.Unmarshal(body, &pods); err != nil {
+		return nil, err
+	}
+	var peers []Peer
+	for _, pod := range pods.Items {
+		// the pods that are starting or terminating aren't peers
+		if pod.Status.Phase != "Running" || pod.Status.PodIP == "" ||
+			pod.Metadata.DeletionTimestamp != "" {
+			continue
+		}
+		peers = append(peers, Peer{
+			Name:    pod.Metadata.Name,
+			Host:    pod.Status.PodIP,
+			Port:    d.port,
+			Ordinal: Ordinal(pod.Metadata.Name),
+			Labels:  pod.Metadata.Labels,
+		})
+	}
+	sortPeers(peers)
+	return peers, nil
+}
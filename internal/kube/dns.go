@@ -0,0 +1,50 @@
+package kube
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// dnsDiscoverer finds the pods of a headless service, whose DNS name has
+// the address of each of its pods, and the address the name of the pod.
+type dnsDiscoverer struct {
+	name       string
+	port       int
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+}
+
+func newDNS(name string, port int) *dnsDiscoverer {
+	return &dnsDiscoverer{
+		name:       name,
+		port:       port,
+		lookupHost: net.DefaultResolver.LookupHost,
+		lookupAddr: net.DefaultResolver.LookupAddr,
+	}
+}
+
+func (d *dnsDiscoverer) Peers(ctx context.Context) ([]Peer, error) {
+	addrs, err := d.lookupHost(ctx, d.name)
+	if err != nil {
+		return nil, err
+	}
+	peers := make([]Peer, 0, len(addrs))
+	for _, addr := range addrs {
+		peer := Peer{Name: addr, Host: addr, Port: d.port, Ordinal: -1}
+		// tile38-0.tile38.default.svc.cluster.local.
+		if names, err := d.lookupAddr(ctx, addr); err == nil && len(names) > 0 {
+			name := names[0]
+			if i := strings.IndexByte(name, '.'); i != -1 {
+				name = name[:i]
+			}
+			if name != "" {
+				peer.Name = name
+				peer.Ordinal = Ordinal(name)
+			}
+		}
+		peers = append(peers, peer)
+	}
+	sortPeers(peers)
+	return peers, nil
+}
@@ -0,0 +1,148 @@
+// Package kube finds the peers of a server that runs in Kubernetes, and
+// picks the leader that the others follow. The peers are the pods of a
+// headless service, from DNS, or the pods of a label selector, from the
+// Kubernetes API.
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LeaderLabel is the pod label of the leader, when it isn't elected.
+const LeaderLabel = "tile38.com/role"
+
+// The roles of the servers of a cluster.
+const (
+	RoleCandidate = "candidate" // hasn't found its leader yet
+	RoleLeader    = "leader"
+	RoleFollower  = "follower"
+)
+
+// Peer is a server of the cluster.
+type Peer struct {
+	Name    string            // pod name, or the address without one
+	Host    string            // address
+	Port    int               // port of the server
+	Ordinal int               // of a pod of a StatefulSet, or -1
+	Labels  map[string]string // of the pod, from the API only
+}
+
+// Discoverer finds the peers.
+type Discoverer interface {
+	Peers(ctx context.Context) ([]Peer, error)
+}
+
+// Open returns the discoverer of a spec, which is "dns:name[:port]" for the
+// pods of a headless service, or "kubernetes:selector" for the pods of a
+// label selector in the namespace of the server. The port of the peers is
+// port, unless it's in the spec.
+func Open(spec string, port int) (Discoverer, error) {
+	switch {
+	case strings.HasPrefix(spec, "dns:"):
+		name := spec[4:]
+		if i := strings.LastIndexByte(name, ':'); i != -1 {
+			n, err := strconv.ParseUint(name[i+1:], 10, 16)
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("invalid port '%s'", name[i+1:])
+			}
+			name, port = name[:i], int(n)
+		}
+		if name == "" {
+			return nil, errors.New("dns discovery needs a service name")
+		}
+		return newDNS(name, port), nil
+	case strings.HasPrefix(spec, "kubernetes:"):
+		selector := spec[11:]
+		if selector == "" {
+			return nil, errors.New("kubernetes discovery needs a label selector")
+		}
+		return newInCluster(selector, port)
+	}
+	return nil, fmt.Errorf("invalid discovery '%s'", spec)
+}
+
+// Ordinal returns the ordinal of the name of a pod of a StatefulSet, which
+// is its number after the last '-', or -1.
+func Ordinal(name string) int {
+	i := strings.LastIndexByte(name, '-')
+	if i == -1 {
+		return -1
+	}
+	n, err := strconv.ParseUint(name[i+1:], 10, 31)
+	if err != nil {
+		return -1
+	}
+	return int(n)
+}
+
+// sortPeers sorts by ordinal, with the peers that don't have one last, and
+// then by name.
+func sortPeers(peers []Peer) {
+	sort.SliceStable(peers, func(i, j int) bool {
+		return less(peers[i], peers[j])
+	})
+}
+
+func less(a, b Peer) bool {
+	if a.Ordinal != b.Ordinal {
+		if a.Ordinal == -1 || b.Ordinal == -1 {
+			return b.Ordinal == -1
+		}
+		return a.Ordinal < b.Ordinal
+	}
+	return a.Name < b.Name
+}
+
+// Member is a peer, and its role as it answered, which is empty when it
+// isn't reachable.
+type Member struct {
+	Peer
+	Self bool
+	Role string
+}
+
+// Leader returns the leader of the members. With election, it's the first
+// of the reachable leaders, or else the first of the reachable members, so
+// that a server that comes back follows the leader that took over. Without
+// election, it's the pod with the leader label, or else ordinal 0, whether
+// it's reachable or not.
+func Leader(members []Member, election bool) (Member, bool) {
+	var leader Member
+	var ok bool
+	pick := func(m Member) {
+		if !ok || less(m.Peer, leader.Peer) {
+			leader, ok = m, true
+		}
+	}
+	if !election {
+		for _, m := range members {
+			if m.Labels[LeaderLabel] == RoleLeader {
+				pick(m)
+			}
+		}
+		for _, m := range members {
+			if !ok && m.Ordinal == 0 {
+				pick(m)
+			}
+		}
+		return leader, ok
+	}
+	for _, m := range members {
+		if m.Role == RoleLeader {
+			pick(m)
+		}
+	}
+	if !ok {
+		for _, m := range members {
+			if m.Role != "" {
+				pick(m)
+			}
+		}
+	}
+	return leader, ok
+}
@@ -0,0 +1,158 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	d, err := Open("dns:tile38", 9851)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dns := d.(*dnsDiscoverer); dns.name != "tile38" || dns.port != 9851 {
+		t.Fatalf("unexpected %+v", dns)
+	}
+	d, err = Open("dns:tile38.default.svc:9000", 9851)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dns := d.(*dnsDiscoverer); dns.name != "tile38.default.svc" ||
+		dns.port != 9000 {
+		t.Fatalf("unexpected %+v", dns)
+	}
+	for _, spec := range []string{"", "dns:", "dns:tile38:x", "kubernetes:",
+		"consul:tile38"} {
+		if _, err := Open(spec, 9851); err == nil {
+			t.Fatalf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for name, ordinal := range map[string]int{
+		"tile38-0": 0, "tile38-12": 12, "my-tile38-3": 3,
+		"tile38": -1, "tile38-": -1, "tile38-x7": -1, "10.0.0.1": -1,
+	} {
+		if n := Ordinal(name); n != ordinal {
+			t.Fatalf("%s: expected %d, got %d", name, ordinal, n)
+		}
+	}
+}
+
+func TestDNS(t *testing.T) {
+	d := newDNS("tile38", 9851)
+	d.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"10.0.0.3", "10.0.0.1", "10.0.0.2"}, nil
+	}
+	d.lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		switch addr {
+		case "10.0.0.1":
+			return []string{"tile38-1.tile38.default.svc.cluster.local."}, nil
+		case "10.0.0.2":
+			return []string{"tile38-0.tile38.default.svc.cluster.local."}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	peers, err := d.Peers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, peer := range peers {
+		got = append(got, fmt.Sprintf("%s/%s:%d/%d", peer.Name, peer.Host,
+			peer.Port, peer.Ordinal))
+	}
+	expect := "tile38-0/10.0.0.2:9851/0 tile38-1/10.0.0.1:9851/1 " +
+		"10.0.0.3/10.0.0.3:9851/-1"
+	if strings.Join(got, " ") != expect {
+		t.Fatalf("expected '%s', got '%s'", expect, strings.Join(got, " "))
+	}
+}
+
+func TestAPI(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		query = r.URL.Path + "?" + r.URL.RawQuery
+		w.Write([]byte(`{"items":[
+			{"metadata":{"name":"tile38-1","labels":{"tile38.com/role":"leader"}},
+			 "status":{"phase":"Running","podIP":"10.0.0.1"}},
+			{"metadata":{"name":"tile38-0"},
+			 "status":{"phase":"Running","podIP":"10.0.0.2"}},
+			{"metadata":{"name":"tile38-2"},
+			 "status":{"phase":"Pending"}},
+			{"metadata":{"name":"tile38-3","deletionTimestamp":"2020-01-01T00:00:00Z"},
+			 "status":{"phase":"Running","podIP":"10.0.0.4"}}
+		]}`))
+	}))
+	defer ts.Close()
+	d := &apiDiscoverer{endpoint: ts.URL, namespace: "default",
+		selector: "app=tile38,tier!=cache", port: 9851, client: ts.Client()}
+	peers, err := d.Peers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "/api/v1/namespaces/default/pods?labelSelector="+
+		"app%3Dtile38%2Ctier%21%3Dcache" {
+		t.Fatalf("unexpected query %s", query)
+	}
+	if len(peers) != 2 || peers[0].Name != "tile38-0" ||
+		peers[1].Host != "10.0.0.1" ||
+		peers[1].Labels[LeaderLabel] != RoleLeader {
+		t.Fatalf("unexpected peers %+v", peers)
+	}
+}
+
+func member(name, role string, self bool) Member {
+	return Member{Peer: Peer{Name: name, Ordinal: Ordinal(name)},
+		Role: role, Self: self}
+}
+
+func TestLeader(t *testing.T) {
+	tests := []struct {
+		members  []Member
+		election bool
+		leader   string
+	}{
+		// the first pod, reachable or not
+		{[]Member{member("t-1", "", true), member("t-0", "", false)},
+			false, "t-0"},
+		{[]Member{member("t-1", "", true), member("t-2", "", false)},
+			false, ""},
+		// the first of the reachable members
+		{[]Member{member("t-2", RoleCandidate, true),
+			member("t-0", "", false), member("t-1", RoleFollower, false)},
+			true, "t-1"},
+		// the leader that took over, over the first pod that came back
+		{[]Member{member("t-0", RoleCandidate, true),
+			member("t-1", RoleFollower, false), member("t-2", RoleLeader, false)},
+			true, "t-2"},
+		// the first of two leaders, after a partition
+		{[]Member{member("t-2", RoleLeader, true),
+			member("t-1", RoleLeader, false)}, true, "t-1"},
+		// the pods without an ordinal are last
+		{[]Member{member("b", RoleCandidate, true),
+			member("a", RoleCandidate, false)}, true, "a"},
+		{[]Member{member("a", RoleCandidate, true),
+			member("t-3", RoleCandidate, false)}, true, "t-3"},
+		{[]Member{member("t-0", "", false)}, true, ""},
+	}
+	for i, tt := range tests {
+		leader, ok := Leader(tt.members, tt.election)
+		if (tt.leader == "") == ok || leader.Name != tt.leader {
+			t.Fatalf("%d: expected '%s', got '%s'", i, tt.leader, leader.Name)
+		}
+	}
+	labeled := member("t-2", "", false)
+	labeled.Labels = map[string]string{LeaderLabel: RoleLeader}
+	leader, _ := Leader([]Member{member("t-0", "", true), labeled}, false)
+	if leader.Name != "t-2" {
+		t.Fatalf("expected 't-2', got '%s'", leader.Name)
+	}
+}
@@ -0,0 +1,153 @@
+// Package layer provides read-only reference layers, such as countries,
+// timezones, and zip codes, for finding the areas that contain a position.
+package layer
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/rtree"
+)
+
+// Ext is the file extension of layer files.
+const Ext = ".geojson"
+
+// Area is a feature of a layer.
+type Area struct {
+	ID         string // feature id
+	Properties string // feature properties json, or empty
+	obj        geojson.Object
+}
+
+// Layer is a read-only set of areas that is indexed by their bounds. It's
+// safe to use from many goroutines.
+type Layer struct {
+	name  string
+	areas []*Area
+	tr    rtree.RTree
+	size  int // bytes of json
+}
+
+// Load reads a layer from a GeoJSON file. The layer is named after the file,
+// without the extension.
+func Load(path string, opts *geojson.ParseOptions) (*Layer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return Parse(name, data, opts)
+}
+
+// LoadDir reads the layers of all of the ".geojson" files in a directory.
+func LoadDir(dir string, opts *geojson.ParseOptions) ([]*Layer, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+Ext))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var layers []*Layer
+	for _, path := range paths {
+		l, err := Load(path, opts)
+		if err != nil {
+			return nil, errors.New(filepath.Base(path) + ": " + err.Error())
+		}
+		layers = append(layers, l)
+	}
+	return layers, nil
+}
+
+// Parse reads a layer from a GeoJSON FeatureCollection. Each Polygon or
+// MultiPolygon feature is an area, which is identified by the "id" member
+// of the feature, or by the "id" or "name" property when there is no such
+// member, or else by its position in the collection. Other features are
+// ignored.
+func Parse(name string, data []byte, opts *geojson.ParseOptions) (*Layer, error) {
+	if !gjson.ValidBytes(data) {
+		return nil, errors.New("invalid layer json")
+	}
+	json := gjson.ParseBytes(data)
+	if json.Get("type").String() != "FeatureCollection" {
+		return nil, errors.New("layer is not a FeatureCollection")
+	}
+	l := &Layer{name: name, size: len(data)}
+	var err error
+	var i int
+	json.Get("features").ForEach(func(_, feature gjson.Result) bool {
+		defer func() { i++ }()
+		geom := feature.Get("geometry")
+		switch geom.Get("type").String() {
+		case "Polygon", "MultiPolygon":
+		default:
+			return true
+		}
+		var obj geojson.Object
+		if obj, err = geojson.Parse(geom.Raw, opts); err != nil {
+			return false
+		}
+		id := feature.Get("id")
+		if !id.Exists() {
+			id = feature.Get("properties.id")
+		}
+		if !id.Exists() {
+			id = feature.Get("properties.name")
+		}
+		area := &Area{ID: id.String(), obj: obj}
+		if !id.Exists() {
+			area.ID = strconv.Itoa(i)
+		}
+		if props := feature.Get("properties"); props.IsObject() {
+			area.Properties = props.Raw
+		}
+		rect := obj.Rect()
+		l.tr.Insert(
+			[2]float64{rect.Min.X, rect.Min.Y},
+			[2]float64{rect.Max.X, rect.Max.Y},
+			area)
+		l.areas = append(l.areas, area)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Name returns the name of the layer.
+func (l *Layer) Name() string {
+	return l.name
+}
+
+// Len returns the number of areas.
+func (l *Layer) Len() int {
+	return len(l.areas)
+}
+
+// Size returns the size of the GeoJSON that the layer was read from, which
+// is an estimate of its memory use.
+func (l *Layer) Size() int {
+	return l.size
+}
+
+// Lookup returns the areas that contain the point, sorted by id.
+func (l *Layer) Lookup(p geometry.Point) []*Area {
+	var areas []*Area
+	l.tr.Search([2]float64{p.X, p.Y}, [2]float64{p.X, p.Y},
+		func(_, _ [2]float64, value interface{}) bool {
+			area := value.(*Area)
+			if area.obj.Spatial().IntersectsPoint(p) {
+				areas = append(areas, area)
+			}
+			return true
+		},
+	)
+	sort.Slice(areas, func(i, j int) bool { return areas[i].ID < areas[j].ID })
+	return areas
+}
@@ -0,0 +1,91 @@
+package layer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tidwall/geojson/geometry"
+)
+
+const zones = `{"type":"FeatureCollection","features":[
+	{"type":"Feature","id":"west","properties":{"tz":"-7"},
+	 "geometry":{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]]]}},
+	{"type":"Feature","properties":{"name":"east"},
+	 "geometry":{"type":"Polygon","coordinates":[[[10,0],[20,0],[20,10],[10,10],[10,0]]]}},
+	{"type":"Feature","properties":{},
+	 "geometry":{"type":"MultiPolygon","coordinates":[[[[5,5],[15,5],[15,6],[5,6],[5,5]]]]}},
+	{"type":"Feature","properties":{"id":"ignored"},
+	 "geometry":{"type":"Point","coordinates":[1,1]}}
+]}`
+
+func ids(areas []*Area) []string {
+	var ids []string
+	for _, area := range areas {
+		ids = append(ids, area.ID)
+	}
+	return ids
+}
+
+func TestLayer(t *testing.T) {
+	l, err := Parse("zones", []byte(zones), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Name() != "zones" || l.Len() != 3 {
+		t.Fatalf("expected zones with 3 areas, got %s %d", l.Name(), l.Len())
+	}
+	for _, tc := range []struct {
+		p   geometry.Point
+		ids string
+	}{
+		{geometry.Point{X: 1, Y: 1}, "[west]"},
+		{geometry.Point{X: 15, Y: 1}, "[east]"},
+		{geometry.Point{X: 12, Y: 5.5}, "[2 east]"},
+		{geometry.Point{X: 30, Y: 30}, "[]"},
+	} {
+		got := ids(l.Lookup(tc.p))
+		if s := "[" + join(got) + "]"; s != tc.ids {
+			t.Fatalf("%v: expected %s, got %s", tc.p, tc.ids, s)
+		}
+	}
+	if areas := l.Lookup(geometry.Point{X: 1, Y: 1}); areas[0].Properties != `{"tz":"-7"}` {
+		t.Fatalf("unexpected properties %s", areas[0].Properties)
+	}
+	if _, err := Parse("x", []byte(`{"type":"Feature"}`), nil); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "tz.geojson"), []byte(zones), 0666)
+	ioutil.WriteFile(filepath.Join(dir, "readme.txt"), []byte("hi"), 0666)
+	layers, err := LoadDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 || layers[0].Name() != "tz" {
+		t.Fatalf("expected the tz layer, got %v", layers)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "bad.geojson"), []byte("{"), 0666)
+	if _, err := LoadDir(dir, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func join(ss []string) string {
+	var s string
+	for i, v := range ss {
+		if i > 0 {
+			s += " "
+		}
+		s += v
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+// Package readthrough loads the objects that a server doesn't have from an
+// authoritative store, so that the server can be a cache in front of it.
+package readthrough
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// Object is an object of the store.
+type Object struct {
+	Object json.RawMessage    `json:"object"` // GeoJSON
+	Fields map[string]float64 `json:"fields,omitempty"`
+}
+
+// Loader is an authoritative store.
+type Loader interface {
+	// Load returns the object of an id, or nil when the store doesn't have
+	// it.
+	Load(ctx context.Context, key, id string) (*Object, error)
+}
+
+// Open returns the loader of a url:
+//
+//	http://host/path, https://host/path
+func Open(rawurl string) (Loader, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &httpLoader{url: u}, nil
+	}
+	return nil, errors.New("unsupported read-through url")
+}
+
+// httpLoader gets the object from a service, with the key and the id in the
+// query of the url. The service replies with the JSON of the Object, or with
+// 404 Not Found.
+type httpLoader struct {
+	url *url.URL
+}
+
+func (l *httpLoader) Load(ctx context.Context, key, id string) (*Object, error) {
+	u := *l.url
+	q := u.Query()
+	q.Set("key", key)
+	q.Set("id", id)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<24))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s: %s %s", u.Host, resp.Status,
+			bytes.TrimSpace(data))
+	}
+	var obj Object
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	if len(obj.Object) == 0 {
+		return nil, fmt.Errorf("%s: missing object", u.Host)
+	}
+	return &obj, nil
+}
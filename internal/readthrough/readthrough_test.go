@@ -0,0 +1,63 @@
+package readthrough
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	if _, err := Open("http://localhost:8080/load?token=x"); err != nil {
+		t.Fatal(err)
+	}
+	for _, rawurl := range []string{"", "grpc://localhost:9000", "::"} {
+		if _, err := Open(rawurl); err == nil {
+			t.Fatalf("%s: expected an error", rawurl)
+		}
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		query = r.URL.RawQuery
+		switch r.URL.Query().Get("id") {
+		case "truck1":
+			w.Write([]byte(`{"object":{"type":"Point","coordinates":[-115,33]},` +
+				`"fields":{"speed":10}}`))
+		case "truck2":
+			http.NotFound(w, r)
+		case "truck3":
+			w.Write([]byte(`{"fields":{"speed":10}}`))
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	l, err := Open(ts.URL + "/load?token=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	obj, err := l.Load(ctx, "fleet", "truck1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "id=truck1&key=fleet&token=x" {
+		t.Fatalf("unexpected query %s", query)
+	}
+	if string(obj.Object) != `{"type":"Point","coordinates":[-115,33]}` ||
+		obj.Fields["speed"] != 10 {
+		t.Fatalf("unexpected object %+v", obj)
+	}
+	if obj, err := l.Load(ctx, "fleet", "truck2"); obj != nil || err != nil {
+		t.Fatalf("expected nil, got %v, %v", obj, err)
+	}
+	for _, id := range []string{"truck3", "truck4"} {
+		if _, err := l.Load(ctx, "fleet", id); err == nil {
+			t.Fatalf("%s: expected an error", id)
+		}
+	}
+}
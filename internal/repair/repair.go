@@ -0,0 +1,174 @@
+// Package repair fixes the common problems of user drawn polygons, such as
+// rings that are not closed, repeated vertices, the wrong winding order,
+// and rings that cross themselves.
+package repair
+
+import (
+	"strconv"
+
+	"github.com/tidwall/gjson"
+)
+
+// Report counts the repairs that were made.
+type Report struct {
+	Duplicates int // repeated vertices that were removed
+	Closed     int // rings that were closed
+	Reoriented int // rings that had their winding order reversed
+	Split      int // self-intersections that were split apart
+	Dropped    int // degenerate rings that were removed
+}
+
+// Repaired returns true when any repair was made.
+func (r Report) Repaired() bool {
+	return r != Report{}
+}
+
+// Add adds the counts of another report.
+func (r *Report) Add(other Report) {
+	r.Duplicates += other.Duplicates
+	r.Closed += other.Closed
+	r.Reoriented += other.Reoriented
+	r.Split += other.Split
+	r.Dropped += other.Dropped
+}
+
+// JSON returns the GeoJSON with its Polygons and MultiPolygons repaired.
+// The exterior rings are counterclockwise and the holes are clockwise, as
+// required by RFC 7946. A Polygon that has a crossing exterior ring becomes
+// a MultiPolygon. All other members are copied as-is.
+func JSON(json []byte) ([]byte, Report) {
+	var report Report
+	out := appendObject(nil, gjson.ParseBytes(json), &report)
+	return out, report
+}
+
+func appendObject(dst []byte, obj gjson.Result, report *Report) []byte {
+	switch {
+	case obj.IsArray():
+		dst = append(dst, '[')
+		var i int
+		obj.ForEach(func(_, value gjson.Result) bool {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendObject(dst, value, report)
+			i++
+			return true
+		})
+		return append(dst, ']')
+	case !obj.IsObject():
+		return append(dst, obj.Raw...)
+	}
+	typ := obj.Get("type").String()
+	var polys [][][]position
+	switch typ {
+	case "Polygon":
+		polys = repairPolygon(parseRings(obj.Get("coordinates")), report)
+	case "MultiPolygon":
+		obj.Get("coordinates").ForEach(func(_, poly gjson.Result) bool {
+			polys = append(polys, repairPolygon(parseRings(poly), report)...)
+			return true
+		})
+	}
+	dst = append(dst, '{')
+	var i int
+	obj.ForEach(func(key, value gjson.Result) bool {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		i++
+		dst = append(dst, key.Raw...)
+		dst = append(dst, ':')
+		switch key.String() {
+		case "type":
+			if typ == "Polygon" && len(polys) != 1 {
+				dst = append(dst, `"MultiPolygon"`...)
+			} else {
+				dst = append(dst, value.Raw...)
+			}
+		case "coordinates":
+			switch typ {
+			case "Polygon":
+				if len(polys) == 1 {
+					dst = appendPolygon(dst, polys[0])
+				} else {
+					dst = appendMultiPolygon(dst, polys)
+				}
+			case "MultiPolygon":
+				dst = appendMultiPolygon(dst, polys)
+			default:
+				dst = append(dst, value.Raw...)
+			}
+		case "geometry", "geometries", "features":
+			dst = appendObject(dst, value, report)
+		default:
+			dst = append(dst, value.Raw...)
+		}
+		return true
+	})
+	return append(dst, '}')
+}
+
+func parseRings(coords gjson.Result) [][]position {
+	var rings [][]position
+	coords.ForEach(func(_, ring gjson.Result) bool {
+		var ps []position
+		ring.ForEach(func(_, value gjson.Result) bool {
+			values := value.Array()
+			if len(values) < 2 {
+				return true
+			}
+			p := position{x: values[0].Float(), y: values[1].Float()}
+			for _, v := range values[2:] {
+				p.extra = append(p.extra, v.Float())
+			}
+			ps = append(ps, p)
+			return true
+		})
+		rings = append(rings, ps)
+		return true
+	})
+	return rings
+}
+
+func appendRing(dst []byte, ring []position) []byte {
+	dst = append(dst, '[')
+	for i := 0; i <= len(ring); i++ {
+		p := ring[i%len(ring)]
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, '[')
+		dst = strconv.AppendFloat(dst, p.x, 'f', -1, 64)
+		dst = append(dst, ',')
+		dst = strconv.AppendFloat(dst, p.y, 'f', -1, 64)
+		for _, v := range p.extra {
+			dst = append(dst, ',')
+			dst = strconv.AppendFloat(dst, v, 'f', -1, 64)
+		}
+		dst = append(dst, ']')
+	}
+	return append(dst, ']')
+}
+
+func appendPolygon(dst []byte, poly [][]position) []byte {
+	dst = append(dst, '[')
+	for i, ring := range poly {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendRing(dst, ring)
+	}
+	return append(dst, ']')
+}
+
+func appendMultiPolygon(dst []byte, polys [][][]position) []byte {
+	dst = append(dst, '[')
+	for i, poly := range polys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendPolygon(dst, poly)
+	}
+	return append(dst, ']')
+}
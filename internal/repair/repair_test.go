@@ -0,0 +1,58 @@
+package repair
+
+import "testing"
+
+func TestJSON(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+		report  Report
+	}{
+		{
+			// valid polygons are unchanged
+			`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}`,
+			`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}`,
+			Report{},
+		},
+		{
+			// clockwise exterior with a repeated vertex, not closed
+			`{"type":"Polygon","coordinates":[[[0,0],[0,1],[0,1],[1,1],[1,0]]]}`,
+			`{"type":"Polygon","coordinates":[[[1,0],[1,1],[0,1],[0,0],[1,0]]]}`,
+			Report{Duplicates: 1, Closed: 1, Reoriented: 1},
+		},
+		{
+			// counterclockwise hole
+			`{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,4],[0,0]],` +
+				`[[1,1],[2,1],[2,2],[1,1]]]}`,
+			`{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,4],[0,0]],` +
+				`[[2,2],[2,1],[1,1],[2,2]]]}`,
+			Report{Reoriented: 1},
+		},
+		{
+			// bowtie
+			`{"type":"Polygon","coordinates":[[[0,0],[2,2],[2,0],[0,2],[0,0]]]}`,
+			`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,1],[0,2],[0,0]]],` +
+				`[[[2,0],[2,2],[1,1],[2,0]]]]}`,
+			Report{Split: 1, Reoriented: 1},
+		},
+		{
+			// degenerate exterior, with extra coordinates kept
+			`{"type":"Feature","geometry":{"type":"MultiPolygon","coordinates":` +
+				`[[[[0,0,5],[1,1,5],[0,0,5]]],[[[0,0,1],[1,0,2],[1,1,3],[0,0,1]]]]},` +
+				`"properties":{"a":1}}`,
+			`{"type":"Feature","geometry":{"type":"MultiPolygon","coordinates":` +
+				`[[[[0,0,1],[1,0,2],[1,1,3],[0,0,1]]]]},"properties":{"a":1}}`,
+			Report{Dropped: 1},
+		},
+	} {
+		out, report := JSON([]byte(tc.in))
+		if string(out) != tc.out {
+			t.Fatalf("expected %s, got %s", tc.out, out)
+		}
+		if report != tc.report {
+			t.Fatalf("%s: expected %+v, got %+v", tc.in, tc.report, report)
+		}
+		if report.Repaired() != (tc.report != Report{}) {
+			t.Fatalf("%s: unexpected Repaired", tc.in)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package repair
+
+// position is a vertex with its extra coordinates, such as Z and M.
+type position struct {
+	x, y  float64
+	extra []float64
+}
+
+func (p position) equals(q position) bool {
+	return p.x == q.x && p.y == q.y
+}
+
+// repairPolygon returns the polygons of the rings of a polygon, where the
+// first ring is the exterior and the others are holes. There are no
+// polygons when the exterior is degenerate, and more than one when the
+// exterior crosses itself.
+func repairPolygon(rings [][]position, report *Report) [][][]position {
+	if len(rings) == 0 {
+		return nil
+	}
+	var polys [][][]position
+	for _, ring := range repairRing(rings[0], true, report) {
+		polys = append(polys, [][]position{ring})
+	}
+	for _, hole := range rings[1:] {
+		for _, ring := range repairRing(hole, false, report) {
+			// the hole belongs to the exterior that contains it
+			var found bool
+			for i, poly := range polys {
+				if len(polys) == 1 || inside(ring, poly[0]) {
+					polys[i] = append(poly, ring)
+					found = true
+					break
+				}
+			}
+			if !found {
+				report.Dropped++
+			}
+		}
+	}
+	return polys
+}
+
+// repairRing returns the simple rings of a ring, without the closing
+// vertex. Exteriors are counterclockwise and holes are clockwise.
+func repairRing(ring []position, exterior bool, report *Report) [][]position {
+	if len(ring) > 0 && !ring[0].equals(ring[len(ring)-1]) {
+		report.Closed++
+	} else if len(ring) > 0 {
+		ring = ring[:len(ring)-1]
+	}
+	ring = dedupe(ring, report)
+	var rings [][]position
+	for _, ring := range split(ring, report) {
+		ring = dedupe(ring, nil)
+		area := signedArea(ring)
+		if len(ring) < 3 || area == 0 {
+			report.Dropped++
+			continue
+		}
+		if (area > 0) != exterior {
+			reverse(ring)
+			report.Reoriented++
+		}
+		rings = append(rings, ring)
+	}
+	return rings
+}
+
+// dedupe removes the repeated vertices of an open ring.
+func dedupe(ring []position, report *Report) []position {
+	out := ring[:0:0]
+	for _, p := range ring {
+		if len(out) > 0 && out[len(out)-1].equals(p) {
+			if report != nil {
+				report.Duplicates++
+			}
+			continue
+		}
+		out = append(out, p)
+	}
+	for len(out) > 1 && out[0].equals(out[len(out)-1]) {
+		out = out[:len(out)-1]
+		if report != nil {
+			report.Duplicates++
+		}
+	}
+	return out
+}
+
+// split splits an open ring at its first self-intersection, and then splits
+// the two parts until none of them cross themselves.
+func split(ring []position, report *Report) [][]position {
+	n := len(ring)
+	for i := 0; i < n; i++ {
+		a, b := ring[i], ring[(i+1)%n]
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				// adjacent through the closing segment
+				continue
+			}
+			c, d := ring[j], ring[(j+1)%n]
+			x, ok := intersection(a, b, c, d)
+			if !ok {
+				continue
+			}
+			report.Split++
+			first := append(append(append([]position{}, ring[:i+1]...), x),
+				ring[j+1:]...)
+			second := append([]position{x}, ring[i+1:j+1]...)
+			return append(split(dedupe(first, nil), report),
+				split(dedupe(second, nil), report)...)
+		}
+	}
+	return [][]position{ring}
+}
+
+// intersection returns the point where the segments a-b and c-d cross. The
+// extra coordinates are interpolated along a-b. Collinear segments don't
+// cross.
+func intersection(a, b, c, d position) (position, bool) {
+	rx, ry := b.x-a.x, b.y-a.y
+	sx, sy := d.x-c.x, d.y-c.y
+	denom := rx*sy - ry*sx
+	if denom == 0 {
+		return position{}, false
+	}
+	qx, qy := c.x-a.x, c.y-a.y
+	t := (qx*sy - qy*sx) / denom
+	u := (qx*ry - qy*rx) / denom
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return position{}, false
+	}
+	p := position{x: a.x + t*rx, y: a.y + t*ry}
+	if len(a.extra) > 0 {
+		p.extra = make([]float64, len(a.extra))
+		for i, v := range a.extra {
+			if i < len(b.extra) {
+				v += (b.extra[i] - v) * t
+			}
+			p.extra[i] = v
+		}
+	}
+	return p, true
+}
+
+// signedArea returns the area of an open ring, which is positive when the
+// ring is counterclockwise.
+func signedArea(ring []position) float64 {
+	var area float64
+	for i, p := range ring {
+		q := ring[(i+1)%len(ring)]
+		area += p.x*q.y - q.x*p.y
+	}
+	return area / 2
+}
+
+func reverse(ring []position) {
+	for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+		ring[i], ring[j] = ring[j], ring[i]
+	}
+}
+
+// inside returns true when the first vertex of a ring is inside of another
+// ring.
+func inside(ring, other []position) bool {
+	p := ring[0]
+	var in bool
+	for i, a := range other {
+		b := other[(i+1)%len(other)]
+		if (a.y > p.y) != (b.y > p.y) &&
+			p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			in = !in
+		}
+	}
+	return in
+}
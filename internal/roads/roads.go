@@ -0,0 +1,172 @@
+// Package roads snaps positions to the nearest segment of a road network.
+package roads
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+
+	"github.com/tidwall/geojson/geo"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/rtree"
+)
+
+// metersPerDegree is the approximate length of one degree of latitude.
+const metersPerDegree = 111320.0
+
+// segment is a straight piece of a road.
+type segment struct {
+	road string
+	a, b geometry.Point
+}
+
+// Network is a read-only set of roads that are indexed by their segments.
+type Network struct {
+	tr       rtree.RTree
+	roads    int
+	segments int
+}
+
+// Match is the nearest position on a road.
+type Match struct {
+	Road     string         // road id
+	Point    geometry.Point // position on the road
+	Distance float64        // meters from the original position
+}
+
+// Load reads a road network from a GeoJSON file.
+func Load(path string) (*Network, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse reads a road network from a GeoJSON FeatureCollection, such as an
+// export of OSM ways. Each LineString or MultiLineString feature is a road,
+// which is identified by the "id" member of the feature, or by the "id"
+// property when there is no such member. Other features are ignored.
+func Parse(data []byte) (*Network, error) {
+	if !gjson.ValidBytes(data) {
+		return nil, errors.New("invalid road network json")
+	}
+	json := gjson.ParseBytes(data)
+	if json.Get("type").String() != "FeatureCollection" {
+		return nil, errors.New("road network is not a FeatureCollection")
+	}
+	n := new(Network)
+	var err error
+	json.Get("features").ForEach(func(_, feature gjson.Result) bool {
+		road := feature.Get("id")
+		if !road.Exists() {
+			road = feature.Get("properties.id")
+		}
+		if !road.Exists() || road.String() == "" {
+			err = errors.New("road without an id in road network")
+			return false
+		}
+		geom := feature.Get("geometry")
+		switch geom.Get("type").String() {
+		case "LineString":
+			n.addLine(road.String(), geom.Get("coordinates"))
+		case "MultiLineString":
+			geom.Get("coordinates").ForEach(func(_, line gjson.Result) bool {
+				n.addLine(road.String(), line)
+				return true
+			})
+		default:
+			return true
+		}
+		n.roads++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *Network) addLine(road string, coords gjson.Result) {
+	var prev geometry.Point
+	var count int
+	coords.ForEach(func(_, coord gjson.Result) bool {
+		xy := coord.Array()
+		if len(xy) < 2 {
+			return true
+		}
+		p := geometry.Point{X: xy[0].Float(), Y: xy[1].Float()}
+		if count > 0 && p != prev {
+			seg := &segment{road: road, a: prev, b: p}
+			n.tr.Insert(
+				[2]float64{math.Min(prev.X, p.X), math.Min(prev.Y, p.Y)},
+				[2]float64{math.Max(prev.X, p.X), math.Max(prev.Y, p.Y)},
+				seg)
+			n.segments++
+		}
+		prev = p
+		count++
+		return true
+	})
+}
+
+// Roads returns the number of roads.
+func (n *Network) Roads() int {
+	return n.roads
+}
+
+// Segments returns the number of road segments.
+func (n *Network) Segments() int {
+	return n.segments
+}
+
+// Match returns the nearest position on a road that is no more than meters
+// away from the point. Returns false when there's no such road.
+func (n *Network) Match(p geometry.Point, meters float64) (Match, bool) {
+	dlat := meters / metersPerDegree
+	dlon := dlat / math.Max(math.Cos(p.Y*math.Pi/180), 0.01)
+	var best Match
+	var found bool
+	n.tr.Search(
+		[2]float64{p.X - dlon, p.Y - dlat},
+		[2]float64{p.X + dlon, p.Y + dlat},
+		func(_, _ [2]float64, value interface{}) bool {
+			seg := value.(*segment)
+			q := project(p, seg.a, seg.b)
+			dist := geo.DistanceTo(p.Y, p.X, q.Y, q.X)
+			if dist > meters {
+				return true
+			}
+			if !found || dist < best.Distance ||
+				(dist == best.Distance && seg.road < best.Road) {
+				best = Match{Road: seg.road, Point: q, Distance: dist}
+				found = true
+			}
+			return true
+		},
+	)
+	return best, found
+}
+
+// project returns the point on the segment a-b that is nearest to p. The
+// longitudes are scaled by the cosine of the latitude, which is accurate
+// enough for the short distances between a position and a road.
+func project(p, a, b geometry.Point) geometry.Point {
+	k := math.Cos(p.Y * math.Pi / 180)
+	ax, ay := (a.X-p.X)*k, a.Y-p.Y
+	bx, by := (b.X-p.X)*k, b.Y-p.Y
+	dx, dy := bx-ax, by-ay
+	l := dx*dx + dy*dy
+	if l == 0 {
+		return a
+	}
+	t := -(ax*dx + ay*dy) / l
+	if t <= 0 {
+		return a
+	}
+	if t >= 1 {
+		return b
+	}
+	return geometry.Point{X: a.X + (b.X-a.X)*t, Y: a.Y + (b.Y-a.Y)*t}
+}
@@ -0,0 +1,68 @@
+package roads
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tidwall/geojson/geometry"
+)
+
+const testNetwork = `{"type":"FeatureCollection","features":[
+	{"type":"Feature","id":"main","properties":{},"geometry":{
+		"type":"LineString","coordinates":[[0,0],[0.01,0],[0.01,0.01]]}},
+	{"type":"Feature","properties":{"id":42},"geometry":{
+		"type":"MultiLineString","coordinates":[[[0,0.002],[0.01,0.002]]]}},
+	{"type":"Feature","id":"stop","geometry":{
+		"type":"Point","coordinates":[0,0]}}
+]}`
+
+func TestParse(t *testing.T) {
+	n, err := Parse([]byte(testNetwork))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Roads() != 2 || n.Segments() != 3 {
+		t.Fatalf("expected 2 roads and 3 segments, got %d and %d",
+			n.Roads(), n.Segments())
+	}
+	for _, data := range []string{
+		`{`,
+		`{"type":"Feature"}`,
+		`{"type":"FeatureCollection","features":[{"type":"Feature",` +
+			`"geometry":{"type":"LineString","coordinates":[[0,0],[1,1]]}}]}`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Fatalf("expected error for %s", data)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	n, err := Parse([]byte(testNetwork))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := n.Match(geometry.Point{X: 0.005, Y: 0.0005}, 100)
+	if !ok || m.Road != "main" {
+		t.Fatalf("expected main, got %v %v", m, ok)
+	}
+	if math.Abs(m.Point.X-0.005) > 1e-9 || m.Point.Y != 0 {
+		t.Fatalf("expected 0.005 0, got %v", m.Point)
+	}
+	if math.Abs(m.Distance-55.66) > 0.1 {
+		t.Fatalf("expected about 55.66 meters, got %v", m.Distance)
+	}
+	m, ok = n.Match(geometry.Point{X: 0.005, Y: 0.0015}, 100)
+	if !ok || m.Road != "42" {
+		t.Fatalf("expected 42, got %v %v", m, ok)
+	}
+	m, ok = n.Match(geometry.Point{X: 0.02, Y: 0.005}, 100)
+	if ok {
+		t.Fatalf("expected no match, got %v", m)
+	}
+	m, ok = n.Match(geometry.Point{X: 0.0105, Y: 0.012}, 500)
+	if !ok || m.Road != "main" ||
+		m.Point != (geometry.Point{X: 0.01, Y: 0.01}) {
+		t.Fatalf("expected end of main, got %v %v", m, ok)
+	}
+}
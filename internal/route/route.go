@@ -0,0 +1,111 @@
+// Package route measures distances along a route, which is a series of
+// positions that are connected by great-circle segments.
+package route
+
+import (
+	"math"
+
+	"github.com/tidwall/geojson/geo"
+	"github.com/tidwall/geojson/geometry"
+)
+
+// Route is a series of positions.
+type Route struct {
+	points []geometry.Point
+	dists  []float64 // distance to each point from the start, meters
+}
+
+// New returns the route through the points.
+func New(points []geometry.Point) *Route {
+	r := &Route{points: points, dists: make([]float64, len(points))}
+	for i := 1; i < len(points); i++ {
+		r.dists[i] = r.dists[i-1] + Distance(points[i-1], points[i])
+	}
+	return r
+}
+
+// Length returns the length of the route, in meters.
+func (r *Route) Length() float64 {
+	if len(r.dists) == 0 {
+		return 0
+	}
+	return r.dists[len(r.dists)-1]
+}
+
+// Interpolate returns the point at a distance from the start of the route.
+// Distances past the ends of the route return the end points.
+func (r *Route) Interpolate(meters float64) geometry.Point {
+	if len(r.points) == 0 {
+		return geometry.Point{}
+	}
+	if meters <= 0 {
+		return r.points[0]
+	}
+	for i := 1; i < len(r.points); i++ {
+		if meters < r.dists[i] {
+			return Intermediate(r.points[i-1], r.points[i],
+				meters-r.dists[i-1])
+		}
+	}
+	return r.points[len(r.points)-1]
+}
+
+// Locate returns the point of the route that is nearest to p, its distance
+// from the start of the route, and its distance from p.
+func (r *Route) Locate(p geometry.Point) (at geometry.Point, along,
+	offset float64,
+) {
+	switch len(r.points) {
+	case 0:
+		return p, 0, 0
+	case 1:
+		return r.points[0], 0, Distance(p, r.points[0])
+	}
+	offset = math.Inf(1)
+	for i := 1; i < len(r.points); i++ {
+		a, b := r.points[i-1], r.points[i]
+		seg := r.dists[i] - r.dists[i-1]
+		t := project(p, a, b)
+		q := Intermediate(a, b, t*seg)
+		if dist := Distance(p, q); dist < offset {
+			at, along, offset = q, r.dists[i-1]+t*seg, dist
+		}
+	}
+	return at, along, offset
+}
+
+// Distance returns the great-circle distance between two points, in meters.
+func Distance(a, b geometry.Point) float64 {
+	return geo.DistanceTo(a.Y, a.X, b.Y, b.X)
+}
+
+// Bearing returns the initial bearing from a to b, in degrees clockwise
+// from north.
+func Bearing(a, b geometry.Point) float64 {
+	return geo.BearingTo(a.Y, a.X, b.Y, b.X)
+}
+
+// Intermediate returns the point on the great circle from a to b at a
+// distance from a.
+func Intermediate(a, b geometry.Point, meters float64) geometry.Point {
+	if meters <= 0 || a == b {
+		return a
+	}
+	lat, lon := geo.DestinationPoint(a.Y, a.X, meters, Bearing(a, b))
+	return geometry.Point{X: lon, Y: lat}
+}
+
+// project returns the fraction of the segment a-b where the point nearest
+// to p is. The longitudes are scaled by the cosine of the latitude, which
+// is accurate enough for segments of a few kilometers.
+func project(p, a, b geometry.Point) float64 {
+	k := math.Cos(p.Y * math.Pi / 180)
+	ax, ay := (a.X-p.X)*k, a.Y-p.Y
+	bx, by := (b.X-p.X)*k, b.Y-p.Y
+	dx, dy := bx-ax, by-ay
+	l := dx*dx + dy*dy
+	if l == 0 {
+		return 0
+	}
+	return math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+}
@@ -0,0 +1,61 @@
+package route
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tidwall/geojson/geometry"
+)
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestRoute(t *testing.T) {
+	// two segments along the equator of about 111 km each
+	r := New([]geometry.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 0}})
+	seg := Distance(geometry.Point{X: 0, Y: 0}, geometry.Point{X: 1, Y: 0})
+	if !near(r.Length(), seg*2, 1e-6) {
+		t.Fatalf("expected %v, got %v", seg*2, r.Length())
+	}
+	for _, tc := range []struct {
+		meters float64
+		x      float64
+	}{
+		{-1, 0}, {0, 0}, {seg / 2, 0.5}, {seg, 1}, {seg * 1.5, 1.5},
+		{seg * 2, 2}, {seg * 3, 2},
+	} {
+		p := r.Interpolate(tc.meters)
+		if !near(p.X, tc.x, 1e-9) || !near(p.Y, 0, 1e-9) {
+			t.Fatalf("%v: expected %v 0, got %v", tc.meters, tc.x, p)
+		}
+	}
+	at, along, offset := r.Locate(geometry.Point{X: 1.5, Y: 0.01})
+	if !near(at.X, 1.5, 1e-6) || !near(along, seg*1.5, 1) {
+		t.Fatalf("unexpected %v %v", at, along)
+	}
+	if !near(offset, 1111.95, 0.01) {
+		t.Fatalf("expected 1111.95, got %v", offset)
+	}
+	at, along, _ = r.Locate(geometry.Point{X: -1, Y: 0})
+	if at.X != 0 || along != 0 {
+		t.Fatalf("expected the start, got %v %v", at, along)
+	}
+}
+
+func TestBearing(t *testing.T) {
+	a := geometry.Point{X: 0, Y: 0}
+	for _, tc := range []struct {
+		b       geometry.Point
+		bearing float64
+	}{
+		{geometry.Point{X: 0, Y: 1}, 0},
+		{geometry.Point{X: 1, Y: 0}, 90},
+		{geometry.Point{X: 0, Y: -1}, 180},
+		{geometry.Point{X: -1, Y: 0}, 270},
+	} {
+		if got := Bearing(a, tc.b); !near(got, tc.bearing, 1e-9) {
+			t.Fatalf("%v: expected %v, got %v", tc.b, tc.bearing, got)
+		}
+	}
+}
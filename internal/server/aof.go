@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math"
 	"net"
 	"os"
 	"sort"
@@ -186,6 +185,19 @@ func (s *Server) writeAOF(args []string, d *commandDetails) error {
 
 	// process geofences
 	if d != nil {
+		// the versions of the objects, see history.go
+		if len(s.histories) > 0 {
+			s.recordHistory(d)
+		}
+		// the moving objects, see fleet.go
+		if len(s.fleets) > 0 {
+			s.recordFleet(d)
+		}
+		// the external stores, see writebehind.go
+		if len(s.writeBehinds) > 0 && s.config.followHost() == "" {
+			s.recordWriteBehind(d)
+		}
+
 		// webhook geofences
 		if s.config.followHost() == "" {
 			// for leader only
@@ -221,85 +233,33 @@ func (s *Server) writeAOF(args []string, d *commandDetails) error {
 	return nil
 }
 
-func (s *Server) getQueueCandidates(d *commandDetails) []*Hook {
-	candidates := make(map[*Hook]bool)
-	// add the hooks with "outside" detection
-	for _, hook := range s.hooksOut {
-		if hook.Key == d.key {
-			candidates[hook] = true
-		}
-	}
-	// look for candidates that might "cross" geofences
-	if d.oldObj != nil && d.obj != nil && s.hookCross.Len() > 0 {
-		r1, r2 := d.oldObj.Rect(), d.obj.Rect()
-		s.hookCross.Search(
-			[2]float64{
-				math.Min(r1.Min.X, r2.Min.X),
-				math.Min(r1.Min.Y, r2.Min.Y),
-			},
-			[2]float64{
-				math.Max(r1.Max.X, r2.Max.X),
-				math.Max(r1.Max.Y, r2.Max.Y),
-			},
-			func(min, max [2]float64, value interface{}) bool {
-				hook := value.(*Hook)
-				if hook.Key == d.key {
-					candidates[hook] = true
-				}
-				return true
-			})
-	}
-	// look for candidates that overlap the old object
-	if d.oldObj != nil {
-		r1 := d.oldObj.Rect()
-		s.hookTree.Search(
-			[2]float64{r1.Min.X, r1.Min.Y},
-			[2]float64{r1.Max.X, r1.Max.Y},
-			func(min, max [2]float64, value interface{}) bool {
-				hook := value.(*Hook)
-				if hook.Key == d.key {
-					candidates[hook] = true
-				}
-				return true
-			})
-	}
-	// look for candidates that overlap the new object
-	if d.obj != nil {
-		r1 := d.obj.Rect()
-		s.hookTree.Search(
-			[2]float64{r1.Min.X, r1.Min.Y},
-			[2]float64{r1.Max.X, r1.Max.Y},
-			func(min, max [2]float64, value interface{}) bool {
-				hook := value.(*Hook)
-				if hook.Key == d.key {
-					candidates[hook] = true
-				}
-				return true
-			})
-	}
-	if len(candidates) == 0 {
-		return nil
-	}
-	// return the candidates as a slice
-	ret := make([]*Hook, 0, len(candidates))
-	for hook := range candidates {
-		ret = append(ret, hook)
-	}
-	return ret
-}
-
 func (s *Server) queueHooks(d *commandDetails) error {
 	// Create the slices that will store all messages and hooks
 	var cmsgs, wmsgs []string
-	var whooks []*Hook
+	var whooks, shooks []*Hook
 
 	// Compile a slice of potential hook recipients
-	candidates := s.getQueueCandidates(d)
+	candidates, fences := s.hookIndex.candidates(d)
+	d.fences = fences
 	for _, hook := range candidates {
 		// Calculate all matching fence messages for all candidates and append
 		// them to the appropriate message slice
 		msgs := FenceMatch(hook.Name, hook.ScanWriter, hook.Fence, hook.Metas, d)
+		if hook.inactive > 0 {
+			msgs = append(msgs, s.watchInactive(hook, d)...)
+		}
+		if hook.occupancy != nil {
+			s.watchOccupancy(hook, d)
+		}
+		if hook.threshold != nil {
+			msgs = append(msgs, s.watchThreshold(hook, d)...)
+		}
+		if ns := s.namespaceOf(hook.Name); ns != "" && len(msgs) > 0 {
+			msgs = namespaceMessages(ns, msgs)
+		}
 		if len(msgs) > 0 {
+			msgs = sequenceMessages(hook, msgs)
+			shooks = append(shooks, hook)
 			if hook.channel {
 				cmsgs = append(cmsgs, msgs...)
 			} else {
@@ -308,12 +268,16 @@ func (s *Server) queueHooks(d *commandDetails) error {
 			}
 		}
 	}
+	fences.commit(candidates)
+	d.fences = nil
 
 	// Return nil if there are no messages to be sent
 	if len(cmsgs)+len(wmsgs) == 0 {
 		return nil
 	}
 
+	s.markHookSeqs(shooks...)
+
 	// Sort both message channel and webhook message slices
 	if len(cmsgs) > 1 {
 		sortMsgs(cmsgs)
@@ -330,7 +294,29 @@ func (s *Server) queueHooks(d *commandDetails) error {
 	}
 
 	// Queue the webhook messages in the buntdb database
+	if err := s.queueHookMessages(wmsgs); err != nil {
+		return err
+	}
+	// all the messages have been queued.
+	// notify the hooks
+	for _, hook := range whooks {
+		hook.Signal()
+	}
+	return nil
+}
+
+// queueHookMessages stores webhook messages in the hooks queue, along with
+// the hook sequences that haven't been saved yet. The hooks of the messages
+// must be signaled afterwards.
+func (s *Server) queueHookMessages(wmsgs []string) error {
+	if len(wmsgs) == 0 {
+		return nil
+	}
+	seqs := s.takeHookSeqs()
 	err := s.qdb.Update(func(tx *buntdb.Tx) error {
+		if err := setHookSeqs(tx, seqs); err != nil {
+			return err
+		}
 		for _, msg := range wmsgs {
 			s.qidx++ // increment the log id
 			key := hookLogPrefix + uint64ToString(s.qidx)
@@ -347,14 +333,9 @@ func (s *Server) queueHooks(d *commandDetails) error {
 		return nil
 	})
 	if err != nil {
-		return err
-	}
-	// all the messages have been queued.
-	// notify the hooks
-	for _, hook := range whooks {
-		hook.Signal()
+		s.restoreHookSeqs(seqs)
 	}
-	return nil
+	return err
 }
 
 // sortMsgs sorts passed notification messages by their detect and hook fields
@@ -5,7 +5,6 @@ import (
 	"os"
 	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/tidwall/geojson"
@@ -104,47 +103,32 @@ func (server *Server) aofshrink() {
 								return false
 							}
 							// here we fill the values array with a new command
-							values = values[:0]
-							values = append(values, "set")
-							values = append(values, keys[0])
-							values = append(values, id)
-							if len(fields) > 0 {
-								fvs := orderFields(fmap, fnames, fields)
-								for _, fv := range fvs {
-									if fv.value != 0 {
-										values = append(values, "field")
-										values = append(values, fv.field)
-										values = append(values, strconv.FormatFloat(fv.value, 'f', -1, 64))
-									}
-								}
-							}
-							if ex != 0 {
-								ttl := math.Floor(float64(ex-now)/float64(time.Second)*10) / 10
-								if ttl < 0.1 {
-									// always leave a little bit of ttl.
-									ttl = 0.1
-								}
-								values = append(values, "ex")
-								values = append(values, strconv.FormatFloat(ttl, 'f', -1, 64))
-							}
-							if objIsSpatial(obj) {
-								values = append(values, "object")
-								values = append(values, string(obj.AppendJSON(nil)))
-							} else {
-								values = append(values, "string")
-								values = append(values, obj.String())
-							}
+							meta, _ := col.Meta(id)
+							snames, svalues := col.StringFields(id)
+							values = appendSetArgs(values[:0], keys[0], id, obj,
+								fmap, fnames, fields, col.Tags(id), meta,
+								snames, svalues, ex, now)
 
 							// append the values to the aof buffer
-							aofbuf = append(aofbuf, '*')
-							aofbuf = append(aofbuf, strconv.FormatInt(int64(len(values)), 10)...)
-							aofbuf = append(aofbuf, '\r', '\n')
-							for _, value := range values {
-								aofbuf = append(aofbuf, '$')
-								aofbuf = append(aofbuf, strconv.FormatInt(int64(len(value)), 10)...)
-								aofbuf = append(aofbuf, '\r', '\n')
-								aofbuf = append(aofbuf, value...)
-								aofbuf = append(aofbuf, '\r', '\n')
+							aofbuf = appendAOFCommand(aofbuf, values)
+
+							// the fields that expire
+							if fexs := col.FieldExpires(id); fexs != nil {
+								fexNames := make([]string, 0, len(fexs))
+								for field := range fexs {
+									fexNames = append(fexNames, field)
+								}
+								sort.Strings(fexNames)
+								for _, field := range fexNames {
+									var value float64
+									if idx, ok := fmap[field]; ok && idx < len(fields) {
+										value = fields[idx]
+									}
+									values = append(values[:0], "fset", keys[0], id,
+										field, strconv.FormatFloat(value, 'f', -1, 64),
+										"ex", strconv.FormatFloat(aofTTL(fexs[field], now), 'f', -1, 64))
+									aofbuf = appendAOFCommand(aofbuf, values)
+								}
 							}
 
 							// increment the object count
@@ -186,22 +170,7 @@ func (server *Server) aofshrink() {
 				hook.cond.L.Lock()
 				defer hook.cond.L.Unlock()
 
-				var values []string
-				if hook.channel {
-					values = append(values, "setchan", name)
-				} else {
-					values = append(values, "sethook", name,
-						strings.Join(hook.Endpoints, ","))
-				}
-				for _, meta := range hook.Metas {
-					values = append(values, "meta", meta.Name, meta.Value)
-				}
-				if !hook.expires.IsZero() {
-					ex := float64(time.Until(hook.expires)) / float64(time.Second)
-					values = append(values, "ex",
-						strconv.FormatFloat(ex, 'f', 1, 64))
-				}
-				values = append(values, hook.Message.Args...)
+				values := hookDefOf(hook).args()
 				// append the values to the aof buffer
 				aofbuf = append(aofbuf, '*')
 				aofbuf = append(aofbuf, strconv.FormatInt(int64(len(values)), 10)...)
@@ -215,6 +184,73 @@ func (server *Server) aofshrink() {
 				}
 			}()
 		}
+
+		// load trajectories
+		var tkeys []string
+		func() {
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			tkeys = server.sortedTrackKeys()
+		}()
+		for _, key := range tkeys {
+			var cmds [][]string
+			func() {
+				server.mu.Lock()
+				defer server.mu.Unlock()
+				if store := server.tracks[key]; store != nil {
+					cmds = trackCommands(key, store)
+				}
+			}()
+			for _, values := range cmds {
+				aofbuf = append(aofbuf, '*')
+				aofbuf = append(aofbuf, strconv.FormatInt(int64(len(values)), 10)...)
+				aofbuf = append(aofbuf, '\r', '\n')
+				for _, value := range values {
+					aofbuf = append(aofbuf, '$')
+					aofbuf = append(aofbuf, strconv.FormatInt(int64(len(value)), 10)...)
+					aofbuf = append(aofbuf, '\r', '\n')
+					aofbuf = append(aofbuf, value...)
+					aofbuf = append(aofbuf, '\r', '\n')
+				}
+			}
+			if len(aofbuf) > maxchunk {
+				if _, err := f.Write(aofbuf); err != nil {
+					return err
+				}
+				aofbuf = aofbuf[:0]
+			}
+		}
+
+		// load the configs of the keys, schemas, namespaces and roles, after
+		// the objects so that the sliding ttls don't apply to objects without
+		// an expiration.
+		var ccmds [][]string
+		func() {
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			ccmds = append(server.areaCommands(), server.ttlCommands()...)
+			ccmds = append(ccmds, server.orderCommands()...)
+			ccmds = append(ccmds, server.keyConfigCommands()...)
+			ccmds = append(ccmds, server.historyCommands()...)
+			ccmds = append(ccmds, server.fleetCommands()...)
+			ccmds = append(ccmds, server.writeBehindCommands()...)
+			ccmds = append(ccmds, server.readThroughCommands()...)
+			ccmds = append(ccmds, server.schemaCommands()...)
+			ccmds = append(ccmds, server.namespaceCommands()...)
+			ccmds = append(ccmds, server.roleCommands()...)
+		}()
+		for _, values := range ccmds {
+			aofbuf = append(aofbuf, '*')
+			aofbuf = append(aofbuf, strconv.FormatInt(int64(len(values)), 10)...)
+			aofbuf = append(aofbuf, '\r', '\n')
+			for _, value := range values {
+				aofbuf = append(aofbuf, '$')
+				aofbuf = append(aofbuf, strconv.FormatInt(int64(len(value)), 10)...)
+				aofbuf = append(aofbuf, '\r', '\n')
+				aofbuf = append(aofbuf, value...)
+				aofbuf = append(aofbuf, '\r', '\n')
+			}
+		}
 		if len(aofbuf) > 0 {
 			if _, err := f.Write(aofbuf); err != nil {
 				return err
@@ -305,3 +341,29 @@ func (server *Server) aofshrink() {
 		return
 	}
 }
+
+// appendAOFCommand appends a command to an aof buffer.
+func appendAOFCommand(aofbuf []byte, values []string) []byte {
+	aofbuf = append(aofbuf, '*')
+	aofbuf = append(aofbuf, strconv.FormatInt(int64(len(values)), 10)...)
+	aofbuf = append(aofbuf, '\r', '\n')
+	for _, value := range values {
+		aofbuf = append(aofbuf, '$')
+		aofbuf = append(aofbuf, strconv.FormatInt(int64(len(value)), 10)...)
+		aofbuf = append(aofbuf, '\r', '\n')
+		aofbuf = append(aofbuf, value...)
+		aofbuf = append(aofbuf, '\r', '\n')
+	}
+	return aofbuf
+}
+
+// aofTTL returns the seconds that are left until an expiration, for the EX
+// of a command.
+func aofTTL(ex, now int64) float64 {
+	ttl := math.Floor(float64(ex-now)/float64(time.Second)*10) / 10
+	if ttl < 0.1 {
+		// always leave a little bit of ttl.
+		ttl = 0.1
+	}
+	return ttl
+}
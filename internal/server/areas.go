@@ -0,0 +1,197 @@
+package server
+
+// Area annotations: the SET and MSET objects of a key are looked up in a
+// reference layer, see layers.go. The fence events of the objects have the
+// ids of the areas that contain them, and the id of the area can be stored in
+// a field.
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/resp"
+)
+
+// areaConfig is the AREACONFIG of a key.
+type areaConfig struct {
+	layer string
+	field string // field for the area id, optional
+}
+
+// cmdAreaConfig sets the layer that the objects of a key are looked up in.
+//
+//	AREACONFIG key layer [FIELD name]
+func (server *Server) cmdAreaConfig(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var conf areaConfig
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, conf.layer, ok = tokenval(vs); !ok || conf.layer == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if len(vs) > 0 {
+		var arg string
+		vs, arg, _ = tokenval(vs)
+		if !lc(arg, "field") {
+			err = errInvalidArgument(arg)
+			return
+		}
+		if vs, conf.field, ok = tokenval(vs); !ok || conf.field == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		if isReservedFieldName(conf.field) {
+			err = errInvalidArgument(conf.field)
+			return
+		}
+	}
+	if len(vs) != 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if msg.ConnType != Null || msg.OutputType != Null {
+		// the layers may have changed since the command was written to the
+		// aof, so they're only checked for clients.
+		if server.layerSet().layers[conf.layer] == nil {
+			err = errLayerNotFound(conf.layer)
+			return
+		}
+	}
+	server.areaConfigs[d.key] = conf
+	d.command = "areaconfig"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// cmdAreaDel stops looking up the objects of a key.
+//
+//	AREADEL key
+func (server *Server) cmdAreaDel(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if len(vs) != 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var n int
+	if _, ok := server.areaConfigs[d.key]; ok {
+		delete(server.areaConfigs, d.key)
+		n = 1
+	}
+	d.command = "areadel"
+	d.updated = n > 0
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
+
+// areaConfig returns the AREACONFIG of a key, or nil when there's none. It's
+// also nil for commands that are loaded from the aof, which already have the
+// field of the area id.
+func (server *Server) areaConfig(msg *Message, key string) *areaConfig {
+	if msg.ConnType == Null && msg.OutputType == Null {
+		return nil
+	}
+	conf, ok := server.areaConfigs[key]
+	if !ok {
+		return nil
+	}
+	return &conf
+}
+
+// annotateAreas sets the areas of the object of a SET, and the field of the
+// area id, when the config has one. The field is added to the arguments of
+// the object, which start at the id, so that the aof doesn't depend on the
+// layer. A field that's set by the command itself is kept. Nothing changes
+// when the layer isn't loaded.
+func (server *Server) annotateAreas(conf *areaConfig, d *commandDetails,
+	fields []string, values []float64, args []string,
+) ([]string, []float64, []string) {
+	l := server.layerSet().layers[conf.layer]
+	if l == nil || d.obj == nil {
+		return fields, values, args
+	}
+	areas := l.Lookup(d.obj.Center())
+	d.areas = make([]string, len(areas))
+	for i, area := range areas {
+		d.areas[i] = area.ID
+	}
+	if conf.field == "" {
+		return fields, values, args
+	}
+	for _, field := range fields {
+		if field == conf.field {
+			return fields, values, args
+		}
+	}
+	// the first area that has a numeric id, or zero when there's none
+	var value float64
+	for _, id := range d.areas {
+		if v, err := strconv.ParseFloat(id, 64); err == nil {
+			value = v
+			break
+		}
+	}
+	fields = append(fields, conf.field)
+	values = append(values, value)
+	nargs := make([]string, 0, len(args)+3)
+	nargs = append(nargs, args[0], "FIELD", conf.field,
+		strconv.FormatFloat(value, 'f', -1, 64))
+	nargs = append(nargs, args[1:]...)
+	return fields, values, nargs
+}
+
+// appendAreas adds the area ids to a json event message.
+func appendAreas(msg string, areas []string) string {
+	// hack off the last '}'
+	nmsg := []byte(msg[:len(msg)-1])
+	nmsg = append(nmsg, `,"areas":[`...)
+	for i, id := range areas {
+		if i > 0 {
+			nmsg = append(nmsg, ',')
+		}
+		nmsg = appendJSONString(nmsg, id)
+	}
+	nmsg = append(nmsg, ']', '}')
+	return string(nmsg)
+}
+
+// areaCommands returns the commands that rebuild the AREACONFIG of the keys,
+// for aofshrink.
+func (server *Server) areaCommands() [][]string {
+	keys := make([]string, 0, len(server.areaConfigs))
+	for key := range server.areaConfigs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmds := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		conf := server.areaConfigs[key]
+		cmd := []string{"areaconfig", key, conf.layer}
+		if conf.field != "" {
+			cmd = append(cmd, "FIELD", conf.field)
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
@@ -0,0 +1,149 @@
+package server
+
+// Authentication providers: a client authenticates with the requirepass
+// password, a JWT that's signed by the identity provider of the jwt-*
+// config, or the credentials of a user of the LDAP directory of the ldap-*
+// config. The credentials come from AUTH, or from the Authorization header of
+// HTTP and websocket requests, which is a password, "Bearer token", or
+// "Basic user:password".
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var errInvalidPassword = errors.New("invalid password")
+
+// errAuthSkip is returned by the providers for credentials that aren't
+// theirs, such as a user for the password provider.
+var errAuthSkip = errors.New("skip")
+
+// authConfig is the config of the providers.
+type authConfig struct {
+	password     string // requirepass
+	jwtSecret    string // for HS256, HS384, and HS512
+	jwtPublicKey string // PEM file, for RS256... and ES256...
+	jwtJWKSURL   string // keys of an OpenID Connect provider
+	jwtIssuer    string
+	jwtAudience  string
+	jwtNamespace string // claim that binds the client to a namespace
+	jwtRole      string // claim that gives the client a role
+	ldapURL      string // ldap://host[:port] or ldaps://host[:port]
+	ldapBindDN   string // DN of a user, with %s for the user name
+}
+
+// authIdentity is who a client authenticated as.
+type authIdentity struct {
+	name      string // subject of a JWT, or LDAP user
+	namespace string // binds the client to a namespace, see namespace.go
+	role      string // redacts the replies of the client, see redact.go
+}
+
+// authProvider validates the credentials of a client. The user is empty for
+// AUTH password and for tokens.
+type authProvider interface {
+	authenticate(user, password string) (authIdentity, error)
+}
+
+// passwordAuth is the requirepass provider.
+type passwordAuth string
+
+func (p passwordAuth) authenticate(user, password string) (authIdentity, error) {
+	if user != "" {
+		return authIdentity{}, errAuthSkip
+	}
+	if subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+		return authIdentity{}, errInvalidPassword
+	}
+	return authIdentity{}, nil
+}
+
+// authProviders returns the providers of the config, or nil when the clients
+// don't need to authenticate.
+func (s *Server) authProviders() []authProvider {
+	cfg := s.config.authConfig()
+	var providers []authProvider
+	if cfg.password != "" {
+		providers = append(providers, passwordAuth(cfg.password))
+	}
+	if cfg.jwtSecret != "" || cfg.jwtPublicKey != "" || cfg.jwtJWKSURL != "" {
+		providers = append(providers, &jwtAuth{cfg: cfg, keys: &s.jwtKeys})
+	}
+	if cfg.ldapURL != "" && cfg.ldapBindDN != "" {
+		providers = append(providers, &ldapAuth{url: cfg.ldapURL, bindDN: cfg.ldapBindDN})
+	}
+	return providers
+}
+
+// authRequired returns true when the clients need to authenticate.
+func (s *Server) authRequired() bool {
+	return len(s.authProviders()) > 0
+}
+
+// authenticate returns the identity of the credentials. The error is the
+// error of the last provider that the credentials are for.
+func (s *Server) authenticate(user, password string) (authIdentity, error) {
+	err := errInvalidPassword
+	for _, provider := range s.authProviders() {
+		id, perr := provider.authenticate(user, password)
+		if perr == nil {
+			return id, nil
+		}
+		if perr != errAuthSkip {
+			err = perr
+		}
+	}
+	return authIdentity{}, err
+}
+
+// authClient authenticates a client, and binds it to the namespace and the
+// role of its identity.
+func (s *Server) authClient(client *Client, user, password string) error {
+	id, err := s.authenticate(user, password)
+	if err != nil {
+		return err
+	}
+	if id.namespace == defaultNamespace {
+		id.namespace = ""
+	}
+	if id.namespace != "" || id.role != "" {
+		s.mu.RLock()
+		_, nsok := s.namespaces[id.namespace]
+		_, roleok := s.roles[id.role]
+		s.mu.RUnlock()
+		if id.namespace != "" && !nsok {
+			return errNamespaceNotFound(id.namespace)
+		}
+		if id.role != "" && !roleok {
+			return errRoleNotFound(id.role)
+		}
+	}
+	client.namespace = id.namespace
+	client.role = id.role
+	client.user = id.name
+	client.nsBound = id.namespace != ""
+	client.authd = true
+	return nil
+}
+
+// parseAuthorization returns the credentials of an Authorization header.
+func parseAuthorization(header string) (user, password string) {
+	header = strings.TrimSpace(header)
+	if i := strings.IndexByte(header, ' '); i != -1 {
+		scheme, value := header[:i], strings.TrimSpace(header[i+1:])
+		switch {
+		case strings.EqualFold(scheme, "bearer"):
+			return "", value
+		case strings.EqualFold(scheme, "basic"):
+			data, err := base64.StdEncoding.DecodeString(value)
+			if err == nil {
+				if j := strings.IndexByte(string(data), ':'); j != -1 {
+					return string(data[:j]), string(data[j+1:])
+				}
+			}
+		}
+	}
+	return "", header
+}
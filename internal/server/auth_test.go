@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func b64(data string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(data))
+}
+
+// signJWT returns a token of the claims, signed with a key, which is an HMAC
+// secret or an RSA or ECDSA private key.
+func signJWT(t *testing.T, alg, kid, claims string, key interface{}) string {
+	t.Helper()
+	header := `{"alg":"` + alg + `","typ":"JWT"`
+	if kid != "" {
+		header += `,"kid":"` + kid + `"`
+	}
+	signed := b64(header+"}") + "." + b64(claims)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch key := key.(type) {
+	case string:
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTAuth(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	p := &jwtAuth{cfg: authConfig{
+		jwtSecret:    "secret",
+		jwtIssuer:    "https://idp.example.com",
+		jwtAudience:  "tile38",
+		jwtNamespace: "tile38.ns",
+	}, keys: &jwtKeys{}}
+	test := func(token, expect string) {
+		t.Helper()
+		id, err := p.authenticate("", token)
+		actual := "ok " + id.name + " " + id.namespace
+		if err != nil {
+			actual = err.Error()
+		}
+		if actual != expect {
+			t.Fatalf("expected '%s', got '%s'", expect, actual)
+		}
+	}
+	claims := func(extra string) string {
+		return fmt.Sprintf(`{"sub":"ann","iss":"https://idp.example.com",`+
+			`"aud":["web","tile38"],"exp":%d,"tile38":{"ns":"acme"}%s}`, exp, extra)
+	}
+	test(signJWT(t, "HS256", "", claims(""), "secret"), "ok ann acme")
+	test(signJWT(t, "HS256", "", claims(""), "wrong"), "invalid token")
+	test(signJWT(t, "none", "", claims(""), ""), "invalid token")
+	test("password", "skip")
+	test("a.b.c", "invalid token")
+	test(signJWT(t, "HS256", "", `{"sub":"ann","exp":1}`, "secret"), "token expired")
+	test(signJWT(t, "HS256", "", claims(`,"nbf":`+fmt.Sprint(exp)), "secret"),
+		"token not valid yet")
+	test(signJWT(t, "HS256", "", `{"iss":"https://evil.example.com"}`, "secret"),
+		"invalid token issuer")
+	test(signJWT(t, "HS256", "", `{"iss":"https://idp.example.com","aud":"web"}`, "secret"),
+		"invalid token audience")
+	test(signJWT(t, "HS256", "", `{"iss":"https://idp.example.com","aud":"tile38"}`, "secret"),
+		"token has no 'tile38.ns' claim")
+	// the role claim is optional
+	p.cfg.jwtRole = "role"
+	for _, role := range []string{"", "partner"} {
+		extra := ""
+		if role != "" {
+			extra = `,"role":"` + role + `"`
+		}
+		id, err := p.authenticate("", signJWT(t, "HS256", "", claims(extra), "secret"))
+		if err != nil || id.role != role {
+			t.Fatalf("expected role '%s', got '%s' (%v)", role, id.role, err)
+		}
+	}
+	p.cfg.jwtRole = ""
+	// RS256 with a key of the public key file
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "tile38-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p.cfg.jwtPublicKey = filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(p.cfg.jwtPublicKey,
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test(signJWT(t, "RS256", "", claims(""), rsaKey), "ok ann acme")
+	// the secret doesn't verify RS256, and the public key doesn't verify HS256
+	test(signJWT(t, "RS256", "", claims(""), "secret"), "invalid token")
+	test(signJWT(t, "HS256", "", claims(""), string(der)), "invalid token")
+	// ES256 with a key of the JWKS
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+		ecKey.X.FillBytes(x)
+		ecKey.Y.FillBytes(y)
+		fmt.Fprintf(w, `{"keys":[{"kty":"EC","kid":"k1","use":"sig","crv":"P-256","x":"%s","y":"%s"},`+
+			`{"kty":"RSA","kid":"k2","use":"enc","n":"AQAB","e":"AQAB"}]}`,
+			base64.RawURLEncoding.EncodeToString(x), base64.RawURLEncoding.EncodeToString(y))
+	}))
+	defer srv.Close()
+	p.cfg.jwtJWKSURL = srv.URL
+	test(signJWT(t, "ES256", "k1", claims(""), ecKey), "ok ann acme")
+	test(signJWT(t, "ES256", "k1", claims(""), ecKey), "ok ann acme")
+	if fetches != 1 {
+		t.Fatalf("expected 1 fetch, got %d", fetches)
+	}
+	// an unknown kid falls back to the public key file, without a fetch
+	test(signJWT(t, "RS256", "k3", claims(""), rsaKey), "ok ann acme")
+	if fetches != 1 {
+		t.Fatalf("expected 1 fetch, got %d", fetches)
+	}
+}
+
+func TestParseAuthorization(t *testing.T) {
+	test := func(header, user, password string) {
+		t.Helper()
+		u, p := parseAuthorization(header)
+		if u != user || p != password {
+			t.Fatalf("%s: expected '%s' '%s', got '%s' '%s'", header, user, password, u, p)
+		}
+	}
+	test("pass", "", "pass")
+	test("web pass", "", "web pass")
+	test("Bearer a.b.c", "", "a.b.c")
+	test("basic "+base64.StdEncoding.EncodeToString([]byte("ann:p:w")), "ann", "p:w")
+}
+
+func TestLDAPEscape(t *testing.T) {
+	test := func(s, expect string) {
+		t.Helper()
+		if actual := ldapEscape(s); actual != expect {
+			t.Fatalf("expected '%s', got '%s'", expect, actual)
+		}
+	}
+	test("ann", "ann")
+	test("a,b=c", `a\,b\=c`)
+	test("#ann ", `\#ann\ `)
+	test("x#y", "x#y")
+}
+
+func TestLDAPAuth(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	binds := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			rd := bufio.NewReader(conn)
+			_, msg, err := berRead(rd)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			_, _, msg, _ = berParse(msg)       // messageID
+			_, req, _, _ := berParse(msg)      // BindRequest
+			_, _, req, _ = berParse(req)       // version
+			_, dn, req, _ := berParse(req)     // name
+			_, password, _, _ := berParse(req) // simple
+			binds <- string(dn)
+			code := byte(ldapInvalidCredentials)
+			if string(password) == "secret" {
+				code = ldapSuccess
+			}
+			conn.Write(berTLV(0x30, berInt(1), berTLV(0x61,
+				[]byte{0x0a, 0x01, code}, berTLV(0x04), berTLV(0x04))))
+			conn.Close()
+		}
+	}()
+	p := &ldapAuth{
+		url:    "ldap://" + ln.Addr().String(),
+		bindDN: "uid=%s,ou=people,dc=example,dc=com",
+	}
+	test := func(user, password, expect string) {
+		t.Helper()
+		id, err := p.authenticate(user, password)
+		actual := "ok " + id.name
+		if err != nil {
+			actual = err.Error()
+		}
+		if actual != expect {
+			t.Fatalf("expected '%s', got '%s'", expect, actual)
+		}
+	}
+	test("ann", "secret", "ok ann")
+	if dn := <-binds; dn != "uid=ann,ou=people,dc=example,dc=com" {
+		t.Fatalf("unexpected dn '%s'", dn)
+	}
+	test("a,b", "wrong", "invalid password")
+	if dn := <-binds; dn != `uid=a\,b,ou=people,dc=example,dc=com` {
+		t.Fatalf("unexpected dn '%s'", dn)
+	}
+	test("ann", "", "invalid password")
+	test("", "secret", "skip")
+	p.url = "ldap://127.0.0.1:1"
+	test("ann", "secret", "ldap unavailable")
+}
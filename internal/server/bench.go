@@ -0,0 +1,373 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/btree"
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/histogram"
+)
+
+// BENCH [OBJECTS num] [FENCES num] [CLIENTS num] [REQUESTS num]
+//       [UPDATES percent]
+//
+// Runs a moving object workload inside of the server and reports the
+// latency of each kind of command. The objects are placed in a 50 km area
+// and every update moves one object a short distance. The rest of the
+// requests are NEARBY, WITHIN, and INTERSECTS queries on the same area. The
+// fences are channels with a NEARBY fence, which means that every update is
+// also checked against the fences.
+//
+// The workload runs on a scratch collection with its own lock, which isn't
+// a key of the server, and so nothing of the benchmark is written to the AOF
+// or sent to the followers. The queries are the commands of the clients, on
+// the scratch collection, and the updates are matched against the fences
+// like the SETs of a key with channels, but their messages aren't sent. The
+// benchmark stops early when the client goes away, or after benchMaxTime,
+// or the TIMEOUT of the command, and then reports the requests it has done.
+
+const (
+	benchLat   = 33.4484 // center of the benchmark area
+	benchLon   = -112.0740
+	benchSpan  = 0.45   // width and height of the area in degrees, about 50 km
+	benchStep  = 0.001  // largest move in degrees, about 100 m
+	benchRange = "1000" // meters, the radius of fences and queries
+
+	benchMaxTime = 30 * time.Second // the longest a benchmark runs
+)
+
+var benchCommands = []string{"set", "nearby", "within", "intersects"}
+
+type benchOptions struct {
+	objects  int
+	fences   int
+	clients  int
+	requests int
+	updates  int // percent
+}
+
+func parseBenchArgs(vs []string) (opts benchOptions, err error) {
+	opts = benchOptions{
+		objects:  1000,
+		fences:   10,
+		clients:  4,
+		requests: 10000,
+		updates:  80,
+	}
+	for len(vs) > 0 {
+		var name, sval string
+		var ok bool
+		if vs, name, ok = tokenval(vs); !ok {
+			return opts, errInvalidNumberOfArguments
+		}
+		if vs, sval, ok = tokenval(vs); !ok {
+			return opts, errInvalidNumberOfArguments
+		}
+		n, err := strconv.ParseUint(sval, 10, 32)
+		if err != nil {
+			return opts, errInvalidArgument(sval)
+		}
+		switch strings.ToLower(name) {
+		case "objects":
+			opts.objects = int(n)
+		case "fences":
+			opts.fences = int(n)
+		case "clients":
+			opts.clients = int(n)
+		case "requests":
+			opts.requests = int(n)
+		case "updates":
+			if n > 100 {
+				return opts, errInvalidArgument(sval)
+			}
+			opts.updates = int(n)
+		default:
+			return opts, errInvalidArgument(name)
+		}
+	}
+	if opts.objects == 0 || opts.clients == 0 {
+		return opts, errors.New("OBJECTS and CLIENTS must be greater than zero")
+	}
+	return opts, nil
+}
+
+func benchPoint(rng *rand.Rand) (lat, lon float64) {
+	return benchLat + (rng.Float64()-0.5)*benchSpan,
+		benchLon + (rng.Float64()-0.5)*benchSpan
+}
+
+func benchFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
+
+// benchKey is the scratch collection of a benchmark, and its fences.
+type benchKey struct {
+	mu     sync.RWMutex // like the lock of a key
+	key    string
+	col    *collection.Collection
+	fences fenceIndex
+}
+
+func (s *Server) cmdBench(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	opts, err := parseBenchArgs(msg.Args[1:])
+	if err != nil {
+		return NOMessage, err
+	}
+	ctx, cancel := context.WithTimeout(msg.Deadline.Context(), benchMaxTime)
+	defer cancel()
+	bk := &benchKey{
+		key:    "bench:" + randomKey(8),
+		col:    collection.New(),
+		fences: make(fenceIndex),
+	}
+
+	// load the objects
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	points := make([][2]float64, opts.objects)
+	for i := range points {
+		lat, lon := benchPoint(rng)
+		points[i] = [2]float64{lat, lon}
+		bk.set(strconv.Itoa(i), lat, lon)
+	}
+	// The groups of the fences, which are kept by the server for the
+	// hooks, are kept apart.
+	groups := &Server{
+		groupHooks:    btree.NewNonConcurrent(byGroupHook),
+		groupObjects:  btree.NewNonConcurrent(byGroupObject),
+		geomIndexOpts: s.geomIndexOpts,
+	}
+	for i := 0; i < opts.fences; i++ {
+		lat, lon := benchPoint(rng)
+		hook, err := s.benchFence(bk, bk.key+":fence:"+strconv.Itoa(i),
+			lat, lon)
+		if err != nil {
+			return NOMessage, err
+		}
+		hook.ScanWriter.s = groups
+		bk.fences.insert(hook)
+	}
+
+	// run the workload
+	hists := make([]map[string]*histogram.Histogram, opts.clients)
+	errs := make([]error, opts.clients)
+	var wg sync.WaitGroup
+	runStart := time.Now()
+	for c := 0; c < opts.clients; c++ {
+		hists[c] = make(map[string]*histogram.Histogram)
+		for _, cmd := range benchCommands {
+			hists[c][cmd] = new(histogram.Histogram)
+		}
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			errs[c] = s.benchClient(ctx, bk, c, opts, points, hists[c])
+		}(c)
+	}
+	wg.Wait()
+	runElapsed := time.Since(runStart)
+	for _, err := range errs {
+		if err != nil {
+			return NOMessage, err
+		}
+	}
+	totals := make(map[string]*histogram.Histogram)
+	opts.requests = 0
+	for _, cmd := range benchCommands {
+		totals[cmd] = new(histogram.Histogram)
+		for c := range hists {
+			totals[cmd].Merge(hists[c][cmd])
+		}
+		opts.requests += totals[cmd].Count()
+	}
+	opsPerSec := float64(opts.requests) / runElapsed.Seconds()
+
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"bench":{`)
+		buf.WriteString(`"objects":` + strconv.Itoa(opts.objects))
+		buf.WriteString(`,"fences":` + strconv.Itoa(opts.fences))
+		buf.WriteString(`,"clients":` + strconv.Itoa(opts.clients))
+		buf.WriteString(`,"requests":` + strconv.Itoa(opts.requests))
+		buf.WriteString(`,"updates":` + strconv.Itoa(opts.updates))
+		buf.WriteString(`,"ops_per_sec":` + strconv.FormatFloat(opsPerSec, 'f', 0, 64))
+		for _, cmd := range benchCommands {
+			buf.WriteString(`,"` + cmd + `":{`)
+			for i, kv := range benchStats(totals[cmd]) {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(`"` + kv[0] + `":` + kv[1])
+			}
+			buf.WriteByte('}')
+		}
+		buf.WriteString(`},"elapsed":"` + time.Since(start).String() + `"}`)
+		res = resp.StringValue(buf.String())
+	case RESP:
+		vals := []resp.Value{
+			resp.StringValue("objects"), resp.IntegerValue(opts.objects),
+			resp.StringValue("fences"), resp.IntegerValue(opts.fences),
+			resp.StringValue("clients"), resp.IntegerValue(opts.clients),
+			resp.StringValue("requests"), resp.IntegerValue(opts.requests),
+			resp.StringValue("updates"), resp.IntegerValue(opts.updates),
+			resp.StringValue("ops_per_sec"),
+			resp.StringValue(strconv.FormatFloat(opsPerSec, 'f', 0, 64)),
+		}
+		for _, cmd := range benchCommands {
+			var stats []resp.Value
+			for _, kv := range benchStats(totals[cmd]) {
+				stats = append(stats, resp.StringValue(kv[0]),
+					resp.StringValue(kv[1]))
+			}
+			vals = append(vals, resp.StringValue(cmd), resp.ArrayValue(stats))
+		}
+		res = resp.ArrayValue(vals)
+	}
+	return res, nil
+}
+
+// benchStats returns the count and latencies, in microseconds, of the
+// histogram as name value pairs.
+func benchStats(h *histogram.Histogram) [][2]string {
+	us := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Microsecond), 'f', 2, 64)
+	}
+	return [][2]string{
+		{"count", strconv.Itoa(h.Count())},
+		{"mean_us", us(h.Mean())},
+		{"p50_us", us(h.Percentile(50))},
+		{"p90_us", us(h.Percentile(90))},
+		{"p99_us", us(h.Percentile(99))},
+		{"p999_us", us(h.Percentile(99.9))},
+		{"max_us", us(h.Max())},
+	}
+}
+
+// benchClient runs the share of the requests for client c, until the
+// context is done. The client only moves the objects where id % clients == c,
+// so no two clients write to the same point.
+func (s *Server) benchClient(ctx context.Context, bk *benchKey, c int,
+	opts benchOptions, points [][2]float64,
+	hists map[string]*histogram.Histogram,
+) error {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(c)))
+	n := opts.requests / opts.clients
+	if c < opts.requests%opts.clients {
+		n++
+	}
+	owned := (len(points) - c + opts.clients - 1) / opts.clients
+	key := bk.key
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		var args []string
+		if rng.Intn(100) < opts.updates && owned > 0 {
+			idx := c + rng.Intn(owned)*opts.clients
+			p := &points[idx]
+			p[0] = math.Max(benchLat-benchSpan/2, math.Min(benchLat+benchSpan/2,
+				p[0]+(rng.Float64()*2-1)*benchStep))
+			p[1] = math.Max(benchLon-benchSpan/2, math.Min(benchLon+benchSpan/2,
+				p[1]+(rng.Float64()*2-1)*benchStep))
+			start := time.Now()
+			bk.set(strconv.Itoa(idx), p[0], p[1])
+			hists["set"].Record(time.Since(start))
+			continue
+		}
+		lat, lon := benchPoint(rng)
+		switch rng.Intn(3) {
+		case 0:
+			args = []string{"nearby", key, "LIMIT", "10", "COUNT",
+				"POINT", benchFloat(lat), benchFloat(lon)}
+		case 1:
+			args = []string{"within", key, "COUNT",
+				"CIRCLE", benchFloat(lat), benchFloat(lon), benchRange}
+		case 2:
+			args = []string{"intersects", key, "COUNT", "BOUNDS",
+				benchFloat(lat - benchStep*10), benchFloat(lon - benchStep*10),
+				benchFloat(lat + benchStep*10), benchFloat(lon + benchStep*10)}
+		}
+		start := time.Now()
+		if err := s.benchQuery(bk, args...); err != nil {
+			return err
+		}
+		hists[args[0]].Record(time.Since(start))
+	}
+	return nil
+}
+
+// benchFence returns a channel with a NEARBY fence on the scratch
+// collection, which isn't added to the server.
+func (s *Server) benchFence(bk *benchKey, name string, lat, lon float64,
+) (*Hook, error) {
+	vs := []string{bk.key, "FENCE", "POINT", benchFloat(lat), benchFloat(lon),
+		benchRange}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	args, err := s.cmdSearchArgs(true, "nearby", vs, nearbyTypes)
+	if err != nil {
+		return nil, err
+	}
+	args.cmd = "nearby"
+	msg := &Message{
+		Args:       append([]string{"nearby"}, vs...),
+		OutputType: JSON,
+		snapshots:  map[string]*collection.Collection{bk.key: bk.col},
+	}
+	hook := &Hook{Key: bk.key, Name: name, Fence: &args, Message: msg,
+		channel: true}
+	hook.ScanWriter, err = s.newScanWriter(&bytes.Buffer{}, msg, args.key,
+		args.output, args.precision, args.glob, false, args.cursor,
+		args.limit, args.wheres, args.whereins, args.whereevals,
+		args.nofields, args.selected, args.reshape, args.crs)
+	if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// set moves an object of the scratch collection, and matches it against the
+// fences, with the lock of the collection.
+func (bk *benchKey) set(id string, lat, lon float64) {
+	bk.mu.Lock()
+	defer bk.mu.Unlock()
+	d := commandDetails{
+		command:   "set",
+		key:       bk.key,
+		id:        id,
+		obj:       geojson.NewPoint(geometry.Point{X: lon, Y: lat}),
+		timestamp: time.Now(),
+	}
+	d.oldObj, d.oldFields, d.fields = bk.col.Set(id, d.obj, nil, nil, 0)
+	candidates, fences := bk.fences.candidates(&d)
+	d.fences = fences
+	for _, hook := range candidates {
+		FenceMatch(hook.Name, hook.ScanWriter, hook.Fence, nil, &d)
+	}
+	fences.commit(candidates)
+}
+
+// benchQuery runs a search of a client on the scratch collection, with the
+// lock of the collection and the server lock for reading, like MREAD.
+func (s *Server) benchQuery(bk *benchKey, args ...string) error {
+	bk.mu.RLock()
+	defer bk.mu.RUnlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	msg := &Message{
+		Args:       args,
+		OutputType: RESP,
+		snapshots:  map[string]*collection.Collection{bk.key: bk.col},
+	}
+	_, _, err := s.command(msg, nil)
+	return err
+}
@@ -0,0 +1,425 @@
+package server
+
+// Bulk commands: DELWHERE deletes the objects of a key that match a scan or
+// a search, and COPY and MOVE copy or move them to another key. They work in
+// batches. Each batch is found and written while holding the write locks,
+// so that it's atomic, and the other writes run between the batches. The
+// objects are written as if by SET and DEL, which means that they go to the
+// AOF and trigger geofence notifications. While a bulk command runs, QUERIES
+// LIST shows the number of objects that it has done so far, and QUERIES
+// KILL stops it before its next batch.
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/glob"
+	"github.com/tidwall/tile38/internal/simplify"
+)
+
+// defaultBulkBatch is the number of objects done by each batch of a bulk
+// command without a BATCH option.
+const defaultBulkBatch = 1000
+
+type bulkArgs struct {
+	search liveFenceSwitches
+	batch  int
+	dryrun bool
+	resume bool   // the objects stay, so the next batch starts after them
+	offset uint64 // where the next batch starts, for resume
+}
+
+// bulkSearch returns the index of the search command in the args of a bulk
+// command, which are searched from the start index, or zero when there is
+// none.
+func bulkSearch(args []string, start int) int {
+	for i := start; i < len(args); i++ {
+		switch {
+		case lc(args[i], "batch"):
+			i++
+		case lc(args[i], "dryrun"):
+		case lc(args[i], "scan"), lc(args[i], "within"),
+			lc(args[i], "intersects"):
+			return i
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// cmdBulkArgs parses the options and the search of a bulk command. The key
+// of the search follows the search command, unless it's given.
+func (s *Server) cmdBulkArgs(name string, vs []string, key string) (
+	args bulkArgs, err error,
+) {
+	args.batch = defaultBulkBatch
+	var cmd string
+	for cmd == "" {
+		var arg string
+		var ok bool
+		if vs, arg, ok = tokenval(vs); !ok || arg == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		switch {
+		case lc(arg, "batch"):
+			var sbatch string
+			if vs, sbatch, ok = tokenval(vs); !ok || sbatch == "" {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			if args.batch, err = strconv.Atoi(sbatch); err != nil ||
+				args.batch <= 0 {
+				err = errInvalidArgument(sbatch)
+				return
+			}
+		case lc(arg, "dryrun"):
+			args.dryrun = true
+		case lc(arg, "scan"), lc(arg, "within"), lc(arg, "intersects"):
+			cmd = strings.ToLower(arg)
+		default:
+			err = errInvalidArgument(arg)
+			return
+		}
+	}
+	if key != "" {
+		vs = append([]string{key}, vs...)
+	}
+	if cmd == "scan" {
+		args.search, err = s.cmdScanArgs(vs)
+	} else {
+		args.search, err = s.cmdSearchArgs(false, cmd, vs,
+			withinOrIntersectsTypes)
+	}
+	args.search.cmd = cmd
+	if err != nil {
+		return
+	}
+	switch {
+	case args.search.fence:
+		err = errors.New("FENCE is not allowed for " + name)
+	case args.search.cursor != 0:
+		err = errors.New("CURSOR is not allowed for " + name)
+	case args.search.usparse:
+		err = errors.New("SPARSE is not allowed for " + name)
+	case args.search.incomplete != 0:
+		err = errors.New("WITHINCOMPLETE is not allowed for " + name)
+	}
+	return
+}
+
+// bulkBatch finds up to max objects that match the search and calls apply
+// with their ids, while holding the locks of the lock func. The search starts
+// over for each batch, because the objects of the last one are gone, unless
+// it resumes. A dry run only
+// counts the objects, with read locks. It returns the number of objects that
+// were applied, or found for a dry run.
+func (s *Server) bulkBatch(msg *Message, args *bulkArgs, max int,
+	lock func() (unlock func()),
+	apply func(col *collection.Collection, ids []string) (int, error),
+) (n int, err error) {
+	key := args.search.key
+	if !args.dryrun {
+		defer lock()()
+	} else if readsOtherKeys(msg.Args) {
+		defer s.lockAllRead()()
+	} else {
+		defer s.lockKeyRead(key)()
+	}
+	sw, err := s.newScanWriter(&bytes.Buffer{}, msg, key, outputCount, 0,
+		args.search.glob, false, 0, 0, args.search.wheres,
+		args.search.whereins, args.search.whereevals, false, nil,
+		simplify.Options{}, nil)
+	if err != nil || sw.col == nil {
+		return 0, err
+	}
+	sw.tagOptions(args.search.searchScanBaseTokens)
+	sw.cursor = args.offset
+	var ids []string
+	iter := func(id string, o geojson.Object, fields []float64) bool {
+		ok, keepGoing, _ := sw.testObject(id, o, fields)
+		if !ok {
+			return keepGoing
+		}
+		n++
+		if !args.dryrun {
+			ids = append(ids, id)
+		}
+		return n < max
+	}
+	switch args.search.cmd {
+	case "scan":
+		g := glob.Parse(sw.globPattern, args.search.desc)
+		if g.Limits[0] == "" && g.Limits[1] == "" {
+			sw.col.Scan(args.search.desc, sw, msg.Deadline, iter)
+		} else {
+			sw.col.ScanRange(g.Limits[0], g.Limits[1], args.search.desc, sw,
+				msg.Deadline, iter)
+		}
+	case "within":
+		sw.col.Within(args.search.obj, 0, sw, msg.Deadline, iter)
+	case "intersects":
+		sw.col.Intersects(args.search.obj, 0, sw, msg.Deadline, iter)
+	}
+	if args.dryrun {
+		return n, nil
+	}
+	if args.resume {
+		args.offset = sw.numberIters
+	}
+	return apply(sw.col, ids)
+}
+
+// runBulk runs the batches of a bulk command until there are no more
+// objects, or until the LIMIT of the search. It returns the number of
+// objects that were done.
+func (s *Server) runBulk(msg *Message, args *bulkArgs,
+	lock func() (unlock func()),
+	apply func(col *collection.Collection, ids []string) (int, error),
+) (n int, err error) {
+	limit := math.MaxInt32
+	if args.search.ulimit && args.search.limit < uint64(limit) {
+		limit = int(args.search.limit)
+	}
+	if args.dryrun {
+		return s.bulkBatch(msg, args, limit, lock, apply)
+	}
+	if s.config.followHost() != "" {
+		return 0, errors.New("not the leader")
+	}
+	if s.config.readOnly() {
+		return 0, errors.New("read only")
+	}
+	for n < limit {
+		if err := s.waitWrites(); err != nil {
+			return n, err
+		}
+		msg.Deadline.Check()
+		size := args.batch
+		if size > limit-n {
+			size = limit - n
+		}
+		done, err := s.bulkBatch(msg, args, size, lock, apply)
+		n += done
+		s.queryProgress(msg, n)
+		if err != nil {
+			return n, err
+		}
+		if done < size {
+			break
+		}
+	}
+	return n, nil
+}
+
+// bulkWrite runs a write command for an object of a bulk command, and
+// writes it to the AOF. It returns false when nothing was written.
+func (s *Server) bulkWrite(args []string,
+	cmd func(msg *Message) (resp.Value, commandDetails, error),
+) (updated bool, err error) {
+	msg := &Message{Args: args, OutputType: RESP}
+	_, d, err := cmd(msg)
+	if err != nil || !d.updated {
+		return false, err
+	}
+	return true, s.writeAOF(msg.Args, &d)
+}
+
+// bulkReply returns the number of objects that a bulk command has done, or
+// has found for a dry run.
+func bulkReply(msg *Message, args *bulkArgs, field string, n int,
+	start time.Time,
+) resp.Value {
+	switch msg.OutputType {
+	case JSON:
+		if args.dryrun {
+			field = "count"
+		}
+		return resp.StringValue(`{"ok":true,"` + field + `":` +
+			strconv.Itoa(n) + `,"elapsed":"` + time.Since(start).String() + `"}`)
+	case RESP:
+		return resp.IntegerValue(n)
+	}
+	return NOMessage
+}
+
+// cmdDelWhere deletes the objects that match a scan or a search, and
+// returns the number of objects that were deleted. A DRYRUN only counts
+// them. The LIMIT of the search is the most objects to delete.
+//
+//	DELWHERE [BATCH size] [DRYRUN] SCAN|WITHIN|INTERSECTS key [options] [area]
+func (s *Server) cmdDelWhere(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	args, err := s.cmdBulkArgs("DELWHERE", msg.Args[1:], "")
+	if args.search.usingLua() {
+		defer args.search.Close()
+		defer func() {
+			if r := recover(); r != nil {
+				res = NOMessage
+				err = errors.New(r.(string))
+				return
+			}
+		}()
+	}
+	if err != nil {
+		return NOMessage, err
+	}
+	key := args.search.key
+	n, err := s.runBulk(msg, &args, func() func() {
+		return s.lockKeyWriteFor(key, func(col *collection.Collection) bool {
+			return col.Count() <= args.batch
+		})
+	}, func(col *collection.Collection, ids []string) (n int, err error) {
+		for _, id := range ids {
+			deleted, err := s.bulkWrite([]string{"del", key, id}, s.cmdDel)
+			if err != nil {
+				return n, err
+			}
+			if deleted {
+				n++
+			}
+		}
+		return n, nil
+	})
+	if err != nil {
+		return NOMessage, err
+	}
+	return bulkReply(msg, &args, "deleted", n, start), nil
+}
+
+// appendSetArgs appends the SET command that writes an object with its
+// fields, string fields, tags, metadata and expiration to a key.
+func appendSetArgs(dst []string, key, id string, obj geojson.Object,
+	fmap map[string]int, fnames []string, fields []float64, tags []string,
+	meta string, snames, svalues []string, ex, now int64,
+) []string {
+	dst = append(dst, "set", key, id)
+	if len(fields) > 0 {
+		for _, fv := range orderFields(fmap, fnames, fields) {
+			if fv.value != 0 {
+				dst = append(dst, "field", fv.field,
+					strconv.FormatFloat(fv.value, 'f', -1, 64))
+			}
+		}
+	}
+	for i, name := range snames {
+		dst = append(dst, "sfield", name, svalues[i])
+	}
+	for _, tag := range tags {
+		dst = append(dst, "tag", tag)
+	}
+	if meta != "" {
+		dst = append(dst, "meta", meta)
+	}
+	if ex != 0 {
+		dst = append(dst, "ex",
+			strconv.FormatFloat(aofTTL(ex, now), 'f', -1, 64))
+	}
+	if objIsSpatial(obj) {
+		dst = append(dst, "object", string(obj.AppendJSON(nil)))
+	} else {
+		dst = append(dst, "string", obj.String())
+	}
+	return dst
+}
+
+// copyObjects writes the objects of the src key to the dst key, with their
+// fields and expirations, and deletes them from src for a move.
+func (s *Server) copyObjects(col *collection.Collection, src, dst string,
+	ids []string, move bool,
+) (n int, err error) {
+	fmap, fnames := col.FieldMap(), col.FieldArr()
+	now := time.Now().UnixNano()
+	for _, id := range ids {
+		obj, fields, ex, ok := col.Get(id)
+		if !ok {
+			continue
+		}
+		fexs := col.FieldExpires(id)
+		meta, _ := col.Meta(id)
+		snames, svalues := col.StringFields(id)
+		args := appendSetArgs(nil, dst, id, obj, fmap, fnames, fields,
+			col.Tags(id), meta, snames, svalues, ex, now)
+		if _, err := s.bulkWrite(args, s.cmdSet); err != nil {
+			return n, err
+		}
+		for field, fex := range fexs {
+			var value float64
+			if idx, ok := fmap[field]; ok && idx < len(fields) {
+				value = fields[idx]
+			}
+			args := []string{"fset", dst, id, field,
+				strconv.FormatFloat(value, 'f', -1, 64),
+				"ex", strconv.FormatFloat(aofTTL(fex, now), 'f', -1, 64)}
+			if _, err := s.bulkWrite(args, s.cmdFset); err != nil {
+				return n, err
+			}
+		}
+		if move {
+			if _, err := s.bulkWrite([]string{"del", src, id},
+				s.cmdDel); err != nil {
+				return n, err
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+// cmdCopy copies or moves the objects of a key that match a scan or a
+// search to another key, and returns the number of objects that were
+// copied. The objects that are already in the other key are replaced. A
+// DRYRUN only counts them.
+//
+//	COPY src dst [BATCH size] [DRYRUN] SCAN|WITHIN|INTERSECTS [options] [area]
+//	MOVE src dst [BATCH size] [DRYRUN] SCAN|WITHIN|INTERSECTS [options] [area]
+func (s *Server) cmdCopy(msg *Message, move bool) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var src, dst string
+	if vs, src, ok = tokenval(vs); !ok || src == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, dst, ok = tokenval(vs); !ok || dst == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if src == dst {
+		return NOMessage, errors.New("source and destination keys are the same")
+	}
+	args, err := s.cmdBulkArgs(strings.ToUpper(msg.Command()), vs, src)
+	if args.search.usingLua() {
+		defer args.search.Close()
+		defer func() {
+			if r := recover(); r != nil {
+				res = NOMessage
+				err = errors.New(r.(string))
+				return
+			}
+		}()
+	}
+	if err != nil {
+		return NOMessage, err
+	}
+	args.resume = !move
+	n, err := s.runBulk(msg, &args, s.lockAllWrite,
+		func(col *collection.Collection, ids []string) (int, error) {
+			return s.copyObjects(col, src, dst, ids, move)
+		})
+	if err != nil {
+		return NOMessage, err
+	}
+	field := "copied"
+	if move {
+		field = "moved"
+	}
+	return bulkReply(msg, &args, field, n, start), nil
+}
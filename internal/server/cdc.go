@@ -0,0 +1,350 @@
+package server
+
+// CDC: the change data capture stream, for consumers that follow the
+// changes of the server without parsing the AOF. CDC offset is like AOF pos
+// on the replication port, but the commands of the AOF are sent as the
+// records of internal/cdc, whose cdc.proto documents the stream.
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/redcon"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/cdc"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// cdcCheckpoint is the number of records between checkpoints.
+const cdcCheckpoint = 1000
+
+type liveCDCSwitches struct {
+	pos int64
+}
+
+func (s liveCDCSwitches) Error() string {
+	return goingLive
+}
+
+// cmdCDC starts the CDC stream of the changes after an offset of the AOF.
+//
+//	CDC offset
+func (s *Server) cmdCDC(msg *Message) (res resp.Value, err error) {
+	if s.aof == nil {
+		return NOMessage, errors.New("aof disabled")
+	}
+	vs := msg.Args[1:]
+	var ok bool
+	var spos string
+	if vs, spos, ok = tokenval(vs); !ok || spos == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	pos, err := strconv.ParseInt(spos, 10, 64)
+	if err != nil || pos < 0 {
+		return NOMessage, errInvalidArgument(spos)
+	}
+	fi, err := os.Stat(s.aof.Name())
+	if err != nil {
+		return NOMessage, err
+	}
+	if fi.Size() < pos {
+		return NOMessage, errors.New("offset is too big, must be less than the aof_size")
+	}
+	return NOMessage, liveCDCSwitches{pos: pos}
+}
+
+// cdcSet returns the Set record of an object of SET or MSET, and the
+// arguments after it.
+func (s *Server) cdcSet(key string, vs []string, multi bool,
+) ([]string, *cdc.Set, error) {
+	now := time.Now().UnixNano()
+	vs, d, fields, values, _, _, _, ex, _, _, err :=
+		s.parseSetObjectArgs(key, vs, multi)
+	if err != nil {
+		return nil, nil, err
+	}
+	set := &cdc.Set{Key: key, ID: d.id, Object: s.snapObject(key, d.obj).JSON()}
+	if ex != 0 {
+		// back to the seconds of EX, to the millisecond
+		set.Ex = math.Round(float64(ex-now)/1e6) / 1e3
+	}
+	for i, name := range fields {
+		if set.Fields == nil {
+			set.Fields = make(map[string]float64)
+		}
+		set.Fields[name] = values[i]
+	}
+	return vs, set, nil
+}
+
+// cdcRecords appends the records of a command of the AOF, which is a Command
+// record when it isn't one of the changes of the schema.
+func (s *Server) cdcRecords(recs []cdc.Record, offset int64, args []string,
+) []cdc.Record {
+	rec := cdc.Record{Offset: offset}
+	switch strings.ToLower(args[0]) {
+	case "set":
+		if len(args) > 2 {
+			s.mu.RLock()
+			vs, set, err := s.cdcSet(args[1], args[2:], false)
+			s.mu.RUnlock()
+			if err == nil && len(vs) == 0 {
+				rec.Set = set
+			}
+		}
+	case "mset":
+		if len(args) > 2 {
+			n := len(recs)
+			vs := args[2:]
+			var err error
+			s.mu.RLock()
+			for err == nil && len(vs) > 1 && lc(vs[0], "id") {
+				var set *cdc.Set
+				if vs, set, err = s.cdcSet(args[1], vs[1:], true); err == nil {
+					recs = append(recs, cdc.Record{Offset: offset, Set: set})
+				}
+			}
+			s.mu.RUnlock()
+			if err == nil && len(vs) == 0 {
+				return recs
+			}
+			recs = recs[:n]
+		}
+	case "fset":
+		d, fields, values, _, _, err := s.parseFSetArgs(args[1:])
+		if err == nil {
+			rec.FSet = &cdc.FSet{Key: d.key, ID: d.id,
+				Fields: make(map[string]float64, len(fields))}
+			for i, name := range fields {
+				rec.FSet.Fields[name] = values[i]
+			}
+		}
+	case "del":
+		if len(args) == 3 {
+			rec.Del = &cdc.Del{Key: args[1], ID: args[2]}
+		}
+	case "expire", "pexpire", "expireat", "pexpireat":
+		if len(args) == 4 {
+			value, err := strconv.ParseFloat(args[3], 64)
+			if err == nil {
+				rec.Expire = &cdc.Expire{Key: args[1], ID: args[2]}
+				switch strings.ToLower(args[0]) {
+				case "expire":
+					rec.Expire.Seconds = value
+				case "pexpire":
+					rec.Expire.Seconds = value / 1000
+				case "expireat":
+					rec.Expire.At = int64(value * 1000)
+				case "pexpireat":
+					rec.Expire.At = int64(value)
+				}
+			}
+		}
+	case "persist":
+		if len(args) == 3 {
+			rec.Persist = &cdc.Persist{Key: args[1], ID: args[2]}
+		}
+	case "drop":
+		if len(args) == 2 {
+			rec.Drop = &cdc.Drop{Key: args[1]}
+		}
+	case "rename", "renamenx":
+		if len(args) == 3 {
+			rec.Rename = &cdc.Rename{Key: args[1], NewKey: args[2]}
+		}
+	case "flushdb":
+		if len(args) == 1 {
+			rec.FlushDB = &cdc.FlushDB{}
+		}
+	}
+	if rec.Set == nil && rec.FSet == nil && rec.Del == nil &&
+		rec.Expire == nil && rec.Persist == nil && rec.Drop == nil &&
+		rec.Rename == nil && rec.FlushDB == nil {
+		rec.Command = &cdc.Command{Args: args}
+	}
+	return append(recs, rec)
+}
+
+// liveCDC sends the records of the AOF after pos, and then the records of
+// the new changes, until the connection is closed or the consumer quits.
+func (s *Server) liveCDC(pos int64, conn net.Conn, rd *PipelineReader) error {
+	s.mu.RLock()
+	f, err := os.Open(s.aof.Name())
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// closed with the followers when the AOF is shrunk
+	s.mu.Lock()
+	s.aofconnM[conn] = f
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.aofconnM, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	if _, err := conn.Write([]byte("+OK\r\n")); err != nil {
+		return err
+	}
+	if _, err := f.Seek(pos, 0); err != nil {
+		return err
+	}
+	cond := sync.NewCond(&sync.Mutex{})
+	var mustQuit bool
+	quit := func() {
+		cond.L.Lock()
+		mustQuit = true
+		cond.Broadcast()
+		cond.L.Unlock()
+	}
+	go func() {
+		defer quit()
+		for {
+			vs, err := rd.ReadMessages()
+			if err != nil {
+				if err != io.EOF {
+					log.Error(err)
+				}
+				return
+			}
+			for _, v := range vs {
+				switch v.Command() {
+				default:
+					log.Error("received a live command that was not QUIT")
+					return
+				case "quit", "":
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		defer quit()
+		if err := s.sendCDC(pos, f, conn); err != nil {
+			if !strings.Contains(err.Error(), "use of closed network connection") &&
+				!strings.Contains(err.Error(), "bad file descriptor") {
+				log.Error(err)
+			}
+		}
+	}()
+	for {
+		cond.L.Lock()
+		if mustQuit {
+			cond.L.Unlock()
+			return nil
+		}
+		cond.Wait()
+		cond.L.Unlock()
+	}
+}
+
+// sendCDC reads the commands of the AOF from pos and sends their records,
+// with a checkpoint after every cdcCheckpoint records and when the end of
+// the AOF is reached.
+func (s *Server) sendCDC(pos int64, f *os.File, conn net.Conn) error {
+	out := cdc.AppendRecord(nil, cdc.Record{Offset: pos,
+		Header: &cdc.Header{Version: cdc.Version}})
+	offset := pos
+	var data []byte
+	var args [][]byte
+	var recs []cdc.Record
+	var count int     // of the records since the checkpoint
+	var caughtUp bool // once the end of the AOF was reached
+	checkpoint := func() {
+		out = cdc.AppendRecord(out, cdc.Record{Offset: offset,
+			Checkpoint: &cdc.Checkpoint{Time: time.Now().UnixNano() / 1e6}})
+		count = 0
+	}
+	b := make([]byte, 65536)
+	for {
+		n, err := f.Read(b)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		data = append(data, b[:n]...)
+		for {
+			for len(data) > 0 && data[0] == 0 {
+				// trailing zeros, see loadAOF
+				data = data[1:]
+				offset++
+			}
+			var complete bool
+			var rest []byte
+			complete, args, _, rest, err = redcon.ReadNextCommand(data,
+				args[:0])
+			if err != nil {
+				return err
+			}
+			if !complete {
+				break
+			}
+			offset += int64(len(data) - len(rest))
+			data = rest
+			if len(args) == 0 {
+				continue
+			}
+			sargs := make([]string, len(args))
+			for i, arg := range args {
+				sargs[i] = string(arg)
+			}
+			recs = s.cdcRecords(recs[:0], offset, sargs)
+			for _, rec := range recs {
+				out = cdc.AppendRecord(out, rec)
+			}
+			if count += len(recs); count >= cdcCheckpoint {
+				checkpoint()
+			}
+		}
+		data = append(data[:0:0], data...)
+		if n == 0 {
+			if count > 0 || !caughtUp {
+				checkpoint()
+				caughtUp = true
+			}
+		}
+		if len(out) > 0 && (n == 0 || len(out) >= 65536) {
+			if _, err := conn.Write(out); err != nil {
+				return err
+			}
+			out = out[:0]
+		}
+		if n == 0 {
+			// wait for the next write, unless there's one that isn't read
+			// or isn't flushed yet
+			s.fcond.L.Lock()
+			dirty := atomic.LoadInt32(&s.aofdirty) != 0
+			if !dirty && !aofGrew(f) {
+				s.fcond.Wait()
+			}
+			s.fcond.L.Unlock()
+			if dirty {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}
+}
+
+// aofGrew returns true when the AOF is larger than the position of f.
+func aofGrew(f *os.File) bool {
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Size() > pos
+}
@@ -0,0 +1,92 @@
+package server
+
+// CENTROID output: the center of the matching objects of a search, which
+// with WEIGHT is weighted by a field, such as the passengers that are
+// waiting at stops for the center of demand. The centers of the objects are
+// added up on the sphere while searching, so the centroid of objects on
+// both sides of the antimeridian is between them.
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/resp"
+)
+
+// addCentroid adds the center of a matching object to the centroid.
+func (sw *scanWriter) addCentroid(o geojson.Object, fields []float64) {
+	if !objIsSpatial(o) {
+		return
+	}
+	weight := 1.0
+	if sw.centroidField != "" {
+		weight = 0
+		if idx, ok := sw.fmap[sw.centroidField]; ok && idx < len(fields) {
+			weight = fields[idx]
+		}
+		if weight <= 0 {
+			return
+		}
+	}
+	center := o.Center()
+	lat, lon := center.Y*math.Pi/180, center.X*math.Pi/180
+	sw.centroid[0] += weight * math.Cos(lat) * math.Cos(lon)
+	sw.centroid[1] += weight * math.Cos(lat) * math.Sin(lon)
+	sw.centroid[2] += weight * math.Sin(lat)
+	sw.centroidWeight += weight
+}
+
+// centroidObject returns the centroid of the matching objects, or nil when
+// there are none.
+func (sw *scanWriter) centroidObject() geojson.Object {
+	if sw.centroidWeight == 0 {
+		return nil
+	}
+	x, y, z := sw.centroid[0], sw.centroid[1], sw.centroid[2]
+	var o geojson.Object = geojson.NewPoint(geometry.Point{
+		X: math.Atan2(y, x) * 180 / math.Pi,
+		Y: math.Atan2(z, math.Sqrt(x*x+y*y)) * 180 / math.Pi,
+	})
+	if sw.crs != nil {
+		o = sw.s.transformObject(o, sw.crs)
+	}
+	return o
+}
+
+// writeCentroid writes the centroid of the matching objects and their total
+// weight for JSON, and returns them for RESP.
+func (sw *scanWriter) writeCentroid() (point, weight resp.Value) {
+	o := sw.centroidObject()
+	sweight := strconv.FormatFloat(sw.centroidWeight, 'f', -1, 64)
+	switch sw.msg.OutputType {
+	case JSON:
+		sw.wr.WriteString(`,"centroid":`)
+		if o == nil {
+			sw.wr.WriteString("null")
+		} else {
+			sw.wr.Write(sw.appendObjectJSON(nil, o))
+		}
+		sw.wr.WriteString(`,"weight":` + sweight)
+	case RESP:
+		point = resp.NullValue()
+		if o != nil {
+			point = resp.StringValue(string(sw.appendObjectJSON(nil, o)))
+		}
+		weight = resp.StringValue(sweight)
+	}
+	return point, weight
+}
+
+// parseCentroid parses the "[WEIGHT field]" arguments of CENTROID.
+func parseCentroid(vs []string) (nvs []string, field string, err error) {
+	if len(vs) == 0 || !lc(vs[0], "weight") {
+		return vs, "", nil
+	}
+	var ok bool
+	if vs, field, ok = tokenval(vs[1:]); !ok || field == "" {
+		return vs, "", errInvalidNumberOfArguments
+	}
+	return vs, field, nil
+}
@@ -1,16 +1,19 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/deadline"
 )
 
 // Client is an remote connection into to Tile38
@@ -19,14 +22,25 @@ type Client struct {
 	replPort   int            // the known replication port for follower connections
 	authd      bool           // client has been authenticated
 	outputType Type           // Null, JSON, or RESP
+	compress   compression    // reply compression, set by OUTPUT COMPRESS
 	remoteAddr string         // original remote address
 	in         InputStream    // input stream
 	pr         PipelineReader // command reader
 	out        []byte         // output write buffer
+	outSince   time.Time      // out is over the soft limit since, see outputlimit.go
+	netConn    net.Conn       // underlying network connection
+	pending    []byte         // input read while watching the connection
 
 	goLiveErr error    // error type used for going line
 	goLiveMsg *Message // last message for go live
 
+	namespace string // namespace of the client, see namespace.go
+	nsBound   bool   // authenticated to the namespace, can't SELECT
+	role      string // read-only role of the client, see redact.go
+	user      string // JWT subject or LDAP user, see auth.go
+
+	cursors clientCursors // open cursors, see cursors.go
+
 	mu     sync.Mutex         // guard
 	conn   io.ReadWriteCloser // out-of-loop connection.
 	name   string             // optional defined name
@@ -40,6 +54,69 @@ func (client *Client) Write(b []byte) (n int, err error) {
 	return len(b), nil
 }
 
+// clientWatchDelay is how long a command runs before the server starts
+// watching the client connection for a disconnect.
+const clientWatchDelay = 10 * time.Millisecond
+
+// watchClient cancels the command when the client disconnects while the
+// command is running. The connection is only watched after the command has
+// been running for the clientWatchDelay, which leaves the short commands
+// alone. Input that arrives while the connection is watched is kept in
+// client.pending for the next read. The returned function stops watching
+// and must be called before the connection is read again.
+func (server *Server) watchClient(client *Client, msg *Message) (stop func()) {
+	if client == nil || client.netConn == nil {
+		return func() {}
+	}
+	// a query that's killed cancels the watch too, see queries.go
+	ctx, cancel := context.WithCancel(msg.Deadline.Parent())
+	if msg.Deadline == nil {
+		msg.Deadline = deadline.WithContext(ctx)
+	} else {
+		msg.Deadline.SetContext(ctx)
+	}
+	conn := client.netConn
+	var mu sync.Mutex
+	var stopped, watching bool
+	var wg sync.WaitGroup
+	timer := time.AfterFunc(clientWatchDelay, func() {
+		mu.Lock()
+		if stopped {
+			mu.Unlock()
+			return
+		}
+		watching = true
+		wg.Add(1)
+		mu.Unlock()
+		defer wg.Done()
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			client.pending = append(client.pending, buf[:n]...)
+			if err != nil {
+				if err, ok := err.(net.Error); !ok || !err.Timeout() {
+					// the client is gone
+					cancel()
+				}
+				return
+			}
+		}
+	})
+	return func() {
+		timer.Stop()
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+		if watching {
+			// wake up the reader
+			conn.SetReadDeadline(time.Now())
+			wg.Wait()
+			conn.SetReadDeadline(time.Time{})
+		}
+		cancel()
+	}
+}
+
 type byID []*Client
 
 func (arr byID) Len() int {
@@ -0,0 +1,169 @@
+package server
+
+// Clustering: the CLUSTER output of WITHIN and INTERSECTS returns the
+// clusters of the matching objects at a zoom level, rather than the objects
+// themselves. A search without filters reads from an index of the whole key,
+// which is kept with the key and rebuilt on the first search after a write.
+// A search with filters clusters the objects that it matches.
+
+import (
+	"strconv"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/cluster"
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/deadline"
+	"github.com/tidwall/tile38/internal/simplify"
+)
+
+// maxClusterZoom is the highest zoom level of the CLUSTER output.
+const maxClusterZoom = 30
+
+// clusterCache is the cluster index of a collection at a version.
+type clusterCache struct {
+	col     *collection.Collection
+	version uint64
+	index   *cluster.Index
+}
+
+// clusterIndex returns the cluster index of the collection of a key. The
+// collection may be a snapshot of the key.
+func (server *Server) clusterIndex(key string, col *collection.Collection,
+	dl *deadline.Deadline,
+) *cluster.Index {
+	c := server.getColContainer(key)
+	if c == nil {
+		return cluster.New(clusterPoints(col, dl), cluster.DefaultOptions)
+	}
+	if cache, _ := c.clusters.Load().(*clusterCache); cache != nil &&
+		cache.col == col && cache.version == col.Version() {
+		return cache.index
+	}
+	c.clusterMu.Lock()
+	defer c.clusterMu.Unlock()
+	if cache, _ := c.clusters.Load().(*clusterCache); cache != nil &&
+		cache.col == col && cache.version == col.Version() {
+		return cache.index
+	}
+	cache := &clusterCache{col: col, version: col.Version()}
+	cache.index = cluster.New(clusterPoints(col, dl), cluster.DefaultOptions)
+	c.clusters.Store(cache)
+	return cache.index
+}
+
+// clusterPoints returns the centers of the spatial objects.
+func clusterPoints(col *collection.Collection, dl *deadline.Deadline,
+) []cluster.Point {
+	points := make([]cluster.Point, 0, col.Count())
+	col.Scan(false, nil, dl, func(id string, o geojson.Object, _ []float64,
+	) bool {
+		if objIsSpatial(o) {
+			center := o.Center()
+			points = append(points,
+				cluster.Point{ID: id, X: center.X, Y: center.Y})
+		}
+		return true
+	})
+	return points
+}
+
+// clusterFiltered returns true when the search has options that filter
+// out objects, which rules out the index of the whole key.
+func (sw *scanWriter) clusterFiltered() bool {
+	return !sw.globEverything || len(sw.wheres) > 0 ||
+		len(sw.whereins) > 0 || len(sw.whereevals) > 0 || len(sw.tagged) > 0
+}
+
+// writeClusters writes the clusters at the zoom level, and sets the count to
+// the number of objects in them.
+func (sw *scanWriter) writeClusters() {
+	var clusters []cluster.Cluster
+	if sw.clusterIdx != nil {
+		// the index has the whole key, keep the clusters that have their
+		// center in the area
+		rect := sw.area.Rect()
+		for _, c := range sw.clusterIdx.Clusters(rect, sw.zoom) {
+			center := geometry.Point{X: c.X, Y: c.Y}
+			if sw.area.Spatial().IntersectsPoint(center) {
+				clusters = append(clusters, c)
+			}
+		}
+	} else {
+		index := cluster.New(sw.clusterPoints, cluster.DefaultOptions)
+		clusters = index.Clusters(worldRect, sw.zoom)
+	}
+	sw.count = 0
+	if sw.msg.OutputType == JSON {
+		sw.wr.WriteString(`,"clusters":[`)
+	}
+	for i, c := range clusters {
+		sw.count += uint64(c.Count)
+		var point, bounds geojson.Object
+		point = geojson.NewPoint(geometry.Point{X: c.X, Y: c.Y})
+		bounds = geojson.NewRect(c.Rect)
+		if sw.redact != nil {
+			// the index of the whole key has the exact objects
+			point = sw.redact.object(point)
+			bounds = sw.redact.object(bounds)
+		}
+		if sw.crs != nil {
+			point = sw.s.transformObject(point, sw.crs)
+			bounds = sw.s.transformObject(bounds, sw.crs)
+		}
+		digits := sw.reshape.Precision
+		switch sw.msg.OutputType {
+		case JSON:
+			if i > 0 {
+				sw.wr.WriteByte(',')
+			}
+			sw.wr.WriteByte('{')
+			if c.Count == 1 {
+				sw.wr.WriteString(`"id":` + jsonString(c.ID) + `,`)
+			}
+			sw.wr.Write(appendJSONSimplePoint([]byte(`"point":`), point, digits))
+			sw.wr.WriteString(`,"count":` + strconv.Itoa(c.Count))
+			if c.Count > 1 {
+				sw.wr.Write(appendJSONSimpleBounds([]byte(`,"bounds":`), bounds,
+					digits))
+				sw.wr.WriteString(`,"expansion_zoom":` + strconv.Itoa(c.ExpansionZoom))
+			}
+			sw.wr.WriteByte('}')
+		case RESP:
+			id := resp.NullValue()
+			if c.Count == 1 {
+				id = resp.StringValue(c.ID)
+			}
+			center, rect := point.Center(), bounds.Rect()
+			sw.values = append(sw.values, resp.ArrayValue([]resp.Value{
+				id,
+				resp.IntegerValue(c.Count),
+				resp.ArrayValue([]resp.Value{
+					resp.FloatValue(simplify.Round(center.Y, digits)),
+					resp.FloatValue(simplify.Round(center.X, digits)),
+				}),
+				resp.ArrayValue([]resp.Value{
+					resp.ArrayValue([]resp.Value{
+						resp.FloatValue(simplify.Round(rect.Min.Y, digits)),
+						resp.FloatValue(simplify.Round(rect.Min.X, digits)),
+					}),
+					resp.ArrayValue([]resp.Value{
+						resp.FloatValue(simplify.Round(rect.Max.Y, digits)),
+						resp.FloatValue(simplify.Round(rect.Max.X, digits)),
+					}),
+				}),
+				resp.IntegerValue(c.ExpansionZoom),
+			}))
+		}
+	}
+	if sw.msg.OutputType == JSON {
+		sw.wr.WriteByte(']')
+	}
+}
+
+// worldRect is the rectangle of all WGS84 coordinates.
+var worldRect = geometry.Rect{
+	Min: geometry.Point{X: -180, Y: -90},
+	Max: geometry.Point{X: 180, Y: 90},
+}
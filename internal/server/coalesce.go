@@ -0,0 +1,197 @@
+package server
+
+// Coalescing: a key that has KEYCONFIG COALESCE holds the SETs of its
+// objects for a window of seconds, and then applies the last SET of each id,
+// which spares the indexes and the geofences the updates in between, for the
+// bursts of the gateways that buffer the positions of their devices. The
+// client gets OK when the SET is held. The SETs in between are dropped, or
+// with COALESCELOG they're written to the AOF before the last one, and so
+// the AOF and the followers have all of the positions.
+//
+// Any other command from a client on the key, a GET too, applies the held
+// SETs first, which keeps the order of the writes and the reads. A search
+// of another key with a GET of an object of the key, and a script, don't.
+// SETs with NX, XX or IF aren't held. A SET that would fail, which doesn't
+// parse or doesn't match the schema of the key, isn't held either, so that it
+// runs now and its error is returned to the client, and the SETs of a client
+// in a namespace with quotas aren't held, as the held objects aren't counted.
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// coalescedSet is the last SET of an id that's held, and the SETs before it
+// when they're logged.
+type coalescedSet struct {
+	args    []string
+	skipped [][]string
+}
+
+// coalescedKey are the SETs that are held for a key, in the order of the
+// ids.
+type coalescedKey struct {
+	ids   map[string]*coalescedSet
+	order []string
+}
+
+// coalescer holds the SETs of the keys that have COALESCE.
+type coalescer struct {
+	mu      sync.Mutex
+	keys    map[string]*coalescedKey
+	pending int32 // atomic, the number of keys
+	held    aint  // the SETs that were held
+	skipped aint  // the SETs that were replaced by another one
+}
+
+// has returns true when SETs of a key are held.
+func (cs *coalescer) has(key string) bool {
+	if atomic.LoadInt32(&cs.pending) == 0 {
+		return false
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.keys[key] != nil
+}
+
+// take removes the SETs of a key and returns them.
+func (cs *coalescer) take(key string) *coalescedKey {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	ck := cs.keys[key]
+	if ck != nil {
+		delete(cs.keys, key)
+		atomic.AddInt32(&cs.pending, -1)
+	}
+	return ck
+}
+
+// dropAll removes all of the SETs.
+func (cs *coalescer) dropAll() {
+	cs.mu.Lock()
+	atomic.AddInt32(&cs.pending, -int32(len(cs.keys)))
+	cs.keys = nil
+	cs.mu.Unlock()
+}
+
+// coalesceWrite holds a SET of a key that has COALESCE and returns true.
+// Otherwise it applies the held SETs of the key of the command, and returns
+// false for the command to run.
+func (server *Server) coalesceWrite(msg *Message) bool {
+	if len(msg.Args) < 2 {
+		return false
+	}
+	key := msg.Args[1]
+	if msg.Command() == "set" && msg.idempotency == "" && server.holdSet(msg) {
+		return true
+	}
+	if server.coalescer.has(key) {
+		server.applyCoalesced(key)
+	}
+	return false
+}
+
+// holdSet holds a SET, and returns false when the SET must run now.
+func (server *Server) holdSet(msg *Message) bool {
+	key := msg.Args[1]
+	server.mu.RLock()
+	conf := server.keyConfigs[key]
+	if conf.coalesce == 0 || server.config.followHost() != "" ||
+		server.config.readOnly() || server.writesPause() != nil ||
+		server.rejectWrites() {
+		server.mu.RUnlock()
+		return false
+	}
+	d, fields, values, xx, nx, conds, _, _, _, err :=
+		server.parseSetArgs(msg.Args[1:])
+	if err != nil || xx || nx || len(conds) > 0 ||
+		server.hasNamespaceQuotas(msg.namespace) ||
+		server.checkMeta(msg, d.meta) != nil {
+		server.mu.RUnlock()
+		return false
+	}
+	args := msg.Args
+	if conf.timestamps {
+		// the time of the SET rather than the time that it's applied
+		var sargs []string
+		fields, values, sargs =
+			server.stampArgs(key, fields, values, msg.Args[2:])
+		args = append(append([]string(nil), msg.Args[:2]...), sargs...)
+	}
+	if schema := server.schema(msg, key); schema != nil {
+		err = schema.checkObject(server.getCol(key), key, d.id,
+			d.obj, fields, values)
+	}
+	server.mu.RUnlock()
+	if err != nil {
+		return false
+	}
+	id := d.id
+	cs := &server.coalescer
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.keys == nil {
+		cs.keys = make(map[string]*coalescedKey)
+	}
+	ck := cs.keys[key]
+	if ck == nil {
+		ck = &coalescedKey{ids: make(map[string]*coalescedSet)}
+		cs.keys[key] = ck
+		atomic.AddInt32(&cs.pending, 1)
+		window := time.Duration(conf.coalesce * float64(time.Second))
+		time.AfterFunc(window, func() { server.applyCoalesced(key) })
+	}
+	cs.held.add(1)
+	if set := ck.ids[id]; set != nil {
+		cs.skipped.add(1)
+		if conf.coalesceLog {
+			set.skipped = append(set.skipped, set.args)
+		}
+		set.args = args
+		return true
+	}
+	ck.ids[id] = &coalescedSet{args: args}
+	ck.order = append(ck.order, id)
+	return true
+}
+
+// applyCoalesced applies the held SETs of a key, with the lock of the key.
+func (server *Server) applyCoalesced(key string) {
+	if err := server.waitWrites(); err != nil {
+		// the writes are paused for longer than a client waits
+		time.AfterFunc(time.Second, func() { server.applyCoalesced(key) })
+		return
+	}
+	defer server.lockKeyWriteFor(key, func(*collection.Collection) bool {
+		return false
+	})()
+	ck := server.coalescer.take(key)
+	if ck == nil || server.config.followHost() != "" {
+		return
+	}
+	for _, id := range ck.order {
+		set := ck.ids[id]
+		for _, args := range set.skipped {
+			if err := server.writeAOF(args, nil); err != nil {
+				log.Fatal(err)
+			}
+		}
+		msg := &Message{Args: set.args, OutputType: RESP}
+		_, d, err := server.cmdSet(msg)
+		if err != nil {
+			log.Errorf("coalesce %s %s: %v", key, id, err)
+			continue
+		}
+		if err := server.writeAOF(msg.Args, &d); err != nil {
+			if _, ok := err.(errAOFHook); ok {
+				log.Errorf("coalesce %s %s: %v", key, id, err)
+				continue
+			}
+			log.Fatal(err)
+		}
+	}
+}
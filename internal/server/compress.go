@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/tidwall/redcon"
+)
+
+// compressMinSize is the smallest reply that will be compressed. Anything
+// smaller is not worth the cpu.
+const compressMinSize = 1024
+
+// compression is the encoding used for compressed replies.
+type compression byte
+
+const (
+	compressNone compression = iota
+	compressGzip
+	compressZstd
+)
+
+func (c compression) String() string {
+	switch c {
+	case compressGzip:
+		return "gzip"
+	case compressZstd:
+		return "zstd"
+	}
+	return "none"
+}
+
+func parseCompression(s string) (c compression, ok bool) {
+	switch strings.ToLower(s) {
+	case "none":
+		return compressNone, true
+	case "gzip":
+		return compressGzip, true
+	case "zstd":
+		return compressZstd, true
+	}
+	return compressNone, false
+}
+
+// parseAcceptEncoding returns the preferred compression from an HTTP
+// Accept-Encoding header value. Zstd is preferred over gzip. An encoding with
+// a q of zero, such as "gzip;q=0.000", is refused.
+func parseAcceptEncoding(value string) compression {
+	var c compression
+	for _, part := range strings.Split(value, ",") {
+		params := strings.Split(part, ";")
+		if !acceptQuality(params[1:]) {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(params[0])) {
+		case "zstd":
+			c = compressZstd
+		case "gzip":
+			if c == compressNone {
+				c = compressGzip
+			}
+		}
+	}
+	return c
+}
+
+// acceptQuality returns false when the parameters of an encoding have a q of
+// zero, or a q that isn't a number.
+func acceptQuality(params []string) bool {
+	for _, param := range params {
+		param = strings.TrimSpace(param)
+		if len(param) < 2 || strings.ToLower(param[:2]) != "q=" {
+			continue
+		}
+		q, err := strconv.ParseFloat(param[2:], 64)
+		if err != nil || q <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+var zstdEncoder struct {
+	once sync.Once
+	enc  *zstd.Encoder
+}
+
+// compressReply compresses the data. The data is returned as-is when
+// it's too small to be worth compressing.
+func compressReply(c compression, data []byte) (out []byte, compressed bool) {
+	if c == compressNone || len(data) < compressMinSize {
+		return data, false
+	}
+	switch c {
+	case compressGzip:
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(data); err != nil {
+			return data, false
+		}
+		if err := gz.Close(); err != nil {
+			return data, false
+		}
+		return buf.Bytes(), true
+	case compressZstd:
+		zstdEncoder.once.Do(func() {
+			zstdEncoder.enc, _ = zstd.NewWriter(nil)
+		})
+		return zstdEncoder.enc.EncodeAll(data, nil), true
+	}
+	return data, false
+}
+
+// appendCompressedFrame appends a RESP reply of a connection with OUTPUT
+// COMPRESS. Each reply of the connection is a bulk string that starts with
+// the encoding of the reply, "gzip:", "zstd:", or "none:" for the replies
+// that are too small to be compressed, which is followed by the reply.
+func appendCompressedFrame(dst []byte, c compression, frame []byte) []byte {
+	data, compressed := compressReply(c, frame)
+	if !compressed {
+		c = compressNone
+	}
+	payload := make([]byte, 0, len(c.String())+1+len(data))
+	payload = append(payload, c.String()...)
+	payload = append(payload, ':')
+	payload = append(payload, data...)
+	return redcon.AppendBulk(dst, payload)
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -11,31 +12,61 @@ import (
 
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
 	"github.com/tidwall/tile38/internal/glob"
 )
 
 const (
-	defaultKeepAlive     = 300 // seconds
-	defaultProtectedMode = "yes"
+	defaultKeepAlive       = 300 // seconds
+	defaultProtectedMode   = "yes"
+	defaultMaxMemoryPolicy = evictRejectWrites
+	defaultGeometryCodec   = "none"
+	defaultMapMatchDist    = 50   // meters
+	defaultCursorTTL       = 600  // seconds
+	defaultMetaMaxSize     = 4096 // bytes
+	defaultTenantWeights   = "read=1,write=1,search=5"
 )
 
 // Config keys
 const (
-	FollowHost    = "follow_host"
-	FollowPort    = "follow_port"
-	FollowID      = "follow_id"
-	FollowPos     = "follow_pos"
-	ServerID      = "server_id"
-	ReadOnly      = "read_only"
-	RequirePass   = "requirepass"
-	LeaderAuth    = "leaderauth"
-	ProtectedMode = "protected-mode"
-	MaxMemory     = "maxmemory"
-	AutoGC        = "autogc"
-	KeepAlive     = "keepalive"
+	FollowHost        = "follow_host"
+	FollowPort        = "follow_port"
+	FollowID          = "follow_id"
+	FollowPos         = "follow_pos"
+	ServerID          = "server_id"
+	ReadOnly          = "read_only"
+	RequirePass       = "requirepass"
+	LeaderAuth        = "leaderauth"
+	ProtectedMode     = "protected-mode"
+	MaxMemory         = "maxmemory"
+	MaxMemoryPolicy   = "maxmemory-policy"
+	AutoGC            = "autogc"
+	KeepAlive         = "keepalive"
+	SnapshotEpoch     = "snapshotepoch"
+	GeometryCodec     = "geometry-codec"
+	RoadNetwork       = "roadnetwork"
+	MapMatchDist      = "mapmatch-distance"
+	LayerDir          = "layerdir"
+	Warmup            = "warmup"
+	CursorTTL         = "cursor-ttl"
+	MetaMaxSize       = "meta-max-size"
+	JWTSecret         = "jwt-secret"
+	JWTPublicKey      = "jwt-public-key"
+	JWTJWKSURL        = "jwt-jwks-url"
+	JWTIssuer         = "jwt-issuer"
+	JWTAudience       = "jwt-audience"
+	JWTNamespaceClaim = "jwt-namespace-claim"
+	JWTRoleClaim      = "jwt-role-claim"
+	TenantRate        = "tenant-rate"
+	TenantBurst       = "tenant-burst"
+	TenantWeights     = "tenant-weights"
+	OutputLimitNormal = "output-limit-normal"
+	OutputLimitPubsub = "output-limit-pubsub"
+	LDAPURL           = "ldap-url"
+	LDAPBindDN        = "ldap-bind-dn"
 )
 
-var validProperties = []string{RequirePass, LeaderAuth, ProtectedMode, MaxMemory, AutoGC, KeepAlive}
+var validProperties = []string{RequirePass, LeaderAuth, ProtectedMode, MaxMemory, MaxMemoryPolicy, AutoGC, KeepAlive, SnapshotEpoch, GeometryCodec, RoadNetwork, MapMatchDist, LayerDir, Warmup, CursorTTL, MetaMaxSize, JWTSecret, JWTPublicKey, JWTJWKSURL, JWTIssuer, JWTAudience, JWTNamespaceClaim, JWTRoleClaim, LDAPURL, LDAPBindDN, TenantRate, TenantBurst, TenantWeights, OutputLimitNormal, OutputLimitPubsub}
 
 // Config is a tile38 config
 type Config struct {
@@ -50,18 +81,64 @@ type Config struct {
 	_serverID   string
 	_readOnly   bool
 
-	_requirePassP   string
-	_requirePass    string
-	_leaderAuthP    string
-	_leaderAuth     string
-	_protectedModeP string
-	_protectedMode  string
-	_maxMemoryP     string
-	_maxMemory      int64
-	_autoGCP        string
-	_autoGC         uint64
-	_keepAliveP     string
-	_keepAlive      int64
+	_requirePassP       string
+	_requirePass        string
+	_leaderAuthP        string
+	_leaderAuth         string
+	_protectedModeP     string
+	_protectedMode      string
+	_maxMemoryP         string
+	_maxMemory          int64
+	_maxMemoryPolicyP   string
+	_maxMemoryPolicy    string
+	_autoGCP            string
+	_autoGC             uint64
+	_keepAliveP         string
+	_keepAlive          int64
+	_snapshotEpochP     string
+	_snapshotEpoch      int64 // milliseconds
+	_geometryCodecP     string
+	_geometryCodec      string
+	_roadNetworkP       string
+	_roadNetwork        string
+	_mapMatchDistP      string
+	_mapMatchDist       float64 // meters
+	_layerDirP          string
+	_layerDir           string
+	_warmupP            string
+	_warmup             string // key patterns, see warmup.go
+	_cursorTTLP         string
+	_cursorTTL          int64 // seconds
+	_metaMaxSizeP       string
+	_metaMaxSize        int64 // bytes
+	_tenantRateP        string
+	_tenantRate         float64 // tokens per second, see tenants.go
+	_tenantBurstP       string
+	_tenantBurst        float64 // tokens
+	_tenantWeightsP     string
+	_tenantWeights      tenantWeights
+	_outputLimitNormalP string
+	_outputLimitNormal  outputLimit // see outputlimit.go
+	_outputLimitPubsubP string
+	_outputLimitPubsub  outputLimit
+	_jwtSecretP         string
+	_jwtSecret          string
+	_jwtPublicKeyP      string
+	_jwtPublicKey       string
+	_jwtJWKSURLP        string
+	_jwtJWKSURL         string
+	_jwtIssuerP         string
+	_jwtIssuer          string
+	_jwtAudienceP       string
+	_jwtAudience        string
+	_jwtNamespaceClaimP string
+	_jwtNamespaceClaim  string
+	_jwtRoleClaimP      string
+	_jwtRoleClaim       string
+	_ldapURLP           string
+	_ldapURL            string
+	_ldapBindDNP        string
+	_ldapBindDN         string
 }
 
 func loadConfig(path string) (*Config, error) {
@@ -77,19 +154,42 @@ func loadConfig(path string) (*Config, error) {
 		json = string(data)
 	}
 	config := &Config{
-		path:            path,
-		_followHost:     gjson.Get(json, FollowHost).String(),
-		_followPort:     gjson.Get(json, FollowPort).Int(),
-		_followID:       gjson.Get(json, FollowID).String(),
-		_followPos:      gjson.Get(json, FollowPos).Int(),
-		_serverID:       gjson.Get(json, ServerID).String(),
-		_readOnly:       gjson.Get(json, ReadOnly).Bool(),
-		_requirePassP:   gjson.Get(json, RequirePass).String(),
-		_leaderAuthP:    gjson.Get(json, LeaderAuth).String(),
-		_protectedModeP: gjson.Get(json, ProtectedMode).String(),
-		_maxMemoryP:     gjson.Get(json, MaxMemory).String(),
-		_autoGCP:        gjson.Get(json, AutoGC).String(),
-		_keepAliveP:     gjson.Get(json, KeepAlive).String(),
+		path:                path,
+		_followHost:         gjson.Get(json, FollowHost).String(),
+		_followPort:         gjson.Get(json, FollowPort).Int(),
+		_followID:           gjson.Get(json, FollowID).String(),
+		_followPos:          gjson.Get(json, FollowPos).Int(),
+		_serverID:           gjson.Get(json, ServerID).String(),
+		_readOnly:           gjson.Get(json, ReadOnly).Bool(),
+		_requirePassP:       gjson.Get(json, RequirePass).String(),
+		_leaderAuthP:        gjson.Get(json, LeaderAuth).String(),
+		_protectedModeP:     gjson.Get(json, ProtectedMode).String(),
+		_maxMemoryP:         gjson.Get(json, MaxMemory).String(),
+		_maxMemoryPolicyP:   gjson.Get(json, MaxMemoryPolicy).String(),
+		_autoGCP:            gjson.Get(json, AutoGC).String(),
+		_keepAliveP:         gjson.Get(json, KeepAlive).String(),
+		_snapshotEpochP:     gjson.Get(json, SnapshotEpoch).String(),
+		_geometryCodecP:     gjson.Get(json, GeometryCodec).String(),
+		_roadNetworkP:       gjson.Get(json, RoadNetwork).String(),
+		_mapMatchDistP:      gjson.Get(json, MapMatchDist).String(),
+		_layerDirP:          gjson.Get(json, LayerDir).String(),
+		_warmupP:            gjson.Get(json, Warmup).String(),
+		_cursorTTLP:         gjson.Get(json, CursorTTL).String(),
+		_metaMaxSizeP:       gjson.Get(json, MetaMaxSize).String(),
+		_tenantRateP:        gjson.Get(json, TenantRate).String(),
+		_tenantBurstP:       gjson.Get(json, TenantBurst).String(),
+		_tenantWeightsP:     gjson.Get(json, TenantWeights).String(),
+		_outputLimitNormalP: gjson.Get(json, OutputLimitNormal).String(),
+		_outputLimitPubsubP: gjson.Get(json, OutputLimitPubsub).String(),
+		_jwtSecretP:         gjson.Get(json, JWTSecret).String(),
+		_jwtPublicKeyP:      gjson.Get(json, JWTPublicKey).String(),
+		_jwtJWKSURLP:        gjson.Get(json, JWTJWKSURL).String(),
+		_jwtIssuerP:         gjson.Get(json, JWTIssuer).String(),
+		_jwtAudienceP:       gjson.Get(json, JWTAudience).String(),
+		_jwtNamespaceClaimP: gjson.Get(json, JWTNamespaceClaim).String(),
+		_jwtRoleClaimP:      gjson.Get(json, JWTRoleClaim).String(),
+		_ldapURLP:           gjson.Get(json, LDAPURL).String(),
+		_ldapBindDNP:        gjson.Get(json, LDAPBindDN).String(),
 	}
 	// load properties
 	if err := config.setProperty(RequirePass, config._requirePassP, true); err != nil {
@@ -104,12 +204,81 @@ func loadConfig(path string) (*Config, error) {
 	if err := config.setProperty(MaxMemory, config._maxMemoryP, true); err != nil {
 		return nil, err
 	}
+	if err := config.setProperty(MaxMemoryPolicy, config._maxMemoryPolicyP, true); err != nil {
+		return nil, err
+	}
 	if err := config.setProperty(AutoGC, config._autoGCP, true); err != nil {
 		return nil, err
 	}
 	if err := config.setProperty(KeepAlive, config._keepAliveP, true); err != nil {
 		return nil, err
 	}
+	if err := config.setProperty(SnapshotEpoch, config._snapshotEpochP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(GeometryCodec, config._geometryCodecP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(RoadNetwork, config._roadNetworkP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(MapMatchDist, config._mapMatchDistP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(LayerDir, config._layerDirP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(Warmup, config._warmupP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(CursorTTL, config._cursorTTLP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(MetaMaxSize, config._metaMaxSizeP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(TenantRate, config._tenantRateP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(TenantBurst, config._tenantBurstP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(TenantWeights, config._tenantWeightsP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(OutputLimitNormal, config._outputLimitNormalP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(OutputLimitPubsub, config._outputLimitPubsubP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(JWTSecret, config._jwtSecretP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(JWTPublicKey, config._jwtPublicKeyP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(JWTJWKSURL, config._jwtJWKSURLP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(JWTIssuer, config._jwtIssuerP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(JWTAudience, config._jwtAudienceP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(JWTNamespaceClaim, config._jwtNamespaceClaimP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(JWTRoleClaim, config._jwtRoleClaimP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(LDAPURL, config._ldapURLP, true); err != nil {
+		return nil, err
+	}
+	if err := config.setProperty(LDAPBindDN, config._ldapBindDNP, true); err != nil {
+		return nil, err
+	}
 	config.write(false)
 	return config, nil
 }
@@ -128,6 +297,11 @@ func (config *Config) write(writeProperties bool) {
 			config._protectedModeP = config._protectedMode
 		}
 		config._maxMemoryP = formatMemSize(config._maxMemory)
+		if config._maxMemoryPolicy == defaultMaxMemoryPolicy {
+			config._maxMemoryPolicyP = ""
+		} else {
+			config._maxMemoryPolicyP = config._maxMemoryPolicy
+		}
 		if config._autoGC == 0 {
 			config._autoGCP = ""
 		} else {
@@ -138,6 +312,68 @@ func (config *Config) write(writeProperties bool) {
 		} else {
 			config._keepAliveP = strconv.FormatUint(uint64(config._keepAlive), 10)
 		}
+		if config._snapshotEpoch == 0 {
+			config._snapshotEpochP = ""
+		} else {
+			config._snapshotEpochP = strconv.FormatInt(config._snapshotEpoch, 10)
+		}
+		if config._geometryCodec == defaultGeometryCodec {
+			config._geometryCodecP = ""
+		} else {
+			config._geometryCodecP = config._geometryCodec
+		}
+		config._roadNetworkP = config._roadNetwork
+		if config._mapMatchDist == defaultMapMatchDist {
+			config._mapMatchDistP = ""
+		} else {
+			config._mapMatchDistP = strconv.FormatFloat(config._mapMatchDist, 'f', -1, 64)
+		}
+		config._layerDirP = config._layerDir
+		config._warmupP = config._warmup
+		if config._cursorTTL == defaultCursorTTL {
+			config._cursorTTLP = ""
+		} else {
+			config._cursorTTLP = strconv.FormatUint(uint64(config._cursorTTL), 10)
+		}
+		if config._metaMaxSize == defaultMetaMaxSize {
+			config._metaMaxSizeP = ""
+		} else {
+			config._metaMaxSizeP = strconv.FormatInt(config._metaMaxSize, 10)
+		}
+		if config._tenantRate == 0 {
+			config._tenantRateP = ""
+		} else {
+			config._tenantRateP = strconv.FormatFloat(config._tenantRate, 'f', -1, 64)
+		}
+		if config._tenantBurst == 0 {
+			config._tenantBurstP = ""
+		} else {
+			config._tenantBurstP = strconv.FormatFloat(config._tenantBurst, 'f', -1, 64)
+		}
+		if w := config._tenantWeights.String(); w == defaultTenantWeights {
+			config._tenantWeightsP = ""
+		} else {
+			config._tenantWeightsP = w
+		}
+		if l := config._outputLimitNormal.String(); l == defaultOutputLimitNormal {
+			config._outputLimitNormalP = ""
+		} else {
+			config._outputLimitNormalP = l
+		}
+		if l := config._outputLimitPubsub.String(); l == defaultOutputLimitPubsub {
+			config._outputLimitPubsubP = ""
+		} else {
+			config._outputLimitPubsubP = l
+		}
+		config._jwtSecretP = config._jwtSecret
+		config._jwtPublicKeyP = config._jwtPublicKey
+		config._jwtJWKSURLP = config._jwtJWKSURL
+		config._jwtIssuerP = config._jwtIssuer
+		config._jwtAudienceP = config._jwtAudience
+		config._jwtNamespaceClaimP = config._jwtNamespaceClaim
+		config._jwtRoleClaimP = config._jwtRoleClaim
+		config._ldapURLP = config._ldapURL
+		config._ldapBindDNP = config._ldapBindDN
 	}
 
 	m := make(map[string]interface{})
@@ -171,12 +407,81 @@ func (config *Config) write(writeProperties bool) {
 	if config._maxMemoryP != "" {
 		m[MaxMemory] = config._maxMemoryP
 	}
+	if config._maxMemoryPolicyP != "" {
+		m[MaxMemoryPolicy] = config._maxMemoryPolicyP
+	}
 	if config._autoGCP != "" {
 		m[AutoGC] = config._autoGCP
 	}
 	if config._keepAliveP != "" {
 		m[KeepAlive] = config._keepAliveP
 	}
+	if config._snapshotEpochP != "" {
+		m[SnapshotEpoch] = config._snapshotEpochP
+	}
+	if config._geometryCodecP != "" {
+		m[GeometryCodec] = config._geometryCodecP
+	}
+	if config._roadNetworkP != "" {
+		m[RoadNetwork] = config._roadNetworkP
+	}
+	if config._mapMatchDistP != "" {
+		m[MapMatchDist] = config._mapMatchDistP
+	}
+	if config._layerDirP != "" {
+		m[LayerDir] = config._layerDirP
+	}
+	if config._warmupP != "" {
+		m[Warmup] = config._warmupP
+	}
+	if config._cursorTTLP != "" {
+		m[CursorTTL] = config._cursorTTLP
+	}
+	if config._metaMaxSizeP != "" {
+		m[MetaMaxSize] = config._metaMaxSizeP
+	}
+	if config._tenantRateP != "" {
+		m[TenantRate] = config._tenantRateP
+	}
+	if config._tenantBurstP != "" {
+		m[TenantBurst] = config._tenantBurstP
+	}
+	if config._tenantWeightsP != "" {
+		m[TenantWeights] = config._tenantWeightsP
+	}
+	if config._outputLimitNormalP != "" {
+		m[OutputLimitNormal] = config._outputLimitNormalP
+	}
+	if config._outputLimitPubsubP != "" {
+		m[OutputLimitPubsub] = config._outputLimitPubsubP
+	}
+	if config._jwtSecretP != "" {
+		m[JWTSecret] = config._jwtSecretP
+	}
+	if config._jwtPublicKeyP != "" {
+		m[JWTPublicKey] = config._jwtPublicKeyP
+	}
+	if config._jwtJWKSURLP != "" {
+		m[JWTJWKSURL] = config._jwtJWKSURLP
+	}
+	if config._jwtIssuerP != "" {
+		m[JWTIssuer] = config._jwtIssuerP
+	}
+	if config._jwtAudienceP != "" {
+		m[JWTAudience] = config._jwtAudienceP
+	}
+	if config._jwtNamespaceClaimP != "" {
+		m[JWTNamespaceClaim] = config._jwtNamespaceClaimP
+	}
+	if config._jwtRoleClaimP != "" {
+		m[JWTRoleClaim] = config._jwtRoleClaimP
+	}
+	if config._ldapURLP != "" {
+		m[LDAPURL] = config._ldapURLP
+	}
+	if config._ldapBindDNP != "" {
+		m[LDAPBindDN] = config._ldapBindDNP
+	}
 	data, err := json.MarshalIndent(m, "", "\t")
 	if err != nil {
 		panic(err)
@@ -261,6 +566,19 @@ func (config *Config) setProperty(name, value string, fromLoad bool) error {
 			return clientErrorf("Invalid argument '%s' for CONFIG SET '%s'", value, name)
 		}
 		config._maxMemory = sz
+	case MaxMemoryPolicy:
+		switch strings.ToLower(value) {
+		case "":
+			if fromLoad {
+				config._maxMemoryPolicy = defaultMaxMemoryPolicy
+			} else {
+				invalid = true
+			}
+		case evictRejectWrites, evictOldestTTL, evictLRUObject, evictColdestKey:
+			config._maxMemoryPolicy = strings.ToLower(value)
+		default:
+			invalid = true
+		}
 	case ProtectedMode:
 		switch strings.ToLower(value) {
 		case "":
@@ -285,6 +603,143 @@ func (config *Config) setProperty(name, value string, fromLoad bool) error {
 				config._keepAlive = int64(keepalive)
 			}
 		}
+	case SnapshotEpoch:
+		if value == "" {
+			config._snapshotEpoch = 0
+		} else {
+			epoch, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				invalid = true
+			} else {
+				config._snapshotEpoch = int64(epoch)
+			}
+		}
+	case GeometryCodec:
+		switch strings.ToLower(value) {
+		case "":
+			if fromLoad {
+				config._geometryCodec = defaultGeometryCodec
+			} else {
+				invalid = true
+			}
+		case "none", "delta":
+			config._geometryCodec = strings.ToLower(value)
+		default:
+			invalid = true
+		}
+	case RoadNetwork:
+		config._roadNetwork = value
+	case LayerDir:
+		config._layerDir = value
+	case Warmup:
+		config._warmup = value
+	case MapMatchDist:
+		if value == "" {
+			config._mapMatchDist = defaultMapMatchDist
+		} else {
+			meters, err := strconv.ParseFloat(value, 64)
+			if err != nil || meters <= 0 {
+				invalid = true
+			} else {
+				config._mapMatchDist = meters
+			}
+		}
+	case CursorTTL:
+		if value == "" {
+			config._cursorTTL = defaultCursorTTL
+		} else {
+			ttl, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || ttl == 0 {
+				invalid = true
+			} else {
+				config._cursorTTL = int64(ttl)
+			}
+		}
+	case MetaMaxSize:
+		if value == "" {
+			config._metaMaxSize = defaultMetaMaxSize
+		} else {
+			sz, ok := parseMemSize(value)
+			if !ok || sz <= 0 {
+				invalid = true
+			} else {
+				config._metaMaxSize = sz
+			}
+		}
+	case TenantRate, TenantBurst:
+		var n float64
+		if value != "" {
+			var err error
+			n, err = strconv.ParseFloat(value, 64)
+			if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+				invalid = true
+				break
+			}
+		}
+		if name == TenantRate {
+			config._tenantRate = n
+		} else {
+			config._tenantBurst = n
+		}
+	case TenantWeights:
+		if value == "" {
+			value = defaultTenantWeights
+		}
+		if w, ok := parseTenantWeights(value); ok {
+			config._tenantWeights = w
+		} else {
+			invalid = true
+		}
+	case OutputLimitNormal:
+		if value == "" {
+			value = defaultOutputLimitNormal
+		}
+		if l, ok := parseOutputLimit(value, false); ok {
+			config._outputLimitNormal = l
+		} else {
+			invalid = true
+		}
+	case OutputLimitPubsub:
+		if value == "" {
+			value = defaultOutputLimitPubsub
+		}
+		if l, ok := parseOutputLimit(value, true); ok {
+			config._outputLimitPubsub = l
+		} else {
+			invalid = true
+		}
+	case JWTSecret:
+		config._jwtSecret = value
+	case JWTPublicKey:
+		config._jwtPublicKey = value
+	case JWTIssuer:
+		config._jwtIssuer = value
+	case JWTAudience:
+		config._jwtAudience = value
+	case JWTNamespaceClaim:
+		config._jwtNamespaceClaim = value
+	case JWTRoleClaim:
+		config._jwtRoleClaim = value
+	case JWTJWKSURL:
+		if value != "" && !strings.HasPrefix(value, "https://") &&
+			!strings.HasPrefix(value, "http://") {
+			invalid = true
+		} else {
+			config._jwtJWKSURL = value
+		}
+	case LDAPURL:
+		if value != "" && !strings.HasPrefix(value, "ldaps://") &&
+			!strings.HasPrefix(value, "ldap://") {
+			invalid = true
+		} else {
+			config._ldapURL = value
+		}
+	case LDAPBindDN:
+		if value != "" && strings.Count(value, "%s") != 1 {
+			invalid = true
+		} else {
+			config._ldapBindDN = value
+		}
 	}
 
 	if invalid {
@@ -320,8 +775,54 @@ func (config *Config) getProperty(name string) string {
 		return config._protectedMode
 	case MaxMemory:
 		return formatMemSize(config._maxMemory)
+	case MaxMemoryPolicy:
+		return config._maxMemoryPolicy
 	case KeepAlive:
 		return strconv.FormatUint(uint64(config._keepAlive), 10)
+	case SnapshotEpoch:
+		return strconv.FormatInt(config._snapshotEpoch, 10)
+	case GeometryCodec:
+		return config._geometryCodec
+	case RoadNetwork:
+		return config._roadNetwork
+	case MapMatchDist:
+		return strconv.FormatFloat(config._mapMatchDist, 'f', -1, 64)
+	case LayerDir:
+		return config._layerDir
+	case Warmup:
+		return config._warmup
+	case CursorTTL:
+		return strconv.FormatUint(uint64(config._cursorTTL), 10)
+	case MetaMaxSize:
+		return strconv.FormatInt(config._metaMaxSize, 10)
+	case TenantRate:
+		return strconv.FormatFloat(config._tenantRate, 'f', -1, 64)
+	case TenantBurst:
+		return strconv.FormatFloat(config._tenantBurst, 'f', -1, 64)
+	case TenantWeights:
+		return config._tenantWeights.String()
+	case OutputLimitNormal:
+		return config._outputLimitNormal.String()
+	case OutputLimitPubsub:
+		return config._outputLimitPubsub.String()
+	case JWTSecret:
+		return config._jwtSecret
+	case JWTPublicKey:
+		return config._jwtPublicKey
+	case JWTJWKSURL:
+		return config._jwtJWKSURL
+	case JWTIssuer:
+		return config._jwtIssuer
+	case JWTAudience:
+		return config._jwtAudience
+	case JWTNamespaceClaim:
+		return config._jwtNamespaceClaim
+	case JWTRoleClaim:
+		return config._jwtRoleClaim
+	case LDAPURL:
+		return config._ldapURL
+	case LDAPBindDN:
+		return config._ldapBindDN
 	}
 }
 
@@ -369,9 +870,30 @@ func (s *Server) cmdConfigSet(msg *Message) (res resp.Value, err error) {
 	if len(vs) != 0 {
 		return NOMessage, errInvalidNumberOfArguments
 	}
+	if strings.ToLower(name) == RoadNetwork {
+		// load the network first, the config is unchanged on failure
+		if err := s.loadRoadNetwork(value); err != nil {
+			return NOMessage, err
+		}
+	}
+	if strings.ToLower(name) == LayerDir {
+		// load the layers first, the config is unchanged on failure
+		if err := s.loadLayers(value); err != nil {
+			return NOMessage, err
+		}
+	}
 	if err := s.config.setProperty(name, value, false); err != nil {
 		return NOMessage, err
 	}
+	if strings.ToLower(name) == GeometryCodec {
+		// new geometries in existing keys use the new codec, unless the
+		// key has its own, see keyconfig.go
+		s.cols.Ascend(nil, func(v interface{}) bool {
+			kc := v.(*collectionKeyContainer)
+			kc.col.SetCodec(s.keyCodec(kc.key))
+			return true
+		})
+	}
 	return OKMessage(msg, start), nil
 }
 func (s *Server) cmdConfigRewrite(msg *Message) (res resp.Value, err error) {
@@ -433,6 +955,12 @@ func (config *Config) maxMemory() int {
 	config.mu.RUnlock()
 	return int(v)
 }
+func (config *Config) maxMemoryPolicy() string {
+	config.mu.RLock()
+	v := config._maxMemoryPolicy
+	config.mu.RUnlock()
+	return v
+}
 func (config *Config) autoGC() uint64 {
 	config.mu.RLock()
 	v := config._autoGC
@@ -445,6 +973,89 @@ func (config *Config) keepAlive() int64 {
 	config.mu.RUnlock()
 	return v
 }
+func (config *Config) snapshotEpoch() time.Duration {
+	config.mu.RLock()
+	v := config._snapshotEpoch
+	config.mu.RUnlock()
+	return time.Duration(v) * time.Millisecond
+}
+func (config *Config) geometryCodec() collection.Codec {
+	config.mu.RLock()
+	v := config._geometryCodec
+	config.mu.RUnlock()
+	if v == "delta" {
+		return collection.CodecDelta
+	}
+	return collection.CodecNone
+}
+func (config *Config) roadNetwork() string {
+	config.mu.RLock()
+	v := config._roadNetwork
+	config.mu.RUnlock()
+	return v
+}
+func (config *Config) mapMatchDistance() float64 {
+	config.mu.RLock()
+	v := config._mapMatchDist
+	config.mu.RUnlock()
+	return v
+}
+func (config *Config) layerDir() string {
+	config.mu.RLock()
+	v := config._layerDir
+	config.mu.RUnlock()
+	return v
+}
+func (config *Config) warmup() string {
+	config.mu.RLock()
+	v := config._warmup
+	config.mu.RUnlock()
+	return v
+}
+
+// authConfig returns the config of the authentication providers, see auth.go.
+func (config *Config) authConfig() authConfig {
+	config.mu.RLock()
+	v := authConfig{
+		password:     config._requirePass,
+		jwtSecret:    config._jwtSecret,
+		jwtPublicKey: config._jwtPublicKey,
+		jwtJWKSURL:   config._jwtJWKSURL,
+		jwtIssuer:    config._jwtIssuer,
+		jwtAudience:  config._jwtAudience,
+		jwtNamespace: config._jwtNamespaceClaim,
+		jwtRole:      config._jwtRoleClaim,
+		ldapURL:      config._ldapURL,
+		ldapBindDN:   config._ldapBindDN,
+	}
+	config.mu.RUnlock()
+	return v
+}
+func (config *Config) tenantLimits() (rate, burst float64, weights tenantWeights) {
+	config.mu.RLock()
+	rate, burst = config._tenantRate, config._tenantBurst
+	weights = config._tenantWeights
+	config.mu.RUnlock()
+	return rate, burst, weights
+}
+func (config *Config) outputLimits() (normal, pubsub outputLimit) {
+	config.mu.RLock()
+	normal, pubsub = config._outputLimitNormal, config._outputLimitPubsub
+	config.mu.RUnlock()
+	return normal, pubsub
+}
+func (config *Config) cursorTTL() time.Duration {
+	config.mu.RLock()
+	v := config._cursorTTL
+	config.mu.RUnlock()
+	return time.Duration(v) * time.Second
+}
+func (config *Config) metaMaxSize() int {
+	config.mu.RLock()
+	v := config._metaMaxSize
+	config.mu.RUnlock()
+	return int(v)
+}
 func (config *Config) setFollowHost(v string) {
 	config.mu.Lock()
 	config._followHost = v
@@ -0,0 +1,39 @@
+package server
+
+// Coordinate reference systems: the CRS option of SET and MSET declares the
+// system of the input geometry, and the CRS option of GET and the search
+// commands declares the system of the output. Geometries are always stored
+// as WGS84.
+
+import (
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/tile38/internal/crs"
+)
+
+// transformObject returns the object with its coordinates transformed from
+// WGS84 into the reference system. Non-spatial objects are returned as-is.
+func (server *Server) transformObject(o geojson.Object, c *crs.CRS,
+) geojson.Object {
+	if c.Code() == crs.WGS84 || !objIsSpatial(o) {
+		return o
+	}
+	data := crs.AppendJSON(nil, o.AppendJSON(nil), c.Forward)
+	to, err := geojson.Parse(string(data), &server.geomParseOpts)
+	if err != nil {
+		return o
+	}
+	return to
+}
+
+// inverseRect returns the WGS84 bounds of a rectangle in the reference
+// system.
+func inverseRect(rect geometry.Rect, c *crs.CRS) geometry.Rect {
+	min, max := crs.TransformBounds(
+		[2]float64{rect.Min.X, rect.Min.Y},
+		[2]float64{rect.Max.X, rect.Max.Y}, c.Inverse)
+	return geometry.Rect{
+		Min: geometry.Point{X: min[0], Y: min[1]},
+		Max: geometry.Point{X: max[0], Y: max[1]},
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -11,9 +12,11 @@ import (
 	"github.com/tidwall/geojson"
 	"github.com/tidwall/geojson/geometry"
 	"github.com/tidwall/resp"
-	"github.com/tidwall/rtree"
 	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/crs"
 	"github.com/tidwall/tile38/internal/glob"
+	"github.com/tidwall/tile38/internal/repair"
+	"github.com/tidwall/tile38/internal/trajectory"
 )
 
 type fvt struct {
@@ -135,12 +138,26 @@ func (server *Server) cmdGet(msg *Message) (resp.Value, error) {
 	if vs, id, ok = tokenval(vs); !ok || id == "" {
 		return NOMessage, errInvalidNumberOfArguments
 	}
+	if _, peek, ok := tokenval(vs); ok && lc(peek, "history") {
+		return server.cmdGetHistory(msg, key, id, vs[1:], start)
+	}
 
 	withfields := false
 	if _, peek, ok := tokenval(vs); ok && strings.ToLower(peek) == "withfields" {
 		withfields = true
 		vs = vs[1:]
 	}
+	var outCRS *crs.CRS
+	if _, peek, ok := tokenval(vs); ok && strings.ToLower(peek) == "crs" {
+		var scrs string
+		if vs, scrs, ok = tokenval(vs[1:]); !ok || scrs == "" {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		var err error
+		if outCRS, err = crs.Parse(scrs); err != nil {
+			return NOMessage, err
+		}
+	}
 
 	col := server.getCol(key)
 	if col == nil {
@@ -150,12 +167,22 @@ func (server *Server) cmdGet(msg *Message) (resp.Value, error) {
 		return NOMessage, errKeyNotFound
 	}
 	o, fields, _, ok := col.Get(id)
+	if ok {
+		server.touchOnRead(key, id)
+	}
 	if !ok {
 		if msg.OutputType == RESP {
 			return resp.NullValue(), nil
 		}
 		return NOMessage, errIDNotFound
 	}
+	rule, err := server.redaction(msg, key)
+	if err != nil {
+		return NOMessage, err
+	}
+	if rule != nil {
+		o = rule.object(o)
+	}
 
 	vals := make([]resp.Value, 0, 2)
 	var buf bytes.Buffer
@@ -167,6 +194,9 @@ func (server *Server) cmdGet(msg *Message) (resp.Value, error) {
 	if !ok {
 		typ = "object"
 	}
+	if outCRS != nil && typ != "hash" {
+		o = server.transformObject(o, outCRS)
+	}
 	switch typ {
 	default:
 		return NOMessage, errInvalidArgument(typ)
@@ -180,7 +210,7 @@ func (server *Server) cmdGet(msg *Message) (resp.Value, error) {
 	case "point":
 		if msg.OutputType == JSON {
 			buf.WriteString(`,"point":`)
-			buf.Write(appendJSONSimplePoint(nil, o))
+			buf.Write(appendJSONSimplePoint(nil, o, -1))
 		} else {
 			point := o.Center()
 			var z float64
@@ -221,7 +251,7 @@ func (server *Server) cmdGet(msg *Message) (resp.Value, error) {
 	case "bounds":
 		if msg.OutputType == JSON {
 			buf.WriteString(`,"bounds":`)
-			buf.Write(appendJSONSimpleBounds(nil, o))
+			buf.Write(appendJSONSimpleBounds(nil, o, -1))
 		} else {
 			bbox := o.Rect()
 			vals = append(vals, resp.ArrayValue([]resp.Value{
@@ -242,6 +272,15 @@ func (server *Server) cmdGet(msg *Message) (resp.Value, error) {
 	}
 	if withfields {
 		fvs := orderFields(col.FieldMap(), col.FieldArr(), fields)
+		if rule != nil && len(rule.hide) > 0 {
+			var shown []fvt
+			for _, fv := range fvs {
+				if !rule.hidden(fv.field) {
+					shown = append(shown, fv)
+				}
+			}
+			fvs = shown
+		}
 		if len(fvs) > 0 {
 			fvals := make([]resp.Value, 0, len(fvs)*2)
 			if msg.OutputType == JSON {
@@ -300,6 +339,7 @@ func (server *Server) cmdDel(msg *Message) (res resp.Value, d commandDetails, er
 	found := false
 	col := server.getCol(d.key)
 	if col != nil {
+		server.keepTombstone(col, d.key, d.id)
 		d.obj, d.fields, ok = col.Delete(d.id)
 		if ok {
 			if col.Count() == 0 {
@@ -366,6 +406,7 @@ func (server *Server) cmdPdel(msg *Message) (res resp.Value, d commandDetails, e
 		}
 		var atLeastOneNotDeleted bool
 		for i, dc := range d.children {
+			server.keepTombstone(col, d.key, dc.id)
 			dc.obj, dc.fields, ok = col.Delete(dc.id)
 			if !ok {
 				d.children[i].command = "?"
@@ -509,9 +550,21 @@ func (server *Server) cmdFlushDB(msg *Message) (res resp.Value, d commandDetails
 	server.groupHooks = btree.NewNonConcurrent(byGroupHook)
 	server.groupObjects = btree.NewNonConcurrent(byGroupObject)
 	server.hooks = make(map[string]*Hook)
-	server.hooksOut = make(map[string]*Hook)
-	server.hookTree = &rtree.RTree{}
-	server.hookCross = &rtree.RTree{}
+	server.hookIndex = make(fenceIndex)
+	server.tracks = make(map[string]*trajectory.Store)
+	server.areaConfigs = make(map[string]areaConfig)
+	server.ttlConfigs = make(map[string]ttlConfig)
+	server.orderConfigs = make(map[string]orderConfig)
+	server.keyConfigs = make(map[string]keyConfig)
+	server.histories = make(map[string]*keyHistory)
+	server.fleets = make(map[string]*fleetTracker)
+	server.closeWriteBehinds()
+	server.readThroughs = make(map[string]*keyReadThrough)
+	server.tombstones.dropAll()
+	server.coalescer.dropAll()
+	server.schemas = make(map[string]keySchema)
+	server.namespaces = make(map[string]namespace)
+	server.roles = make(map[string]role)
 	d.command = "flushdb"
 	d.updated = true
 	d.timestamp = time.Now()
@@ -526,15 +579,134 @@ func (server *Server) cmdFlushDB(msg *Message) (res resp.Value, d commandDetails
 
 func (server *Server) parseSetArgs(vs []string) (
 	d commandDetails, fields []string, values []float64,
-	xx, nx bool,
+	xx, nx bool, conds []fieldCond,
 	ex int64, etype []byte, evs []string, err error,
 ) {
 	var ok bool
-	var typ []byte
-	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+	var key string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	vs, d, fields, values, xx, nx, conds, ex, etype, evs, err =
+		server.parseSetObjectArgs(key, vs, false)
+	if err != nil {
+		return
+	}
+	d.key = key
+	if len(vs) != 0 {
+		err = errInvalidNumberOfArguments
+	}
+	return
+}
+
+// fieldCond is an IF FIELD condition of SET, which is met when the current
+// value of the field compares to the value with the operator.
+type fieldCond struct {
+	field string
+	op    string
+	value float64
+}
+
+// parseFieldCond parses the condition that follows IF.
+//
+//	IF FIELD name ==|!=|<|<=|>|>= value
+func parseFieldCond(vs []string) (rest []string, c fieldCond, err error) {
+	var ok bool
+	var arg string
+	if vs, arg, ok = tokenval(vs); !ok || arg == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if !lc(arg, "field") {
+		err = errInvalidArgument(arg)
+		return
+	}
+	if vs, c.field, ok = tokenval(vs); !ok || c.field == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, c.op, ok = tokenval(vs); !ok || c.op == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	switch c.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		err = errInvalidArgument(c.op)
+		return
+	}
+	if vs, arg, ok = tokenval(vs); !ok || arg == "" {
 		err = errInvalidNumberOfArguments
 		return
 	}
+	if c.value, err = strconv.ParseFloat(arg, 64); err != nil {
+		err = errInvalidArgument(arg)
+		return
+	}
+	return vs, c, nil
+}
+
+func (c fieldCond) match(value float64) bool {
+	switch c.op {
+	case "==":
+		return value == c.value
+	case "!=":
+		return value != c.value
+	case "<":
+		return value < c.value
+	case "<=":
+		return value <= c.value
+	case ">":
+		return value > c.value
+	case ">=":
+		return value >= c.value
+	}
+	return false
+}
+
+// fieldCondsMet returns true when the object meets all of the conditions.
+// The fields that aren't set are zero, and so are all of the fields of an
+// object that doesn't exist.
+func fieldCondsMet(col *collection.Collection, id string,
+	conds []fieldCond,
+) bool {
+	if len(conds) == 0 {
+		return true
+	}
+	var fmap map[string]int
+	var fields []float64
+	if col != nil {
+		fmap = col.FieldMap()
+		_, fields, _, _ = col.Get(id)
+	}
+	for _, c := range conds {
+		var value float64
+		if idx, ok := fmap[c.field]; ok && idx < len(fields) {
+			value = fields[idx]
+		}
+		if !c.match(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSetObjectArgs parses the arguments of a single object for SET and
+// MSET, starting at the id. The remaining arguments are returned. When multi
+// is true an ID token ends the object, which is how MSET separates objects.
+// The objects are parsed with the options of the key, see keyconfig.go.
+func (server *Server) parseSetObjectArgs(key string, vs []string,
+	multi bool,
+) (
+	rest []string, d commandDetails, fields []string, values []float64,
+	xx, nx bool, conds []fieldCond,
+	ex int64, etype []byte, evs []string, err error,
+) {
+	var ok bool
+	var typ []byte
+	var match, hasZ, fix bool
+	var inCRS *crs.CRS
 	if vs, d.id, ok = tokenval(vs); !ok || d.id == "" {
 		err = errInvalidNumberOfArguments
 		return
@@ -572,6 +744,48 @@ func (server *Server) parseSetArgs(vs []string) (
 			values = append(values, value)
 			continue
 		}
+		if lcb(arg, "sfield") {
+			vs = nvs
+			var name, value string
+			if vs, name, ok = tokenval(vs); !ok || name == "" {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			if isReservedFieldName(name) {
+				err = errInvalidArgument(name)
+				return
+			}
+			if vs, value, ok = tokenval(vs); !ok {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			d.sfields = append(d.sfields, name, value)
+			continue
+		}
+		if lcb(arg, "tag") {
+			vs = nvs
+			var tag string
+			if vs, tag, ok = tokenval(vs); !ok || tag == "" {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			d.tags = append(d.tags, tag)
+			continue
+		}
+		if lcb(arg, "meta") {
+			vs = nvs
+			if d.meta != nil {
+				err = errInvalidArgument(string(arg))
+				return
+			}
+			var meta string
+			if vs, meta, ok = tokenval(vs); !ok {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			d.meta = &meta
+			continue
+		}
 		if lcb(arg, "ex") {
 			vs = nvs
 			if ex != 0 {
@@ -610,8 +824,41 @@ func (server *Server) parseSetArgs(vs []string) (
 			nx = true
 			continue
 		}
+		if lcb(arg, "if") {
+			var c fieldCond
+			if vs, c, err = parseFieldCond(nvs); err != nil {
+				return
+			}
+			conds = append(conds, c)
+			continue
+		}
+		if lcb(arg, "match") {
+			vs = nvs
+			match = true
+			continue
+		}
+		if lcb(arg, "repair") {
+			vs = nvs
+			fix = true
+			continue
+		}
+		if lcb(arg, "crs") {
+			vs = nvs
+			var s string
+			if vs, s, ok = tokenval(vs); !ok || s == "" {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			if inCRS, err = crs.Parse(s); err != nil {
+				return
+			}
+			continue
+		}
 		break
 	}
+	if d.tags != nil {
+		d.tags = sortTags(d.tags)
+	}
 	if vs, typ, ok = tokenvalbytes(vs); !ok || len(typ) == 0 {
 		err = errInvalidNumberOfArguments
 		return
@@ -643,8 +890,13 @@ func (server *Server) parseSetArgs(vs []string) (
 			err = errInvalidNumberOfArguments
 			return
 		}
-		vs, sz, ok = tokenval(vs)
-		if !ok || sz == "" {
+		var nvs []string
+		if nvs, sz, ok = tokenval(vs); ok && !(multi && lc(sz, "id")) {
+			vs = nvs
+		} else {
+			sz = ""
+		}
+		if sz == "" {
 			var x, y float64
 			y, err = strconv.ParseFloat(slat, 64)
 			if err != nil {
@@ -675,6 +927,7 @@ func (server *Server) parseSetArgs(vs []string) (
 				return
 			}
 			d.obj = geojson.NewPointZ(geometry.Point{X: x, Y: y}, z)
+			hasZ = true
 		}
 	case lcb(typ, "bounds"):
 		var sminlat, sminlon, smaxlat, smaxlon string
@@ -733,19 +986,69 @@ func (server *Server) parseSetArgs(vs []string) (
 			err = errInvalidNumberOfArguments
 			return
 		}
-		d.obj, err = geojson.Parse(object, &server.geomParseOpts)
+		if inCRS != nil {
+			object = string(crs.AppendJSON(nil, []byte(object), inCRS.Inverse))
+		}
+		if fix {
+			var data []byte
+			d.repair = new(repair.Report)
+			data, *d.repair = repair.JSON([]byte(object))
+			object = string(data)
+		}
+		d.obj, err = geojson.Parse(object, server.parseOpts(key))
 		if err != nil {
 			return
 		}
 	}
-	if len(vs) != 0 {
-		err = errInvalidNumberOfArguments
+	if inCRS != nil {
+		switch {
+		case lcb(typ, "point"):
+			point := d.obj.(*geojson.Point)
+			var p geometry.Point
+			p.X, p.Y = inCRS.Inverse(point.Base().X, point.Base().Y)
+			if hasZ {
+				d.obj = geojson.NewPointZ(p, point.Z())
+			} else {
+				d.obj = geojson.NewPoint(p)
+			}
+		case lcb(typ, "bounds"):
+			d.obj = geojson.NewRect(inverseRect(d.obj.Rect(), inCRS))
+		case lcb(typ, "object"):
+			// transformed before parsing
+		default:
+			err = errInvalidArgument(string(typ))
+			return
+		}
+	}
+	if fix && d.repair == nil {
+		// only objects are repaired, the other types are always valid
+		d.repair = new(repair.Report)
 	}
+	if match {
+		point, ok := d.obj.(*geojson.Point)
+		if !ok || !lcb(typ, "point") {
+			err = errInvalidArgument(string(typ))
+			return
+		}
+		if d.obj, d.road, err = server.matchRoad(point, hasZ); err != nil {
+			return
+		}
+	}
+	evs = evs[:len(evs)-len(vs)]
+	rest = vs
 	return
 }
 
+// cmdSet sets an object. NX, XX and the IF FIELD conditions are checked
+// under the lock of the key, and when one isn't met nothing is set and the
+// reply is null. So is an update that's older than the object when the key
+// has an ORDERCONFIG, see order.go.
+//
+//	SET key id [FIELD name value ...] [SFIELD name value ...] [TAG tag ...]
+//	    [META meta] [EX seconds] [NX|XX]
+//	    [IF FIELD name op value ...] value
 func (server *Server) cmdSet(msg *Message) (res resp.Value, d commandDetails, err error) {
-	if server.config.maxMemory() > 0 && server.outOfMemory.on() {
+	if server.rejectWrites() {
 		err = errOOM
 		return
 	}
@@ -754,13 +1057,49 @@ func (server *Server) cmdSet(msg *Message) (res resp.Value, d commandDetails, er
 	var fmap map[string]int
 	var fields []string
 	var values []float64
-	var xx, nx bool
+	var xx, nx, unmet, older bool
+	var conds []fieldCond
 	var ex int64
-	d, fields, values, xx, nx, ex, _, _, err = server.parseSetArgs(vs)
+	d, fields, values, xx, nx, conds, ex, _, _, err = server.parseSetArgs(vs)
 	if err != nil {
 		return
 	}
+	if err = server.checkMeta(msg, d.meta); err != nil {
+		return
+	}
+	d.obj = server.snapObject(d.key, d.obj)
+	if server.keyConfigs[d.key].timestamps {
+		var args []string
+		fields, values, args =
+			server.stampArgs(d.key, fields, values, msg.Args[2:])
+		msg.Args = append(msg.Args[:2:2], args...)
+	}
+	if schema := server.schema(msg, d.key); schema != nil {
+		err = schema.checkObject(server.getCol(d.key), d.key, d.id, d.obj,
+			fields, values)
+		if err != nil {
+			return
+		}
+	}
+	if ex == 0 {
+		ex = server.slidingExpires(d.key)
+	}
+	if server.roadNetwork() != nil {
+		if args := matchedArgs(msg.Args[2:], d.obj); args != nil {
+			msg.Args = append(msg.Args[:2:2], args...)
+		}
+	}
+	if conf := server.areaConfig(msg, d.key); conf != nil {
+		var args []string
+		fields, values, args =
+			server.annotateAreas(conf, &d, fields, values, msg.Args[2:])
+		msg.Args = append(msg.Args[:2:2], args...)
+	}
 	col := server.getCol(d.key)
+	if !fieldCondsMet(col, d.id, conds) {
+		unmet = true
+		goto notok
+	}
 	if col == nil {
 		if xx {
 			goto notok
@@ -774,9 +1113,30 @@ func (server *Server) cmdSet(msg *Message) (res resp.Value, d commandDetails, er
 			goto notok
 		}
 	}
-	d.oldObj, d.oldFields, d.fields = col.Set(d.id, d.obj, fields, values, ex)
-	d.command = "set"
-	d.updated = true // perhaps we should do a diff on the previous object?
+	if server.unchanged(col, d.key, d.id, d.obj, fields, values, d.tags,
+		d.meta, d.sfields, ex) {
+		// not updated, so it isn't written to the aof or the geofences
+		d.command = "set"
+	} else if conf, ok := server.outOfOrder(d.key, col, d.id, fields, values); ok {
+		if !conf.merge {
+			older = true
+			goto notok
+		}
+		d.updated = mergeMissingFields(col, &d, fields, values) > 0
+		d.command = "fset"
+	} else {
+		d.oldObj, d.oldFields, d.fields =
+			col.Set(d.id, d.obj, fields, values, ex)
+		if d.tags != nil {
+			col.SetTags(d.id, d.tags)
+		}
+		if d.meta != nil {
+			col.SetMeta(d.id, *d.meta)
+		}
+		setStringFields(col, d.id, d.sfields)
+		d.command = "set"
+		d.updated = true // perhaps we should do a diff on the previous object?
+	}
 	d.timestamp = time.Now()
 	if msg.ConnType != Null || msg.OutputType != Null {
 		// likely loaded from aof at server startup, ignore field remapping.
@@ -792,18 +1152,32 @@ func (server *Server) cmdSet(msg *Message) (res resp.Value, d commandDetails, er
 	switch msg.OutputType {
 	default:
 	case JSON:
-		res = resp.StringValue(`{"ok":true,"elapsed":"` + time.Since(start).String() + "\"}")
+		var repaired string
+		if d.repair != nil {
+			repaired = `,"repair":` + string(appendJSONRepair(nil, *d.repair))
+		}
+		res = resp.StringValue(`{"ok":true` + repaired + `,"elapsed":"` +
+			time.Since(start).String() + "\"}")
 	case RESP:
-		res = resp.SimpleStringValue("OK")
+		if d.repair != nil {
+			res = respRepair(*d.repair)
+		} else {
+			res = resp.SimpleStringValue("OK")
+		}
 	}
 	return
 notok:
 	switch msg.OutputType {
 	default:
 	case JSON:
-		if nx {
+		switch {
+		case unmet:
+			err = errConditionNotMet
+		case older:
+			err = errOlderTimestamp
+		case nx:
 			err = errIDAlreadyExists
-		} else {
+		default:
 			err = errIDNotFound
 		}
 		return
@@ -813,8 +1187,13 @@ notok:
 	return
 }
 
+// parseFSetArgs parses the FSET arguments. The expiration of the fields is
+// zero when there's no EX.
+//
+//	FSET key id [XX] field value [field value ...] [EX seconds]
 func (server *Server) parseFSetArgs(vs []string) (
-	d commandDetails, fields []string, values []float64, xx bool, err error,
+	d commandDetails, fields []string, values []float64, xx bool, ex int64,
+	err error,
 ) {
 	var ok bool
 	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
@@ -835,6 +1214,20 @@ func (server *Server) parseFSetArgs(vs []string) (
 			xx = true
 			continue
 		}
+		if lc(name, "ex") {
+			var sttl string
+			if vs, sttl, ok = tokenval(vs); !ok || sttl == "" || ex != 0 {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			ttl, perr := strconv.ParseFloat(sttl, 64)
+			if perr != nil || ttl <= 0 {
+				err = errInvalidArgument(sttl)
+				return
+			}
+			ex = time.Now().Add(time.Duration(ttl * float64(time.Second))).UnixNano()
+			continue
+		}
 		if isReservedFieldName(name) {
 			err = errInvalidArgument(name)
 			return
@@ -857,7 +1250,7 @@ func (server *Server) parseFSetArgs(vs []string) (
 }
 
 func (server *Server) cmdFset(msg *Message) (res resp.Value, d commandDetails, err error) {
-	if server.config.maxMemory() > 0 && server.outOfMemory.on() {
+	if server.rejectWrites() {
 		err = errOOM
 		return
 	}
@@ -866,8 +1259,17 @@ func (server *Server) cmdFset(msg *Message) (res resp.Value, d commandDetails, e
 	var fields []string
 	var values []float64
 	var xx bool
+	var ex int64
 	var updateCount int
-	d, fields, values, xx, err = server.parseFSetArgs(vs)
+	d, fields, values, xx, ex, err = server.parseFSetArgs(vs)
+	if err != nil {
+		return
+	}
+	if schema := server.schema(msg, d.key); schema != nil {
+		if err = schema.checkFields(d.key, fields, values); err != nil {
+			return
+		}
+	}
 
 	col := server.getCol(d.key)
 	if col == nil {
@@ -881,6 +1283,10 @@ func (server *Server) cmdFset(msg *Message) (res resp.Value, d commandDetails, e
 		return
 	}
 	if ok {
+		// fields that are set without EX don't expire
+		for _, field := range fields {
+			col.SetFieldExpires(d.id, field, ex)
+		}
 		d.command = "fset"
 		d.timestamp = time.Now()
 		d.updated = updateCount > 0
@@ -900,6 +1306,412 @@ func (server *Server) cmdFset(msg *Message) (res resp.Value, d commandDetails, e
 	return
 }
 
+// cmdFincrby updates a field from its current value, and returns the new
+// value. A field that isn't set is zero, and FMIN and FMAX set the fields
+// that are zero to the value. The command is written to the AOF as is, and
+// the expiration of the field doesn't change.
+//
+//	FINCRBY key id field amount
+//	FDECRBY key id field amount
+//	FMIN key id field value
+//	FMAX key id field value
+func (server *Server) cmdFincrby(msg *Message) (res resp.Value, d commandDetails, err error) {
+	if server.rejectWrites() {
+		err = errOOM
+		return
+	}
+	start := time.Now()
+	if len(msg.Args) != 5 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	d.key, d.id = msg.Args[1], msg.Args[2]
+	field, samount := msg.Args[3], msg.Args[4]
+	if field == "" || isReservedFieldName(field) {
+		err = errInvalidArgument(field)
+		return
+	}
+	amount, err := strconv.ParseFloat(samount, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		err = errInvalidArgument(samount)
+		return
+	}
+	col := server.getCol(d.key)
+	if col == nil {
+		err = errKeyNotFound
+		return
+	}
+	_, fields, _, ok := col.Get(d.id)
+	if !ok {
+		err = errIDNotFound
+		return
+	}
+	var value float64
+	if idx, ok := col.FieldMap()[field]; ok && idx < len(fields) {
+		value = fields[idx]
+	}
+	switch msg.Command() {
+	case "fincrby":
+		value += amount
+	case "fdecrby":
+		value -= amount
+	case "fmin":
+		if value == 0 || amount < value {
+			value = amount
+		}
+	case "fmax":
+		if value == 0 || amount > value {
+			value = amount
+		}
+	}
+	if schema := server.schema(msg, d.key); schema != nil {
+		err = schema.checkFields(d.key, []string{field}, []float64{value})
+		if err != nil {
+			return
+		}
+	}
+	var updateCount int
+	d.obj, d.fields, updateCount, _ = col.SetFields(d.id, []string{field},
+		[]float64{value})
+	d.command = "fset"
+	d.timestamp = time.Now()
+	d.updated = updateCount > 0
+	fmap := col.FieldMap()
+	d.fmap = make(map[string]int)
+	for key, idx := range fmap {
+		d.fmap[key] = idx
+	}
+	svalue := strconv.FormatFloat(value, 'f', -1, 64)
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"value":` + svalue +
+			`,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.StringValue(svalue)
+	}
+	return
+}
+
+// cmdMset sets many objects in one key.
+//
+//	MSET key ID id [FIELD name value ...] [EX seconds] [NX|XX]
+//	         [IF FIELD name op value ...] value [ID id ...]
+//
+// Each object takes the same options as SET. The whole command is written
+// to the AOF as a single record, and each object that was set is forwarded
+// to the geofences on its own.
+func (server *Server) cmdMset(msg *Message) (res resp.Value, d commandDetails, err error) {
+	if server.rejectWrites() {
+		err = errOOM
+		return
+	}
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	type msetObject struct {
+		d      commandDetails
+		fields []string
+		values []float64
+		xx, nx bool
+		conds  []fieldCond
+		ex     int64
+		args   []string // ID id ...
+	}
+	var objs []msetObject
+	network := server.roadNetwork()
+	conf := server.areaConfig(msg, d.key)
+	schema := server.schema(msg, d.key)
+	var margs []string // args with matched points and area fields
+	var rewritten bool
+	var report *repair.Report // sum of the REPAIR reports
+	for len(vs) > 0 {
+		var arg string
+		if vs, arg, ok = tokenval(vs); !ok || !lc(arg, "id") {
+			err = errInvalidArgument(arg)
+			return
+		}
+		var o msetObject
+		ovs := vs
+		vs, o.d, o.fields, o.values, o.xx, o.nx, o.conds, o.ex, _, _, err =
+			server.parseSetObjectArgs(d.key, vs, true)
+		if err != nil {
+			return
+		}
+		if err = server.checkMeta(msg, o.d.meta); err != nil {
+			return
+		}
+		o.d.obj = server.snapObject(d.key, o.d.obj)
+		oargs := ovs[:len(ovs)-len(vs)]
+		if server.keyConfigs[d.key].timestamps {
+			o.fields, o.values, oargs =
+				server.stampArgs(d.key, o.fields, o.values, oargs)
+			rewritten = true
+		}
+		if schema != nil {
+			err = schema.checkObject(server.getCol(d.key), d.key, o.d.id,
+				o.d.obj, o.fields, o.values)
+			if err != nil {
+				return
+			}
+		}
+		if o.d.repair != nil {
+			if report == nil {
+				report = new(repair.Report)
+			}
+			report.Add(*o.d.repair)
+		}
+		if network != nil {
+			if args := matchedArgs(oargs, o.d.obj); args != nil {
+				oargs = args
+				rewritten = true
+			}
+		}
+		if conf != nil {
+			o.fields, o.values, oargs =
+				server.annotateAreas(conf, &o.d, o.fields, o.values, oargs)
+			rewritten = true
+		}
+		o.args = append([]string{arg}, oargs...)
+		margs = append(margs, o.args...)
+		objs = append(objs, o)
+	}
+	if len(objs) == 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if rewritten {
+		msg.Args = append(msg.Args[:2:2], margs...)
+	}
+	now := time.Now()
+	col := server.getCol(d.key)
+	var unchanged bool
+	margs = margs[:0]
+	for _, o := range objs {
+		if o.ex == 0 {
+			o.ex = server.slidingExpires(d.key)
+		}
+		if server.unchanged(col, d.key, o.d.id, o.d.obj, o.fields, o.values,
+			o.d.tags, o.d.meta, o.d.sfields, o.ex) {
+			// left out of the aof, see keyconfig.go
+			unchanged = true
+			continue
+		}
+		margs = append(margs, o.args...)
+		if !fieldCondsMet(col, o.d.id, o.conds) {
+			continue
+		}
+		if col == nil {
+			if o.xx {
+				continue
+			}
+			col = collection.New()
+			server.setCol(d.key, col)
+		}
+		if o.xx || o.nx {
+			_, _, _, ok := col.Get(o.d.id)
+			if (o.nx && ok) || (o.xx && !ok) {
+				continue
+			}
+		}
+		dc := o.d
+		dc.key = d.key
+		dc.timestamp = now
+		conf, older := server.outOfOrder(d.key, col, dc.id, o.fields, o.values)
+		if older {
+			if !conf.merge ||
+				mergeMissingFields(col, &dc, o.fields, o.values) == 0 {
+				continue
+			}
+			dc.command = "fset"
+			dc.updated = true
+			d.children = append(d.children, &dc)
+			continue
+		}
+		dc.oldObj, dc.oldFields, dc.fields =
+			col.Set(dc.id, dc.obj, o.fields, o.values, o.ex)
+		if dc.tags != nil {
+			col.SetTags(dc.id, dc.tags)
+		}
+		if dc.meta != nil {
+			col.SetMeta(dc.id, *dc.meta)
+		}
+		setStringFields(col, dc.id, dc.sfields)
+		dc.command = "set"
+		dc.updated = true
+		d.children = append(d.children, &dc)
+	}
+	if unchanged {
+		msg.Args = append(msg.Args[:2:2], margs...)
+	}
+	if len(d.children) > 0 &&
+		(msg.ConnType != Null || msg.OutputType != Null) {
+		// likely loaded from aof at server startup, ignore field remapping.
+		fmap := make(map[string]int)
+		for key, idx := range col.FieldMap() {
+			fmap[key] = idx
+		}
+		for _, dc := range d.children {
+			dc.fmap = fmap
+		}
+	}
+	d.command = "mset"
+	d.updated = len(d.children) > 0
+	d.timestamp = now
+	d.parent = true
+	switch msg.OutputType {
+	case JSON:
+		var repaired string
+		if report != nil {
+			repaired = `,"repair":` + string(appendJSONRepair(nil, *report))
+		}
+		res = resp.StringValue(`{"ok":true` + repaired + `,"elapsed":"` +
+			time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(len(d.children))
+	}
+	return
+}
+
+// cmdMfset sets fields on many objects in one key.
+//
+//	MFSET key [XX] ID id field value [field value ...] [ID id ...]
+//
+// All ids must exist, unless XX is provided, in which case the missing ids
+// are skipped.
+func (server *Server) cmdMfset(msg *Message) (res resp.Value, d commandDetails, err error) {
+	if server.rejectWrites() {
+		err = errOOM
+		return
+	}
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var xx bool
+	var arg string
+	if vs, arg, ok = tokenval(vs); ok && lc(arg, "xx") {
+		xx = true
+		vs, arg, ok = tokenval(vs)
+	}
+	if !ok || !lc(arg, "id") {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	schema := server.schema(msg, d.key)
+	type mfsetObject struct {
+		id     string
+		fields []string
+		values []float64
+	}
+	var objs []mfsetObject
+	for {
+		var o mfsetObject
+		if vs, o.id, ok = tokenval(vs); !ok || o.id == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		for {
+			var name, svalue string
+			if vs, name, ok = tokenval(vs); !ok || lc(name, "id") {
+				break
+			}
+			if isReservedFieldName(name) {
+				err = errInvalidArgument(name)
+				return
+			}
+			if vs, svalue, ok = tokenval(vs); !ok || svalue == "" {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			var value float64
+			value, err = strconv.ParseFloat(svalue, 64)
+			if err != nil {
+				err = errInvalidArgument(svalue)
+				return
+			}
+			o.fields = append(o.fields, name)
+			o.values = append(o.values, value)
+		}
+		if len(o.fields) == 0 {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		if schema != nil {
+			if err = schema.checkFields(d.key, o.fields, o.values); err != nil {
+				return
+			}
+		}
+		objs = append(objs, o)
+		if !ok {
+			break
+		}
+	}
+	col := server.getCol(d.key)
+	if col == nil {
+		err = errKeyNotFound
+		return
+	}
+	if !xx {
+		// check all ids before updating any of them
+		for _, o := range objs {
+			if _, _, _, ok := col.Get(o.id); !ok {
+				err = errIDNotFound
+				return
+			}
+		}
+	}
+	now := time.Now()
+	var updateCount int
+	for _, o := range objs {
+		dc := &commandDetails{key: d.key, id: o.id}
+		var n int
+		dc.obj, dc.fields, n, ok = col.SetFields(o.id, o.fields, o.values)
+		if !ok {
+			continue
+		}
+		dc.command = "fset"
+		dc.updated = n > 0
+		dc.timestamp = now
+		updateCount += n
+		d.children = append(d.children, dc)
+	}
+	if len(d.children) > 0 {
+		fmap := make(map[string]int)
+		for key, idx := range col.FieldMap() {
+			fmap[key] = idx
+		}
+		for _, dc := range d.children {
+			dc.fmap = fmap
+		}
+	}
+	d.command = "mfset"
+	d.updated = updateCount > 0
+	d.timestamp = now
+	d.parent = true
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(updateCount)
+	}
+	return
+}
+
+// cmdExpire sets the expiration of an object. EXPIRE and PEXPIRE are relative
+// to now, in seconds and milliseconds, and EXPIREAT and PEXPIREAT are unix
+// timestamps, in seconds and milliseconds.
+//
+//	EXPIRE key id seconds
+//	PEXPIRE key id milliseconds
+//	EXPIREAT key id timestamp
+//	PEXPIREAT key id timestamp
 func (server *Server) cmdExpire(msg *Message) (res resp.Value, d commandDetails, err error) {
 	start := time.Now()
 	vs := msg.Args[1:]
@@ -927,10 +1739,24 @@ func (server *Server) cmdExpire(msg *Message) (res resp.Value, d commandDetails,
 		err = errInvalidArgument(svalue)
 		return
 	}
+	var ex int64
+	switch msg.Command() {
+	case "expire":
+		ex = time.Now().Add(time.Duration(float64(time.Second) * value)).UnixNano()
+	case "pexpire":
+		ex = time.Now().Add(time.Duration(float64(time.Millisecond) * value)).UnixNano()
+	case "expireat":
+		ex = int64(value * float64(time.Second))
+	case "pexpireat":
+		ex = int64(value * float64(time.Millisecond))
+	}
+	if ex <= 0 {
+		// zero is no expiration, so expire as soon as possible instead
+		ex = 1
+	}
 	ok = false
 	col := server.getCol(key)
 	if col != nil {
-		ex := time.Now().Add(time.Duration(float64(time.Second) * value)).UnixNano()
 		ok = col.SetExpires(id, ex)
 	}
 	if ok {
@@ -1005,6 +1831,11 @@ func (server *Server) cmdPersist(msg *Message) (res resp.Value, d commandDetails
 	return
 }
 
+// cmdTTL returns the time to live of an object, in seconds for TTL and in
+// milliseconds for PTTL.
+//
+//	TTL key id
+//	PTTL key id
 func (server *Server) cmdTTL(msg *Message) (res resp.Value, err error) {
 	start := time.Now()
 	vs := msg.Args[1:]
@@ -1036,6 +1867,9 @@ func (server *Server) cmdTTL(msg *Message) (res resp.Value, err error) {
 					ok2 = false
 				} else {
 					v = float64(ex-now) / float64(time.Second)
+					if msg.Command() == "pttl" {
+						v = float64((ex - now) / int64(time.Millisecond))
+					}
 					if v < 0 {
 						v = 0
 					}
@@ -1054,7 +1888,8 @@ func (server *Server) cmdTTL(msg *Message) (res resp.Value, err error) {
 				ttl = "-1"
 			}
 			res = resp.SimpleStringValue(
-				`{"ok":true,"ttl":` + ttl + `,"elapsed":"` + time.Since(start).String() + "\"}")
+				`{"ok":true,"` + msg.Command() + `":` + ttl + `,"elapsed":"` +
+					time.Since(start).String() + "\"}")
 		} else {
 			return resp.SimpleStringValue(""), errIDNotFound
 		}
@@ -0,0 +1,282 @@
+package server
+
+// Cursors: a SCAN or a search that returns a cursor leaves an open cursor on
+// the client, until the client asks for the last page or the cursor isn't
+// used for the cursor-ttl. CURSORS LIST shows the open cursors of all of the
+// clients, and CURSORS KILL makes the next page of a cursor fail, which stops
+// a paginated export that's running.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+var errCursorKilled = errors.New("cursor killed")
+
+var cursorID uint64
+
+// openCursor is a scan or a search of a client that has more pages.
+type openCursor struct {
+	id      uint64
+	command string
+	key     string
+	cursor  uint64 // of the next page
+	opened  time.Time
+	last    time.Time // last page
+	killed  bool      // the next page fails
+}
+
+// clientCursors are the open cursors of a client.
+type clientCursors struct {
+	mu   sync.Mutex
+	list []*openCursor
+}
+
+// open returns the open cursor that a scan or a search continues, or nil for
+// the first page. The error is for the cursors that were killed.
+func (cc *clientCursors) open(msg *Message, key string, cursor uint64) (*openCursor, error) {
+	if cc == nil || cursor == 0 {
+		return nil, nil
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for i, oc := range cc.list {
+		if oc.command == msg.Command() && oc.key == key && oc.cursor == cursor {
+			if oc.killed {
+				cc.list = append(cc.list[:i], cc.list[i+1:]...)
+				return nil, errCursorKilled
+			}
+			return oc, nil
+		}
+	}
+	return nil, nil
+}
+
+// update moves an open cursor to the next page, which closes the cursor when
+// it's zero.
+func (cc *clientCursors) update(msg *Message, key string, oc *openCursor, next uint64) {
+	if cc == nil {
+		return
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	idx := -1
+	if oc != nil {
+		for i := range cc.list {
+			if cc.list[i] == oc {
+				idx = i
+				break
+			}
+		}
+	}
+	if next == 0 {
+		if idx != -1 {
+			cc.list = append(cc.list[:idx], cc.list[idx+1:]...)
+		}
+		return
+	}
+	now := time.Now()
+	if oc == nil {
+		oc = &openCursor{
+			id:      atomic.AddUint64(&cursorID, 1),
+			command: msg.Command(),
+			key:     key,
+			opened:  now,
+		}
+	}
+	if idx == -1 {
+		// a new cursor, or one that expired while its page was running
+		cc.list = append(cc.list, oc)
+	}
+	oc.cursor = next
+	oc.last = now
+}
+
+// expire removes the cursors that weren't used since a time.
+func (cc *clientCursors) expire(since time.Time) int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	var n int
+	list := cc.list[:0]
+	for _, oc := range cc.list {
+		if oc.last.Before(since) {
+			n++
+			continue
+		}
+		list = append(list, oc)
+	}
+	for i := len(list); i < len(cc.list); i++ {
+		cc.list[i] = nil
+	}
+	cc.list = list
+	return n
+}
+
+// backgroundCursors removes the cursors that weren't used for the cursor-ttl,
+// such as the cursors of the exports that a client abandoned.
+func (s *Server) backgroundCursors() {
+	for {
+		if s.stopServer.on() {
+			return
+		}
+		s.connsmu.RLock()
+		since := time.Now().Add(-s.config.cursorTTL())
+		var n int
+		for _, client := range s.conns {
+			n += client.cursors.expire(since)
+		}
+		s.connsmu.RUnlock()
+		if n > 0 {
+			log.Debugf("Expired %d cursors\n", n)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// clientCursor is an open cursor of a client, for CURSORS LIST.
+type clientCursor struct {
+	openCursor
+	client int
+	addr   string
+}
+
+// cursors returns the open cursors of all of the clients, by id.
+func (s *Server) cursors() []clientCursor {
+	var list []clientCursor
+	s.connsmu.RLock()
+	for _, client := range s.conns {
+		client.cursors.mu.Lock()
+		for _, oc := range client.cursors.list {
+			list = append(list, clientCursor{*oc, client.id, client.remoteAddr})
+		}
+		client.cursors.mu.Unlock()
+	}
+	s.connsmu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].id < list[j].id
+	})
+	return list
+}
+
+// cmdCursorsList returns the open cursors of all of the clients. Like CLIENT
+// LIST, the RESP reply is a line of fields for each cursor.
+//
+//	CURSORS LIST
+func (s *Server) cmdCursorsList(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	if len(msg.Args) != 1 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	now := time.Now()
+	list := s.cursors()
+	switch msg.OutputType {
+	case JSON:
+		buf := []byte(`{"ok":true,"cursors":[`)
+		for i, c := range list {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `{"id":`+strconv.FormatUint(c.id, 10)...)
+			buf = append(buf, `,"client":`+strconv.Itoa(c.client)...)
+			buf = append(buf, `,"addr":`...)
+			buf = appendJSONString(buf, c.addr)
+			buf = append(buf, `,"command":"`+c.command+`","key":`...)
+			buf = appendJSONString(buf, c.key)
+			buf = append(buf, `,"cursor":`+strconv.FormatUint(c.cursor, 10)...)
+			buf = append(buf, `,"age":`+strconv.Itoa(int(now.Sub(c.opened)/time.Second))...)
+			buf = append(buf, `,"idle":`+strconv.Itoa(int(now.Sub(c.last)/time.Second))...)
+			buf = append(buf, `,"killed":`+strconv.FormatBool(c.killed)+`}`...)
+		}
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+`"}`...)
+		res = resp.StringValue(string(buf))
+	case RESP:
+		var sb strings.Builder
+		for _, c := range list {
+			killed := 0
+			if c.killed {
+				killed = 1
+			}
+			fmt.Fprintf(&sb, "id=%d client=%d addr=%s command=%s key=%s "+
+				"cursor=%d age=%d idle=%d killed=%d\n",
+				c.id, c.client, c.addr, c.command, c.key, c.cursor,
+				now.Sub(c.opened)/time.Second, now.Sub(c.last)/time.Second,
+				killed)
+		}
+		res = resp.BytesValue([]byte(sb.String()))
+	}
+	return
+}
+
+// cmdCursorsKill kills open cursors, so that the next page of the cursors
+// fails. It returns the number of cursors that were killed.
+//
+//	CURSORS KILL id [id ...]
+//	CURSORS KILL CLIENT id
+func (s *Server) cmdCursorsKill(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	if len(vs) == 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var client int
+	var ids []uint64
+	if lc(vs[0], "client") {
+		if len(vs) != 2 {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		if client, err = strconv.Atoi(vs[1]); err != nil || client <= 0 {
+			return NOMessage, errInvalidArgument(vs[1])
+		}
+	} else {
+		for _, v := range vs {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return NOMessage, errInvalidArgument(v)
+			}
+			ids = append(ids, id)
+		}
+	}
+	var n int
+	s.connsmu.RLock()
+	for _, cc := range s.conns {
+		if client != 0 && cc.id != client {
+			continue
+		}
+		cc.cursors.mu.Lock()
+		for _, oc := range cc.cursors.list {
+			if oc.killed {
+				continue
+			}
+			kill := client != 0
+			for _, id := range ids {
+				if oc.id == id {
+					kill = true
+					break
+				}
+			}
+			if kill {
+				oc.killed = true
+				n++
+			}
+		}
+		cc.cursors.mu.Unlock()
+	}
+	s.connsmu.RUnlock()
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"killed":` + strconv.Itoa(n) +
+			`,"elapsed":"` + time.Since(start).String() + `"}`)
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
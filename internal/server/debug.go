@@ -0,0 +1,155 @@
+package server
+
+// Diagnostics for live instances: the DEBUG command and the pprof endpoints
+// of the metrics server. Both are disabled unless the server is started
+// with --enable-debug yes, or --enable-debug local for loopback clients only.
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/core"
+)
+
+var errDebugNotAllowed = errors.New(
+	"DEBUG command not allowed, the server must be started with --enable-debug")
+
+// debugAllowed returns true when the diagnostics are allowed for a client
+// with the remote address.
+func debugAllowed(addr string) bool {
+	switch core.EnableDebug {
+	case "yes":
+		return true
+	case "local":
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
+
+// cmdDebug runs a diagnostic subcommand.
+//
+//	DEBUG OBJECT key id
+//	DEBUG SLEEP seconds
+//	DEBUG SET-ACTIVE-EXPIRE 0|1
+func (server *Server) cmdDebug(msg *Message, client *Client) (resp.Value, error) {
+	start := time.Now()
+	var addr string
+	if client != nil {
+		addr = client.remoteAddr
+	}
+	if !debugAllowed(addr) {
+		return NOMessage, errDebugNotAllowed
+	}
+	vs := msg.Args[1:]
+	var ok bool
+	var sub string
+	if vs, sub, ok = tokenval(vs); !ok || sub == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	switch strings.ToLower(sub) {
+	case "object":
+		var key, id string
+		if vs, key, ok = tokenval(vs); !ok || key == "" {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		if vs, id, ok = tokenval(vs); !ok || id == "" {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		if len(vs) != 0 {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		return server.debugObject(msg, start, key, id)
+	case "sleep":
+		var secs string
+		if vs, secs, ok = tokenval(vs); !ok || secs == "" || len(vs) != 0 {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		d, err := strconv.ParseFloat(secs, 64)
+		if err != nil || d < 0 {
+			return NOMessage, errInvalidArgument(secs)
+		}
+		time.Sleep(time.Duration(float64(time.Second) * d))
+		return OKMessage(msg, start), nil
+	case "set-active-expire":
+		var on string
+		if vs, on, ok = tokenval(vs); !ok || on == "" || len(vs) != 0 {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		switch on {
+		case "0":
+			server.activeExpireOff.set(true)
+		case "1":
+			server.activeExpireOff.set(false)
+		default:
+			return NOMessage, errInvalidArgument(on)
+		}
+		return OKMessage(msg, start), nil
+	}
+	return NOMessage, errInvalidArgument(sub)
+}
+
+// debugObject returns how an object is stored.
+func (server *Server) debugObject(msg *Message, start time.Time, key, id string,
+) (resp.Value, error) {
+	col := server.getCol(key)
+	if col == nil {
+		return NOMessage, errKeyNotFound
+	}
+	info, ok := col.ObjectInfo(id)
+	if !ok {
+		return NOMessage, errIDNotFound
+	}
+	ttl := -1
+	if ex, _ := col.Expires(id); ex != 0 {
+		ttl = int(time.Until(time.Unix(0, ex)) / time.Second)
+		if ttl < 0 {
+			ttl = 0
+		}
+	}
+	switch msg.OutputType {
+	case JSON:
+		return resp.StringValue(`{"ok":true,"object":{"encoding":` +
+			jsonString(info.Encoding) +
+			`,"weight":` + strconv.Itoa(info.Weight) +
+			`,"points":` + strconv.Itoa(info.Points) +
+			`,"fields":` + strconv.Itoa(info.Fields) +
+			`,"ttl":` + strconv.Itoa(ttl) +
+			`},"elapsed":"` + time.Since(start).String() + "\"}"), nil
+	case RESP:
+		return resp.SimpleStringValue("encoding:" + info.Encoding +
+			" weight:" + strconv.Itoa(info.Weight) +
+			" points:" + strconv.Itoa(info.Points) +
+			" fields:" + strconv.Itoa(info.Fields) +
+			" ttl:" + strconv.Itoa(ttl)), nil
+	}
+	return NOMessage, nil
+}
+
+// handlePprof registers the pprof endpoints on a metrics server mux.
+func handlePprof(mux *http.ServeMux) {
+	gate := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !debugAllowed(r.RemoteAddr) {
+				http.NotFound(w, r)
+				return
+			}
+			h(w, r)
+		}
+	}
+	mux.HandleFunc("/debug/pprof/", gate(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", gate(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", gate(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", gate(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", gate(pprof.Trace))
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/grid"
+)
+
+// maxDensityCells is the largest number of cells in a density grid.
+const maxDensityCells = 1 << 20
+
+// cmdDensity returns a grid of the number of objects in each cell of an
+// area, or of the sum of a field of the objects. An object is in the cell
+// that has its center.
+//
+//	DENSITY key [FIELD name] [WHERE field min max ...] GRID rows cols
+//	    BOUNDS minlat minlon maxlat maxlon | HASH geohash | TILE x y z |
+//	    QUADKEY quadkey
+func (server *Server) cmdDensity(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key, field string
+	var wheres []whereT
+	var rows, cols uint64
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var rect *geojson.Rect
+	for rect == nil {
+		var arg string
+		if vs, arg, ok = tokenval(vs); !ok || arg == "" {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		var err error
+		switch strings.ToLower(arg) {
+		case "field":
+			if field != "" {
+				return NOMessage, errDuplicateArgument(strings.ToUpper(arg))
+			}
+			if vs, field, ok = tokenval(vs); !ok || field == "" {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+		case "where":
+			var where whereT
+			if vs, where, err = parseWhere(vs); err != nil {
+				return NOMessage, err
+			}
+			wheres = append(wheres, where)
+		case "grid":
+			if rows != 0 {
+				return NOMessage, errDuplicateArgument(strings.ToUpper(arg))
+			}
+			var srows, scols string
+			if vs, srows, ok = tokenval(vs); !ok || srows == "" {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+			if vs, scols, ok = tokenval(vs); !ok || scols == "" {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+			if rows, err = strconv.ParseUint(srows, 10, 32); err != nil ||
+				rows == 0 || rows > maxDensityCells {
+				return NOMessage, errInvalidArgument(srows)
+			}
+			if cols, err = strconv.ParseUint(scols, 10, 32); err != nil ||
+				cols == 0 || rows*cols > maxDensityCells {
+				return NOMessage, errInvalidArgument(scols)
+			}
+		case "bounds", "hash", "tile", "quadkey":
+			if vs, rect, err = parseRectArea(strings.ToLower(arg), vs); err != nil {
+				return NOMessage, err
+			}
+		default:
+			return NOMessage, errInvalidArgument(arg)
+		}
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if rows == 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	g := grid.New(rect.Rect(), int(rows), int(cols))
+	var count int
+	if col := server.getCol(key); col != nil {
+		fmap := col.FieldMap()
+		fieldValue := func(fields []float64, name string) float64 {
+			if idx, ok := fmap[name]; ok && idx < len(fields) {
+				return fields[idx]
+			}
+			return 0
+		}
+		col.Intersects(rect, 0, nil, msg.Deadline, func(
+			id string, o geojson.Object, fields []float64,
+		) bool {
+			for _, where := range wheres {
+				if where.eq != nil {
+					value, _ := col.StringField(id, where.field)
+					if !where.matchString(value) {
+						return true
+					}
+				} else if !where.match(fieldValue(fields, where.field)) {
+					return true
+				}
+			}
+			value := 1.0
+			if field != "" {
+				value = fieldValue(fields, field)
+			}
+			if g.Add(o.Center(), value) {
+				count++
+			}
+			return true
+		})
+	}
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"bounds":`)
+		buf.Write(appendJSONSimpleBounds(nil, rect, -1))
+		buf.WriteString(`,"rows":` + strconv.Itoa(g.Rows()))
+		buf.WriteString(`,"cols":` + strconv.Itoa(g.Cols()))
+		buf.WriteString(`,"cells":[`)
+		for row := 0; row < g.Rows(); row++ {
+			if row > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('[')
+			for col := 0; col < g.Cols(); col++ {
+				if col > 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(strconv.FormatFloat(g.Value(row, col), 'f', -1, 64))
+			}
+			buf.WriteByte(']')
+		}
+		buf.WriteString(`],"max":` + strconv.FormatFloat(g.Max(), 'f', -1, 64))
+		buf.WriteString(`,"count":` + strconv.Itoa(count))
+		buf.WriteString(`,"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		vals := make([]resp.Value, g.Rows())
+		for row := range vals {
+			cells := make([]resp.Value, g.Cols())
+			for col := range cells {
+				cells[col] = resp.FloatValue(g.Value(row, col))
+			}
+			vals[row] = resp.ArrayValue(cells)
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
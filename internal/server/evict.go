@@ -0,0 +1,237 @@
+package server
+
+import (
+	"runtime"
+	"sort"
+	"sync/atomic"
+
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// Eviction
+//
+// When the heap grows beyond the maxmemory config property, the
+// maxmemory-policy property decides what happens:
+//
+//   reject-writes      SET and FSET fail with an OOM error. This is the
+//                      default.
+//   evict-oldest-ttl   objects with an expiration are deleted, soonest to
+//                      expire first.
+//   evict-lru-object   the least recently used objects are deleted. An
+//                      object is used when it's written by SET or read by
+//                      GET. The objects are sampled, so this is an
+//                      approximation.
+//   evict-coldest-key  the key that was least recently read from or written
+//                      to is dropped.
+//
+// Evicted objects are deleted as if by DEL or DROP, which means that they
+// are written to the AOF and trigger geofence notifications. When there is
+// nothing left to evict, such as with evict-oldest-ttl and no objects that
+// expire, writes are rejected as with reject-writes.
+
+const (
+	evictRejectWrites = "reject-writes"
+	evictOldestTTL    = "evict-oldest-ttl"
+	evictLRUObject    = "evict-lru-object"
+	evictColdestKey   = "evict-coldest-key"
+)
+
+// evictSamples is the number of objects sampled from each key for
+// evict-lru-object.
+const evictSamples = 16
+
+// evictMargin is the extra fraction of the heap that's freed beyond
+// maxmemory, which keeps the server from evicting again right away.
+const evictMargin = 0.05
+
+type evictCandidate struct {
+	key  string
+	id   string
+	rank int64 // lower is evicted first
+}
+
+// rejectWrites returns true when write commands that add data should fail
+// with an OOM error.
+func (server *Server) rejectWrites() bool {
+	if server.config.maxMemory() == 0 || !server.outOfMemory.on() {
+		return false
+	}
+	return server.config.maxMemoryPolicy() == evictRejectWrites ||
+		server.evictStalled.on()
+}
+
+// evict deletes objects, or keys, by the policy until the heap is expected
+// to be below maxmemory. The amount to delete is estimated from the weights
+// of the collections. Returns false when there was not enough to evict.
+func (server *Server) evict(policy string, heap, maxMemory int) bool {
+	var total int
+	unlock := server.lockAllRead()
+	server.cols.Ascend(nil, func(v interface{}) bool {
+		total += v.(*collectionKeyContainer).col.TotalWeight()
+		return true
+	})
+	unlock()
+	if total == 0 || heap == 0 {
+		return false
+	}
+	// The weights are only a fraction of what the collections take up on
+	// the heap, so the overage is scaled by the ratio of the two.
+	over := float64(heap) - float64(maxMemory)*(1-evictMargin)
+	target := int(over * float64(total) / float64(heap))
+	var evicted int
+	for target > 0 {
+		var freed, n int
+		switch policy {
+		case evictOldestTTL:
+			freed, n = server.evictCandidates(server.oldestTTLCandidates())
+		case evictLRUObject:
+			freed, n = server.evictCandidates(server.lruCandidates())
+		case evictColdestKey:
+			freed, n = server.evictColdestKey()
+		}
+		if n == 0 {
+			break
+		}
+		target -= freed
+		evicted += n
+		// let others run between batches
+		runtime.Gosched()
+	}
+	if evicted > 0 {
+		log.Debugf("Evicted %d items\n", evicted)
+	}
+	return target <= 0
+}
+
+// oldestTTLCandidates returns the objects that expire soonest, across all
+// keys.
+func (server *Server) oldestTTLCandidates() []evictCandidate {
+	var cands []evictCandidate
+	unlock := server.lockAllRead()
+	server.cols.Ascend(nil, func(v interface{}) bool {
+		c := v.(*collectionKeyContainer)
+		var n int
+		c.col.ScanExpires(func(id string, expires int64) bool {
+			cands = append(cands, evictCandidate{c.key, id, expires})
+			n++
+			return n < bgExpireBatch
+		})
+		return true
+	})
+	unlock()
+	return sortCandidates(cands)
+}
+
+// lruCandidates returns a sample of the least recently used objects, across
+// all keys.
+func (server *Server) lruCandidates() []evictCandidate {
+	var cands []evictCandidate
+	unlock := server.lockAllRead()
+	server.cols.Ascend(nil, func(v interface{}) bool {
+		c := v.(*collectionKeyContainer)
+		c.col.Sample(evictSamples, func(id string, atime int64) bool {
+			cands = append(cands, evictCandidate{c.key, id, atime})
+			return true
+		})
+		return true
+	})
+	unlock()
+	cands = sortCandidates(cands)
+	// Only the oldest quarter of the samples is evicted, otherwise a key
+	// with few objects would be sampled in full and emptied in one go.
+	if len(cands) > 4 {
+		cands = cands[:len(cands)/4]
+	}
+	return cands
+}
+
+// sortCandidates sorts by rank and trims the candidates to a single batch.
+func sortCandidates(cands []evictCandidate) []evictCandidate {
+	sort.Slice(cands, func(i, j int) bool {
+		return cands[i].rank < cands[j].rank
+	})
+	if len(cands) > bgExpireBatch {
+		cands = cands[:bgExpireBatch]
+	}
+	return cands
+}
+
+// evictCandidates deletes the candidates and returns the weight freed and
+// the number of objects deleted.
+func (server *Server) evictCandidates(cands []evictCandidate) (freed, n int) {
+	byKey := make(map[string][]string)
+	var keys []string
+	for _, cand := range cands {
+		if _, ok := byKey[cand.key]; !ok {
+			keys = append(keys, cand.key)
+		}
+		byKey[cand.key] = append(byKey[cand.key], cand.id)
+	}
+	for _, key := range keys {
+		kfreed, kn := server.evictObjects(key, byKey[key])
+		freed += kfreed
+		n += kn
+	}
+	return freed, n
+}
+
+// evictObjects deletes the objects from the key.
+func (server *Server) evictObjects(key string, ids []string) (freed, n int) {
+	defer server.lockKeyWriteFor(key, func(col *collection.Collection) bool {
+		return col.Count() <= len(ids)
+	})()
+	col := server.getCol(key)
+	if col == nil {
+		return 0, 0
+	}
+	weight := col.TotalWeight()
+	for _, id := range ids {
+		msg := &Message{Args: []string{"del", key, id}}
+		_, d, err := server.cmdDel(msg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !d.updated {
+			// deleted since it was picked
+			continue
+		}
+		if err := server.writeAOF(msg.Args, &d); err != nil {
+			log.Fatal(err)
+		}
+		n++
+	}
+	server.statsEvicted.add(n)
+	return weight - col.TotalWeight(), n
+}
+
+// evictColdestKey drops the key that was accessed least recently.
+func (server *Server) evictColdestKey() (freed, n int) {
+	server.wmu.Lock()
+	defer server.wmu.Unlock()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	var coldest *collectionKeyContainer
+	server.cols.Ascend(nil, func(v interface{}) bool {
+		c := v.(*collectionKeyContainer)
+		if coldest == nil ||
+			atomic.LoadInt64(&c.atime) < atomic.LoadInt64(&coldest.atime) {
+			coldest = c
+		}
+		return true
+	})
+	if coldest == nil {
+		return 0, 0
+	}
+	freed, n = coldest.col.TotalWeight(), coldest.col.Count()
+	msg := &Message{Args: []string{"drop", coldest.key}}
+	_, d, err := server.cmdDrop(msg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := server.writeAOF(msg.Args, &d); err != nil {
+		log.Fatal(err)
+	}
+	server.statsEvicted.add(n)
+	return freed, n
+}
@@ -1,49 +1,121 @@
 package server
 
 import (
+	"runtime"
 	"time"
 
+	"github.com/tidwall/tile38/internal/collection"
 	"github.com/tidwall/tile38/internal/log"
 )
 
 const bgExpireDelay = time.Second / 10
 
+// bgExpireBatch is the most items that are deleted while holding the lock.
+// The lock is released between batches so that other commands can run.
+const bgExpireBatch = 256
+
 // backgroundExpiring deletes expired items from the database.
-// It's executes every 1/10 of a second.
+// It sweeps all keys every 1/10 of a second, deleting in small batches.
 func (s *Server) backgroundExpiring() {
 	for {
 		if s.stopServer.on() {
 			return
 		}
-		func() {
-			s.mu.Lock()
-			defer s.mu.Unlock()
-			now := time.Now().UnixNano()
-			var ids []string
-			var msgs []*Message
-			s.cols.Ascend(nil, func(v interface{}) bool {
-				col := v.(*collectionKeyContainer)
-				ids = col.col.Expired(now, ids[:0])
-				for _, id := range ids {
-					msgs = append(msgs, &Message{
-						Args: []string{"del", col.key, id},
-					})
-				}
-				return true
-			})
-			for _, msg := range msgs {
-				_, d, err := s.cmdDel(msg)
-				if err != nil {
-					log.Fatal(err)
-				}
-				if err := s.writeAOF(msg.Args, &d); err != nil {
-					log.Fatal(err)
-				}
-			}
-			if len(msgs) > 0 {
-				log.Debugf("Expired %d items\n", len(msgs))
+		// DEBUG SET-ACTIVE-EXPIRE 0 turns off the sweeps, and they don't
+		// write to the aof while the writes are paused
+		if !s.activeExpireOff.on() && s.writesPause() == nil {
+			if n := s.expireSweep(); n > 0 {
+				log.Debugf("Expired %d items\n", n)
 			}
-		}()
+		}
 		time.Sleep(bgExpireDelay)
 	}
 }
+
+// expireSweep deletes all of the items that have expired and returns the
+// number of items deleted.
+func (s *Server) expireSweep() int {
+	var keys []string
+	s.mu.RLock()
+	s.cols.Ascend(nil, func(v interface{}) bool {
+		keys = append(keys, v.(*collectionKeyContainer).key)
+		return true
+	})
+	s.mu.RUnlock()
+	var total int
+	var ids []string
+	for _, key := range keys {
+		for {
+			var n int
+			n, ids = s.expireBatch(key, ids)
+			total += n
+			if n < bgExpireBatch {
+				break
+			}
+			// there are more expired items in this key, let others run
+			runtime.Gosched()
+		}
+	}
+	return total
+}
+
+// expireBatch deletes up to bgExpireBatch expired items from the key.
+func (s *Server) expireBatch(key string, buf []string) (n int, ids []string) {
+	defer s.lockKeyWriteFor(key, func(col *collection.Collection) bool {
+		return col.Count() <= bgExpireBatch
+	})()
+	col := s.getCol(key)
+	s.applyReadTouches(key, col)
+	if col == nil {
+		return 0, buf
+	}
+	ids = col.ExpiredLimit(time.Now().UnixNano(), buf, bgExpireBatch)
+	var fmap map[string]int
+	if len(ids) > 0 {
+		// the field map of the objects, for the "expired" fence events
+		fmap = make(map[string]int)
+		for field, idx := range col.FieldMap() {
+			fmap[field] = idx
+		}
+	}
+	for _, id := range ids {
+		msg := &Message{Args: []string{"del", key, id}}
+		_, d, err := s.cmdDel(msg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d.expired = true
+		d.fmap = fmap
+		if err := s.writeAOF(msg.Args, &d); err != nil {
+			log.Fatal(err)
+		}
+	}
+	s.statsExpired.add(len(ids))
+	n = len(ids)
+	if len(ids) < bgExpireBatch {
+		n += s.expireFields(key, col, bgExpireBatch-len(ids))
+	}
+	return n, ids
+}
+
+// expireFields clears up to limit expired fields of the objects of the key,
+// and returns the number of fields cleared. The fields are set to zero with
+// FSET, which notifies the geofences of the change.
+func (s *Server) expireFields(key string, col *collection.Collection, limit int) int {
+	fields := col.ExpiredFieldsLimit(time.Now().UnixNano(), nil, limit)
+	for i, f := range fields {
+		msg := &Message{Args: []string{"fset", key, f.ID, "XX", f.Field, "0"}}
+		_, d, err := s.cmdFset(msg)
+		if err == errOOM {
+			// try again on the next sweep
+			return i
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := s.writeAOF(msg.Args, &d); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return len(fields)
+}
@@ -10,12 +10,23 @@ import (
 	"github.com/tidwall/geojson/geo"
 	"github.com/tidwall/geojson/geometry"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/tile38/internal/collection"
 	"github.com/tidwall/tile38/internal/glob"
 )
 
 // FenceMatch executes a fence match returns back json messages for fence detection.
 func FenceMatch(hookName string, sw *scanWriter, fence *liveFenceSwitches, metas []FenceMeta, details *commandDetails) []string {
 	msgs := fenceMatch(hookName, sw, fence, metas, details)
+	if details.road != "" {
+		for i := range msgs {
+			msgs[i] = appendRoad(msgs[i], details.road)
+		}
+	}
+	if details.areas != nil {
+		for i := range msgs {
+			msgs[i] = appendAreas(msgs[i], details.areas)
+		}
+	}
 	if len(fence.accept) == 0 {
 		return msgs
 	}
@@ -47,9 +58,14 @@ func appendHookDetails(b []byte, hookName string, metas []FenceMeta) []byte {
 	return b
 }
 
+// objIsSpatial returns true for the geometries, which are the spatial
+// objects and the circles, see collection/circle.go.
 func objIsSpatial(obj geojson.Object) bool {
-	_, ok := obj.(geojson.Spatial)
-	return ok
+	switch obj.(type) {
+	case geojson.Spatial, *geojson.Circle:
+		return true
+	}
+	return false
 }
 
 func hookJSONString(hookName string, metas []FenceMeta) string {
@@ -84,6 +100,11 @@ func fenceMatch(
 		}
 	}
 	if details.command == "del" {
+		if details.expired && details.fmap != nil &&
+			(fence.detect == nil || fence.detect["expired"]) &&
+			fenceMatchObject(fence, details.obj) {
+			return fenceMatchExpired(hookName, sw, fence, metas, details)
+		}
 		return []string{
 			`{"command":"del"` + hookJSONString(hookName, metas) +
 				`,"key":` + jsonString(details.key) +
@@ -106,8 +127,12 @@ func fenceMatch(
 			detect = "roam"
 		} else {
 			// not using roaming
-			match1 := fenceMatchObject(fence, details.oldObj)
+			match1, ok := details.fences.inside(fence)
+			if !ok {
+				match1 = fenceMatchObject(fence, details.oldObj)
+			}
 			match2 := fenceMatchObject(fence, details.obj)
+			details.fences.test(fence, match2)
 			if match1 && match2 {
 				detect = "inside"
 			} else if match1 && !match2 {
@@ -163,38 +188,11 @@ func fenceMatch(
 		}
 		break
 	}
-	sw.mu.Lock()
-	var distance float64
-	if fence.distance && fence.obj != nil {
-		distance = details.obj.Distance(fence.obj)
-	}
-	sw.fmap = details.fmap
-	sw.fullFields = true
-	sw.msg.OutputType = JSON
-	sw.writeObject(ScanWriterParams{
-		id:         details.id,
-		o:          details.obj,
-		fields:     details.fields,
-		noLock:     true,
-		distance:   distance,
-		distOutput: fence.distance,
-	})
-
-	if sw.wr.Len() == 0 {
-		sw.mu.Unlock()
+	res := fenceWriteObject(sw, fence, details)
+	if res == "" {
 		return nil
 	}
 
-	res := sw.wr.String()
-	sw.wr.Reset()
-	if len(res) > 0 && res[0] == ',' {
-		res = res[1:]
-	}
-	if sw.output == outputIDs {
-		res = `{"id":` + string(res) + `}`
-	}
-	sw.mu.Unlock()
-
 	var group string
 	if detect == "enter" {
 		group = sw.s.groupConnect(hookName, details.key, details.id)
@@ -311,6 +309,60 @@ func extendRoamMessage(
 	return string(nmsg)
 }
 
+// fenceWriteObject returns the json of the object of the details, as it's
+// written by the scanWriter of the fence.
+func fenceWriteObject(sw *scanWriter, fence *liveFenceSwitches, details *commandDetails) string {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	var distance float64
+	if fence.distance && fence.obj != nil {
+		distance = details.obj.Distance(fence.obj)
+	}
+	sw.fmap = details.fmap
+	sw.fullFields = true
+	sw.msg.OutputType = JSON
+	sw.writeObject(ScanWriterParams{
+		id:         details.id,
+		o:          details.obj,
+		fields:     details.fields,
+		noLock:     true,
+		distance:   distance,
+		distOutput: fence.distance,
+	})
+	if sw.wr.Len() == 0 {
+		return ""
+	}
+	res := sw.wr.String()
+	sw.wr.Reset()
+	if len(res) > 0 && res[0] == ',' {
+		res = res[1:]
+	}
+	if sw.output == outputIDs {
+		res = `{"id":` + string(res) + `}`
+	}
+	return res
+}
+
+// fenceMatchExpired returns the "expired" event of an object that was inside
+// the fence when it expired. The object is already deleted, so the event
+// doesn't have a group.
+func fenceMatchExpired(
+	hookName string, sw *scanWriter, fence *liveFenceSwitches,
+	metas []FenceMeta, details *commandDetails,
+) []string {
+	res := fenceWriteObject(sw, fence, details)
+	if len(res) == 0 || res[0] != '{' {
+		return nil
+	}
+	var buf []byte
+	buf = append(buf, `{"command":"del","detect":"expired"`...)
+	buf = appendHookDetails(buf, hookName, metas)
+	buf = appendJSONString(append(buf, `,"key":`...), details.key)
+	buf = appendJSONTimeFormat(append(buf, `,"time":`...), details.timestamp)
+	buf = append(append(buf, ','), res[1:]...)
+	return []string{string(buf)}
+}
+
 func makemsg(
 	command, group, detect, hookName string,
 	metas []FenceMeta, key string, t time.Time, tail string,
@@ -338,11 +390,11 @@ func fenceMatchObject(fence *liveFenceSwitches, obj geojson.Object) bool {
 	switch fence.cmd {
 	case "nearby":
 		// nearby is an INTERSECT on a Circle
-		return obj.Intersects(fence.obj)
+		return collection.ObjectIntersects(obj, fence.obj)
 	case "within":
-		return obj.Within(fence.obj)
+		return collection.ObjectWithin(obj, fence.obj)
 	case "intersects":
-		return obj.Intersects(fence.obj)
+		return collection.ObjectIntersects(obj, fence.obj)
 	}
 	return false
 }
@@ -0,0 +1,278 @@
+package server
+
+// Fence index: the fences of the hooks are indexed by the key that they
+// watch, and by their area in an r-tree, so that a SET only evaluates the
+// fences of its key that it can enter, exit, cross or be inside of. The
+// fences with "outside" detection, which is the default, are evaluated for
+// every update of their key.
+//
+// The index also remembers which fences each object is inside of, as a
+// bitset by id. The next update of the object takes these fences instead of
+// searching with the area of the old object, and doesn't test the old object
+// against them again. The memberships are forgotten when the hooks of the
+// key change, or when the object was changed without its hooks.
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/rtree"
+)
+
+// fenceBits is a set of fences, by their bit in the keyFences.
+type fenceBits []uint64
+
+func (b fenceBits) has(i int) bool {
+	return i/64 < len(b) && b[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (b *fenceBits) set(i int) {
+	for i/64 >= len(*b) {
+		*b = append(*b, 0)
+	}
+	(*b)[i/64] |= 1 << uint(i%64)
+}
+
+// eachOr calls the function with the bits that are in either set, a word at a
+// time, so that the cost follows the bits that are set.
+func (b fenceBits) eachOr(other fenceBits, fn func(i int)) {
+	n := len(b)
+	if len(other) > n {
+		n = len(other)
+	}
+	for w := 0; w < n; w++ {
+		var word uint64
+		if w < len(b) {
+			word = b[w]
+		}
+		if w < len(other) {
+			word |= other[w]
+		}
+		for word != 0 {
+			i := bits.TrailingZeros64(word)
+			fn(w*64 + i)
+			word &= word - 1
+		}
+	}
+}
+
+// fenceMembers are the fences of an object after its last update.
+type fenceMembers struct {
+	obj     geojson.Object // the object of the update
+	gen     uint64         // of the keyFences at the update
+	inside  fenceBits      // fences the object is inside of
+	unknown fenceBits      // candidates that weren't tested, such as by a glob
+}
+
+// keyFences are the fences of the hooks of a key.
+type keyFences struct {
+	tree    rtree.RTree    // fences with an area
+	cross   rtree.RTree    // fences with an area and "cross" detection
+	out     map[*Hook]bool // fences with "outside" detection
+	bits    map[*liveFenceSwitches]int
+	hooks   []*Hook // by bit, nil for the free bits
+	gen     uint64  // changed with the hooks
+	objects map[string]*fenceMembers
+}
+
+// fenceIndex are the fences of the hooks, by key.
+type fenceIndex map[string]*keyFences
+
+func hookRect(hook *Hook) (rect geometry.Rect, ok bool) {
+	if hook.Fence == nil || hook.Fence.obj == nil {
+		return rect, false
+	}
+	return hook.Fence.obj.Rect(), true
+}
+
+func hookOutside(hook *Hook) bool {
+	return hook.Fence.detect == nil || hook.Fence.detect["outside"]
+}
+
+// insert adds the fence of a hook.
+func (idx fenceIndex) insert(hook *Hook) {
+	kf := idx[hook.Key]
+	if kf == nil {
+		kf = &keyFences{
+			out:     make(map[*Hook]bool),
+			bits:    make(map[*liveFenceSwitches]int),
+			objects: make(map[string]*fenceMembers),
+		}
+		idx[hook.Key] = kf
+	}
+	if hookOutside(hook) {
+		kf.out[hook] = true
+	}
+	if rect, ok := hookRect(hook); ok {
+		min, max := [2]float64{rect.Min.X, rect.Min.Y},
+			[2]float64{rect.Max.X, rect.Max.Y}
+		kf.tree.Insert(min, max, hook)
+		if hook.Fence.detect["cross"] {
+			kf.cross.Insert(min, max, hook)
+		}
+	}
+	bit := len(kf.hooks)
+	for i, h := range kf.hooks {
+		if h == nil {
+			bit = i
+			break
+		}
+	}
+	if bit == len(kf.hooks) {
+		kf.hooks = append(kf.hooks, nil)
+	}
+	kf.hooks[bit] = hook
+	kf.bits[hook.Fence] = bit
+	kf.gen++
+}
+
+// delete removes the fence of a hook.
+func (idx fenceIndex) delete(hook *Hook) {
+	kf := idx[hook.Key]
+	if kf == nil {
+		return
+	}
+	bit, ok := kf.bits[hook.Fence]
+	if !ok {
+		return
+	}
+	delete(kf.out, hook)
+	if rect, ok := hookRect(hook); ok {
+		min, max := [2]float64{rect.Min.X, rect.Min.Y},
+			[2]float64{rect.Max.X, rect.Max.Y}
+		kf.tree.Delete(min, max, hook)
+		if hook.Fence.detect["cross"] {
+			kf.cross.Delete(min, max, hook)
+		}
+	}
+	delete(kf.bits, hook.Fence)
+	kf.hooks[bit] = nil
+	kf.gen++
+	if len(kf.bits) == 0 {
+		delete(idx, hook.Key)
+	}
+}
+
+// fenceUpdate carries the fences of the old object of an update to the
+// fence matching, and collects the fences of the new object.
+type fenceUpdate struct {
+	kf     *keyFences
+	id     string
+	prev   *fenceMembers // nil when they aren't known
+	next   fenceMembers
+	tested fenceBits
+}
+
+// inside returns true when the old object is inside of the fence, and false
+// for ok when it isn't known.
+func (u *fenceUpdate) inside(fence *liveFenceSwitches) (inside, ok bool) {
+	if u == nil || u.prev == nil {
+		return false, false
+	}
+	bit, ok := u.kf.bits[fence]
+	if !ok || u.prev.unknown.has(bit) {
+		return false, false
+	}
+	return u.prev.inside.has(bit), true
+}
+
+// test records whether the new object is inside of the fence.
+func (u *fenceUpdate) test(fence *liveFenceSwitches, inside bool) {
+	if u == nil {
+		return
+	}
+	if bit, ok := u.kf.bits[fence]; ok {
+		u.tested.set(bit)
+		if inside {
+			u.next.inside.set(bit)
+		}
+	}
+}
+
+// commit remembers the fences of the new object. The candidates that
+// weren't tested may have the object inside.
+func (u *fenceUpdate) commit(candidates []*Hook) {
+	if u == nil {
+		return
+	}
+	for _, hook := range candidates {
+		if bit, ok := u.kf.bits[hook.Fence]; ok && !u.tested.has(bit) {
+			u.next.unknown.set(bit)
+		}
+	}
+	u.kf.objects[u.id] = &u.next
+}
+
+// candidates returns the hooks whose fences may match an update, and the
+// update of the fences of the object.
+func (idx fenceIndex) candidates(d *commandDetails) ([]*Hook, *fenceUpdate) {
+	kf := idx[d.key]
+	if kf == nil {
+		return nil, nil
+	}
+	var u *fenceUpdate
+	switch {
+	case d.command == "drop" || d.command == "rename" ||
+		d.command == "renamenx":
+		kf.objects = make(map[string]*fenceMembers)
+	case d.obj == nil || d.id == "":
+		delete(kf.objects, d.id)
+	default:
+		u = &fenceUpdate{kf: kf, id: d.id,
+			next: fenceMembers{obj: d.obj, gen: kf.gen}}
+		if m := kf.objects[d.id]; m != nil && m.gen == kf.gen &&
+			d.oldObj != nil && m.obj == d.oldObj {
+			u.prev = m
+		}
+	}
+	candidates := make(map[*Hook]bool, len(kf.out))
+	for hook := range kf.out {
+		candidates[hook] = true
+	}
+	add := func(min, max [2]float64, value interface{}) bool {
+		candidates[value.(*Hook)] = true
+		return true
+	}
+	// look for candidates that might "cross" geofences
+	if d.oldObj != nil && d.obj != nil && kf.cross.Len() > 0 {
+		r1, r2 := d.oldObj.Rect(), d.obj.Rect()
+		kf.cross.Search(
+			[2]float64{
+				math.Min(r1.Min.X, r2.Min.X),
+				math.Min(r1.Min.Y, r2.Min.Y),
+			},
+			[2]float64{
+				math.Max(r1.Max.X, r2.Max.X),
+				math.Max(r1.Max.Y, r2.Max.Y),
+			}, add)
+	}
+	// look for candidates that the old object was inside of after its last
+	// update, or that overlap the old object when that isn't known
+	if u != nil && u.prev != nil {
+		u.prev.inside.eachOr(u.prev.unknown, func(bit int) {
+			if bit < len(kf.hooks) && kf.hooks[bit] != nil {
+				candidates[kf.hooks[bit]] = true
+			}
+		})
+	} else if d.oldObj != nil {
+		r := d.oldObj.Rect()
+		kf.tree.Search([2]float64{r.Min.X, r.Min.Y},
+			[2]float64{r.Max.X, r.Max.Y}, add)
+	}
+	// look for candidates that overlap the new object
+	if d.obj != nil {
+		r := d.obj.Rect()
+		kf.tree.Search([2]float64{r.Min.X, r.Min.Y},
+			[2]float64{r.Max.X, r.Max.Y}, add)
+	}
+	if len(candidates) == 0 {
+		return nil, u
+	}
+	ret := make([]*Hook, 0, len(candidates))
+	for hook := range candidates {
+		ret = append(ret, hook)
+	}
+	return ret, u
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+)
+
+func TestFenceIndex(t *testing.T) {
+	rect := func(minX, minY, maxX, maxY float64) geojson.Object {
+		return geojson.NewRect(geometry.Rect{
+			Min: geometry.Point{X: minX, Y: minY},
+			Max: geometry.Point{X: maxX, Y: maxY},
+		})
+	}
+	point := func(x, y float64) geojson.Object {
+		return geojson.NewPoint(geometry.Point{X: x, Y: y})
+	}
+	hook := func(name, key, detect string, obj geojson.Object) *Hook {
+		fence := &liveFenceSwitches{obj: obj}
+		if detect != "" {
+			fence.detect = make(map[string]bool)
+			for _, d := range strings.Split(detect, ",") {
+				fence.detect[d] = true
+			}
+		}
+		return &Hook{Name: name, Key: key, Fence: fence}
+	}
+	idx := make(fenceIndex)
+	hooks := []*Hook{
+		hook("west", "fleet", "enter,exit", rect(0, 0, 10, 10)),
+		hook("east", "fleet", "enter,exit", rect(20, 0, 30, 10)),
+		hook("line", "fleet", "cross", rect(12, 0, 18, 10)),
+		hook("all", "fleet", "", rect(40, 40, 50, 50)),
+		hook("other", "trucks", "enter", rect(0, 0, 10, 10)),
+	}
+	for _, h := range hooks {
+		idx.insert(h)
+	}
+	test := func(d *commandDetails, expect string) {
+		t.Helper()
+		var names []string
+		hooks, _ := idx.candidates(d)
+		for _, h := range hooks {
+			names = append(names, h.Name)
+		}
+		sort.Strings(names)
+		if strings.Join(names, ",") != expect {
+			t.Fatalf("expected '%s', got '%s'", expect, strings.Join(names, ","))
+		}
+	}
+	// the default detection is evaluated for every update of the key
+	test(&commandDetails{key: "fleet", obj: point(5, 5)}, "all,west")
+	test(&commandDetails{key: "fleet", obj: point(100, 100)}, "all")
+	test(&commandDetails{key: "trucks", obj: point(5, 5)}, "other")
+	test(&commandDetails{key: "cars", obj: point(5, 5)}, "")
+	// moving from west to east crosses the line
+	test(&commandDetails{key: "fleet", oldObj: point(5, 5),
+		obj: point(25, 5)}, "all,east,line,west")
+	idx.delete(hooks[3])
+	idx.delete(hooks[4])
+	test(&commandDetails{key: "fleet", obj: point(100, 100)}, "")
+	test(&commandDetails{key: "trucks", obj: point(5, 5)}, "")
+	if len(idx) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(idx))
+	}
+}
+
+func TestFenceIndexMembers(t *testing.T) {
+	fence := &liveFenceSwitches{obj: geojson.NewRect(geometry.Rect{
+		Max: geometry.Point{X: 10, Y: 10},
+	})}
+	fence.detect = map[string]bool{"enter": true, "exit": true}
+	west := &Hook{Name: "west", Key: "fleet", Fence: fence}
+	idx := make(fenceIndex)
+	idx.insert(west)
+	p1 := geojson.NewPoint(geometry.Point{X: 5, Y: 5})
+	p2 := geojson.NewPoint(geometry.Point{X: 50, Y: 50})
+	// the first update isn't known
+	hooks, u := idx.candidates(&commandDetails{key: "fleet", id: "a", obj: p1})
+	if len(hooks) != 1 || u == nil || u.prev != nil {
+		t.Fatalf("unexpected %v %v", hooks, u)
+	}
+	if _, ok := u.inside(fence); ok {
+		t.Fatal("expected an unknown membership")
+	}
+	u.test(fence, true)
+	u.commit(hooks)
+	// the next one takes the fence from the last update
+	hooks, u = idx.candidates(&commandDetails{key: "fleet", id: "a",
+		oldObj: p1, obj: p2})
+	if len(hooks) != 1 || u.prev == nil {
+		t.Fatalf("unexpected %v %v", hooks, u)
+	}
+	if inside, ok := u.inside(fence); !inside || !ok {
+		t.Fatalf("expected inside, got %v %v", inside, ok)
+	}
+	u.test(fence, false)
+	u.commit(hooks)
+	// the object that was outside is known to still be outside
+	hooks, u = idx.candidates(&commandDetails{key: "fleet", id: "a",
+		oldObj: p2, obj: p2})
+	if len(hooks) != 0 || u.prev == nil {
+		t.Fatalf("unexpected %v %v", hooks, u)
+	}
+	u.commit(hooks)
+	// an object changed without the hooks isn't known
+	p3 := geojson.NewPoint(geometry.Point{X: 5, Y: 5})
+	hooks, u = idx.candidates(&commandDetails{key: "fleet", id: "a",
+		oldObj: p3, obj: p2})
+	if len(hooks) != 1 || u.prev != nil {
+		t.Fatalf("unexpected %v %v", hooks, u)
+	}
+	u.commit(hooks)
+	// and neither are the objects after the hooks change
+	idx.insert(&Hook{Name: "east", Key: "fleet", Fence: &liveFenceSwitches{}})
+	_, u = idx.candidates(&commandDetails{key: "fleet", id: "a",
+		oldObj: p2, obj: p2})
+	if u.prev != nil {
+		t.Fatal("expected an unknown membership")
+	}
+	idx.candidates(&commandDetails{key: "fleet", id: "a", oldObj: p2})
+	if len(idx["fleet"].objects) != 0 {
+		t.Fatal("expected the deleted object to be forgotten")
+	}
+}
+
+func TestFenceBits(t *testing.T) {
+	var a, b fenceBits
+	for _, i := range []int{0, 3, 63, 64, 200} {
+		a.set(i)
+	}
+	b.set(3)
+	b.set(130)
+	var got []int
+	a.eachOr(b, func(i int) { got = append(got, i) })
+	if !reflect.DeepEqual(got, []int{0, 3, 63, 64, 130, 200}) {
+		t.Fatalf("expected '%v', got '%v'", []int{0, 3, 63, 64, 130, 200}, got)
+	}
+	got = nil
+	b.eachOr(nil, func(i int) { got = append(got, i) })
+	if !reflect.DeepEqual(got, []int{3, 130}) {
+		t.Fatalf("expected '%v', got '%v'", []int{3, 130}, got)
+	}
+}
@@ -0,0 +1,288 @@
+package server
+
+// Fleet statistics: a key that has a FLEETCONFIG keeps a summary of the
+// updates of its objects, which FLEETSTATS returns without scanning the key.
+// The updates within the window are queued in time order, and they leave
+// the window from the front of the queue. Like the history, the updates are
+// recorded with the writes that go to the AOF, so they start empty when the
+// server starts.
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tidwall/resp"
+)
+
+var errFleetNotEnabled = errors.New("fleet stats are not enabled for the key")
+
+// fleetConfig is the FLEETCONFIG of a key.
+type fleetConfig struct {
+	window time.Duration // objects updated within are active
+	speed  string        // field that has the speed, if any
+}
+
+// fleetUpdate is an update of an object within the window.
+type fleetUpdate struct {
+	id       string
+	time     int64 // unix nanoseconds
+	speed    float64
+	hasSpeed bool
+}
+
+// fleetTracker is the summary of the updates of a key.
+type fleetTracker struct {
+	conf     fleetConfig
+	mu       sync.Mutex
+	last     map[string]int64 // last update of the active ids
+	queue    []fleetUpdate    // the updates within the window, oldest first
+	speedSum float64          // of the updates in the queue that have speed
+	speedN   int
+}
+
+func newFleetTracker(conf fleetConfig) *fleetTracker {
+	return &fleetTracker{conf: conf, last: make(map[string]int64)}
+}
+
+// reset forgets all of the updates.
+func (t *fleetTracker) reset() {
+	t.last = make(map[string]int64)
+	t.queue = nil
+	t.speedSum, t.speedN = 0, 0
+}
+
+// expire removes the updates that left the window. The ids whose last
+// update left are no longer active.
+func (t *fleetTracker) expire(now int64) {
+	min := now - int64(t.conf.window)
+	var i int
+	for ; i < len(t.queue) && t.queue[i].time < min; i++ {
+		u := t.queue[i]
+		if t.last[u.id] == u.time {
+			delete(t.last, u.id)
+		}
+		if u.hasSpeed {
+			t.speedSum -= u.speed
+			t.speedN--
+		}
+	}
+	if i > 0 {
+		t.queue = append(t.queue[:0:0], t.queue[i:]...)
+	}
+}
+
+func (t *fleetTracker) update(u fleetUpdate) {
+	t.expire(u.time)
+	t.last[u.id] = u.time
+	t.queue = append(t.queue, u)
+	if u.hasSpeed {
+		t.speedSum += u.speed
+		t.speedN++
+	}
+}
+
+// cmdFleetConfig sets the window of the fleet statistics of a key, and the
+// field that has the speed of the objects. Without options the statistics
+// are removed.
+//
+//	FLEETCONFIG key [WINDOW seconds [SPEED field]]
+func (server *Server) cmdFleetConfig(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var conf fleetConfig
+	for len(vs) > 0 {
+		var arg, sval string
+		vs, arg, _ = tokenval(vs)
+		if vs, sval, ok = tokenval(vs); !ok || sval == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		switch {
+		case lc(arg, "window"):
+			secs, perr := strconv.ParseFloat(sval, 64)
+			if perr != nil || secs <= 0 {
+				err = errInvalidArgument(sval)
+				return
+			}
+			conf.window = time.Duration(secs * float64(time.Second))
+		case lc(arg, "speed"):
+			conf.speed = sval
+		default:
+			err = errInvalidArgument(arg)
+			return
+		}
+	}
+	switch {
+	case len(msg.Args) == 2:
+		delete(server.fleets, d.key)
+	case conf.window == 0:
+		err = errInvalidNumberOfArguments
+		return
+	case server.fleets[d.key] != nil:
+		t := server.fleets[d.key]
+		t.mu.Lock()
+		if t.conf.speed != conf.speed {
+			// the speeds of the queued updates are of the other field
+			t.reset()
+		}
+		t.conf = conf
+		t.expire(time.Now().UnixNano())
+		t.mu.Unlock()
+	default:
+		server.fleets[d.key] = newFleetTracker(conf)
+	}
+	d.command = "fleetconfig"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// recordFleet adds the objects that were set to the fleet statistics of
+// their key, and forgets the objects that were deleted.
+func (server *Server) recordFleet(d *commandDetails) {
+	if d.parent {
+		for _, dc := range d.children {
+			server.recordFleet(dc)
+		}
+		return
+	}
+	t := server.fleets[d.key]
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch d.command {
+	case "set", "fset":
+		u := fleetUpdate{id: d.id, time: d.timestamp.UnixNano()}
+		if t.conf.speed != "" {
+			if col := server.getCol(d.key); col != nil {
+				idx, ok := col.FieldMap()[t.conf.speed]
+				if ok && idx < len(d.fields) {
+					u.speed, u.hasSpeed = d.fields[idx], true
+				}
+			}
+		}
+		t.update(u)
+	case "del":
+		delete(t.last, d.id)
+	case "drop", "rename", "renamenx":
+		t.reset()
+	}
+}
+
+// cmdFleetStats returns the fleet statistics of a key: the number of
+// objects, the objects that were updated within the window and the ones
+// that weren't, the updates per second within the window, the average speed
+// of those updates, and the bounds of the key.
+//
+//	FLEETSTATS key
+func (server *Server) cmdFleetStats(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	t := server.fleets[key]
+	if t == nil {
+		return NOMessage, errFleetNotEnabled
+	}
+	var count int
+	var minX, minY, maxX, maxY float64
+	if col := server.getCol(key); col != nil {
+		count = col.Count()
+		minX, minY, maxX, maxY = col.Bounds()
+	}
+	t.mu.Lock()
+	t.expire(time.Now().UnixNano())
+	active := len(t.last)
+	rate := float64(len(t.queue)) / t.conf.window.Seconds()
+	var speed float64
+	if t.speedN > 0 {
+		speed = t.speedSum / float64(t.speedN)
+	}
+	t.mu.Unlock()
+	if active > count {
+		// expired objects that haven't been swept yet
+		active = count
+	}
+	stalled := count - active
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"stats":{"count":` + strconv.Itoa(count) +
+			`,"active":` + strconv.Itoa(active) +
+			`,"stalled":` + strconv.Itoa(stalled) +
+			`,"update_rate":` + strconv.FormatFloat(rate, 'f', -1, 64) +
+			`,"avg_speed":` + strconv.FormatFloat(speed, 'f', -1, 64))
+		if count > 0 {
+			buf.WriteString(`,"bounds":[` +
+				strconv.FormatFloat(minX, 'f', -1, 64) + `,` +
+				strconv.FormatFloat(minY, 'f', -1, 64) + `,` +
+				strconv.FormatFloat(maxX, 'f', -1, 64) + `,` +
+				strconv.FormatFloat(maxY, 'f', -1, 64) + `]`)
+		}
+		buf.WriteString(`},"elapsed":"` + time.Since(start).String() + `"}`)
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		vals := []resp.Value{
+			resp.StringValue("count"), resp.IntegerValue(count),
+			resp.StringValue("active"), resp.IntegerValue(active),
+			resp.StringValue("stalled"), resp.IntegerValue(stalled),
+			resp.StringValue("update_rate"),
+			resp.StringValue(strconv.FormatFloat(rate, 'f', -1, 64)),
+			resp.StringValue("avg_speed"),
+			resp.StringValue(strconv.FormatFloat(speed, 'f', -1, 64)),
+		}
+		if count > 0 {
+			vals = append(vals, resp.StringValue("bounds"),
+				resp.ArrayValue([]resp.Value{
+					resp.ArrayValue([]resp.Value{
+						resp.FloatValue(minX), resp.FloatValue(minY),
+					}),
+					resp.ArrayValue([]resp.Value{
+						resp.FloatValue(maxX), resp.FloatValue(maxY),
+					}),
+				}))
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
+
+// fleetCommands returns the commands that rebuild the FLEETCONFIG of the
+// keys, for aofshrink.
+func (server *Server) fleetCommands() [][]string {
+	keys := make([]string, 0, len(server.fleets))
+	for key := range server.fleets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmds := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		conf := server.fleets[key].conf
+		cmd := []string{"fleetconfig", key, "WINDOW",
+			strconv.FormatFloat(conf.window.Seconds(), 'f', -1, 64)}
+		if conf.speed != "" {
+			cmd = append(cmd, "SPEED", conf.speed)
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
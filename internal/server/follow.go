@@ -20,6 +20,9 @@ const checksumsz = 512 * 1024
 
 func (s *Server) cmdFollow(msg *Message) (res resp.Value, err error) {
 	start := time.Now()
+	if s.cluster != nil {
+		return NOMessage, errors.New("cannot follow: the leader is managed by cluster-discovery")
+	}
 	vs := msg.Args[1:]
 	var ok bool
 	var host, sport string
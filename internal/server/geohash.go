@@ -0,0 +1,207 @@
+package server
+
+// GEOHASH command: encode, decode, and neighbors of geohash cells.
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/resp"
+)
+
+// geohashDirections are the names of the neighbors returned by
+// geohashNeighbors, in order.
+var geohashDirections = [8]string{"n", "ne", "e", "se", "s", "sw", "w", "nw"}
+
+// parseGeohash validates and returns a lowercase geohash.
+func parseGeohash(s string) (string, bool) {
+	hash := strings.ToLower(s)
+	if len(hash) == 0 || len(hash) > 12 || geohash.Validate(hash) != nil {
+		return "", false
+	}
+	return hash, true
+}
+
+// geohashNeighbors returns the eight cells that surround a geohash cell, in
+// the order of geohashDirections. The cells wrap around the antimeridian.
+// There are no cells past the poles, so those are returned as empty strings.
+func geohashNeighbors(hash string) [8]string {
+	box := geohash.BoundingBox(hash)
+	lat, lon := box.Center()
+	dlat := box.MaxLat - box.MinLat
+	dlon := box.MaxLng - box.MinLng
+	precision := uint(len(hash))
+	offsets := [8][2]float64{
+		{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1},
+	}
+	var cells [8]string
+	for i, off := range offsets {
+		nlat := lat + off[0]*dlat
+		if nlat > 90 || nlat < -90 {
+			continue
+		}
+		nlon := lon + off[1]*dlon
+		if nlon > 180 {
+			nlon -= 360
+		} else if nlon < -180 {
+			nlon += 360
+		}
+		cells[i] = geohash.EncodeWithPrecision(nlat, nlon, precision)
+	}
+	return cells
+}
+
+// cmdGeohashEncode encodes a point into a geohash.
+//
+//	GEOHASH ENCODE lat lon [precision]
+func (server *Server) cmdGeohashEncode(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var slat, slon, sprecision string
+	if vs, slat, ok = tokenval(vs); !ok || slat == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, slon, ok = tokenval(vs); !ok || slon == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	precision := int64(12)
+	if vs, sprecision, ok = tokenval(vs); ok {
+		var err error
+		precision, err = strconv.ParseInt(sprecision, 10, 64)
+		if err != nil || precision < 1 || precision > 12 {
+			return NOMessage, errInvalidArgument(sprecision)
+		}
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	lat, err := strconv.ParseFloat(slat, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return NOMessage, errInvalidArgument(slat)
+	}
+	lon, err := strconv.ParseFloat(slon, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return NOMessage, errInvalidArgument(slon)
+	}
+	hash := geohash.EncodeWithPrecision(lat, lon, uint(precision))
+	switch msg.OutputType {
+	case JSON:
+		return resp.StringValue(`{"ok":true,"hash":"` + hash +
+			`","elapsed":"` + time.Since(start).String() + "\"}"), nil
+	case RESP:
+		return resp.StringValue(hash), nil
+	}
+	return NOMessage, nil
+}
+
+// cmdGeohashDecode decodes a geohash into the center and the bounds of its
+// cell.
+//
+//	GEOHASH DECODE hash
+func (server *Server) cmdGeohashDecode(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var shash string
+	if vs, shash, ok = tokenval(vs); !ok || shash == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	hash, ok := parseGeohash(shash)
+	if !ok {
+		return NOMessage, errInvalidArgument(shash)
+	}
+	box := geohash.BoundingBox(hash)
+	lat, lon := box.Center()
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"center":`)
+		buf.Write(appendJSONSimplePoint(nil,
+			geojson.NewPoint(geometry.Point{X: lon, Y: lat}), -1))
+		buf.WriteString(`,"bounds":`)
+		buf.Write(appendJSONSimpleBounds(nil,
+			geojson.NewRect(geometry.Rect{
+				Min: geometry.Point{X: box.MinLng, Y: box.MinLat},
+				Max: geometry.Point{X: box.MaxLng, Y: box.MaxLat},
+			}), -1))
+		buf.WriteString(`,"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		return resp.ArrayValue([]resp.Value{
+			resp.ArrayValue([]resp.Value{
+				resp.FloatValue(lat),
+				resp.FloatValue(lon),
+			}),
+			resp.ArrayValue([]resp.Value{
+				resp.ArrayValue([]resp.Value{
+					resp.FloatValue(box.MinLat),
+					resp.FloatValue(box.MinLng),
+				}),
+				resp.ArrayValue([]resp.Value{
+					resp.FloatValue(box.MaxLat),
+					resp.FloatValue(box.MaxLng),
+				}),
+			}),
+		}), nil
+	}
+	return NOMessage, nil
+}
+
+// cmdGeohashNeighbors returns the cells that surround a geohash cell.
+//
+//	GEOHASH NEIGHBORS hash
+func (server *Server) cmdGeohashNeighbors(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var shash string
+	if vs, shash, ok = tokenval(vs); !ok || shash == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	hash, ok := parseGeohash(shash)
+	if !ok {
+		return NOMessage, errInvalidArgument(shash)
+	}
+	cells := geohashNeighbors(hash)
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"neighbors":{`)
+		var i int
+		for j, cell := range cells {
+			if cell == "" {
+				continue
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(`"` + geohashDirections[j] + `":"` + cell + `"`)
+			i++
+		}
+		buf.WriteString(`},"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		vals := make([]resp.Value, 0, len(cells)*2)
+		for j, cell := range cells {
+			if cell == "" {
+				continue
+			}
+			vals = append(vals, resp.StringValue(geohashDirections[j]),
+				resp.StringValue(cell))
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
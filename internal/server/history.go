@@ -0,0 +1,231 @@
+package server
+
+// Object history: a key that has a HISTORYCONFIG keeps the last versions of
+// each object, which GET ... HISTORY returns, for breadcrumb trails that
+// don't need the timestamps of TRACK. The versions are recorded with the
+// writes that go to the AOF, so they're kept in memory only and start empty
+// when the server starts. The config itself is in the AOF.
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/resp"
+)
+
+var errHistoryNotEnabled = errors.New("history is not enabled for the key")
+
+// historyConfig is the HISTORYCONFIG of a key. Zero is no limit.
+type historyConfig struct {
+	versions int
+	age      time.Duration
+}
+
+// objectVersion is a version of an object and the time that it was set.
+type objectVersion struct {
+	time int64 // unix nanoseconds
+	obj  geojson.Object
+}
+
+// keyHistory is the history of the objects of a key.
+type keyHistory struct {
+	conf historyConfig
+	mu   sync.Mutex
+	ids  map[string][]objectVersion // oldest first
+}
+
+// trim removes the versions of an id that are over the limits.
+func (h *keyHistory) trim(id string, now int64) {
+	versions := h.ids[id]
+	var i int
+	if h.conf.versions > 0 && len(versions) > h.conf.versions {
+		i = len(versions) - h.conf.versions
+	}
+	if h.conf.age > 0 {
+		for i < len(versions) && versions[i].time < now-int64(h.conf.age) {
+			i++
+		}
+	}
+	if i == len(versions) {
+		delete(h.ids, id)
+	} else if i > 0 {
+		h.ids[id] = append(versions[:0:0], versions[i:]...)
+	}
+}
+
+// cmdHistoryConfig sets how many versions of the objects of a key are kept,
+// and for how long. Without options the history is removed.
+//
+//	HISTORYCONFIG key [VERSIONS n] [AGE seconds]
+func (server *Server) cmdHistoryConfig(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var conf historyConfig
+	for len(vs) > 0 {
+		var arg, sval string
+		vs, arg, _ = tokenval(vs)
+		if vs, sval, ok = tokenval(vs); !ok || sval == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		switch {
+		case lc(arg, "versions"):
+			n, perr := strconv.ParseUint(sval, 10, 32)
+			if perr != nil || n == 0 {
+				err = errInvalidArgument(sval)
+				return
+			}
+			conf.versions = int(n)
+		case lc(arg, "age"):
+			secs, perr := strconv.ParseFloat(sval, 64)
+			if perr != nil || secs <= 0 {
+				err = errInvalidArgument(sval)
+				return
+			}
+			conf.age = time.Duration(secs * float64(time.Second))
+		default:
+			err = errInvalidArgument(arg)
+			return
+		}
+	}
+	if len(msg.Args) == 2 {
+		delete(server.histories, d.key)
+	} else if h := server.histories[d.key]; h != nil {
+		h.mu.Lock()
+		h.conf = conf
+		now := time.Now().UnixNano()
+		for id := range h.ids {
+			h.trim(id, now)
+		}
+		h.mu.Unlock()
+	} else {
+		server.histories[d.key] = &keyHistory{conf: conf,
+			ids: make(map[string][]objectVersion)}
+	}
+	d.command = "historyconfig"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// recordHistory adds the objects that were set to the history of their key,
+// and forgets the objects that were deleted.
+func (server *Server) recordHistory(d *commandDetails) {
+	if d.parent {
+		for _, dc := range d.children {
+			server.recordHistory(dc)
+		}
+		return
+	}
+	h := server.histories[d.key]
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch d.command {
+	case "set":
+		now := d.timestamp.UnixNano()
+		h.ids[d.id] = append(h.ids[d.id], objectVersion{now, d.obj})
+		h.trim(d.id, now)
+	case "del":
+		delete(h.ids, d.id)
+	case "drop", "rename", "renamenx":
+		h.ids = make(map[string][]objectVersion)
+	}
+}
+
+// cmdGetHistory returns the versions of an object, newest first. The first
+// one is the current object.
+//
+//	GET key id HISTORY
+func (server *Server) cmdGetHistory(msg *Message, key, id string,
+	vs []string, start time.Time,
+) (resp.Value, error) {
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	h := server.histories[key]
+	if h == nil {
+		return NOMessage, errHistoryNotEnabled
+	}
+	rule, err := server.redaction(msg, key)
+	if err != nil {
+		return NOMessage, err
+	}
+	h.mu.Lock()
+	h.trim(id, time.Now().UnixNano())
+	versions := make([]objectVersion, 0, len(h.ids[id]))
+	for i := len(h.ids[id]) - 1; i >= 0; i-- {
+		versions = append(versions, h.ids[id][i])
+	}
+	h.mu.Unlock()
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"history":[`)
+		for i, v := range versions {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			obj := v.obj
+			if rule != nil {
+				obj = rule.object(obj)
+			}
+			buf.WriteString(`{"time":` + formatUnixTime(v.time) +
+				`,"object":` + string(obj.AppendJSON(nil)) + `}`)
+		}
+		buf.WriteString(`],"elapsed":"` + time.Since(start).String() + `"}`)
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		vals := make([]resp.Value, len(versions))
+		for i, v := range versions {
+			obj := v.obj
+			if rule != nil {
+				obj = rule.object(obj)
+			}
+			vals[i] = resp.ArrayValue([]resp.Value{
+				resp.StringValue(formatUnixTime(v.time)),
+				resp.StringValue(obj.String()),
+			})
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
+
+// historyCommands returns the commands that rebuild the HISTORYCONFIG of the
+// keys, for aofshrink.
+func (server *Server) historyCommands() [][]string {
+	keys := make([]string, 0, len(server.histories))
+	for key := range server.histories {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmds := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		conf := server.histories[key].conf
+		cmd := []string{"historyconfig", key}
+		if conf.versions > 0 {
+			cmd = append(cmd, "VERSIONS", strconv.Itoa(conf.versions))
+		}
+		if conf.age > 0 {
+			cmd = append(cmd, "AGE",
+				strconv.FormatFloat(conf.age.Seconds(), 'f', -1, 64))
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
@@ -0,0 +1,215 @@
+package server
+
+// Hook export and import: HOOKEXPORT writes the hooks and channels as a JSON
+// document, and HOOKIMPORT sets them on another server, for blue/green
+// deployments and disaster recovery. Each hook is imported with its own
+// SETHOOK or SETCHAN, which is what's written to the AOF.
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/glob"
+)
+
+// hookDef is the definition of a hook or a channel.
+type hookDef struct {
+	Name      string            `json:"name"`
+	Channel   bool              `json:"channel,omitempty"`
+	Endpoints []string          `json:"endpoints,omitempty"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	Ex        float64           `json:"ex,omitempty"`        // seconds left
+	Inactive  float64           `json:"inactive,omitempty"`  // seconds
+	Occupancy float64           `json:"occupancy,omitempty"` // minutes
+	Threshold []string          `json:"threshold,omitempty"` // field above below
+	Command   []string          `json:"command"`
+}
+
+// hookDefOf returns the definition of a hook. The hook must be locked.
+func hookDefOf(hook *Hook) hookDef {
+	def := hookDef{
+		Name:     hook.Name,
+		Channel:  hook.channel,
+		Inactive: hook.inactive.Seconds(),
+		Command:  append([]string(nil), hook.Message.Args...),
+	}
+	if hook.occupancy != nil {
+		def.Occupancy = hook.occupancy.window.Minutes()
+	}
+	if hook.threshold != nil {
+		def.Threshold = hook.threshold.args()[1:]
+	}
+	if !hook.channel {
+		def.Endpoints = append([]string(nil), hook.Endpoints...)
+	}
+	if len(hook.Metas) > 0 {
+		def.Meta = make(map[string]string, len(hook.Metas))
+		for _, meta := range hook.Metas {
+			def.Meta[meta.Name] = meta.Value
+		}
+	}
+	if !hook.expires.IsZero() {
+		// to the tenth of a second, and not zero, which doesn't expire
+		def.Ex = math.Max(math.Round(time.Until(hook.expires).Seconds()*10)/10,
+			0.1)
+	}
+	return def
+}
+
+// args returns the SETHOOK or SETCHAN command of the definition.
+func (def hookDef) args() []string {
+	var args []string
+	if def.Channel {
+		args = append(args, "setchan", def.Name)
+	} else {
+		args = append(args, "sethook", def.Name,
+			strings.Join(def.Endpoints, ","))
+	}
+	names := make([]string, 0, len(def.Meta))
+	for name := range def.Meta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "meta", name, def.Meta[name])
+	}
+	if def.Ex > 0 {
+		args = append(args, "ex", strconv.FormatFloat(def.Ex, 'f', -1, 64))
+	}
+	if def.Inactive > 0 {
+		args = append(args, "inactive",
+			strconv.FormatFloat(def.Inactive, 'f', -1, 64))
+	}
+	if def.Occupancy > 0 {
+		args = append(args, "occupancy",
+			strconv.FormatFloat(def.Occupancy, 'f', -1, 64))
+	}
+	if len(def.Threshold) > 0 {
+		args = append(append(args, "threshold"), def.Threshold...)
+	}
+	return append(args, def.Command...)
+}
+
+// cmdHookExport returns the hooks and channels that match a pattern, all of
+// them by default, as a JSON document for HOOKIMPORT.
+//
+//	HOOKEXPORT [pattern]
+func (s *Server) cmdHookExport(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	pattern := "*"
+	var ok bool
+	if len(vs) > 0 {
+		if vs, pattern, ok = tokenval(vs); !ok || pattern == "" {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var hooks []*Hook
+	for name, hook := range s.hooks {
+		if match, _ := glob.Match(pattern, name); match {
+			hooks = append(hooks, hook)
+		}
+	}
+	sort.Sort(hooksByName(hooks))
+	defs := make([]hookDef, len(hooks))
+	for i, hook := range hooks {
+		hook.cond.L.Lock()
+		defs[i] = hookDefOf(hook)
+		hook.cond.L.Unlock()
+	}
+	data, err := json.Marshal(defs)
+	if err != nil {
+		return NOMessage, err
+	}
+	switch msg.OutputType {
+	case JSON:
+		return resp.StringValue(`{"ok":true,"hooks":` + string(data) +
+			`,"elapsed":"` + time.Since(start).String() + "\"}"), nil
+	case RESP:
+		return resp.StringValue(`{"hooks":` + string(data) + `}`), nil
+	}
+	return NOMessage, nil
+}
+
+// cmdHookImport sets the hooks and channels of a HOOKEXPORT document, and
+// returns how many were set. A hook that's already on the server fails the
+// import before anything is set, unless SKIP leaves it as it is, or REPLACE
+// sets it again.
+//
+//	HOOKIMPORT document [FAIL|SKIP|REPLACE]
+func (s *Server) cmdHookImport(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var doc, mode string
+	if vs, doc, ok = tokenval(vs); !ok || doc == "" {
+		return NOMessage, d, errInvalidNumberOfArguments
+	}
+	mode = "fail"
+	if len(vs) > 0 {
+		vs, mode, _ = tokenval(vs)
+		mode = strings.ToLower(mode)
+		switch mode {
+		case "fail", "skip", "replace":
+		default:
+			return NOMessage, d, errInvalidArgument(mode)
+		}
+	}
+	if len(vs) != 0 {
+		return NOMessage, d, errInvalidNumberOfArguments
+	}
+	var v struct {
+		Hooks []hookDef `json:"hooks"`
+	}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		return NOMessage, d, errInvalidArgument(doc)
+	}
+	names := make(map[string]bool, len(v.Hooks))
+	for _, def := range v.Hooks {
+		if def.Name == "" || len(def.Command) == 0 ||
+			(!def.Channel && len(def.Endpoints) == 0) {
+			return NOMessage, d, errors.New("invalid hook definition")
+		}
+		if names[def.Name] {
+			return NOMessage, d, errors.New("duplicate hook '" + def.Name + "'")
+		}
+		names[def.Name] = true
+		if mode == "fail" && s.hooks[def.Name] != nil {
+			return NOMessage, d, errors.New("hook '" + def.Name +
+				"' already exists")
+		}
+	}
+	var n int
+	for _, def := range v.Hooks {
+		if mode == "skip" && s.hooks[def.Name] != nil {
+			continue
+		}
+		hmsg := &Message{Args: def.args()}
+		_, hd, err := s.cmdSetHook(hmsg, def.Channel)
+		if err != nil {
+			return NOMessage, d, errors.New(def.Name + ": " + err.Error())
+		}
+		if err := s.writeAOF(hmsg.Args, &hd); err != nil {
+			return NOMessage, d, err
+		}
+		n++
+	}
+	d.timestamp = time.Now() // nothing for the aof, each hook is written
+	switch msg.OutputType {
+	case JSON:
+		return resp.StringValue(`{"ok":true,"imported":` + strconv.Itoa(n) +
+			`,"elapsed":"` + time.Since(start).String() + "\"}"), d, nil
+	case RESP:
+		return resp.IntegerValue(n), d, nil
+	}
+	return NOMessage, d, nil
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"sort"
 	"strconv"
@@ -68,6 +69,9 @@ func (s *Server) cmdSetHook(msg *Message, chanCmd bool) (
 	var types []string
 	var expires float64
 	var expiresSet bool
+	var inactive time.Duration
+	var occupancyWindow time.Duration
+	var threshold *thresholdFence
 	metaMap := make(map[string]string)
 	for {
 		commandvs = vs
@@ -101,6 +105,50 @@ func (s *Server) cmdSetHook(msg *Message, chanCmd bool) (
 			expires = v
 			expiresSet = true
 			continue
+		case "inactive":
+			var s string
+			if vs, s, ok = tokenval(vs); !ok || s == "" {
+				return NOMessage, d, errInvalidNumberOfArguments
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil || v <= 0 {
+				return NOMessage, d, errInvalidArgument(s)
+			}
+			inactive = time.Duration(v * float64(time.Second))
+			continue
+		case "occupancy":
+			var s string
+			if vs, s, ok = tokenval(vs); !ok || s == "" {
+				return NOMessage, d, errInvalidNumberOfArguments
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil || v <= 0 {
+				return NOMessage, d, errInvalidArgument(s)
+			}
+			occupancyWindow = time.Duration(v * float64(time.Minute))
+			continue
+		case "threshold":
+			var field, sabove, sbelow string
+			if vs, field, ok = tokenval(vs); !ok || field == "" {
+				return NOMessage, d, errInvalidNumberOfArguments
+			}
+			if vs, sabove, ok = tokenval(vs); !ok || sabove == "" {
+				return NOMessage, d, errInvalidNumberOfArguments
+			}
+			if vs, sbelow, ok = tokenval(vs); !ok || sbelow == "" {
+				return NOMessage, d, errInvalidNumberOfArguments
+			}
+			above, err := strconv.ParseFloat(sabove, 64)
+			if err != nil {
+				return NOMessage, d, errInvalidArgument(sabove)
+			}
+			below, err := strconv.ParseFloat(sbelow, 64)
+			if err != nil || below > above {
+				return NOMessage, d, errInvalidArgument(sbelow)
+			}
+			threshold = &thresholdFence{field: field, above: above,
+				below: below}
+			continue
 		case "nearby":
 			types = nearbyTypes
 		case "within", "intersects":
@@ -118,6 +166,18 @@ func (s *Server) cmdSetHook(msg *Message, chanCmd bool) (
 	if !args.fence {
 		return NOMessage, d, errors.New("missing FENCE argument")
 	}
+	if inactive > 0 && args.roam.on {
+		return NOMessage, d, errors.New("INACTIVE is not allowed with ROAM")
+	}
+	if occupancyWindow > 0 && args.roam.on {
+		return NOMessage, d, errors.New("OCCUPANCY is not allowed with ROAM")
+	}
+	if threshold != nil && args.roam.on {
+		return NOMessage, d, errors.New("THRESHOLD is not allowed with ROAM")
+	}
+	if threshold == nil && (args.detect["rising"] || args.detect["falling"]) {
+		return NOMessage, d, errors.New("missing THRESHOLD argument")
+	}
 	args.cmd = cmdlc
 	cmsg := &Message{}
 	*cmsg = *msg
@@ -143,6 +203,17 @@ func (s *Server) cmdSetHook(msg *Message, chanCmd bool) (
 		cond:      sync.NewCond(&sync.Mutex{}),
 		counter:   &s.statsTotalMsgsSent,
 	}
+	if inactive > 0 {
+		hook.inactive = inactive
+		hook.inactives = make(map[string]*inactiveItem)
+	}
+	if occupancyWindow > 0 {
+		hook.occupancy = newOccupancy(occupancyWindow)
+	}
+	if threshold != nil {
+		threshold.high = make(map[string]bool)
+		hook.threshold = threshold
+	}
 	if expiresSet {
 		hook.expires =
 			time.Now().Add(time.Duration(expires * float64(time.Second)))
@@ -154,7 +225,7 @@ func (s *Server) cmdSetHook(msg *Message, chanCmd bool) (
 	hook.ScanWriter, err = s.newScanWriter(
 		&wr, cmsg, args.key, args.output, args.precision, args.glob, false,
 		args.cursor, args.limit, args.wheres, args.whereins, args.whereevals,
-		args.nofields)
+		args.nofields, args.selected, args.reshape, args.crs)
 	if err != nil {
 
 		return NOMessage, d, err
@@ -181,45 +252,27 @@ func (s *Server) cmdSetHook(msg *Message, chanCmd bool) (
 		}
 		prevHook.Close()
 		delete(s.hooks, name)
-		delete(s.hooksOut, name)
+		s.hookIndex.delete(prevHook)
 		s.groupDisconnectHook(name)
+		hook.seq = prevHook.seq
+	} else {
+		hook.seq = s.hookSeq(name)
 	}
 
 	d.updated = true
 	d.timestamp = time.Now()
 
 	s.hooks[name] = hook
-	if hook.Fence.detect == nil || hook.Fence.detect["outside"] {
-		s.hooksOut[name] = hook
-	}
-
-	// remove previous hook from spatial index
-	if prevHook != nil && prevHook.Fence != nil && prevHook.Fence.obj != nil {
-		rect := prevHook.Fence.obj.Rect()
-		s.hookTree.Delete(
-			[2]float64{rect.Min.X, rect.Min.Y},
-			[2]float64{rect.Max.X, rect.Max.Y},
-			prevHook)
-		if prevHook.Fence.detect["cross"] {
-			s.hookCross.Delete(
-				[2]float64{rect.Min.X, rect.Min.Y},
-				[2]float64{rect.Max.X, rect.Max.Y},
-				prevHook)
-		}
+	s.hookIndex.insert(hook)
+
+	if hook.inactive > 0 {
+		s.seedInactive(hook)
 	}
-	// add hook to spatial index
-	if hook != nil && hook.Fence != nil && hook.Fence.obj != nil {
-		rect := hook.Fence.obj.Rect()
-		s.hookTree.Insert(
-			[2]float64{rect.Min.X, rect.Min.Y},
-			[2]float64{rect.Max.X, rect.Max.Y},
-			hook)
-		if hook.Fence.detect["cross"] {
-			s.hookCross.Insert(
-				[2]float64{rect.Min.X, rect.Min.Y},
-				[2]float64{rect.Max.X, rect.Max.Y},
-				hook)
-		}
+	if hook.occupancy != nil {
+		s.seedOccupancy(hook)
+	}
+	if hook.threshold != nil {
+		s.seedThreshold(hook)
 	}
 
 	hook.Open() // Opens a goroutine to notify the hook
@@ -253,23 +306,9 @@ func (s *Server) cmdDelHook(msg *Message, chanCmd bool) (
 		hook.Close()
 		// remove hook from maps
 		delete(s.hooks, hook.Name)
-		delete(s.hooksOut, hook.Name)
+		s.hookIndex.delete(hook)
 		// remove any hook / object connections
 		s.groupDisconnectHook(hook.Name)
-		// remove hook from spatial index
-		if hook.Fence != nil && hook.Fence.obj != nil {
-			rect := hook.Fence.obj.Rect()
-			s.hookTree.Delete(
-				[2]float64{rect.Min.X, rect.Min.Y},
-				[2]float64{rect.Max.X, rect.Max.Y},
-				hook)
-			if hook.Fence.detect["cross"] {
-				s.hookCross.Delete(
-					[2]float64{rect.Min.X, rect.Min.Y},
-					[2]float64{rect.Max.X, rect.Max.Y},
-					hook)
-			}
-		}
 		d.updated = true
 	}
 	d.timestamp = time.Now()
@@ -313,23 +352,9 @@ func (s *Server) cmdPDelHook(msg *Message, channel bool) (
 		hook.Close()
 		// remove hook from maps
 		delete(s.hooks, hook.Name)
-		delete(s.hooksOut, hook.Name)
+		s.hookIndex.delete(hook)
 		// remove any hook / object connections
 		s.groupDisconnectHook(hook.Name)
-		// remove hook from spatial index
-		if hook.Fence != nil && hook.Fence.obj != nil {
-			rect := hook.Fence.obj.Rect()
-			s.hookTree.Delete(
-				[2]float64{rect.Min.X, rect.Min.Y},
-				[2]float64{rect.Max.X, rect.Max.Y},
-				hook)
-			if hook.Fence.detect["cross"] {
-				s.hookCross.Delete(
-					[2]float64{rect.Min.X, rect.Min.Y},
-					[2]float64{rect.Max.X, rect.Max.Y},
-					hook)
-			}
-		}
 		d.updated = true
 		count++
 	}
@@ -415,8 +440,8 @@ func (s *Server) cmdHooks(msg *Message, channel bool) (
 				buf.WriteByte(',')
 			}
 			buf.WriteString(`{`)
-			buf.WriteString(`"name":` + jsonString(hook.Name))
-			buf.WriteString(`,"key":` + jsonString(hook.Key))
+			buf.WriteString(`"name":` + jsonString(msg.nsLocal(hook.Name)))
+			buf.WriteString(`,"key":` + jsonString(msg.nsLocal(hook.Key)))
 			if !channel {
 				buf.WriteString(`,"endpoints":[`)
 				for i, endpoint := range hook.Endpoints {
@@ -432,7 +457,7 @@ func (s *Server) cmdHooks(msg *Message, channel bool) (
 				if i > 0 {
 					buf.WriteString(`,`)
 				}
-				buf.WriteString(jsonString(v))
+				buf.WriteString(jsonString(msg.nsLocal(v)))
 			}
 			buf.WriteString(`],"meta":{`)
 			for i, meta := range hook.Metas {
@@ -452,8 +477,8 @@ func (s *Server) cmdHooks(msg *Message, channel bool) (
 		var vals []resp.Value
 		for _, hook := range hooks {
 			var hvals []resp.Value
-			hvals = append(hvals, resp.StringValue(hook.Name))
-			hvals = append(hvals, resp.StringValue(hook.Key))
+			hvals = append(hvals, resp.StringValue(msg.nsLocal(hook.Name)))
+			hvals = append(hvals, resp.StringValue(msg.nsLocal(hook.Key)))
 			var evals []resp.Value
 			for _, endpoint := range hook.Endpoints {
 				evals = append(evals, resp.StringValue(endpoint))
@@ -461,7 +486,7 @@ func (s *Server) cmdHooks(msg *Message, channel bool) (
 			hvals = append(hvals, resp.ArrayValue(evals))
 			avals := make([]resp.Value, len(hook.Message.Args))
 			for i := 0; i < len(hook.Message.Args); i++ {
-				avals[i] = resp.StringValue(hook.Message.Args[i])
+				avals[i] = resp.StringValue(msg.nsLocal(hook.Message.Args[i]))
 			}
 			hvals = append(hvals, resp.ArrayValue(avals))
 			var metas []resp.Value
@@ -493,9 +518,17 @@ type Hook struct {
 	opened     bool
 	query      string
 	epm        *endpoint.Manager
+	ctx        context.Context    // canceled when the hook closes
+	cancel     context.CancelFunc // cancels in-flight endpoint sends
 	expires    time.Time
 	counter    *aint // counter that grows when a message was sent
 	sig        int
+
+	inactive  time.Duration            // INACTIVE timeout, see inactive.go
+	inactives map[string]*inactiveItem // watched objects by id
+	seq       uint64                   // of the last event, see hookseq.go
+	occupancy *occupancy               // OCCUPANCY counts, see occupancy.go
+	threshold *thresholdFence          // THRESHOLD, see threshold.go
 }
 
 // Expires returns when the hook expires. Required by the expire.Item interface.
@@ -511,7 +544,14 @@ func (h *Hook) Equals(hook *Hook) bool {
 		len(h.Metas) != len(hook.Metas) {
 		return false
 	}
-	if !h.expires.Equal(hook.expires) {
+	if !h.expires.Equal(hook.expires) || h.inactive != hook.inactive {
+		return false
+	}
+	if (h.occupancy == nil) != (hook.occupancy == nil) ||
+		(h.occupancy != nil && h.occupancy.window != hook.occupancy.window) {
+		return false
+	}
+	if !h.threshold.equals(hook.threshold) {
 		return false
 	}
 	for i, endpoint := range h.Endpoints {
@@ -569,6 +609,7 @@ func (h *Hook) Open() {
 	}
 	h.opened = true
 	h.query = `{"hook":` + jsonString(h.Name) + `}`
+	h.ctx, h.cancel = context.WithCancel(context.Background())
 	go h.manager()
 }
 
@@ -584,6 +625,9 @@ func (h *Hook) Close() {
 		return
 	}
 	h.closed = true
+	if h.cancel != nil {
+		h.cancel()
+	}
 	h.cond.Broadcast()
 }
 
@@ -686,7 +730,7 @@ func (h *Hook) proc() (ok bool) {
 		idx := stringToUint64(key[len(hookLogPrefix):])
 		var sent bool
 		for _, endpoint := range h.Endpoints {
-			err := h.epm.Send(endpoint, val)
+			err := h.epm.SendContext(h.ctx, endpoint, val)
 			if err != nil {
 				log.Debugf("Endpoint connect/send error: %v: %v: %v",
 					idx, endpoint, err)
@@ -0,0 +1,240 @@
+package server
+
+// Event sequences: each hook and channel numbers its events, and the "seq"
+// of an event is one more than the one of the previous event of the hook.
+// The sequences are kept in the queue database, so that they continue after
+// a restart and when a hook is set again with the same name. They're counted
+// in memory and saved with the next webhook messages that are queued, once a
+// second, and on shutdown, rather than on every event. A crash may lose the
+// sequences of the last second of channel events, which then continue from
+// the saved ones. The consumers
+// of a channel commit the last event that they've handled with CHANACK, so
+// that after a reconnect they can tell the events that they've missed from
+// the ones that they've already handled.
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/buntdb"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+const (
+	hookSeqPrefix = "hook:seq:" // last sequence by hook name
+	hookAckPrefix = "hook:ack:" // acked sequence by channel and consumer
+)
+
+// hookSeq returns the last sequence of a hook name.
+func (s *Server) hookSeq(name string) uint64 {
+	s.hookSeqMu.Lock()
+	seq, ok := s.hookSeqs[name]
+	s.hookSeqMu.Unlock()
+	if ok {
+		return seq
+	}
+	s.qdb.View(func(tx *buntdb.Tx) error {
+		if val, err := tx.Get(hookSeqPrefix + name); err == nil {
+			seq = stringToUint64(val)
+		}
+		return nil
+	})
+	return seq
+}
+
+// sequenceMessages numbers the json messages of a hook.
+func sequenceMessages(hook *Hook, msgs []string) []string {
+	for i, msg := range msgs {
+		if len(msg) < 2 || msg[0] != '{' || msg[len(msg)-1] != '}' {
+			continue
+		}
+		hook.seq++
+		msgs[i] = msg[:len(msg)-1] + `,"seq":` +
+			strconv.FormatUint(hook.seq, 10) + "}"
+	}
+	return msgs
+}
+
+// markHookSeqs keeps the last sequences of hooks until they're saved.
+func (s *Server) markHookSeqs(hooks ...*Hook) {
+	s.hookSeqMu.Lock()
+	defer s.hookSeqMu.Unlock()
+	if s.hookSeqs == nil {
+		s.hookSeqs = make(map[string]uint64)
+	}
+	for _, hook := range hooks {
+		s.hookSeqs[hook.Name] = hook.seq
+	}
+}
+
+// takeHookSeqs returns the sequences that haven't been saved yet.
+func (s *Server) takeHookSeqs() map[string]uint64 {
+	s.hookSeqMu.Lock()
+	defer s.hookSeqMu.Unlock()
+	seqs := s.hookSeqs
+	s.hookSeqs = nil
+	return seqs
+}
+
+// restoreHookSeqs puts back the sequences that failed to save, unless newer
+// ones have been marked since.
+func (s *Server) restoreHookSeqs(seqs map[string]uint64) {
+	s.hookSeqMu.Lock()
+	defer s.hookSeqMu.Unlock()
+	if s.hookSeqs == nil {
+		s.hookSeqs = make(map[string]uint64)
+	}
+	for name, seq := range seqs {
+		if seq > s.hookSeqs[name] {
+			s.hookSeqs[name] = seq
+		}
+	}
+}
+
+// setHookSeqs writes sequences in a queue database transaction.
+func setHookSeqs(tx *buntdb.Tx, seqs map[string]uint64) error {
+	for name, seq := range seqs {
+		if _, _, err := tx.Set(hookSeqPrefix+name, uint64ToString(seq),
+			nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveHookSeqs stores the sequences that haven't been saved yet.
+func (s *Server) saveHookSeqs() error {
+	seqs := s.takeHookSeqs()
+	if len(seqs) == 0 {
+		return nil
+	}
+	err := s.qdb.Update(func(tx *buntdb.Tx) error {
+		return setHookSeqs(tx, seqs)
+	})
+	if err != nil {
+		s.restoreHookSeqs(seqs)
+	}
+	return err
+}
+
+// backgroundHookSeqs saves the sequences once a second.
+func (s *Server) backgroundHookSeqs() {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for range t.C {
+		if s.stopServer.on() {
+			return
+		}
+		if err := s.saveHookSeqs(); err != nil {
+			log.Errorf("hook sequences: %v", err)
+		}
+	}
+}
+
+func hookAckKey(channel, consumer string) string {
+	return hookAckPrefix + channel + "\x00" + consumer
+}
+
+// cmdChanAck commits the last event of a channel that a consumer has
+// handled. It returns 1 when the position of the consumer moved forward.
+//
+//	CHANACK channel consumer seq
+func (s *Server) cmdChanAck(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	if len(vs) != 3 || vs[0] == "" || vs[1] == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	channel, consumer := vs[0], vs[1]
+	hook := s.hooks[channel]
+	if hook == nil || !hook.channel {
+		return NOMessage, errors.New("unknown channel")
+	}
+	seq, err := strconv.ParseUint(vs[2], 10, 64)
+	if err != nil || seq > hook.seq {
+		return NOMessage, errInvalidArgument(vs[2])
+	}
+	var moved bool
+	err = s.qdb.Update(func(tx *buntdb.Tx) error {
+		key := hookAckKey(channel, consumer)
+		if val, err := tx.Get(key); err == nil && stringToUint64(val) >= seq {
+			return nil
+		}
+		moved = true
+		_, _, err := tx.Set(key, uint64ToString(seq), nil)
+		return err
+	})
+	if err != nil {
+		return NOMessage, err
+	}
+	switch msg.OutputType {
+	case JSON:
+		res = OKMessage(msg, start)
+	case RESP:
+		if moved {
+			res = resp.IntegerValue(1)
+		} else {
+			res = resp.IntegerValue(0)
+		}
+	}
+	return res, nil
+}
+
+// cmdChanAcks returns the last sequence of a channel, and the events that
+// its consumers have acked.
+//
+//	CHANACKS channel
+func (s *Server) cmdChanAcks(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	if len(vs) != 1 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	channel := vs[0]
+	hook := s.hooks[channel]
+	if hook == nil || !hook.channel {
+		return NOMessage, errors.New("unknown channel")
+	}
+	var consumers []string
+	var seqs []uint64
+	prefix := hookAckKey(channel, "")
+	s.qdb.View(func(tx *buntdb.Tx) error {
+		return tx.AscendGreaterOrEqual("", prefix, func(key, val string) bool {
+			if !strings.HasPrefix(key, prefix) {
+				return false
+			}
+			consumers = append(consumers, key[len(prefix):])
+			seqs = append(seqs, stringToUint64(val))
+			return true
+		})
+	})
+	switch msg.OutputType {
+	case JSON:
+		buf := []byte(`{"ok":true,"seq":` + strconv.FormatUint(hook.seq, 10) +
+			`,"consumers":[`)
+		for i, consumer := range consumers {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(append(buf, `{"consumer":`...), consumer)
+			buf = append(buf, `,"seq":`+strconv.FormatUint(seqs[i], 10)+`}`...)
+		}
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+`"}`...)
+		res = resp.StringValue(string(buf))
+	case RESP:
+		vals := []resp.Value{}
+		for i, consumer := range consumers {
+			vals = append(vals, resp.ArrayValue([]resp.Value{
+				resp.StringValue(consumer),
+				resp.IntegerValue(int(seqs[i])),
+			}))
+		}
+		res = resp.ArrayValue([]resp.Value{
+			resp.IntegerValue(int(hook.seq)), resp.ArrayValue(vals),
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,186 @@
+package server
+
+// Hot keys: the keys and the objects that the commands use are counted by a
+// sketch, which keeps the most used ones, so that HOTKEYS and the prometheus
+// metrics can point at the polygon that a misconfigured client hammers. The
+// counts are halved every minute, so they follow the recent load. An object
+// is used by the commands on its id, and by the searches that have it as
+// their GET area.
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/sketch"
+)
+
+const (
+	hotKeysTop   = 32 // keys and objects that are kept
+	hotKeysDecay = time.Minute
+)
+
+// hotKeys are the sketches of the keys and the objects. The object names
+// are the key and the id, with a zero byte between them.
+type hotKeys struct {
+	mu      sync.Mutex
+	keys    *sketch.TopK
+	objects *sketch.TopK
+	decayed time.Time
+}
+
+// hotObjectOp returns true for the commands that have the id of an object
+// after the key.
+func hotObjectOp(cmd string) bool {
+	switch cmd {
+	case "get", "set", "del", "fset", "jget", "jset", "jdel", "expire",
+		"persist", "ttl", "pttl", "touch", "pexpire", "expireat", "pexpireat",
+		"jincrby", "jdecrby", "jappend", "jinsert", "fincrby", "fdecrby", "fmin",
+		"fmax", "tag", "untag", "tags",
+		"setmeta", "getmeta", "undelete", "sfset", "sfget":
+		return true
+	}
+	return false
+}
+
+// record counts the keys and the objects of a command.
+func (h *hotKeys) record(msg *Message) {
+	cmd := msg.Command()
+	op := keyOp(cmd)
+	if op == "" || len(msg.Args) < 2 {
+		return
+	}
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.keys == nil {
+		h.keys = sketch.New(hotKeysTop)
+		h.objects = sketch.New(hotKeysTop)
+		h.decayed = now
+	}
+	if now.Sub(h.decayed) >= hotKeysDecay {
+		h.keys.Decay()
+		h.objects.Decay()
+		h.decayed = now
+	}
+	h.keys.Add(msg.Args[1])
+	if hotObjectOp(cmd) && len(msg.Args) > 2 {
+		h.objects.Add(msg.Args[1] + "\x00" + msg.Args[2])
+	}
+	if op == "search" {
+		for i := 2; i < len(msg.Args)-2; i++ {
+			if lc(msg.Args[i], "get") {
+				h.objects.Add(msg.Args[i+1] + "\x00" + msg.Args[i+2])
+				break
+			}
+		}
+	}
+}
+
+// top returns the most used keys or objects.
+func (h *hotKeys) top(objects bool) []sketch.Item {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.keys == nil {
+		return nil
+	}
+	if objects {
+		return h.objects.Top()
+	}
+	return h.keys.Top()
+}
+
+func (h *hotKeys) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.keys, h.objects = nil, nil
+}
+
+// splitHotObject returns the key and the id of an object name.
+func splitHotObject(name string) (key, id string) {
+	i := strings.IndexByte(name, 0)
+	return name[:i], name[i+1:]
+}
+
+// cmdHotKeys returns the most used keys or objects, the most used first.
+//
+//	HOTKEYS [OBJECTS] [COUNT n]
+//	HOTKEYS RESET
+func (server *Server) cmdHotKeys(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	if len(vs) == 1 && lc(vs[0], "reset") {
+		server.hotKeys.reset()
+		return OKMessage(msg, start), nil
+	}
+	var objects bool
+	count := 10
+	for len(vs) > 0 {
+		var arg string
+		vs, arg, _ = tokenval(vs)
+		switch {
+		case lc(arg, "objects"):
+			objects = true
+		case lc(arg, "count"):
+			var scount string
+			var ok bool
+			if vs, scount, ok = tokenval(vs); !ok || scount == "" {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+			n, err := strconv.Atoi(scount)
+			if err != nil || n <= 0 {
+				return NOMessage, errInvalidArgument(scount)
+			}
+			count = n
+		default:
+			return NOMessage, errInvalidArgument(arg)
+		}
+	}
+	items := server.hotKeys.top(objects)
+	if len(items) > count {
+		items = items[:count]
+	}
+	var ms []map[string]interface{}
+	vals := []resp.Value{}
+	for _, item := range items {
+		if objects {
+			key, id := splitHotObject(item.Name)
+			ms = append(ms, map[string]interface{}{
+				"key": key, "id": id, "hits": item.Count,
+			})
+			vals = append(vals, resp.ArrayValue([]resp.Value{
+				resp.StringValue(key), resp.StringValue(id),
+				resp.IntegerValue(int(item.Count)),
+			}))
+		} else {
+			ms = append(ms, map[string]interface{}{
+				"key": item.Name, "hits": item.Count,
+			})
+			vals = append(vals, resp.ArrayValue([]resp.Value{
+				resp.StringValue(item.Name), resp.IntegerValue(int(item.Count)),
+			}))
+		}
+	}
+	switch msg.OutputType {
+	case JSON:
+		if ms == nil {
+			ms = []map[string]interface{}{}
+		}
+		data, err := json.Marshal(ms)
+		if err != nil {
+			return NOMessage, err
+		}
+		name := "keys"
+		if objects {
+			name = "objects"
+		}
+		res = resp.StringValue(`{"ok":true,"` + name + `":` + string(data) +
+			`,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.ArrayValue(vals)
+	}
+	return res, nil
+}
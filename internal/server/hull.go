@@ -0,0 +1,166 @@
+package server
+
+// HULL output: the convex hull, or with CONCAVE a concave hull, of the
+// matching objects of a search, which outlines the area that they cover.
+// The positions of the objects are gathered while searching and the hull is
+// written at the end, like CLUSTER.
+
+import (
+	"strconv"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geo"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/hull"
+)
+
+// hullCompact is the number of positions gathered for a convex hull before
+// they're replaced by their hull, which keeps the memory down.
+const hullCompact = 4096
+
+// hullSteps is the largest number of positions that are added along a
+// segment of an object for a concave hull.
+const hullSteps = 64
+
+// appendHullPoints appends the positions of an object to dst. Polygons
+// have the positions of their exterior rings, and the other objects that
+// aren't points have the corners of their bounds. With meters, positions
+// are added along the segments that are longer, so that a concave hull
+// doesn't cut across the object.
+func appendHullPoints(dst []geometry.Point, o geojson.Object, meters float64,
+) []geometry.Point {
+	switch o := o.(type) {
+	case *geojson.Point:
+		return append(dst, o.Base())
+	case *geojson.SimplePoint:
+		return append(dst, o.Base())
+	case *geojson.LineString:
+		line := o.Base()
+		points := make([]geometry.Point, line.NumPoints())
+		for i := range points {
+			points[i] = line.PointAt(i)
+		}
+		return appendHullLine(dst, points, meters)
+	case *geojson.Polygon:
+		ring := o.Base().Exterior
+		points := make([]geometry.Point, ring.NumPoints())
+		for i := range points {
+			points[i] = ring.PointAt(i)
+		}
+		return appendHullLine(dst, points, meters)
+	case *geojson.Feature:
+		return appendHullPoints(dst, o.Base(), meters)
+	case *geojson.Circle:
+		return appendHullPoints(dst, o.Primative(), meters)
+	case interface{ Children() []geojson.Object }:
+		for _, child := range o.Children() {
+			dst = appendHullPoints(dst, child, meters)
+		}
+		return dst
+	}
+	if !objIsSpatial(o) {
+		return dst
+	}
+	rect := o.Rect()
+	return appendHullLine(dst, []geometry.Point{rect.Min,
+		{X: rect.Max.X, Y: rect.Min.Y}, rect.Max,
+		{X: rect.Min.X, Y: rect.Max.Y}, rect.Min}, meters)
+}
+
+// appendHullLine appends the positions of a line, and with meters the
+// positions along its segments.
+func appendHullLine(dst, points []geometry.Point, meters float64,
+) []geometry.Point {
+	for i, p := range points {
+		if i > 0 && meters > 0 {
+			a := points[i-1]
+			n := int(geo.DistanceTo(a.Y, a.X, p.Y, p.X) / meters)
+			if n > hullSteps {
+				n = hullSteps
+			}
+			for j := 1; j <= n; j++ {
+				t := float64(j) / float64(n+1)
+				dst = append(dst, geometry.Point{
+					X: a.X + (p.X-a.X)*t, Y: a.Y + (p.Y-a.Y)*t,
+				})
+			}
+		}
+		dst = append(dst, p)
+	}
+	return dst
+}
+
+// addHullPoints adds the positions of a matching object to the hull.
+func (sw *scanWriter) addHullPoints(o geojson.Object) {
+	sw.hullPoints = appendHullPoints(sw.hullPoints, o, sw.hull)
+	if sw.hull == 0 && len(sw.hullPoints) >= sw.hullSize+hullCompact {
+		sw.hullPoints = hull.Convex(sw.hullPoints)
+		sw.hullSize = 2 * len(sw.hullPoints)
+	}
+}
+
+// hullObject returns the hull of the matching objects, which is a Point or
+// a LineString when they don't cover an area, or nil when there are none.
+func (sw *scanWriter) hullObject() geojson.Object {
+	var ring []geometry.Point
+	if sw.hull > 0 {
+		ring = hull.Concave(sw.hullPoints, sw.hull)
+	} else {
+		ring = hull.Convex(sw.hullPoints)
+	}
+	var o geojson.Object
+	switch len(ring) {
+	case 0:
+		return nil
+	case 1:
+		o = geojson.NewPoint(ring[0])
+	case 2:
+		o = geojson.NewLineString(geometry.NewLine(ring, nil))
+	default:
+		o = geojson.NewPolygon(
+			geometry.NewPoly(append(ring, ring[0]), nil, nil))
+	}
+	if sw.crs != nil {
+		o = sw.s.transformObject(o, sw.crs)
+	}
+	return o
+}
+
+// writeHull writes the hull of the matching objects for JSON, and returns it
+// for RESP.
+func (sw *scanWriter) writeHull() resp.Value {
+	o := sw.hullObject()
+	switch sw.msg.OutputType {
+	case JSON:
+		sw.wr.WriteString(`,"hull":`)
+		if o == nil {
+			sw.wr.WriteString("null")
+		} else {
+			sw.wr.Write(sw.appendObjectJSON(nil, o))
+		}
+	case RESP:
+		if o == nil {
+			return resp.NullValue()
+		}
+		return resp.StringValue(string(sw.appendObjectJSON(nil, o)))
+	}
+	return NOMessage
+}
+
+// parseHull parses the "[CONCAVE meters]" arguments of HULL.
+func parseHull(vs []string) (nvs []string, meters float64, err error) {
+	if len(vs) == 0 || !lc(vs[0], "concave") {
+		return vs, 0, nil
+	}
+	var smeters string
+	var ok bool
+	if vs, smeters, ok = tokenval(vs[1:]); !ok || smeters == "" {
+		return vs, 0, errInvalidNumberOfArguments
+	}
+	meters, err = strconv.ParseFloat(smeters, 64)
+	if err != nil || meters <= 0 {
+		return vs, 0, errInvalidArgument(smeters)
+	}
+	return vs, meters, nil
+}
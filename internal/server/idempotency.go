@@ -0,0 +1,116 @@
+package server
+
+// Idempotency: a write that's sent as IDEMPOTENT token command runs once for
+// a token, and the same write with the same token gets the reply of the
+// first one without running again, for the producers that deliver at least
+// once, such as the consumers of a queue and the clients that retry after a
+// timeout. The tokens of the last writes are kept, with their replies, and
+// the writes are written to the AOF with their tokens, which gives the
+// followers, and the server after a restart, the same tokens. A write that
+// doesn't change anything isn't written to the AOF. AOFSHRINK drops the
+// tokens from the AOF. The tokens of a namespace are its own.
+//
+//	IDEMPOTENT token command [arg ...]
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tidwall/resp"
+)
+
+// idempotencyTokens is the number of tokens that are kept.
+const idempotencyTokens = 100000
+
+var errIdempotentNotWrite = errors.New(
+	"IDEMPOTENT is only for the writes of a single key")
+
+// idempotentReply is the reply of the write of a token.
+type idempotentReply struct {
+	value      resp.Value
+	outputType Type
+}
+
+// idempotency are the tokens of the last writes.
+type idempotency struct {
+	mu      sync.Mutex
+	replies map[string]idempotentReply
+	queue   []string // the tokens in order, for the eviction
+}
+
+// get returns the reply of a token.
+func (ic *idempotency) get(token string) (idempotentReply, bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	reply, ok := ic.replies[token]
+	return reply, ok
+}
+
+// add keeps the reply of a token, and drops the oldest token when there are
+// too many.
+func (ic *idempotency) add(token string, reply idempotentReply) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if ic.replies == nil {
+		ic.replies = make(map[string]idempotentReply)
+	}
+	if _, ok := ic.replies[token]; !ok {
+		ic.queue = append(ic.queue, token)
+	}
+	ic.replies[token] = reply
+	for len(ic.queue) > idempotencyTokens {
+		delete(ic.replies, ic.queue[0])
+		ic.queue = ic.queue[1:]
+	}
+}
+
+// rewriteIdempotentMsg removes IDEMPOTENT and the token from the arguments
+// of a message, like TIMEOUT.
+func rewriteIdempotentMsg(msg *Message) error {
+	vs := msg.Args[1:]
+	var ok bool
+	var token string
+	if vs, token, ok = tokenval(vs); !ok || token == "" || len(vs) == 0 {
+		return errInvalidNumberOfArguments
+	}
+	msg.Args = vs
+	msg._command = ""
+	msg.idempotency = token
+	return nil
+}
+
+// idempotencyToken returns the token of a message, in its namespace.
+func idempotencyToken(msg *Message) string {
+	if msg.namespace != "" {
+		return msg.namespace + ":" + msg.idempotency
+	}
+	return msg.idempotency
+}
+
+// idempotentReplyFor returns the reply of a write whose token was seen. The
+// reply is the reply of the first write, when it had the same output type.
+func idempotentReplyFor(msg *Message, reply idempotentReply) resp.Value {
+	if reply.outputType == msg.OutputType {
+		return reply.value
+	}
+	return OKMessage(msg, time.Now())
+}
+
+// cmdIdempotent runs the write of an IDEMPOTENT from the AOF or the leader.
+// The clients' IDEMPOTENT is rewritten, see handleInputCommand.
+func (server *Server) cmdIdempotent(msg *Message, client *Client) (res resp.Value, d commandDetails, err error) {
+	nmsg := *msg
+	if err = rewriteIdempotentMsg(&nmsg); err != nil {
+		return
+	}
+	token := idempotencyToken(&nmsg)
+	if reply, ok := server.idempotency.get(token); ok {
+		return idempotentReplyFor(msg, reply), d, nil
+	}
+	res, d, err = server.command(&nmsg, client)
+	if err == nil {
+		server.idempotency.add(token, idempotentReply{res, msg.OutputType})
+	}
+	return
+}
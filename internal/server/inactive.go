@@ -0,0 +1,169 @@
+package server
+
+// Inactivity fences: a hook or channel with INACTIVE sends an "inactive"
+// event when an object inside of its fence hasn't been updated for a while,
+// and an "active" event when the object is updated again.
+
+import (
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/tile38/internal/glob"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// inactiveItem is an object inside of the fence of a hook with INACTIVE.
+// It's only changed while holding the server lock, or the write lock.
+type inactiveItem struct {
+	hook     *Hook
+	key, id  string
+	deadline time.Time // when the object becomes inactive
+	queued   bool      // the item is in the inactex list
+	inactive bool      // the inactive event was sent
+}
+
+// Expires returns when the object becomes inactive. Required by the
+// expire.Item interface.
+func (item *inactiveItem) Expires() time.Time {
+	return item.deadline
+}
+
+// inactiveMatch returns true when an object is watched by the inactivity
+// fence of a hook.
+func inactiveMatch(hook *Hook, id string, obj geojson.Object) bool {
+	fence := hook.Fence
+	if len(fence.glob) > 0 && !(len(fence.glob) == 1 && fence.glob[0] == '*') {
+		if match, _ := glob.Match(fence.glob, id); !match {
+			return false
+		}
+	}
+	return obj != nil && objIsSpatial(obj) && fenceMatchObject(fence, obj)
+}
+
+// watchInactive restarts the inactivity timer of an object that was written,
+// and returns the "active" event when the object was inactive. Objects that
+// are deleted, or moved out of the fence, are no longer watched.
+func (s *Server) watchInactive(hook *Hook, d *commandDetails) []string {
+	if d.command == "drop" {
+		for id, item := range hook.inactives {
+			if item.key == d.key {
+				delete(hook.inactives, id)
+			}
+		}
+		return nil
+	}
+	if d.key != hook.Key || d.id == "" {
+		return nil
+	}
+	if d.command == "del" || !inactiveMatch(hook, d.id, d.obj) {
+		delete(hook.inactives, d.id)
+		return nil
+	}
+	item := s.trackInactive(hook, d.id, d.timestamp)
+	if !item.inactive {
+		return nil
+	}
+	item.inactive = false
+	return []string{s.objectMessage(hook, d.command, "active", d)}
+}
+
+// trackInactive starts or restarts the inactivity timer of an object.
+func (s *Server) trackInactive(hook *Hook, id string, t time.Time) *inactiveItem {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	item := hook.inactives[id]
+	if item == nil {
+		item = &inactiveItem{hook: hook, key: hook.Key, id: id}
+		hook.inactives[id] = item
+	}
+	item.deadline = t.Add(hook.inactive)
+	if !item.queued {
+		item.queued = true
+		s.inactex.Push(item)
+	}
+	return item
+}
+
+// seedInactive watches the objects that are inside of the fence of a new
+// hook.
+func (s *Server) seedInactive(hook *Hook) {
+	col := s.getCol(hook.Key)
+	if col == nil {
+		return
+	}
+	now := time.Now()
+	col.Scan(false, nil, nil, func(id string, obj geojson.Object, fields []float64) bool {
+		if inactiveMatch(hook, id, obj) {
+			s.trackInactive(hook, id, now)
+		}
+		return true
+	})
+}
+
+// possiblyInactive sends the "inactive" event of an object when it hasn't
+// been updated before its deadline. An item that was updated is queued
+// again. This operation is called from an independent goroutine.
+func (s *Server) possiblyInactive(item *inactiveItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item.queued = false
+	hook := item.hook
+	if s.hooks[hook.Name] != hook || hook.inactives[item.id] != item ||
+		item.inactive {
+		return
+	}
+	if time.Now().Before(item.deadline) {
+		item.queued = true
+		s.inactex.Push(item)
+		return
+	}
+	d := commandDetails{key: item.key, id: item.id, timestamp: time.Now()}
+	var ok bool
+	if col := s.getCol(item.key); col != nil {
+		d.obj, d.fields, _, ok = col.Get(item.id)
+		d.fmap = make(map[string]int)
+		for field, idx := range col.FieldMap() {
+			d.fmap[field] = idx
+		}
+	}
+	if !ok || !inactiveMatch(hook, item.id, d.obj) {
+		delete(hook.inactives, item.id)
+		return
+	}
+	item.inactive = true
+	msg := s.objectMessage(hook, "inactive", "inactive", &d)
+	msg = sequenceMessages(hook, []string{msg})[0]
+	s.markHookSeqs(hook)
+	if hook.channel {
+		s.Publish(hook.Name, msg)
+		return
+	}
+	if err := s.queueHookMessages([]string{msg}); err != nil {
+		log.Errorf("inactive: %v", err)
+		return
+	}
+	hook.Signal()
+}
+
+// objectMessage returns an event of an object that isn't from FenceMatch,
+// such as "inactive" and "active", or "rising" and "falling" of threshold.go.
+func (s *Server) objectMessage(hook *Hook, command, detect string,
+	d *commandDetails,
+) string {
+	res := fenceWriteObject(hook.ScanWriter, hook.Fence, d)
+	var buf []byte
+	buf = append(append(buf, `{"command":"`...), command...)
+	buf = append(append(buf, `","detect":"`...), detect...)
+	buf = append(buf, '"')
+	buf = appendHookDetails(buf, hook.Name, hook.Metas)
+	buf = appendJSONString(append(buf, `,"key":`...), d.key)
+	buf = appendJSONTimeFormat(append(buf, `,"time":`...), d.timestamp)
+	if len(res) > 1 && res[0] == '{' {
+		buf = append(append(buf, ','), res[1:]...)
+	} else {
+		buf = appendJSONString(append(buf, `,"id":`...), d.id)
+		buf = append(buf, '}')
+	}
+	return string(buf)
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"strconv"
 	"strings"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/tidwall/resp"
 	"github.com/tidwall/sjson"
 	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/simplify"
 )
 
 func appendJSONString(b []byte, s string) []byte {
@@ -117,33 +119,37 @@ func isJSONNumber(data string) bool {
 	return i == len(data)
 }
 
-func appendJSONSimpleBounds(dst []byte, o geojson.Object) []byte {
+// appendJSONSimpleBounds appends the bounds of the object. The digits param
+// rounds the coordinates, and a negative value keeps the full precision.
+func appendJSONSimpleBounds(dst []byte, o geojson.Object, digits int) []byte {
 	bbox := o.Rect()
 	dst = append(dst, `{"sw":{"lat":`...)
-	dst = strconv.AppendFloat(dst, bbox.Min.Y, 'f', -1, 64)
+	dst = strconv.AppendFloat(dst, simplify.Round(bbox.Min.Y, digits), 'f', -1, 64)
 	dst = append(dst, `,"lon":`...)
-	dst = strconv.AppendFloat(dst, bbox.Min.X, 'f', -1, 64)
+	dst = strconv.AppendFloat(dst, simplify.Round(bbox.Min.X, digits), 'f', -1, 64)
 	dst = append(dst, `},"ne":{"lat":`...)
-	dst = strconv.AppendFloat(dst, bbox.Max.Y, 'f', -1, 64)
+	dst = strconv.AppendFloat(dst, simplify.Round(bbox.Max.Y, digits), 'f', -1, 64)
 	dst = append(dst, `,"lon":`...)
-	dst = strconv.AppendFloat(dst, bbox.Max.X, 'f', -1, 64)
+	dst = strconv.AppendFloat(dst, simplify.Round(bbox.Max.X, digits), 'f', -1, 64)
 	dst = append(dst, `}}`...)
 	return dst
 }
 
-func appendJSONSimplePoint(dst []byte, o geojson.Object) []byte {
+// appendJSONSimplePoint appends the center of the object. The digits param
+// rounds the coordinates, and a negative value keeps the full precision.
+func appendJSONSimplePoint(dst []byte, o geojson.Object, digits int) []byte {
 	point := o.Center()
 	var z float64
 	if gPoint, ok := o.(*geojson.Point); ok {
 		z = gPoint.Z()
 	}
 	dst = append(dst, `{"lat":`...)
-	dst = strconv.AppendFloat(dst, point.Y, 'f', -1, 64)
+	dst = strconv.AppendFloat(dst, simplify.Round(point.Y, digits), 'f', -1, 64)
 	dst = append(dst, `,"lon":`...)
-	dst = strconv.AppendFloat(dst, point.X, 'f', -1, 64)
+	dst = strconv.AppendFloat(dst, simplify.Round(point.X, digits), 'f', -1, 64)
 	if z != 0 {
 		dst = append(dst, `,"z":`...)
-		dst = strconv.AppendFloat(dst, z, 'f', -1, 64)
+		dst = strconv.AppendFloat(dst, simplify.Round(z, digits), 'f', -1, 64)
 	}
 	dst = append(dst, '}')
 	return dst
@@ -202,7 +208,15 @@ func (s *Server) cmdJget(msg *Message) (resp.Value, error) {
 		return NOMessage, errIDNotFound
 	}
 	var res gjson.Result
-	if doget {
+	if doget && isJSONPath(path) {
+		matches, ok, err := jsonPathGet(o.String(), path)
+		if err != nil {
+			return NOMessage, err
+		}
+		if ok {
+			res = gjson.Parse(matches)
+		}
+	} else if doget {
 		res = gjson.Get(o.String(), path)
 	} else {
 		res = gjson.Parse(o.String())
@@ -278,15 +292,26 @@ func (s *Server) cmdJset(msg *Message) (res resp.Value, d commandDetails, err er
 		geoobj = objIsSpatial(o)
 		json = o.String()
 	}
-	if raw {
-		// set as raw block
-		json, err = sjson.SetRaw(json, path, val)
-	} else {
-		// set as a string
-		json, err = sjson.Set(json, path, val)
+	paths := []string{path}
+	if isJSONPath(path) {
+		if paths, err = jsonPathSetPaths(json, path); err != nil {
+			return NOMessage, d, err
+		}
+		if len(paths) == 0 {
+			return NOMessage, d, errPathNotFound
+		}
 	}
-	if err != nil {
-		return NOMessage, d, err
+	for _, path := range paths {
+		if raw {
+			// set as raw block
+			json, err = sjson.SetRaw(json, path, val)
+		} else {
+			// set as a string
+			json, err = sjson.Set(json, path, val)
+		}
+		if err != nil {
+			return NOMessage, d, err
+		}
 	}
 
 	if geoobj {
@@ -295,6 +320,12 @@ func (s *Server) cmdJset(msg *Message) (res resp.Value, d commandDetails, err er
 		// SET key id OBJECT json
 		return s.cmdSet(&nmsg)
 	}
+	if schema := s.schema(msg, key); schema != nil {
+		err = schema.checkObject(col, key, id, collection.String(json), nil, nil)
+		if err != nil {
+			return NOMessage, d, err
+		}
+	}
 	if createcol {
 		s.setCol(key, col)
 	}
@@ -305,7 +336,7 @@ func (s *Server) cmdJset(msg *Message) (res resp.Value, d commandDetails, err er
 	d.timestamp = time.Now()
 	d.updated = true
 
-	col.Set(d.id, d.obj, nil, nil, 0)
+	col.Set(d.id, d.obj, nil, nil, s.slidingExpires(key))
 	switch msg.OutputType {
 	case JSON:
 		var buf bytes.Buffer
@@ -343,9 +374,18 @@ func (s *Server) cmdJdel(msg *Message) (res resp.Value, d commandDetails, err er
 		geoobj = objIsSpatial(o)
 		json = o.String()
 	}
-	njson, err := sjson.Delete(json, path)
-	if err != nil {
-		return NOMessage, d, err
+	paths := []string{path}
+	if isJSONPath(path) {
+		if paths, err = jsonPathPaths(json, path); err != nil {
+			return NOMessage, d, err
+		}
+	}
+	njson := json
+	// in reverse, so that the array indexes of the other paths stay the same
+	for i := len(paths) - 1; i >= 0; i-- {
+		if njson, err = sjson.Delete(njson, paths[i]); err != nil {
+			return NOMessage, d, err
+		}
 	}
 	if njson == json {
 		switch msg.OutputType {
@@ -370,7 +410,7 @@ func (s *Server) cmdJdel(msg *Message) (res resp.Value, d commandDetails, err er
 	d.timestamp = time.Now()
 	d.updated = true
 
-	col.Set(d.id, d.obj, nil, nil, 0)
+	col.Set(d.id, d.obj, nil, nil, s.slidingExpires(key))
 	switch msg.OutputType {
 	case JSON:
 		var buf bytes.Buffer
@@ -382,3 +422,253 @@ func (s *Server) cmdJdel(msg *Message) (res resp.Value, d commandDetails, err er
 	}
 	return NOMessage, d, nil
 }
+
+// jsonValue returns the json of a JSET, JAPPEND or JINSERT value, which is a
+// string unless it's a number, true, false, null, or the RAW option is used.
+func jsonValue(val string, opts []string) (string, error) {
+	var raw, str bool
+	switch len(opts) {
+	default:
+		return "", errInvalidNumberOfArguments
+	case 0:
+	case 1:
+		switch strings.ToLower(opts[0]) {
+		default:
+			return "", errInvalidArgument(opts[0])
+		case "raw":
+			raw = true
+		case "str":
+			str = true
+		}
+	}
+	if !str && !raw {
+		switch val {
+		default:
+			raw = isJSONNumber(val)
+		case "true", "false", "null":
+			raw = true
+		}
+	}
+	if !raw {
+		return jsonString(val), nil
+	}
+	if !gjson.Valid(val) {
+		return "", errInvalidArgument(val)
+	}
+	return val, nil
+}
+
+// jsonUpdate changes the json of an object, and stores it like JSET. The
+// object is created when it doesn't exist.
+func (s *Server) jsonUpdate(msg *Message, key, id string,
+	update func(json string) (string, error),
+) (d commandDetails, err error) {
+	col := s.getCol(key)
+	var createcol bool
+	if col == nil {
+		col = collection.New()
+		createcol = true
+	}
+	var json string
+	var geoobj bool
+	o, _, _, ok := col.Get(id)
+	if ok {
+		geoobj = objIsSpatial(o)
+		json = o.String()
+	}
+	if json, err = update(json); err != nil {
+		return d, err
+	}
+	if geoobj {
+		nmsg := *msg
+		nmsg.Args = []string{"SET", key, id, "OBJECT", json}
+		// SET key id OBJECT json
+		_, d, err = s.cmdSet(&nmsg)
+		return d, err
+	}
+	if schema := s.schema(msg, key); schema != nil {
+		err = schema.checkObject(col, key, id, collection.String(json), nil, nil)
+		if err != nil {
+			return d, err
+		}
+	}
+	if createcol {
+		s.setCol(key, col)
+	}
+
+	d.key = key
+	d.id = id
+	d.obj = collection.String(json)
+	d.timestamp = time.Now()
+	d.updated = true
+
+	col.Set(d.id, d.obj, nil, nil, s.slidingExpires(key))
+	return d, nil
+}
+
+// jsonArray returns the elements of the array at a path, which is empty
+// when the path doesn't exist.
+func jsonArray(json, path string) ([]gjson.Result, error) {
+	res := gjson.Get(json, path)
+	if !res.Exists() {
+		return nil, nil
+	}
+	if !res.IsArray() {
+		return nil, errors.New("value is not an array")
+	}
+	return res.Array(), nil
+}
+
+func (s *Server) cmdJincrby(msg *Message, decr bool) (res resp.Value, d commandDetails, err error) {
+	// JINCRBY key id path amount
+	// JDECRBY key id path amount
+	start := time.Now()
+
+	if len(msg.Args) != 5 {
+		return NOMessage, d, errInvalidNumberOfArguments
+	}
+	key := msg.Args[1]
+	id := msg.Args[2]
+	path := msg.Args[3]
+	amount := msg.Args[4]
+	if !isJSONNumber(amount) {
+		return NOMessage, d, errInvalidArgument(amount)
+	}
+	var num string
+	d, err = s.jsonUpdate(msg, key, id, func(json string) (string, error) {
+		cur := gjson.Get(json, path)
+		if cur.Exists() && cur.Type != gjson.Number {
+			return "", errors.New("value is not a number")
+		}
+		curRaw := "0"
+		if cur.Exists() {
+			curRaw = cur.Raw
+		}
+		// integers stay integers, for values past the precision of floats
+		x, err1 := strconv.ParseInt(curRaw, 10, 64)
+		y, err2 := strconv.ParseInt(amount, 10, 64)
+		if decr {
+			y = -y
+		}
+		if z := x + y; err1 == nil && err2 == nil && (z > x) == (y > 0) {
+			num = strconv.FormatInt(z, 10)
+		} else {
+			f, _ := strconv.ParseFloat(amount, 64)
+			if decr {
+				f = -f
+			}
+			num = strconv.FormatFloat(cur.Float()+f, 'f', -1, 64)
+		}
+		return sjson.SetRaw(json, path, num)
+	})
+	if err != nil {
+		return NOMessage, d, err
+	}
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true`)
+		buf.WriteString(`,"value":` + num)
+		buf.WriteString(`,"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String()), d, nil
+	case RESP:
+		return resp.StringValue(num), d, nil
+	}
+	return NOMessage, d, nil
+}
+
+func (s *Server) cmdJappend(msg *Message) (res resp.Value, d commandDetails, err error) {
+	// JAPPEND key id path value [RAW|STR]
+	start := time.Now()
+
+	if len(msg.Args) < 5 {
+		return NOMessage, d, errInvalidNumberOfArguments
+	}
+	key := msg.Args[1]
+	id := msg.Args[2]
+	path := msg.Args[3]
+	val, err := jsonValue(msg.Args[4], msg.Args[5:])
+	if err != nil {
+		return NOMessage, d, err
+	}
+	var n int
+	d, err = s.jsonUpdate(msg, key, id, func(json string) (string, error) {
+		arr, err := jsonArray(json, path)
+		if err != nil {
+			return "", err
+		}
+		n = len(arr) + 1
+		if len(arr) == 0 {
+			return sjson.SetRaw(json, path, "["+val+"]")
+		}
+		return sjson.SetRaw(json, path+".-1", val)
+	})
+	if err != nil {
+		return NOMessage, d, err
+	}
+	return jsonLengthReply(msg, start, n), d, nil
+}
+
+func (s *Server) cmdJinsert(msg *Message) (res resp.Value, d commandDetails, err error) {
+	// JINSERT key id path index value [RAW|STR]
+	start := time.Now()
+
+	if len(msg.Args) < 6 {
+		return NOMessage, d, errInvalidNumberOfArguments
+	}
+	key := msg.Args[1]
+	id := msg.Args[2]
+	path := msg.Args[3]
+	index, err := strconv.Atoi(msg.Args[4])
+	if err != nil {
+		return NOMessage, d, errInvalidArgument(msg.Args[4])
+	}
+	val, err := jsonValue(msg.Args[5], msg.Args[6:])
+	if err != nil {
+		return NOMessage, d, err
+	}
+	var n int
+	d, err = s.jsonUpdate(msg, key, id, func(json string) (string, error) {
+		arr, err := jsonArray(json, path)
+		if err != nil {
+			return "", err
+		}
+		i := index
+		if i < 0 {
+			i += len(arr)
+		}
+		if i < 0 || i > len(arr) {
+			return "", errors.New("index out of range")
+		}
+		elems := make([]string, 0, len(arr)+1)
+		for _, elem := range arr[:i] {
+			elems = append(elems, elem.Raw)
+		}
+		elems = append(elems, val)
+		for _, elem := range arr[i:] {
+			elems = append(elems, elem.Raw)
+		}
+		n = len(elems)
+		return sjson.SetRaw(json, path, "["+strings.Join(elems, ",")+"]")
+	})
+	if err != nil {
+		return NOMessage, d, err
+	}
+	return jsonLengthReply(msg, start, n), d, nil
+}
+
+// jsonLengthReply returns the reply of JAPPEND and JINSERT, which is the new
+// length of the array.
+func jsonLengthReply(msg *Message, start time.Time, n int) resp.Value {
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true`)
+		buf.WriteString(`,"length":` + strconv.Itoa(n))
+		buf.WriteString(`,"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String())
+	case RESP:
+		return resp.IntegerValue(n)
+	}
+	return NOMessage
+}
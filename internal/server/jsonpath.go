@@ -0,0 +1,661 @@
+package server
+
+// JSONPath for JGET, JSET and JDEL. A path that starts with '$' is a
+// JSONPath, which is resolved into the gjson/sjson paths of the values that
+// it matches, in document order. Supported are child names, ['name']
+// brackets, array indexes, negative indexes, slices, unions, wildcards,
+// recursive descent (..), and filters with ==, !=, <, <=, >, >=, &&, ||, !
+// and parentheses, such as $.fleet[?(@.speed > 50 && @.kind == 'truck')].id
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+var errInvalidJSONPath = errors.New("invalid jsonpath")
+
+// isJSONPath returns true when a JGET, JSET or JDEL path is a JSONPath.
+func isJSONPath(path string) bool {
+	return len(path) > 0 && path[0] == '$'
+}
+
+// jpNode is a value that was matched, with its gjson/sjson path.
+type jpNode struct {
+	path string
+	val  gjson.Result
+}
+
+// jpStep is one selector of a JSONPath.
+type jpStep struct {
+	recursive bool     // ..
+	wildcard  bool     // * or [*]
+	names     []string // .name, ['name','other']
+	indexes   []int    // [0,-1]
+	slice     *[3]int  // [start:end:step], see sliceSet
+	sliceSet  [3]bool  // which of the slice values are set
+	filter    jpExpr   // [?(expr)]
+}
+
+// jsonPathPaths returns the gjson/sjson paths of the values of a json
+// document that match a JSONPath.
+func jsonPathPaths(json, path string) ([]string, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	nodes := jpEval(gjson.Parse(json), steps)
+	paths := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if node.path != "" {
+			paths = append(paths, node.path)
+		}
+	}
+	return paths, nil
+}
+
+// jpEval returns the nodes of a value that are selected by the steps.
+func jpEval(val gjson.Result, steps []jpStep) []jpNode {
+	nodes := []jpNode{{val: val}}
+	for _, step := range steps {
+		var next []jpNode
+		for _, node := range nodes {
+			if step.recursive {
+				jpDescendants(node, func(node jpNode) {
+					next = step.apply(node, next)
+				})
+			} else {
+				next = step.apply(node, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// jsonPathSetPaths returns the paths for setting the values that match a
+// JSONPath. When nothing matches and the last selector is a member name, the
+// member is added to the parents that match instead.
+func jsonPathSetPaths(json, path string) ([]string, error) {
+	if json == "" {
+		// a new object
+		json = "{}"
+	}
+	paths, err := jsonPathPaths(json, path)
+	if err != nil || len(paths) > 0 {
+		return paths, err
+	}
+	steps, _ := parseJSONPath(path)
+	if len(steps) == 0 {
+		return nil, nil
+	}
+	last := steps[len(steps)-1]
+	if last.recursive || len(last.names) != 1 {
+		return nil, nil
+	}
+	name := last.names[0]
+	for _, node := range jpEval(gjson.Parse(json), steps[:len(steps)-1]) {
+		if node.val.IsObject() {
+			paths = append(paths, jpChild(node.path, name))
+		}
+	}
+	return paths, nil
+}
+
+// jsonPathGet returns a json array with the values that match a JSONPath,
+// and false when nothing matches.
+func jsonPathGet(json, path string) (string, bool, error) {
+	paths, err := jsonPathPaths(json, path)
+	if err != nil || len(paths) == 0 {
+		return "", false, err
+	}
+	var buf []byte
+	buf = append(buf, '[')
+	for i, path := range paths {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, gjson.Get(json, path).Raw...)
+	}
+	buf = append(buf, ']')
+	return string(buf), true, nil
+}
+
+// jpChild returns the path of a member or element.
+func jpChild(path, name string) string {
+	var b strings.Builder
+	if path != "" {
+		b.WriteString(path)
+		b.WriteByte('.')
+	}
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '.', '*', '?', '|', '#', '@', '\\', '!', '=', '<', '>', '%', ':':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// jpDescendants calls iter for a node and all of its descendants.
+func jpDescendants(node jpNode, iter func(node jpNode)) {
+	iter(node)
+	if node.val.IsObject() {
+		node.val.ForEach(func(key, val gjson.Result) bool {
+			jpDescendants(jpNode{jpChild(node.path, key.String()), val}, iter)
+			return true
+		})
+	} else if node.val.IsArray() {
+		for i, val := range node.val.Array() {
+			jpDescendants(jpNode{jpChild(node.path, strconv.Itoa(i)), val}, iter)
+		}
+	}
+}
+
+// apply adds the children of a node that are selected by the step.
+func (step *jpStep) apply(node jpNode, next []jpNode) []jpNode {
+	val := node.val
+	switch {
+	case step.wildcard || step.filter != nil:
+		if val.IsObject() {
+			val.ForEach(func(key, v gjson.Result) bool {
+				if step.filter == nil || step.filter.eval(v).truthy() {
+					next = append(next, jpNode{jpChild(node.path, key.String()), v})
+				}
+				return true
+			})
+		} else if val.IsArray() {
+			for i, v := range val.Array() {
+				if step.filter == nil || step.filter.eval(v).truthy() {
+					next = append(next, jpNode{jpChild(node.path, strconv.Itoa(i)), v})
+				}
+			}
+		}
+	case len(step.names) > 0:
+		if val.IsObject() {
+			for _, name := range step.names {
+				if v := val.Get(jpChild("", name)); v.Exists() {
+					next = append(next, jpNode{jpChild(node.path, name), v})
+				}
+			}
+		}
+	case len(step.indexes) > 0:
+		if val.IsArray() {
+			arr := val.Array()
+			for _, i := range step.indexes {
+				if i < 0 {
+					i += len(arr)
+				}
+				if i >= 0 && i < len(arr) {
+					next = append(next, jpNode{jpChild(node.path, strconv.Itoa(i)), arr[i]})
+				}
+			}
+		}
+	case step.slice != nil:
+		if val.IsArray() {
+			arr := val.Array()
+			start, end, by := step.sliceBounds(len(arr))
+			for i := start; (by > 0 && i < end) || (by < 0 && i > end); i += by {
+				next = append(next, jpNode{jpChild(node.path, strconv.Itoa(i)), arr[i]})
+			}
+		}
+	}
+	return next
+}
+
+// sliceBounds returns the start, end and step of a slice of an array.
+func (step *jpStep) sliceBounds(n int) (start, end, by int) {
+	by = 1
+	if step.sliceSet[2] {
+		by = step.slice[2]
+	}
+	norm := func(i int) int {
+		if i < 0 {
+			i += n
+		}
+		if i < -1 {
+			i = -1
+		}
+		if i > n {
+			i = n
+		}
+		return i
+	}
+	if by > 0 {
+		start, end = 0, n
+	} else {
+		start, end = n-1, -1
+	}
+	if step.sliceSet[0] {
+		start = norm(step.slice[0])
+	}
+	if step.sliceSet[1] {
+		end = norm(step.slice[1])
+	}
+	if by > 0 && start < 0 {
+		start = 0
+	}
+	if by < 0 && start > n-1 {
+		start = n - 1
+	}
+	return start, end, by
+}
+
+// parseJSONPath returns the steps of a JSONPath.
+func parseJSONPath(path string) ([]jpStep, error) {
+	if !isJSONPath(path) {
+		return nil, errInvalidJSONPath
+	}
+	p := path[1:]
+	var steps []jpStep
+	for len(p) > 0 {
+		var step jpStep
+		switch {
+		case strings.HasPrefix(p, ".."):
+			step.recursive = true
+			p = p[2:]
+			if len(p) > 0 && p[0] == '[' {
+				break
+			}
+			fallthrough
+		case p[0] == '.':
+			if p[0] == '.' {
+				p = p[1:]
+			}
+			n := 0
+			for n < len(p) && p[n] != '.' && p[n] != '[' {
+				n++
+			}
+			if n == 0 {
+				return nil, errInvalidJSONPath
+			}
+			if p[:n] == "*" {
+				step.wildcard = true
+			} else {
+				step.names = []string{p[:n]}
+			}
+			p = p[n:]
+			steps = append(steps, step)
+			continue
+		case p[0] != '[':
+			return nil, errInvalidJSONPath
+		}
+		var err error
+		if p, err = parseJSONPathBracket(p, &step); err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// parseJSONPathBracket parses a [...] selector, and returns the rest of the
+// path.
+func parseJSONPathBracket(p string, step *jpStep) (string, error) {
+	p = strings.TrimLeft(p[1:], " ")
+	if strings.HasPrefix(p, "?") {
+		rest := strings.TrimLeft(p[1:], " ")
+		expr, rest, err := parseJPExpr(rest)
+		if err != nil {
+			return "", err
+		}
+		rest = strings.TrimLeft(rest, " ")
+		if len(rest) == 0 || rest[0] != ']' {
+			return "", errInvalidJSONPath
+		}
+		step.filter = expr
+		return rest[1:], nil
+	}
+	end := jpBracketEnd(p)
+	if end == -1 {
+		return "", errInvalidJSONPath
+	}
+	body, rest := strings.TrimSpace(p[:end]), p[end+1:]
+	switch {
+	case body == "*":
+		step.wildcard = true
+	case body != "" && (body[0] == '\'' || body[0] == '"'):
+		for len(body) > 0 {
+			s, n, ok := jpParseString(body)
+			if !ok {
+				return "", errInvalidJSONPath
+			}
+			step.names = append(step.names, s)
+			body = strings.TrimLeft(body[n:], " ")
+			if len(body) > 0 {
+				if body[0] != ',' {
+					return "", errInvalidJSONPath
+				}
+				body = strings.TrimLeft(body[1:], " ")
+			}
+		}
+	case strings.Contains(body, ":"):
+		parts := strings.Split(body, ":")
+		if len(parts) > 3 {
+			return "", errInvalidJSONPath
+		}
+		step.slice = new([3]int)
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil || (i == 2 && n == 0) {
+				return "", errInvalidJSONPath
+			}
+			step.slice[i] = n
+			step.sliceSet[i] = true
+		}
+	default:
+		for _, part := range strings.Split(body, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return "", errInvalidJSONPath
+			}
+			step.indexes = append(step.indexes, n)
+		}
+	}
+	return rest, nil
+}
+
+// jpBracketEnd returns the index of the ']' that closes a bracket, skipping
+// over quoted strings.
+func jpBracketEnd(p string) int {
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '\'', '"':
+			_, n, ok := jpParseString(p[i:])
+			if !ok {
+				return -1
+			}
+			i += n - 1
+		case ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// jpParseString parses a quoted string, and returns it with the number of
+// bytes that were read.
+func jpParseString(p string) (string, int, bool) {
+	q := p[0]
+	var b strings.Builder
+	for i := 1; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			if i+1 < len(p) {
+				i++
+				b.WriteByte(p[i])
+			}
+		case q:
+			return b.String(), i + 1, true
+		default:
+			b.WriteByte(p[i])
+		}
+	}
+	return "", 0, false
+}
+
+// jpExpr is a filter expression.
+type jpExpr interface {
+	eval(v gjson.Result) jpValue
+}
+
+// jpValue is the value of an operand of a filter expression.
+type jpValue struct {
+	res gjson.Result
+}
+
+func (v jpValue) truthy() bool {
+	switch v.res.Type {
+	case gjson.Null, gjson.False:
+		return false
+	}
+	return v.res.Exists()
+}
+
+type jpLiteral struct{ res gjson.Result }
+
+func (e jpLiteral) eval(gjson.Result) jpValue { return jpValue{e.res} }
+
+// jpRelative is @, or a path of @ such as @.speed or @['kind'][0].
+type jpRelative struct{ steps []jpStep }
+
+func (e jpRelative) eval(v gjson.Result) jpValue {
+	nodes := jpEval(v, e.steps)
+	if len(nodes) == 0 {
+		return jpValue{}
+	}
+	return jpValue{nodes[0].val}
+}
+
+type jpNot struct{ x jpExpr }
+
+func (e jpNot) eval(v gjson.Result) jpValue {
+	return jpBool(!e.x.eval(v).truthy())
+}
+
+type jpBinary struct {
+	op   string
+	l, r jpExpr
+}
+
+func jpBool(t bool) jpValue {
+	if t {
+		return jpValue{gjson.Result{Type: gjson.True, Raw: "true"}}
+	}
+	return jpValue{gjson.Result{Type: gjson.False, Raw: "false"}}
+}
+
+func (e jpBinary) eval(v gjson.Result) jpValue {
+	switch e.op {
+	case "&&":
+		return jpBool(e.l.eval(v).truthy() && e.r.eval(v).truthy())
+	case "||":
+		return jpBool(e.l.eval(v).truthy() || e.r.eval(v).truthy())
+	}
+	l, r := e.l.eval(v).res, e.r.eval(v).res
+	if !l.Exists() || !r.Exists() {
+		return jpBool(e.op == "!=" && l.Exists() != r.Exists())
+	}
+	var cmp int
+	var comparable bool
+	switch {
+	case l.Type == gjson.Number && r.Type == gjson.Number:
+		comparable = true
+		if l.Num < r.Num {
+			cmp = -1
+		} else if l.Num > r.Num {
+			cmp = 1
+		}
+	case l.Type == gjson.String && r.Type == gjson.String:
+		comparable = true
+		cmp = strings.Compare(l.Str, r.Str)
+	default:
+		if l.Type == r.Type && l.Type != gjson.JSON {
+			// true, false, null
+			comparable = true
+		} else if l.Type == r.Type {
+			comparable = true
+			cmp = strings.Compare(string(jpUgly(l.Raw)), string(jpUgly(r.Raw)))
+		}
+	}
+	switch e.op {
+	case "==":
+		return jpBool(comparable && cmp == 0)
+	case "!=":
+		return jpBool(!comparable || cmp != 0)
+	}
+	if !comparable || (l.Type != gjson.Number && l.Type != gjson.String) {
+		return jpBool(false)
+	}
+	switch e.op {
+	case "<":
+		return jpBool(cmp < 0)
+	case "<=":
+		return jpBool(cmp <= 0)
+	case ">":
+		return jpBool(cmp > 0)
+	case ">=":
+		return jpBool(cmp >= 0)
+	}
+	return jpBool(false)
+}
+
+// jpUgly removes the whitespace of json that's outside of strings, for
+// comparing objects and arrays.
+func jpUgly(raw string) []byte {
+	var dst []byte
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c == '"' {
+			j := i + 1
+			for ; j < len(raw); j++ {
+				if raw[j] == '\\' {
+					j++
+				} else if raw[j] == '"' {
+					break
+				}
+			}
+			if j >= len(raw) {
+				j = len(raw) - 1
+			}
+			dst = append(dst, raw[i:j+1]...)
+			i = j
+			continue
+		}
+		if c > ' ' {
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+// parseJPExpr parses a filter expression, which must be in parentheses.
+func parseJPExpr(p string) (jpExpr, string, error) {
+	if len(p) == 0 || p[0] != '(' {
+		return nil, "", errInvalidJSONPath
+	}
+	expr, rest, err := parseJPOr(p[1:])
+	if err != nil {
+		return nil, "", err
+	}
+	rest = strings.TrimLeft(rest, " ")
+	if len(rest) == 0 || rest[0] != ')' {
+		return nil, "", errInvalidJSONPath
+	}
+	return expr, rest[1:], nil
+}
+
+func parseJPOr(p string) (jpExpr, string, error) {
+	l, p, err := parseJPAnd(p)
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		p = strings.TrimLeft(p, " ")
+		if !strings.HasPrefix(p, "||") {
+			return l, p, nil
+		}
+		var r jpExpr
+		if r, p, err = parseJPAnd(p[2:]); err != nil {
+			return nil, "", err
+		}
+		l = jpBinary{"||", l, r}
+	}
+}
+
+func parseJPAnd(p string) (jpExpr, string, error) {
+	l, p, err := parseJPCompare(p)
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		p = strings.TrimLeft(p, " ")
+		if !strings.HasPrefix(p, "&&") {
+			return l, p, nil
+		}
+		var r jpExpr
+		if r, p, err = parseJPCompare(p[2:]); err != nil {
+			return nil, "", err
+		}
+		l = jpBinary{"&&", l, r}
+	}
+}
+
+func parseJPCompare(p string) (jpExpr, string, error) {
+	l, p, err := parseJPOperand(p)
+	if err != nil {
+		return nil, "", err
+	}
+	p = strings.TrimLeft(p, " ")
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p, op) {
+			var r jpExpr
+			if r, p, err = parseJPOperand(p[len(op):]); err != nil {
+				return nil, "", err
+			}
+			return jpBinary{op, l, r}, p, nil
+		}
+	}
+	return l, p, nil
+}
+
+func parseJPOperand(p string) (jpExpr, string, error) {
+	p = strings.TrimLeft(p, " ")
+	if len(p) == 0 {
+		return nil, "", errInvalidJSONPath
+	}
+	switch c := p[0]; {
+	case c == '!':
+		x, rest, err := parseJPOperand(p[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		return jpNot{x}, rest, nil
+	case c == '(':
+		return parseJPExpr(p)
+	case c == '@':
+		// the relative path ends at an operator, a space or a ')'
+		n := 1
+		for depth := 0; n < len(p); n++ {
+			if p[n] == '[' {
+				depth++
+			} else if p[n] == ']' {
+				depth--
+			} else if depth == 0 && strings.IndexByte(" =!<>&|)", p[n]) != -1 {
+				break
+			}
+		}
+		steps, err := parseJSONPath("$" + p[1:n])
+		if err != nil {
+			return nil, "", err
+		}
+		return jpRelative{steps}, p[n:], nil
+	case c == '\'' || c == '"':
+		s, n, ok := jpParseString(p)
+		if !ok {
+			return nil, "", errInvalidJSONPath
+		}
+		return jpLiteral{gjson.Parse(jsonString(s))}, p[n:], nil
+	}
+	// number, true, false or null
+	n := 0
+	for n < len(p) && strings.IndexByte(" =!<>&|)", p[n]) == -1 {
+		n++
+	}
+	lit := p[:n]
+	switch lit {
+	case "true", "false", "null":
+	default:
+		if !isJSONNumber(lit) {
+			return nil, "", errInvalidJSONPath
+		}
+	}
+	return jpLiteral{gjson.Parse(lit)}, p[n:], nil
+}
@@ -0,0 +1,67 @@
+package server
+
+import "testing"
+
+func TestJSONPath(t *testing.T) {
+	json := `{
+		"name": "fleet",
+		"trucks": [
+			{"id": "t1", "speed": 20, "kind": "truck", "tags": ["a", "b"]},
+			{"id": "t2", "speed": 65, "kind": "van"},
+			{"id": "t3", "speed": 80, "kind": "truck", "tags": []},
+			{"id": "t4", "kind": "truck", "meta": {"a.b": 1}}
+		]
+	}`
+	test := func(path, expect string) {
+		t.Helper()
+		actual, ok, err := jsonPathGet(json, path)
+		if err != nil {
+			actual = "error: " + err.Error()
+		} else if !ok {
+			actual = "none"
+		}
+		if actual != expect {
+			t.Fatalf("%s: expected '%s', got '%s'", path, expect, actual)
+		}
+	}
+	test(`$.name`, `["fleet"]`)
+	test(`$['name']`, `["fleet"]`)
+	test(`$.missing`, `none`)
+	test(`$.trucks[0].id`, `["t1"]`)
+	test(`$.trucks[-1].id`, `["t4"]`)
+	test(`$.trucks[0,2].id`, `["t1","t3"]`)
+	test(`$.trucks[1:3].id`, `["t2","t3"]`)
+	test(`$.trucks[::-2].id`, `["t4","t2"]`)
+	test(`$.trucks[*].speed`, `[20,65,80]`)
+	test(`$..tags[0]`, `["a"]`)
+	test(`$.trucks[3].meta['a.b']`, `[1]`)
+	test(`$.trucks[?(@.speed > 50)].id`, `["t2","t3"]`)
+	test(`$.trucks[?(@.speed >= 20 && @.kind == 'truck')].id`, `["t1","t3"]`)
+	test(`$.trucks[?(@.kind == "van" || !@.speed)].id`, `["t2","t4"]`)
+	test(`$.trucks[?(@.tags)].id`, `["t1","t3"]`)
+	test(`$.trucks[?((@.speed < 30 || @.speed > 70) && @.id != 't3')].id`, `["t1"]`)
+	test(`$.trucks[?(@.kind == 'van')]`, `[{"id": "t2", "speed": 65, "kind": "van"}]`)
+	test(`$.trucks[`, `error: invalid jsonpath`)
+	test(`$.trucks[?(@.speed >)]`, `error: invalid jsonpath`)
+	test(`$trucks`, `error: invalid jsonpath`)
+
+	paths, err := jsonPathPaths(json, `$.trucks[?(@.kind == 'truck')].speed`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || paths[0] != "trucks.0.speed" || paths[1] != "trucks.2.speed" {
+		t.Fatalf("unexpected paths: %v", paths)
+	}
+	paths, _ = jsonPathPaths(json, `$.trucks[3].meta['a.b']`)
+	if len(paths) != 1 || paths[0] != `trucks.3.meta.a\.b` {
+		t.Fatalf("unexpected paths: %v", paths)
+	}
+	paths, _ = jsonPathSetPaths(json, `$.trucks[?(@.speed > 50)].fast`)
+	if len(paths) != 2 || paths[0] != "trucks.1.fast" || paths[1] != "trucks.2.fast" {
+		t.Fatalf("unexpected paths: %v", paths)
+	}
+	paths, _ = jsonPathSetPaths("", `$.name`)
+	if len(paths) != 1 || paths[0] != "name" {
+		t.Fatalf("unexpected paths: %v", paths)
+	}
+}
@@ -0,0 +1,341 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // for the SHA256 hashes of tokens
+	_ "crypto/sha512" // for the SHA384 and SHA512 hashes of tokens
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// jwtLeeway is the clock skew that's allowed for the exp and nbf claims.
+const jwtLeeway = time.Minute
+
+// jwksRefresh is the least time between two fetches of the JWKS, which are
+// fetched again when a token has a kid that isn't known.
+const jwksRefresh = time.Minute
+
+var (
+	errInvalidToken = errors.New("invalid token")
+	errTokenExpired = errors.New("token expired")
+)
+
+// jwtAuth is the JWT provider. The tokens are signed with HMAC by the
+// jwt-secret, or with RSA or ECDSA by the key of the jwt-public-key file or a
+// key of the jwt-jwks-url.
+type jwtAuth struct {
+	cfg  authConfig
+	keys *jwtKeys
+}
+
+func (p *jwtAuth) authenticate(user, token string) (authIdentity, error) {
+	if user != "" || strings.Count(token, ".") != 2 {
+		return authIdentity{}, errAuthSkip
+	}
+	parts := strings.Split(token, ".")
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || !gjson.ValidBytes(header) {
+		return authIdentity{}, errInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !gjson.ValidBytes(payload) {
+		return authIdentity{}, errInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return authIdentity{}, errInvalidToken
+	}
+	alg := gjson.GetBytes(header, "alg").String()
+	kid := gjson.GetBytes(header, "kid").String()
+	if err := p.verify(alg, kid, parts[0]+"."+parts[1], sig); err != nil {
+		return authIdentity{}, err
+	}
+	claims := gjson.ParseBytes(payload)
+	now := time.Now()
+	if exp := claims.Get("exp"); exp.Exists() &&
+		!now.Before(time.Unix(exp.Int(), 0).Add(jwtLeeway)) {
+		return authIdentity{}, errTokenExpired
+	}
+	if nbf := claims.Get("nbf"); nbf.Exists() &&
+		now.Add(jwtLeeway).Before(time.Unix(nbf.Int(), 0)) {
+		return authIdentity{}, errors.New("token not valid yet")
+	}
+	if p.cfg.jwtIssuer != "" && claims.Get("iss").String() != p.cfg.jwtIssuer {
+		return authIdentity{}, errors.New("invalid token issuer")
+	}
+	if p.cfg.jwtAudience != "" {
+		var ok bool
+		aud := claims.Get("aud")
+		if aud.IsArray() {
+			aud.ForEach(func(_, v gjson.Result) bool {
+				ok = v.String() == p.cfg.jwtAudience
+				return !ok
+			})
+		} else {
+			ok = aud.String() == p.cfg.jwtAudience
+		}
+		if !ok {
+			return authIdentity{}, errors.New("invalid token audience")
+		}
+	}
+	id := authIdentity{name: claims.Get("sub").String()}
+	if p.cfg.jwtNamespace != "" {
+		// the claim is a path, such as "tile38.namespace"
+		ns := claims.Get(p.cfg.jwtNamespace)
+		if ns.Type != gjson.String || ns.String() == "" {
+			return authIdentity{}, fmt.Errorf("token has no '%s' claim",
+				p.cfg.jwtNamespace)
+		}
+		id.namespace = ns.String()
+	}
+	if p.cfg.jwtRole != "" {
+		// a token without the claim has no role, unlike the namespace claim,
+		// so that the same identity provider can issue tokens without one
+		if role := claims.Get(p.cfg.jwtRole); role.Type == gjson.String {
+			id.role = role.String()
+		}
+	}
+	return id, nil
+}
+
+// verify checks the signature of a token.
+func (p *jwtAuth) verify(alg, kid, signed string, sig []byte) error {
+	if len(alg) != 5 {
+		// including "none"
+		return errInvalidToken
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return errInvalidToken
+	}
+	switch alg[:2] {
+	case "HS":
+		if p.cfg.jwtSecret == "" {
+			return errInvalidToken
+		}
+		mac := hmac.New(hash.New, []byte(p.cfg.jwtSecret))
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errInvalidToken
+		}
+		return nil
+	case "RS", "ES":
+		key, err := p.keys.key(p.cfg, kid)
+		if err != nil {
+			return err
+		}
+		h := hash.New()
+		h.Write([]byte(signed))
+		digest := h.Sum(nil)
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			if alg[:2] == "RS" && rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			size := (key.Curve.Params().BitSize + 7) / 8
+			if alg[:2] == "ES" && len(sig) == size*2 {
+				r := new(big.Int).SetBytes(sig[:size])
+				s := new(big.Int).SetBytes(sig[size:])
+				if ecdsa.Verify(key, digest, r, s) {
+					return nil
+				}
+			}
+		}
+	}
+	return errInvalidToken
+}
+
+// jwtKeys are the public keys of the JWT provider. The key of the
+// jwt-public-key file is loaded again when the file changes, and the keys of
+// the jwt-jwks-url are fetched again for a kid that isn't known.
+type jwtKeys struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	pemKey  crypto.PublicKey
+	url     string
+	jwks    map[string]crypto.PublicKey // by kid
+	fetched time.Time
+}
+
+// key returns the public key of a token.
+func (k *jwtKeys) key(cfg authConfig, kid string) (crypto.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if cfg.jwtJWKSURL != "" {
+		if k.url != cfg.jwtJWKSURL {
+			k.url, k.jwks, k.fetched = cfg.jwtJWKSURL, nil, time.Time{}
+		}
+		key, ok := k.jwksKey(kid)
+		if !ok && time.Since(k.fetched) >= jwksRefresh {
+			k.fetched = time.Now()
+			jwks, err := fetchJWKS(k.url)
+			if err != nil {
+				log.Warnf("jwks: %v", err)
+			} else {
+				k.jwks = jwks
+			}
+			key, ok = k.jwksKey(kid)
+		}
+		if ok {
+			return key, nil
+		}
+	}
+	if cfg.jwtPublicKey != "" {
+		fi, err := os.Stat(cfg.jwtPublicKey)
+		if err != nil {
+			log.Warnf("jwt-public-key: %v", err)
+			return nil, errInvalidToken
+		}
+		if k.path != cfg.jwtPublicKey || !k.modTime.Equal(fi.ModTime()) {
+			key, err := loadPublicKey(cfg.jwtPublicKey)
+			if err != nil {
+				log.Warnf("jwt-public-key: %v", err)
+				return nil, errInvalidToken
+			}
+			k.path, k.modTime, k.pemKey = cfg.jwtPublicKey, fi.ModTime(), key
+		}
+		return k.pemKey, nil
+	}
+	return nil, errInvalidToken
+}
+
+// jwksKey returns the key of a kid. A token without a kid can use the key of
+// a JWKS that has one key.
+func (k *jwtKeys) jwksKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(k.jwks) == 1 {
+		for _, key := range k.jwks {
+			return key, true
+		}
+	}
+	key, ok := k.jwks[kid]
+	return key, ok
+}
+
+// loadPublicKey loads an RSA or ECDSA public key, or the key of a
+// certificate, from a PEM file.
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+	var key crypto.PublicKey
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, errors.New("not an RSA or ECDSA key")
+}
+
+// fetchJWKS fetches the signing keys of a JWKS.
+func fetchJWKS(url string) (map[string]crypto.PublicKey, error) {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if !gjson.ValidBytes(data) {
+		return nil, fmt.Errorf("%s: invalid json", url)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	gjson.GetBytes(data, "keys").ForEach(func(_, jwk gjson.Result) bool {
+		if use := jwk.Get("use").String(); use != "" && use != "sig" {
+			return true
+		}
+		if key := parseJWK(jwk); key != nil {
+			keys[jwk.Get("kid").String()] = key
+		}
+		return true
+	})
+	return keys, nil
+}
+
+// parseJWK returns the public key of an RSA or EC JWK, or nil.
+func parseJWK(jwk gjson.Result) crypto.PublicKey {
+	num := func(name string) *big.Int {
+		data, err := base64.RawURLEncoding.DecodeString(jwk.Get(name).String())
+		if err != nil || len(data) == 0 {
+			return nil
+		}
+		return new(big.Int).SetBytes(data)
+	}
+	switch jwk.Get("kty").String() {
+	case "RSA":
+		n, e := num("n"), num("e")
+		if n == nil || e == nil || !e.IsInt64() {
+			return nil
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Get("crv").String() {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil
+		}
+		x, y := num("x"), num("y")
+		if x == nil || y == nil || !curve.IsOnCurve(x, y) {
+			return nil
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	}
+	return nil
+}
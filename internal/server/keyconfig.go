@@ -0,0 +1,375 @@
+package server
+
+// Key configs: KEYCONFIG sets the storage options of a key, which otherwise
+// come from the server. PACKED overrides the geometry-codec config, VALIDATE
+// overrides the REQUIREVALID environment variable, TIMESTAMPS adds the time
+// of each SET to the object as a field, and SNAP rounds the points to a grid
+// and drops the SETs that don't change anything, for devices that report the
+// same position over and over. TOMBSTONES keeps the deleted objects for a
+// number of seconds, see tombstone.go. COALESCE holds the SETs for a number
+// of seconds and applies the last one of each id, and COALESCELOG writes the
+// others to the AOF, see coalesce.go. ZINDEX indexes the altitude of the
+// objects, for the searches with WHERE z, see zindex.go in the collection
+// package. The configs are written to the AOF
+// like TTLCONFIG, and the schema of a key stays with SCHEMASET.
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+)
+
+// timestampField is the field of the objects in the keys that have
+// TIMESTAMPS, in unix milliseconds.
+const timestampField = "timestamp"
+
+// keyConfig is the KEYCONFIG of a key. The zero values follow the server.
+type keyConfig struct {
+	packed      string // "true" or "false"
+	validate    string // "strict" or "loose"
+	timestamps  bool
+	snap        float64 // grid size, in degrees
+	tombstones  float64 // seconds that deleted objects are kept
+	coalesce    float64 // seconds that SETs are held
+	coalesceLog bool
+	zindex      bool
+}
+
+// args returns the option and value pairs of a config, in order.
+func (conf keyConfig) args() []string {
+	var args []string
+	if conf.packed != "" {
+		args = append(args, "packed", conf.packed)
+	}
+	if conf.validate != "" {
+		args = append(args, "validate", conf.validate)
+	}
+	if conf.timestamps {
+		args = append(args, "timestamps", "on")
+	}
+	if conf.snap > 0 {
+		args = append(args, "snap", strconv.FormatFloat(conf.snap, 'f', -1, 64))
+	}
+	if conf.tombstones > 0 {
+		args = append(args, "tombstones",
+			strconv.FormatFloat(conf.tombstones, 'f', -1, 64))
+	}
+	if conf.coalesce > 0 {
+		args = append(args, "coalesce",
+			strconv.FormatFloat(conf.coalesce, 'f', -1, 64))
+	}
+	if conf.coalesceLog {
+		args = append(args, "coalescelog", "on")
+	}
+	if conf.zindex {
+		args = append(args, "zindex", "on")
+	}
+	return args
+}
+
+// parseOnOff parses the value of a boolean option.
+func parseOnOff(value string) (on, ok bool) {
+	switch strings.ToLower(value) {
+	case "on", "true", "yes":
+		return true, true
+	case "off", "false", "no":
+		return false, true
+	}
+	return false, false
+}
+
+// cmdKeyConfig sets, returns, or removes the config of a key. DEL without
+// options removes all of the options.
+//
+//	KEYCONFIG SET key option value [option value ...]
+//	KEYCONFIG GET key
+//	KEYCONFIG DEL key [option ...]
+func (server *Server) cmdKeyConfig(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var sub string
+	if vs, sub, ok = tokenval(vs); !ok || sub == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	conf := server.keyConfigs[d.key]
+	switch strings.ToLower(sub) {
+	case "get":
+		if len(vs) != 0 {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		res = keyConfigReply(msg, conf, start)
+		return
+	case "set":
+		if len(vs) == 0 || len(vs)%2 != 0 {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		for i := 0; i < len(vs); i += 2 {
+			name, value := strings.ToLower(vs[i]), strings.ToLower(vs[i+1])
+			on, isBool := parseOnOff(value)
+			switch {
+			case name == "packed" && isBool:
+				conf.packed = strconv.FormatBool(on)
+			case name == "validate" && (value == "strict" || value == "loose"):
+				conf.validate = value
+			case name == "timestamps" && isBool:
+				conf.timestamps = on
+			case name == "snap":
+				size, perr := strconv.ParseFloat(value, 64)
+				if perr != nil || size <= 0 || size > 1 {
+					err = errInvalidArgument(vs[i+1])
+					return
+				}
+				conf.snap = size
+			case name == "tombstones":
+				secs, perr := strconv.ParseFloat(value, 64)
+				if perr != nil || secs <= 0 {
+					err = errInvalidArgument(vs[i+1])
+					return
+				}
+				conf.tombstones = secs
+			case name == "coalesce":
+				secs, perr := strconv.ParseFloat(value, 64)
+				if perr != nil || secs <= 0 || secs > 60 {
+					err = errInvalidArgument(vs[i+1])
+					return
+				}
+				conf.coalesce = secs
+			case name == "coalescelog" && isBool:
+				conf.coalesceLog = on
+			case name == "zindex" && isBool:
+				conf.zindex = on
+			case name == "packed" || name == "validate" || name == "timestamps" ||
+				name == "coalescelog" || name == "zindex":
+				err = errInvalidArgument(vs[i+1])
+				return
+			default:
+				err = errInvalidArgument(vs[i])
+				return
+			}
+		}
+	case "del":
+		if len(vs) == 0 {
+			conf = keyConfig{}
+		}
+		for _, name := range vs {
+			switch strings.ToLower(name) {
+			case "packed":
+				conf.packed = ""
+			case "validate":
+				conf.validate = ""
+			case "timestamps":
+				conf.timestamps = false
+			case "snap":
+				conf.snap = 0
+			case "tombstones":
+				conf.tombstones = 0
+			case "coalesce":
+				conf.coalesce = 0
+			case "coalescelog":
+				conf.coalesceLog = false
+			case "zindex":
+				conf.zindex = false
+			default:
+				err = errInvalidArgument(name)
+				return
+			}
+		}
+	default:
+		err = errInvalidArgument(sub)
+		return
+	}
+	if conf == (keyConfig{}) {
+		delete(server.keyConfigs, d.key)
+	} else {
+		server.keyConfigs[d.key] = conf
+	}
+	if col := server.getCol(d.key); col != nil {
+		col.SetCodec(server.keyCodec(d.key))
+		col.SetZIndex(conf.zindex)
+	}
+	if conf.tombstones == 0 {
+		server.tombstones.drop(d.key)
+	}
+	d.command = "keyconfig"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// keyConfigReply returns the reply of KEYCONFIG GET.
+func keyConfigReply(msg *Message, conf keyConfig, start time.Time) resp.Value {
+	args := conf.args()
+	switch msg.OutputType {
+	case JSON:
+		buf := []byte(`{"ok":true,"config":{`)
+		for i := 0; i < len(args); i += 2 {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `"`+args[i]+`":`...)
+			switch {
+			case args[i+1] == "on":
+				buf = append(buf, "true"...)
+			case args[i+1] == "true", args[i+1] == "false", args[i] == "snap",
+				args[i] == "tombstones", args[i] == "coalesce":
+				buf = append(buf, args[i+1]...)
+			default:
+				buf = appendJSONString(buf, args[i+1])
+			}
+		}
+		buf = append(buf, `},"elapsed":"`+time.Since(start).String()+`"}`...)
+		return resp.StringValue(string(buf))
+	case RESP:
+		vals := make([]resp.Value, len(args))
+		for i, arg := range args {
+			vals[i] = resp.StringValue(arg)
+		}
+		return resp.ArrayValue(vals)
+	}
+	return NOMessage
+}
+
+// keyCodec returns the storage format of the new geometries of a key.
+func (server *Server) keyCodec(key string) collection.Codec {
+	switch server.keyConfigs[key].packed {
+	case "true":
+		return collection.CodecDelta
+	case "false":
+		return collection.CodecNone
+	}
+	return server.config.geometryCodec()
+}
+
+// parseOpts returns the options for parsing the objects of a key.
+func (server *Server) parseOpts(key string) *geojson.ParseOptions {
+	validate := server.keyConfigs[key].validate
+	if validate == "" ||
+		(validate == "strict") == server.geomParseOpts.RequireValid {
+		return &server.geomParseOpts
+	}
+	opts := server.geomParseOpts
+	opts.RequireValid = validate == "strict"
+	return &opts
+}
+
+// stampArgs adds the time of a SET to the fields of an object, when the key
+// has TIMESTAMPS. Like annotateAreas, the field is added to the arguments of
+// the object, which start at the id, so that the AOF has the same time. A
+// timestamp that's set by the command itself is kept.
+func (server *Server) stampArgs(key string, fields []string,
+	values []float64, args []string,
+) ([]string, []float64, []string) {
+	if !server.keyConfigs[key].timestamps {
+		return fields, values, args
+	}
+	for _, field := range fields {
+		if field == timestampField {
+			return fields, values, args
+		}
+	}
+	ts := time.Now().UnixNano() / int64(time.Millisecond)
+	fields = append(fields, timestampField)
+	values = append(values, float64(ts))
+	nargs := make([]string, 0, len(args)+3)
+	nargs = append(nargs, args[0], "FIELD", timestampField,
+		strconv.FormatInt(ts, 10))
+	nargs = append(nargs, args[1:]...)
+	return fields, values, nargs
+}
+
+// snapObject returns a point that's rounded to the grid of its key. The
+// other objects aren't changed.
+func (server *Server) snapObject(key string, o geojson.Object) geojson.Object {
+	size := server.keyConfigs[key].snap
+	if size == 0 {
+		return o
+	}
+	snap := func(v float64) float64 {
+		// 15 digits drop the error of the multiplication
+		v, _ = strconv.ParseFloat(
+			strconv.FormatFloat(math.Round(v/size)*size, 'g', 15, 64), 64)
+		return v
+	}
+	if p, ok := o.(*geojson.Point); ok {
+		point := geometry.Point{X: snap(p.Base().X), Y: snap(p.Base().Y)}
+		if p.Z() != 0 {
+			return geojson.NewPointZ(point, p.Z())
+		}
+		return geojson.NewPoint(point)
+	}
+	return o
+}
+
+// unchanged returns true when a SET to a key that has SNAP wouldn't change
+// the object, the values of its fields, its tags, its metadata, or its
+// expiration, so that it's dropped. The timestamp of TIMESTAMPS isn't a change.
+func (server *Server) unchanged(col *collection.Collection, key, id string,
+	obj geojson.Object, fields []string, values []float64, tags []string,
+	meta *string, sfields []string, ex int64,
+) bool {
+	conf := server.keyConfigs[key]
+	if conf.snap == 0 || col == nil || ex != 0 {
+		return false
+	}
+	old, ofields, oex, ok := col.Get(id)
+	if !ok || oex != 0 || old.String() != obj.String() {
+		return false
+	}
+	fmap := col.FieldMap()
+	for i, field := range fields {
+		if conf.timestamps && field == timestampField {
+			continue
+		}
+		var value float64
+		if idx, ok := fmap[field]; ok && idx < len(ofields) {
+			value = ofields[idx]
+		}
+		if value != values[i] {
+			return false
+		}
+	}
+	if meta != nil {
+		if ometa, _ := col.Meta(id); ometa != *meta {
+			return false
+		}
+	}
+	for i := 0; i < len(sfields); i += 2 {
+		if value, _ := col.StringField(id, sfields[i]); value != sfields[i+1] {
+			return false
+		}
+	}
+	return tags == nil || equalTags(tags, col.Tags(id))
+}
+
+// keyConfigCommands returns the commands that rebuild the KEYCONFIG of the
+// keys, for aofshrink.
+func (server *Server) keyConfigCommands() [][]string {
+	keys := make([]string, 0, len(server.keyConfigs))
+	for key := range server.keyConfigs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmds := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, append([]string{"keyconfig", "set", key},
+			server.keyConfigs[key].args()...))
+	}
+	return cmds
+}
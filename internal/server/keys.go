@@ -59,9 +59,9 @@ func (s *Server) cmdKeys(msg *Message) (res resp.Value, err error) {
 			}
 			switch msg.OutputType {
 			case JSON:
-				wr.WriteString(jsonString(vcol.key))
+				wr.WriteString(jsonString(msg.nsLocal(vcol.key)))
 			case RESP:
-				vals = append(vals, resp.StringValue(vcol.key))
+				vals = append(vals, resp.StringValue(msg.nsLocal(vcol.key)))
 			}
 
 			// If no more than one match is expected, stop searching
@@ -0,0 +1,71 @@
+package server
+
+// Per-key operation counters, which are shown by STATS and the prometheus
+// metrics. The counters are kept with the key, so they start over when the
+// key is dropped.
+
+// keyStats are the operation counters of a key.
+type keyStats struct {
+	reads    aint
+	writes   aint
+	searches aint
+	bytesIn  aint // bytes of the command arguments
+	bytesOut aint // bytes of the replies
+}
+
+// keyOp returns the kind of operation of a command on a single key, or an
+// empty string for other commands. The commands are the ones that take the
+// key lock in handleInputCommand, which TestKeyOp checks.
+func keyOp(cmd string) string {
+	switch cmd {
+	case "set", "del", "fset", "expire", "persist", "jset", "jdel", "pdel",
+		"mset", "mfset", "touch", "pexpire", "expireat", "pexpireat",
+		"jincrby", "jdecrby", "jappend", "jinsert", "fincrby", "fdecrby", "fmin",
+		"fmax", "tag", "untag", "setmeta", "undelete", "sfset":
+		return "write"
+	case "get", "ttl", "pttl", "bounds", "type", "jget", "density", "tags",
+		"getmeta", "tombstones", "mget", "sfget", "fleetstats",
+		"writebehindstats", "readthroughstats":
+		return "read"
+	case "scan", "nearby", "within", "intersects", "search":
+		return "search"
+	}
+	return ""
+}
+
+// countKeyOp counts a command on the key of the message and returns the
+// counters of the key. It returns nil when the command isn't on a single
+// key, or when the key doesn't exist. The key lock must be held.
+func (server *Server) countKeyOp(msg *Message) *keyStats {
+	op := keyOp(msg.Command())
+	if op == "" || len(msg.Args) < 2 {
+		return nil
+	}
+	c := server.getColContainer(msg.Args[1])
+	if c == nil {
+		return nil
+	}
+	switch op {
+	case "write":
+		c.ops.writes.add(1)
+	case "read":
+		c.ops.reads.add(1)
+	case "search":
+		c.ops.searches.add(1)
+	}
+	var n int
+	for _, arg := range msg.Args {
+		n += len(arg)
+	}
+	c.ops.bytesIn.add(n)
+	return &c.ops
+}
+
+// addStats adds the counters to the STATS of a key.
+func (ks *keyStats) addStats(m map[string]interface{}) {
+	m["reads"] = ks.reads.get()
+	m["writes"] = ks.writes.get()
+	m["searches"] = ks.searches.get()
+	m["bytes_in"] = ks.bytesIn.get()
+	m["bytes_out"] = ks.bytesOut.get()
+}
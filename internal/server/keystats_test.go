@@ -0,0 +1,73 @@
+package server
+
+import (
+	"go/ast"
+	"go/parser"
+	gotoken "go/token"
+	"strconv"
+	"testing"
+)
+
+// lockSwitchCommands returns the commands of the case of the locking switch
+// in handleInputCommand that includes a command.
+func lockSwitchCommands(t *testing.T, include string) []string {
+	t.Helper()
+	file, err := parser.ParseFile(gotoken.NewFileSet(), "server.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cmds []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		fn, ok := n.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "handleInputCommand" {
+			return true
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			clause, ok := n.(*ast.CaseClause)
+			if !ok || cmds != nil {
+				return cmds == nil
+			}
+			var list []string
+			for _, expr := range clause.List {
+				if lit, ok := expr.(*ast.BasicLit); ok {
+					cmd, _ := strconv.Unquote(lit.Value)
+					list = append(list, cmd)
+				}
+			}
+			for _, cmd := range list {
+				if cmd == include {
+					cmds = list
+				}
+			}
+			return false
+		})
+		return false
+	})
+	if cmds == nil {
+		t.Fatalf("no locking case for '%s'", include)
+	}
+	return cmds
+}
+
+func TestKeyOp(t *testing.T) {
+	writes := lockSwitchCommands(t, "set")
+	reads := lockSwitchCommands(t, "get")
+	for _, cmd := range writes {
+		if op := keyOp(cmd); op != "write" {
+			t.Fatalf("%s: expected 'write', got '%s'", cmd, op)
+		}
+	}
+	for _, cmd := range reads {
+		if op := keyOp(cmd); op != "read" && op != "search" {
+			t.Fatalf("%s: expected 'read' or 'search', got '%s'", cmd, op)
+		}
+	}
+	for _, cmd := range []string{"keys", "drop", "sethook", "mread", "server"} {
+		if op := keyOp(cmd); op != "" {
+			t.Fatalf("%s: expected no operation, got '%s'", cmd, op)
+		}
+	}
+	if len(reads) < 10 || len(writes) < 10 {
+		t.Fatalf("expected the read and write cases, got %v %v", reads, writes)
+	}
+}
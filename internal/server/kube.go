@@ -0,0 +1,170 @@
+package server
+
+// Kubernetes clustering: with --cluster-discovery, the server finds its
+// peers with DNS or the Kubernetes API, and follows their leader, in place
+// of FOLLOW. The leader is the pod with the leader label, or the first pod
+// of the StatefulSet, or with --cluster-election yes, the first of the
+// peers that answer, unless one of them already leads. See internal/kube.
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tidwall/tile38/core"
+	"github.com/tidwall/tile38/internal/kube"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// clusterInterval is the time between the elections.
+const clusterInterval = 2 * time.Second
+
+type kubeCluster struct {
+	disc     kube.Discoverer
+	election bool
+	name     string // of the pod
+
+	// protected by the server mutex
+	role   string
+	leader string // host:port, or empty when leading
+}
+
+// openCluster returns the cluster of core.ClusterDiscovery, or nil.
+func (s *Server) openCluster() (*kubeCluster, error) {
+	if core.ClusterDiscovery == "" {
+		return nil, nil
+	}
+	disc, err := kube.Open(core.ClusterDiscovery, s.port)
+	if err != nil {
+		return nil, fmt.Errorf("cluster-discovery: %v", err)
+	}
+	name, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return &kubeCluster{disc: disc, election: core.ClusterElection,
+		name: name, role: kube.RoleCandidate}, nil
+}
+
+func (s *Server) backgroundCluster() {
+	for !s.stopServer.on() {
+		if err := s.clusterStep(); err != nil {
+			log.Errorf("cluster: %v", err)
+		}
+		time.Sleep(clusterInterval)
+	}
+}
+
+// clusterStep finds the peers and follows their leader.
+func (s *Server) clusterStep() error {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterInterval)
+	defer cancel()
+	peers, err := s.cluster.disc.Peers(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	id := s.config.serverID()
+	auth := s.config.leaderAuth()
+	role := s.cluster.role
+	s.mu.RUnlock()
+
+	members := make([]kube.Member, len(peers))
+	var wg sync.WaitGroup
+	for i := range peers {
+		members[i].Peer = peers[i]
+		wg.Add(1)
+		go func(m *kube.Member) {
+			defer wg.Done()
+			clusterProbe(m, id, auth)
+		}(&members[i])
+	}
+	wg.Wait()
+	var found bool
+	for i := range members {
+		if members[i].Self || members[i].Name == s.cluster.name {
+			members[i].Self = true
+			members[i].Role = role
+			found = true
+		}
+	}
+	if !found {
+		// not a peer until it's ready, for the DNS of a headless service
+		members = append(members, kube.Member{
+			Peer: kube.Peer{Name: s.cluster.name, Port: s.port,
+				Ordinal: kube.Ordinal(s.cluster.name)},
+			Self: true, Role: role,
+		})
+	}
+	leader, ok := kube.Leader(members, s.cluster.election)
+	if !ok {
+		return nil
+	}
+	if leader.Self {
+		s.clusterFollow("", 0)
+	} else {
+		s.clusterFollow(leader.Host, leader.Port)
+	}
+	return nil
+}
+
+// clusterProbe sets the role of a member that answers, and whether it's
+// this server.
+func clusterProbe(m *kube.Member, id, auth string) {
+	conn, err := DialTimeout(net.JoinHostPort(m.Host, strconv.Itoa(m.Port)),
+		time.Second*2)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.conn.SetDeadline(time.Now().Add(time.Second * 2))
+	if auth != "" {
+		if v, err := conn.Do("auth", auth); err != nil || v.Error() != nil {
+			return
+		}
+	}
+	info, err := doServer(conn)
+	if err != nil {
+		return
+	}
+	switch {
+	case info["id"] == id:
+		m.Self = true
+	case info["cluster_role"] != "":
+		m.Role = info["cluster_role"]
+	case info["following"] != "":
+		m.Role = kube.RoleFollower
+	default:
+		m.Role = kube.RoleCandidate
+	}
+}
+
+// clusterFollow follows a leader, or no one when this server leads.
+func (s *Server) clusterFollow(host string, port int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if host == "" {
+		s.cluster.role = kube.RoleLeader
+		s.cluster.leader = ""
+	} else {
+		s.cluster.role = kube.RoleFollower
+		s.cluster.leader = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	if s.config.followHost() == host && s.config.followPort() == port {
+		return
+	}
+	s.config.setFollowHost(host)
+	s.config.setFollowPort(port)
+	s.config.write(false)
+	s.followc.add(1)
+	if host != "" {
+		log.Infof("cluster: following the leader %s", s.cluster.leader)
+		go s.follow(host, port, s.followc.get())
+	} else {
+		log.Infof("cluster: leading")
+	}
+}
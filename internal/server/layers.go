@@ -0,0 +1,184 @@
+package server
+
+// Reference layers: read-only areas, such as countries, timezones, and zip
+// codes, that are loaded from the directory of the "layerdir" config
+// property and looked up by point. Layers are shared by all clients and
+// don't count against maxmemory.
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/layer"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// layerSet is the set of loaded layers. It's replaced as a whole and never
+// modified, so it's used without locks.
+type layerSet struct {
+	names  []string // sorted
+	layers map[string]*layer.Layer
+	heap   int // heap bytes taken by the layers
+}
+
+// loadLayers loads the layers of a directory, replacing the current layers.
+// An empty directory unloads the layers.
+func (server *Server) loadLayers(dir string) error {
+	set := &layerSet{layers: make(map[string]*layer.Layer)}
+	if dir != "" {
+		var mem runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&mem)
+		before := mem.HeapAlloc
+		layers, err := layer.LoadDir(dir, &server.geomParseOpts)
+		if err != nil {
+			return err
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&mem)
+		if mem.HeapAlloc > before {
+			set.heap = int(mem.HeapAlloc - before)
+		}
+		for _, l := range layers {
+			set.names = append(set.names, l.Name())
+			set.layers[l.Name()] = l
+			log.Infof("Layer loaded: %s, %d areas", l.Name(), l.Len())
+		}
+		sort.Strings(set.names)
+	}
+	server.layers.Store(set)
+	return nil
+}
+
+// layerSet returns the loaded layers.
+func (server *Server) layerSet() *layerSet {
+	set, _ := server.layers.Load().(*layerSet)
+	if set == nil {
+		return &layerSet{}
+	}
+	return set
+}
+
+// layersHeap returns the heap bytes taken by the loaded layers.
+func (server *Server) layersHeap() int {
+	return server.layerSet().heap
+}
+
+func errLayerNotFound(name string) error {
+	return errors.New("layer not found: " + name)
+}
+
+// cmdLayers lists the loaded layers with the number of areas of each.
+//
+//	LAYERS
+func (server *Server) cmdLayers(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	if len(msg.Args) != 1 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	set := server.layerSet()
+	switch msg.OutputType {
+	case JSON:
+		var buf []byte
+		buf = append(buf, `{"ok":true,"layers":[`...)
+		for i, name := range set.names {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `{"name":`...)
+			buf = appendJSONString(buf, name)
+			buf = append(buf, `,"areas":`...)
+			buf = strconv.AppendInt(buf, int64(set.layers[name].Len()), 10)
+			buf = append(buf, '}')
+		}
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+"\"}"...)
+		return resp.StringValue(string(buf)), nil
+	case RESP:
+		vals := make([]resp.Value, 0, len(set.names))
+		for _, name := range set.names {
+			vals = append(vals, resp.ArrayValue([]resp.Value{
+				resp.StringValue(name),
+				resp.IntegerValue(set.layers[name].Len()),
+			}))
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
+
+// cmdLookup returns the areas of a layer that contain a point.
+//
+//	LOOKUP layer lat lon
+func (server *Server) cmdLookup(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var name, slat, slon string
+	if vs, name, ok = tokenval(vs); !ok || name == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, slat, ok = tokenval(vs); !ok || slat == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, slon, ok = tokenval(vs); !ok || slon == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	lat, err := strconv.ParseFloat(slat, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return NOMessage, errInvalidArgument(slat)
+	}
+	lon, err := strconv.ParseFloat(slon, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return NOMessage, errInvalidArgument(slon)
+	}
+	l := server.layerSet().layers[name]
+	if l == nil {
+		return NOMessage, errLayerNotFound(name)
+	}
+	areas := l.Lookup(geometry.Point{X: lon, Y: lat})
+	switch msg.OutputType {
+	case JSON:
+		var buf []byte
+		buf = append(buf, `{"ok":true,"areas":[`...)
+		for i, area := range areas {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONArea(buf, area)
+		}
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+"\"}"...)
+		return resp.StringValue(string(buf)), nil
+	case RESP:
+		vals := make([]resp.Value, 0, len(areas))
+		for _, area := range areas {
+			props := area.Properties
+			if props == "" {
+				props = "{}"
+			}
+			vals = append(vals, resp.ArrayValue([]resp.Value{
+				resp.StringValue(area.ID), resp.StringValue(props),
+			}))
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
+
+// appendJSONArea appends the id and the properties of a layer area.
+func appendJSONArea(dst []byte, area *layer.Area) []byte {
+	dst = append(dst, `{"id":`...)
+	dst = appendJSONString(dst, area.ID)
+	if area.Properties != "" {
+		dst = append(dst, `,"properties":`...)
+		dst = append(dst, area.Properties...)
+	}
+	return append(dst, '}')
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// ldapTimeout is the timeout of the LDAP binds.
+const ldapTimeout = 5 * time.Second
+
+// The LDAP result codes of binds.
+const (
+	ldapSuccess            = 0
+	ldapInvalidCredentials = 49
+)
+
+var errLDAPUnavailable = errors.New("ldap unavailable")
+
+// ldapAuth is the LDAP provider. The user is authenticated with a simple bind
+// of the DN of the ldap-bind-dn, such as "uid=%s,ou=people,dc=example,dc=com".
+type ldapAuth struct {
+	url    string
+	bindDN string
+}
+
+func (p *ldapAuth) authenticate(user, password string) (authIdentity, error) {
+	if user == "" {
+		return authIdentity{}, errAuthSkip
+	}
+	if password == "" {
+		// a bind without a password is an unauthenticated bind, which
+		// servers accept for any user
+		return authIdentity{}, errInvalidPassword
+	}
+	dn := strings.Replace(p.bindDN, "%s", ldapEscape(user), 1)
+	if err := ldapBind(p.url, dn, password); err != nil {
+		return authIdentity{}, err
+	}
+	return authIdentity{name: user}, nil
+}
+
+// ldapBind does a simple bind on an LDAP server.
+func ldapBind(url, dn, password string) error {
+	addr, port, secure := url, "389", false
+	if strings.HasPrefix(url, "ldaps://") {
+		addr, port, secure = url[len("ldaps://"):], "636", true
+	} else {
+		addr = strings.TrimPrefix(addr, "ldap://")
+	}
+	addr = strings.TrimSuffix(addr, "/")
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+		addr = net.JoinHostPort(addr, port)
+	}
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+	var conn net.Conn
+	if secure {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr,
+			&tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		log.Warnf("ldap: %v", err)
+		return errLDAPUnavailable
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ldapTimeout))
+
+	// LDAPMessage { messageID, BindRequest { version, name, simple } }
+	req := berTLV(0x30, berInt(1),
+		berTLV(0x60, berInt(3), berTLV(0x04, []byte(dn)),
+			berTLV(0x80, []byte(password))))
+	if _, err := conn.Write(req); err != nil {
+		log.Warnf("ldap: %v", err)
+		return errLDAPUnavailable
+	}
+	code, err := ldapReadBindResponse(bufio.NewReader(conn))
+	if err != nil {
+		log.Warnf("ldap: %v", err)
+		return errLDAPUnavailable
+	}
+	// UnbindRequest
+	conn.Write(berTLV(0x30, berInt(2), berTLV(0x42)))
+	switch code {
+	case ldapSuccess:
+		return nil
+	case ldapInvalidCredentials:
+		return errInvalidPassword
+	default:
+		return fmt.Errorf("ldap bind failed with result code %d", code)
+	}
+}
+
+// ldapReadBindResponse returns the result code of a BindResponse.
+func ldapReadBindResponse(rd *bufio.Reader) (int, error) {
+	tag, msg, err := berRead(rd)
+	if err != nil {
+		return 0, err
+	}
+	if tag != 0x30 {
+		return 0, errors.New("invalid ldap message")
+	}
+	// messageID
+	if tag, _, msg, err = berParse(msg); err != nil || tag != 0x02 {
+		return 0, errors.New("invalid ldap message")
+	}
+	var op []byte
+	if tag, op, _, err = berParse(msg); err != nil || tag != 0x61 {
+		return 0, errors.New("invalid ldap bind response")
+	}
+	var code []byte
+	if tag, code, _, err = berParse(op); err != nil || tag != 0x0a ||
+		len(code) == 0 || len(code) > 4 {
+		return 0, errors.New("invalid ldap bind response")
+	}
+	var n int
+	for _, b := range code {
+		n = n<<8 | int(b)
+	}
+	return n, nil
+}
+
+// berTLV returns the BER encoding of a tag and its contents.
+func berTLV(tag byte, contents ...[]byte) []byte {
+	var n int
+	for _, c := range contents {
+		n += len(c)
+	}
+	b := []byte{tag}
+	if n < 0x80 {
+		b = append(b, byte(n))
+	} else {
+		var l []byte
+		for x := n; x > 0; x >>= 8 {
+			l = append([]byte{byte(x)}, l...)
+		}
+		b = append(append(b, 0x80|byte(len(l))), l...)
+	}
+	for _, c := range contents {
+		b = append(b, c...)
+	}
+	return b
+}
+
+// berInt returns the BER encoding of a small integer.
+func berInt(n byte) []byte {
+	return []byte{0x02, 0x01, n}
+}
+
+// berRead reads a BER element.
+func berRead(rd *bufio.Reader) (tag byte, contents []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(rd, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(hdr[1])
+	if n&0x80 != 0 {
+		nb := n & 0x7f
+		if nb == 0 || nb > 3 {
+			return 0, nil, errors.New("invalid ber length")
+		}
+		n = 0
+		for i := 0; i < nb; i++ {
+			b, err := rd.ReadByte()
+			if err != nil {
+				return 0, nil, err
+			}
+			n = n<<8 | int(b)
+		}
+		if n > 1<<20 {
+			return 0, nil, errors.New("ldap message too large")
+		}
+	}
+	contents = make([]byte, n)
+	if _, err = io.ReadFull(rd, contents); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], contents, nil
+}
+
+// berParse returns the first BER element of data, and the data after it.
+func berParse(data []byte) (tag byte, contents, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, errors.New("invalid ber element")
+	}
+	tag, n, i := data[0], int(data[1]), 2
+	if n&0x80 != 0 {
+		nb := n & 0x7f
+		if nb == 0 || nb > 3 || len(data) < 2+nb {
+			return 0, nil, nil, errors.New("invalid ber length")
+		}
+		n = 0
+		for ; i < 2+nb; i++ {
+			n = n<<8 | int(data[i])
+		}
+	}
+	if len(data)-i < n {
+		return 0, nil, nil, errors.New("invalid ber element")
+	}
+	return tag, data[i : i+n], data[i+n:], nil
+}
+
+// ldapEscape escapes a value of a DN, see RFC 4514.
+func ldapEscape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 0:
+			sb.WriteString(`\00`)
+			continue
+		case strings.IndexByte(`\,+"<>;=`, c) != -1,
+			c == '#' && i == 0,
+			c == ' ' && (i == 0 || i == len(s)-1):
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
@@ -6,18 +6,59 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/tidwall/redcon"
 	"github.com/tidwall/tile38/internal/log"
 )
 
 type liveBuffer struct {
-	key     string
-	glob    string
-	fence   *liveFenceSwitches
-	details []*commandDetails
-	cond    *sync.Cond
+	key       string
+	glob      string
+	fence     *liveFenceSwitches
+	details   []*commandDetails
+	viewports [][]string // VIEWPORT args that the main loop applies
+	cond      *sync.Cond
+}
+
+// Viewports: a client of a live WITHIN or INTERSECTS fence that follows a
+// map sends VIEWPORT with the rect of the map as it pans and zooms, on the
+// fence connection, and the area of the fence becomes the rect. The fence
+// keeps its key and options, and the next updates are matched with the new
+// area, which doesn't send anything for the objects that are already in it,
+// and so the client searches the new area when it needs them.
+//
+//	VIEWPORT BOUNDS minlat minlon maxlat maxlon
+//	VIEWPORT HASH geohash
+//	VIEWPORT TILE x y z
+//	VIEWPORT QUADKEY quadkey
+
+var errViewportNotAllowed = errors.New(
+	"viewport is only allowed for within and intersects fences")
+
+// viewportFence returns a copy of a fence with the area of VIEWPORT args.
+func viewportFence(fence *liveFenceSwitches, args []string) (*liveFenceSwitches, error) {
+	if fence.cmd != "within" && fence.cmd != "intersects" {
+		return nil, errViewportNotAllowed
+	}
+	vs := args[1:]
+	var ok bool
+	var typ string
+	if vs, typ, ok = tokenval(vs); !ok || typ == "" {
+		return nil, errInvalidNumberOfArguments
+	}
+	vs, rect, err := parseRectArea(strings.ToLower(typ), vs)
+	if err != nil {
+		return nil, err
+	}
+	if len(vs) != 0 {
+		return nil, errInvalidNumberOfArguments
+	}
+	nfence := *fence
+	nfence.obj = rect
+	return &nfence, nil
 }
 
 func (server *Server) processLives() {
@@ -33,12 +74,10 @@ func (server *Server) processLives() {
 			if len(server.lstack) == 0 {
 				server.lstack = nil
 			}
-			for lb := range server.lives {
+			for lb := range server.lives[item.key] {
 				lb.cond.L.Lock()
-				if lb.key != "" && lb.key == item.key {
-					lb.details = append(lb.details, item)
-					lb.cond.Broadcast()
-				}
+				lb.details = append(lb.details, item)
+				lb.cond.Broadcast()
 				lb.cond.L.Unlock()
 			}
 		}
@@ -85,6 +124,8 @@ func (server *Server) goLive(
 		return errors.New("invalid live type switches")
 	case liveAOFSwitches:
 		return server.liveAOF(s.pos, conn, rd, msg)
+	case liveCDCSwitches:
+		return server.liveCDC(s.pos, conn, rd)
 	case liveSubscriptionSwitches:
 		return server.liveSubscription(conn, rd, msg, websocket)
 	case liveMonitorSwitches:
@@ -104,22 +145,29 @@ func (server *Server) goLive(
 	lb.glob = s.glob
 	lb.key = s.key
 	lb.fence = &s
-	server.mu.RLock()
+	msg.snap = nil // live fences always see the latest objects
+	unlock := server.lockKeyRead(s.key)
 	sw, err = server.newScanWriter(
 		&wr, msg, s.key, s.output, s.precision, s.glob, false,
-		s.cursor, s.limit, s.wheres, s.whereins, s.whereevals, s.nofields)
-	server.mu.RUnlock()
+		s.cursor, s.limit, s.wheres, s.whereins, s.whereevals, s.nofields, s.selected, s.reshape, s.crs)
+	unlock()
 
 	// everything below if for live SCAN, NEARBY, WITHIN, INTERSECTS
 	if err != nil {
 		return err
 	}
 	server.lcond.L.Lock()
-	server.lives[lb] = true
+	if server.lives[lb.key] == nil {
+		server.lives[lb.key] = make(map[*liveBuffer]bool)
+	}
+	server.lives[lb.key][lb] = true
 	server.lcond.L.Unlock()
 	defer func() {
 		server.lcond.L.Lock()
-		delete(server.lives, lb)
+		delete(server.lives[lb.key], lb)
+		if len(server.lives[lb.key]) == 0 {
+			delete(server.lives, lb.key)
+		}
 		server.lcond.L.Unlock()
 		conn.Close()
 	}()
@@ -147,8 +195,13 @@ func (server *Server) goLive(
 				}
 				switch v.Command() {
 				default:
-					log.Error("received a live command that was not QUIT")
+					log.Error("received a live command that was not QUIT or VIEWPORT")
 					return
+				case "viewport":
+					lb.cond.L.Lock()
+					lb.viewports = append(lb.viewports, v.Args)
+					lb.cond.Broadcast()
+					lb.cond.L.Unlock()
 				case "quit", "":
 					return
 				}
@@ -176,6 +229,37 @@ func (server *Server) goLive(
 			lb.cond.L.Unlock()
 			return nil
 		}
+		for len(lb.viewports) > 0 {
+			args := lb.viewports[0]
+			lb.viewports = lb.viewports[1:]
+			start := time.Now()
+			fence, err := viewportFence(lb.fence, args)
+			if err == nil {
+				lb.fence = fence
+			}
+			lb.cond.L.Unlock()
+			var res []byte
+			switch outputType {
+			case JSON:
+				if err != nil {
+					res = []byte(`{"ok":false,"err":` + jsonString(err.Error()) +
+						`,"elapsed":"` + time.Since(start).String() + `"}`)
+				} else {
+					res = []byte(`{"ok":true,"elapsed":"` +
+						time.Since(start).String() + `"}`)
+				}
+			case RESP:
+				if err != nil {
+					res = redcon.AppendError(nil, "ERR "+err.Error())
+				} else {
+					res = redcon.AppendOK(nil)
+				}
+			}
+			if err := writeLiveMessage(conn, res, outputType == JSON, connType, websocket); err != nil {
+				return nil // nil return is fine here
+			}
+			lb.cond.L.Lock()
+		}
 		for len(lb.details) > 0 {
 			details := lb.details[0]
 			lb.details = lb.details[1:]
@@ -187,10 +271,12 @@ func (server *Server) goLive(
 			var msgs []string
 			func() {
 				// safely lock the fence because we are outside the main loop
-				server.mu.RLock()
-				defer server.mu.RUnlock()
+				defer server.lockAllRead()()
 				msgs = FenceMatch("", sw, fence, nil, details)
 			}()
+			if msg.namespace != "" {
+				msgs = namespaceMessages(msg.namespace, msgs)
+			}
 			for _, msg := range msgs {
 				if err := writeLiveMessage(conn, []byte(msg), true, connType, websocket); err != nil {
 					return nil // nil return is fine here
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/tile38/internal/collection"
+)
+
+// Locking
+//
+// There are three levels of locks, which are always acquired in this order:
+//
+//   server.wmu   serializes writers. Readers that span multiple keys also
+//                hold it for reading so that no key changes underneath them.
+//   server.mu    the server-level lock. It's held exclusively only when a key
+//                is created or dropped, or when server state such as hooks,
+//                channels, and config changes.
+//   key mu       the collectionKeyContainer lock for a single key.
+//
+// A read on a single key holds server.mu and the key lock for reading,
+// which allows for reads on any key to run while another key is being
+// written to.
+
+// getColContainer returns the container for the key, or nil if the key does
+// not exist. The server.mu lock must be held.
+func (server *Server) getColContainer(key string) *collectionKeyContainer {
+	if v := server.cols.Get(&collectionKeyContainer{key: key}); v != nil {
+		return v.(*collectionKeyContainer)
+	}
+	return nil
+}
+
+// touch records an access to the key.
+func (c *collectionKeyContainer) touch() {
+	atomic.StoreInt64(&c.atime, time.Now().UnixNano())
+}
+
+// lockKeyRead acquires the locks for reading a single key and returns the
+// function that releases them.
+func (server *Server) lockKeyRead(key string) (unlock func()) {
+	server.mu.RLock()
+	c := server.getColContainer(key)
+	if c == nil {
+		return server.mu.RUnlock
+	}
+	c.touch()
+	c.mu.RLock()
+	return func() {
+		c.mu.RUnlock()
+		server.mu.RUnlock()
+	}
+}
+
+// lockRead acquires the locks for a read command on a single key and returns
+// the function that releases them. Searches that reference another key,
+// such as with a GET area, lock across all keys instead. When snapshot
+// reads are enabled, WITHIN, NEARBY, and INTERSECTS do not lock the key at
+// all, once the key has a snapshot. See snapshot.go.
+func (server *Server) lockRead(msg *Message) (unlock func()) {
+	if len(msg.Args) < 2 || readsOtherKeys(msg.Args[2:]) {
+		return server.lockAllRead()
+	}
+	if isSnapshotCommand(msg.Command()) && server.config.snapshotEpoch() > 0 {
+		return server.lockSnapshotRead(msg)
+	}
+	return server.lockKeyRead(msg.Args[1])
+}
+
+// readsOtherKeys returns true if the command arguments include a GET, which
+// references an object in another key.
+func readsOtherKeys(args []string) bool {
+	for _, arg := range args {
+		if len(arg) == 3 && strings.ToLower(arg) == "get" {
+			return true
+		}
+	}
+	return false
+}
+
+// lockKeyWrite acquires the locks for writing to the key of the message and
+// returns the function that releases them. The server-level lock is taken
+// exclusively when the command might create or drop the key.
+func (server *Server) lockKeyWrite(msg *Message) (unlock func()) {
+	var key string
+	if len(msg.Args) > 1 {
+		key = msg.Args[1]
+	}
+	cmd := msg.Command()
+	return server.lockKeyWriteFor(key, func(col *collection.Collection) bool {
+		return mayDropKey(cmd, col)
+	})
+}
+
+// lockKeyWriteFor acquires the locks for writing to the key. The mayDrop
+// function returns true when the write could remove the last object in the
+// collection, which in turn drops the key.
+func (server *Server) lockKeyWriteFor(
+	key string, mayDrop func(col *collection.Collection) bool,
+) (unlock func()) {
+	server.wmu.Lock()
+	server.mu.RLock()
+	c := server.getColContainer(key)
+	if c == nil || mayDrop(c.col) {
+		// No other writer can run, so it's safe to upgrade the lock.
+		server.mu.RUnlock()
+		server.mu.Lock()
+		return func() {
+			server.mu.Unlock()
+			server.wmu.Unlock()
+		}
+	}
+	c.touch()
+	c.mu.Lock()
+	return func() {
+		c.mu.Unlock()
+		server.mu.RUnlock()
+		server.wmu.Unlock()
+	}
+}
+
+// mayDropKey returns true when the command could delete the last object in
+// the collection.
+func mayDropKey(cmd string, col *collection.Collection) bool {
+	switch cmd {
+	case "pdel":
+		return true
+	case "del", "jdel":
+		return col.Count() <= 1
+	}
+	return false
+}
+
+// lockAllWrite acquires the locks for writing across all keys and returns
+// the function that releases them. Everything else is blocked.
+func (server *Server) lockAllWrite() (unlock func()) {
+	server.wmu.Lock()
+	server.mu.Lock()
+	return func() {
+		server.mu.Unlock()
+		server.wmu.Unlock()
+	}
+}
+
+// lockAllRead acquires the locks for reading across all keys and returns the
+// function that releases them. Writers are blocked, other readers are not.
+func (server *Server) lockAllRead() (unlock func()) {
+	server.wmu.RLock()
+	server.mu.RLock()
+	return func() {
+		server.mu.RUnlock()
+		server.wmu.RUnlock()
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+// Metadata: an opaque blob of an object that is set with SET ... META, or
+// with SETMETA, and returned with GETMETA. It's kept with the id of the
+// object, and no larger than the meta-max-size of the config.
+
+import (
+	"time"
+
+	"github.com/tidwall/resp"
+)
+
+// checkMeta returns an error when the metadata of a write is larger than the
+// meta-max-size. The AOF and the leader aren't checked.
+func (server *Server) checkMeta(msg *Message, meta *string) error {
+	if meta == nil || (msg.ConnType == Null && msg.OutputType == Null) {
+		return nil
+	}
+	if max := server.config.metaMaxSize(); len(*meta) > max {
+		return clientErrorf("meta is larger than the %s of %d bytes",
+			MetaMaxSize, max)
+	}
+	return nil
+}
+
+// cmdSetMeta replaces the metadata of an object, or removes it when it's
+// empty.
+//
+//	SETMETA key id meta
+func (server *Server) cmdSetMeta(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var meta string
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, d.id, ok = tokenval(vs); !ok || d.id == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, meta, ok = tokenval(vs); !ok || len(vs) != 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if err = server.checkMeta(msg, &meta); err != nil {
+		return
+	}
+	col := server.getCol(d.key)
+	if col == nil {
+		err = errKeyNotFound
+		return
+	}
+	old, ok := col.Meta(d.id)
+	if !ok {
+		err = errIDNotFound
+		return
+	}
+	if old != meta {
+		col.SetMeta(d.id, meta)
+		d.updated = true
+	}
+	d.command = "setmeta"
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"elapsed":"` +
+			time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.SimpleStringValue("OK")
+	}
+	return
+}
+
+// cmdGetMeta returns the metadata of an object. For RESP, it's null when the
+// object has none, as an empty reply isn't written.
+//
+//	GETMETA key id
+func (server *Server) cmdGetMeta(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key, id string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, id, ok = tokenval(vs); !ok || id == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var meta string
+	col := server.getCol(key)
+	if col != nil {
+		meta, ok = col.Meta(id)
+	}
+	if col == nil || !ok {
+		if msg.OutputType == RESP {
+			return resp.NullValue(), nil
+		}
+		if col == nil {
+			return NOMessage, errKeyNotFound
+		}
+		return NOMessage, errIDNotFound
+	}
+	switch msg.OutputType {
+	case JSON:
+		buf := appendJSONString([]byte(`{"ok":true,"meta":`), meta)
+		buf = append(buf, `,"elapsed":"`+time.Since(start).String()+`"}`...)
+		return resp.StringValue(string(buf)), nil
+	case RESP:
+		if meta == "" {
+			return resp.NullValue(), nil
+		}
+		return resp.StringValue(meta), nil
+	}
+	return NOMessage, nil
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/tidwall/tile38/core"
 
@@ -32,6 +33,7 @@ var (
 		"tile38_total_connections_received": prometheus.NewDesc("tile38_connections_received_total", "", nil, nil),
 		"tile38_total_messages_sent":        prometheus.NewDesc("tile38_messages_sent_total", "", nil, nil),
 		"tile38_expired_keys":               prometheus.NewDesc("tile38_expired_keys_total", "", nil, nil),
+		"tile38_evicted_keys":               prometheus.NewDesc("tile38_evicted_keys_total", "", nil, nil),
 
 		/*
 			these metrics are NOT taken from basicStats() / extStats()
@@ -41,9 +43,16 @@ var (
 		"collection_points":  prometheus.NewDesc("tile38_collection_points", "Total number of points per collection", []string{"col"}, nil),
 		"collection_strings": prometheus.NewDesc("tile38_collection_strings", "Total number of strings per collection", []string{"col"}, nil),
 		"collection_weight":  prometheus.NewDesc("tile38_collection_weight_bytes", "Total weight of collection in bytes", []string{"col"}, nil),
+		"collection_ops":     prometheus.NewDesc("tile38_collection_ops_total", "Total number of operations per collection", []string{"col", "op"}, nil),
+		"collection_bytes":   prometheus.NewDesc("tile38_collection_bytes_total", "Total bytes of commands and replies per collection", []string{"col", "direction"}, nil),
 		"server_info":        prometheus.NewDesc("tile38_server_info", "Server info", []string{"id", "version"}, nil),
 		"replication":        prometheus.NewDesc("tile38_replication_info", "Replication info", []string{"role", "following", "caught_up", "caught_up_once"}, nil),
 		"start_time":         prometheus.NewDesc("tile38_start_time_seconds", "", nil, nil),
+		"hot_key_hits":       prometheus.NewDesc("tile38_hot_key_hits", "Recent uses of the most used collections", []string{"col"}, nil),
+		"hot_object_hits":    prometheus.NewDesc("tile38_hot_object_hits", "Recent uses of the most used objects", []string{"col", "id"}, nil),
+		"hook_inside":        prometheus.NewDesc("tile38_hook_inside", "Objects inside of the fence of a hook with OCCUPANCY", []string{"hook"}, nil),
+		"hook_entered":       prometheus.NewDesc("tile38_hook_entered", "Objects that entered the fence of a hook in its OCCUPANCY window", []string{"hook"}, nil),
+		"hook_exited":        prometheus.NewDesc("tile38_hook_exited", "Objects that exited the fence of a hook in its OCCUPANCY window", []string{"hook"}, nil),
 	}
 
 	cmdDurations = prometheus.NewSummaryVec(prometheus.SummaryOpts{
@@ -117,6 +126,32 @@ func (s *Server) Collect(ch chan<- prometheus.Metric) {
 		prometheus.GaugeValue, 1.0,
 		replLbls...)
 
+	for _, item := range s.hotKeys.top(false) {
+		ch <- prometheus.MustNewConstMetric(
+			metricDescriptions["hot_key_hits"],
+			prometheus.GaugeValue, float64(item.Count), item.Name)
+	}
+	for _, item := range s.hotKeys.top(true) {
+		key, id := splitHotObject(item.Name)
+		ch <- prometheus.MustNewConstMetric(
+			metricDescriptions["hot_object_hits"],
+			prometheus.GaugeValue, float64(item.Count), key, id)
+	}
+
+	now := time.Now()
+	for name, hook := range s.hooks {
+		if hook.occupancy == nil {
+			continue
+		}
+		inside, entered, exited := hook.occupancy.counts(now)
+		ch <- prometheus.MustNewConstMetric(metricDescriptions["hook_inside"],
+			prometheus.GaugeValue, float64(inside), name)
+		ch <- prometheus.MustNewConstMetric(metricDescriptions["hook_entered"],
+			prometheus.GaugeValue, float64(entered), name)
+		ch <- prometheus.MustNewConstMetric(metricDescriptions["hook_exited"],
+			prometheus.GaugeValue, float64(exited), name)
+	}
+
 	/*
 		add objects/points/strings stats for each collection
 	*/
@@ -146,6 +181,29 @@ func (s *Server) Collect(ch chan<- prometheus.Metric) {
 			float64(c.col.TotalWeight()),
 			c.key,
 		)
+		for _, op := range []struct {
+			name string
+			n    *aint
+		}{{"read", &c.ops.reads}, {"write", &c.ops.writes}, {"search", &c.ops.searches}} {
+			ch <- prometheus.MustNewConstMetric(
+				metricDescriptions["collection_ops"],
+				prometheus.CounterValue,
+				float64(op.n.get()),
+				c.key, op.name,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			metricDescriptions["collection_bytes"],
+			prometheus.CounterValue,
+			float64(c.ops.bytesIn.get()),
+			c.key, "in",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			metricDescriptions["collection_bytes"],
+			prometheus.CounterValue,
+			float64(c.ops.bytesOut.get()),
+			c.key, "out",
+		)
 		return true
 	})
 }
@@ -0,0 +1,145 @@
+package server
+
+// MGET returns many objects of a key at once, such as the objects that a
+// dashboard shows, with one lock of the key, and so the objects are
+// consistent with each other, and with one reply instead of a GET for each.
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/resp"
+)
+
+// cmdMget returns the objects of ids of a key, in the order of the ids, and
+// optionally their fields and the seconds that they have left to live,
+// which is -1 for an object that doesn't expire. An id that doesn't exist
+// has a null. The options come before the ids.
+//
+//	MGET key [WITHFIELDS] [WITHTTL] id [id ...]
+func (server *Server) cmdMget(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var withfields, withttl bool
+	for len(vs) > 0 {
+		if lc(vs[0], "withfields") {
+			withfields = true
+		} else if lc(vs[0], "withttl") {
+			withttl = true
+		} else {
+			break
+		}
+		vs = vs[1:]
+	}
+	if len(vs) == 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	ids := vs
+	rule, err := server.redaction(msg, key)
+	if err != nil {
+		return NOMessage, err
+	}
+	col := server.getCol(key)
+	now := start.UnixNano()
+	var buf []byte
+	var vals []resp.Value
+	if msg.OutputType == JSON {
+		buf = append(buf, `{"ok":true,"objects":[`...)
+	} else {
+		vals = make([]resp.Value, 0, len(ids))
+	}
+	for i, id := range ids {
+		if msg.OutputType == JSON && i > 0 {
+			buf = append(buf, ',')
+		}
+		var o geojson.Object
+		var fields []float64
+		var ex int64
+		var found bool
+		if col != nil {
+			o, fields, ex, found = col.Get(id)
+		}
+		if !found {
+			switch msg.OutputType {
+			case JSON:
+				buf = append(buf, "null"...)
+			case RESP:
+				vals = append(vals, resp.NullValue())
+			}
+			continue
+		}
+		server.touchOnRead(key, id)
+		if rule != nil {
+			o = rule.object(o)
+		}
+		var fvs []fvt
+		if withfields {
+			for _, fv := range orderFields(col.FieldMap(), col.FieldArr(), fields) {
+				if rule == nil || !rule.hidden(fv.field) {
+					fvs = append(fvs, fv)
+				}
+			}
+		}
+		ttl := -1.0
+		if ex != 0 {
+			ttl = math.Max(0, float64(ex-now)/float64(time.Second))
+		}
+		switch msg.OutputType {
+		case JSON:
+			buf = append(buf, `{"id":`...)
+			buf = appendJSONString(buf, id)
+			buf = append(buf, `,"object":`...)
+			buf = o.AppendJSON(buf)
+			if withfields {
+				buf = append(buf, `,"fields":{`...)
+				for i, fv := range fvs {
+					if i > 0 {
+						buf = append(buf, ',')
+					}
+					buf = appendJSONString(buf, fv.field)
+					buf = append(buf, ':')
+					buf = strconv.AppendFloat(buf, fv.value, 'f', -1, 64)
+				}
+				buf = append(buf, '}')
+			}
+			if withttl {
+				buf = append(buf, `,"ttl":`...)
+				buf = strconv.AppendFloat(buf, ttl, 'f', -1, 64)
+			}
+			buf = append(buf, '}')
+		case RESP:
+			if !withfields && !withttl {
+				vals = append(vals, resp.StringValue(o.String()))
+				continue
+			}
+			ovals := []resp.Value{resp.StringValue(o.String())}
+			if withfields {
+				fvals := make([]resp.Value, 0, len(fvs)*2)
+				for _, fv := range fvs {
+					fvals = append(fvals, resp.StringValue(fv.field),
+						resp.StringValue(strconv.FormatFloat(fv.value, 'f', -1, 64)))
+				}
+				ovals = append(ovals, resp.ArrayValue(fvals))
+			}
+			if withttl {
+				ovals = append(ovals, resp.IntegerValue(int(ttl)))
+			}
+			vals = append(vals, resp.ArrayValue(ovals))
+		}
+	}
+	switch msg.OutputType {
+	case JSON:
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+`"}`...)
+		return resp.StringValue(string(buf)), nil
+	case RESP:
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
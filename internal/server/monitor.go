@@ -75,7 +75,7 @@ func (s *Server) sendMonitor(err error, msg *Message, c *Client, lua bool) {
 	switch strings.ToLower(msg.Command()) {
 	case "config", "config set", "config get", "config rewrite",
 		"auth", "follow", "slaveof", "replconf",
-		"aof", "aofmd5", "client",
+		"aof", "aofmd5", "cdc", "client",
 		"monitor":
 		return
 	}
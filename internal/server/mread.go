@@ -0,0 +1,153 @@
+package server
+
+// MREAD runs several searches against a single consistent view of the keys,
+// such as a NEARBY of vehicles and a SCAN of zones that a dashboard joins.
+// The keys are copied while no writer is running, and the searches read the
+// copies without blocking the writers. The copy of a key is the snapshot of
+// snapshot.go when it's current.
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+)
+
+// mreadSep separates the searches of MREAD.
+const mreadSep = ";"
+
+// isMreadCommand returns true for the commands that MREAD runs.
+func isMreadCommand(cmd string) bool {
+	switch cmd {
+	case "scan", "search", "nearby", "within", "intersects":
+		return true
+	}
+	return false
+}
+
+// cmdMread runs searches against a consistent copy of their keys. The reply
+// has the reply of each search, which is an error for a search that failed.
+//
+//	MREAD command [arg ...] [; command [arg ...] ...]
+func (server *Server) cmdMread(msg *Message, client *Client) (res resp.Value, err error) {
+	start := time.Now()
+	var reads [][]string
+	var args []string
+	for _, arg := range append(msg.Args[1:], mreadSep) {
+		if arg != mreadSep {
+			args = append(args, arg)
+			continue
+		}
+		if len(args) == 0 {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		if !isMreadCommand(strings.ToLower(args[0])) {
+			return NOMessage, errInvalidArgument(args[0])
+		}
+		if len(args) < 2 {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		for _, arg := range args[2:] {
+			if lc(arg, "fence") {
+				return NOMessage, errors.New("FENCE is not allowed in MREAD")
+			}
+		}
+		// a GET area would read the live key rather than the copy
+		if readsOtherKeys(args[2:]) {
+			return NOMessage, errors.New("GET is not allowed in MREAD")
+		}
+		reads = append(reads, args)
+		args = nil
+	}
+
+	// The searches hold the server lock for reading but no key lock, like
+	// the snapshot reads. The writers are only held off for the copies of
+	// the objects, and the copies are built once they're released.
+	server.wmu.RLock()
+	server.mu.RLock()
+	snaps, copies := server.mreadSnapshots(reads)
+	server.wmu.RUnlock()
+	defer server.mu.RUnlock()
+	for key, cp := range copies {
+		snaps[key] = cp.Build()
+	}
+
+	vals := make([]resp.Value, len(reads))
+	for i, args := range reads {
+		rmsg := &Message{
+			Args:       args,
+			ConnType:   msg.ConnType,
+			OutputType: msg.OutputType,
+			snapshots:  snaps,
+			role:       msg.role,
+			Deadline:   msg.Deadline,
+		}
+		var val resp.Value
+		val, _, err = server.command(rmsg, client)
+		if err != nil {
+			switch msg.OutputType {
+			case JSON:
+				val = resp.StringValue(`{"ok":false,"err":` +
+					string(appendJSONString(nil, err.Error())) + `}`)
+			case RESP:
+				val = resp.ErrorValue(err)
+			}
+			err = nil
+		}
+		vals[i] = val
+	}
+	switch msg.OutputType {
+	case JSON:
+		var buf []byte
+		buf = append(buf, `{"ok":true,"results":[`...)
+		for i, val := range vals {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, val.String()...)
+		}
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+`"}`...)
+		res = resp.StringValue(string(buf))
+	case RESP:
+		res = resp.ArrayValue(vals)
+	}
+	return
+}
+
+// mreadSnapshots copies the keys of the searches, which are the current
+// snapshots of the keys, or the copies of the keys that are then built into
+// snapshots. The server.wmu and server.mu locks must be held for reading, so
+// that no writer runs while the keys are copied, which makes the copies
+// consistent with each other.
+func (server *Server) mreadSnapshots(reads [][]string) (
+	snaps map[string]*collection.Collection,
+	copies map[string]*collection.SnapshotCopy,
+) {
+	snaps = make(map[string]*collection.Collection)
+	copies = make(map[string]*collection.SnapshotCopy)
+	for _, args := range reads {
+		key := args[1]
+		if _, ok := snaps[key]; ok {
+			continue
+		}
+		if _, ok := copies[key]; ok {
+			continue
+		}
+		c := server.getColContainer(key)
+		if c == nil {
+			snaps[key] = nil
+			continue
+		}
+		c.touch()
+		c.mu.RLock()
+		if snap := c.loadSnapshot(); snap != nil && snap.Version() == c.col.Version() {
+			snaps[key] = snap
+		} else {
+			copies[key] = c.col.SnapshotCopy()
+		}
+		c.mu.RUnlock()
+	}
+	return snaps, copies
+}
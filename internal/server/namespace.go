@@ -0,0 +1,501 @@
+package server
+
+// Namespaces: a namespace is a logical database with its own keys and hooks,
+// and optional quotas on its memory, objects and hooks. A client that
+// authenticates with AUTH namespace password is bound to the namespace, and
+// other clients can switch to one with SELECT. The keys and hook names of a
+// namespace are stored with a "namespace:" prefix, which is added to the
+// commands of the clients in the namespace before they run, so the AOF and
+// the followers get the stored names. The prefix is removed from the keys,
+// hooks and fence messages that the clients of the namespace get back.
+// Channels, scripts and the server commands aren't allowed in a namespace.
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/sjson"
+)
+
+// defaultNamespace is the SELECT name for leaving a namespace.
+const defaultNamespace = "default"
+
+var errNotInNamespace = errors.New("not allowed in a namespace")
+
+func errNamespaceNotFound(name string) error {
+	return errors.New("namespace not found: " + name)
+}
+
+func errNamespaceQuota(what string) error {
+	return errors.New("namespace " + what + " quota exceeded")
+}
+
+// namespace is the NSSET of a namespace.
+type namespace struct {
+	password   string // for AUTH namespace password, optional
+	maxMemory  int64  // bytes
+	maxObjects int
+	maxHooks   int
+}
+
+// namespaceUsage is what a namespace uses, for NSINFO and the quotas.
+type namespaceUsage struct {
+	keys    int
+	objects int
+	strings int
+	points  int
+	memory  int64
+	hooks   int
+}
+
+// validNamespace returns true when a name can be a namespace. The names
+// can't have the ':' of the prefix or the characters of glob patterns.
+func validNamespace(name string) bool {
+	if name == "" || name == defaultNamespace {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') &&
+			!(c >= '0' && c <= '9') && c != '_' && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// cmdNSSet creates a namespace, or replaces its settings.
+//
+//	NSSET name [PASSWORD password] [MAXMEMORY size] [MAXOBJECTS n]
+//	           [MAXHOOKS n]
+func (server *Server) cmdNSSet(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var name string
+	if vs, name, ok = tokenval(vs); !ok || name == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if !validNamespace(name) {
+		err = errInvalidArgument(name)
+		return
+	}
+	var ns namespace
+	for len(vs) > 0 {
+		var arg, val string
+		vs, arg, _ = tokenval(vs)
+		if vs, val, ok = tokenval(vs); !ok || val == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		switch {
+		case lc(arg, "password"):
+			ns.password = val
+		case lc(arg, "maxmemory"):
+			if ns.maxMemory, ok = parseMemSize(val); !ok {
+				err = errInvalidArgument(val)
+				return
+			}
+		case lc(arg, "maxobjects"), lc(arg, "maxhooks"):
+			n, perr := strconv.Atoi(val)
+			if perr != nil || n < 0 {
+				err = errInvalidArgument(val)
+				return
+			}
+			if lc(arg, "maxobjects") {
+				ns.maxObjects = n
+			} else {
+				ns.maxHooks = n
+			}
+		default:
+			err = errInvalidArgument(arg)
+			return
+		}
+	}
+	server.namespaces[name] = ns
+	d.command = "nsset"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// cmdNSDel removes a namespace. Its keys and hooks aren't removed, and the
+// clients that are bound to it stay in it.
+//
+//	NSDEL name
+func (server *Server) cmdNSDel(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var name string
+	if vs, name, ok = tokenval(vs); !ok || name == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if len(vs) != 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var n int
+	if _, ok := server.namespaces[name]; ok {
+		delete(server.namespaces, name)
+		n = 1
+	}
+	d.command = "nsdel"
+	d.updated = n > 0
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
+
+// cmdNSInfo returns the settings and usage of namespaces. The clients in a
+// namespace only get their own.
+//
+//	NSINFO [name ...]
+func (server *Server) cmdNSInfo(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	names := msg.Args[1:]
+	if msg.namespace != "" {
+		for _, name := range names {
+			if name != msg.namespace {
+				return NOMessage, errNotInNamespace
+			}
+		}
+		names = []string{msg.namespace}
+	} else if len(names) == 0 {
+		for name := range server.namespaces {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	var ms []map[string]interface{}
+	var vals []resp.Value
+	for _, name := range names {
+		ns, ok := server.namespaces[name]
+		if !ok {
+			return NOMessage, errNamespaceNotFound(name)
+		}
+		u := server.namespaceUsage(name)
+		m := map[string]interface{}{
+			"name":            name,
+			"num_collections": u.keys,
+			"num_objects":     u.objects,
+			"num_strings":     u.strings,
+			"num_points":      u.points,
+			"in_memory_size":  u.memory,
+			"num_hooks":       u.hooks,
+			"max_memory":      ns.maxMemory,
+			"max_objects":     ns.maxObjects,
+			"max_hooks":       ns.maxHooks,
+		}
+		switch msg.OutputType {
+		case JSON:
+			ms = append(ms, m)
+		case RESP:
+			vals = append(vals, resp.ArrayValue(respValuesSimpleMap(m)))
+		}
+	}
+	switch msg.OutputType {
+	case JSON:
+		data, err := json.Marshal(ms)
+		if err != nil {
+			return NOMessage, err
+		}
+		res = resp.StringValue(`{"ok":true,"namespaces":` + string(data) +
+			`,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.ArrayValue(vals)
+	}
+	return res, nil
+}
+
+// cmdSelect switches the namespace of a client, and SELECT default leaves
+// it. The clients that are bound to a namespace can't switch.
+//
+//	SELECT name
+func (server *Server) cmdSelect(msg *Message, client *Client) (res resp.Value, err error) {
+	start := time.Now()
+	if len(msg.Args) != 2 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if client.nsBound {
+		return NOMessage, errNotInNamespace
+	}
+	name := msg.Args[1]
+	if name == defaultNamespace {
+		name = ""
+	} else if _, ok := server.namespaces[name]; !ok {
+		return NOMessage, errNamespaceNotFound(name)
+	}
+	client.namespace = name
+	return OKMessage(msg, start), nil
+}
+
+// namespaceAuth binds a client to a namespace when the password is the
+// password of the namespace.
+func (server *Server) namespaceAuth(client *Client, name, password string) bool {
+	server.mu.RLock()
+	ns, ok := server.namespaces[name]
+	server.mu.RUnlock()
+	if !ok || ns.password == "" || ns.password != password {
+		return false
+	}
+	client.namespace = name
+	client.nsBound = true
+	client.authd = true
+	return true
+}
+
+// namespaceOf returns the namespace of a key or hook name, or an empty
+// string when it's not in one.
+func (server *Server) namespaceOf(name string) string {
+	i := strings.IndexByte(name, ':')
+	if i == -1 {
+		return ""
+	}
+	if _, ok := server.namespaces[name[:i]]; !ok {
+		return ""
+	}
+	return name[:i]
+}
+
+// namespaceUsage returns what a namespace uses. The server.mu lock must be
+// held.
+func (server *Server) namespaceUsage(name string) namespaceUsage {
+	var u namespaceUsage
+	prefix := name + ":"
+	server.cols.Ascend(&collectionKeyContainer{key: prefix},
+		func(v interface{}) bool {
+			c := v.(*collectionKeyContainer)
+			if !strings.HasPrefix(c.key, prefix) {
+				return false
+			}
+			u.keys++
+			u.objects += c.col.Count()
+			u.strings += c.col.StringCount()
+			u.points += c.col.PointCount()
+			u.memory += int64(c.col.TotalWeight())
+			return true
+		})
+	for hookName, hook := range server.hooks {
+		if !hook.channel && strings.HasPrefix(hookName, prefix) {
+			u.hooks++
+		}
+	}
+	return u
+}
+
+// nsLocal returns a key or hook name without the namespace of the message.
+func (msg *Message) nsLocal(name string) string {
+	if msg.namespace == "" {
+		return name
+	}
+	return strings.TrimPrefix(name, msg.namespace+":")
+}
+
+// namespaceArgs adds the namespace of a client to the keys and hook names
+// of a command, or returns an error when the command isn't allowed in a
+// namespace.
+func namespaceArgs(ns string, msg *Message) error {
+	prefix := ns + ":"
+	args := msg.Args
+	switch msg.Command() {
+	case "set", "get", "del", "pdel", "drop", "fset", "mset", "mfset",
+		"expire", "persist", "ttl", "pttl", "touch", "pexpire", "expireat",
+		"pexpireat", "jget", "jset", "jdel", "jincrby", "jdecrby", "jappend",
+		"jinsert", "fincrby", "fdecrby", "fmin", "fmax", "bounds", "type",
+		"scan", "search", "density", "ttlconfig", "orderconfig",
+		"historyconfig", "fleetconfig", "fleetstats", "writebehind",
+		"writebehindstats", "readthrough", "readthroughstats", "areaconfig",
+		"areadel", "tag", "untag", "tags",
+		"setmeta", "getmeta", "undelete", "tombstones", "mget", "sfset",
+		"sfget", "schemaset", "schemaget", "schemadel", "trackconfig", "track",
+		"trackdel", "trajectory", "keys", "delhook", "pdelhook", "hooks",
+		"notify", "occupancy":
+		if len(args) > 1 {
+			args[1] = prefix + args[1]
+		}
+	case "nearby", "within", "intersects", "passed":
+		if len(args) > 1 {
+			args[1] = prefix + args[1]
+			namespaceAreaArgs(prefix, args[2:])
+		}
+	case "keyconfig":
+		if len(args) > 2 {
+			args[2] = prefix + args[2]
+		}
+	case "stats", "rename", "renamenx":
+		for i := 1; i < len(args); i++ {
+			args[i] = prefix + args[i]
+		}
+	case "sethook":
+		if len(args) > 1 {
+			args[1] = prefix + args[1]
+		}
+		for i := 3; i < len(args)-1; i++ {
+			if lc(args[i], "nearby") || lc(args[i], "within") ||
+				lc(args[i], "intersects") {
+				args[i+1] = prefix + args[i+1]
+				namespaceAreaArgs(prefix, args[i+2:])
+				break
+			}
+		}
+	case "delwhere":
+		if i := bulkSearch(args, 1); i > 0 && i < len(args)-1 {
+			args[i+1] = prefix + args[i+1]
+			namespaceAreaArgs(prefix, args[i+2:])
+		}
+	case "copy", "move":
+		for i := 1; i < len(args) && i < 3; i++ {
+			args[i] = prefix + args[i]
+		}
+		if i := bulkSearch(args, 3); i > 0 {
+			namespaceAreaArgs(prefix, args[i+1:])
+		}
+	case "ping", "echo", "output", "quit", "select", "nsinfo", "geohash",
+		"layers", "lookup":
+	default:
+		return errNotInNamespace
+	}
+	msg.namespace = ns
+	return nil
+}
+
+// namespaceAreaArgs adds the namespace to the keys of the GET areas and the
+// ROAM options of a search.
+func namespaceAreaArgs(prefix string, args []string) {
+	for i := 0; i < len(args)-1; i++ {
+		if lc(args[i], "get") || lc(args[i], "roam") {
+			args[i+1] = prefix + args[i+1]
+			i++
+		}
+	}
+}
+
+// namespaceMessages removes the namespace from the keys and hook names of
+// fence messages.
+func namespaceMessages(ns string, msgs []string) []string {
+	prefix := ns + ":"
+	for i, msg := range msgs {
+		for _, path := range []string{"key", "hook", "nearby.key", "faraway.key"} {
+			val := gjson.Get(msg, path)
+			if val.Type == gjson.String && strings.HasPrefix(val.Str, prefix) {
+				msg, _ = sjson.Set(msg, path, val.Str[len(prefix):])
+			}
+		}
+		msgs[i] = msg
+	}
+	return msgs
+}
+
+// hasNamespaceQuotas returns true when a namespace has a quota.
+func (server *Server) hasNamespaceQuotas(namespace string) bool {
+	ns, ok := server.namespaces[namespace]
+	return ok && (ns.maxMemory > 0 || ns.maxObjects > 0 || ns.maxHooks > 0)
+}
+
+// checkNamespaceQuotas returns an error when a write of a client in a
+// namespace is over a quota of the namespace. The writes that only change
+// or remove objects are always allowed.
+func (server *Server) checkNamespaceQuotas(msg *Message) error {
+	if !server.hasNamespaceQuotas(msg.namespace) {
+		return nil
+	}
+	ns := server.namespaces[msg.namespace]
+	cmd := msg.Command()
+	var adds, hook bool
+	switch cmd {
+	case "set", "mset", "fset", "mfset", "jset", "jincrby", "jdecrby",
+		"jappend", "jinsert", "track":
+		adds = true
+	case "sethook":
+		_, exists := server.hooks[msg.Args[1]]
+		hook = !exists
+	}
+	if !adds && !hook {
+		return nil
+	}
+	u := server.namespaceUsage(msg.namespace)
+	if hook {
+		if ns.maxHooks > 0 && u.hooks >= ns.maxHooks {
+			return errNamespaceQuota("hook")
+		}
+		return nil
+	}
+	if ns.maxMemory > 0 && u.memory >= ns.maxMemory {
+		return errNamespaceQuota("memory")
+	}
+	if ns.maxObjects > 0 {
+		var ids []string
+		switch cmd {
+		case "set", "jset", "jincrby", "jdecrby", "jappend", "jinsert":
+			if len(msg.Args) > 2 {
+				ids = msg.Args[2:3]
+			}
+		case "mset":
+			for i := 2; i < len(msg.Args)-1; i++ {
+				if lc(msg.Args[i], "id") {
+					ids = append(ids, msg.Args[i+1])
+					i++
+				}
+			}
+		}
+		var n int
+		col := server.getCol(msg.Args[1])
+		for _, id := range ids {
+			if col == nil {
+				n++
+			} else if _, _, _, ok := col.Get(id); !ok {
+				n++
+			}
+		}
+		if n > 0 && u.objects+n > ns.maxObjects {
+			return errNamespaceQuota("object")
+		}
+	}
+	return nil
+}
+
+// namespaceCommands returns the commands that rebuild the namespaces, for
+// aofshrink.
+func (server *Server) namespaceCommands() [][]string {
+	names := make([]string, 0, len(server.namespaces))
+	for name := range server.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cmds := make([][]string, 0, len(names))
+	for _, name := range names {
+		ns := server.namespaces[name]
+		cmd := []string{"nsset", name}
+		if ns.password != "" {
+			cmd = append(cmd, "PASSWORD", ns.password)
+		}
+		if ns.maxMemory > 0 {
+			cmd = append(cmd, "MAXMEMORY", strconv.FormatInt(ns.maxMemory, 10))
+		}
+		if ns.maxObjects > 0 {
+			cmd = append(cmd, "MAXOBJECTS", strconv.Itoa(ns.maxObjects))
+		}
+		if ns.maxHooks > 0 {
+			cmd = append(cmd, "MAXHOOKS", strconv.Itoa(ns.maxHooks))
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/simplify"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// ndjsonWriter streams search results to an HTTP client as newline delimited
+// GeoJSON Features. The records are buffered, and written to the connection
+// by their own goroutine, so that the client can start processing before the
+// search completes, and a slow client doesn't hold the locks of the search.
+// The response header is written lazily, which allows for argument errors to
+// still be returned as a regular JSON response.
+type ndjsonWriter struct {
+	wr      io.Writer
+	active  bool // the search is streaming its results
+	started bool // the response header has been written
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte        // records that are waiting for the connection
+	closed bool          // no more records
+	err    error         // of the connection
+	done   chan struct{} // closed when the goroutine is done
+}
+
+func (w *ndjsonWriter) writeHeader() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	w.cond = sync.NewCond(&w.mu)
+	w.done = make(chan struct{})
+	go w.run()
+	return w.write([]byte("HTTP/1.1 200 OK\r\n" +
+		"Connection: close\r\n" +
+		"Content-Type: " + ndjsonContentType + "\r\n" +
+		"\r\n"))
+}
+
+// writeRecord adds a single line to the stream. It returns the error of the
+// connection, when a previous write failed.
+func (w *ndjsonWriter) writeRecord(line []byte) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.write(append(line, '\n'))
+}
+
+func (w *ndjsonWriter) write(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.buf = append(w.buf, data...)
+	w.cond.Signal()
+	return nil
+}
+
+// run writes the buffered records to the connection until the writer is
+// closed.
+func (w *ndjsonWriter) run() {
+	defer close(w.done)
+	var data []byte
+	for {
+		w.mu.Lock()
+		for len(w.buf) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.buf) == 0 {
+			w.mu.Unlock()
+			return
+		}
+		data, w.buf = w.buf, data[:0]
+		w.mu.Unlock()
+		if _, err := w.wr.Write(data); err != nil {
+			w.mu.Lock()
+			w.err = err
+			w.buf = nil
+			w.mu.Unlock()
+			return
+		}
+	}
+}
+
+// close returns when all of the records are written to the connection. It's
+// called once the locks of the search are released.
+func (w *ndjsonWriter) close() error {
+	if !w.started {
+		return nil
+	}
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Signal()
+	w.mu.Unlock()
+	<-w.done
+	return w.err
+}
+
+// sjsonEscape escapes the characters of a name which are path syntax for
+// sjson, such as the dot of "speed.kph".
+func sjsonEscape(name string) string {
+	var esc []byte
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '.', '*', '?', '|', '#', '@', '\\', ':', '!':
+			if esc == nil {
+				esc = append(make([]byte, 0, len(name)+1), name[:i]...)
+			}
+			esc = append(esc, '\\')
+		}
+		if esc != nil {
+			esc = append(esc, name[i])
+		}
+	}
+	if esc == nil {
+		return name
+	}
+	return string(esc)
+}
+
+// isNDJSONCommand returns true for the commands that can stream.
+func isNDJSONCommand(cmd string) bool {
+	switch cmd {
+	case "scan", "search", "nearby", "within", "intersects":
+		return true
+	}
+	return false
+}
+
+// appendNDJSONFeature appends the object as a single line GeoJSON Feature.
+// The field values, in the same order as fnames, are merged into the
+// Feature properties.
+func appendNDJSONFeature(dst []byte, id string, o geojson.Object,
+	fnames []string, fvals []float64,
+	distance float64, distOutput bool, reshape simplify.Options,
+) []byte {
+	props := "{}"
+	base := o
+	if f, ok := o.(*geojson.Feature); ok {
+		base = f.Base()
+		if p := gjson.Get(f.Members(), "properties"); p.IsObject() {
+			props = p.Raw
+		}
+	}
+	for i, name := range fnames {
+		props, _ = sjson.SetRaw(props, sjsonEscape(name),
+			strconv.FormatFloat(fvals[i], 'f', -1, 64))
+	}
+	if distOutput || distance > 0 {
+		props, _ = sjson.SetRaw(props, "distance",
+			strconv.FormatFloat(distance, 'f', -1, 64))
+	}
+	dst = append(dst, `{"type":"Feature","id":`...)
+	dst = appendJSONString(dst, id)
+	dst = append(dst, `,"geometry":`...)
+	if _, ok := base.(collection.String); ok {
+		dst = append(dst, "null"...)
+		props, _ = sjson.Set(props, "value", base.String())
+	} else {
+		dst = simplify.AppendJSON(dst, base.AppendJSON(nil), reshape)
+	}
+	dst = append(dst, `,"properties":`...)
+	dst = append(dst, props...)
+	dst = append(dst, '}')
+	return dst
+}
@@ -0,0 +1,94 @@
+package server
+
+// NOTIFY: an application message that's sent to hooks and channels like the
+// events of their geofences, with the sequences, the retries of the hook
+// queue, and the fan-out to the endpoints of the hooks. It's not written to
+// the AOF, and so the followers don't send it again.
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/glob"
+)
+
+// cmdNotify sends a message to the hooks and channels that match a pattern,
+// and returns how many there were.
+//
+//	NOTIFY pattern type message
+//
+// The message is JSON, and it's sent as the "data" of a "notify" event of
+// the type.
+func (s *Server) cmdNotify(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var pattern, typ, data string
+	if vs, pattern, ok = tokenval(vs); !ok || pattern == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, typ, ok = tokenval(vs); !ok || typ == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, data, ok = tokenval(vs); !ok || len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if !gjson.Valid(data) {
+		return NOMessage, errInvalidArgument(data)
+	}
+	var hooks []*Hook
+	for name, hook := range s.hooks {
+		if match, _ := glob.Match(pattern, name); match {
+			hooks = append(hooks, hook)
+		}
+	}
+	sort.Sort(hooksByName(hooks))
+	now := time.Now()
+	var wmsgs []string
+	var whooks []*Hook
+	for _, hook := range hooks {
+		m := notifyMessage(hook, typ, data, now)
+		if ns := s.namespaceOf(hook.Name); ns != "" {
+			m = namespaceMessages(ns, []string{m})[0]
+		}
+		m = sequenceMessages(hook, []string{m})[0]
+		if hook.channel {
+			s.Publish(hook.Name, m)
+		} else {
+			wmsgs = append(wmsgs, m)
+			whooks = append(whooks, hook)
+		}
+	}
+	s.markHookSeqs(hooks...)
+	if err := s.queueHookMessages(wmsgs); err != nil {
+		return NOMessage, err
+	}
+	for _, hook := range whooks {
+		hook.Signal()
+	}
+	switch msg.OutputType {
+	case JSON:
+		return resp.StringValue(`{"ok":true,"notified":` +
+			strconv.Itoa(len(hooks)) + `,"elapsed":"` +
+			time.Since(start).String() + `"}`), nil
+	case RESP:
+		return resp.IntegerValue(len(hooks)), nil
+	}
+	return NOMessage, nil
+}
+
+// notifyMessage returns the "notify" event of a message for a hook.
+func notifyMessage(hook *Hook, typ, data string, now time.Time) string {
+	var buf []byte
+	buf = append(buf, `{"command":"notify","type":`...)
+	buf = appendJSONString(buf, typ)
+	buf = appendHookDetails(buf, hook.Name, hook.Metas)
+	buf = appendJSONString(append(buf, `,"key":`...), hook.Key)
+	buf = appendJSONTimeFormat(append(buf, `,"time":`...), now)
+	buf = append(append(buf, `,"data":`...), data...)
+	buf = append(buf, '}')
+	return string(buf)
+}
@@ -0,0 +1,197 @@
+package server
+
+// Occupancy: a hook or channel with OCCUPANCY counts the objects that are
+// inside of its fence, and the objects that entered and exited the fence in
+// the last minutes, so that "how many vehicles are in zone A right now"
+// doesn't need a consumer that rebuilds the state from the events. The
+// counts are returned by OCCUPANCY, and exported as metrics.
+//
+//	SETHOOK name endpoint OCCUPANCY minutes NEARBY|WITHIN|INTERSECTS ...
+//	OCCUPANCY pattern
+//
+// The counts are kept by the leader, which is the one that detects the
+// events, and they start again from the objects that are inside of the
+// fence when the hook is set again. The entered and exited counts are kept
+// by the minute, and so the window moves a minute at a time.
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/glob"
+)
+
+// occupancyMinute are the objects that entered and exited a fence in a
+// minute.
+type occupancyMinute struct {
+	minute          int64 // since the epoch
+	entered, exited int
+}
+
+// occupancy are the counts of the fence of a hook with OCCUPANCY.
+type occupancy struct {
+	mu      sync.Mutex
+	window  time.Duration
+	inside  map[string]bool   // ids of the objects inside of the fence
+	minutes []occupancyMinute // ring of the minutes of the window
+}
+
+func newOccupancy(window time.Duration) *occupancy {
+	n := int(math.Ceil(float64(window) / float64(time.Minute)))
+	return &occupancy{
+		window:  window,
+		inside:  make(map[string]bool),
+		minutes: make([]occupancyMinute, n),
+	}
+}
+
+// at returns the counts of the minute of a time.
+func (o *occupancy) at(now time.Time) *occupancyMinute {
+	minute := now.Unix() / 60
+	m := &o.minutes[minute%int64(len(o.minutes))]
+	if m.minute != minute {
+		*m = occupancyMinute{minute: minute}
+	}
+	return m
+}
+
+// update counts an object that's inside or outside of the fence after a
+// write.
+func (o *occupancy) update(id string, inside bool, now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	switch {
+	case inside && !o.inside[id]:
+		o.inside[id] = true
+		o.at(now).entered++
+	case !inside && o.inside[id]:
+		delete(o.inside, id)
+		o.at(now).exited++
+	}
+}
+
+// exitAll counts all of the objects as exited, for a key that's dropped.
+func (o *occupancy) exitAll(now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.at(now).exited += len(o.inside)
+	o.inside = make(map[string]bool)
+}
+
+// counts returns the objects inside of the fence, and the objects that
+// entered and exited it in the window.
+func (o *occupancy) counts(now time.Time) (inside, entered, exited int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	since := now.Unix()/60 - int64(len(o.minutes))
+	for _, m := range o.minutes {
+		if m.minute > since {
+			entered += m.entered
+			exited += m.exited
+		}
+	}
+	return len(o.inside), entered, exited
+}
+
+// watchOccupancy counts an object of the key of a hook that was written.
+func (s *Server) watchOccupancy(hook *Hook, d *commandDetails) {
+	now := time.Now()
+	if d.command == "drop" {
+		if d.key == hook.Key {
+			hook.occupancy.exitAll(now)
+		}
+		return
+	}
+	if d.key != hook.Key || d.id == "" {
+		return
+	}
+	inside := d.command != "del" && inactiveMatch(hook, d.id, d.obj)
+	hook.occupancy.update(d.id, inside, now)
+}
+
+// seedOccupancy counts the objects that are inside of the fence of a new
+// hook, which didn't enter it.
+func (s *Server) seedOccupancy(hook *Hook) {
+	col := s.getCol(hook.Key)
+	if col == nil {
+		return
+	}
+	o := hook.occupancy
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	col.Scan(false, nil, nil, func(id string, obj geojson.Object, fields []float64) bool {
+		if inactiveMatch(hook, id, obj) {
+			o.inside[id] = true
+		}
+		return true
+	})
+}
+
+// cmdOccupancy returns the counts of the hooks and channels with OCCUPANCY
+// that match a pattern.
+//
+//	OCCUPANCY pattern
+func (s *Server) cmdOccupancy(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var pattern string
+	var ok bool
+	if vs, pattern, ok = tokenval(vs); !ok || pattern == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var hooks []*Hook
+	for name, hook := range s.hooks {
+		if hook.occupancy == nil {
+			continue
+		}
+		if match, _ := glob.Match(pattern, name); match {
+			hooks = append(hooks, hook)
+		}
+	}
+	sort.Sort(hooksByName(hooks))
+
+	now := time.Now()
+	switch msg.OutputType {
+	case JSON:
+		buf := &bytes.Buffer{}
+		buf.WriteString(`{"ok":true,"occupancy":[`)
+		for i, hook := range hooks {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			inside, entered, exited := hook.occupancy.counts(now)
+			buf.WriteString(`{"name":` + jsonString(msg.nsLocal(hook.Name)))
+			buf.WriteString(`,"key":` + jsonString(msg.nsLocal(hook.Key)))
+			buf.WriteString(`,"inside":` + strconv.Itoa(inside))
+			buf.WriteString(`,"entered":` + strconv.Itoa(entered))
+			buf.WriteString(`,"exited":` + strconv.Itoa(exited))
+			buf.WriteString(`,"minutes":` + strconv.FormatFloat(
+				hook.occupancy.window.Minutes(), 'f', -1, 64))
+			buf.WriteByte('}')
+		}
+		buf.WriteString(`],"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		vals := make([]resp.Value, 0, len(hooks))
+		for _, hook := range hooks {
+			inside, entered, exited := hook.occupancy.counts(now)
+			vals = append(vals, resp.ArrayValue([]resp.Value{
+				resp.StringValue(msg.nsLocal(hook.Name)),
+				resp.IntegerValue(inside),
+				resp.IntegerValue(entered),
+				resp.IntegerValue(exited),
+			}))
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
@@ -0,0 +1,144 @@
+package server
+
+// Out-of-order updates: a key that has an ORDERCONFIG has a timestamp field,
+// and a SET that carries an older timestamp than the current object is
+// rejected, or with MERGE only sets the fields that the object doesn't have.
+// Devices often send their reports over several connections, which makes
+// the reports arrive in any order.
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+)
+
+var errOlderTimestamp = errors.New("timestamp is older than the object")
+
+// orderConfig is the ORDERCONFIG of a key.
+type orderConfig struct {
+	field string
+	merge bool // older updates set the missing fields
+}
+
+// cmdOrderConfig sets the timestamp field of a key. Without a field the
+// config is removed.
+//
+//	ORDERCONFIG key [FIELD name [MERGE]]
+func (server *Server) cmdOrderConfig(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var conf orderConfig
+	for len(vs) > 0 {
+		var arg string
+		vs, arg, _ = tokenval(vs)
+		switch {
+		case lc(arg, "field") && conf.field == "":
+			if vs, conf.field, ok = tokenval(vs); !ok || conf.field == "" {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			if isReservedFieldName(conf.field) {
+				err = errInvalidArgument(conf.field)
+				return
+			}
+		case lc(arg, "merge") && conf.field != "":
+			conf.merge = true
+		default:
+			err = errInvalidArgument(arg)
+			return
+		}
+	}
+	if conf.field == "" {
+		delete(server.orderConfigs, d.key)
+	} else {
+		server.orderConfigs[d.key] = conf
+	}
+	d.command = "orderconfig"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// outOfOrder returns true when an update of an object carries an older
+// timestamp than the object. Updates without the timestamp field and new
+// objects are always in order.
+func (server *Server) outOfOrder(key string, col *collection.Collection,
+	id string, fields []string, values []float64,
+) (conf orderConfig, older bool) {
+	conf, ok := server.orderConfigs[key]
+	if !ok || col == nil {
+		return conf, false
+	}
+	ts := -1
+	for i, field := range fields {
+		if field == conf.field {
+			ts = i
+		}
+	}
+	if ts == -1 {
+		return conf, false
+	}
+	_, cur, _, ok := col.Get(id)
+	if !ok {
+		return conf, false
+	}
+	var value float64
+	if idx, ok := col.FieldMap()[conf.field]; ok && idx < len(cur) {
+		value = cur[idx]
+	}
+	return conf, values[ts] < value
+}
+
+// mergeMissingFields sets the fields of an out of order update that the
+// object doesn't have, and returns the number of fields that were set.
+func mergeMissingFields(col *collection.Collection, d *commandDetails,
+	fields []string, values []float64,
+) int {
+	_, cur, _, _ := col.Get(d.id)
+	fmap := col.FieldMap()
+	var mfields []string
+	var mvalues []float64
+	for i, field := range fields {
+		if idx, ok := fmap[field]; ok && idx < len(cur) && cur[idx] != 0 {
+			continue
+		}
+		mfields = append(mfields, field)
+		mvalues = append(mvalues, values[i])
+	}
+	if len(mfields) == 0 {
+		return 0
+	}
+	var n int
+	d.obj, d.fields, n, _ = col.SetFields(d.id, mfields, mvalues)
+	return n
+}
+
+// orderCommands returns the commands that rebuild the ORDERCONFIG of the
+// keys, for aofshrink.
+func (server *Server) orderCommands() [][]string {
+	keys := make([]string, 0, len(server.orderConfigs))
+	for key := range server.orderConfigs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmds := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		conf := server.orderConfigs[key]
+		cmd := []string{"orderconfig", key, "FIELD", conf.field}
+		if conf.merge {
+			cmd = append(cmd, "MERGE")
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
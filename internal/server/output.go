@@ -14,19 +14,38 @@ func (s *Server) cmdOutput(msg *Message) (res resp.Value, err error) {
 	var ok bool
 
 	if len(vs) != 0 {
-		if _, arg, ok = tokenval(vs); !ok || arg == "" {
+		if vs, arg, ok = tokenval(vs); !ok || arg == "" {
 			return NOMessage, errInvalidNumberOfArguments
 		}
-		// Setting the original message output type will be picked up by the
-		// server prior to the next command being executed.
+		var outputType Type
 		switch strings.ToLower(arg) {
 		default:
 			return NOMessage, errInvalidArgument(arg)
 		case "json":
-			msg.OutputType = JSON
+			outputType = JSON
 		case "resp":
-			msg.OutputType = RESP
+			outputType = RESP
 		}
+		compress := msg.compress
+		if len(vs) != 0 {
+			// OUTPUT json|resp COMPRESS none|gzip|zstd
+			if vs, arg, ok = tokenval(vs); !ok || strings.ToLower(arg) != "compress" {
+				return NOMessage, errInvalidArgument(arg)
+			}
+			if vs, arg, ok = tokenval(vs); !ok || arg == "" {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+			if compress, ok = parseCompression(arg); !ok {
+				return NOMessage, errInvalidArgument(arg)
+			}
+			if len(vs) != 0 {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+		}
+		// Setting the original message output type and compression will be
+		// picked up by the server prior to the next command being executed.
+		msg.OutputType = outputType
+		msg.compress = compress
 		return OKMessage(msg, start), nil
 	}
 	// return the output
@@ -0,0 +1,224 @@
+package server
+
+// Output limits: the replies of a client, and the messages of a subscriber
+// that are waiting to be written to its connection, are kept in memory, and
+// a client that doesn't read them makes them grow until the server runs out
+// of memory. The output-limit-normal and output-limit-pubsub config
+// properties limit them for the clients and for the subscribers:
+//
+//	CONFIG SET output-limit-normal "hard soft seconds"
+//	CONFIG SET output-limit-pubsub "hard soft seconds [policy]"
+//
+// The output is over the limit when it's over the hard size, or when it's
+// been over the soft size for the seconds. The sizes are bytes, or kb, mb
+// and gb, and zero is no limit. A client that's over the limit is
+// disconnected. A subscriber that's over the limit gets the policy:
+//
+//	disconnect      the subscriber is disconnected
+//	drop-oldest     the oldest messages that are waiting are dropped
+//	pause-producer  the publisher waits, up to outputPauseMax, for the
+//	                subscriber to read its messages, and then disconnects it
+//
+// pause-producer slows the writes that publish geofence messages, as they
+// wait for the subscribers, and it's for subscribers that can't lose
+// messages.
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/tile38/internal/log"
+)
+
+const (
+	defaultOutputLimitNormal = "0 0 0"
+	defaultOutputLimitPubsub = "32mb 8mb 60"
+)
+
+// The policies of the subscribers that are over the limit.
+const (
+	outputDisconnect    = "disconnect"
+	outputDropOldest    = "drop-oldest"
+	outputPauseProducer = "pause-producer"
+)
+
+// outputPauseMax is the longest that a publisher waits for a subscriber
+// with pause-producer.
+const outputPauseMax = time.Second
+
+var errOutputLimit = errors.New("output limit exceeded")
+
+// outputLimit is the output limit of a class of clients.
+type outputLimit struct {
+	hard, soft int64 // bytes
+	seconds    int64
+	policy     string
+}
+
+// parseOutputLimit parses "hard soft seconds", and the policy of the
+// subscribers.
+func parseOutputLimit(s string, pubsub bool) (outputLimit, bool) {
+	var l outputLimit
+	parts := strings.Fields(s)
+	if len(parts) != 3 && (!pubsub || len(parts) != 4) {
+		return l, false
+	}
+	var ok bool
+	if l.hard, ok = parseMemSize(parts[0]); !ok {
+		return l, false
+	}
+	if l.soft, ok = parseMemSize(parts[1]); !ok {
+		return l, false
+	}
+	n, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return l, false
+	}
+	l.seconds = int64(n)
+	l.policy = outputDisconnect
+	if len(parts) == 4 {
+		switch strings.ToLower(parts[3]) {
+		case outputDisconnect, outputDropOldest, outputPauseProducer:
+			l.policy = strings.ToLower(parts[3])
+		default:
+			return l, false
+		}
+	}
+	return l, true
+}
+
+func (l outputLimit) String() string {
+	s := formatOutputSize(l.hard) + " " + formatOutputSize(l.soft) + " " +
+		strconv.FormatInt(l.seconds, 10)
+	if l.policy != "" && l.policy != outputDisconnect {
+		s += " " + l.policy
+	}
+	return s
+}
+
+// formatOutputSize formats a size like the maxmemory property, with zero
+// for no limit.
+func formatOutputSize(sz int64) string {
+	if sz <= 0 {
+		return "0"
+	}
+	return formatMemSize(sz)
+}
+
+// over returns true when a size is over the limit. The since time is when
+// the size went over the soft size, which is kept by the caller.
+func (l outputLimit) over(size int64, since *time.Time, now time.Time) bool {
+	if l.hard > 0 && size > l.hard {
+		return true
+	}
+	if l.soft <= 0 || size <= l.soft {
+		*since = time.Time{}
+		return false
+	}
+	if since.IsZero() {
+		*since = now
+	}
+	return now.Sub(*since) >= time.Duration(l.seconds)*time.Second
+}
+
+// below returns the size that the drop-oldest policy drops the messages
+// down to.
+func (l outputLimit) below() int64 {
+	if l.soft > 0 {
+		return l.soft
+	}
+	return l.hard
+}
+
+// clientOverLimit returns true when the replies of a client are over the
+// output-limit-normal.
+func (server *Server) clientOverLimit(client *Client) bool {
+	limit, _ := server.config.outputLimits()
+	if limit.hard <= 0 && limit.soft <= 0 {
+		return false
+	}
+	if !limit.over(int64(len(client.out)), &client.outSince, time.Now()) {
+		return false
+	}
+	log.Warnf("%s: %v, %d bytes", client.remoteAddr, errOutputLimit,
+		len(client.out))
+	server.statsOutputClosed.add(1)
+	client.out = nil
+	return true
+}
+
+// size returns the size of a message in the output of a subscriber.
+func (msg submsg) size() int64 {
+	return int64(len(msg.message) + len(msg.channel) + len(msg.pattern))
+}
+
+// pushSubmsg queues a message for a subscriber, with the
+// output-limit-pubsub.
+func (server *Server) pushSubmsg(target *subtarget, msg submsg, limit outputLimit) {
+	n := msg.size()
+	target.cond.L.Lock()
+	defer target.cond.L.Unlock()
+	if target.closed {
+		return
+	}
+	if limit.policy == outputPauseProducer {
+		threshold := limit.below()
+		start := time.Now()
+		for threshold > 0 && target.size+n > threshold && !target.closed &&
+			time.Since(start) < outputPauseMax {
+			// the writer of the subscriber doesn't signal when it's done
+			// with a message
+			target.cond.L.Unlock()
+			time.Sleep(time.Millisecond)
+			target.cond.L.Lock()
+		}
+		if target.closed {
+			return
+		}
+	}
+	target.msgs = append(target.msgs, msg)
+	target.size += n
+	if !limit.over(target.size, &target.since, time.Now()) {
+		target.cond.Broadcast()
+		return
+	}
+	if limit.policy == outputDropOldest {
+		var dropped int
+		for len(target.msgs) > 0 && target.size > limit.below() {
+			m := target.msgs[0]
+			target.msgs = target.msgs[1:]
+			target.size -= m.size()
+			dropped++
+		}
+		target.since = time.Time{}
+		server.statsOutputDropped.add(dropped)
+		target.cond.Broadcast()
+		return
+	}
+	log.Warnf("%s: %v, %d bytes", target.addr(), errOutputLimit, target.size)
+	server.statsOutputClosed.add(1)
+	target.msgs = nil
+	target.closed = true
+	if target.conn != nil {
+		target.conn.Close()
+	}
+	target.cond.Broadcast()
+}
+
+// addr returns the address of the subscriber.
+func (target *subtarget) addr() string {
+	if target.conn == nil || target.conn.RemoteAddr() == nil {
+		return "subscriber"
+	}
+	return target.conn.RemoteAddr().String()
+}
+
+// written removes a message that's been written from the size of the
+// output of a subscriber.
+func (target *subtarget) written(msg submsg) {
+	target.cond.L.Lock()
+	target.size -= msg.size()
+	target.cond.L.Unlock()
+}
@@ -0,0 +1,205 @@
+package server
+
+// Write pauses: WRITES PAUSE stops the writes of the clients for a maintenance
+// window, such as copying the aof for a backup, without stopping the server.
+// The reads and the replication to the followers go on. The paused writes are
+// rejected with an error, or with QUEUE they wait until WRITES RESUME. The
+// background expirations and AOFSHRINK don't run while the writes are paused,
+// so the aof doesn't change at all.
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+const defaultPauseMessage = "writes paused"
+
+// writePause is a WRITES PAUSE.
+type writePause struct {
+	queue   bool          // writes wait for WRITES RESUME
+	timeout time.Duration // of the queued writes, zero waits forever
+	message string        // error of the rejected writes
+	since   time.Time
+}
+
+// writesPause returns the current pause, or nil.
+func (s *Server) writesPause() *writePause {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+	return s.pause
+}
+
+// writesPausedErr returns the error of the current pause, or nil. It's for
+// the writes that can't wait, because they hold the server locks.
+func (s *Server) writesPausedErr() error {
+	if p := s.writesPause(); p != nil {
+		return errors.New(p.message)
+	}
+	return nil
+}
+
+// waitWrites returns when the writes aren't paused. It must be called before
+// the write takes the server locks, so that the reads go on while it waits.
+// The error is for rejected writes and for queued writes that timed out.
+func (s *Server) waitWrites() error {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+	var timedOut bool
+	var timer *time.Timer
+	for {
+		p := s.pause
+		if p == nil {
+			return nil
+		}
+		if !p.queue || timedOut {
+			return errors.New(p.message)
+		}
+		if timer == nil && p.timeout > 0 {
+			timer = time.AfterFunc(p.timeout, func() {
+				s.pauseMu.Lock()
+				timedOut = true
+				s.pauseCond.Broadcast()
+				s.pauseMu.Unlock()
+			})
+			defer timer.Stop()
+		}
+		s.pauseQueued++
+		s.pauseCond.Wait()
+		s.pauseQueued--
+	}
+}
+
+// cmdWritesPause pauses the writes, replacing the pause that was running.
+//
+//	WRITES PAUSE [REJECT|QUEUE] [TIMEOUT seconds] [MESSAGE text]
+func (s *Server) cmdWritesPause(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	p := &writePause{message: defaultPauseMessage}
+	var mode, timeout, message bool
+	for len(vs) > 0 {
+		var arg string
+		vs, arg, _ = tokenval(vs)
+		switch {
+		case lc(arg, "reject"), lc(arg, "queue"):
+			if mode {
+				return NOMessage, errDuplicateArgument(arg)
+			}
+			mode = true
+			p.queue = lc(arg, "queue")
+		case lc(arg, "timeout"):
+			if timeout {
+				return NOMessage, errDuplicateArgument(arg)
+			}
+			timeout = true
+			var sval string
+			var ok bool
+			if vs, sval, ok = tokenval(vs); !ok || sval == "" {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+			secs, err := strconv.ParseFloat(sval, 64)
+			if err != nil || secs < 0 {
+				return NOMessage, errInvalidArgument(sval)
+			}
+			p.timeout = time.Duration(secs * float64(time.Second))
+		case lc(arg, "message"):
+			if message {
+				return NOMessage, errDuplicateArgument(arg)
+			}
+			message = true
+			var ok bool
+			if vs, p.message, ok = tokenval(vs); !ok || p.message == "" {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+		default:
+			return NOMessage, errInvalidArgument(arg)
+		}
+	}
+	if timeout && !p.queue {
+		return NOMessage, errors.New("TIMEOUT is only for QUEUE")
+	}
+	p.since = time.Now()
+	s.pauseMu.Lock()
+	s.pause = p
+	// queued writes wait again, or are rejected by the new pause
+	s.pauseCond.Broadcast()
+	s.pauseMu.Unlock()
+	if p.queue {
+		log.Info("writes paused (queue)")
+	} else {
+		log.Info("writes paused")
+	}
+	return OKMessage(msg, start), nil
+}
+
+// cmdWritesResume resumes the writes and runs the queued writes.
+//
+//	WRITES RESUME
+func (s *Server) cmdWritesResume(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	if len(msg.Args) != 1 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	s.pauseMu.Lock()
+	paused := s.pause != nil
+	s.pause = nil
+	s.pauseCond.Broadcast()
+	s.pauseMu.Unlock()
+	if paused {
+		log.Info("writes resumed")
+	}
+	return OKMessage(msg, start), nil
+}
+
+// cmdWritesStatus returns the current pause.
+//
+//	WRITES STATUS
+func (s *Server) cmdWritesStatus(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	if len(msg.Args) != 1 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	s.pauseMu.Lock()
+	p := s.pause
+	queued := s.pauseQueued
+	s.pauseMu.Unlock()
+	m := map[string]interface{}{"paused": p != nil}
+	if p != nil {
+		mode := "reject"
+		if p.queue {
+			mode = "queue"
+			m["timeout"] = p.timeout.Seconds()
+			m["queued"] = queued
+		}
+		m["mode"] = mode
+		m["message"] = p.message
+		m["since"] = p.since.UTC().Format(time.RFC3339Nano)
+	}
+	switch msg.OutputType {
+	case JSON:
+		var sb strings.Builder
+		sb.WriteString(`{"ok":true,"writes":{"paused":`)
+		sb.WriteString(strconv.FormatBool(p != nil))
+		if p != nil {
+			sb.WriteString(`,"mode":"` + m["mode"].(string) + `"`)
+			sb.WriteString(`,"message":`)
+			sb.Write(appendJSONString(nil, p.message))
+			sb.WriteString(`,"since":"` + m["since"].(string) + `"`)
+			if p.queue {
+				sb.WriteString(`,"timeout":`)
+				sb.WriteString(strconv.FormatFloat(p.timeout.Seconds(), 'f', -1, 64))
+				sb.WriteString(`,"queued":` + strconv.Itoa(queued))
+			}
+		}
+		sb.WriteString(`},"elapsed":"` + time.Since(start).String() + `"}`)
+		res = resp.StringValue(sb.String())
+	case RESP:
+		res = resp.ArrayValue(respValuesSimpleMap(m))
+	}
+	return
+}
@@ -0,0 +1,192 @@
+package server
+
+// Prepared queries: PREPARE stores a search with $1, $2, ... placeholders
+// for its arguments, and EXECUTE runs it with the parameters in place of
+// the placeholders. The OBJECT areas of a prepared search are parsed once,
+// when it's prepared, so that a large polygon isn't sent and decoded again
+// for every run. EXECUTE is rewritten into the search before the namespace,
+// role and locks of the command are checked, so it runs like the search.
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/resp"
+)
+
+var errUnknownPrepared = errors.New("unknown prepared query")
+
+// preparedQuery is a search with placeholders.
+type preparedQuery struct {
+	args    []string
+	params  int      // number of parameters
+	objects []string // OBJECT areas, parsed by the preparedQueries
+}
+
+// preparedObject is a parsed OBJECT area of the prepared queries.
+type preparedObject struct {
+	obj  geojson.Object
+	refs int
+}
+
+// preparedQueries are the prepared queries by name, and their parsed OBJECT
+// areas by their json.
+type preparedQueries struct {
+	mu      sync.RWMutex
+	queries map[string]*preparedQuery
+	objects map[string]*preparedObject
+}
+
+// placeholder returns the parameter of a $n placeholder, starting at 1.
+func placeholder(arg string) int {
+	if len(arg) < 2 || arg[0] != '$' {
+		return 0
+	}
+	n, err := strconv.Atoi(arg[1:])
+	if err != nil || n <= 0 || arg[1] == '0' || arg[1] == '+' {
+		return 0
+	}
+	return n
+}
+
+// parseObjectArea parses the json of an OBJECT area, or returns the area
+// that was parsed when a query was prepared.
+func (s *Server) parseObjectArea(obj string) (geojson.Object, error) {
+	s.prepared.mu.RLock()
+	po := s.prepared.objects[obj]
+	s.prepared.mu.RUnlock()
+	if po != nil {
+		return po.obj, nil
+	}
+	return geojson.Parse(obj, &s.geomParseOpts)
+}
+
+// release forgets the OBJECT areas of a query that aren't used by the other
+// queries. The mu must be locked.
+func (p *preparedQueries) release(q *preparedQuery) {
+	for _, obj := range q.objects {
+		if po := p.objects[obj]; po != nil {
+			po.refs--
+			if po.refs == 0 {
+				delete(p.objects, obj)
+			}
+		}
+	}
+}
+
+// cmdPrepare stores a search with placeholders.
+//
+//	PREPARE name SCAN|SEARCH|NEARBY|WITHIN|INTERSECTS key [arg ...]
+func (s *Server) cmdPrepare(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	if len(msg.Args) < 4 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	name := msg.Args[1]
+	args := append([]string(nil), msg.Args[2:]...)
+	switch lcs := args[0]; {
+	case lc(lcs, "scan"), lc(lcs, "search"), lc(lcs, "nearby"),
+		lc(lcs, "within"), lc(lcs, "intersects"):
+	default:
+		return NOMessage, errInvalidArgument(args[0])
+	}
+	q := &preparedQuery{args: args}
+	objs := make(map[string]geojson.Object)
+	for i, arg := range args {
+		if n := placeholder(arg); n > q.params {
+			q.params = n
+		}
+		if i > 1 && i < len(args)-1 && lc(arg, "object") &&
+			placeholder(args[i+1]) == 0 {
+			obj := args[i+1]
+			if objs[obj] == nil {
+				o, err := s.parseObjectArea(obj)
+				if err != nil {
+					return NOMessage, err
+				}
+				objs[obj] = o
+				q.objects = append(q.objects, obj)
+			}
+		}
+	}
+	s.prepared.mu.Lock()
+	if s.prepared.queries == nil {
+		s.prepared.queries = make(map[string]*preparedQuery)
+		s.prepared.objects = make(map[string]*preparedObject)
+	}
+	if prev := s.prepared.queries[name]; prev != nil {
+		s.prepared.release(prev)
+	}
+	for _, obj := range q.objects {
+		po := s.prepared.objects[obj]
+		if po == nil {
+			po = &preparedObject{obj: objs[obj]}
+			s.prepared.objects[obj] = po
+		}
+		po.refs++
+	}
+	s.prepared.queries[name] = q
+	s.prepared.mu.Unlock()
+	return OKMessage(msg, start), nil
+}
+
+// cmdDeallocate removes a prepared query.
+//
+//	DEALLOCATE name
+func (s *Server) cmdDeallocate(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	if len(msg.Args) != 2 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	s.prepared.mu.Lock()
+	q := s.prepared.queries[msg.Args[1]]
+	if q != nil {
+		s.prepared.release(q)
+		delete(s.prepared.queries, msg.Args[1])
+	}
+	s.prepared.mu.Unlock()
+	switch msg.OutputType {
+	case JSON:
+		res = OKMessage(msg, start)
+	case RESP:
+		if q != nil {
+			res = resp.IntegerValue(1)
+		} else {
+			res = resp.IntegerValue(0)
+		}
+	}
+	return res, nil
+}
+
+// executeArgs rewrites an EXECUTE into its prepared search, with the
+// parameters in place of the placeholders.
+//
+//	EXECUTE name [param ...]
+func (s *Server) executeArgs(msg *Message) error {
+	if len(msg.Args) < 2 {
+		return errInvalidNumberOfArguments
+	}
+	params := msg.Args[2:]
+	s.prepared.mu.RLock()
+	q := s.prepared.queries[msg.Args[1]]
+	s.prepared.mu.RUnlock()
+	if q == nil {
+		return errUnknownPrepared
+	}
+	if len(params) != q.params {
+		return errInvalidNumberOfArguments
+	}
+	args := make([]string, len(q.args))
+	for i, arg := range q.args {
+		if n := placeholder(arg); n > 0 {
+			arg = params[n-1]
+		}
+		args[i] = arg
+	}
+	msg.Args = args
+	msg._command = ""
+	return nil
+}
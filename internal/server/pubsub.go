@@ -4,6 +4,7 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -38,8 +39,11 @@ func (s *Server) Publish(channel string, message ...string) int {
 	var msgs []submsg
 	s.pubsub.mu.RLock()
 	if hub := s.pubsub.hubs[pubsubChannel][channel]; hub != nil {
-		for target := range hub.targets {
+		for target, filter := range hub.targets {
 			for _, message := range message {
+				if !filter.match(message) {
+					continue
+				}
 				msgs = append(msgs, submsg{
 					kind:    pubsubChannel,
 					target:  target,
@@ -51,8 +55,11 @@ func (s *Server) Publish(channel string, message ...string) int {
 	}
 	for pattern, hub := range s.pubsub.hubs[pubsubPattern] {
 		if match.Match(channel, pattern) {
-			for target := range hub.targets {
+			for target, filter := range hub.targets {
 				for _, message := range message {
+					if !filter.match(message) {
+						continue
+					}
 					msgs = append(msgs, submsg{
 						kind:    pubsubPattern,
 						target:  target,
@@ -66,24 +73,25 @@ func (s *Server) Publish(channel string, message ...string) int {
 	}
 	s.pubsub.mu.RUnlock()
 
-	for _, msg := range msgs {
-		msg.target.cond.L.Lock()
-		msg.target.msgs = append(msg.target.msgs, msg)
-		msg.target.cond.Broadcast()
-		msg.target.cond.L.Unlock()
+	if len(msgs) > 0 {
+		_, limit := s.config.outputLimits()
+		for _, msg := range msgs {
+			s.pushSubmsg(msg.target, msg, limit)
+		}
 	}
 
 	return len(msgs)
 }
 
-func (ps *pubsub) register(kind int, channel string, target *subtarget) {
+func (ps *pubsub) register(kind int, channel string, target *subtarget,
+	filter *subfilter) {
 	ps.mu.Lock()
 	hub, ok := ps.hubs[kind][channel]
 	if !ok {
 		hub = newSubhub()
 		ps.hubs[kind][channel] = hub
 	}
-	hub.targets[target] = true
+	hub.targets[target] = filter
 	ps.mu.Unlock()
 }
 
@@ -111,6 +119,10 @@ type subtarget struct {
 	cond   *sync.Cond
 	msgs   []submsg
 	closed bool
+
+	conn  net.Conn  // closed when over the limit, see outputlimit.go
+	size  int64     // of the messages that aren't written yet
+	since time.Time // when the size went over the soft limit
 }
 
 func newSubtarget() *subtarget {
@@ -120,15 +132,101 @@ func newSubtarget() *subtarget {
 }
 
 type subhub struct {
-	targets map[*subtarget]bool
+	targets map[*subtarget]*subfilter // nil for no filter
 }
 
 func newSubhub() *subhub {
 	hub := new(subhub)
-	hub.targets = make(map[*subtarget]bool)
+	hub.targets = make(map[*subtarget]*subfilter)
 	return hub
 }
 
+// subfilter keeps the geofence messages of a subscription that match, so
+// that the other messages aren't sent to the subscriber.
+type subfilter struct {
+	glob   string          // of the object ids
+	detect map[string]bool // detections
+	wheres []whereT        // on the fields of the objects
+}
+
+func (f *subfilter) match(message string) bool {
+	if f == nil {
+		return true
+	}
+	if f.glob != "" && !match.Match(gjson.Get(message, "id").String(), f.glob) {
+		return false
+	}
+	if f.detect != nil && !f.detect[gjson.Get(message, "detect").String()] {
+		return false
+	}
+	for _, where := range f.wheres {
+		value := gjson.Get(message, "fields."+where.field)
+		if where.eq != nil {
+			if !where.matchString(value.String()) {
+				return false
+			}
+		} else if !where.match(value.Float()) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSubscribe returns the channels or patterns of a subscribe, and its
+// filter.
+//
+//	SUBSCRIBE channel [channel ...] [FILTER [MATCH pattern]
+//	    [DETECT what] [WHERE field min max] [WHERE field = value] ...]
+func parseSubscribe(args []string) (channels []string, filter *subfilter,
+	err error) {
+	for i, arg := range args {
+		if i > 0 && lc(arg, "filter") {
+			channels, args = args[:i], args[i+1:]
+			filter = &subfilter{}
+			break
+		}
+	}
+	if filter == nil {
+		return args, nil, nil
+	}
+	vs := args
+	for len(vs) > 0 {
+		var arg string
+		vs, arg, _ = tokenval(vs)
+		switch {
+		case lc(arg, "match"):
+			if vs, filter.glob, _ = tokenval(vs); filter.glob == "" {
+				return nil, nil, errInvalidNumberOfArguments
+			}
+		case lc(arg, "detect"):
+			var what string
+			if vs, what, _ = tokenval(vs); what == "" {
+				return nil, nil, errInvalidNumberOfArguments
+			}
+			filter.detect = make(map[string]bool)
+			for _, s := range strings.Split(what, ",") {
+				part := strings.TrimSpace(strings.ToLower(s))
+				switch part {
+				default:
+					return nil, nil, errInvalidArgument(what)
+				case "inside", "outside", "enter", "exit", "cross", "roam",
+					"expired":
+				}
+				filter.detect[part] = true
+			}
+		case lc(arg, "where"):
+			var where whereT
+			if vs, where, err = parseWhere(vs); err != nil {
+				return nil, nil, err
+			}
+			filter.wheres = append(filter.wheres, where)
+		default:
+			return nil, nil, errInvalidArgument(arg)
+		}
+	}
+	return channels, filter, nil
+}
+
 type liveSubscriptionSwitches struct {
 	// no fields. everything is managed through the Message
 }
@@ -141,6 +239,9 @@ func (s *Server) cmdSubscribe(msg *Message) (resp.Value, error) {
 	if len(msg.Args) < 2 {
 		return resp.Value{}, errInvalidNumberOfArguments
 	}
+	if _, _, err := parseSubscribe(msg.Args[1:]); err != nil {
+		return resp.Value{}, err
+	}
 	return NOMessage, liveSubscriptionSwitches{}
 }
 
@@ -148,6 +249,9 @@ func (s *Server) cmdPsubscribe(msg *Message) (resp.Value, error) {
 	if len(msg.Args) < 2 {
 		return resp.Value{}, errInvalidNumberOfArguments
 	}
+	if _, _, err := parseSubscribe(msg.Args[1:]); err != nil {
+		return resp.Value{}, err
+	}
 	return NOMessage, liveSubscriptionSwitches{}
 }
 
@@ -227,6 +331,15 @@ func (s *Server) liveSubscription(
 				"PING / QUIT allowed in this context\r\n"))
 		}
 	}
+	writeErr := func(err error) {
+		switch outputType {
+		case JSON:
+			write([]byte(`{"ok":false,"err":` + jsonString(err.Error()) +
+				`,"elapsed":"` + time.Since(start).String() + `"}`))
+		case RESP:
+			write([]byte("-ERR " + err.Error() + "\r\n"))
+		}
+	}
 	writeSubscribe := func(command, channel string, num int) {
 		switch outputType {
 		case JSON:
@@ -289,6 +402,7 @@ func (s *Server) liveSubscription(
 	}
 
 	target := newSubtarget()
+	target.conn = conn
 
 	defer func() {
 		for i := 0; i < 2; i++ {
@@ -314,6 +428,7 @@ func (s *Server) liveSubscription(
 			target.cond.L.Unlock()
 			for _, msg := range msgs {
 				writeMessage(msg)
+				target.written(msg)
 			}
 			target.cond.L.Lock()
 			if target.closed {
@@ -350,14 +465,22 @@ func (s *Server) liveSubscription(
 			if len(msg.Args) < 2 {
 				writeWrongNumberOfArgsErr(msg.Command())
 			}
-			for i := 1; i < len(msg.Args); i++ {
-				channel := msg.Args[i]
+			channels, filter := msg.Args[1:], (*subfilter)(nil)
+			if !un {
+				var err error
+				channels, filter, err = parseSubscribe(msg.Args[1:])
+				if err != nil {
+					writeErr(err)
+					continue
+				}
+			}
+			for _, channel := range channels {
 				if un {
 					delete(m[kind], channel)
 					s.pubsub.unregister(kind, channel, target)
 				} else {
 					m[kind][channel] = true
-					s.pubsub.register(kind, channel, target)
+					s.pubsub.register(kind, channel, target, filter)
 				}
 				writeSubscribe(msg.Command(), channel, len(m[0])+len(m[1]))
 			}
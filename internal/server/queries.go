@@ -0,0 +1,218 @@
+package server
+
+// Running queries: the searches get an id while they run, and QUERIES KILL
+// cancels them through the context of their deadline, like a client that
+// goes away, see watchClient. The canceled search stops at its next step and
+// replies with a "canceled" error. QUERIES takes no lock, so it runs while
+// the searches hold the locks. The bulk commands, see bulk.go, also show the
+// number of objects that they've done so far.
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/deadline"
+)
+
+// runningQuery is a search that QUERIES KILL can cancel.
+type runningQuery struct {
+	id      uint64
+	client  int
+	addr    string
+	command string
+	key     string
+	started time.Time
+	killed  bool
+	done    int // objects done by a bulk command
+	cancel  context.CancelFunc
+}
+
+// runningQueries are the running queries, by id.
+type runningQueries struct {
+	mu     sync.Mutex
+	nextID uint64
+	list   map[uint64]*runningQuery
+}
+
+// isQueryCommand returns true for the commands that can be canceled.
+func isQueryCommand(cmd string) bool {
+	switch cmd {
+	case "scan", "search", "nearby", "within", "intersects", "density",
+		"mread", "delwhere", "copy", "move":
+		return true
+	}
+	return false
+}
+
+// startQuery gives a search an id and a context that cancels it, and
+// returns the func that removes it when it's done.
+func (s *Server) startQuery(client *Client, msg *Message) (stop func()) {
+	if !isQueryCommand(msg.Command()) {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if msg.Deadline == nil {
+		msg.Deadline = deadline.WithContext(ctx)
+	} else {
+		msg.Deadline.SetContext(ctx)
+	}
+	q := &runningQuery{
+		client:  client.id,
+		addr:    client.remoteAddr,
+		command: msg.Command(),
+		started: time.Now(),
+		cancel:  cancel,
+	}
+	if q.command == "delwhere" {
+		if i := bulkSearch(msg.Args, 1); i > 0 && i < len(msg.Args)-1 {
+			q.key = msg.Args[i+1]
+		}
+	} else if len(msg.Args) > 1 {
+		q.key = msg.Args[1]
+	}
+	msg.query = q
+	s.queries.mu.Lock()
+	if s.queries.list == nil {
+		s.queries.list = make(map[uint64]*runningQuery)
+	}
+	s.queries.nextID++
+	q.id = s.queries.nextID
+	s.queries.list[q.id] = q
+	s.queries.mu.Unlock()
+	return func() {
+		s.queries.mu.Lock()
+		delete(s.queries.list, q.id)
+		s.queries.mu.Unlock()
+		cancel()
+	}
+}
+
+// queryProgress sets the number of objects that a bulk command has done.
+func (s *Server) queryProgress(msg *Message, done int) {
+	if msg.query == nil {
+		return
+	}
+	s.queries.mu.Lock()
+	msg.query.done = done
+	s.queries.mu.Unlock()
+}
+
+// cmdQueriesList returns the running queries. Like CLIENT LIST, the RESP
+// reply is a line of fields for each query.
+//
+//	QUERIES LIST
+func (s *Server) cmdQueriesList(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	if len(msg.Args) != 1 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	s.queries.mu.Lock()
+	list := make([]runningQuery, 0, len(s.queries.list))
+	for _, q := range s.queries.list {
+		list = append(list, *q)
+	}
+	s.queries.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].id < list[j].id
+	})
+	now := time.Now()
+	switch msg.OutputType {
+	case JSON:
+		buf := []byte(`{"ok":true,"queries":[`)
+		for i, q := range list {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `{"id":`+strconv.FormatUint(q.id, 10)...)
+			buf = append(buf, `,"client":`+strconv.Itoa(q.client)...)
+			buf = append(buf, `,"addr":`...)
+			buf = appendJSONString(buf, q.addr)
+			buf = append(buf, `,"command":"`+q.command+`","key":`...)
+			buf = appendJSONString(buf, q.key)
+			buf = append(buf, `,"age_ms":`+strconv.FormatInt(int64(now.Sub(q.started)/time.Millisecond), 10)...)
+			buf = append(buf, `,"done":`+strconv.Itoa(q.done)...)
+			buf = append(buf, `,"killed":`+strconv.FormatBool(q.killed)+`}`...)
+		}
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+`"}`...)
+		res = resp.StringValue(string(buf))
+	case RESP:
+		var sb strings.Builder
+		for _, q := range list {
+			killed := 0
+			if q.killed {
+				killed = 1
+			}
+			fmt.Fprintf(&sb, "id=%d client=%d addr=%s command=%s key=%s "+
+				"age_ms=%d done=%d killed=%d\n",
+				q.id, q.client, q.addr, q.command, q.key,
+				now.Sub(q.started)/time.Millisecond, q.done, killed)
+		}
+		res = resp.BytesValue([]byte(sb.String()))
+	}
+	return
+}
+
+// cmdQueriesKill cancels running queries. It returns the number of queries
+// that were canceled.
+//
+//	QUERIES KILL id [id ...]
+//	QUERIES KILL CLIENT id
+func (s *Server) cmdQueriesKill(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	if len(vs) == 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var client int
+	var ids []uint64
+	if lc(vs[0], "client") {
+		if len(vs) != 2 {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		if client, err = strconv.Atoi(vs[1]); err != nil || client <= 0 {
+			return NOMessage, errInvalidArgument(vs[1])
+		}
+	} else {
+		for _, v := range vs {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return NOMessage, errInvalidArgument(v)
+			}
+			ids = append(ids, id)
+		}
+	}
+	var n int
+	s.queries.mu.Lock()
+	for _, q := range s.queries.list {
+		if q.killed {
+			continue
+		}
+		kill := client != 0 && q.client == client
+		for _, id := range ids {
+			if q.id == id {
+				kill = true
+				break
+			}
+		}
+		if kill {
+			q.killed = true
+			q.cancel()
+			n++
+		}
+	}
+	s.queries.mu.Unlock()
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"killed":` + strconv.Itoa(n) +
+			`,"elapsed":"` + time.Since(start).String() + `"}`)
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
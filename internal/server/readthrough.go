@@ -0,0 +1,223 @@
+package server
+
+// Read-through: a key that has a READTHROUGH loads the objects that a GET
+// misses from an authoritative store, and sets them, so that the server can
+// be a cache in front of the store. The load happens without the locks, and
+// the object is set like a SET, with the EX of the READTHROUGH, and written
+// to the AOF. Only the leader loads. Searches don't load, as they don't know
+// the ids that they miss. See internal/readthrough.
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/readthrough"
+)
+
+// defaultReadThroughTimeout is the longest time of a load, by default.
+const defaultReadThroughTimeout = 5 * time.Second
+
+var errReadThroughNotEnabled = errors.New(
+	"read-through is not enabled for the key")
+
+// keyReadThrough is the READTHROUGH of a key and its loader.
+type keyReadThrough struct {
+	url     string
+	timeout time.Duration
+	ex      float64 // seconds of the objects that are loaded, or zero
+	loader  readthrough.Loader
+
+	loaded int64 // atomic, objects that were loaded
+	missed int64 // atomic, ids that the store doesn't have
+	failed int64 // atomic, loads that failed
+}
+
+// cmdReadThrough sets the store that the objects of a key are loaded from
+// when a GET misses them, and how long the loads may take, and the objects
+// live. Without a url the read-through is removed.
+//
+//	READTHROUGH key [url [TIMEOUT seconds] [EX seconds]]
+func (server *Server) cmdReadThrough(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var url string
+	rt := &keyReadThrough{timeout: defaultReadThroughTimeout}
+	if len(vs) > 0 {
+		vs, url, _ = tokenval(vs)
+	}
+	for len(vs) > 0 {
+		var arg, sval string
+		vs, arg, _ = tokenval(vs)
+		if vs, sval, ok = tokenval(vs); !ok || sval == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		secs, perr := strconv.ParseFloat(sval, 64)
+		if perr != nil || secs <= 0 {
+			err = errInvalidArgument(sval)
+			return
+		}
+		switch {
+		case lc(arg, "timeout"):
+			rt.timeout = time.Duration(secs * float64(time.Second))
+		case lc(arg, "ex"):
+			rt.ex = secs
+		default:
+			err = errInvalidArgument(arg)
+			return
+		}
+	}
+	if url != "" {
+		if rt.loader, err = readthrough.Open(url); err != nil {
+			err = errInvalidArgument(url)
+			return
+		}
+		rt.url = url
+		server.readThroughs[d.key] = rt
+	} else {
+		delete(server.readThroughs, d.key)
+	}
+	d.command = "readthrough"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// readThrough loads the object of a GET when the key has a READTHROUGH and
+// the server doesn't have the object. It's called before the locks of the
+// GET are taken.
+func (server *Server) readThrough(msg *Message) error {
+	if len(msg.Args) < 3 || server.config.followHost() != "" ||
+		server.config.readOnly() {
+		return nil
+	}
+	key, id := msg.Args[1], msg.Args[2]
+	unlock := server.lockKeyRead(key)
+	rt := server.readThroughs[key]
+	var found bool
+	if col := server.getCol(key); rt != nil && col != nil {
+		_, found = col.Expires(id)
+	}
+	unlock()
+	if rt == nil || found {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rt.timeout)
+	obj, err := rt.loader.Load(ctx, key, id)
+	cancel()
+	if err != nil {
+		atomic.AddInt64(&rt.failed, 1)
+		return errors.New("read-through: " + err.Error())
+	}
+	if obj == nil {
+		atomic.AddInt64(&rt.missed, 1)
+		return nil
+	}
+	args := []string{"set", key, id}
+	names := make([]string, 0, len(obj.Fields))
+	for name := range obj.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "field", name,
+			strconv.FormatFloat(obj.Fields[name], 'f', -1, 64))
+	}
+	if rt.ex > 0 {
+		args = append(args, "ex", strconv.FormatFloat(rt.ex, 'f', -1, 64))
+	}
+	args = append(args, "object", string(obj.Object))
+	if err := server.waitWrites(); err != nil {
+		return err
+	}
+	smsg := &Message{Args: args, OutputType: RESP}
+	defer server.lockKeyWrite(smsg)()
+	if col := server.getCol(key); col != nil {
+		if _, ok := col.Expires(id); ok {
+			// set while it was loading
+			return nil
+		}
+	}
+	_, d, err := server.cmdSet(smsg)
+	if err != nil {
+		atomic.AddInt64(&rt.failed, 1)
+		return errors.New("read-through: " + err.Error())
+	}
+	atomic.AddInt64(&rt.loaded, 1)
+	return server.writeAOF(smsg.Args, &d)
+}
+
+// cmdReadThroughStats returns the counts of the loads of the read-through
+// of a key: the objects that were loaded, the ids that the store doesn't
+// have, and the loads that failed.
+//
+//	READTHROUGHSTATS key
+func (server *Server) cmdReadThroughStats(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	rt := server.readThroughs[key]
+	if rt == nil {
+		return NOMessage, errReadThroughNotEnabled
+	}
+	loaded := int(atomic.LoadInt64(&rt.loaded))
+	missed := int(atomic.LoadInt64(&rt.missed))
+	failed := int(atomic.LoadInt64(&rt.failed))
+	switch msg.OutputType {
+	case JSON:
+		return resp.StringValue(`{"ok":true,"stats":{"url":` +
+			jsonString(rt.url) +
+			`,"loaded":` + strconv.Itoa(loaded) +
+			`,"missed":` + strconv.Itoa(missed) +
+			`,"failed":` + strconv.Itoa(failed) +
+			`},"elapsed":"` + time.Since(start).String() + `"}`), nil
+	case RESP:
+		return resp.ArrayValue([]resp.Value{
+			resp.StringValue("url"), resp.StringValue(rt.url),
+			resp.StringValue("loaded"), resp.IntegerValue(loaded),
+			resp.StringValue("missed"), resp.IntegerValue(missed),
+			resp.StringValue("failed"), resp.IntegerValue(failed),
+		}), nil
+	}
+	return NOMessage, nil
+}
+
+// readThroughCommands returns the commands that rebuild the READTHROUGH of
+// the keys, for aofshrink.
+func (server *Server) readThroughCommands() [][]string {
+	keys := make([]string, 0, len(server.readThroughs))
+	for key := range server.readThroughs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmds := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		rt := server.readThroughs[key]
+		cmd := []string{"readthrough", key, rt.url, "TIMEOUT",
+			strconv.FormatFloat(rt.timeout.Seconds(), 'f', -1, 64)}
+		if rt.ex > 0 {
+			cmd = append(cmd, "EX", strconv.FormatFloat(rt.ex, 'f', -1, 64))
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
@@ -0,0 +1,350 @@
+package server
+
+// Roles: a role is a read-only credential whose replies are redacted by the
+// rules of the keys, such as a partner that sees the vehicles of a fleet
+// with three decimals and without the driver field. A client takes a role
+// with AUTH role password, or with the jwt-role-claim of its token. A rule
+// hides fields, and rounds the coordinates of the objects, the bounds, the
+// points, the hashes and the clusters to a number of decimals. The searches
+// still match the exact objects, and the distances are rounded to about the
+// size of the decimal. The clients of a role can't filter on its hidden
+// fields, and can only run the reads that are redacted.
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/glob"
+	"github.com/tidwall/tile38/internal/simplify"
+)
+
+// metersPerDegree is about the length of a degree of latitude, for rounding
+// the distances.
+const metersPerDegree = 111320
+
+var errNotAllowedForRole = errors.New("not allowed for role")
+
+func errRoleNotFound(name string) error {
+	return errors.New("role not found: " + name)
+}
+
+func errFieldRedacted(name string) error {
+	return errors.New("field is redacted: " + name)
+}
+
+// role is the ROLESET of a role.
+type role struct {
+	password string // for AUTH role password, optional
+	rules    []redactRule
+}
+
+// redactRule redacts the keys that match a pattern.
+type redactRule struct {
+	pattern   string
+	hide      []string // fields
+	precision int      // decimals, -1 for all of them
+}
+
+// hidden returns true when the rule hides a field.
+func (rule *redactRule) hidden(field string) bool {
+	for _, name := range rule.hide {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// object returns the object with its coordinates rounded.
+func (rule *redactRule) object(o geojson.Object) geojson.Object {
+	if rule.precision < 0 || !objIsSpatial(o) {
+		return o
+	}
+	switch o := o.(type) {
+	case *geojson.SimplePoint:
+		return geojson.NewSimplePoint(rule.point(o.Center()))
+	case *geojson.Point:
+		if o.Z() == 0 {
+			return geojson.NewPoint(rule.point(o.Center()))
+		}
+	}
+	data := simplify.AppendJSON(nil, o.AppendJSON(nil),
+		simplify.Options{Precision: rule.precision})
+	ro, err := geojson.Parse(string(data), nil)
+	if err != nil {
+		// never the exact object
+		return geojson.NewPoint(rule.point(o.Center()))
+	}
+	return ro
+}
+
+func (rule *redactRule) point(p geometry.Point) geometry.Point {
+	return geometry.Point{
+		X: simplify.Round(p.X, rule.precision),
+		Y: simplify.Round(p.Y, rule.precision),
+	}
+}
+
+// distance returns the distance rounded to about the size of the decimal.
+func (rule *redactRule) distance(meters float64) float64 {
+	if rule.precision < 0 {
+		return meters
+	}
+	step := metersPerDegree / math.Pow(10, float64(rule.precision))
+	return math.Round(meters/step) * step
+}
+
+// redaction returns the rule of the role of a message for a key, or nil when
+// the key isn't redacted. The server.mu lock must be held.
+func (server *Server) redaction(msg *Message, key string) (*redactRule, error) {
+	if msg.role == "" {
+		return nil, nil
+	}
+	r, ok := server.roles[msg.role]
+	if !ok {
+		return nil, errRoleNotFound(msg.role)
+	}
+	key = msg.nsLocal(key)
+	for i := range r.rules {
+		if match, _ := glob.Match(r.rules[i].pattern, key); match {
+			return &r.rules[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// roleArgs returns an error when a command isn't allowed for the role of a
+// client.
+func (server *Server) roleArgs(name string, msg *Message) error {
+	server.mu.RLock()
+	_, ok := server.roles[name]
+	server.mu.RUnlock()
+	if !ok {
+		return errRoleNotFound(name)
+	}
+	switch msg.Command() {
+	case "scan", "search", "nearby", "within", "intersects", "mread":
+		// the fences write the objects without the scan writer
+		for _, arg := range msg.Args[1:] {
+			if lc(arg, "fence") {
+				return errNotAllowedForRole
+			}
+		}
+	case "get", "mget", "keys", "type", "ttl", "pttl", "output", "quit", "geohash":
+	default:
+		return errNotAllowedForRole
+	}
+	msg.role = name
+	return nil
+}
+
+// roleAuth gives a client a role when the password is the password of the
+// role.
+func (server *Server) roleAuth(client *Client, name, password string) bool {
+	server.mu.RLock()
+	r, ok := server.roles[name]
+	server.mu.RUnlock()
+	if !ok || r.password == "" || r.password != password {
+		return false
+	}
+	client.role = name
+	client.authd = true
+	return true
+}
+
+// cmdRoleSet creates a role, or replaces its settings. The first rule that
+// matches a key redacts it.
+//
+//	ROLESET name [PASSWORD password]
+//	             [REDACT pattern [HIDE field ...] [PRECISION decimals] ...]
+func (server *Server) cmdRoleSet(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var name string
+	if vs, name, ok = tokenval(vs); !ok || name == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var r role
+	for len(vs) > 0 {
+		var arg, val string
+		vs, arg, _ = tokenval(vs)
+		if vs, val, ok = tokenval(vs); !ok || val == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		switch {
+		case lc(arg, "password"):
+			r.password = val
+		case lc(arg, "redact"):
+			r.rules = append(r.rules, redactRule{pattern: val, precision: -1})
+		case lc(arg, "hide"), lc(arg, "precision"):
+			if len(r.rules) == 0 {
+				err = errInvalidArgument(arg)
+				return
+			}
+			rule := &r.rules[len(r.rules)-1]
+			if lc(arg, "hide") {
+				rule.hide = append(rule.hide, val)
+				continue
+			}
+			n, perr := strconv.Atoi(val)
+			if perr != nil || n < 0 || n > 15 {
+				err = errInvalidArgument(val)
+				return
+			}
+			rule.precision = n
+		default:
+			err = errInvalidArgument(arg)
+			return
+		}
+	}
+	server.roles[name] = r
+	d.command = "roleset"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// cmdRoleDel removes a role. The clients that have it get errors until they
+// authenticate again.
+//
+//	ROLEDEL name
+func (server *Server) cmdRoleDel(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var name string
+	if vs, name, ok = tokenval(vs); !ok || name == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if len(vs) != 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var n int
+	if _, ok := server.roles[name]; ok {
+		delete(server.roles, name)
+		n = 1
+	}
+	d.command = "roledel"
+	d.updated = n > 0
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
+
+// cmdRoles returns the rules of roles, but not their passwords.
+//
+//	ROLES [name ...]
+func (server *Server) cmdRoles(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	names := msg.Args[1:]
+	if len(names) == 0 {
+		for name := range server.roles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	var ms []map[string]interface{}
+	var vals []resp.Value
+	for _, name := range names {
+		r, ok := server.roles[name]
+		if !ok {
+			return NOMessage, errRoleNotFound(name)
+		}
+		var rules []map[string]interface{}
+		var rvals []resp.Value
+		for _, rule := range r.rules {
+			hide := rule.hide
+			if hide == nil {
+				hide = []string{}
+			}
+			rules = append(rules, map[string]interface{}{
+				"pattern":   rule.pattern,
+				"hide":      hide,
+				"precision": rule.precision,
+			})
+			hvals := make([]resp.Value, len(hide))
+			for i, field := range hide {
+				hvals[i] = resp.StringValue(field)
+			}
+			rvals = append(rvals, resp.ArrayValue([]resp.Value{
+				resp.StringValue(rule.pattern),
+				resp.ArrayValue(hvals),
+				resp.IntegerValue(rule.precision),
+			}))
+		}
+		switch msg.OutputType {
+		case JSON:
+			if rules == nil {
+				rules = []map[string]interface{}{}
+			}
+			ms = append(ms, map[string]interface{}{"name": name, "rules": rules})
+		case RESP:
+			vals = append(vals, resp.ArrayValue([]resp.Value{
+				resp.StringValue(name), resp.ArrayValue(rvals),
+			}))
+		}
+	}
+	switch msg.OutputType {
+	case JSON:
+		if ms == nil {
+			ms = []map[string]interface{}{}
+		}
+		data, err := json.Marshal(ms)
+		if err != nil {
+			return NOMessage, err
+		}
+		res = resp.StringValue(`{"ok":true,"roles":` + string(data) +
+			`,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.ArrayValue(vals)
+	}
+	return res, nil
+}
+
+// roleCommands returns the commands that rebuild the roles, for aofshrink.
+func (server *Server) roleCommands() [][]string {
+	names := make([]string, 0, len(server.roles))
+	for name := range server.roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cmds := make([][]string, 0, len(names))
+	for _, name := range names {
+		r := server.roles[name]
+		cmd := []string{"roleset", name}
+		if r.password != "" {
+			cmd = append(cmd, "PASSWORD", r.password)
+		}
+		for _, rule := range r.rules {
+			cmd = append(cmd, "REDACT", rule.pattern)
+			for _, field := range rule.hide {
+				cmd = append(cmd, "HIDE", field)
+			}
+			if rule.precision >= 0 {
+				cmd = append(cmd, "PRECISION", strconv.Itoa(rule.precision))
+			}
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
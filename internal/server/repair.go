@@ -0,0 +1,34 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/repair"
+)
+
+// appendJSONRepair appends the report of the REPAIR option of SET and MSET.
+func appendJSONRepair(dst []byte, r repair.Report) []byte {
+	dst = append(dst, `{"duplicates":`...)
+	dst = strconv.AppendInt(dst, int64(r.Duplicates), 10)
+	dst = append(dst, `,"closed":`...)
+	dst = strconv.AppendInt(dst, int64(r.Closed), 10)
+	dst = append(dst, `,"reoriented":`...)
+	dst = strconv.AppendInt(dst, int64(r.Reoriented), 10)
+	dst = append(dst, `,"split":`...)
+	dst = strconv.AppendInt(dst, int64(r.Split), 10)
+	dst = append(dst, `,"dropped":`...)
+	dst = strconv.AppendInt(dst, int64(r.Dropped), 10)
+	return append(dst, '}')
+}
+
+// respRepair returns the report as name and count pairs.
+func respRepair(r repair.Report) resp.Value {
+	return resp.ArrayValue([]resp.Value{
+		resp.StringValue("duplicates"), resp.IntegerValue(r.Duplicates),
+		resp.StringValue("closed"), resp.IntegerValue(r.Closed),
+		resp.StringValue("reoriented"), resp.IntegerValue(r.Reoriented),
+		resp.StringValue("split"), resp.IntegerValue(r.Split),
+		resp.StringValue("dropped"), resp.IntegerValue(r.Dropped),
+	})
+}
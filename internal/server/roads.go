@@ -0,0 +1,110 @@
+package server
+
+// Map matching: SET and MSET points that are snapped to the nearest road of
+// the network that is loaded from the "roadnetwork" config property.
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/tile38/internal/log"
+	"github.com/tidwall/tile38/internal/roads"
+)
+
+var errNoRoadNetwork = errors.New("road network not loaded")
+
+// loadRoadNetwork loads the road network from a GeoJSON file, replacing the
+// current network. An empty path unloads the network.
+func (server *Server) loadRoadNetwork(path string) error {
+	var network *roads.Network
+	if path != "" {
+		var err error
+		network, err = roads.Load(path)
+		if err != nil {
+			return err
+		}
+		log.Infof("Road network loaded: %d roads, %d segments",
+			network.Roads(), network.Segments())
+	}
+	server.network.Store(network)
+	return nil
+}
+
+// roadNetwork returns the loaded road network, or nil when there's none.
+func (server *Server) roadNetwork() *roads.Network {
+	network, _ := server.network.Load().(*roads.Network)
+	return network
+}
+
+// matchRoad snaps a point to the nearest road that is within the
+// "mapmatch-distance" config property. The point is returned unchanged, with
+// an empty road id, when no road is near enough.
+func (server *Server) matchRoad(point *geojson.Point, hasZ bool) (
+	geojson.Object, string, error,
+) {
+	network := server.roadNetwork()
+	if network == nil {
+		return nil, "", errNoRoadNetwork
+	}
+	m, ok := network.Match(point.Base(), server.config.mapMatchDistance())
+	if !ok {
+		return point, "", nil
+	}
+	if hasZ {
+		return geojson.NewPointZ(m.Point, point.Z()), m.Road, nil
+	}
+	return geojson.NewPoint(m.Point), m.Road, nil
+}
+
+// matchedArgs returns the arguments of a SET object, starting at the id,
+// with the MATCH option removed and the point replaced by the snapped point.
+// This keeps the AOF independent of the road network. Returns nil when the
+// arguments have no MATCH option.
+func matchedArgs(args []string, obj geojson.Object) []string {
+	var matched bool
+	out := make([]string, 0, len(args))
+	out = append(out, args[0])
+	i := 1
+loop:
+	for i < len(args) {
+		switch strings.ToLower(args[i]) {
+		case "field":
+			out = append(out, args[i:i+3]...)
+			i += 3
+		case "ex":
+			out = append(out, args[i:i+2]...)
+			i += 2
+		case "crs":
+			// the snapped point is WGS84
+			i += 2
+		case "xx", "nx":
+			out = append(out, args[i])
+			i++
+		case "match":
+			matched = true
+			i++
+		default:
+			break loop
+		}
+	}
+	if !matched {
+		return nil
+	}
+	p := obj.Center()
+	out = append(out, args[i],
+		strconv.FormatFloat(p.Y, 'f', -1, 64),
+		strconv.FormatFloat(p.X, 'f', -1, 64))
+	return append(out, args[i+3:]...)
+}
+
+// appendRoad adds the road id to a json event message.
+func appendRoad(msg, road string) string {
+	// hack off the last '}'
+	nmsg := []byte(msg[:len(msg)-1])
+	nmsg = append(nmsg, `,"road":`...)
+	nmsg = appendJSONString(nmsg, road)
+	nmsg = append(nmsg, '}')
+	return string(nmsg)
+}
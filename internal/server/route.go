@@ -0,0 +1,520 @@
+package server
+
+// Routes: great-circle distances and bearings between objects, distance
+// matrices between sets of objects, and positions along the LineStrings of
+// a key.
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/route"
+)
+
+var errNotLineString = errors.New("not a linestring")
+
+// maxRoutePoints is the largest number of points of ROUTE INTERPOLATE.
+const maxRoutePoints = 100000
+
+// getObject returns an object of a key.
+func (server *Server) getObject(key, id string) (geojson.Object, error) {
+	col := server.getCol(key)
+	if col == nil {
+		return nil, errKeyNotFound
+	}
+	o, _, _, ok := col.Get(id)
+	if !ok {
+		return nil, errIDNotFound
+	}
+	return o, nil
+}
+
+// getRoute returns the route of a LineString, or of a Feature that has a
+// LineString geometry.
+func (server *Server) getRoute(key, id string) (*route.Route, error) {
+	o, err := server.getObject(key, id)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := o.(*geojson.Feature); ok {
+		o = f.Base()
+	}
+	ls, ok := o.(*geojson.LineString)
+	if !ok {
+		return nil, errNotLineString
+	}
+	line := ls.Base()
+	points := make([]geometry.Point, line.NumPoints())
+	for i := range points {
+		points[i] = line.PointAt(i)
+	}
+	return route.New(points), nil
+}
+
+// parseKeyID parses the "key id" arguments of an object.
+func parseKeyID(vs []string) (nvs []string, key, id string, err error) {
+	var ok bool
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return vs, "", "", errInvalidNumberOfArguments
+	}
+	if vs, id, ok = tokenval(vs); !ok || id == "" {
+		return vs, "", "", errInvalidNumberOfArguments
+	}
+	return vs, key, id, nil
+}
+
+func appendJSONPoint(dst []byte, p geometry.Point) []byte {
+	return appendJSONSimplePoint(dst, geojson.NewSimplePoint(p), -1)
+}
+
+func respPoint(p geometry.Point) resp.Value {
+	return resp.ArrayValue([]resp.Value{
+		resp.FloatValue(p.Y), resp.FloatValue(p.X),
+	})
+}
+
+// cmdRouteDistance returns the great-circle distance and the initial
+// bearing between the centers of two objects.
+//
+//	ROUTE DISTANCE key id key id
+func (server *Server) cmdRouteDistance(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var points [2]geometry.Point
+	for i := range points {
+		var key, id string
+		var err error
+		if vs, key, id, err = parseKeyID(vs); err != nil {
+			return NOMessage, err
+		}
+		o, err := server.getObject(key, id)
+		if err != nil {
+			return NOMessage, err
+		}
+		points[i] = o.Center()
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	meters := route.Distance(points[0], points[1])
+	bearing := route.Bearing(points[0], points[1])
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"distance":` +
+			strconv.FormatFloat(meters, 'f', -1, 64))
+		buf.WriteString(`,"bearing":` + strconv.FormatFloat(bearing, 'f', -1, 64))
+		buf.WriteString(`,"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		return resp.ArrayValue([]resp.Value{
+			resp.FloatValue(meters), resp.FloatValue(bearing),
+		}), nil
+	}
+	return NOMessage, nil
+}
+
+// cmdRouteInterpolate returns the points of a route at distances from its
+// start, which are either listed or every STEP meters, including the start
+// and the end. Distances past the end of the route return the end.
+//
+//	ROUTE INTERPOLATE key id AT meters [meters ...] | STEP meters
+func (server *Server) cmdRouteInterpolate(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs, key, id, err := parseKeyID(msg.Args[1:])
+	if err != nil {
+		return NOMessage, err
+	}
+	var ok bool
+	var which string
+	if vs, which, ok = tokenval(vs); !ok || len(vs) == 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var dists []float64
+	var step float64
+	switch strings.ToLower(which) {
+	case "at":
+		for _, s := range vs {
+			meters, err := strconv.ParseFloat(s, 64)
+			if err != nil || meters < 0 {
+				return NOMessage, errInvalidArgument(s)
+			}
+			dists = append(dists, meters)
+		}
+	case "step":
+		if len(vs) != 1 {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		if step, err = strconv.ParseFloat(vs[0], 64); err != nil || step <= 0 {
+			return NOMessage, errInvalidArgument(vs[0])
+		}
+	default:
+		return NOMessage, errInvalidArgument(which)
+	}
+	r, err := server.getRoute(key, id)
+	if err != nil {
+		return NOMessage, err
+	}
+	if step > 0 {
+		if r.Length()/step >= maxRoutePoints {
+			return NOMessage, errInvalidArgument(vs[0])
+		}
+		for meters := 0.0; meters < r.Length(); meters += step {
+			dists = append(dists, meters)
+		}
+		dists = append(dists, r.Length())
+	}
+	points := make([]geometry.Point, len(dists))
+	for i, meters := range dists {
+		points[i] = r.Interpolate(meters)
+	}
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"length":` +
+			strconv.FormatFloat(r.Length(), 'f', -1, 64))
+		buf.WriteString(`,"points":[`)
+		for i, p := range points {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(appendJSONPoint(nil, p))
+		}
+		buf.WriteString(`],"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		vals := make([]resp.Value, len(points))
+		for i, p := range points {
+			vals[i] = respPoint(p)
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
+
+// cmdRouteAlong returns the distance of an object along a route, which is
+// the distance from the start of the route to the point of the route that
+// is nearest to the center of the object. The offset is the distance from
+// the object to that point.
+//
+//	ROUTE ALONG routekey routeid key id
+func (server *Server) cmdRouteAlong(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs, rkey, rid, err := parseKeyID(msg.Args[1:])
+	if err != nil {
+		return NOMessage, err
+	}
+	vs, key, id, err := parseKeyID(vs)
+	if err != nil {
+		return NOMessage, err
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	r, err := server.getRoute(rkey, rid)
+	if err != nil {
+		return NOMessage, err
+	}
+	o, err := server.getObject(key, id)
+	if err != nil {
+		return NOMessage, err
+	}
+	at, along, offset := r.Locate(o.Center())
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"distance":` +
+			strconv.FormatFloat(along, 'f', -1, 64))
+		buf.WriteString(`,"offset":` + strconv.FormatFloat(offset, 'f', -1, 64))
+		buf.WriteString(`,"length":` + strconv.FormatFloat(r.Length(), 'f', -1, 64))
+		buf.WriteString(`,"point":`)
+		buf.Write(appendJSONPoint(nil, at))
+		buf.WriteString(`,"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		return resp.ArrayValue([]resp.Value{
+			resp.FloatValue(along), resp.FloatValue(offset), respPoint(at),
+		}), nil
+	}
+	return NOMessage, nil
+}
+
+// maxMatrixCells is the largest number of distances of ROUTE MATRIX.
+const maxMatrixCells = 1000000
+
+var errMatrixTooLarge = errors.New("the matrix is too large")
+
+// matrixNeighbor is a target of a row of ROUTE MATRIX TOPK.
+type matrixNeighbor struct {
+	id   string
+	dist float64
+}
+
+// insertNeighbor adds a target to the nearest targets of a row, which are
+// ordered by distance and no more than k.
+func insertNeighbor(nn []matrixNeighbor, n matrixNeighbor, k int,
+) []matrixNeighbor {
+	i := sort.Search(len(nn), func(i int) bool { return nn[i].dist > n.dist })
+	if i >= k {
+		return nn
+	}
+	if len(nn) < k {
+		nn = append(nn, matrixNeighbor{})
+	}
+	copy(nn[i+1:], nn[i:])
+	nn[i] = n
+	return nn
+}
+
+// parseMatrixKey parses the "key [IDS id,...]" arguments of ROUTE MATRIX.
+// No ids is all of the objects of the key.
+func parseMatrixKey(vs []string) (nvs []string, key string, ids []string,
+	err error,
+) {
+	var ok bool
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return vs, "", nil, errInvalidNumberOfArguments
+	}
+	if len(vs) == 0 || !lc(vs[0], "ids") {
+		return vs, key, nil, nil
+	}
+	var sids string
+	if vs, sids, ok = tokenval(vs[1:]); !ok || sids == "" {
+		return vs, "", nil, errInvalidNumberOfArguments
+	}
+	for _, id := range strings.Split(sids, ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			return vs, "", nil, errInvalidArgument(sids)
+		}
+		ids = append(ids, id)
+	}
+	return vs, key, ids, nil
+}
+
+// matrixPoints returns the ids and the centers of the objects of a side of
+// ROUTE MATRIX, which are all of the objects of the key, ordered by id,
+// when there are no ids.
+func (server *Server) matrixPoints(msg *Message, key string, ids []string,
+) ([]string, []geometry.Point, error) {
+	var points []geometry.Point
+	if ids != nil {
+		for _, id := range ids {
+			o, err := server.getObject(key, id)
+			if err != nil {
+				return nil, nil, err
+			}
+			points = append(points, o.Center())
+		}
+		return ids, points, nil
+	}
+	col := server.getCol(key)
+	if col == nil {
+		return nil, nil, errKeyNotFound
+	}
+	col.Scan(false, nil, msg.Deadline,
+		func(id string, o geojson.Object, _ []float64) bool {
+			ids = append(ids, id)
+			points = append(points, o.Center())
+			return true
+		},
+	)
+	return ids, points, nil
+}
+
+// cmdRouteMatrix returns the great-circle distances between the centers of
+// the sources and of the targets, a row for each source. With TOPK the row
+// of a source has its k nearest targets instead, which are found with the
+// spatial index of the key when the targets are all of its objects.
+//
+//	ROUTE MATRIX key [IDS id,...] TO key [IDS id,...] [TOPK k]
+func (server *Server) cmdRouteMatrix(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs, skey, sids, err := parseMatrixKey(msg.Args[1:])
+	if err != nil {
+		return NOMessage, err
+	}
+	var ok bool
+	var to string
+	if vs, to, ok = tokenval(vs); !ok {
+		return NOMessage, errInvalidNumberOfArguments
+	} else if !lc(to, "to") {
+		return NOMessage, errInvalidArgument(to)
+	}
+	vs, tkey, tids, err := parseMatrixKey(vs)
+	if err != nil {
+		return NOMessage, err
+	}
+	var topk int
+	if len(vs) > 0 {
+		if !lc(vs[0], "topk") {
+			return NOMessage, errInvalidArgument(vs[0])
+		}
+		if len(vs) != 2 {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+		n, err := strconv.ParseUint(vs[1], 10, 32)
+		if err != nil || n == 0 {
+			return NOMessage, errInvalidArgument(vs[1])
+		}
+		topk = int(n)
+	}
+	sids, spoints, err := server.matrixPoints(msg, skey, sids)
+	if err != nil {
+		return NOMessage, err
+	}
+	var tpoints []geometry.Point
+	var tcount int
+	if topk == 0 || tids != nil {
+		if tids, tpoints, err = server.matrixPoints(msg, tkey, tids); err != nil {
+			return NOMessage, err
+		}
+		tcount = len(tids)
+	} else if col := server.getCol(tkey); col == nil {
+		return NOMessage, errKeyNotFound
+	} else {
+		tcount = col.Count()
+	}
+	cells := tcount
+	if topk > 0 && topk < cells {
+		cells = topk
+	}
+	if len(sids)*cells > maxMatrixCells {
+		return NOMessage, errMatrixTooLarge
+	}
+	var dists [][]float64
+	var nearest [][]matrixNeighbor
+	if topk == 0 {
+		dists = make([][]float64, len(spoints))
+		for i, p := range spoints {
+			msg.Deadline.Check()
+			dists[i] = make([]float64, len(tpoints))
+			for j, q := range tpoints {
+				dists[i][j] = route.Distance(p, q)
+			}
+		}
+	} else {
+		nearest = make([][]matrixNeighbor, len(spoints))
+		for i, p := range spoints {
+			var nn []matrixNeighbor
+			if tpoints != nil {
+				msg.Deadline.Check()
+				for j, q := range tpoints {
+					nn = insertNeighbor(nn,
+						matrixNeighbor{tids[j], route.Distance(p, q)}, topk)
+				}
+			} else {
+				// the distance of the index is to the bounds of an object,
+				// which is never farther than its center
+				server.getCol(tkey).Nearby(geojson.NewPoint(p), nil,
+					msg.Deadline, func(id string, o geojson.Object,
+						_ []float64, dist float64,
+					) bool {
+						if len(nn) == topk && dist > nn[topk-1].dist {
+							return false
+						}
+						nn = insertNeighbor(nn, matrixNeighbor{id,
+							route.Distance(p, o.Center())}, topk)
+						return true
+					},
+				)
+			}
+			nearest[i] = nn
+		}
+	}
+	switch msg.OutputType {
+	case JSON:
+		buf := []byte(`{"ok":true,"sources":[`)
+		for i, id := range sids {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, id)
+		}
+		buf = append(buf, ']')
+		if topk == 0 {
+			buf = append(buf, `,"targets":[`...)
+			for i, id := range tids {
+				if i > 0 {
+					buf = append(buf, ',')
+				}
+				buf = appendJSONString(buf, id)
+			}
+			buf = append(buf, `],"distances":[`...)
+			for i, row := range dists {
+				if i > 0 {
+					buf = append(buf, ',')
+				}
+				buf = append(buf, '[')
+				for j, dist := range row {
+					if j > 0 {
+						buf = append(buf, ',')
+					}
+					buf = strconv.AppendFloat(buf, dist, 'f', -1, 64)
+				}
+				buf = append(buf, ']')
+			}
+		} else {
+			buf = append(buf, `,"nearest":[`...)
+			for i, row := range nearest {
+				if i > 0 {
+					buf = append(buf, ',')
+				}
+				buf = append(buf, '[')
+				for j, n := range row {
+					if j > 0 {
+						buf = append(buf, ',')
+					}
+					buf = append(buf, `{"id":`...)
+					buf = appendJSONString(buf, n.id)
+					buf = append(buf, `,"distance":`...)
+					buf = strconv.AppendFloat(buf, n.dist, 'f', -1, 64)
+					buf = append(buf, '}')
+				}
+				buf = append(buf, ']')
+			}
+		}
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+`"}`...)
+		return resp.StringValue(string(buf)), nil
+	case RESP:
+		ids := func(ids []string) resp.Value {
+			vals := make([]resp.Value, len(ids))
+			for i, id := range ids {
+				vals[i] = resp.StringValue(id)
+			}
+			return resp.ArrayValue(vals)
+		}
+		var rows []resp.Value
+		if topk == 0 {
+			rows = make([]resp.Value, len(dists))
+			for i, row := range dists {
+				vals := make([]resp.Value, len(row))
+				for j, dist := range row {
+					vals[j] = resp.FloatValue(dist)
+				}
+				rows[i] = resp.ArrayValue(vals)
+			}
+			return resp.ArrayValue([]resp.Value{
+				ids(sids), ids(tids), resp.ArrayValue(rows),
+			}), nil
+		}
+		rows = make([]resp.Value, len(nearest))
+		for i, row := range nearest {
+			vals := make([]resp.Value, len(row))
+			for j, n := range row {
+				vals[j] = resp.ArrayValue([]resp.Value{
+					resp.StringValue(n.id), resp.FloatValue(n.dist),
+				})
+			}
+			rows[i] = resp.ArrayValue(vals)
+		}
+		return resp.ArrayValue([]resp.Value{ids(sids), resp.ArrayValue(rows)}), nil
+	}
+	return NOMessage, nil
+}
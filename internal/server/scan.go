@@ -48,17 +48,23 @@ func (s *Server) cmdScan(msg *Message) (res resp.Value, err error) {
 	sw, err := s.newScanWriter(
 		wr, msg, args.key, args.output, args.precision, args.glob, false,
 		args.cursor, args.limit, args.wheres, args.whereins, args.whereevals,
-		args.nofields)
+		args.nofields, args.selected, args.reshape, args.crs)
 	if err != nil {
 		return NOMessage, err
 	}
+	sw.expiresOptions(args.searchScanBaseTokens)
+	sw.tagOptions(args.searchScanBaseTokens)
+	sw.budgetOptions(args.searchScanBaseTokens)
+	if err := sw.aggregateOptions(args.searchScanBaseTokens); err != nil {
+		return NOMessage, err
+	}
 	if msg.OutputType == JSON {
 		wr.WriteString(`{"ok":true`)
 	}
 	sw.writeHead()
 	if sw.col != nil {
 		if sw.output == outputCount && len(sw.wheres) == 0 &&
-			len(sw.whereins) == 0 && sw.globEverything {
+			len(sw.whereins) == 0 && len(sw.tagged) == 0 && sw.globEverything {
 			count := sw.col.Count() - int(args.cursor)
 			if count < 0 {
 				count = 0
@@ -66,7 +72,19 @@ func (s *Server) cmdScan(msg *Message) (res resp.Value, err error) {
 			sw.count = uint64(count)
 		} else {
 			g := glob.Parse(sw.globPattern, args.desc)
-			if g.Limits[0] == "" && g.Limits[1] == "" {
+			if tag, ok := sw.leastTagged(); ok &&
+				g.Limits[0] == "" && g.Limits[1] == "" {
+				// the objects of the inverted index of the tag
+				sw.col.ScanTagged(tag, args.desc, sw, msg.Deadline,
+					func(id string, o geojson.Object, fields []float64) bool {
+						return sw.writeObject(ScanWriterParams{
+							id:     id,
+							o:      o,
+							fields: fields,
+						})
+					},
+				)
+			} else if g.Limits[0] == "" && g.Limits[1] == "" {
 				sw.col.Scan(args.desc, sw,
 					msg.Deadline,
 					func(id string, o geojson.Object, fields []float64) bool {
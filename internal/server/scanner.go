@@ -2,17 +2,24 @@ package server
 
 import (
 	"bytes"
+	"encoding/base64"
 	"errors"
 	"math"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/mmcloughlin/geohash"
 	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
 	"github.com/tidwall/resp"
 	"github.com/tidwall/tile38/internal/clip"
+	"github.com/tidwall/tile38/internal/cluster"
 	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/crs"
+	"github.com/tidwall/tile38/internal/flatgeobuf"
 	"github.com/tidwall/tile38/internal/glob"
+	"github.com/tidwall/tile38/internal/simplify"
 )
 
 const limitItems = 100
@@ -27,6 +34,10 @@ const (
 	outputPoints
 	outputHashes
 	outputBounds
+	outputFlatGeobuf
+	outputCluster
+	outputHull
+	outputCentroid
 )
 
 type scanWriter struct {
@@ -58,6 +69,40 @@ type scanWriter struct {
 	values         []resp.Value
 	matchValues    bool
 	respOut        resp.Value
+	reshape        simplify.Options
+	fgb            *flatgeobuf.Writer
+	crs            *crs.CRS // output reference system
+	selected       []string // SELECT field projection
+	onames         []string // output field names
+	oidxs          []int    // output field indexes, -1 for unknown fields
+
+	zoom          int             // CLUSTER zoom level
+	area          geojson.Object  // CLUSTER search area
+	clusterIdx    *cluster.Index  // CLUSTER index of the whole key
+	clusterPoints []cluster.Point // CLUSTER points of the matching objects
+
+	hull       float64          // HULL CONCAVE meters, zero for the convex hull
+	hullPoints []geometry.Point // HULL positions of the matching objects
+	hullSize   int              // HULL positions of the last compaction
+
+	centroidField  string     // CENTROID WEIGHT field
+	centroid       [3]float64 // CENTROID sum of the weighted unit vectors
+	centroidWeight float64    // CENTROID sum of the weights
+
+	withExpires bool  // WITHEXPIRES output
+	withTTL     bool  // WITHTTL output
+	minExpires  int64 // MINTTL, the objects that expire before are skipped
+
+	tagged []string // TAGGED, the tags that the objects must have
+
+	budget     int64 // WITHINCOMPLETE, the unix nano time that the search stops at
+	incomplete bool  // WITHINCOMPLETE, output the partial flag
+	partial    bool  // the search stopped at the budget
+
+	key        string      // for the open cursor
+	openCursor *openCursor // continued by the scan, see cursors.go
+
+	redact *redactRule // of the role of the client, see redact.go
 }
 
 // ScanWriterParams ...
@@ -77,16 +122,19 @@ func (s *Server) newScanWriter(
 	wr *bytes.Buffer, msg *Message, key string, output outputT,
 	precision uint64, globPattern string, matchValues bool,
 	cursor, limit uint64, wheres []whereT, whereins []whereinT, whereevals []whereevalT, nofields bool,
+	selected []string, reshape simplify.Options, outCRS *crs.CRS,
 ) (
 	*scanWriter, error,
 ) {
 	switch output {
 	default:
 		return nil, errors.New("invalid output type")
-	case outputIDs, outputObjects, outputCount, outputBounds, outputPoints, outputHashes:
+	case outputIDs, outputObjects, outputCount, outputBounds, outputPoints, outputHashes,
+		outputFlatGeobuf, outputCluster, outputHull, outputCentroid:
 	}
 	if limit == 0 {
-		if output == outputCount {
+		if output == outputCount || output == outputCluster ||
+			output == outputHull || output == outputCentroid {
 			limit = math.MaxUint64
 		} else {
 			limit = limitItems
@@ -104,6 +152,14 @@ func (s *Server) newScanWriter(
 		precision:   precision,
 		globPattern: globPattern,
 		matchValues: matchValues,
+		reshape:     reshape,
+		crs:         outCRS,
+		selected:    selected,
+	}
+	var err error
+	sw.key = key
+	if sw.openCursor, err = msg.cursors.open(msg, key, cursor); err != nil {
+		return nil, err
 	}
 	if globPattern == "*" || globPattern == "" {
 		sw.globEverything = true
@@ -112,7 +168,41 @@ func (s *Server) newScanWriter(
 			sw.globSingle = true
 		}
 	}
-	sw.col = s.getCol(key)
+	if msg.ndjson != nil && output != outputCount && output != outputFlatGeobuf &&
+		output != outputCluster && output != outputHull &&
+		output != outputCentroid {
+		msg.ndjson.active = true
+	}
+	if msg.snapshots != nil {
+		sw.col = msg.snapshots[key]
+	} else if msg.snap != nil {
+		sw.col = msg.snap
+	} else {
+		sw.col = s.getCol(key)
+	}
+	if sw.redact, err = s.redaction(msg, key); err != nil {
+		return nil, err
+	}
+	if sw.redact != nil && len(sw.redact.hide) > 0 {
+		if len(whereevals) > 0 {
+			return nil, errNotAllowedForRole
+		}
+		for _, where := range wheres {
+			if sw.redact.hidden(where.field) {
+				return nil, errFieldRedacted(where.field)
+			}
+		}
+		for _, wherein := range whereins {
+			if sw.redact.hidden(wherein.field) {
+				return nil, errFieldRedacted(wherein.field)
+			}
+		}
+		for _, field := range selected {
+			if sw.redact.hidden(field) {
+				return nil, errFieldRedacted(field)
+			}
+		}
+	}
 	if sw.col != nil {
 		sw.fmap = sw.col.FieldMap()
 		sw.farr = sw.col.FieldArr()
@@ -139,26 +229,93 @@ func (s *Server) newScanWriter(
 		}
 	}
 	sw.fvals = make([]float64, len(sw.farr))
+	if !nofields {
+		sw.onames, sw.oidxs = sw.outputFields(sw.fmap)
+	}
+	if output == outputFlatGeobuf {
+		sw.fgb = flatgeobuf.NewWriter(key, sw.onames)
+	}
 	return sw, nil
 }
 
+// outputFields returns the names and indexes of the fields that are
+// written for each object. These are either the fields listed by SELECT, in
+// their requested order, or all of the collection fields.
+func (sw *scanWriter) outputFields(fmap map[string]int) (names []string, idxs []int) {
+	names = sw.selected
+	if names == nil {
+		names = sw.farr
+		if sw.redact != nil && len(sw.redact.hide) > 0 {
+			names = nil
+			for _, name := range sw.farr {
+				if !sw.redact.hidden(name) {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	idxs = make([]int, len(names))
+	for i, name := range names {
+		if idx, ok := fmap[name]; ok {
+			idxs[i] = idx
+		} else {
+			idxs[i] = -1
+		}
+	}
+	return names, idxs
+}
+
+// isSelected returns true if the field is included in the output.
+func (sw *scanWriter) isSelected(name string) bool {
+	if sw.redact != nil && sw.redact.hidden(name) {
+		return false
+	}
+	if sw.selected == nil {
+		return true
+	}
+	for _, sel := range sw.selected {
+		if sel == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldValue returns the value of the output field at position i.
+func (sw *scanWriter) fieldValue(i int, fields []float64) float64 {
+	if idx := sw.oidxs[i]; idx >= 0 && idx < len(fields) {
+		return fields[idx]
+	}
+	return 0
+}
+
 func (sw *scanWriter) hasFieldsOutput() bool {
 	switch sw.output {
 	default:
 		return false
-	case outputObjects, outputPoints, outputHashes, outputBounds:
+	case outputIDs:
+		// SELECT turns the ids into id and fields pairs
+		return len(sw.selected) > 0 && !sw.nofields
+	case outputObjects, outputPoints, outputHashes, outputBounds, outputFlatGeobuf:
 		return !sw.nofields
 	}
 }
 
+func (sw *scanWriter) streaming() bool {
+	return sw.msg.ndjson != nil && sw.msg.ndjson.active
+}
+
 func (sw *scanWriter) writeHead() {
 	sw.mu.Lock()
 	defer sw.mu.Unlock()
+	if sw.streaming() {
+		return
+	}
 	switch sw.msg.OutputType {
 	case JSON:
-		if len(sw.farr) > 0 && sw.hasFieldsOutput() {
+		if len(sw.onames) > 0 && sw.hasFieldsOutput() && sw.output != outputFlatGeobuf {
 			sw.wr.WriteString(`,"fields":[`)
-			for i, field := range sw.farr {
+			for i, field := range sw.onames {
 				if i > 0 {
 					sw.wr.WriteByte(',')
 				}
@@ -187,46 +344,137 @@ func (sw *scanWriter) writeHead() {
 func (sw *scanWriter) writeFoot() {
 	sw.mu.Lock()
 	defer sw.mu.Unlock()
+	if sw.streaming() {
+		return
+	}
 	cursor := sw.numberIters
-	if !sw.hitLimit {
+	if sw.partial {
+		// the object that stopped the search wasn't written
+		cursor--
+	} else if !sw.hitLimit {
 		cursor = 0
 	}
+	sw.msg.cursors.update(sw.msg, sw.key, sw.openCursor, cursor)
 	switch sw.msg.OutputType {
 	case JSON:
 		switch sw.output {
 		default:
 			sw.wr.WriteByte(']')
 		case outputCount:
-
+		case outputCluster:
+			sw.writeClusters()
+		case outputHull:
+			sw.writeHull()
+		case outputCentroid:
+			sw.writeCentroid()
+		case outputFlatGeobuf:
+			sw.wr.WriteString(`,"flatgeobuf":"`)
+			sw.wr.WriteString(base64.StdEncoding.EncodeToString(sw.fgb.Bytes()))
+			sw.wr.WriteByte('"')
 		}
 		sw.wr.WriteString(`,"count":` + strconv.FormatUint(sw.count, 10))
 		sw.wr.WriteString(`,"cursor":` + strconv.FormatUint(cursor, 10))
+		if sw.incomplete {
+			sw.wr.WriteString(`,"partial":` + strconv.FormatBool(sw.partial))
+		}
 	case RESP:
 		if sw.output == outputCount {
 			sw.respOut = resp.IntegerValue(int(sw.count))
+		} else if sw.output == outputCluster {
+			sw.writeClusters()
+			sw.respOut = resp.ArrayValue([]resp.Value{
+				resp.IntegerValue(int(cursor)),
+				resp.ArrayValue(sw.values),
+			})
+		} else if sw.output == outputHull {
+			sw.respOut = resp.ArrayValue([]resp.Value{
+				resp.IntegerValue(int(cursor)),
+				sw.writeHull(),
+			})
+		} else if sw.output == outputCentroid {
+			point, weight := sw.writeCentroid()
+			sw.respOut = resp.ArrayValue([]resp.Value{
+				resp.IntegerValue(int(cursor)), point, weight,
+			})
+		} else if sw.output == outputFlatGeobuf {
+			sw.respOut = resp.ArrayValue([]resp.Value{
+				resp.IntegerValue(int(cursor)),
+				resp.BytesValue(sw.fgb.Bytes()),
+			})
 		} else {
 			values := []resp.Value{
 				resp.IntegerValue(int(cursor)),
 				resp.ArrayValue(sw.values),
 			}
+			if sw.incomplete {
+				values = append(values, resp.BoolValue(sw.partial))
+			}
 			sw.respOut = resp.ArrayValue(values)
 		}
 	}
 }
 
-func (sw *scanWriter) fieldMatch(fields []float64, o geojson.Object) (fvals []float64, match bool) {
-	var z float64
-	var gotz bool
+// whereZ returns true when any of the z coordinates of an object is within
+// the where range. Positions without a z coordinate have a z of zero.
+func whereZ(where whereT, zs []float64) bool {
+	for _, z := range zs {
+		if where.match(z) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectZ returns the z coordinates of an object, which are kept by the
+// collection, see ZValues.
+func (sw *scanWriter) objectZ(id string, o geojson.Object) []float64 {
+	if sw.col == nil {
+		return collection.ObjectZ(o)
+	}
+	return sw.col.ZValues(id, o)
+}
+
+// zBand returns the range of the WHERE z of a search, for the altitude index
+// of its key. The index returns the objects with a z in the range, with the
+// ends included, which are then matched by whereZ.
+func (sw *scanWriter) zBand() (minZ, maxZ float64, ok bool) {
+	if !sw.col.ZIndexed() {
+		return 0, 0, false
+	}
+	for _, where := range sw.wheres {
+		if where.field == "z" && where.eq == nil {
+			return where.min, where.max, true
+		}
+	}
+	return 0, 0, false
+}
+
+// stringFieldMatch returns true when the string value of the field of a
+// WHERE field = value is equal to the value.
+func (sw *scanWriter) stringFieldMatch(id string, where whereT) bool {
+	var value string
+	if sw.col != nil {
+		value, _ = sw.col.StringField(id, where.field)
+	}
+	return where.matchString(value)
+}
+
+func (sw *scanWriter) fieldMatch(id string, fields []float64, o geojson.Object) (fvals []float64, match bool) {
 	fvals = sw.fvals
+	var zs []float64
 	if !sw.hasFieldsOutput() || sw.fullFields {
 		for _, where := range sw.wheres {
+			if where.eq != nil {
+				if !sw.stringFieldMatch(id, where) {
+					return
+				}
+				continue
+			}
 			if where.field == "z" {
-				if !gotz {
-					if point, ok := o.(*geojson.Point); ok {
-						z = point.Z()
-					}
+				if zs == nil {
+					zs = sw.objectZ(id, o)
 				}
-				if !where.match(z) {
+				if !whereZ(where, zs) {
 					return
 				}
 				continue
@@ -268,13 +516,17 @@ func (sw *scanWriter) fieldMatch(fields []float64, o geojson.Object) (fvals []fl
 			sw.fvals[i] = 0
 		}
 		for _, where := range sw.wheres {
+			if where.eq != nil {
+				if !sw.stringFieldMatch(id, where) {
+					return
+				}
+				continue
+			}
 			if where.field == "z" {
-				if !gotz {
-					if point, ok := o.(*geojson.Point); ok {
-						z = point.Z()
-					}
+				if zs == nil {
+					zs = sw.objectZ(id, o)
 				}
-				if !where.match(z) {
+				if !whereZ(where, zs) {
 					return
 				}
 				continue
@@ -353,16 +605,41 @@ func (sw *scanWriter) testObject(id string, o geojson.Object, fields []float64)
 	if !match {
 		return false, kg, fieldVals
 	}
-	nf, ok := sw.fieldMatch(fields, o)
+	if sw.minExpires != 0 && sw.col != nil {
+		if ex, _ := sw.col.Expires(id); ex != 0 && ex < sw.minExpires {
+			return false, true, fieldVals
+		}
+	}
+	for _, tag := range sw.tagged {
+		if sw.col == nil || !sw.col.HasTag(id, tag) {
+			return false, true, fieldVals
+		}
+	}
+	nf, ok := sw.fieldMatch(id, fields, o)
 	return ok, true, nf
 }
 
+// appendObjectJSON appends the object json, applying the PRECISION and
+// SIMPLIFY output options.
+func (sw *scanWriter) appendObjectJSON(dst []byte, o geojson.Object) []byte {
+	if !sw.reshape.Enabled() {
+		return o.AppendJSON(dst)
+	}
+	return simplify.AppendJSON(dst, o.AppendJSON(nil), sw.reshape)
+}
+
 //id string, o geojson.Object, fields []float64, noLock bool
 func (sw *scanWriter) writeObject(opts ScanWriterParams) bool {
 	if !opts.noLock {
 		sw.mu.Lock()
 		defer sw.mu.Unlock()
 	}
+	if sw.budget != 0 && sw.numberItems > 0 &&
+		time.Now().UnixNano() >= sw.budget {
+		// WITHINCOMPLETE, after one object at least for the cursor to move
+		sw.partial = true
+		return false
+	}
 	ok, keepGoing, _ := sw.testObject(opts.id, opts.o, opts.fields)
 	if !ok {
 		return keepGoing
@@ -371,13 +648,53 @@ func (sw *scanWriter) writeObject(opts ScanWriterParams) bool {
 	if sw.output == outputCount {
 		return sw.count < sw.limit
 	}
+	if sw.redact != nil {
+		opts.o = sw.redact.object(opts.o)
+		opts.distance = sw.redact.distance(opts.distance)
+	}
+	if sw.output == outputCluster {
+		if objIsSpatial(opts.o) {
+			center := opts.o.Center()
+			sw.clusterPoints = append(sw.clusterPoints,
+				cluster.Point{ID: opts.id, X: center.X, Y: center.Y})
+		}
+		return sw.count < sw.limit
+	}
+	if sw.output == outputHull {
+		sw.addHullPoints(opts.o)
+		return sw.count < sw.limit
+	}
+	if sw.output == outputCentroid {
+		sw.addCentroid(opts.o, opts.fields)
+		return sw.count < sw.limit
+	}
 	if opts.clip != nil {
 		opts.o = clip.Clip(opts.o, opts.clip, &sw.s.geomIndexOpts)
 	}
-	switch sw.msg.OutputType {
-	case JSON:
+	if sw.crs != nil {
+		opts.o = sw.s.transformObject(opts.o, sw.crs)
+	}
+	switch {
+	case sw.output == outputFlatGeobuf:
+		values := make([]float64, len(sw.onames))
+		for i := range values {
+			values[i] = sw.fieldValue(i, opts.fields)
+		}
+		sw.fgb.Add(opts.id, opts.o, values)
+	case sw.streaming():
+		values := make([]float64, len(sw.onames))
+		for i := range values {
+			values[i] = sw.fieldValue(i, opts.fields)
+		}
+		line := appendNDJSONFeature(nil, opts.id, opts.o, sw.onames, values,
+			opts.distance, opts.distOutput, sw.reshape)
+		if err := sw.msg.ndjson.writeRecord(line); err != nil {
+			// the client has likely gone away
+			return false
+		}
+	case sw.msg.OutputType == JSON:
 		var wr bytes.Buffer
-		var jsfields string
+		var jsfields []byte
 		if sw.once {
 			wr.WriteByte(',')
 		} else {
@@ -386,89 +703,105 @@ func (sw *scanWriter) writeObject(opts ScanWriterParams) bool {
 		if sw.hasFieldsOutput() {
 			if sw.fullFields {
 				if len(sw.fmap) > 0 {
-					jsfields = `,"fields":{`
+					jsfields = append(jsfields, `,"fields":{`...)
 					var i int
 					for field, idx := range sw.fmap {
+						if !sw.isSelected(field) {
+							continue
+						}
 						if len(opts.fields) > idx {
 							if opts.fields[idx] != 0 {
 								if i > 0 {
-									jsfields += `,`
+									jsfields = append(jsfields, ',')
 								}
-								jsfields += jsonString(field) + ":" + strconv.FormatFloat(opts.fields[idx], 'f', -1, 64)
+								jsfields = appendJSONString(jsfields, field)
+								jsfields = append(jsfields, ':')
+								jsfields = strconv.AppendFloat(jsfields, opts.fields[idx], 'f', -1, 64)
 								i++
 							}
 						}
 					}
-					jsfields += `}`
+					jsfields = append(jsfields, '}')
 				}
 
-			} else if len(sw.farr) > 0 {
-				jsfields = `,"fields":[`
-				for i, name := range sw.farr {
+			} else if len(sw.onames) > 0 {
+				jsfields = append(jsfields, `,"fields":[`...)
+				for i := range sw.onames {
 					if i > 0 {
-						jsfields += `,`
-					}
-					j := sw.fmap[name]
-					if j < len(opts.fields) {
-						jsfields += strconv.FormatFloat(opts.fields[j], 'f', -1, 64)
-					} else {
-						jsfields += "0"
+						jsfields = append(jsfields, ',')
 					}
+					jsfields = strconv.AppendFloat(jsfields,
+						sw.fieldValue(i, opts.fields), 'f', -1, 64)
 				}
-				jsfields += `]`
+				jsfields = append(jsfields, ']')
 			}
 		}
-		if sw.output == outputIDs {
+		if sw.output == outputIDs && !sw.hasFieldsOutput() && !sw.withExpires &&
+			!sw.withTTL {
 			wr.WriteString(jsonString(opts.id))
 		} else {
 			wr.WriteString(`{"id":` + jsonString(opts.id))
 			switch sw.output {
 			case outputObjects:
-				wr.WriteString(`,"object":` + string(opts.o.AppendJSON(nil)))
+				wr.WriteString(`,"object":` + string(sw.appendObjectJSON(nil, opts.o)))
 			case outputPoints:
-				wr.WriteString(`,"point":` + string(appendJSONSimplePoint(nil, opts.o)))
+				wr.WriteString(`,"point":` + string(appendJSONSimplePoint(nil, opts.o, sw.reshape.Precision)))
 			case outputHashes:
 				center := opts.o.Center()
 				p := geohash.EncodeWithPrecision(center.Y, center.X, uint(sw.precision))
 				wr.WriteString(`,"hash":"` + p + `"`)
 			case outputBounds:
-				wr.WriteString(`,"bounds":` + string(appendJSONSimpleBounds(nil, opts.o)))
+				wr.WriteString(`,"bounds":` + string(appendJSONSimpleBounds(nil, opts.o, sw.reshape.Precision)))
 			}
 
-			wr.WriteString(jsfields)
+			wr.Write(jsfields)
 
 			if opts.distOutput || opts.distance > 0 {
 				wr.WriteString(`,"distance":` + strconv.FormatFloat(opts.distance, 'f', -1, 64))
 			}
+			if sw.withExpires {
+				if ex := sw.expires(opts.id); ex != -1 {
+					wr.WriteString(`,"expires":` + strconv.FormatInt(ex, 10))
+				}
+			}
+			if sw.withTTL {
+				if ttl := sw.ttl(opts.id); ttl != -1 {
+					wr.WriteString(`,"ttl":` +
+						strconv.FormatFloat(ttl, 'f', -1, 64))
+				}
+			}
 
 			wr.WriteString(`}`)
 		}
 		sw.wr.Write(wr.Bytes())
-	case RESP:
+	case sw.msg.OutputType == RESP:
 		vals := make([]resp.Value, 1, 3)
 		vals[0] = resp.StringValue(opts.id)
-		if sw.output == outputIDs {
+		if sw.output == outputIDs && !sw.hasFieldsOutput() && !sw.withExpires &&
+			!sw.withTTL {
 			sw.values = append(sw.values, vals[0])
 		} else {
 			switch sw.output {
 			case outputObjects:
-				vals = append(vals, resp.StringValue(opts.o.String()))
+				vals = append(vals, resp.StringValue(
+					string(sw.appendObjectJSON(nil, opts.o))))
 			case outputPoints:
 				point := opts.o.Center()
 				var z float64
 				if point, ok := opts.o.(*geojson.Point); ok {
 					z = point.Z()
 				}
+				digits := sw.reshape.Precision
 				if z != 0 {
 					vals = append(vals, resp.ArrayValue([]resp.Value{
-						resp.FloatValue(point.Y),
-						resp.FloatValue(point.X),
-						resp.FloatValue(z),
+						resp.FloatValue(simplify.Round(point.Y, digits)),
+						resp.FloatValue(simplify.Round(point.X, digits)),
+						resp.FloatValue(simplify.Round(z, digits)),
 					}))
 				} else {
 					vals = append(vals, resp.ArrayValue([]resp.Value{
-						resp.FloatValue(point.Y),
-						resp.FloatValue(point.X),
+						resp.FloatValue(simplify.Round(point.Y, digits)),
+						resp.FloatValue(simplify.Round(point.X, digits)),
 					}))
 				}
 			case outputHashes:
@@ -477,32 +810,39 @@ func (sw *scanWriter) writeObject(opts ScanWriterParams) bool {
 				vals = append(vals, resp.StringValue(p))
 			case outputBounds:
 				bbox := opts.o.Rect()
+				digits := sw.reshape.Precision
 				vals = append(vals, resp.ArrayValue([]resp.Value{
 					resp.ArrayValue([]resp.Value{
-						resp.FloatValue(bbox.Min.Y),
-						resp.FloatValue(bbox.Min.X),
+						resp.FloatValue(simplify.Round(bbox.Min.Y, digits)),
+						resp.FloatValue(simplify.Round(bbox.Min.X, digits)),
 					}),
 					resp.ArrayValue([]resp.Value{
-						resp.FloatValue(bbox.Max.Y),
-						resp.FloatValue(bbox.Max.X),
+						resp.FloatValue(simplify.Round(bbox.Max.Y, digits)),
+						resp.FloatValue(simplify.Round(bbox.Max.X, digits)),
 					}),
 				}))
 			}
 
 			if sw.hasFieldsOutput() {
-				fvs := orderFields(sw.fmap, sw.farr, opts.fields)
-				if len(fvs) > 0 {
-					fvals := make([]resp.Value, 0, len(fvs)*2)
-					for i, fv := range fvs {
-						fvals = append(fvals, resp.StringValue(fv.field), resp.StringValue(strconv.FormatFloat(fv.value, 'f', -1, 64)))
-						i++
+				var fvals []resp.Value
+				for i, name := range sw.onames {
+					if value := sw.fieldValue(i, opts.fields); value != 0 {
+						fvals = append(fvals, resp.StringValue(name), resp.StringValue(strconv.FormatFloat(value, 'f', -1, 64)))
 					}
+				}
+				if len(fvals) > 0 {
 					vals = append(vals, resp.ArrayValue(fvals))
 				}
 			}
 			if opts.distOutput || opts.distance > 0 {
 				vals = append(vals, resp.FloatValue(opts.distance))
 			}
+			if sw.withExpires {
+				vals = append(vals, resp.IntegerValue(int(sw.expires(opts.id))))
+			}
+			if sw.withTTL {
+				vals = append(vals, resp.IntegerValue(int(sw.ttl(opts.id))))
+			}
 
 			sw.values = append(sw.values, resp.ArrayValue(vals))
 		}
@@ -514,3 +854,79 @@ func (sw *scanWriter) writeObject(opts ScanWriterParams) bool {
 	}
 	return keepGoing
 }
+
+// expires returns the expiration of an object as a unix timestamp in
+// milliseconds, or -1 when it doesn't expire.
+func (sw *scanWriter) expires(id string) int64 {
+	ex, ok := sw.col.Expires(id)
+	if !ok || ex == 0 {
+		return -1
+	}
+	return ex / int64(time.Millisecond)
+}
+
+// ttl returns the remaining ttl of an object in seconds, or -1 when it
+// doesn't expire. Like TTL, RESP truncates it to whole seconds.
+func (sw *scanWriter) ttl(id string) float64 {
+	ex, ok := sw.col.Expires(id)
+	if !ok || ex == 0 {
+		return -1
+	}
+	ttl := float64(ex-time.Now().UnixNano()) / float64(time.Second)
+	if ttl < 0 {
+		ttl = 0
+	}
+	return ttl
+}
+
+// expiresOptions sets the WITHEXPIRES, WITHTTL and MINTTL options.
+func (sw *scanWriter) expiresOptions(t searchScanBaseTokens) {
+	sw.withExpires = t.withexpires
+	sw.withTTL = t.withttl
+	sw.minExpires = t.minExpires()
+}
+
+// budgetOptions sets the WITHINCOMPLETE option, with the budget starting
+// now.
+func (sw *scanWriter) budgetOptions(t searchScanBaseTokens) {
+	if t.incomplete != 0 {
+		sw.incomplete = true
+		sw.budget = time.Now().Add(t.incomplete).UnixNano()
+	}
+}
+
+// countsAll returns true when the output is COUNT and every object that's
+// found is counted, as there are no filters and no cursor.
+func (sw *scanWriter) countsAll() bool {
+	return sw.output == outputCount && sw.cursor == 0 &&
+		len(sw.wheres) == 0 && len(sw.whereins) == 0 &&
+		len(sw.whereevals) == 0 && len(sw.tagged) == 0 &&
+		sw.minExpires == 0 && sw.globEverything
+}
+
+// tagOptions sets the TAGGED option.
+func (sw *scanWriter) tagOptions(t searchScanBaseTokens) {
+	sw.tagged = t.tagged
+}
+
+// leastTagged returns the TAGGED tag that the fewest objects have, which
+// is the shortest of the inverted indexes to scan, or false without TAGGED.
+func (sw *scanWriter) leastTagged() (string, bool) {
+	var least string
+	var count int
+	for i, tag := range sw.tagged {
+		if n := sw.col.TagCount(tag); i == 0 || n < count {
+			least, count = tag, n
+		}
+	}
+	return least, len(sw.tagged) > 0
+}
+
+// aggregateOptions sets the options of the HULL and CENTROID outputs.
+func (sw *scanWriter) aggregateOptions(t searchScanBaseTokens) error {
+	if t.centroid != "" && sw.redact != nil && sw.redact.hidden(t.centroid) {
+		return errFieldRedacted(t.centroid)
+	}
+	sw.hull, sw.centroidField = t.hull, t.centroid
+	return nil
+}
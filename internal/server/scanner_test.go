@@ -31,8 +31,8 @@ func BenchmarkFieldMatch(t *testing.B) {
 	}
 	sw := &scanWriter{
 		wheres: []whereT{
-			{"foo", 0, false, 1, false, 3},
-			{"bar", 1, false, 10, false, 30},
+			{"foo", 0, false, 1, false, 3, nil},
+			{"bar", 1, false, 10, false, 30, nil},
 		},
 		whereins: []whereinT{
 			{"foo", 0, []float64{1, 2}},
@@ -46,7 +46,7 @@ func BenchmarkFieldMatch(t *testing.B) {
 	for i := 0; i < t.N; i++ {
 		// one call is super fast, measurements are not reliable, let's do 100
 		for ix := 0; ix < 100; ix++ {
-			sw.fieldMatch(items[i].fields, items[i].object)
+			sw.fieldMatch("", items[i].fields, items[i].object)
 		}
 	}
 }
@@ -0,0 +1,393 @@
+package server
+
+// Schemas: the objects and fields that are set in a key that has a SCHEMASET
+// are checked against the schema of the key. A schema has the field names
+// and types that are allowed, the fields that a new object must have, and the
+// geometry types that are allowed. The objects that are already in the key
+// aren't checked.
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+)
+
+// The types of schema fields.
+const (
+	schemaNumber  = "number"
+	schemaInteger = "integer"
+	schemaBoolean = "boolean" // 0 or 1
+)
+
+// schemaGeometries are the geometry types of schemas. The type of a Feature
+// is the type of its geometry, and String is for string objects.
+var schemaGeometries = []string{
+	"Point", "MultiPoint", "LineString", "MultiLineString", "Polygon",
+	"MultiPolygon", "GeometryCollection", "FeatureCollection", "String",
+}
+
+// schemaField is a field of a schema.
+type schemaField struct {
+	typ      string
+	required bool // new objects must have the field
+}
+
+// keySchema is the SCHEMASET of a key.
+type keySchema struct {
+	fields     map[string]schemaField // any field when empty
+	geometries []string               // any geometry when empty
+}
+
+func errSchemaField(key, name string) error {
+	return errors.New("field '" + name + "' is not in the schema of " + key)
+}
+
+func errSchemaFieldType(name, typ string) error {
+	article := "a"
+	if typ == schemaInteger {
+		article = "an"
+	}
+	return errors.New("field '" + name + "' must be " + article + " " + typ)
+}
+
+func errSchemaRequired(name string) error {
+	return errors.New("missing required field '" + name + "'")
+}
+
+func errSchemaGeometry(key, typ string) error {
+	return errors.New("geometry '" + typ + "' is not allowed in " + key)
+}
+
+// cmdSchemaSet sets the schema of a key, replacing the schema that it had.
+//
+//	SCHEMASET key [FIELD name NUMBER|INTEGER|BOOLEAN [REQUIRED] ...]
+//	              [GEOMETRY type [type ...]]
+func (server *Server) cmdSchemaSet(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	schema := keySchema{fields: make(map[string]schemaField)}
+	for len(vs) > 0 {
+		var arg string
+		vs, arg, _ = tokenval(vs)
+		switch {
+		case lc(arg, "field"):
+			var name, typ string
+			if vs, name, ok = tokenval(vs); !ok || name == "" {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			if isReservedFieldName(name) {
+				err = errInvalidArgument(name)
+				return
+			}
+			if _, ok := schema.fields[name]; ok {
+				err = errDuplicateArgument(name)
+				return
+			}
+			if vs, typ, ok = tokenval(vs); !ok || typ == "" {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			var field schemaField
+			switch strings.ToLower(typ) {
+			case schemaNumber, schemaInteger, schemaBoolean:
+				field.typ = strings.ToLower(typ)
+			default:
+				err = errInvalidArgument(typ)
+				return
+			}
+			if len(vs) > 0 && lc(vs[0], "required") {
+				vs = vs[1:]
+				field.required = true
+			}
+			schema.fields[name] = field
+		case lc(arg, "geometry"):
+			if len(schema.geometries) > 0 {
+				err = errDuplicateArgument(arg)
+				return
+			}
+			for len(vs) > 0 && !lc(vs[0], "field") {
+				var typ string
+				vs, typ, _ = tokenval(vs)
+				var found bool
+				for _, name := range schemaGeometries {
+					if strings.EqualFold(typ, name) {
+						schema.geometries = append(schema.geometries, name)
+						found = true
+						break
+					}
+				}
+				if !found {
+					err = errInvalidArgument(typ)
+					return
+				}
+			}
+			if len(schema.geometries) == 0 {
+				err = errInvalidNumberOfArguments
+				return
+			}
+		default:
+			err = errInvalidArgument(arg)
+			return
+		}
+	}
+	server.schemas[d.key] = schema
+	d.command = "schemaset"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// cmdSchemaDel removes the schema of a key.
+//
+//	SCHEMADEL key
+func (server *Server) cmdSchemaDel(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if len(vs) != 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var n int
+	if _, ok := server.schemas[d.key]; ok {
+		delete(server.schemas, d.key)
+		n = 1
+	}
+	d.command = "schemadel"
+	d.updated = n > 0
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
+
+// cmdSchemaGet returns the schema of a key. The RESP reply is the arguments
+// of the SCHEMASET of the key, and null when it has none.
+//
+//	SCHEMAGET key
+func (server *Server) cmdSchemaGet(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if len(vs) != 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	schema, ok := server.schemas[key]
+	switch msg.OutputType {
+	case JSON:
+		if !ok {
+			return NOMessage, errors.New("schema not found")
+		}
+		buf := []byte(`{"ok":true,"schema":{"fields":{`)
+		for i, name := range schema.fieldNames() {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			field := schema.fields[name]
+			buf = appendJSONString(buf, name)
+			buf = append(buf, `:{"type":"`+field.typ+`"`...)
+			if field.required {
+				buf = append(buf, `,"required":true`...)
+			}
+			buf = append(buf, '}')
+		}
+		buf = append(buf, `},"geometry":[`...)
+		for i, typ := range schema.geometries {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, typ)
+		}
+		buf = append(buf, `]},"elapsed":"`+time.Since(start).String()+`"}`...)
+		res = resp.StringValue(string(buf))
+	case RESP:
+		if !ok {
+			return resp.NullValue(), nil
+		}
+		args := schema.args()
+		vals := make([]resp.Value, len(args))
+		for i, arg := range args {
+			vals[i] = resp.StringValue(arg)
+		}
+		res = resp.ArrayValue(vals)
+	}
+	return
+}
+
+// fieldNames returns the sorted names of the fields of a schema.
+func (schema *keySchema) fieldNames() []string {
+	names := make([]string, 0, len(schema.fields))
+	for name := range schema.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// args returns the SCHEMASET arguments of a schema, after the key.
+func (schema *keySchema) args() []string {
+	var args []string
+	for _, name := range schema.fieldNames() {
+		field := schema.fields[name]
+		args = append(args, "FIELD", name, strings.ToUpper(field.typ))
+		if field.required {
+			args = append(args, "REQUIRED")
+		}
+	}
+	if len(schema.geometries) > 0 {
+		args = append(append(args, "GEOMETRY"), schema.geometries...)
+	}
+	return args
+}
+
+// schema returns the schema of a key, or nil when there's none. It's also
+// nil for commands that are loaded from the aof, which were checked when
+// they were written.
+func (server *Server) schema(msg *Message, key string) *keySchema {
+	if msg.ConnType == Null && msg.OutputType == Null {
+		return nil
+	}
+	schema, ok := server.schemas[key]
+	if !ok {
+		return nil
+	}
+	return &schema
+}
+
+// checkFields returns an error when a field isn't in the schema, or when its
+// value isn't of the type of the field.
+func (schema *keySchema) checkFields(key string, fields []string, values []float64) error {
+	if len(schema.fields) == 0 {
+		return nil
+	}
+	for i, name := range fields {
+		field, ok := schema.fields[name]
+		if !ok {
+			return errSchemaField(key, name)
+		}
+		value := values[i]
+		switch field.typ {
+		case schemaInteger:
+			ok = value == math.Trunc(value) && !math.IsInf(value, 0)
+		case schemaBoolean:
+			ok = value == 0 || value == 1
+		}
+		if !ok {
+			return errSchemaFieldType(name, field.typ)
+		}
+	}
+	return nil
+}
+
+// checkObject returns an error when an object that's set in a key doesn't
+// match the schema. The required fields are only checked for new objects,
+// because the objects that are replaced keep their fields.
+func (schema *keySchema) checkObject(col *collection.Collection, key, id string,
+	obj geojson.Object, fields []string, values []float64,
+) error {
+	if len(schema.geometries) > 0 {
+		typ := schemaGeometry(obj)
+		var ok bool
+		for _, allowed := range schema.geometries {
+			if typ == allowed {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return errSchemaGeometry(key, typ)
+		}
+	}
+	if err := schema.checkFields(key, fields, values); err != nil {
+		return err
+	}
+	if col != nil {
+		if _, _, _, ok := col.Get(id); ok {
+			return nil
+		}
+	}
+	for _, name := range schema.fieldNames() {
+		if !schema.fields[name].required {
+			continue
+		}
+		var ok bool
+		for _, field := range fields {
+			if field == name {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return errSchemaRequired(name)
+		}
+	}
+	return nil
+}
+
+// schemaGeometry returns the geometry type of an object.
+func schemaGeometry(obj geojson.Object) string {
+	switch obj := obj.(type) {
+	case collection.String:
+		return "String"
+	case *geojson.Point, *geojson.SimplePoint:
+		return "Point"
+	case *geojson.Rect:
+		return "Polygon"
+	case *geojson.LineString:
+		return "LineString"
+	case *geojson.Polygon:
+		return "Polygon"
+	default:
+		res := gjson.Parse(obj.JSON())
+		if res.Get("type").String() == "Feature" {
+			res = res.Get("geometry")
+		}
+		return res.Get("type").String()
+	}
+}
+
+// schemaCommands returns the commands that rebuild the SCHEMASET of the
+// keys, for aofshrink.
+func (server *Server) schemaCommands() [][]string {
+	keys := make([]string, 0, len(server.schemas))
+	for key := range server.schemas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmds := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		schema := server.schemas[key]
+		cmds = append(cmds, append([]string{"schemaset", key}, schema.args()...))
+	}
+	return cmds
+}
@@ -2,7 +2,6 @@ package server
 
 import (
 	"bytes"
-	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
@@ -397,9 +396,7 @@ func (s *Server) cmdEvalUnified(scriptIsSha bool, msg *Message) (res resp.Value,
 	luaDeadline := lua.LNil
 	if msg.Deadline != nil {
 		dlTime := msg.Deadline.GetDeadlineTime()
-		ctx, cancel := context.WithDeadline(context.Background(), dlTime)
-		defer cancel()
-		luaState.SetContext(ctx)
+		luaState.SetContext(msg.Deadline.Context())
 		defer luaState.RemoveContext()
 		luaDeadline = lua.LNumber(float64(dlTime.UnixNano()) / 1e9)
 	}
@@ -468,7 +465,8 @@ func (s *Server) cmdEvalUnified(scriptIsSha bool, msg *Message) (res resp.Value,
 			"EVAL_CMD": lua.LNil,
 		})
 	if err := luaState.PCall(0, 1, nil); err != nil {
-		if strings.Contains(err.Error(), "context deadline exceeded") {
+		if strings.Contains(err.Error(), "context deadline exceeded") ||
+			strings.Contains(err.Error(), "context canceled") {
 			msg.Deadline.Check()
 		}
 		log.Debugf("%v", err.Error())
@@ -595,13 +593,17 @@ func (s *Server) commandInScript(msg *Message) (
 		res, d, err = s.cmdSet(msg)
 	case "fset":
 		res, d, err = s.cmdFset(msg)
+	case "mset":
+		res, d, err = s.cmdMset(msg)
+	case "mfset":
+		res, d, err = s.cmdMfset(msg)
 	case "del":
 		res, d, err = s.cmdDel(msg)
 	case "pdel":
 		res, d, err = s.cmdPdel(msg)
 	case "drop":
 		res, d, err = s.cmdDrop(msg)
-	case "expire":
+	case "expire", "pexpire", "expireat", "pexpireat":
 		res, d, err = s.cmdExpire(msg)
 	case "rename":
 		res, d, err = s.cmdRename(msg, false)
@@ -609,7 +611,7 @@ func (s *Server) commandInScript(msg *Message) (
 		res, d, err = s.cmdRename(msg, true)
 	case "persist":
 		res, d, err = s.cmdPersist(msg)
-	case "ttl":
+	case "ttl", "pttl":
 		res, err = s.cmdTTL(msg)
 	case "stats":
 		res, err = s.cmdStats(msg)
@@ -633,6 +635,16 @@ func (s *Server) commandInScript(msg *Message) (
 		res, d, err = s.cmdJset(msg)
 	case "jdel":
 		res, d, err = s.cmdJdel(msg)
+	case "jincrby":
+		res, d, err = s.cmdJincrby(msg, false)
+	case "jdecrby":
+		res, d, err = s.cmdJincrby(msg, true)
+	case "jappend":
+		res, d, err = s.cmdJappend(msg)
+	case "fincrby", "fdecrby", "fmin", "fmax":
+		res, d, err = s.cmdFincrby(msg)
+	case "jinsert":
+		res, d, err = s.cmdJinsert(msg)
 	case "type":
 		res, err = s.cmdType(msg)
 	case "keys":
@@ -659,9 +671,9 @@ func (s *Server) luaTile38Call(evalcmd string, cmd string, args ...string) (resp
 
 	switch msg.Command() {
 	case "ping", "echo", "auth", "massinsert", "shutdown", "gc",
-		"sethook", "pdelhook", "delhook",
+		"sethook", "pdelhook", "delhook", "hookimport",
 		"follow", "readonly", "config", "output", "client",
-		"aofshrink",
+		"aofshrink", "idempotent", "verify",
 		"script load", "script exists", "script flush",
 		"eval", "evalsha", "evalro", "evalrosha", "evalna", "evalnasha":
 		return resp.NullValue(), errCmdNotSupported
@@ -687,7 +699,9 @@ func (s *Server) luaTile38AtomicRW(msg *Message) (resp.Value, error) {
 	default:
 		return resp.NullValue(), errCmdNotSupported
 	case "set", "del", "drop", "fset", "flushdb", "expire", "persist", "jset", "pdel",
-		"rename", "renamenx":
+		"rename", "renamenx", "mset", "mfset", "pexpire", "expireat", "pexpireat",
+		"jincrby", "jdecrby", "jappend", "jinsert", "fincrby", "fdecrby", "fmin",
+		"fmax":
 		// write operations
 		write = true
 		if s.config.followHost() != "" {
@@ -696,8 +710,11 @@ func (s *Server) luaTile38AtomicRW(msg *Message) (resp.Value, error) {
 		if s.config.readOnly() {
 			return resp.NullValue(), errReadOnly
 		}
+		if err := s.writesPausedErr(); err != nil {
+			return resp.NullValue(), err
+		}
 	case "get", "keys", "scan", "nearby", "within", "intersects", "hooks", "search",
-		"ttl", "bounds", "server", "info", "type", "jget", "test":
+		"ttl", "pttl", "bounds", "server", "info", "type", "jget", "test":
 		// read operations
 		if s.config.followHost() != "" && !s.fcuponce {
 			return resp.NullValue(), errCatchingUp
@@ -745,12 +762,14 @@ func (s *Server) luaTile38AtomicRO(msg *Message) (resp.Value, error) {
 		return resp.NullValue(), errCmdNotSupported
 
 	case "set", "del", "drop", "fset", "flushdb", "expire", "persist", "jset", "pdel",
-		"rename", "renamenx":
+		"rename", "renamenx", "mset", "mfset", "pexpire", "expireat", "pexpireat",
+		"jincrby", "jdecrby", "jappend", "jinsert", "fincrby", "fdecrby", "fmin",
+		"fmax":
 		// write operations
 		return resp.NullValue(), errReadOnly
 
 	case "get", "keys", "scan", "nearby", "within", "intersects", "hooks", "search",
-		"ttl", "bounds", "server", "info", "type", "jget", "test":
+		"ttl", "pttl", "bounds", "server", "info", "type", "jget", "test":
 		// read operations
 		if s.config.followHost() != "" && !s.fcuponce {
 			return resp.NullValue(), errCatchingUp
@@ -789,7 +808,9 @@ func (s *Server) luaTile38NonAtomic(msg *Message) (resp.Value, error) {
 	default:
 		return resp.NullValue(), errCmdNotSupported
 	case "set", "del", "drop", "fset", "flushdb", "expire", "persist", "jset", "pdel",
-		"rename", "renamenx":
+		"rename", "renamenx", "mset", "mfset", "pexpire", "expireat", "pexpireat",
+		"jincrby", "jdecrby", "jappend", "jinsert", "fincrby", "fdecrby", "fmin",
+		"fmax":
 		// write operations
 		write = true
 		s.mu.Lock()
@@ -800,11 +821,19 @@ func (s *Server) luaTile38NonAtomic(msg *Message) (resp.Value, error) {
 		if s.config.readOnly() {
 			return resp.NullValue(), errReadOnly
 		}
-	case "get", "keys", "scan", "nearby", "within", "intersects", "hooks", "search",
-		"ttl", "bounds", "server", "info", "type", "jget", "test":
+		if err := s.writesPausedErr(); err != nil {
+			return resp.NullValue(), err
+		}
+	case "get", "scan", "nearby", "within", "intersects", "search", "ttl", "pttl",
+		"bounds", "type", "jget":
+		// read operations on a single key
+		defer s.lockRead(msg)()
+		if s.config.followHost() != "" && !s.fcuponce {
+			return resp.NullValue(), errCatchingUp
+		}
+	case "keys", "hooks", "server", "info", "test":
 		// read operations
-		s.mu.RLock()
-		defer s.mu.RUnlock()
+		defer s.lockAllRead()()
 		if s.config.followHost() != "" && !s.fcuponce {
 			return resp.NullValue(), errCatchingUp
 		}
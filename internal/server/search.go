@@ -13,6 +13,7 @@ import (
 	"github.com/tidwall/resp"
 	"github.com/tidwall/tile38/internal/bing"
 	"github.com/tidwall/tile38/internal/clip"
+	"github.com/tidwall/tile38/internal/collection"
 	"github.com/tidwall/tile38/internal/glob"
 )
 
@@ -276,7 +277,7 @@ func (server *Server) cmdSearchArgs(
 			err = errInvalidNumberOfArguments
 			return
 		}
-		s.obj, err = geojson.Parse(obj, &server.geomParseOpts)
+		s.obj, err = server.parseObjectArea(obj)
 		if err != nil {
 			return
 		}
@@ -405,10 +406,16 @@ func (server *Server) cmdNearby(msg *Message) (res resp.Value, err error) {
 	}
 	sw, err := server.newScanWriter(
 		wr, msg, s.key, s.output, s.precision, s.glob, false,
-		s.cursor, s.limit, s.wheres, s.whereins, s.whereevals, s.nofields)
+		s.cursor, s.limit, s.wheres, s.whereins, s.whereevals, s.nofields, s.selected, s.reshape, s.crs)
 	if err != nil {
 		return NOMessage, err
 	}
+	sw.expiresOptions(s.searchScanBaseTokens)
+	sw.tagOptions(s.searchScanBaseTokens)
+	sw.budgetOptions(s.searchScanBaseTokens)
+	if err := sw.aggregateOptions(s.searchScanBaseTokens); err != nil {
+		return NOMessage, err
+	}
 	if msg.OutputType == JSON {
 		wr.WriteString(`{"ok":true`)
 	}
@@ -453,7 +460,11 @@ func (server *Server) cmdNearby(msg *Message) (res resp.Value, err error) {
 				}
 				return iterStep(id, o, fields, meters)
 			}
-			sw.col.Nearby(s.obj, sw, msg.Deadline, iter)
+			if maxDist > 0 {
+				sw.col.NearbyWithin(s.obj, maxDist, sw, msg.Deadline, iter)
+			} else {
+				sw.col.Nearby(s.obj, sw, msg.Deadline, iter)
+			}
 		}
 	}
 	sw.writeFoot()
@@ -464,6 +475,15 @@ func (server *Server) cmdNearby(msg *Message) (res resp.Value, err error) {
 	return sw.respOut, nil
 }
 
+// rectCoversCol returns true when a rectangle contains the bounds of all of
+// the objects of a collection.
+func rectCoversCol(rect *geojson.Rect, col *collection.Collection) bool {
+	minX, minY, maxX, maxY := col.Bounds()
+	base := rect.Base()
+	return minX >= base.Min.X && minY >= base.Min.Y &&
+		maxX <= base.Max.X && maxY <= base.Max.Y
+}
+
 func (server *Server) cmdWithin(msg *Message) (res resp.Value, err error) {
 	return server.cmdWithinOrIntersects("within", msg)
 }
@@ -497,32 +517,46 @@ func (server *Server) cmdWithinOrIntersects(cmd string, msg *Message) (res resp.
 	}
 	sw, err := server.newScanWriter(
 		wr, msg, s.key, s.output, s.precision, s.glob, false,
-		s.cursor, s.limit, s.wheres, s.whereins, s.whereevals, s.nofields)
+		s.cursor, s.limit, s.wheres, s.whereins, s.whereevals, s.nofields, s.selected, s.reshape, s.crs)
 	if err != nil {
 		return NOMessage, err
 	}
+	sw.expiresOptions(s.searchScanBaseTokens)
+	sw.tagOptions(s.searchScanBaseTokens)
+	sw.budgetOptions(s.searchScanBaseTokens)
+	if err := sw.aggregateOptions(s.searchScanBaseTokens); err != nil {
+		return NOMessage, err
+	}
 	if msg.OutputType == JSON {
 		wr.WriteString(`{"ok":true`)
 	}
+	sw.zoom, sw.area = s.zoom, s.obj
 	sw.writeHead()
 	if sw.col != nil {
-		if cmd == "within" {
-			sw.col.Within(s.obj, s.sparse, sw, msg.Deadline, func(
-				id string, o geojson.Object, fields []float64,
-			) bool {
+		if sw.output == outputCluster && !sw.clusterFiltered() &&
+			s.sparse == 0 {
+			sw.clusterIdx = server.clusterIndex(s.key, sw.col, msg.Deadline)
+		} else if rect, ok := s.obj.(*geojson.Rect); ok &&
+			sw.countsAll() && s.sparse == 0 && rectCoversCol(rect, sw.col) {
+			// every object of the key is within the rectangle
+			sw.count = uint64(sw.col.CountRect(rect.Base()))
+		} else if cmd == "within" {
+			iter := func(id string, o geojson.Object, fields []float64) bool {
 				return sw.writeObject(ScanWriterParams{
 					id:     id,
 					o:      o,
 					fields: fields,
 					noLock: true,
 				})
-			})
+			}
+			if minZ, maxZ, ok := sw.zBand(); ok {
+				sw.col.WithinZ(s.obj, minZ, maxZ, s.sparse, sw, msg.Deadline,
+					iter)
+			} else {
+				sw.col.Within(s.obj, s.sparse, sw, msg.Deadline, iter)
+			}
 		} else if cmd == "intersects" {
-			sw.col.Intersects(s.obj, s.sparse, sw, msg.Deadline, func(
-				id string,
-				o geojson.Object,
-				fields []float64,
-			) bool {
+			iter := func(id string, o geojson.Object, fields []float64) bool {
 				params := ScanWriterParams{
 					id:     id,
 					o:      o,
@@ -533,7 +567,13 @@ func (server *Server) cmdWithinOrIntersects(cmd string, msg *Message) (res resp.
 					params.clip = s.obj
 				}
 				return sw.writeObject(params)
-			})
+			}
+			if minZ, maxZ, ok := sw.zBand(); ok {
+				sw.col.IntersectsZ(s.obj, minZ, maxZ, s.sparse, sw,
+					msg.Deadline, iter)
+			} else {
+				sw.col.Intersects(s.obj, s.sparse, sw, msg.Deadline, iter)
+			}
 		}
 	}
 	sw.writeFoot()
@@ -581,16 +621,23 @@ func (server *Server) cmdSearch(msg *Message) (res resp.Value, err error) {
 	}
 	sw, err := server.newScanWriter(
 		wr, msg, s.key, s.output, s.precision, s.glob, true,
-		s.cursor, s.limit, s.wheres, s.whereins, s.whereevals, s.nofields)
+		s.cursor, s.limit, s.wheres, s.whereins, s.whereevals, s.nofields, s.selected, s.reshape, s.crs)
 	if err != nil {
 		return NOMessage, err
 	}
+	sw.expiresOptions(s.searchScanBaseTokens)
+	sw.tagOptions(s.searchScanBaseTokens)
+	sw.budgetOptions(s.searchScanBaseTokens)
+	if err := sw.aggregateOptions(s.searchScanBaseTokens); err != nil {
+		return NOMessage, err
+	}
 	if msg.OutputType == JSON {
 		wr.WriteString(`{"ok":true`)
 	}
 	sw.writeHead()
 	if sw.col != nil {
-		if sw.output == outputCount && len(sw.wheres) == 0 && sw.globEverything {
+		if sw.output == outputCount && len(sw.wheres) == 0 &&
+			len(sw.tagged) == 0 && sw.globEverything {
 			count := sw.col.Count() - int(s.cursor)
 			if count < 0 {
 				count = 0
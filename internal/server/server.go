@@ -31,13 +31,14 @@ import (
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/redcon"
 	"github.com/tidwall/resp"
-	"github.com/tidwall/rtree"
 	"github.com/tidwall/tile38/core"
 	"github.com/tidwall/tile38/internal/collection"
 	"github.com/tidwall/tile38/internal/deadline"
 	"github.com/tidwall/tile38/internal/endpoint"
 	"github.com/tidwall/tile38/internal/expire"
 	"github.com/tidwall/tile38/internal/log"
+	"github.com/tidwall/tile38/internal/repair"
+	"github.com/tidwall/tile38/internal/trajectory"
 )
 
 var errOOM = errors.New("OOM command not allowed when used memory > 'maxmemory'")
@@ -64,9 +65,17 @@ type commandDetails struct {
 	oldFields []float64         // previous object field values
 	updated   bool              // object was updated
 	timestamp time.Time         // timestamp when the update occured
+	road      string            // road id, when matched to the road network
+	areas     []string          // layer area ids, when the key has an AREACONFIG
+	tags      []string          // sorted TAG tags of SET, nil without any
+	meta      *string           // META of SET, nil without
+	sfields   []string          // SFIELD names and values of SET, in pairs
+	repair    *repair.Report    // repairs of the object, when REPAIR is used
+	expired   bool              // deleted by the expiration of the object
 	parent    bool              // when true, only children are forwarded
 	pattern   string            // PDEL key pattern
 	children  []*commandDetails // for multi actions such as "PDEL"
+	fences    *fenceUpdate      // while the hooks are matched, see fenceindex.go
 }
 
 // Server is a tile38 controller
@@ -91,13 +100,22 @@ type Server struct {
 	statsTotalCommands aint // counter for total commands
 	statsTotalMsgsSent aint // counter for total sent webhook messages
 	statsExpired       aint // item expiration counter
+	statsTenantWaited  aint // commands that waited for tenant tokens
+	statsTenantDenied  aint // commands rejected by the tenant-rate
+	statsVerifyDiff    aint // VERIFY ranges that differ from the leader
+	statsVerifyRepair  aint // VERIFY ranges repaired by the leader
+	statsOutputClosed  aint // connections closed by the output limits
+	statsOutputDropped aint // subscriber messages dropped by drop-oldest
 	lastShrinkDuration aint
 	stopServer         abool
 	outOfMemory        abool
+	evictStalled       abool // nothing left to evict, see evict.go
+	statsEvicted       aint  // evicted objects counter
 
 	connsmu sync.RWMutex
 	conns   map[int]*Client
 
+	wmu      sync.RWMutex // serializes writers, see lock.go
 	mu       sync.RWMutex
 	aof      *os.File     // active aof file
 	aofdirty int32        // mark the aofbuf as having data
@@ -107,22 +125,48 @@ type Server struct {
 	qidx     uint64       // hook queue log last idx
 	cols     *btree.BTree // data collections
 
+	hookSeqMu sync.Mutex
+	hookSeqs  map[string]uint64 // unsaved hook sequences, see hookseq.go
+
 	follows      map[*bytes.Buffer]bool
 	fcond        *sync.Cond
 	lstack       []*commandDetails
-	lives        map[*liveBuffer]bool
+	lives        map[string]map[*liveBuffer]bool // live fences by key
 	lcond        *sync.Cond
 	fcup         bool             // follow caught up
 	fcuponce     bool             // follow caught up once
 	shrinking    bool             // aof shrinking flag
 	shrinklog    [][]string       // aof shrinking log
 	hooks        map[string]*Hook // hook name
-	hookCross    *rtree.RTree     // hook spatial tree for "cross" geofences
-	hookTree     *rtree.RTree     // hook spatial tree for all
-	hooksOut     map[string]*Hook // hooks with "outside" detection
+	hookIndex    fenceIndex       // hook fences by key, see fenceindex.go
 	groupHooks   *btree.BTree     // hooks that are connected to objects
 	groupObjects *btree.BTree     // objects that are connected to hooks
 
+	tracks  map[string]*trajectory.Store // trajectories by key
+	network atomic.Value                 // *roads.Network, see roads.go
+	layers  atomic.Value                 // *layerSet, see layers.go
+
+	areaConfigs map[string]areaConfig // area lookups by key, see areas.go
+	ttlConfigs  map[string]ttlConfig  // sliding ttls by key, see ttl.go
+	schemas     map[string]keySchema  // schemas by key, see schema.go
+	namespaces  map[string]namespace  // see namespace.go
+	roles       map[string]role       // see redact.go
+	tenants     tenantBuckets         // see tenants.go
+	hotKeys     hotKeys               // see hotkeys.go
+	queries     runningQueries        // see queries.go
+	prepared    preparedQueries       // see prepared.go
+	readTouches readTouches           // objects read with ONREAD, see ttl.go
+
+	orderConfigs map[string]orderConfig     // timestamp fields, see order.go
+	keyConfigs   map[string]keyConfig       // storage options, see keyconfig.go
+	histories    map[string]*keyHistory     // object versions, see history.go
+	fleets       map[string]*fleetTracker   // moving objects, see fleet.go
+	writeBehinds map[string]*keyWriteBehind // see writebehind.go
+	readThroughs map[string]*keyReadThrough // see readthrough.go
+	tombstones   tombstones                 // deleted objects, see tombstone.go
+	coalescer    coalescer                  // held SETs, see coalesce.go
+	idempotency  idempotency                // tokens of the writes, see idempotency.go
+
 	aofconnM   map[net.Conn]io.Closer
 	luascripts *lScriptMap
 	luapool    *lStatePool
@@ -130,11 +174,38 @@ type Server struct {
 	pubsub *pubsub
 	hookex expire.List
 
+	inactex expire.List // inactivity timers of hooks, see inactive.go
+
+	activeExpireOff abool // DEBUG SET-ACTIVE-EXPIRE 0, see debug.go
+
+	pauseMu     sync.Mutex
+	pauseCond   *sync.Cond
+	pause       *writePause // WRITES PAUSE, see pause.go
+	pauseQueued int         // writes waiting for WRITES RESUME
+
+	jwtKeys jwtKeys // public keys of the JWT auth provider, see jwt.go
+
+	cluster *kubeCluster // leader of the peers, see kube.go
+
 	monconnsMu sync.RWMutex
 	monconns   map[net.Conn]bool // monitor connections
 }
 
 // Serve starts a new tile38 server
+// serving is the server that Serve is running, once its queue is loaded.
+var serving atomic.Value // *Server
+
+// Shutdown saves the state that the server keeps in memory, which is the
+// sequences of the hook events. It's called before the process exits on a
+// signal.
+func Shutdown() {
+	if server, _ := serving.Load().(*Server); server != nil {
+		if err := server.saveHookSeqs(); err != nil {
+			log.Errorf("hook sequences: %v", err)
+		}
+	}
+}
+
 func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string) error {
 	if core.AppendFileName == "" {
 		core.AppendFileName = path.Join(dir, "appendonly.aof")
@@ -151,12 +222,11 @@ func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string)
 		dir:       dir,
 		follows:   make(map[*bytes.Buffer]bool),
 		fcond:     sync.NewCond(&sync.Mutex{}),
-		lives:     make(map[*liveBuffer]bool),
+		lives:     make(map[string]map[*liveBuffer]bool),
 		lcond:     sync.NewCond(&sync.Mutex{}),
 		hooks:     make(map[string]*Hook),
-		hooksOut:  make(map[string]*Hook),
-		hookCross: &rtree.RTree{},
-		hookTree:  &rtree.RTree{},
+		hookIndex: make(fenceIndex),
+		tracks:    make(map[string]*trajectory.Store),
 		aofconnM:  make(map[net.Conn]io.Closer),
 		started:   time.Now(),
 		conns:     make(map[int]*Client),
@@ -167,6 +237,17 @@ func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string)
 
 		groupHooks:   btree.NewNonConcurrent(byGroupHook),
 		groupObjects: btree.NewNonConcurrent(byGroupObject),
+		areaConfigs:  make(map[string]areaConfig),
+		ttlConfigs:   make(map[string]ttlConfig),
+		orderConfigs: make(map[string]orderConfig),
+		keyConfigs:   make(map[string]keyConfig),
+		histories:    make(map[string]*keyHistory),
+		fleets:       make(map[string]*fleetTracker),
+		writeBehinds: make(map[string]*keyWriteBehind),
+		readThroughs: make(map[string]*keyReadThrough),
+		schemas:      make(map[string]keySchema),
+		namespaces:   make(map[string]namespace),
+		roles:        make(map[string]role),
 	}
 
 	server.hookex.Expired = func(item expire.Item) {
@@ -175,6 +256,10 @@ func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string)
 			server.possiblyExpireHook(v.Name)
 		}
 	}
+	server.pauseCond = sync.NewCond(&server.pauseMu)
+	server.inactex.Expired = func(item expire.Item) {
+		server.possiblyInactive(item.(*inactiveItem))
+	}
 	server.epc = endpoint.NewManager(server)
 	server.luascripts = server.newScriptMap()
 	server.luapool = server.newPool()
@@ -188,6 +273,9 @@ func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string)
 	if err != nil {
 		return err
 	}
+	if err := server.loadRoadNetwork(server.config.roadNetwork()); err != nil {
+		return err
+	}
 
 	// Send "500 Internal Server" error instead of "200 OK" for json responses
 	// with `"ok":false`. T38HTTP500ERRORS=1
@@ -239,6 +327,11 @@ func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string)
 	}
 	log.Debugf("Multi indexing: RTree (%d points)", server.geomParseOpts.IndexChildren)
 
+	// Reference layers are parsed with the geometry options
+	if err := server.loadLayers(server.config.layerDir()); err != nil {
+		return err
+	}
+
 	// Load the queue before the aof
 	qdb, err := buntdb.Open(core.QueueFileName)
 	if err != nil {
@@ -265,6 +358,7 @@ func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string)
 
 	server.qdb = qdb
 	server.qidx = qidx
+	serving.Store(server)
 	if err := server.migrateAOF(); err != nil {
 		return err
 	}
@@ -277,6 +371,7 @@ func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string)
 		if err := server.loadAOF(); err != nil {
 			return err
 		}
+		server.warmupConfigKeys()
 		defer func() {
 			server.flushAOF(false)
 			server.aof.Sync()
@@ -284,6 +379,11 @@ func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string)
 	}
 	// server.fillExpiresList()
 
+	server.cluster, err = server.openCluster()
+	if err != nil {
+		return err
+	}
+
 	// Start background routines
 	if server.config.followHost() != "" {
 		go server.follow(server.config.followHost(), server.config.followPort(),
@@ -293,9 +393,11 @@ func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string)
 	if metricsAddr != "" {
 		log.Infof("Listening for metrics at: %s", metricsAddr)
 		go func() {
-			http.HandleFunc("/", server.MetricsIndexHandler)
-			http.HandleFunc("/metrics", server.MetricsHandler)
-			log.Fatal(http.ListenAndServe(metricsAddr, nil))
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", server.MetricsIndexHandler)
+			mux.HandleFunc("/metrics", server.MetricsHandler)
+			handlePprof(mux)
+			log.Fatal(http.ListenAndServe(metricsAddr, mux))
 		}()
 	}
 
@@ -304,11 +406,21 @@ func Serve(host string, port int, dir string, useHTTP bool, metricsAddr string)
 	go server.watchLuaStatePool()
 	go server.watchAutoGC()
 	go server.backgroundExpiring()
+	go server.backgroundCursors()
+	go server.backgroundTenants()
+	go server.backgroundSnapshots()
 	go server.backgroundSyncAOF()
+	go server.backgroundHookSeqs()
+	if server.cluster != nil {
+		go server.backgroundCluster()
+	}
 	defer func() {
 		// Stop background routines
 		server.followc.add(1) // this will force any follow communication to die
 		server.stopServer.set(true)
+		if err := server.saveHookSeqs(); err != nil {
+			log.Errorf("hook sequences: %v", err)
+		}
 
 		// notify the live geofence connections that we are stopping.
 		server.lcond.L.Lock()
@@ -330,7 +442,7 @@ func (server *Server) isProtected() bool {
 		// -h address
 		return false
 	}
-	is := server.config.protectedMode() != "no" && server.config.requirePass() == ""
+	is := server.config.protectedMode() != "no" && !server.authRequired()
 	return is
 }
 
@@ -355,6 +467,7 @@ func (server *Server) netServe() error {
 			client.id = int(atomic.AddInt64(&clientID, 1))
 			client.opened = time.Now()
 			client.remoteAddr = conn.RemoteAddr().String()
+			client.netConn = conn
 
 			// add client to server map
 			server.connsmu.Lock()
@@ -398,11 +511,18 @@ func (server *Server) netServe() error {
 			packet := make([]byte, 0xFFFF)
 			for {
 				var close bool
-				n, err := conn.Read(packet)
-				if err != nil {
-					return
+				var in []byte
+				if len(client.pending) > 0 {
+					// input that was read while a command was running
+					in = client.pending
+					client.pending = nil
+				} else {
+					n, err := conn.Read(packet)
+					if err != nil {
+						return
+					}
+					in = packet[:n]
 				}
-				in := packet[:n]
 
 				// read the payload packet from the client input stream.
 				packet := client.in.Begin(in)
@@ -426,6 +546,9 @@ func (server *Server) netServe() error {
 						if client.outputType != Null {
 							msg.OutputType = client.outputType
 						}
+						if msg.ConnType == RESP {
+							msg.compress = client.compress
+						}
 						if msg.Command() == "quit" {
 							if msg.OutputType == RESP {
 								io.WriteString(client, "+OK\r\n")
@@ -444,6 +567,11 @@ func (server *Server) netServe() error {
 
 						// handle the command
 						err := server.handleInputCommand(client, msg)
+						if msg.ndjson != nil {
+							// the rest of the streamed results, now that the
+							// locks are released
+							msg.ndjson.close()
+						}
 						if err != nil {
 							if err.Error() == goingLive {
 								client.goLiveErr = err
@@ -483,6 +611,11 @@ func (server *Server) netServe() error {
 						}
 
 						client.outputType = msg.OutputType
+						client.compress = msg.compress
+						if server.clientOverLimit(client) {
+							close = true // close connection
+							break
+						}
 					} else {
 						client.Write([]byte("HTTP/1.1 500 Bad Request\r\nConnection: close\r\n\r\n"))
 						break
@@ -613,9 +746,11 @@ func (server *Server) watchOutOfMemory() {
 				return
 			}
 			oom := server.outOfMemory.on()
-			if server.config.maxMemory() == 0 {
+			maxMemory := server.config.maxMemory()
+			if maxMemory == 0 {
 				if oom {
 					server.outOfMemory.set(false)
+					server.evictStalled.set(false)
 				}
 				return
 			}
@@ -623,7 +758,17 @@ func (server *Server) watchOutOfMemory() {
 				runtime.GC()
 			}
 			runtime.ReadMemStats(&mem)
-			server.outOfMemory.set(int(mem.HeapAlloc) > server.config.maxMemory())
+			// reference layers don't count against maxmemory
+			heap := int(mem.HeapAlloc) - server.layersHeap()
+			oom = heap > maxMemory
+			server.outOfMemory.set(oom)
+			if !oom {
+				server.evictStalled.set(false)
+				return
+			}
+			if policy := server.config.maxMemoryPolicy(); policy != evictRejectWrites {
+				server.evictStalled.set(!server.evict(policy, heap, maxMemory))
+			}
 		}()
 	}
 }
@@ -658,8 +803,20 @@ func (server *Server) backgroundSyncAOF() {
 // the collection and the key. It's needed for support with the btree package,
 // which requires a comparator less function.
 type collectionKeyContainer struct {
-	key string
-	col *collection.Collection
+	atime int64 // unix nano of the last read or write, see evict.go
+	key   string
+	col   *collection.Collection
+	mu    sync.RWMutex // key lock, see lock.go
+
+	snap     atomic.Value // *collection.Collection, see snapshot.go
+	snapRead int64        // unix nano of the last snapshot read
+	snapMu   sync.Mutex   // serializes the stores of the snapshot
+	snapNext int64        // unix nano before which it's not copied again
+
+	clusters  atomic.Value // *clusterCache, see cluster.go
+	clusterMu sync.Mutex   // serializes the building of the cluster index
+
+	ops keyStats // operation counters, see keystats.go
 }
 
 func byCollectionKey(a, b interface{}) bool {
@@ -667,7 +824,10 @@ func byCollectionKey(a, b interface{}) bool {
 }
 
 func (server *Server) setCol(key string, col *collection.Collection) {
-	server.cols.Set(&collectionKeyContainer{key, col})
+	col.SetCodec(server.keyCodec(key))
+	col.SetZIndex(server.keyConfigs[key].zindex)
+	server.cols.Set(&collectionKeyContainer{
+		key: key, col: col, atime: time.Now().UnixNano()})
 }
 
 func (server *Server) getCol(key string) *collection.Collection {
@@ -752,27 +912,32 @@ func (server *Server) handleInputCommand(client *Client, msg *Message) error {
 				!gjson.Get(res, "ok").Bool() {
 				status = "500 Internal Server Error"
 			}
+			var encoding string
+			body, compressed := compressReply(msg.compress, []byte(res+"\r\n"))
+			if compressed {
+				encoding = "Content-Encoding: " + msg.compress.String() + "\r\n"
+			}
 			_, err := fmt.Fprintf(client, "HTTP/1.1 %s\r\n"+
 				"Connection: close\r\n"+
 				"Content-Length: %d\r\n"+
 				"Content-Type: application/json; charset=utf-8\r\n"+
-				"\r\n", status, len(res)+2)
-			if err != nil {
-				return err
-			}
-			_, err = io.WriteString(client, res)
+				"%s\r\n", status, len(body), encoding)
 			if err != nil {
 				return err
 			}
-			_, err = io.WriteString(client, "\r\n")
+			_, err = client.Write(body)
 			return err
 		case RESP:
-			var err error
+			var frame []byte
 			if msg.OutputType == JSON {
-				_, err = fmt.Fprintf(client, "$%d\r\n%s\r\n", len(res), res)
+				frame = []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(res), res))
 			} else {
-				_, err = io.WriteString(client, res)
+				frame = []byte(res)
 			}
+			if msg.compress != compressNone {
+				frame = appendCompressedFrame(nil, msg.compress, frame)
+			}
+			_, err := client.Write(frame)
 			return err
 		case Native:
 			_, err := fmt.Fprintf(client, "$%d %s\r\n", len(res), res)
@@ -819,33 +984,68 @@ func (server *Server) handleInputCommand(client *Client, msg *Message) error {
 		return nil
 	}
 
+	if msg.webUI {
+		return server.writeWebUI(client, msg)
+	}
+
 	if cmd == "timeout" {
 		if err := rewriteTimeoutMsg(msg); err != nil {
 			return writeErr(err.Error())
 		}
 	}
 
+	if msg.Command() == "idempotent" {
+		// the command runs once for the token, see idempotency.go
+		if err := rewriteIdempotentMsg(msg); err != nil {
+			return writeErr(err.Error())
+		}
+	}
+
+	if msg.Command() == "execute" {
+		// EXECUTE runs as its prepared search, see prepared.go
+		if err := server.executeArgs(msg); err != nil {
+			return writeErr(err.Error())
+		}
+	}
+
+	if msg.ConnType == HTTP && msg.acceptNDJSON && isNDJSONCommand(msg.Command()) {
+		// Search results will be streamed directly to the connection.
+		msg.ndjson = &ndjsonWriter{wr: client.netConn}
+	}
+
 	var write bool
 
+	if cmd == "auth" && len(msg.Args) == 3 {
+		// AUTH namespace password, AUTH role password, or AUTH user password
+		// of LDAP
+		if !server.namespaceAuth(client, msg.Args[1], msg.Args[2]) &&
+			!server.roleAuth(client, msg.Args[1], msg.Args[2]) {
+			if err := server.authClient(client, msg.Args[1], msg.Args[2]); err != nil {
+				return writeErr(err.Error())
+			}
+		}
+		resStr, _ := serializeOutput(OKMessage(msg, start))
+		return writeOutput(resStr)
+	}
+
 	if (!client.authd || cmd == "auth") && cmd != "output" {
-		if server.config.requirePass() != "" {
-			password := ""
+		if server.authRequired() {
+			var user, password string
 			// This better be an AUTH command or the Message should contain an Auth
 			if cmd != "auth" && msg.Auth == "" {
 				// Just shut down the pipeline now. The less the client connection knows the better.
 				return writeErr("authentication required")
 			}
 			if msg.Auth != "" {
-				password = msg.Auth
+				user, password = parseAuthorization(msg.Auth)
 			} else {
 				if len(msg.Args) > 1 {
 					password = msg.Args[1]
 				}
 			}
-			if server.config.requirePass() != strings.TrimSpace(password) {
-				return writeErr("invalid password")
+			if err := server.authClient(client, user, strings.TrimSpace(password)); err != nil {
+				return writeErr(err.Error())
 			}
-			client.authd = true
 			if msg.ConnType != HTTP {
 				resStr, _ := serializeOutput(OKMessage(msg, start))
 				return writeOutput(resStr)
@@ -855,17 +1055,74 @@ func (server *Server) handleInputCommand(client *Client, msg *Message) error {
 		}
 	}
 
+	if client.namespace != "" {
+		if err := namespaceArgs(client.namespace, msg); err != nil {
+			return writeErr(err.Error())
+		}
+	}
+	if client.role != "" {
+		if err := server.roleArgs(client.role, msg); err != nil {
+			return writeErr(err.Error())
+		}
+	}
+	if err := server.waitTenant(client, msg); err != nil {
+		return writeErr(err.Error())
+	}
+	msg.cursors = &client.cursors
+	defer server.startQuery(client, msg)()
+
+	if msg.Command() == "get" {
+		// load the object that the key doesn't have, see readthrough.go
+		if err := server.readThrough(msg); err != nil {
+			return writeErr(err.Error())
+		}
+	}
+	if server.coalesceWrite(msg) {
+		// the SET is held, see coalesce.go
+		resStr, _ := serializeOutput(OKMessage(msg, start))
+		return writeOutput(resStr)
+	}
+
 	// choose the locking strategy
 	switch msg.Command() {
 	default:
-		server.mu.RLock()
-		defer server.mu.RUnlock()
-	case "set", "del", "drop", "fset", "flushdb",
+		defer server.lockAllRead()()
+	case "set", "del", "fset", "expire", "persist", "jset", "jdel", "pdel",
+		"mset", "mfset", "touch", "pexpire", "expireat", "pexpireat",
+		"jincrby", "jdecrby", "jappend", "jinsert", "fincrby", "fdecrby", "fmin",
+		"fmax", "tag", "untag", "setmeta", "undelete", "sfset":
+		// write operations on a single key
+		write = true
+		if err := server.waitWrites(); err != nil {
+			return writeErr(err.Error())
+		}
+		defer server.lockKeyWrite(msg)()
+		if server.config.followHost() != "" {
+			return writeErr("not the leader")
+		}
+		if server.config.readOnly() {
+			return writeErr("read only")
+		}
+	case "keyconfig":
+		if len(msg.Args) > 1 && lc(msg.Args[1], "get") {
+			// KEYCONFIG GET reads
+			defer server.lockAllRead()()
+			break
+		}
+		fallthrough
+	case "drop", "flushdb",
 		"setchan", "pdelchan", "delchan",
-		"sethook", "pdelhook", "delhook",
-		"expire", "persist", "jset", "pdel", "rename", "renamenx":
+		"sethook", "pdelhook", "delhook", "hookimport",
+		"rename", "renamenx",
+		"trackconfig", "track", "trackdel", "areaconfig", "areadel",
+		"ttlconfig", "orderconfig", "historyconfig", "fleetconfig",
+		"writebehind", "readthrough", "schemaset", "schemadel",
+		"nsset", "nsdel", "roleset", "roledel":
 		// write operations
 		write = true
+		if err := server.waitWrites(); err != nil {
+			return writeErr(err.Error())
+		}
 		server.mu.Lock()
 		defer server.mu.Unlock()
 		if server.config.followHost() != "" {
@@ -874,8 +1131,18 @@ func (server *Server) handleInputCommand(client *Client, msg *Message) error {
 		if server.config.readOnly() {
 			return writeErr("read only")
 		}
+	case "notify":
+		// queues hook messages, but nothing for the aof
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		if server.config.followHost() != "" {
+			return writeErr("not the leader")
+		}
 	case "eval", "evalsha":
 		// write operations (potentially) but no AOF for the script command itself
+		if err := server.waitWrites(); err != nil {
+			return writeErr(err.Error())
+		}
 		server.mu.Lock()
 		defer server.mu.Unlock()
 		if server.config.followHost() != "" {
@@ -884,47 +1151,95 @@ func (server *Server) handleInputCommand(client *Client, msg *Message) error {
 		if server.config.readOnly() {
 			return writeErr("read only")
 		}
-	case "get", "keys", "scan", "nearby", "within", "intersects", "hooks",
-		"chans", "search", "ttl", "bounds", "server", "info", "type", "jget",
-		"evalro", "evalrosha", "healthz":
+	case "get", "scan", "nearby", "within", "intersects", "search", "ttl", "pttl",
+		"bounds", "type", "jget", "density", "fleetstats", "writebehindstats",
+		"readthroughstats",
+		"tags", "getmeta", "tombstones", "mget", "sfget":
+		// read operations on a single key
+		defer server.lockRead(msg)()
+		if server.config.followHost() != "" && !server.fcuponce {
+			return writeErr("catching up to leader")
+		}
+		switch msg.Command() {
+		case "scan", "nearby", "within", "intersects", "search":
+			// searches stop early when the client goes away
+			defer server.watchClient(client, msg)()
+		}
+	case "keys", "hooks", "chans", "chanacks", "server", "info", "evalro",
+		"evalrosha", "healthz", "trajectory", "passed", "route", "nsinfo",
+		"select", "roles", "hookexport", "occupancy":
 		// read operations
 
-		server.mu.RLock()
-		defer server.mu.RUnlock()
+		defer server.lockAllRead()()
 		if server.config.followHost() != "" && !server.fcuponce {
 			return writeErr("catching up to leader")
 		}
-	case "follow", "slaveof", "replconf", "readonly", "config":
+	case "follow", "slaveof", "replconf", "readonly", "config", "chanack":
 		// system operations
 		// does not write to aof, but requires a write lock.
 		server.mu.Lock()
 		defer server.mu.Unlock()
 	case "output":
 		// this is local connection operation. Locks not needed.
+	case "writes", "cursors", "hotkeys", "queries", "prepare", "deallocate",
+		"warmup", "verify":
+		// these have their own locks, see pause.go, cursors.go, hotkeys.go,
+		// queries.go, prepared.go, warmup.go and verify.go
+	case "delwhere", "copy", "move":
+		// locks for each batch, see bulk.go
+	case "mread":
+		// locks while copying the keys, see mread.go
+		if server.config.followHost() != "" && !server.fcuponce {
+			return writeErr("catching up to leader")
+		}
 	case "echo":
+	case "geohash":
+		// pure computation. Locks not needed.
+	case "layers", "lookup":
+		// read-only reference layers. Locks not needed.
 	case "massinsert":
 		// dev operation
 	case "sleep":
 		// dev operation
-		server.mu.RLock()
-		defer server.mu.RUnlock()
+		defer server.lockAllRead()()
 	case "shutdown":
 		// dev operation
 		server.mu.Lock()
 		defer server.mu.Unlock()
 	case "aofshrink":
-		server.mu.RLock()
-		defer server.mu.RUnlock()
+		// the aof doesn't change while the writes are paused
+		if err := server.waitWrites(); err != nil {
+			return writeErr(err.Error())
+		}
+		defer server.lockAllRead()()
 	case "client":
 		server.mu.Lock()
 		defer server.mu.Unlock()
 	case "evalna", "evalnasha":
 		// No locking for scripts, otherwise writes cannot happen within scripts
+	case "bench":
+		// No locking, each command of the benchmark takes its own locks,
+		// and it stops early when the client goes away
+		defer server.watchClient(client, msg)()
 	case "subscribe", "psubscribe", "publish":
 		// No locking for pubsub
 	case "monitor":
 		// No locking for monitor
 	}
+	if write && msg.namespace != "" {
+		if err := server.checkNamespaceQuotas(msg); err != nil {
+			return writeErr(err.Error())
+		}
+	}
+	if msg.idempotency != "" {
+		if !write {
+			return writeErr(errIdempotentNotWrite.Error())
+		}
+		if reply, ok := server.idempotency.get(idempotencyToken(msg)); ok {
+			resStr, _ := serializeOutput(idempotentReplyFor(msg, reply))
+			return writeOutput(resStr)
+		}
+	}
 	res, d, err := func() (res resp.Value, d commandDetails, err error) {
 		if msg.Deadline != nil {
 			if write {
@@ -932,6 +1247,7 @@ func (server *Server) handleInputCommand(client *Client, msg *Message) error {
 				err = errTimeoutOnCmd(msg.Command())
 				return
 			}
+			defer msg.Deadline.Close()
 			defer func() {
 				if msg.Deadline.Hit() {
 					v := recover()
@@ -941,12 +1257,34 @@ func (server *Server) handleInputCommand(client *Client, msg *Message) error {
 						}
 					}
 					res = NOMessage
+					if msg.Deadline.Canceled() {
+						log.Debugf("Canceled %s: %s", msg.Command(),
+							client.remoteAddr)
+						err = errors.New("canceled")
+						return
+					}
+					if msg.ndjson != nil && msg.ndjson.started {
+						err = errors.New("timeout")
+						return
+					}
 					err = writeErr("timeout")
 				}
 			}()
 		}
 		return server.command(msg, client)
 	}()
+	ops := server.countKeyOp(msg)
+	server.hotKeys.record(msg)
+	if msg.ndjson != nil && msg.ndjson.active {
+		if err != nil {
+			if !msg.ndjson.started {
+				return writeErr(err.Error())
+			}
+			return msg.ndjson.writeRecord([]byte(`{"ok":false,"err":` +
+				jsonString(err.Error()) + `}`))
+		}
+		return msg.ndjson.writeHeader()
+	}
 	if res.Type() == resp.Error {
 		return writeErr(res.String())
 	}
@@ -957,7 +1295,13 @@ func (server *Server) handleInputCommand(client *Client, msg *Message) error {
 		return writeErr(err.Error())
 	}
 	if write {
-		if err := server.writeAOF(msg.Args, &d); err != nil {
+		args := msg.Args
+		if msg.idempotency != "" {
+			token := idempotencyToken(msg)
+			args = append([]string{"idempotent", token}, args...)
+			server.idempotency.add(token, idempotentReply{res, msg.OutputType})
+		}
+		if err := server.writeAOF(args, &d); err != nil {
 			if _, ok := err.(errAOFHook); ok {
 				return writeErr(err.Error())
 			}
@@ -971,6 +1315,9 @@ func (server *Server) handleInputCommand(client *Client, msg *Message) error {
 		if err != nil {
 			return err
 		}
+		if ops != nil {
+			ops.bytesOut.add(len(resStr))
+		}
 		if err := writeOutput(resStr); err != nil {
 			return err
 		}
@@ -1007,12 +1354,24 @@ func (server *Server) command(msg *Message, client *Client) (
 		err = fmt.Errorf("unknown command '%s'", msg.Args[0])
 	case "set":
 		res, d, err = server.cmdSet(msg)
+	case "idempotent":
+		res, d, err = server.cmdIdempotent(msg, client)
 	case "fset":
 		res, d, err = server.cmdFset(msg)
+	case "mset":
+		res, d, err = server.cmdMset(msg)
+	case "mfset":
+		res, d, err = server.cmdMfset(msg)
 	case "del":
 		res, d, err = server.cmdDel(msg)
 	case "pdel":
 		res, d, err = server.cmdPdel(msg)
+	case "delwhere":
+		res, err = server.cmdDelWhere(msg)
+	case "copy":
+		res, err = server.cmdCopy(msg, false)
+	case "move":
+		res, err = server.cmdCopy(msg, true)
 	case "drop":
 		res, d, err = server.cmdDrop(msg)
 	case "flushdb":
@@ -1025,29 +1384,120 @@ func (server *Server) command(msg *Message, client *Client) (
 		res, d, err = server.cmdSetHook(msg, false)
 	case "delhook":
 		res, d, err = server.cmdDelHook(msg, false)
+	case "hookexport":
+		res, err = server.cmdHookExport(msg)
+	case "hookimport":
+		res, d, err = server.cmdHookImport(msg)
 	case "pdelhook":
 		res, d, err = server.cmdPDelHook(msg, false)
 	case "hooks":
 		res, err = server.cmdHooks(msg, false)
+	case "occupancy":
+		res, err = server.cmdOccupancy(msg)
 	case "setchan":
 		res, d, err = server.cmdSetHook(msg, true)
 	case "delchan":
 		res, d, err = server.cmdDelHook(msg, true)
 	case "pdelchan":
 		res, d, err = server.cmdPDelHook(msg, true)
+	case "chanack":
+		res, err = server.cmdChanAck(msg)
+	case "chanacks":
+		res, err = server.cmdChanAcks(msg)
 	case "chans":
 		res, err = server.cmdHooks(msg, true)
-	case "expire":
+	case "expire", "pexpire", "expireat", "pexpireat":
 		res, d, err = server.cmdExpire(msg)
 	case "persist":
 		res, d, err = server.cmdPersist(msg)
-	case "ttl":
+	case "trackconfig":
+		res, d, err = server.cmdTrackConfig(msg)
+	case "track":
+		res, d, err = server.cmdTrack(msg)
+	case "trackdel":
+		res, d, err = server.cmdTrackDel(msg)
+	case "ttlconfig":
+		res, d, err = server.cmdTTLConfig(msg)
+	case "keyconfig":
+		res, d, err = server.cmdKeyConfig(msg)
+	case "historyconfig":
+		res, d, err = server.cmdHistoryConfig(msg)
+	case "fleetconfig":
+		res, d, err = server.cmdFleetConfig(msg)
+	case "writebehind":
+		res, d, err = server.cmdWriteBehind(msg)
+	case "readthrough":
+		res, d, err = server.cmdReadThrough(msg)
+	case "undelete":
+		res, d, err = server.cmdUndelete(msg)
+	case "orderconfig":
+		res, d, err = server.cmdOrderConfig(msg)
+	case "touch":
+		res, d, err = server.cmdTouch(msg)
+	case "tag":
+		res, d, err = server.cmdTag(msg, false)
+	case "untag":
+		res, d, err = server.cmdTag(msg, true)
+	case "tags":
+		res, err = server.cmdTags(msg)
+	case "setmeta":
+		res, d, err = server.cmdSetMeta(msg)
+	case "getmeta":
+		res, err = server.cmdGetMeta(msg)
+	case "sfset":
+		res, d, err = server.cmdSFset(msg)
+	case "sfget":
+		res, err = server.cmdSFget(msg)
+	case "areaconfig":
+		res, d, err = server.cmdAreaConfig(msg)
+	case "areadel":
+		res, d, err = server.cmdAreaDel(msg)
+	case "schemaset":
+		res, d, err = server.cmdSchemaSet(msg)
+	case "schemadel":
+		res, d, err = server.cmdSchemaDel(msg)
+	case "schemaget":
+		res, err = server.cmdSchemaGet(msg)
+	case "nsset":
+		res, d, err = server.cmdNSSet(msg)
+	case "nsdel":
+		res, d, err = server.cmdNSDel(msg)
+	case "nsinfo":
+		res, err = server.cmdNSInfo(msg)
+	case "select":
+		res, err = server.cmdSelect(msg, client)
+	case "roleset":
+		res, d, err = server.cmdRoleSet(msg)
+	case "roledel":
+		res, d, err = server.cmdRoleDel(msg)
+	case "roles":
+		res, err = server.cmdRoles(msg)
+	case "hotkeys":
+		res, err = server.cmdHotKeys(msg)
+	case "warmup":
+		res, err = server.cmdWarmup(msg)
+	case "verify":
+		res, err = server.cmdVerify(msg)
+	case "trajectory":
+		res, err = server.cmdTrajectory(msg)
+	case "passed":
+		res, err = server.cmdPassed(msg)
+	case "density":
+		res, err = server.cmdDensity(msg)
+	case "layers":
+		res, err = server.cmdLayers(msg)
+	case "lookup":
+		res, err = server.cmdLookup(msg)
+	case "ttl", "pttl":
 		res, err = server.cmdTTL(msg)
 	case "shutdown":
 		if !core.DevMode {
 			err = fmt.Errorf("unknown command '%s'", msg.Args[0])
 			return
 		}
+		if err := server.saveHookSeqs(); err != nil {
+			log.Errorf("hook sequences: %v", err)
+		}
 		log.Fatal("shutdown requested by developer")
 	case "massinsert":
 		if !core.DevMode {
@@ -1061,18 +1511,42 @@ func (server *Server) command(msg *Message, client *Client) (
 			return
 		}
 		res, err = server.cmdSleep(msg)
+	case "debug":
+		res, err = server.cmdDebug(msg, client)
 	case "follow", "slaveof":
 		res, err = server.cmdFollow(msg)
 	case "replconf":
 		res, err = server.cmdReplConf(msg, client)
 	case "readonly":
 		res, err = server.cmdReadOnly(msg)
+	case "writes pause":
+		res, err = server.cmdWritesPause(msg)
+	case "writes resume":
+		res, err = server.cmdWritesResume(msg)
+	case "writes status":
+		res, err = server.cmdWritesStatus(msg)
+	case "cursors list":
+		res, err = server.cmdCursorsList(msg)
+	case "mread":
+		res, err = server.cmdMread(msg, client)
+	case "cursors kill":
+		res, err = server.cmdCursorsKill(msg)
+	case "prepare":
+		res, err = server.cmdPrepare(msg)
+	case "deallocate":
+		res, err = server.cmdDeallocate(msg)
+	case "queries list":
+		res, err = server.cmdQueriesList(msg)
+	case "queries kill":
+		res, err = server.cmdQueriesKill(msg)
 	case "stats":
 		res, err = server.cmdStats(msg)
 	case "server":
 		res, err = server.cmdServer(msg)
 	case "healthz":
 		res, err = server.cmdHealthz(msg)
+	case "bench":
+		res, err = server.cmdBench(msg)
 	case "info":
 		res, err = server.cmdInfo(msg)
 	case "scan":
@@ -1087,14 +1561,34 @@ func (server *Server) command(msg *Message, client *Client) (
 		res, err = server.cmdSearch(msg)
 	case "bounds":
 		res, err = server.cmdBounds(msg)
+	case "fleetstats":
+		res, err = server.cmdFleetStats(msg)
+	case "writebehindstats":
+		res, err = server.cmdWriteBehindStats(msg)
+	case "readthroughstats":
+		res, err = server.cmdReadThroughStats(msg)
+	case "tombstones":
+		res, err = server.cmdTombstones(msg)
 	case "get":
 		res, err = server.cmdGet(msg)
+	case "mget":
+		res, err = server.cmdMget(msg)
 	case "jget":
 		res, err = server.cmdJget(msg)
 	case "jset":
 		res, d, err = server.cmdJset(msg)
 	case "jdel":
 		res, d, err = server.cmdJdel(msg)
+	case "jincrby":
+		res, d, err = server.cmdJincrby(msg, false)
+	case "jdecrby":
+		res, d, err = server.cmdJincrby(msg, true)
+	case "jappend":
+		res, d, err = server.cmdJappend(msg)
+	case "fincrby", "fdecrby", "fmin", "fmax":
+		res, d, err = server.cmdFincrby(msg)
+	case "jinsert":
+		res, d, err = server.cmdJinsert(msg)
 	case "type":
 		res, err = server.cmdType(msg)
 	case "keys":
@@ -1105,6 +1599,8 @@ func (server *Server) command(msg *Message, client *Client) (
 		res, err = server.cmdAOF(msg)
 	case "aofmd5":
 		res, err = server.cmdAOFMD5(msg)
+	case "cdc":
+		res, err = server.cmdCDC(msg)
 	case "gc":
 		runtime.GC()
 		debug.FreeOSMemory()
@@ -1118,8 +1614,25 @@ func (server *Server) command(msg *Message, client *Client) (
 		res, err = server.cmdConfigSet(msg)
 	case "config rewrite":
 		res, err = server.cmdConfigRewrite(msg)
-	case "config", "script":
-		// These get rewritten into "config foo" and "script bar"
+	case "geohash encode":
+		res, err = server.cmdGeohashEncode(msg)
+	case "geohash decode":
+		res, err = server.cmdGeohashDecode(msg)
+	case "geohash neighbors":
+		res, err = server.cmdGeohashNeighbors(msg)
+	case "route distance":
+		res, err = server.cmdRouteDistance(msg)
+	case "route interpolate":
+		res, err = server.cmdRouteInterpolate(msg)
+	case "route along":
+		res, err = server.cmdRouteAlong(msg)
+	case "route matrix":
+		res, err = server.cmdRouteMatrix(msg)
+	case "config", "script", "geohash", "route", "writes", "cursors",
+		"queries":
+		// These get rewritten into "config foo", "script bar",
+		// "geohash baz", "route qux", "writes quux", "cursors corge",
+		// and "queries grault"
 		err = fmt.Errorf("unknown command '%s'", msg.Args[0])
 		if len(msg.Args) > 1 {
 			msg.Args[1] = msg.Args[0] + " " + msg.Args[1]
@@ -1145,6 +1658,8 @@ func (server *Server) command(msg *Message, client *Client) (
 		res, err = server.cmdPsubscribe(msg)
 	case "publish":
 		res, err = server.cmdPublish(msg)
+	case "notify":
+		res, err = server.cmdNotify(msg)
 	case "test":
 		res, err = server.cmdTest(msg)
 	case "monitor":
@@ -1235,6 +1750,19 @@ type Message struct {
 	OutputType Type
 	Auth       string
 	Deadline   *deadline.Deadline
+
+	acceptNDJSON bool           // HTTP client accepts application/x-ndjson
+	ndjson       *ndjsonWriter  // streaming writer for search results
+	compress     compression    // compression for large replies
+	webUI        bool           // the page of the web console
+	namespace    string         // namespace of the client, see namespace.go
+	cursors      *clientCursors // open cursors of the client, see cursors.go
+	role         string         // redacts the reply, see redact.go
+	idempotency  string         // token of IDEMPOTENT, see idempotency.go
+	query        *runningQuery  // while it runs, see queries.go
+
+	snap      *collection.Collection            // snapshot of the key, see snapshot.go
+	snapshots map[string]*collection.Collection // copies of the keys, see mread.go
 }
 
 // Command returns the first argument as a lowercase string
@@ -1313,10 +1841,16 @@ func readNextHTTPCommand(packet []byte, argsIn [][]byte, msg *Message, wr io.Wri
 		websocket := false
 		websocketVersion := 0
 		websocketKey := ""
+		websocketProtocol := ""
 		for _, header := range headers[1:] {
 			if header[0] == 'a' || header[0] == 'A' {
 				if strings.HasPrefix(strings.ToLower(header), "authorization:") {
 					msg.Auth = strings.TrimSpace(header[len("authorization:"):])
+				} else if strings.HasPrefix(strings.ToLower(header), "accept:") {
+					msg.acceptNDJSON = strings.Contains(
+						strings.ToLower(header[len("accept:"):]), ndjsonContentType)
+				} else if strings.HasPrefix(strings.ToLower(header), "accept-encoding:") {
+					msg.compress = parseAcceptEncoding(header[len("accept-encoding:"):])
 				}
 			} else if header[0] == 'u' || header[0] == 'U' {
 				if strings.HasPrefix(strings.ToLower(header), "upgrade:") && strings.ToLower(strings.TrimSpace(header[len("upgrade:"):])) == "websocket" {
@@ -1332,6 +1866,11 @@ func readNextHTTPCommand(packet []byte, argsIn [][]byte, msg *Message, wr io.Wri
 					websocketVersion = int(n)
 				} else if strings.HasPrefix(strings.ToLower(header), "sec-websocket-key:") {
 					websocketKey = strings.TrimSpace(header[len("sec-websocket-key:"):])
+				} else if strings.HasPrefix(strings.ToLower(header), "sec-websocket-protocol:") {
+					// browsers can't set the authorization header of a
+					// websocket, so the password can be a protocol.
+					websocketProtocol, msg.Auth = websocketAuth(
+						header[len("sec-websocket-protocol:"):], msg.Auth)
 				}
 			} else if header[0] == 'c' || header[0] == 'C' {
 				if strings.HasPrefix(strings.ToLower(header), "content-length:") {
@@ -1344,6 +1883,11 @@ func readNextHTTPCommand(packet []byte, argsIn [][]byte, msg *Message, wr io.Wri
 				}
 			}
 		}
+		if method == "GET" && !websocket && (path == "ui" || path == "ui/") {
+			msg.webUI = true
+			msg.Args = []string{"ui"}
+			return true, nil
+		}
 		if websocket && websocketVersion >= 13 && websocketKey != "" {
 			msg.ConnType = WebSocket
 			if wr == nil {
@@ -1351,7 +1895,11 @@ func readNextHTTPCommand(packet []byte, argsIn [][]byte, msg *Message, wr io.Wri
 			}
 			sum := sha1.Sum([]byte(websocketKey + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
 			accept := base64.StdEncoding.EncodeToString(sum[:])
-			wshead := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + accept + "\r\n\r\n"
+			wshead := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + accept + "\r\n"
+			if websocketProtocol != "" {
+				wshead += "Sec-WebSocket-Protocol: " + websocketProtocol + "\r\n"
+			}
+			wshead += "\r\n"
 			if _, err = wr.Write([]byte(wshead)); err != nil {
 				return false, err
 			}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/tile38/internal/collection"
+)
+
+// Snapshot reads
+//
+// When the snapshotepoch config property is set, WITHIN, NEARBY, and
+// INTERSECTS search a read-only snapshot of the collection rather than the
+// collection itself. These searches hold the server lock for reading but
+// never the key lock, which means that they do not block writers.
+//
+// The snapshots are refreshed by a background routine once every epoch, so
+// search results may be up to one epoch stale. A key is searched with its
+// key lock until its first snapshot is made, by the next refresh after it's
+// read. The key lock is only held while the objects are copied, and the
+// trees of the snapshot are built once it's released. A retired snapshot stays
+// alive for as long as a search is using it and is then reclaimed by the
+// garbage collector.
+//
+// The copy is still O(n) in the objects of the key, and writers of the key
+// wait for it. To bound that, a key isn't copied again until
+// snapshotCopyRatio times the duration of its last copy has passed, which
+// keeps the key lock held by copies for at most about 1/snapshotCopyRatio of
+// the time. Small keys are copied every epoch, while the snapshots of large
+// and busy keys may be several epochs stale.
+
+// snapshotIdleEpochs is the number of epochs without a snapshot read before
+// the snapshot of a key is dropped.
+const snapshotIdleEpochs = 100
+
+// snapshotCopyRatio is the ratio of the time between the copies of a key to
+// the time that its last copy held the key lock.
+const snapshotCopyRatio = 20
+
+// isSnapshotCommand returns true for the commands that read from snapshots.
+func isSnapshotCommand(cmd string) bool {
+	switch cmd {
+	case "within", "nearby", "intersects":
+		return true
+	}
+	return false
+}
+
+func (c *collectionKeyContainer) loadSnapshot() *collection.Collection {
+	snap, _ := c.snap.Load().(*collection.Collection)
+	return snap
+}
+
+// lockSnapshotRead acquires the locks for a search of the snapshot of the
+// key, which is only the server lock for reading. A key that doesn't have a
+// snapshot yet is searched with its key lock, like without snapshots, and its
+// snapshot is made by the next refresh.
+func (server *Server) lockSnapshotRead(msg *Message) (unlock func()) {
+	server.mu.RLock()
+	c := server.getColContainer(msg.Args[1])
+	if c == nil {
+		return server.mu.RUnlock
+	}
+	c.touch()
+	atomic.StoreInt64(&c.snapRead, time.Now().UnixNano())
+	if msg.snap = c.loadSnapshot(); msg.snap != nil {
+		return server.mu.RUnlock
+	}
+	c.mu.RLock()
+	return func() {
+		c.mu.RUnlock()
+		server.mu.RUnlock()
+	}
+}
+
+// storeSnapshot replaces the snapshot of the key, unless it already has a
+// newer one.
+func (c *collectionKeyContainer) storeSnapshot(snap *collection.Collection) {
+	c.snapMu.Lock()
+	defer c.snapMu.Unlock()
+	if cur := c.loadSnapshot(); cur == nil || cur.Version() < snap.Version() {
+		c.snap.Store(snap)
+	}
+}
+
+// backgroundSnapshots refreshes the collection snapshots once every epoch.
+func (server *Server) backgroundSnapshots() {
+	var enabled bool
+	for {
+		if server.stopServer.on() {
+			return
+		}
+		epoch := server.config.snapshotEpoch()
+		if epoch == 0 {
+			if enabled {
+				// snapshots were turned off, release them
+				server.refreshSnapshots(0)
+				enabled = false
+			}
+			time.Sleep(time.Second / 10)
+			continue
+		}
+		enabled = true
+		time.Sleep(epoch)
+		server.refreshSnapshots(epoch)
+	}
+}
+
+// refreshSnapshots makes a new snapshot for every key that has been read
+// and has changed since its last snapshot, unless the key was copied too
+// recently for its size, see snapshotCopyRatio. Snapshots that have not been
+// read in a while are dropped. All snapshots are dropped when the epoch is
+// zero.
+func (server *Server) refreshSnapshots(epoch time.Duration) {
+	var conts []*collectionKeyContainer
+	server.mu.RLock()
+	server.cols.Ascend(nil, func(v interface{}) bool {
+		c := v.(*collectionKeyContainer)
+		if atomic.LoadInt64(&c.snapRead) != 0 {
+			conts = append(conts, c)
+		}
+		return true
+	})
+	server.mu.RUnlock()
+	idle := time.Now().Add(-epoch * snapshotIdleEpochs).UnixNano()
+	for _, c := range conts {
+		if read := atomic.LoadInt64(&c.snapRead); epoch == 0 || read < idle {
+			atomic.CompareAndSwapInt64(&c.snapRead, read, 0)
+			c.snapMu.Lock()
+			c.snap.Store((*collection.Collection)(nil))
+			c.snapMu.Unlock()
+			continue
+		}
+		if time.Now().UnixNano() < atomic.LoadInt64(&c.snapNext) {
+			continue
+		}
+		// The key lock is only held while the objects are copied, one key
+		// at a time, and the snapshot is built once it's released.
+		var cp *collection.SnapshotCopy
+		server.mu.RLock()
+		c.mu.RLock()
+		start := time.Now()
+		if snap := c.loadSnapshot(); snap == nil || snap.Version() != c.col.Version() {
+			cp = c.col.SnapshotCopy()
+		}
+		c.mu.RUnlock()
+		server.mu.RUnlock()
+		if cp != nil {
+			held := time.Since(start)
+			atomic.StoreInt64(&c.snapNext,
+				time.Now().Add(held*snapshotCopyRatio).UnixNano())
+			c.storeSnapshot(cp.Build())
+		}
+	}
+}
@@ -59,13 +59,15 @@ func (s *Server) cmdStats(msg *Message) (res resp.Value, err error) {
 		if !ok {
 			break
 		}
-		col := s.getCol(key)
-		if col != nil {
+		c := s.getColContainer(key)
+		if c != nil {
+			col := c.col
 			m := make(map[string]interface{})
 			m["num_points"] = col.PointCount()
 			m["in_memory_size"] = col.TotalWeight()
 			m["num_objects"] = col.Count()
 			m["num_strings"] = col.StringCount()
+			c.ops.addStats(m)
 			switch msg.OutputType {
 			case JSON:
 				ms = append(ms, m)
@@ -153,6 +155,12 @@ func (s *Server) basicStats(m map[string]interface{}) {
 		m["caught_up"] = s.fcup
 		m["caught_up_once"] = s.fcuponce
 	}
+	if s.cluster != nil {
+		m["cluster_role"] = s.cluster.role
+		if s.cluster.leader != "" {
+			m["cluster_leader"] = s.cluster.leader
+		}
+	}
 	m["http_transport"] = s.http
 	m["pid"] = os.Getpid()
 	m["aof_size"] = s.aofsz
@@ -168,16 +176,19 @@ func (s *Server) basicStats(m map[string]interface{}) {
 	points := 0
 	objects := 0
 	strings := 0
+	shared := 0
 	s.cols.Ascend(nil, func(v interface{}) bool {
 		col := v.(*collectionKeyContainer).col
 		points += col.PointCount()
 		objects += col.Count()
 		strings += col.StringCount()
+		shared += col.SharedCount()
 		return true
 	})
 	m["num_points"] = points
 	m["num_objects"] = objects
 	m["num_strings"] = strings
+	m["num_shared_geometries"] = shared
 	mem := readMemStats()
 	avgsz := 0
 	if points != 0 {
@@ -187,10 +198,20 @@ func (s *Server) basicStats(m map[string]interface{}) {
 	m["heap_size"] = mem.HeapAlloc
 	m["heap_released"] = mem.HeapReleased
 	m["max_heap_size"] = s.config.maxMemory()
+	m["layers_heap_size"] = s.layersHeap()
 	m["avg_item_size"] = avgsz
 	m["version"] = core.Version
 	m["pointer_size"] = (32 << uintptr(uint64(^uintptr(0))>>63)) / 8
 	m["read_only"] = s.config.readOnly()
+	m["writes_paused"] = s.writesPause() != nil
+	m["tenant_waited"] = s.statsTenantWaited.get()
+	m["tenant_denied"] = s.statsTenantDenied.get()
+	m["coalesce_held"] = s.coalescer.held.get()
+	m["coalesce_skipped"] = s.coalescer.skipped.get()
+	m["verify_diverged"] = s.statsVerifyDiff.get()
+	m["verify_repaired"] = s.statsVerifyRepair.get()
+	m["output_limit_disconnects"] = s.statsOutputClosed.get()
+	m["output_limit_dropped"] = s.statsOutputDropped.get()
 	m["cpus"] = runtime.NumCPU()
 	n, _ := runtime.ThreadCreateProfile(nil)
 	m["threads"] = float64(n)
@@ -314,6 +335,7 @@ func (s *Server) extStats(m map[string]interface{}) {
 	m["tile38_total_messages_sent"] = s.statsTotalMsgsSent.get()
 	// Number of key expiration events
 	m["tile38_expired_keys"] = s.statsExpired.get()
+	m["tile38_evicted_keys"] = s.statsEvicted.get()
 	// Number of connected slaves
 	m["tile38_connected_slaves"] = len(s.aofconnM)
 
@@ -400,6 +422,7 @@ func (s *Server) writeInfoStats(w *bytes.Buffer) {
 	fmt.Fprintf(w, "total_commands_processed:%d\r\n", s.statsTotalCommands.get()) // Total number of commands processed by the server
 	fmt.Fprintf(w, "total_messages_sent:%d\r\n", s.statsTotalMsgsSent.get())      // Total number of commands processed by the server
 	fmt.Fprintf(w, "expired_keys:%d\r\n", s.statsExpired.get())                   // Total number of key expiration events
+	fmt.Fprintf(w, "evicted_keys:%d\r\n", s.statsEvicted.get())                   // Total number of objects evicted due to maxmemory
 }
 
 // writeInfoReplication writes all replication data to the 'info' response
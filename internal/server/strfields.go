@@ -0,0 +1,128 @@
+package server
+
+// String fields: fields with a string value, such as a plate number, that
+// are set with SET ... SFIELD name value, or with SFSET, and returned with
+// SFGET. They're kept by the collection apart from the float64 fields, and
+// a WHERE field = value filters the objects of a search on them. An empty
+// value removes the field.
+
+import (
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+)
+
+// setStringFields sets the SFIELD names and values of a SET, which are in
+// pairs, and returns the number of values that changed.
+func setStringFields(col *collection.Collection, id string,
+	sfields []string,
+) (n int) {
+	for i := 0; i < len(sfields); i += 2 {
+		if updated, _ := col.SetStringField(id, sfields[i],
+			sfields[i+1]); updated {
+			n++
+		}
+	}
+	return n
+}
+
+// cmdSFset sets the string fields of an object, and returns the number of
+// values that changed.
+//
+//	SFSET key id field value [field value ...]
+func (server *Server) cmdSFset(msg *Message) (res resp.Value, d commandDetails, err error) {
+	if server.rejectWrites() {
+		err = errOOM
+		return
+	}
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, d.id, ok = tokenval(vs); !ok || d.id == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if len(vs) == 0 || len(vs)%2 != 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	for i := 0; i < len(vs); i += 2 {
+		if vs[i] == "" || isReservedFieldName(vs[i]) {
+			err = errInvalidArgument(vs[i])
+			return
+		}
+	}
+	col := server.getCol(d.key)
+	if col == nil {
+		err = errKeyNotFound
+		return
+	}
+	if _, ok = col.StringField(d.id, vs[0]); !ok {
+		err = errIDNotFound
+		return
+	}
+	n := setStringFields(col, d.id, vs)
+	d.command = "sfset"
+	d.updated = n > 0
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"elapsed":"` +
+			time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
+
+// cmdSFget returns the value of a string field of an object. For RESP, it's
+// null when the object doesn't have the field.
+//
+//	SFGET key id field
+func (server *Server) cmdSFget(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key, id, field string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, id, ok = tokenval(vs); !ok || id == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, field, ok = tokenval(vs); !ok || field == "" || len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var value string
+	col := server.getCol(key)
+	if col != nil {
+		value, ok = col.StringField(id, field)
+	}
+	if col == nil || !ok {
+		if msg.OutputType == RESP {
+			return resp.NullValue(), nil
+		}
+		if col == nil {
+			return NOMessage, errKeyNotFound
+		}
+		return NOMessage, errIDNotFound
+	}
+	switch msg.OutputType {
+	case JSON:
+		buf := appendJSONString([]byte(`{"ok":true,"value":`), value)
+		buf = append(buf, `,"elapsed":"`+time.Since(start).String()+`"}`...)
+		return resp.StringValue(string(buf)), nil
+	case RESP:
+		if value == "" {
+			return resp.NullValue(), nil
+		}
+		return resp.StringValue(value), nil
+	}
+	return NOMessage, nil
+}
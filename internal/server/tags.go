@@ -0,0 +1,149 @@
+package server
+
+// Tags: the strings of an object that are set with SET ... TAG, or with
+// TAG and UNTAG, and that the TAGGED option of the searches filters by.
+// They're kept apart from the fields, with an inverted index of each tag in
+// the collection, see collection/tags.go.
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/resp"
+)
+
+// sortTags sorts the tags and removes the duplicates.
+func sortTags(tags []string) []string {
+	sort.Strings(tags)
+	n := 0
+	for i, tag := range tags {
+		if i == 0 || tag != tags[n-1] {
+			tags[n] = tag
+			n++
+		}
+	}
+	return tags[:n]
+}
+
+// equalTags returns true when the sorted tags are the same.
+func equalTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// cmdTag adds tags to an object, or removes them for UNTAG. Returns the
+// number of tags that were added or removed.
+//
+//	TAG key id tag [tag ...]
+//	UNTAG key id tag [tag ...]
+func (server *Server) cmdTag(msg *Message, remove bool) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, d.id, ok = tokenval(vs); !ok || d.id == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if len(vs) == 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	for _, tag := range vs {
+		if tag == "" {
+			err = errInvalidArgument(tag)
+			return
+		}
+	}
+	col := server.getCol(d.key)
+	if col == nil {
+		err = errKeyNotFound
+		return
+	}
+	var n int
+	if remove {
+		n, ok = col.RemoveTags(d.id, vs)
+		d.command = "untag"
+	} else {
+		n, ok = col.AddTags(d.id, vs)
+		d.command = "tag"
+	}
+	if !ok {
+		err = errIDNotFound
+		return
+	}
+	d.updated = n > 0
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"count":` + strconv.Itoa(n) +
+			`,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
+
+// cmdTags returns the sorted tags of an object.
+//
+//	TAGS key id
+func (server *Server) cmdTags(msg *Message) (res resp.Value, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key, id string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, id, ok = tokenval(vs); !ok || id == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	col := server.getCol(key)
+	if col == nil {
+		if msg.OutputType == RESP {
+			return resp.NullValue(), nil
+		}
+		return NOMessage, errKeyNotFound
+	}
+	if _, ok := col.Expires(id); !ok {
+		if msg.OutputType == RESP {
+			return resp.NullValue(), nil
+		}
+		return NOMessage, errIDNotFound
+	}
+	tags := col.Tags(id)
+	switch msg.OutputType {
+	case JSON:
+		buf := []byte(`{"ok":true,"tags":[`)
+		for i, tag := range tags {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, tag)
+		}
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+`"}`...)
+		return resp.StringValue(string(buf)), nil
+	case RESP:
+		vals := make([]resp.Value, len(tags))
+		for i, tag := range tags {
+			vals[i] = resp.StringValue(tag)
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
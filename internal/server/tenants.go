@@ -0,0 +1,203 @@
+package server
+
+// Tenants: the commands of a tenant take tokens from its bucket, which
+// refills at the tenant-rate and holds up to the tenant-burst, so that one
+// tenant's heavy searches don't starve the others. A search costs more
+// tokens than a read or a write, see tenant-weights. A command that finds
+// the bucket empty waits for its tokens, in the order of the commands of the
+// tenant, and is rejected when it would wait too long. The tenant of a
+// client is its bound namespace, or else the user of its JWT or LDAP
+// credentials, or else its role. The other clients aren't scheduled.
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tenantMaxWait is the longest that a command waits for its tokens.
+const tenantMaxWait = time.Second
+
+var errTenantRate = errors.New("tenant rate limit exceeded")
+
+// tenantWeights are the tokens of the classes of commands.
+type tenantWeights struct {
+	read, write, search float64
+}
+
+// parseTenantWeights parses "read=1,write=1,search=5". The classes that
+// aren't listed keep their default weights.
+func parseTenantWeights(s string) (tenantWeights, bool) {
+	w := tenantWeights{read: 1, write: 1, search: 5}
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return w, false
+		}
+		n, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil || n < 0 {
+			return w, false
+		}
+		switch strings.ToLower(kv[0]) {
+		case "read":
+			w.read = n
+		case "write":
+			w.write = n
+		case "search":
+			w.search = n
+		default:
+			return w, false
+		}
+	}
+	return w, true
+}
+
+func (w tenantWeights) String() string {
+	return "read=" + strconv.FormatFloat(w.read, 'f', -1, 64) +
+		",write=" + strconv.FormatFloat(w.write, 'f', -1, 64) +
+		",search=" + strconv.FormatFloat(w.search, 'f', -1, 64)
+}
+
+// cost returns the tokens of a command.
+func (w tenantWeights) cost(cmd string) float64 {
+	switch cmd {
+	case "output", "quit", "auth", "select":
+		return 0
+	case "mread", "passed", "trajectory", "route", "evalro", "evalrosha":
+		return w.search
+	case "drop", "flushdb", "rename", "renamenx", "sethook", "delhook",
+		"pdelhook", "hookimport", "notify", "eval", "evalsha", "evalna",
+		"evalnasha":
+		return w.write
+	}
+	switch keyOp(cmd) {
+	case "write":
+		return w.write
+	case "search":
+		return w.search
+	}
+	return w.read
+}
+
+// tenantBucket is the token bucket of a tenant. The tokens are below zero
+// while commands wait for them.
+type tenantBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// tenantBuckets are the buckets of the tenants.
+type tenantBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]*tenantBucket
+}
+
+// reserve takes the tokens of a command from the bucket of a tenant, and
+// returns how long the command waits for them. The tokens aren't taken when
+// the wait is over the max.
+func (tb *tenantBuckets) reserve(tenant string, cost, rate, burst float64,
+	now time.Time, max time.Duration,
+) (time.Duration, bool) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if tb.buckets == nil {
+		tb.buckets = make(map[string]*tenantBucket)
+	}
+	b, ok := tb.buckets[tenant]
+	if !ok {
+		b = &tenantBucket{tokens: burst, last: now}
+		tb.buckets[tenant] = b
+	}
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+	}
+	tokens := b.tokens - cost
+	var wait time.Duration
+	if tokens < 0 {
+		wait = time.Duration(-tokens / rate * float64(time.Second))
+		if wait > max {
+			return wait, false
+		}
+	}
+	b.tokens = tokens
+	return wait, true
+}
+
+// expire removes the buckets that are full again.
+func (tb *tenantBuckets) expire(rate, burst float64, now time.Time) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	for tenant, b := range tb.buckets {
+		if rate == 0 || b.tokens+now.Sub(b.last).Seconds()*rate >= burst {
+			delete(tb.buckets, tenant)
+		}
+	}
+}
+
+// tenant returns the tenant of a client, or an empty string.
+func (c *Client) tenant() string {
+	switch {
+	case c.nsBound:
+		return "namespace:" + c.namespace
+	case c.user != "":
+		return "user:" + c.user
+	case c.role != "":
+		return "role:" + c.role
+	}
+	return ""
+}
+
+// waitTenant waits until the tenant of a client has the tokens of a command,
+// or returns an error when the wait would be too long.
+func (s *Server) waitTenant(client *Client, msg *Message) error {
+	rate, burst, weights := s.config.tenantLimits()
+	if rate == 0 {
+		return nil
+	}
+	tenant := client.tenant()
+	if tenant == "" {
+		return nil
+	}
+	cost := weights.cost(msg.Command())
+	if cost == 0 {
+		return nil
+	}
+	if burst == 0 {
+		burst = rate
+	}
+	if cost > burst {
+		// the command would never have its tokens
+		cost = burst
+	}
+	wait, ok := s.tenants.reserve(tenant, cost, rate, burst, time.Now(), tenantMaxWait)
+	if !ok {
+		s.statsTenantDenied.add(1)
+		return errTenantRate
+	}
+	if wait > 0 {
+		s.statsTenantWaited.add(1)
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// backgroundTenants removes the buckets of the idle tenants.
+func (s *Server) backgroundTenants() {
+	for {
+		if s.stopServer.on() {
+			return
+		}
+		rate, burst, _ := s.config.tenantLimits()
+		if burst == 0 {
+			burst = rate
+		}
+		s.tenants.expire(rate, burst, time.Now())
+		time.Sleep(time.Second * 10)
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTenantWeights(t *testing.T) {
+	w, ok := parseTenantWeights("search=10, write=2")
+	if !ok || w.String() != "read=1,write=2,search=10" {
+		t.Fatalf("unexpected weights '%s' %v", w, ok)
+	}
+	for _, s := range []string{"search", "search=-1", "scan=1", "read=x"} {
+		if _, ok := parseTenantWeights(s); ok {
+			t.Fatalf("expected '%s' to be invalid", s)
+		}
+	}
+	test := func(cmd string, expect float64) {
+		t.Helper()
+		if cost := w.cost(cmd); cost != expect {
+			t.Fatalf("%s: expected %v, got %v", cmd, expect, cost)
+		}
+	}
+	test("get", 1)
+	test("keys", 1)
+	test("set", 2)
+	test("drop", 2)
+	test("intersects", 10)
+	test("mread", 10)
+	test("output", 0)
+}
+
+func TestTenantBuckets(t *testing.T) {
+	var tb tenantBuckets
+	now := time.Unix(1000, 0)
+	test := func(tenant string, cost float64, expect time.Duration, expectOK bool) {
+		t.Helper()
+		wait, ok := tb.reserve(tenant, cost, 2, 4, now, time.Second)
+		if ok != expectOK || (ok && wait != expect) {
+			t.Fatalf("expected %v %v, got %v %v", expect, expectOK, wait, ok)
+		}
+	}
+	// a new tenant has a full bucket
+	test("a", 4, 0, true)
+	// the next command waits for the refill at 2 per second
+	test("a", 1, time.Second/2, true)
+	// and another one would wait more than the max
+	test("a", 2, 0, false)
+	// the other tenants aren't affected
+	test("b", 4, 0, true)
+	now = now.Add(time.Second)
+	test("a", 2, time.Second/2, true)
+	// the buckets are removed when they're full again
+	tb.expire(2, 4, now.Add(time.Second))
+	if len(tb.buckets) != 1 {
+		t.Fatalf("expected 1 bucket, got %d", len(tb.buckets))
+	}
+	tb.expire(2, 4, now.Add(3*time.Second))
+	if len(tb.buckets) != 0 {
+		t.Fatalf("expected 0 buckets, got %d", len(tb.buckets))
+	}
+}
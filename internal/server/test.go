@@ -93,7 +93,7 @@ func (s *Server) parseArea(ovs []string, doClip bool) (vs []string, o geojson.Ob
 			err = errInvalidNumberOfArguments
 			return
 		}
-		o, err = geojson.Parse(obj, &s.geomParseOpts)
+		o, err = s.parseObjectArea(obj)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,105 @@
+package server
+
+// Threshold fences: a hook or channel with THRESHOLD sends a "rising" event
+// when a field of an object inside of its fence goes above a value, and a
+// "falling" event when the field goes below a second value, such as a speed
+// that goes over 100 and then under 90.
+//
+//	SETHOOK name endpoint THRESHOLD field above below WITHIN key FENCE ...
+//
+// The second value, which can't be more than the first, keeps a field that
+// goes up and down around the first value from sending an event for each
+// write. The area of the fence restricts the objects that are watched, and
+// an object that leaves it is forgotten, without an event. The objects that
+// are above the value when the hook is set don't send a "rising" event.
+
+import (
+	"strconv"
+
+	"github.com/tidwall/geojson"
+)
+
+// thresholdFence is the THRESHOLD of a hook, and the objects that are above
+// it. It's only changed while holding the server lock, or the write lock.
+type thresholdFence struct {
+	field        string
+	above, below float64
+	high         map[string]bool // ids of the objects above the threshold
+}
+
+func (th *thresholdFence) equals(other *thresholdFence) bool {
+	if th == nil || other == nil {
+		return th == other
+	}
+	return th.field == other.field && th.above == other.above &&
+		th.below == other.below
+}
+
+// args returns the THRESHOLD arguments of a hook.
+func (th *thresholdFence) args() []string {
+	return []string{"threshold", th.field,
+		strconv.FormatFloat(th.above, 'f', -1, 64),
+		strconv.FormatFloat(th.below, 'f', -1, 64)}
+}
+
+// thresholdValue returns the value of the field of an object.
+func thresholdValue(th *thresholdFence, fmap map[string]int, fields []float64,
+) float64 {
+	if idx, ok := fmap[th.field]; ok && idx < len(fields) {
+		return fields[idx]
+	}
+	return 0
+}
+
+// watchThreshold returns the "rising" or "falling" event of an object that
+// was written.
+func (s *Server) watchThreshold(hook *Hook, d *commandDetails) []string {
+	th := hook.threshold
+	if d.command == "drop" {
+		if d.key == hook.Key {
+			th.high = make(map[string]bool)
+		}
+		return nil
+	}
+	if d.key != hook.Key || d.id == "" {
+		return nil
+	}
+	if d.command == "del" || !inactiveMatch(hook, d.id, d.obj) {
+		delete(th.high, d.id)
+		return nil
+	}
+	value := thresholdValue(th, d.fmap, d.fields)
+	var detect string
+	switch {
+	case value > th.above && !th.high[d.id]:
+		th.high[d.id] = true
+		detect = "rising"
+	case value < th.below && th.high[d.id]:
+		delete(th.high, d.id)
+		detect = "falling"
+	default:
+		return nil
+	}
+	if hook.Fence.detect != nil && !hook.Fence.detect[detect] {
+		return nil
+	}
+	return []string{s.objectMessage(hook, d.command, detect, d)}
+}
+
+// seedThreshold finds the objects that are inside of the fence of a new
+// hook, and above its threshold.
+func (s *Server) seedThreshold(hook *Hook) {
+	col := s.getCol(hook.Key)
+	if col == nil {
+		return
+	}
+	th := hook.threshold
+	fmap := col.FieldMap()
+	col.Scan(false, nil, nil, func(id string, obj geojson.Object, fields []float64) bool {
+		if inactiveMatch(hook, id, obj) &&
+			thresholdValue(th, fmap, fields) > th.above {
+			th.high[id] = true
+		}
+		return true
+	})
+}
@@ -6,7 +6,10 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/tidwall/tile38/internal/crs"
+	"github.com/tidwall/tile38/internal/simplify"
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -16,6 +19,7 @@ var errInvalidNumberOfArguments = errors.New("invalid number of arguments")
 var errKeyNotFound = errors.New("key not found")
 var errIDNotFound = errors.New("id not found")
 var errIDAlreadyExists = errors.New("id already exists")
+var errConditionNotMet = errors.New("condition not met")
 var errPathNotFound = errors.New("path not found")
 var errKeyHasHooksSet = errors.New("key has hooks set")
 var errNotRectangle = errors.New("not a rectangle")
@@ -93,6 +97,13 @@ type whereT struct {
 	min   float64
 	maxx  bool
 	max   float64
+	eq    *string // WHERE field = value, for a string field
+}
+
+// matchString returns true when the string value of a field is equal to the
+// value of a WHERE field = value.
+func (where whereT) matchString(value string) bool {
+	return value == *where.eq
 }
 
 func (where whereT) match(value float64) bool {
@@ -205,12 +216,91 @@ type searchScanBaseTokens struct {
 	whereins   []whereinT
 	whereevals []whereevalT
 	nofields   bool
+	selected   []string
 	ulimit     bool
 	limit      uint64
 	usparse    bool
 	sparse     uint8
 	desc       bool
 	clip       bool
+	reshape    simplify.Options
+	crs        *crs.CRS // output reference system
+	zoom       int      // CLUSTER zoom level
+	hull       float64  // HULL CONCAVE meters
+	centroid   string   // CENTROID WEIGHT field
+
+	withexpires bool          // WITHEXPIRES, output the expirations
+	withttl     bool          // WITHTTL, output the remaining ttls
+	minttl      time.Duration // MINTTL, skip the objects that expire sooner
+
+	tagged []string // TAGGED, the tags that the objects must have
+
+	incomplete time.Duration // WITHINCOMPLETE, the time budget of the search
+}
+
+// minExpires returns the expiration before which the objects are skipped,
+// or zero when there's no MINTTL.
+func (t searchScanBaseTokens) minExpires() int64 {
+	if t.minttl == 0 {
+		return 0
+	}
+	return time.Now().Add(t.minttl).UnixNano()
+}
+
+// parseWhere parses the "field min max" arguments of a WHERE, or the
+// "field = value" arguments of a WHERE on a string field.
+func parseWhere(vs []string) (nvs []string, where whereT, err error) {
+	var ok bool
+	var field, smin, smax string
+	if vs, field, ok = tokenval(vs); !ok || field == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, smin, ok = tokenval(vs); !ok || smin == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if smin == "=" {
+		// the value of a string field, which may be empty
+		if vs, smax, ok = tokenval(vs); !ok {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		return vs, whereT{field: field, index: -1, eq: &smax}, nil
+	}
+	if vs, smax, ok = tokenval(vs); !ok || smax == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var minx, maxx bool
+	var min, max float64
+	if strings.ToLower(smin) == "-inf" {
+		min = math.Inf(-1)
+	} else {
+		if strings.HasPrefix(smin, "(") {
+			minx = true
+			smin = smin[1:]
+		}
+		min, err = strconv.ParseFloat(smin, 64)
+		if err != nil {
+			err = errInvalidArgument(smin)
+			return
+		}
+	}
+	if strings.ToLower(smax) == "+inf" {
+		max = math.Inf(+1)
+	} else {
+		if strings.HasPrefix(smax, "(") {
+			maxx = true
+			smax = smax[1:]
+		}
+		max, err = strconv.ParseFloat(smax, 64)
+		if err != nil {
+			err = errInvalidArgument(smax)
+			return
+		}
+	}
+	return vs, whereT{field, -1, minx, min, maxx, max, nil}, nil
 }
 
 func (s *Server) parseSearchScanBaseTokens(
@@ -229,6 +319,9 @@ func (s *Server) parseSearchScanBaseTokens(
 	var slimit string
 	var ssparse string
 	var scursor string
+	var sdigits string
+	var stolerance string
+	var scrs string
 	var asc bool
 	for {
 		nvs, wtok, ok := tokenval(vs)
@@ -246,49 +339,11 @@ func (s *Server) parseSearchScanBaseTokens(
 				}
 				continue
 			case "where":
-				vs = nvs
-				var field, smin, smax string
-				if vs, field, ok = tokenval(vs); !ok || field == "" {
-					err = errInvalidNumberOfArguments
-					return
-				}
-				if vs, smin, ok = tokenval(vs); !ok || smin == "" {
-					err = errInvalidNumberOfArguments
+				var where whereT
+				if vs, where, err = parseWhere(nvs); err != nil {
 					return
 				}
-				if vs, smax, ok = tokenval(vs); !ok || smax == "" {
-					err = errInvalidNumberOfArguments
-					return
-				}
-				var minx, maxx bool
-				var min, max float64
-				if strings.ToLower(smin) == "-inf" {
-					min = math.Inf(-1)
-				} else {
-					if strings.HasPrefix(smin, "(") {
-						minx = true
-						smin = smin[1:]
-					}
-					min, err = strconv.ParseFloat(smin, 64)
-					if err != nil {
-						err = errInvalidArgument(smin)
-						return
-					}
-				}
-				if strings.ToLower(smax) == "+inf" {
-					max = math.Inf(+1)
-				} else {
-					if strings.HasPrefix(smax, "(") {
-						maxx = true
-						smax = smax[1:]
-					}
-					max, err = strconv.ParseFloat(smax, 64)
-					if err != nil {
-						err = errInvalidArgument(smax)
-						return
-					}
-				}
-				t.wheres = append(t.wheres, whereT{field, -1, minx, min, maxx, max})
+				t.wheres = append(t.wheres, where)
 				continue
 			case "wherein":
 				vs = nvs
@@ -401,6 +456,87 @@ func (s *Server) parseSearchScanBaseTokens(
 				}
 				t.nofields = true
 				continue
+			case "withexpires":
+				vs = nvs
+				if t.withexpires {
+					err = errDuplicateArgument(strings.ToUpper(wtok))
+					return
+				}
+				t.withexpires = true
+				continue
+			case "withttl":
+				vs = nvs
+				if t.withttl {
+					err = errDuplicateArgument(strings.ToUpper(wtok))
+					return
+				}
+				t.withttl = true
+				continue
+			case "minttl":
+				vs = nvs
+				if t.minttl != 0 {
+					err = errDuplicateArgument(strings.ToUpper(wtok))
+					return
+				}
+				var sttl string
+				if vs, sttl, ok = tokenval(vs); !ok || sttl == "" {
+					err = errInvalidNumberOfArguments
+					return
+				}
+				secs, perr := strconv.ParseFloat(sttl, 64)
+				if perr != nil || secs <= 0 {
+					err = errInvalidArgument(sttl)
+					return
+				}
+				t.minttl = time.Duration(secs * float64(time.Second))
+				continue
+			case "withincomplete":
+				vs = nvs
+				if t.incomplete != 0 {
+					err = errDuplicateArgument(strings.ToUpper(wtok))
+					return
+				}
+				var sms string
+				if vs, sms, ok = tokenval(vs); !ok || sms == "" {
+					err = errInvalidNumberOfArguments
+					return
+				}
+				ms, perr := strconv.ParseFloat(sms, 64)
+				if perr != nil || ms <= 0 {
+					err = errInvalidArgument(sms)
+					return
+				}
+				t.incomplete = time.Duration(ms * float64(time.Millisecond))
+				continue
+			case "tagged":
+				vs = nvs
+				var tag string
+				if vs, tag, ok = tokenval(vs); !ok || tag == "" {
+					err = errInvalidNumberOfArguments
+					return
+				}
+				t.tagged = append(t.tagged, tag)
+				continue
+			case "select":
+				vs = nvs
+				if t.selected != nil {
+					err = errDuplicateArgument(strings.ToUpper(wtok))
+					return
+				}
+				var sfields string
+				if vs, sfields, ok = tokenval(vs); !ok || sfields == "" {
+					err = errInvalidNumberOfArguments
+					return
+				}
+				for _, name := range strings.Split(sfields, ",") {
+					name = strings.TrimSpace(name)
+					if name == "" {
+						err = errInvalidArgument(sfields)
+						return
+					}
+					t.selected = append(t.selected, name)
+				}
+				continue
 			case "limit":
 				vs = nvs
 				if slimit != "" {
@@ -481,7 +617,8 @@ func (s *Server) parseSearchScanBaseTokens(
 					default:
 						err = errInvalidArgument(peek)
 						return
-					case "inside", "outside", "enter", "exit", "cross":
+					case "inside", "outside", "enter", "exit", "cross",
+						"expired", "rising", "falling":
 					}
 					if t.detect[part] {
 						err = errDuplicateArgument(s)
@@ -496,6 +633,7 @@ func (s *Server) parseSearchScanBaseTokens(
 						"enter":   true,
 						"exit":    true,
 						"cross":   true,
+						"expired": true,
 					}
 				}
 				continue
@@ -542,6 +680,39 @@ func (s *Server) parseSearchScanBaseTokens(
 				}
 				t.clip = true
 				continue
+			case "precision":
+				vs = nvs
+				if sdigits != "" {
+					err = errDuplicateArgument(strings.ToUpper(wtok))
+					return
+				}
+				if vs, sdigits, ok = tokenval(vs); !ok || sdigits == "" {
+					err = errInvalidNumberOfArguments
+					return
+				}
+				continue
+			case "simplify":
+				vs = nvs
+				if stolerance != "" {
+					err = errDuplicateArgument(strings.ToUpper(wtok))
+					return
+				}
+				if vs, stolerance, ok = tokenval(vs); !ok || stolerance == "" {
+					err = errInvalidNumberOfArguments
+					return
+				}
+				continue
+			case "crs":
+				vs = nvs
+				if scrs != "" {
+					err = errDuplicateArgument(strings.ToUpper(wtok))
+					return
+				}
+				if vs, scrs, ok = tokenval(vs); !ok || scrs == "" {
+					err = errInvalidNumberOfArguments
+					return
+				}
+				continue
 			}
 		}
 		break
@@ -583,6 +754,26 @@ func (s *Server) parseSearchScanBaseTokens(
 		err = errors.New("DETECT is not allowed when FENCE is not specified")
 		return
 	}
+	if t.withexpires && t.fence {
+		err = errors.New("WITHEXPIRES is not allowed when FENCE is specified")
+		return
+	}
+	if (t.withttl || t.minttl != 0) && t.fence {
+		err = errors.New("WITHTTL and MINTTL are not allowed when FENCE is specified")
+		return
+	}
+	if len(t.tagged) > 0 && t.fence {
+		err = errors.New("TAGGED is not allowed when FENCE is specified")
+		return
+	}
+	if t.incomplete != 0 && t.fence {
+		err = errors.New("WITHINCOMPLETE is not allowed when FENCE is specified")
+		return
+	}
+	if t.incomplete != 0 && ssparse != "" {
+		err = errors.New("WITHINCOMPLETE is not allowed when SPARSE is specified")
+		return
+	}
 
 	t.output = defaultSearchOutput
 	var nvs []string
@@ -611,6 +802,48 @@ func (s *Server) parseSearchScanBaseTokens(
 			}
 		case "bounds":
 			t.output = outputBounds
+		case "flatgeobuf":
+			if t.fence {
+				err = errInvalidArgument(which)
+				return
+			}
+			t.output = outputFlatGeobuf
+		case "cluster":
+			if t.fence || (cmd != "within" && cmd != "intersects") {
+				err = errInvalidArgument(which)
+				return
+			}
+			t.output = outputCluster
+			var szoom string
+			if nvs, szoom, ok = tokenval(nvs); !ok || szoom == "" {
+				err = errInvalidNumberOfArguments
+				return
+			}
+			var zoom uint64
+			if zoom, err = strconv.ParseUint(szoom, 10, 8); err != nil ||
+				zoom > maxClusterZoom {
+				err = errInvalidArgument(szoom)
+				return
+			}
+			t.zoom = int(zoom)
+		case "hull":
+			if t.fence {
+				err = errInvalidArgument(which)
+				return
+			}
+			t.output = outputHull
+			if nvs, t.hull, err = parseHull(nvs); err != nil {
+				return
+			}
+		case "centroid":
+			if t.fence {
+				err = errInvalidArgument(which)
+				return
+			}
+			t.output = outputCentroid
+			if nvs, t.centroid, err = parseCentroid(nvs); err != nil {
+				return
+			}
 		case "ids":
 			t.output = outputIDs
 		}
@@ -631,6 +864,10 @@ func (s *Server) parseSearchScanBaseTokens(
 			return
 		}
 	}
+	if t.nofields && t.selected != nil {
+		err = errors.New("SELECT cannot be used with NOFIELDS")
+		return
+	}
 	if slimit != "" {
 		t.ulimit = true
 		if t.limit, err = strconv.ParseUint(slimit, 10, 64); err != nil || t.limit == 0 {
@@ -638,6 +875,31 @@ func (s *Server) parseSearchScanBaseTokens(
 			return
 		}
 	}
+	t.reshape.Precision = -1
+	if sdigits != "" {
+		var digits uint64
+		if digits, err = strconv.ParseUint(sdigits, 10, 8); err != nil || digits > 15 {
+			err = errInvalidArgument(sdigits)
+			return
+		}
+		t.reshape.Precision = int(digits)
+	}
+	if stolerance != "" {
+		t.reshape.Tolerance, err = strconv.ParseFloat(stolerance, 64)
+		if err != nil || t.reshape.Tolerance < 0 {
+			err = errInvalidArgument(stolerance)
+			return
+		}
+	}
+	if scrs != "" {
+		if t.crs, err = crs.Parse(scrs); err != nil {
+			return
+		}
+		if t.output == outputHashes {
+			err = errors.New("HASHES is not allowed when CRS is specified")
+			return
+		}
+	}
 	if ssparse != "" {
 		t.usparse = true
 		var sparse uint64
@@ -648,6 +910,16 @@ func (s *Server) parseSearchScanBaseTokens(
 		t.sparse = uint8(sparse)
 		t.limit = math.MaxUint64
 	}
+	if t.incomplete != 0 {
+		switch t.output {
+		case outputIDs, outputObjects, outputPoints, outputBounds,
+			outputHashes:
+		default:
+			err = errors.New("WITHINCOMPLETE is only allowed for IDS, " +
+				"OBJECTS, POINTS, BOUNDS and HASHES")
+			return
+		}
+	}
 	vsout = vs
 	tout = t
 	return
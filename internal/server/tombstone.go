@@ -0,0 +1,248 @@
+package server
+
+// Tombstones: a key that has KEYCONFIG TOMBSTONES keeps the objects that DEL
+// and PDEL delete for a while, so that UNDELETE can set them again after a
+// delete that shouldn't have happened. The objects are deleted like without
+// tombstones, and so they're gone from the searches and the geofences get
+// the del events. DEL and UNDELETE are written to the AOF, which gives the
+// followers the same tombstones, and the tombstones are kept again when the
+// AOF is loaded, from the time of the load. AOFSHRINK drops them.
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/glob"
+)
+
+var errTombstonesNotEnabled = errors.New(
+	"tombstones are not enabled for the key")
+
+// tombstone is a deleted object, as the SET that sets it again.
+type tombstone struct {
+	id      string
+	args    []string
+	deleted time.Time
+}
+
+// keyTombstones are the tombstones of a key, by id, and in the order of the
+// deletes for the purge.
+type keyTombstones struct {
+	ids   map[string]*tombstone
+	queue []*tombstone
+}
+
+// tombstones are the tombstones of the keys. DEL has the lock of a single
+// key, and so the tombstones have their own lock.
+type tombstones struct {
+	mu   sync.Mutex
+	keys map[string]*keyTombstones
+}
+
+// purge removes the tombstones of a key that are older than the retention.
+// The lock must be held.
+func (ts *tombstones) purge(key string, retention time.Duration, now time.Time) *keyTombstones {
+	kt := ts.keys[key]
+	if kt == nil {
+		return nil
+	}
+	var i int
+	for ; i < len(kt.queue); i++ {
+		t := kt.queue[i]
+		if now.Sub(t.deleted) < retention {
+			break
+		}
+		if kt.ids[t.id] == t {
+			delete(kt.ids, t.id)
+		}
+	}
+	kt.queue = kt.queue[i:]
+	if len(kt.ids) == 0 {
+		delete(ts.keys, key)
+		return nil
+	}
+	return kt
+}
+
+// drop removes the tombstones of a key.
+func (ts *tombstones) drop(key string) {
+	ts.mu.Lock()
+	delete(ts.keys, key)
+	ts.mu.Unlock()
+}
+
+// dropAll removes all of the tombstones.
+func (ts *tombstones) dropAll() {
+	ts.mu.Lock()
+	ts.keys = nil
+	ts.mu.Unlock()
+}
+
+// tombstoneRetention returns how long the deleted objects of a key are kept,
+// which is zero without tombstones.
+func (server *Server) tombstoneRetention(key string) time.Duration {
+	return time.Duration(server.keyConfigs[key].tombstones * float64(time.Second))
+}
+
+// keepTombstone keeps an object that's about to be deleted, when its key has
+// tombstones. The key lock must be held.
+func (server *Server) keepTombstone(col *collection.Collection, key, id string) {
+	retention := server.tombstoneRetention(key)
+	if retention == 0 {
+		return
+	}
+	obj, fields, ex, ok := col.Get(id)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	meta, _ := col.Meta(id)
+	snames, svalues := col.StringFields(id)
+	t := &tombstone{
+		id: id,
+		args: appendSetArgs(nil, key, id, obj, col.FieldMap(), col.FieldArr(),
+			fields, col.Tags(id), meta, snames, svalues, ex, now.UnixNano()),
+		deleted: now,
+	}
+	ts := &server.tombstones
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	kt := ts.purge(key, retention, now)
+	if kt == nil {
+		if ts.keys == nil {
+			ts.keys = make(map[string]*keyTombstones)
+		}
+		kt = &keyTombstones{ids: make(map[string]*tombstone)}
+		ts.keys[key] = kt
+	}
+	kt.ids[id] = t
+	kt.queue = append(kt.queue, t)
+}
+
+// cmdUndelete sets a deleted object of a key again, with the fields, tags,
+// metadata and expiration that it had, and returns 1. It returns 0 when
+// there's no tombstone, and when the id was set again after the delete,
+// which removes the tombstone.
+//
+//	UNDELETE key id
+func (server *Server) cmdUndelete(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key, id string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, d, errInvalidNumberOfArguments
+	}
+	if vs, id, ok = tokenval(vs); !ok || id == "" {
+		return NOMessage, d, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, d, errInvalidNumberOfArguments
+	}
+	retention := server.tombstoneRetention(key)
+	if retention == 0 {
+		return NOMessage, d, errTombstonesNotEnabled
+	}
+	var t *tombstone
+	ts := &server.tombstones
+	ts.mu.Lock()
+	if kt := ts.purge(key, retention, start); kt != nil {
+		if t = kt.ids[id]; t != nil {
+			delete(kt.ids, id)
+		}
+	}
+	ts.mu.Unlock()
+	if col := server.getCol(key); t != nil && col != nil {
+		if _, exists := col.Expires(id); exists {
+			t = nil
+		}
+	}
+	if t != nil {
+		// the set is what the geofences get
+		_, d, err = server.cmdSet(&Message{Args: t.args, OutputType: RESP})
+		if err != nil {
+			return NOMessage, d, err
+		}
+	} else {
+		d.key, d.id = key, id
+		d.command = "undelete"
+		d.timestamp = start
+	}
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"undeleted":` +
+			strconv.FormatBool(t != nil) + `,"elapsed":"` +
+			time.Since(start).String() + `"}`)
+	case RESP:
+		if t != nil {
+			res = resp.IntegerValue(1)
+		} else {
+			res = resp.IntegerValue(0)
+		}
+	}
+	return res, d, nil
+}
+
+// cmdTombstones returns the ids of the deleted objects of a key that match a
+// pattern, all of them by default, in the order of the deletes.
+//
+//	TOMBSTONES key [pattern]
+func (server *Server) cmdTombstones(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key string
+	pattern := "*"
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) > 0 {
+		if vs, pattern, ok = tokenval(vs); !ok || pattern == "" {
+			return NOMessage, errInvalidNumberOfArguments
+		}
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	retention := server.tombstoneRetention(key)
+	if retention == 0 {
+		return NOMessage, errTombstonesNotEnabled
+	}
+	var ids []string
+	ts := &server.tombstones
+	ts.mu.Lock()
+	if kt := ts.purge(key, retention, start); kt != nil {
+		for _, t := range kt.queue {
+			if kt.ids[t.id] != t {
+				continue
+			}
+			if match, _ := glob.Match(pattern, t.id); match {
+				ids = append(ids, t.id)
+			}
+		}
+	}
+	ts.mu.Unlock()
+	switch msg.OutputType {
+	case JSON:
+		buf := []byte(`{"ok":true,"ids":[`)
+		for i, id := range ids {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, id)
+		}
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+`"}`...)
+		return resp.StringValue(string(buf)), nil
+	case RESP:
+		vals := make([]resp.Value, len(ids))
+		for i, id := range ids {
+			vals[i] = resp.StringValue(id)
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
@@ -0,0 +1,408 @@
+package server
+
+// Trajectories: timestamped positions per id, and spatiotemporal queries.
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/trajectory"
+)
+
+// parseUnixTime parses unix seconds, which may have a fraction, into unix
+// nanoseconds.
+func parseUnixTime(s string) (int64, error) {
+	secs, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(secs) || math.IsInf(secs, 0) {
+		return 0, errInvalidArgument(s)
+	}
+	return int64(secs * float64(time.Second)), nil
+}
+
+// formatUnixTime formats unix nanoseconds as unix seconds.
+func formatUnixTime(t int64) string {
+	return strconv.FormatFloat(float64(t)/float64(time.Second), 'f', -1, 64)
+}
+
+// parseTimeWindow parses the optional START and END arguments.
+func parseTimeWindow(vs []string) (rest []string, start, end int64, err error) {
+	start, end = math.MinInt64, math.MaxInt64
+	for {
+		nvs, arg, ok := tokenval(vs)
+		if !ok || (!lc(arg, "start") && !lc(arg, "end")) {
+			break
+		}
+		var s string
+		if nvs, s, ok = tokenval(nvs); !ok || s == "" {
+			return vs, 0, 0, errInvalidNumberOfArguments
+		}
+		var t int64
+		if t, err = parseUnixTime(s); err != nil {
+			return vs, 0, 0, err
+		}
+		if lc(arg, "start") {
+			start = t
+		} else {
+			end = t
+		}
+		vs = nvs
+	}
+	return vs, start, end, nil
+}
+
+// cmdTrackConfig sets the retention and downsampling of the trajectories in
+// a key.
+//
+//	TRACKCONFIG key [RETENTION seconds] [INTERVAL seconds] [MAXPOINTS n]
+func (server *Server) cmdTrackConfig(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var opts trajectory.Options
+	for len(vs) > 0 {
+		var arg, sval string
+		vs, arg, _ = tokenval(vs)
+		if vs, sval, ok = tokenval(vs); !ok || sval == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		switch {
+		case lc(arg, "retention"), lc(arg, "interval"):
+			secs, perr := strconv.ParseFloat(sval, 64)
+			if perr != nil || secs < 0 {
+				err = errInvalidArgument(sval)
+				return
+			}
+			dur := time.Duration(secs * float64(time.Second))
+			if lc(arg, "retention") {
+				opts.Retention = dur
+			} else {
+				opts.Interval = dur
+			}
+		case lc(arg, "maxpoints"):
+			n, perr := strconv.ParseUint(sval, 10, 32)
+			if perr != nil {
+				err = errInvalidArgument(sval)
+				return
+			}
+			opts.MaxPoints = int(n)
+		default:
+			err = errInvalidArgument(arg)
+			return
+		}
+	}
+	if store := server.tracks[key]; store != nil {
+		store.SetOptions(opts)
+	} else {
+		server.tracks[key] = trajectory.NewStore(opts)
+	}
+	d.command = "trackconfig"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// cmdTrack appends a timestamped position to the trajectory of an id. When
+// the timestamp is omitted the current time is used, and it's added to the
+// command so that the AOF replays the same position.
+//
+//	TRACK key id [TIMESTAMP seconds] POINT lat lon [z]
+func (server *Server) cmdTrack(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, d.id, ok = tokenval(vs); !ok || d.id == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var pos trajectory.Position
+	var arg string
+	if vs, arg, ok = tokenval(vs); ok && lc(arg, "timestamp") {
+		var s string
+		if vs, s, ok = tokenval(vs); !ok || s == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		if pos.Time, err = parseUnixTime(s); err != nil {
+			return
+		}
+		vs, arg, ok = tokenval(vs)
+	} else {
+		pos.Time = time.Now().UnixNano()
+		args := append([]string{}, msg.Args[:3]...)
+		args = append(args, "timestamp", formatUnixTime(pos.Time))
+		msg.Args = append(args, msg.Args[3:]...)
+	}
+	if !ok || !lc(arg, "point") {
+		err = errInvalidArgument(arg)
+		return
+	}
+	var slat, slon, sz string
+	if vs, slat, ok = tokenval(vs); !ok || slat == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, slon, ok = tokenval(vs); !ok || slon == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	vs, sz, _ = tokenval(vs)
+	if len(vs) != 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if pos.Point.Y, err = strconv.ParseFloat(slat, 64); err != nil {
+		err = errInvalidArgument(slat)
+		return
+	}
+	if pos.Point.X, err = strconv.ParseFloat(slon, 64); err != nil {
+		err = errInvalidArgument(slon)
+		return
+	}
+	if sz != "" {
+		if pos.Z, err = strconv.ParseFloat(sz, 64); err != nil {
+			err = errInvalidArgument(sz)
+			return
+		}
+	}
+	store := server.tracks[d.key]
+	if store == nil {
+		store = trajectory.NewStore(trajectory.Options{})
+		server.tracks[d.key] = store
+	}
+	var n int
+	if store.Append(d.id, pos) {
+		n = 1
+	}
+	d.command = "track"
+	d.updated = n > 0
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
+
+// cmdTrackDel deletes the trajectory of an id, or all trajectories in a key.
+//
+//	TRACKDEL key [id]
+func (server *Server) cmdTrackDel(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	vs, d.id, _ = tokenval(vs)
+	if len(vs) != 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var n int
+	if store := server.tracks[d.key]; store != nil {
+		if d.id == "" {
+			n = store.Len()
+			delete(server.tracks, d.key)
+		} else if store.Delete(d.id) {
+			n = 1
+		}
+	}
+	d.command = "trackdel"
+	d.updated = n > 0
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
+
+// cmdTrajectory returns the positions of an id.
+//
+//	TRAJECTORY key id [START seconds] [END seconds]
+func (server *Server) cmdTrajectory(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key, id string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if vs, id, ok = tokenval(vs); !ok || id == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	vs, tstart, tend, err := parseTimeWindow(vs)
+	if err != nil {
+		return NOMessage, err
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var traj *trajectory.Trajectory
+	if store := server.tracks[key]; store != nil {
+		traj = store.Get(id)
+	}
+	if traj == nil {
+		if msg.OutputType == RESP {
+			return resp.NullValue(), nil
+		}
+		return NOMessage, errIDNotFound
+	}
+	var buf bytes.Buffer
+	var vals []resp.Value
+	if msg.OutputType == JSON {
+		buf.WriteString(`{"ok":true,"id":` + jsonString(id) + `,"positions":[`)
+	}
+	var i int
+	traj.Range(tstart, tend, func(pos trajectory.Position) bool {
+		if msg.OutputType == JSON {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(`{"time":` + formatUnixTime(pos.Time) +
+				`,"lat":` + strconv.FormatFloat(pos.Point.Y, 'f', -1, 64) +
+				`,"lon":` + strconv.FormatFloat(pos.Point.X, 'f', -1, 64))
+			if pos.Z != 0 {
+				buf.WriteString(`,"z":` + strconv.FormatFloat(pos.Z, 'f', -1, 64))
+			}
+			buf.WriteByte('}')
+		} else {
+			pvals := []resp.Value{
+				resp.StringValue(formatUnixTime(pos.Time)),
+				resp.StringValue(strconv.FormatFloat(pos.Point.Y, 'f', -1, 64)),
+				resp.StringValue(strconv.FormatFloat(pos.Point.X, 'f', -1, 64)),
+			}
+			if pos.Z != 0 {
+				pvals = append(pvals,
+					resp.StringValue(strconv.FormatFloat(pos.Z, 'f', -1, 64)))
+			}
+			vals = append(vals, resp.ArrayValue(pvals))
+		}
+		i++
+		return true
+	})
+	switch msg.OutputType {
+	case JSON:
+		buf.WriteString(`],"count":` + strconv.Itoa(i))
+		buf.WriteString(`,"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
+
+// cmdPassed returns the ids with a trajectory that passed through an area
+// during a time window.
+//
+//	PASSED key [START seconds] [END seconds] area
+func (server *Server) cmdPassed(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	vs, tstart, tend, err := parseTimeWindow(vs)
+	if err != nil {
+		return NOMessage, err
+	}
+	vs, area, err := server.parseArea(vs, false)
+	if err != nil {
+		return NOMessage, err
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var ids []string
+	if store := server.tracks[key]; store != nil {
+		store.Scan(func(id string, traj *trajectory.Trajectory) bool {
+			if traj.Passed(area, tstart, tend) {
+				ids = append(ids, id)
+			}
+			return true
+		})
+	}
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"ids":[`)
+		for i, id := range ids {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(jsonString(id))
+		}
+		buf.WriteString(`],"count":` + strconv.Itoa(len(ids)))
+		buf.WriteString(`,"elapsed":"` + time.Since(start).String() + "\"}")
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		vals := make([]resp.Value, len(ids))
+		for i, id := range ids {
+			vals[i] = resp.StringValue(id)
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
+
+// trackCommands returns the commands that rebuild the trajectories of a key,
+// which is used by the aofshrink.
+func trackCommands(key string, store *trajectory.Store) [][]string {
+	opts := store.Options()
+	cmds := [][]string{{"trackconfig", key,
+		"retention", strconv.FormatFloat(opts.Retention.Seconds(), 'f', -1, 64),
+		"interval", strconv.FormatFloat(opts.Interval.Seconds(), 'f', -1, 64),
+		"maxpoints", strconv.Itoa(opts.MaxPoints),
+	}}
+	store.Scan(func(id string, traj *trajectory.Trajectory) bool {
+		traj.Range(math.MinInt64, math.MaxInt64, func(pos trajectory.Position) bool {
+			cmd := []string{"track", key, id,
+				"timestamp", formatUnixTime(pos.Time), "point",
+				strconv.FormatFloat(pos.Point.Y, 'f', -1, 64),
+				strconv.FormatFloat(pos.Point.X, 'f', -1, 64),
+			}
+			if pos.Z != 0 {
+				cmd = append(cmd, strconv.FormatFloat(pos.Z, 'f', -1, 64))
+			}
+			cmds = append(cmds, cmd)
+			return true
+		})
+		return true
+	})
+	return cmds
+}
+
+// sortedTrackKeys returns the keys that have trajectories.
+func (server *Server) sortedTrackKeys() []string {
+	keys := make([]string, 0, len(server.tracks))
+	for key := range server.tracks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,198 @@
+package server
+
+// Sliding TTLs: the objects of a key that has a TTLCONFIG expire when they
+// haven't been set or touched, and optionally read, within the TTL of the
+// key.
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// ttlConfig is the TTLCONFIG of a key.
+type ttlConfig struct {
+	ttl    time.Duration
+	onRead bool // GET refreshes the expiration
+}
+
+// readTouches are the objects that were read from keys that have ONREAD.
+// Reads only hold the read lock, so the expirations are refreshed later by
+// the expiration sweep, which holds the write lock.
+type readTouches struct {
+	mu   sync.Mutex
+	keys map[string]map[string]bool
+}
+
+// cmdTTLConfig sets the sliding TTL of a key. A TTL of zero removes it.
+//
+//	TTLCONFIG key seconds [ONREAD]
+func (server *Server) cmdTTLConfig(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var sttl string
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if vs, sttl, ok = tokenval(vs); !ok || sttl == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	secs, perr := strconv.ParseFloat(sttl, 64)
+	if perr != nil || secs < 0 {
+		err = errInvalidArgument(sttl)
+		return
+	}
+	conf := ttlConfig{ttl: time.Duration(secs * float64(time.Second))}
+	for len(vs) > 0 {
+		var arg string
+		vs, arg, _ = tokenval(vs)
+		if !lc(arg, "onread") {
+			err = errInvalidArgument(arg)
+			return
+		}
+		conf.onRead = true
+	}
+	if conf.ttl == 0 {
+		delete(server.ttlConfigs, d.key)
+	} else {
+		server.ttlConfigs[d.key] = conf
+	}
+	d.command = "ttlconfig"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// cmdTouch refreshes the sliding TTL of objects, and their access time for
+// the evict-lru-object policy. Returns the number of objects that exist.
+//
+//	TOUCH key id [id ...]
+func (server *Server) cmdTouch(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	if len(vs) == 0 {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var n int
+	if col := server.getCol(d.key); col != nil {
+		ex := server.slidingExpires(d.key)
+		for _, id := range vs {
+			if _, _, _, ok := col.Get(id); !ok {
+				continue
+			}
+			if ex != 0 {
+				col.SetExpires(id, ex)
+			}
+			n++
+		}
+	}
+	d.command = "touch"
+	d.updated = n > 0
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	switch msg.OutputType {
+	case JSON:
+		res = resp.StringValue(`{"ok":true,"count":` + strconv.Itoa(n) +
+			`,"elapsed":"` + time.Since(start).String() + "\"}")
+	case RESP:
+		res = resp.IntegerValue(n)
+	}
+	return
+}
+
+// slidingExpires returns the expiration of an object that's set in a key
+// right now, or zero when the key doesn't have a sliding TTL.
+func (server *Server) slidingExpires(key string) int64 {
+	conf, ok := server.ttlConfigs[key]
+	if !ok {
+		return 0
+	}
+	return time.Now().Add(conf.ttl).UnixNano()
+}
+
+// touchOnRead queues the refresh of the expiration of an object that was
+// read, when the key has ONREAD. Followers don't refresh, they get the
+// TOUCH commands of the leader.
+func (server *Server) touchOnRead(key, id string) {
+	if conf, ok := server.ttlConfigs[key]; !ok || !conf.onRead ||
+		server.config.followHost() != "" {
+		return
+	}
+	rt := &server.readTouches
+	rt.mu.Lock()
+	if rt.keys == nil {
+		rt.keys = make(map[string]map[string]bool)
+	}
+	ids := rt.keys[key]
+	if ids == nil {
+		ids = make(map[string]bool)
+		rt.keys[key] = ids
+	}
+	ids[id] = true
+	rt.mu.Unlock()
+}
+
+// applyReadTouches refreshes the expirations of the objects of a key that
+// were read since the last sweep. It's called with the write lock held, and
+// the TOUCH commands are written to the aof.
+func (server *Server) applyReadTouches(key string, col *collection.Collection) {
+	rt := &server.readTouches
+	rt.mu.Lock()
+	ids := rt.keys[key]
+	delete(rt.keys, key)
+	rt.mu.Unlock()
+	if len(ids) == 0 || col == nil {
+		return
+	}
+	args := make([]string, 0, len(ids)+2)
+	args = append(args, "touch", key)
+	for id := range ids {
+		args = append(args, id)
+	}
+	sort.Strings(args[2:])
+	msg := &Message{Args: args}
+	_, d, err := server.cmdTouch(msg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := server.writeAOF(msg.Args, &d); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ttlCommands returns the commands that rebuild the TTLCONFIG of the keys,
+// for aofshrink.
+func (server *Server) ttlCommands() [][]string {
+	keys := make([]string, 0, len(server.ttlConfigs))
+	for key := range server.ttlConfigs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmds := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		conf := server.ttlConfigs[key]
+		cmd := []string{"ttlconfig", key,
+			strconv.FormatFloat(conf.ttl.Seconds(), 'f', -1, 64)}
+		if conf.onRead {
+			cmd = append(cmd, "ONREAD")
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
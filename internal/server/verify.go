@@ -0,0 +1,428 @@
+package server
+
+// Verify: VERIFY returns digests of the objects of a key over ranges of
+// ids, which a follower compares with the digests of its leader to find
+// where the two diverged. The digest of a range is the md5 of the ids, the
+// objects and the fields of the range, in the order of the ids. The
+// expirations, tags and metadata aren't in it, as a follower sets the
+// expirations when the writes arrive.
+//
+//	VERIFY key [RANGE start end] [SPLIT n]
+//	VERIFY key RANGE start end REPAIR [id ...]
+//	VERIFY key LEADER [REPAIR]
+//
+// A range is from its start id, included, to its end id, excluded, and an
+// empty end is the end of the key. SPLIT splits the range into n ranges of
+// about the same number of objects, at the ids of the server that's asked.
+//
+// A follower with LEADER asks its leader for the digests of the key, and
+// splits the ranges that differ again, until they have no more than
+// verifyLeafObjects objects on the leader, and returns those ranges. With
+// REPAIR the follower asks the leader to repair each of them. A leader
+// repairs a range by writing its objects to the AOF as SETs, and DELs for
+// the ids that were given that it doesn't have, and the writes go to all of
+// the followers, which is cheaper than a full resync. The writes that are
+// on their way to a follower make their ranges differ too.
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// verifySplit is the number of ranges that a range of a follower's VERIFY
+// is split into.
+const verifySplit = 16
+
+// verifyLeafObjects is the number of objects under which a range of a
+// follower's VERIFY that differs isn't split any more.
+const verifyLeafObjects = 100
+
+var errNotFollower = errors.New("not a follower")
+
+// verifyRange is the digest of a range of ids.
+type verifyRange struct {
+	start, end string
+	count      int
+	digest     string
+}
+
+// inRange returns true when an id is in the range.
+func (r verifyRange) inRange(id string) bool {
+	return id >= r.start && (r.end == "" || id < r.end)
+}
+
+// verifyHash writes an object to the digest of its range.
+func verifyHash(h hash.Hash, id string, obj geojson.Object,
+	fmap map[string]int, fnames []string, fields []float64,
+) {
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write([]byte(obj.String()))
+	h.Write([]byte{0})
+	fvs := orderFields(fmap, fnames, fields)
+	sort.Slice(fvs, func(i, j int) bool { return fvs[i].field < fvs[j].field })
+	for _, fv := range fvs {
+		h.Write([]byte(fv.field))
+		h.Write([]byte{0})
+		h.Write(strconv.AppendFloat(nil, fv.value, 'f', -1, 64))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{1})
+}
+
+// scanRange iterates through the objects of a range.
+func scanRange(col *collection.Collection, r verifyRange,
+	iter func(id string, obj geojson.Object, fields []float64, ex int64) bool,
+) {
+	col.ScanGreaterOrEqual(r.start, false, nil, nil,
+		func(id string, obj geojson.Object, fields []float64, ex int64) bool {
+			if !r.inRange(id) {
+				return false
+			}
+			return iter(id, obj, fields, ex)
+		},
+	)
+}
+
+// verifyRanges splits a range of a collection into n ranges and returns
+// their digests. The key lock must be held.
+func verifyRanges(col *collection.Collection, r verifyRange, n int) []verifyRange {
+	var ids []string
+	if col != nil {
+		scanRange(col, r, func(id string, _ geojson.Object, _ []float64, _ int64) bool {
+			ids = append(ids, id)
+			return true
+		})
+	}
+	if n > len(ids) {
+		n = len(ids)
+	}
+	if n < 1 {
+		n = 1
+	}
+	ranges := make([]verifyRange, n)
+	for i := range ranges {
+		ranges[i].start = r.start
+		if i > 0 {
+			ranges[i].start = ids[i*len(ids)/n]
+			ranges[i-1].end = ranges[i].start
+		}
+	}
+	ranges[n-1].end = r.end
+	if col == nil {
+		ranges[0].digest = hex.EncodeToString(md5.New().Sum(nil))
+		return ranges
+	}
+	fmap, fnames := col.FieldMap(), col.FieldArr()
+	for i := range ranges {
+		h := md5.New()
+		scanRange(col, ranges[i], func(id string, obj geojson.Object, fields []float64, _ int64) bool {
+			verifyHash(h, id, obj, fmap, fnames, fields)
+			ranges[i].count++
+			return true
+		})
+		ranges[i].digest = hex.EncodeToString(h.Sum(nil))
+	}
+	return ranges
+}
+
+// verifyRepair writes the objects of a range of a leader to the AOF, and
+// DELs for the ids that it doesn't have, and returns the number of
+// commands.
+func (server *Server) verifyRepair(key string, r verifyRange, ids []string) (int, error) {
+	if server.config.followHost() != "" {
+		return 0, errors.New("not the leader")
+	}
+	if server.config.readOnly() {
+		return 0, errors.New("read only")
+	}
+	if err := server.waitWrites(); err != nil {
+		return 0, err
+	}
+	defer server.lockKeyWriteFor(key, func(*collection.Collection) bool {
+		return false
+	})()
+	col := server.getCol(key)
+	var n int
+	for _, id := range ids {
+		if !r.inRange(id) {
+			continue
+		}
+		if col != nil {
+			if _, _, _, ok := col.Get(id); ok {
+				continue
+			}
+		}
+		if err := server.writeAOF([]string{"del", key, id}, nil); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if col == nil {
+		return n, nil
+	}
+	fmap, fnames := col.FieldMap(), col.FieldArr()
+	now := time.Now().UnixNano()
+	var values []string
+	var err error
+	scanRange(col, r, func(id string, obj geojson.Object, fields []float64, ex int64) bool {
+		meta, _ := col.Meta(id)
+		snames, svalues := col.StringFields(id)
+		values = appendSetArgs(values[:0], key, id, obj, fmap, fnames,
+			fields, col.Tags(id), meta, snames, svalues, ex, now)
+		if err = server.writeAOF(values, nil); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	return n, err
+}
+
+// verifyLeader compares the digests of a key with the ones of the leader,
+// and returns the ranges that differ, with the number of objects of the
+// leader, and with repair has the leader repair them.
+func (server *Server) verifyLeader(key string, repair bool) (
+	diverged []verifyRange, local []int, err error,
+) {
+	host, port := server.config.followHost(), server.config.followPort()
+	if host == "" {
+		return nil, nil, errNotFollower
+	}
+	conn, err := DialTimeout(fmt.Sprintf("%s:%d", host, port), time.Second*2)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	if auth := server.config.leaderAuth(); auth != "" {
+		if err := server.followDoLeaderAuth(conn, auth); err != nil {
+			return nil, nil, err
+		}
+	}
+	queue := []verifyRange{{}}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+		ranges, err := connVerify(conn, key, r, verifySplit)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, lr := range ranges {
+			unlock := server.lockKeyRead(key)
+			fr := verifyRanges(server.getCol(key), lr, 1)[0]
+			unlock()
+			if fr.digest == lr.digest {
+				continue
+			}
+			if lr.count > verifyLeafObjects && len(ranges) > 1 {
+				queue = append(queue, lr)
+				continue
+			}
+			diverged = append(diverged, lr)
+			local = append(local, fr.count)
+		}
+	}
+	server.statsVerifyDiff.add(len(diverged))
+	if !repair {
+		return diverged, local, nil
+	}
+	for _, r := range diverged {
+		var ids []string
+		unlock := server.lockKeyRead(key)
+		if col := server.getCol(key); col != nil {
+			scanRange(col, r, func(id string, _ geojson.Object, _ []float64, _ int64) bool {
+				ids = append(ids, id)
+				return true
+			})
+		}
+		unlock()
+		args := []interface{}{key, "range", r.start, r.end, "repair"}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+		v, err := conn.Do("verify", args...)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v.Error() != nil {
+			return nil, nil, v.Error()
+		}
+		server.statsVerifyRepair.add(1)
+		log.Infof("verify: repaired %s from %q to %q with %d writes",
+			key, r.start, r.end, v.Integer())
+	}
+	return diverged, local, nil
+}
+
+// connVerify returns the digests of a range of a key of a leader, split
+// into n ranges.
+func connVerify(conn *RESPConn, key string, r verifyRange, n int) ([]verifyRange, error) {
+	v, err := conn.Do("verify", key, "range", r.start, r.end, "split", n)
+	if err != nil {
+		return nil, err
+	}
+	if v.Error() != nil {
+		return nil, v.Error()
+	}
+	var ranges []verifyRange
+	for _, v := range v.Array() {
+		vals := v.Array()
+		if len(vals) != 4 {
+			return nil, errors.New("invalid response to verify request")
+		}
+		ranges = append(ranges, verifyRange{
+			start:  vals[0].String(),
+			end:    vals[1].String(),
+			count:  vals[2].Integer(),
+			digest: vals[3].String(),
+		})
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("invalid response to verify request")
+	}
+	return ranges, nil
+}
+
+func (server *Server) cmdVerify(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	var r verifyRange
+	var hasRange, leader, repair bool
+	split := 1
+	var ids []string
+	for len(vs) > 0 {
+		var tok string
+		vs, tok, _ = tokenval(vs)
+		switch {
+		case lc(tok, "range") && !hasRange && !leader:
+			if vs, r.start, ok = tokenval(vs); !ok {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+			if vs, r.end, ok = tokenval(vs); !ok {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+			if r.end != "" && r.end <= r.start {
+				return NOMessage, errInvalidArgument(r.end)
+			}
+			hasRange = true
+		case lc(tok, "split") && !repair && !leader:
+			var sn string
+			if vs, sn, ok = tokenval(vs); !ok || sn == "" {
+				return NOMessage, errInvalidNumberOfArguments
+			}
+			n, err := strconv.Atoi(sn)
+			if err != nil || n < 1 || n > 1000 {
+				return NOMessage, errInvalidArgument(sn)
+			}
+			split = n
+		case lc(tok, "leader") && !hasRange && split == 1:
+			leader = true
+		case lc(tok, "repair") && (hasRange || leader) && split == 1:
+			repair = true
+			if hasRange {
+				ids, vs = vs, nil
+			}
+		default:
+			return NOMessage, errInvalidArgument(tok)
+		}
+	}
+	if leader {
+		diverged, local, err := server.verifyLeader(key, repair)
+		if err != nil {
+			return NOMessage, err
+		}
+		switch msg.OutputType {
+		case JSON:
+			buf := []byte(`{"ok":true,"ranges":[`)
+			for i, r := range diverged {
+				if i > 0 {
+					buf = append(buf, ',')
+				}
+				buf = append(buf, `{"start":`...)
+				buf = appendJSONString(buf, r.start)
+				buf = append(buf, `,"end":`...)
+				buf = appendJSONString(buf, r.end)
+				buf = append(buf, `,"leader":`...)
+				buf = strconv.AppendInt(buf, int64(r.count), 10)
+				buf = append(buf, `,"local":`...)
+				buf = strconv.AppendInt(buf, int64(local[i]), 10)
+				buf = append(buf, '}')
+			}
+			buf = append(buf, `],"repaired":`...)
+			buf = strconv.AppendBool(buf, repair)
+			buf = append(buf, `,"elapsed":"`+time.Since(start).String()+`"}`...)
+			return resp.BytesValue(buf), nil
+		case RESP:
+			vals := make([]resp.Value, len(diverged))
+			for i, r := range diverged {
+				vals[i] = resp.ArrayValue([]resp.Value{
+					resp.StringValue(r.start), resp.StringValue(r.end),
+					resp.IntegerValue(r.count), resp.IntegerValue(local[i]),
+				})
+			}
+			return resp.ArrayValue(vals), nil
+		}
+		return NOMessage, nil
+	}
+	if repair {
+		n, err := server.verifyRepair(key, r, ids)
+		if err != nil {
+			return NOMessage, err
+		}
+		switch msg.OutputType {
+		case JSON:
+			return resp.StringValue(`{"ok":true,"writes":` + strconv.Itoa(n) +
+				`,"elapsed":"` + time.Since(start).String() + `"}`), nil
+		case RESP:
+			return resp.IntegerValue(n), nil
+		}
+		return NOMessage, nil
+	}
+	unlock := server.lockKeyRead(key)
+	ranges := verifyRanges(server.getCol(key), r, split)
+	unlock()
+	switch msg.OutputType {
+	case JSON:
+		buf := []byte(`{"ok":true,"ranges":[`)
+		for i, r := range ranges {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `{"start":`...)
+			buf = appendJSONString(buf, r.start)
+			buf = append(buf, `,"end":`...)
+			buf = appendJSONString(buf, r.end)
+			buf = append(buf, `,"count":`...)
+			buf = strconv.AppendInt(buf, int64(r.count), 10)
+			buf = append(buf, `,"digest":"`+r.digest+`"}`...)
+		}
+		buf = append(buf, `],"elapsed":"`+time.Since(start).String()+`"}`...)
+		return resp.BytesValue(buf), nil
+	case RESP:
+		vals := make([]resp.Value, len(ranges))
+		for i, r := range ranges {
+			vals[i] = resp.ArrayValue([]resp.Value{
+				resp.StringValue(r.start), resp.StringValue(r.end),
+				resp.IntegerValue(r.count), resp.StringValue(r.digest),
+			})
+		}
+		return resp.ArrayValue(vals), nil
+	}
+	return NOMessage, nil
+}
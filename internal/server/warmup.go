@@ -0,0 +1,124 @@
+package server
+
+// Warmup: the first searches of a key after a restart are slower than the
+// next ones, as the memory of the key isn't in the caches, and as the
+// snapshot of snapshot.go and the cluster index of cluster.go are made by
+// the first search that needs them. WARMUP reads the trees of the keys and
+// makes the snapshots, and the cluster indexes when asked to, ahead of the
+// searches. The keys of the warmup config property, a list of patterns, are
+// warmed up after the AOF is loaded, before the server accepts connections.
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/glob"
+	"github.com/tidwall/tile38/internal/log"
+)
+
+// warmup warms up the keys that match any of the patterns, one key at a
+// time, and returns the number of keys and objects.
+func (server *Server) warmup(patterns []string, clusters bool) (keys, objects int) {
+	var conts []*collectionKeyContainer
+	server.mu.RLock()
+	server.cols.Ascend(nil, func(v interface{}) bool {
+		c := v.(*collectionKeyContainer)
+		for _, pattern := range patterns {
+			if match, _ := glob.Match(pattern, c.key); match {
+				conts = append(conts, c)
+				break
+			}
+		}
+		return true
+	})
+	server.mu.RUnlock()
+	snapshots := server.config.snapshotEpoch() > 0
+	for _, c := range conts {
+		// The key lock is taken one key at a time, like for the snapshots,
+		// which only blocks the writers of that key.
+		server.mu.RLock()
+		if server.getColContainer(c.key) != c {
+			// dropped or replaced since
+			server.mu.RUnlock()
+			continue
+		}
+		c.mu.RLock()
+		n, _ := c.col.Warmup()
+		col := c.col
+		var cp *collection.SnapshotCopy
+		if snapshots {
+			col = c.loadSnapshot()
+			if col == nil || col.Version() != c.col.Version() {
+				cp = c.col.SnapshotCopy()
+			}
+			atomic.StoreInt64(&c.snapRead, time.Now().UnixNano())
+		}
+		if clusters && cp == nil {
+			server.clusterIndex(c.key, col, nil)
+		}
+		c.mu.RUnlock()
+		server.mu.RUnlock()
+		if cp != nil {
+			// the snapshot is built once the locks are released
+			col = cp.Build()
+			c.storeSnapshot(col)
+			if clusters {
+				server.mu.RLock()
+				server.clusterIndex(c.key, col, nil)
+				server.mu.RUnlock()
+			}
+		}
+		keys++
+		objects += n
+	}
+	return keys, objects
+}
+
+// warmupConfigKeys warms up the keys of the warmup config property.
+func (server *Server) warmupConfigKeys() {
+	patterns := strings.Fields(server.config.warmup())
+	if len(patterns) == 0 {
+		return
+	}
+	start := time.Now()
+	keys, objects := server.warmup(patterns, false)
+	log.Infof("Warmed up %d keys, %d objects in %s", keys, objects,
+		time.Since(start))
+}
+
+// cmdWarmup warms up the keys that match a pattern, all of them by default,
+// and with CLUSTERS makes their cluster indexes, and returns the number of
+// keys.
+//
+//	WARMUP [pattern] [CLUSTERS]
+func (server *Server) cmdWarmup(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	pattern := "*"
+	var clusters bool
+	if len(vs) > 0 && !lc(vs[0], "clusters") {
+		pattern = vs[0]
+		vs = vs[1:]
+	}
+	if len(vs) > 0 && lc(vs[0], "clusters") {
+		clusters = true
+		vs = vs[1:]
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	keys, objects := server.warmup([]string{pattern}, clusters)
+	switch msg.OutputType {
+	case JSON:
+		return resp.StringValue(`{"ok":true,"keys":` + strconv.Itoa(keys) +
+			`,"objects":` + strconv.Itoa(objects) +
+			`,"elapsed":"` + time.Since(start).String() + `"}`), nil
+	case RESP:
+		return resp.IntegerValue(keys), nil
+	}
+	return NOMessage, nil
+}
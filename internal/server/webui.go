@@ -0,0 +1,59 @@
+package server
+
+// Web console: a single page that's served at /ui of the HTTP transport when
+// the server is started with --web-ui. The page runs its commands with the
+// HTTP and WebSocket APIs, so it needs the requirepass password like any
+// other client.
+
+import (
+	_ "embed" // for the page
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/tile38/core"
+)
+
+//go:embed webui/index.html
+var webUIPage []byte
+
+// writeWebUI writes the page of the web console, or 404 when it's disabled.
+func (server *Server) writeWebUI(client *Client, msg *Message) error {
+	status, ctype, body := "200 OK", "text/html", webUIPage
+	if !core.WebUI {
+		status, ctype, body = "404 Not Found", "text/plain", []byte("not found\n")
+	}
+	var encoding string
+	body, compressed := compressReply(msg.compress, body)
+	if compressed {
+		encoding = "Content-Encoding: " + msg.compress.String() + "\r\n"
+	}
+	_, err := fmt.Fprintf(client, "HTTP/1.1 %s\r\n"+
+		"Connection: close\r\n"+
+		"Content-Length: %d\r\n"+
+		"Content-Type: %s; charset=utf-8\r\n"+
+		"%s\r\n", status, len(body), ctype, encoding)
+	if err != nil {
+		return err
+	}
+	_, err = client.Write(body)
+	return err
+}
+
+// websocketAuth returns the "auth-<password>" protocol of a
+// Sec-WebSocket-Protocol header, and its password, which is base64url
+// encoded without padding. The auth is returned as is when there's none.
+func websocketAuth(header, auth string) (protocol, password string) {
+	for _, protocol := range strings.Split(header, ",") {
+		protocol = strings.TrimSpace(protocol)
+		if !strings.HasPrefix(protocol, "auth-") {
+			continue
+		}
+		data, err := base64.RawURLEncoding.DecodeString(protocol[5:])
+		if err != nil {
+			continue
+		}
+		return protocol, string(data)
+	}
+	return "", auth
+}
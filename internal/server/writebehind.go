@@ -0,0 +1,261 @@
+package server
+
+// Write-behind: a key that has a WRITEBEHIND mirrors its objects to an
+// external store, such as PostGIS, DynamoDB or BigQuery, so that the server
+// can be the hot tier of a larger store. The writes that go to the AOF are
+// queued and sent in batches in the background, see internal/writebehind.
+// Only the leader sends them. The objects that were set before the
+// WRITEBEHIND aren't sent, and the writes that are queued when the server
+// stops are lost.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/resp"
+	"github.com/tidwall/tile38/internal/collection"
+	"github.com/tidwall/tile38/internal/writebehind"
+)
+
+var errWriteBehindNotEnabled = errors.New(
+	"write-behind is not enabled for the key")
+
+// keyWriteBehind is the WRITEBEHIND of a key and its writer.
+type keyWriteBehind struct {
+	url  string
+	opts writebehind.Options
+	w    *writebehind.Writer
+}
+
+// cmdWriteBehind sets the store that the objects of a key are mirrored to,
+// and how the writes are batched and retried. Without a url the
+// write-behind is removed, after the queued writes are sent.
+//
+//	WRITEBEHIND key [url [BATCH count] [INTERVAL seconds] [RETRIES count]]
+func (server *Server) cmdWriteBehind(msg *Message) (res resp.Value, d commandDetails, err error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	if vs, d.key, ok = tokenval(vs); !ok || d.key == "" {
+		err = errInvalidNumberOfArguments
+		return
+	}
+	var url string
+	opts := writebehind.DefaultOptions
+	if len(vs) > 0 {
+		vs, url, _ = tokenval(vs)
+	}
+	for len(vs) > 0 {
+		var arg, sval string
+		vs, arg, _ = tokenval(vs)
+		if vs, sval, ok = tokenval(vs); !ok || sval == "" {
+			err = errInvalidNumberOfArguments
+			return
+		}
+		switch {
+		case lc(arg, "batch"):
+			n, perr := strconv.Atoi(sval)
+			if perr != nil || n <= 0 {
+				err = errInvalidArgument(sval)
+				return
+			}
+			opts.Batch = n
+		case lc(arg, "interval"):
+			secs, perr := strconv.ParseFloat(sval, 64)
+			if perr != nil || secs <= 0 {
+				err = errInvalidArgument(sval)
+				return
+			}
+			opts.Interval = time.Duration(secs * float64(time.Second))
+		case lc(arg, "retries"):
+			n, perr := strconv.Atoi(sval)
+			if perr != nil || n < 0 {
+				err = errInvalidArgument(sval)
+				return
+			}
+			opts.Retries = n
+		default:
+			err = errInvalidArgument(arg)
+			return
+		}
+	}
+	var sink writebehind.Sink
+	if url != "" {
+		if sink, err = writebehind.Open(url); err != nil {
+			err = errInvalidArgument(url)
+			return
+		}
+	}
+	if wb := server.writeBehinds[d.key]; wb != nil {
+		wb.w.Close()
+		delete(server.writeBehinds, d.key)
+	}
+	if sink != nil {
+		server.writeBehinds[d.key] = &keyWriteBehind{url: url, opts: opts,
+			w: writebehind.NewWriter(sink, opts)}
+	}
+	d.command = "writebehind"
+	d.updated = true
+	d.timestamp = time.Now()
+	d.parent = true // nothing for the geofences
+	res = OKMessage(msg, start)
+	return
+}
+
+// writeBehindSet returns the record of an object that was set.
+func writeBehindSet(col *collection.Collection, key, id string,
+	obj geojson.Object, fields []float64, now time.Time,
+) writebehind.Record {
+	rec := writebehind.Record{Op: writebehind.OpSet, Key: key, ID: id,
+		Object: json.RawMessage(obj.JSON()), Time: now}
+	for i, name := range col.FieldArr() {
+		if i < len(fields) && fields[i] != 0 {
+			if rec.Fields == nil {
+				rec.Fields = make(map[string]float64)
+			}
+			rec.Fields[name] = fields[i]
+		}
+	}
+	return rec
+}
+
+// recordWriteBehind queues the objects that were set or deleted for the
+// write-behind of their key.
+func (server *Server) recordWriteBehind(d *commandDetails) {
+	if d.parent {
+		for _, dc := range d.children {
+			server.recordWriteBehind(dc)
+		}
+		return
+	}
+	now := d.timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	wb := server.writeBehinds[d.key]
+	switch d.command {
+	case "set", "fset":
+		if wb == nil {
+			return
+		}
+		if col := server.getCol(d.key); col != nil {
+			if obj, fields, _, ok := col.Get(d.id); ok {
+				wb.w.Add(writeBehindSet(col, d.key, d.id, obj, fields, now))
+			}
+		}
+	case "del":
+		if wb != nil {
+			wb.w.Add(writebehind.Record{Op: writebehind.OpDel, Key: d.key,
+				ID: d.id, Time: now})
+		}
+	case "drop":
+		if wb != nil {
+			wb.w.Add(writebehind.Record{Op: writebehind.OpDrop, Key: d.key,
+				Time: now})
+		}
+	case "rename":
+		if wb != nil {
+			wb.w.Add(writebehind.Record{Op: writebehind.OpDrop, Key: d.key,
+				Time: now})
+		}
+		// the objects are set again for the new key
+		nwb := server.writeBehinds[d.newKey]
+		col := server.getCol(d.newKey)
+		if nwb == nil || col == nil {
+			return
+		}
+		nwb.w.Add(writebehind.Record{Op: writebehind.OpDrop, Key: d.newKey,
+			Time: now})
+		col.Scan(false, nil, nil, func(id string, obj geojson.Object,
+			fields []float64) bool {
+			nwb.w.Add(writeBehindSet(col, d.newKey, id, obj, fields, now))
+			return true
+		})
+	}
+}
+
+// closeWriteBehinds stops the write-behinds of all keys, for FLUSHDB. The
+// objects of the keys are dropped from the stores first.
+func (server *Server) closeWriteBehinds() {
+	leader := server.config.followHost() == ""
+	for key, wb := range server.writeBehinds {
+		if leader {
+			wb.w.Add(writebehind.Record{Op: writebehind.OpDrop, Key: key,
+				Time: time.Now()})
+		}
+		wb.w.Close()
+	}
+	server.writeBehinds = make(map[string]*keyWriteBehind)
+}
+
+// cmdWriteBehindStats returns the counts of the writes of the write-behind
+// of a key: the ones that are queued, the ones that were sent, the ones that
+// failed after the retries, and the ones that were dropped from a full
+// queue, with the last error of the store.
+//
+//	WRITEBEHINDSTATS key
+func (server *Server) cmdWriteBehindStats(msg *Message) (resp.Value, error) {
+	start := time.Now()
+	vs := msg.Args[1:]
+	var ok bool
+	var key string
+	if vs, key, ok = tokenval(vs); !ok || key == "" {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	if len(vs) != 0 {
+		return NOMessage, errInvalidNumberOfArguments
+	}
+	wb := server.writeBehinds[key]
+	if wb == nil {
+		return NOMessage, errWriteBehindNotEnabled
+	}
+	stats := wb.w.Stats()
+	switch msg.OutputType {
+	case JSON:
+		var buf bytes.Buffer
+		buf.WriteString(`{"ok":true,"stats":{"url":` + jsonString(wb.url) +
+			`,"pending":` + strconv.Itoa(stats.Pending) +
+			`,"sent":` + strconv.Itoa(stats.Sent) +
+			`,"failed":` + strconv.Itoa(stats.Failed) +
+			`,"dropped":` + strconv.Itoa(stats.Dropped) +
+			`,"last_error":` + jsonString(stats.LastError) +
+			`},"elapsed":"` + time.Since(start).String() + `"}`)
+		return resp.StringValue(buf.String()), nil
+	case RESP:
+		return resp.ArrayValue([]resp.Value{
+			resp.StringValue("url"), resp.StringValue(wb.url),
+			resp.StringValue("pending"), resp.IntegerValue(stats.Pending),
+			resp.StringValue("sent"), resp.IntegerValue(stats.Sent),
+			resp.StringValue("failed"), resp.IntegerValue(stats.Failed),
+			resp.StringValue("dropped"), resp.IntegerValue(stats.Dropped),
+			resp.StringValue("last_error"), resp.StringValue(stats.LastError),
+		}), nil
+	}
+	return NOMessage, nil
+}
+
+// writeBehindCommands returns the commands that rebuild the WRITEBEHIND of
+// the keys, for aofshrink.
+func (server *Server) writeBehindCommands() [][]string {
+	keys := make([]string, 0, len(server.writeBehinds))
+	for key := range server.writeBehinds {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmds := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		wb := server.writeBehinds[key]
+		cmds = append(cmds, []string{"writebehind", key, wb.url,
+			"BATCH", strconv.Itoa(wb.opts.Batch),
+			"INTERVAL", strconv.FormatFloat(wb.opts.Interval.Seconds(),
+				'f', -1, 64),
+			"RETRIES", strconv.Itoa(wb.opts.Retries),
+		})
+	}
+	return cmds
+}
@@ -0,0 +1,234 @@
+// Package simplify reduces the size of GeoJSON output by simplifying
+// geometries and limiting the precision of coordinates.
+package simplify
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/tidwall/gjson"
+)
+
+// Options for reshaping GeoJSON.
+type Options struct {
+	// Precision is the number of decimal digits to keep for each coordinate.
+	// A negative value keeps the full precision.
+	Precision int
+	// Tolerance is the Douglas-Peucker tolerance, in the same units as the
+	// coordinates. Zero disables simplification.
+	Tolerance float64
+}
+
+// Enabled returns true when the options will change the GeoJSON.
+func (opts Options) Enabled() bool {
+	return opts.Precision >= 0 || opts.Tolerance > 0
+}
+
+// Round rounds the value to the number of decimal digits. A negative digits
+// leaves the value unchanged.
+func Round(v float64, digits int) float64 {
+	if digits < 0 {
+		return v
+	}
+	pow := math.Pow(10, float64(digits))
+	return math.Round(v*pow) / pow
+}
+
+// AppendJSON appends the reshaped version of the GeoJSON to dst. Only the
+// "coordinates" and "bbox" members are modified, all other members are
+// copied as-is.
+func AppendJSON(dst []byte, json []byte, opts Options) []byte {
+	if !opts.Enabled() {
+		return append(dst, json...)
+	}
+	return appendObject(dst, gjson.ParseBytes(json), opts)
+}
+
+func appendObject(dst []byte, obj gjson.Result, opts Options) []byte {
+	switch {
+	case obj.IsArray():
+		dst = append(dst, '[')
+		var i int
+		obj.ForEach(func(_, value gjson.Result) bool {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendObject(dst, value, opts)
+			i++
+			return true
+		})
+		return append(dst, ']')
+	case !obj.IsObject():
+		return append(dst, obj.Raw...)
+	}
+	typ := obj.Get("type").String()
+	dst = append(dst, '{')
+	var i int
+	obj.ForEach(func(key, value gjson.Result) bool {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, key.Raw...)
+		dst = append(dst, ':')
+		switch key.String() {
+		case "coordinates":
+			dst = appendCoordinates(dst, typ, value, opts)
+		case "bbox":
+			dst = appendPosition(dst, parsePosition(value), opts)
+		case "geometry", "geometries", "features":
+			dst = appendObject(dst, value, opts)
+		default:
+			dst = append(dst, value.Raw...)
+		}
+		i++
+		return true
+	})
+	return append(dst, '}')
+}
+
+func appendCoordinates(dst []byte, typ string, coords gjson.Result,
+	opts Options,
+) []byte {
+	switch typ {
+	case "Point":
+		return appendPosition(dst, parsePosition(coords), opts)
+	case "MultiPoint":
+		return appendSeries(dst, parseSeries(coords), opts)
+	case "LineString":
+		series := parseSeries(coords)
+		series = simplifySeries(series, 2, opts.Tolerance)
+		return appendSeries(dst, series, opts)
+	case "MultiLineString", "Polygon":
+		minPoints := 2
+		if typ == "Polygon" {
+			minPoints = 4
+		}
+		dst = append(dst, '[')
+		var i int
+		coords.ForEach(func(_, value gjson.Result) bool {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			series := parseSeries(value)
+			series = simplifySeries(series, minPoints, opts.Tolerance)
+			dst = appendSeries(dst, series, opts)
+			i++
+			return true
+		})
+		return append(dst, ']')
+	case "MultiPolygon":
+		dst = append(dst, '[')
+		var i int
+		coords.ForEach(func(_, value gjson.Result) bool {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendCoordinates(dst, "Polygon", value, opts)
+			i++
+			return true
+		})
+		return append(dst, ']')
+	}
+	return append(dst, coords.Raw...)
+}
+
+func parsePosition(value gjson.Result) []float64 {
+	var pos []float64
+	value.ForEach(func(_, value gjson.Result) bool {
+		pos = append(pos, value.Float())
+		return true
+	})
+	return pos
+}
+
+func parseSeries(value gjson.Result) [][]float64 {
+	var series [][]float64
+	value.ForEach(func(_, value gjson.Result) bool {
+		series = append(series, parsePosition(value))
+		return true
+	})
+	return series
+}
+
+func appendPosition(dst []byte, pos []float64, opts Options) []byte {
+	dst = append(dst, '[')
+	for i, v := range pos {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = strconv.AppendFloat(dst, Round(v, opts.Precision), 'f', -1, 64)
+	}
+	return append(dst, ']')
+}
+
+func appendSeries(dst []byte, series [][]float64, opts Options) []byte {
+	dst = append(dst, '[')
+	for i, pos := range series {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendPosition(dst, pos, opts)
+	}
+	return append(dst, ']')
+}
+
+// simplifySeries simplifies the series using the Douglas-Peucker algorithm.
+// The original series is returned if the result has fewer than minPoints.
+func simplifySeries(series [][]float64, minPoints int, tolerance float64,
+) [][]float64 {
+	if tolerance <= 0 || len(series) <= minPoints {
+		return series
+	}
+	for _, pos := range series {
+		if len(pos) < 2 {
+			return series
+		}
+	}
+	keep := make([]bool, len(series))
+	keep[0] = true
+	keep[len(series)-1] = true
+	stack := [][2]int{{0, len(series) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+		var maxDist float64
+		index := -1
+		for i := first + 1; i < last; i++ {
+			dist := segmentDistance(series[i], series[first], series[last])
+			if dist > maxDist {
+				maxDist = dist
+				index = i
+			}
+		}
+		if index != -1 && maxDist > tolerance {
+			keep[index] = true
+			stack = append(stack, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+	var simplified [][]float64
+	for i, pos := range series {
+		if keep[i] {
+			simplified = append(simplified, pos)
+		}
+	}
+	if len(simplified) < minPoints {
+		return series
+	}
+	return simplified
+}
+
+// segmentDistance returns the planar distance from p to the segment a-b.
+func segmentDistance(p, a, b []float64) float64 {
+	x, y := a[0], a[1]
+	dx, dy := b[0]-x, b[1]-y
+	if dx != 0 || dy != 0 {
+		t := ((p[0]-x)*dx + (p[1]-y)*dy) / (dx*dx + dy*dy)
+		if t > 1 {
+			x, y = b[0], b[1]
+		} else if t > 0 {
+			x += dx * t
+			y += dy * t
+		}
+	}
+	return math.Hypot(p[0]-x, p[1]-y)
+}
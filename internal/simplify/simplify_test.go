@@ -0,0 +1,44 @@
+package simplify
+
+import "testing"
+
+func TestPrecision(t *testing.T) {
+	json := `{"type":"Point","coordinates":[-112.123456789,33.987654321,12.5]}`
+	out := string(AppendJSON(nil, []byte(json), Options{Precision: 3}))
+	expect := `{"type":"Point","coordinates":[-112.123,33.988,12.5]}`
+	if out != expect {
+		t.Fatalf("expected '%s', got '%s'", expect, out)
+	}
+}
+
+func TestSimplifyLineString(t *testing.T) {
+	json := `{"type":"LineString","coordinates":[[0,0],[1,0.01],[2,-0.01],[3,0],[3,5]]}`
+	out := string(AppendJSON(nil, []byte(json), Options{
+		Precision: -1, Tolerance: 0.1,
+	}))
+	expect := `{"type":"LineString","coordinates":[[0,0],[3,0],[3,5]]}`
+	if out != expect {
+		t.Fatalf("expected '%s', got '%s'", expect, out)
+	}
+}
+
+func TestSimplifyPolygonKeepsRing(t *testing.T) {
+	json := `{"type":"Polygon","coordinates":[[[0,0],[0.01,0.01],[0,0.02],[0,0]]]}`
+	out := string(AppendJSON(nil, []byte(json), Options{
+		Precision: -1, Tolerance: 1,
+	}))
+	if out != json {
+		t.Fatalf("expected '%s', got '%s'", json, out)
+	}
+}
+
+func TestSimplifyFeature(t *testing.T) {
+	json := `{"type":"Feature","geometry":{"type":"MultiLineString","coordinates":[[[0,0],[1,0.001],[2,0]]]},"properties":{"coordinates":[1.23456]}}`
+	out := string(AppendJSON(nil, []byte(json), Options{
+		Precision: 1, Tolerance: 0.1,
+	}))
+	expect := `{"type":"Feature","geometry":{"type":"MultiLineString","coordinates":[[[0,0],[2,0]]]},"properties":{"coordinates":[1.23456]}}`
+	if out != expect {
+		t.Fatalf("expected '%s', got '%s'", expect, out)
+	}
+}
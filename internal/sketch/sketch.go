@@ -0,0 +1,128 @@
+// Package sketch finds the most frequent names of a stream in a fixed amount
+// of memory.
+//
+// The names are counted by a count-min sketch, which never undercounts and
+// overcounts by a small fraction of the total. The names with the largest
+// counts are kept with their counts.
+package sketch
+
+import "sort"
+
+const (
+	width = 2048
+	depth = 4
+)
+
+// Item is a name and its count.
+type Item struct {
+	Name  string
+	Count uint32
+}
+
+// TopK counts names, and keeps the k names with the largest counts. The
+// zero value isn't ready for use, use New. A TopK is not safe for concurrent
+// use.
+type TopK struct {
+	k      int
+	counts [depth][width]uint32
+	top    map[string]uint32
+	min    string // name with the smallest count of the top
+}
+
+// New returns a TopK that keeps k names.
+func New(k int) *TopK {
+	return &TopK{k: k, top: make(map[string]uint32, k)}
+}
+
+// hash returns the FNV-1a hash of a name.
+func hash(name string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(name); i++ {
+		h ^= uint64(name[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// Add counts a name, and returns its count.
+func (t *TopK) Add(name string) uint32 {
+	h := hash(name)
+	h1, h2 := uint32(h), uint32(h>>32)
+	count := ^uint32(0)
+	for i := 0; i < depth; i++ {
+		c := &t.counts[i][(h1+uint32(i)*h2)%width]
+		if *c < ^uint32(0) {
+			*c++
+		}
+		if *c < count {
+			count = *c
+		}
+	}
+	if _, ok := t.top[name]; ok {
+		t.top[name] = count
+		if name == t.min {
+			t.findMin()
+		}
+		return count
+	}
+	if len(t.top) < t.k {
+		t.top[name] = count
+		if len(t.top) == 1 || count < t.top[t.min] {
+			t.min = name
+		}
+		return count
+	}
+	if count > t.top[t.min] {
+		delete(t.top, t.min)
+		t.top[name] = count
+		t.findMin()
+	}
+	return count
+}
+
+func (t *TopK) findMin() {
+	var first = true
+	for name, count := range t.top {
+		if first || count < t.top[t.min] {
+			t.min, first = name, false
+		}
+	}
+}
+
+// Top returns the names with the largest counts, the largest first.
+func (t *TopK) Top() []Item {
+	items := make([]Item, 0, len(t.top))
+	for name, count := range t.top {
+		items = append(items, Item{Name: name, Count: count})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// Decay halves the counts, so that the counts favor the recent names. The
+// names that drop to zero are removed from the top.
+func (t *TopK) Decay() {
+	for i := range t.counts {
+		for j := range t.counts[i] {
+			t.counts[i][j] >>= 1
+		}
+	}
+	for name, count := range t.top {
+		if count>>1 == 0 {
+			delete(t.top, name)
+		} else {
+			t.top[name] = count >> 1
+		}
+	}
+	t.findMin()
+}
+
+// Reset removes all of the counts.
+func (t *TopK) Reset() {
+	*t = *New(t.k)
+}
@@ -0,0 +1,64 @@
+package sketch
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	rand.Seed(1)
+	tk := New(5)
+	// five hot names among many cold ones
+	for i := 0; i < 100000; i++ {
+		if i%2 == 0 {
+			tk.Add(fmt.Sprintf("hot%d", rand.Intn(5)))
+		} else {
+			tk.Add(fmt.Sprintf("cold%d", rand.Intn(10000)))
+		}
+	}
+	top := tk.Top()
+	if len(top) != 5 {
+		t.Fatalf("expected 5 items, got %d", len(top))
+	}
+	for _, item := range top {
+		if item.Name[:3] != "hot" {
+			t.Fatalf("unexpected %v", top)
+		}
+		// about 10000 each, and never less than the real count
+		if item.Count < 9000 || item.Count > 11500 {
+			t.Fatalf("unexpected count %v", item)
+		}
+	}
+	for i := 1; i < len(top); i++ {
+		if top[i].Count > top[i-1].Count {
+			t.Fatalf("not sorted %v", top)
+		}
+	}
+}
+
+func TestTopKDecay(t *testing.T) {
+	tk := New(2)
+	for i := 0; i < 4; i++ {
+		tk.Add("a")
+	}
+	tk.Add("b")
+	tk.Decay()
+	top := tk.Top()
+	if len(top) != 1 || top[0].Name != "a" || top[0].Count != 2 {
+		t.Fatalf("unexpected %v", top)
+	}
+	// a new name replaces the smallest count of a full top
+	tk.Add("c")
+	tk.Add("d")
+	tk.Add("d")
+	tk.Add("d")
+	top = tk.Top()
+	if len(top) != 2 || top[0].Name != "d" || top[1].Name != "a" {
+		t.Fatalf("unexpected %v", top)
+	}
+	tk.Reset()
+	if len(tk.Top()) != 0 || tk.Add("a") != 1 {
+		t.Fatalf("expected an empty top")
+	}
+}
@@ -0,0 +1,188 @@
+// Package trajectory stores the timestamped positions of moving objects.
+package trajectory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+)
+
+// Position is a point at a moment in time.
+type Position struct {
+	Time  int64 // unix nanoseconds
+	Point geometry.Point
+	Z     float64
+}
+
+// Options for retention and downsampling of trajectories.
+type Options struct {
+	// Retention removes positions that are older than the latest position
+	// by more than the duration. Zero keeps all positions.
+	Retention time.Duration
+	// Interval is the minimum time between two stored positions. Positions
+	// that arrive sooner are dropped. Zero keeps all positions.
+	Interval time.Duration
+	// MaxPoints is the maximum number of positions in a trajectory. The
+	// oldest positions are removed first. Zero means no limit.
+	MaxPoints int
+}
+
+// Trajectory is a list of positions ordered by time.
+type Trajectory struct {
+	positions []Position
+}
+
+// Len returns the number of positions.
+func (t *Trajectory) Len() int {
+	return len(t.positions)
+}
+
+// Append adds a position to the trajectory. Positions may arrive out of
+// order. Returns false when the position was dropped due to downsampling.
+func (t *Trajectory) Append(pos Position, opts Options) bool {
+	i := sort.Search(len(t.positions), func(i int) bool {
+		return t.positions[i].Time > pos.Time
+	})
+	if opts.Interval > 0 {
+		if i > 0 && pos.Time-t.positions[i-1].Time < int64(opts.Interval) {
+			return false
+		}
+		if i < len(t.positions) &&
+			t.positions[i].Time-pos.Time < int64(opts.Interval) {
+			return false
+		}
+	}
+	if i > 0 && t.positions[i-1].Time == pos.Time {
+		// same moment, replace the position
+		t.positions[i-1] = pos
+		return true
+	}
+	t.positions = append(t.positions, Position{})
+	copy(t.positions[i+1:], t.positions[i:])
+	t.positions[i] = pos
+	t.trim(opts)
+	return true
+}
+
+// trim removes the positions that are outside of the retention.
+func (t *Trajectory) trim(opts Options) {
+	var n int
+	if opts.Retention > 0 && len(t.positions) > 0 {
+		min := t.positions[len(t.positions)-1].Time - int64(opts.Retention)
+		n = sort.Search(len(t.positions), func(i int) bool {
+			return t.positions[i].Time >= min
+		})
+	}
+	if opts.MaxPoints > 0 && len(t.positions)-n > opts.MaxPoints {
+		n = len(t.positions) - opts.MaxPoints
+	}
+	if n > 0 {
+		t.positions = append(t.positions[:0], t.positions[n:]...)
+	}
+}
+
+// Range iterates over the positions between start and end, inclusive.
+func (t *Trajectory) Range(start, end int64, iter func(pos Position) bool,
+) bool {
+	i := sort.Search(len(t.positions), func(i int) bool {
+		return t.positions[i].Time >= start
+	})
+	for ; i < len(t.positions) && t.positions[i].Time <= end; i++ {
+		if !iter(t.positions[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Passed returns true if the trajectory passed through the area between
+// start and end. The path between two positions is a straight line, so an
+// object that crossed the area in-between two reports is also detected.
+func (t *Trajectory) Passed(area geojson.Object, start, end int64) bool {
+	var prev Position
+	var count int
+	var passed bool
+	t.Range(start, end, func(pos Position) bool {
+		if count == 0 || prev.Point == pos.Point {
+			passed = area.Intersects(geojson.NewPoint(pos.Point))
+		} else {
+			passed = area.Intersects(geojson.NewLineString(geometry.NewLine(
+				[]geometry.Point{prev.Point, pos.Point}, nil)))
+		}
+		prev = pos
+		count++
+		return !passed
+	})
+	return passed
+}
+
+// Store holds the trajectories for many ids.
+type Store struct {
+	opts  Options
+	trajs map[string]*Trajectory
+}
+
+// NewStore returns a new store
+func NewStore(opts Options) *Store {
+	return &Store{opts: opts, trajs: make(map[string]*Trajectory)}
+}
+
+// Options returns the options of the store.
+func (s *Store) Options() Options {
+	return s.opts
+}
+
+// SetOptions changes the options, and trims all trajectories.
+func (s *Store) SetOptions(opts Options) {
+	s.opts = opts
+	for _, t := range s.trajs {
+		t.trim(opts)
+	}
+}
+
+// Len returns the number of trajectories.
+func (s *Store) Len() int {
+	return len(s.trajs)
+}
+
+// Append adds a position to the trajectory of an id. Returns false when the
+// position was dropped due to downsampling.
+func (s *Store) Append(id string, pos Position) bool {
+	t := s.trajs[id]
+	if t == nil {
+		t = new(Trajectory)
+		s.trajs[id] = t
+	}
+	return t.Append(pos, s.opts)
+}
+
+// Get returns the trajectory for an id, or nil when not found.
+func (s *Store) Get(id string) *Trajectory {
+	return s.trajs[id]
+}
+
+// Delete removes the trajectory of an id.
+func (s *Store) Delete(id string) bool {
+	if _, ok := s.trajs[id]; !ok {
+		return false
+	}
+	delete(s.trajs, id)
+	return true
+}
+
+// Scan iterates over all trajectories, ordered by id.
+func (s *Store) Scan(iter func(id string, t *Trajectory) bool) bool {
+	ids := make([]string, 0, len(s.trajs))
+	for id := range s.trajs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if !iter(id, s.trajs[id]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package trajectory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+)
+
+func pos(sec int64, x, y float64) Position {
+	return Position{Time: sec * int64(time.Second), Point: geometry.Point{X: x, Y: y}}
+}
+
+func times(t *Trajectory) []int64 {
+	var res []int64
+	t.Range(0, 1<<62, func(pos Position) bool {
+		res = append(res, pos.Time/int64(time.Second))
+		return true
+	})
+	return res
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAppend(t *testing.T) {
+	var tr Trajectory
+	for _, sec := range []int64{3, 1, 2, 5, 4} {
+		if !tr.Append(pos(sec, 0, 0), Options{}) {
+			t.Fatalf("expected append")
+		}
+	}
+	if got := times(&tr); !equal(got, []int64{1, 2, 3, 4, 5}) {
+		t.Fatalf("expected %v, got %v", []int64{1, 2, 3, 4, 5}, got)
+	}
+	tr.Append(pos(3, 1, 1), Options{})
+	if tr.Len() != 5 {
+		t.Fatalf("expected 5, got %d", tr.Len())
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var tr Trajectory
+	opts := Options{Interval: time.Second * 2}
+	for sec := int64(0); sec < 10; sec++ {
+		tr.Append(pos(sec, 0, 0), opts)
+	}
+	if got := times(&tr); !equal(got, []int64{0, 2, 4, 6, 8}) {
+		t.Fatalf("expected %v, got %v", []int64{0, 2, 4, 6, 8}, got)
+	}
+	tr.trim(Options{Retention: time.Second * 4})
+	if got := times(&tr); !equal(got, []int64{4, 6, 8}) {
+		t.Fatalf("expected %v, got %v", []int64{4, 6, 8}, got)
+	}
+	tr.trim(Options{MaxPoints: 1})
+	if got := times(&tr); !equal(got, []int64{8}) {
+		t.Fatalf("expected %v, got %v", []int64{8}, got)
+	}
+}
+
+func TestPassed(t *testing.T) {
+	var tr Trajectory
+	tr.Append(pos(1, -10, 0), Options{})
+	tr.Append(pos(2, 10, 0), Options{})
+	tr.Append(pos(3, 10, 10), Options{})
+	area := geojson.NewRect(geometry.Rect{
+		Min: geometry.Point{X: -1, Y: -1},
+		Max: geometry.Point{X: 1, Y: 1},
+	})
+	sec := int64(time.Second)
+	if !tr.Passed(area, 0, 3*sec) {
+		t.Fatal("expected passed")
+	}
+	if tr.Passed(area, 2*sec, 3*sec) {
+		t.Fatal("expected not passed")
+	}
+	if tr.Passed(area, 1*sec, 1*sec) {
+		t.Fatal("expected not passed")
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore(Options{MaxPoints: 2})
+	s.Append("b", pos(1, 0, 0))
+	s.Append("a", pos(1, 0, 0))
+	s.Append("a", pos(2, 0, 0))
+	s.Append("a", pos(3, 0, 0))
+	if s.Len() != 2 || s.Get("a").Len() != 2 {
+		t.Fatal("invalid store")
+	}
+	var ids []string
+	s.Scan(func(id string, t *Trajectory) bool {
+		ids = append(ids, id)
+		return true
+	})
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Fatalf("expected [a b], got %v", ids)
+	}
+	if !s.Delete("a") || s.Delete("a") || s.Get("a") != nil {
+		t.Fatal("invalid delete")
+	}
+}
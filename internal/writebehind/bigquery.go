@@ -0,0 +1,148 @@
+package writebehind
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const bigqueryScope = "https://www.googleapis.com/auth/bigquery.insertdata"
+
+// bigquerySink streams the records into a BigQuery table, which keeps all
+// of the changes, as the rows of a streaming insert can't be updated. The
+// table has the op, key, id, object and fields string columns, with the
+// GeoJSON in object, which can be a GEOGRAPHY column, and the fields as
+// JSON, and a time timestamp column.
+type bigquerySink struct {
+	url      string // of insertAll
+	credPath string
+	auth     bool // with the Google credentials
+
+	mu     sync.Mutex
+	client *http.Client
+}
+
+func newBigQuerySink(u *url.URL) (*bigquerySink, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.New("invalid bigquery url")
+	}
+	q := u.Query()
+	endpoint := q.Get("endpoint")
+	s := &bigquerySink{credPath: q.Get("credpath"), auth: endpoint == ""}
+	if endpoint == "" {
+		endpoint = "https://bigquery.googleapis.com"
+	}
+	s.url = strings.TrimSuffix(endpoint, "/") + "/bigquery/v2/projects/" +
+		url.PathEscape(u.Host) + "/datasets/" + url.PathEscape(parts[0]) +
+		"/tables/" + url.PathEscape(parts[1]) + "/insertAll"
+	return s, nil
+}
+
+// getClient returns the client, which has the credentials of the credpath,
+// or the default credentials. They're found on the first write, so that
+// a sink can be opened without them.
+func (s *bigquerySink) getClient() (*http.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	if !s.auth {
+		s.client = http.DefaultClient
+		return s.client, nil
+	}
+	// the client outlives the context of the write
+	bg := context.Background()
+	if s.credPath != "" {
+		data, err := ioutil.ReadFile(s.credPath)
+		if err != nil {
+			return nil, err
+		}
+		creds, err := google.CredentialsFromJSON(bg, data, bigqueryScope)
+		if err != nil {
+			return nil, err
+		}
+		s.client = oauth2.NewClient(bg, creds.TokenSource)
+	} else {
+		client, err := google.DefaultClient(bg, bigqueryScope)
+		if err != nil {
+			return nil, err
+		}
+		s.client = client
+	}
+	return s.client, nil
+}
+
+// bigqueryRow is a row of a streaming insert.
+type bigqueryRow struct {
+	InsertID string            `json:"insertId"`
+	JSON     map[string]string `json:"json"`
+}
+
+// Write inserts a row for each record. The insert ids, which are hashes of
+// the records, let BigQuery leave out the rows of a write that's retried.
+func (s *bigquerySink) Write(ctx context.Context, recs []Record) error {
+	client, err := s.getClient()
+	if err != nil {
+		return err
+	}
+	rows := make([]bigqueryRow, len(recs))
+	for i, rec := range recs {
+		ts := rec.Time.UTC().Format("2006-01-02 15:04:05.999999")
+		row := map[string]string{"op": rec.Op, "key": rec.Key, "time": ts}
+		if rec.ID != "" {
+			row["id"] = rec.ID
+		}
+		if rec.Op == OpSet {
+			row["object"] = string(rec.Object)
+			fields, err := json.Marshal(rec.Fields)
+			if err != nil {
+				return err
+			}
+			row["fields"] = string(fields)
+		}
+		id := sha1.Sum([]byte(fmt.Sprintf("%s:%s:%s:%d", rec.Op, rec.Key,
+			rec.ID, rec.Time.UnixNano())))
+		rows[i] = bigqueryRow{InsertID: hex.EncodeToString(id[:]), JSON: row}
+	}
+	body, err := json.Marshal(map[string]interface{}{"rows": rows})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url,
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var res struct {
+		InsertErrors []struct {
+			Index  int
+			Errors []struct{ Message string }
+		}
+	}
+	if err := send(client, req, &res); err != nil {
+		return err
+	}
+	if len(res.InsertErrors) > 0 {
+		msg := "bigquery: row not inserted"
+		if e := res.InsertErrors[0]; len(e.Errors) > 0 {
+			msg += ": " + e.Errors[0].Message
+		}
+		return errors.New(msg)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package writebehind
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// dynamoBatch is the most requests of a BatchWriteItem.
+const dynamoBatch = 25
+
+// dynamoUnprocessed is the number of times that the unprocessed requests of
+// a BatchWriteItem are sent again.
+const dynamoUnprocessed = 5
+
+// dynamoSink writes the records to a DynamoDB table, which has a partition
+// key of the key and a sort key of the id, both strings. The items have the
+// GeoJSON in object, the fields in a map, and the time.
+type dynamoSink struct {
+	table    string
+	region   string
+	endpoint string
+	signer   *v4.Signer
+}
+
+func newDynamoDBSink(u *url.URL) (*dynamoSink, error) {
+	table := strings.Trim(u.Path, "/")
+	if u.Host == "" || table == "" || strings.Contains(table, "/") {
+		return nil, errors.New("invalid dynamodb url")
+	}
+	q := u.Query()
+	s := &dynamoSink{
+		table:    table,
+		region:   u.Host,
+		endpoint: q.Get("endpoint"),
+	}
+	if s.endpoint == "" {
+		s.endpoint = "https://dynamodb." + s.region + ".amazonaws.com"
+	}
+	var creds *credentials.Credentials
+	if credPath := q.Get("credpath"); credPath != "" {
+		credProfile := q.Get("credprofile")
+		if credProfile == "" {
+			credProfile = "default"
+		}
+		creds = credentials.NewSharedCredentials(credPath, credProfile)
+	} else {
+		sess, err := session.NewSession(&aws.Config{Region: &s.region})
+		if err != nil {
+			return nil, err
+		}
+		creds = sess.Config.Credentials
+	}
+	s.signer = v4.NewSigner(creds)
+	return s, nil
+}
+
+// dynamoValue is an attribute value.
+type dynamoValue struct {
+	S *string                `json:"S,omitempty"`
+	N *string                `json:"N,omitempty"`
+	M map[string]dynamoValue `json:"M,omitempty"`
+}
+
+func dynamoString(s string) dynamoValue {
+	return dynamoValue{S: &s}
+}
+
+// dynamoRequest is a request of a BatchWriteItem.
+type dynamoRequest struct {
+	PutRequest *struct {
+		Item map[string]dynamoValue
+	} `json:",omitempty"`
+	DeleteRequest *struct {
+		Key map[string]dynamoValue
+	} `json:",omitempty"`
+}
+
+func dynamoPut(rec Record) dynamoRequest {
+	item := map[string]dynamoValue{
+		"key":    dynamoString(rec.Key),
+		"id":     dynamoString(rec.ID),
+		"object": dynamoString(string(rec.Object)),
+		"time":   dynamoString(rec.Time.UTC().Format(time.RFC3339Nano)),
+	}
+	if len(rec.Fields) > 0 {
+		fields := make(map[string]dynamoValue, len(rec.Fields))
+		for name, value := range rec.Fields {
+			n := strconv.FormatFloat(value, 'f', -1, 64)
+			fields[name] = dynamoValue{N: &n}
+		}
+		item["fields"] = dynamoValue{M: fields}
+	}
+	var req dynamoRequest
+	req.PutRequest = &struct{ Item map[string]dynamoValue }{item}
+	return req
+}
+
+func dynamoDelete(key, id string) dynamoRequest {
+	var req dynamoRequest
+	req.DeleteRequest = &struct{ Key map[string]dynamoValue }{
+		map[string]dynamoValue{
+			"key": dynamoString(key),
+			"id":  dynamoString(id),
+		},
+	}
+	return req
+}
+
+// Write sends the records with BatchWriteItem, which can't have an item
+// twice, so a batch is sent before an item is in it again. A drop deletes
+// the items of the key one by one, as there's no way to delete them all.
+func (s *dynamoSink) Write(ctx context.Context, recs []Record) error {
+	var batch []dynamoRequest
+	inBatch := make(map[[2]string]bool)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.batchWrite(ctx, batch)
+		batch = batch[:0]
+		inBatch = make(map[[2]string]bool)
+		return err
+	}
+	for _, rec := range recs {
+		if rec.Op == OpDrop {
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := s.drop(ctx, rec.Key); err != nil {
+				return err
+			}
+			continue
+		}
+		item := [2]string{rec.Key, rec.ID}
+		if inBatch[item] || len(batch) == dynamoBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		inBatch[item] = true
+		if rec.Op == OpSet {
+			batch = append(batch, dynamoPut(rec))
+		} else {
+			batch = append(batch, dynamoDelete(rec.Key, rec.ID))
+		}
+	}
+	return flush()
+}
+
+func (s *dynamoSink) batchWrite(ctx context.Context, batch []dynamoRequest,
+) error {
+	for i := 0; ; i++ {
+		var res struct {
+			UnprocessedItems map[string][]dynamoRequest
+		}
+		err := s.call(ctx, "BatchWriteItem", map[string]interface{}{
+			"RequestItems": map[string][]dynamoRequest{s.table: batch},
+		}, &res)
+		if err != nil {
+			return err
+		}
+		batch = res.UnprocessedItems[s.table]
+		if len(batch) == 0 {
+			return nil
+		}
+		if i == dynamoUnprocessed {
+			return errors.New("dynamodb: unprocessed items")
+		}
+		time.Sleep(retryDelay << uint(i))
+	}
+}
+
+// drop deletes the items of a key.
+func (s *dynamoSink) drop(ctx context.Context, key string) error {
+	var start map[string]dynamoValue
+	for {
+		var res struct {
+			Items            []map[string]dynamoValue
+			LastEvaluatedKey map[string]dynamoValue
+		}
+		query := map[string]interface{}{
+			"TableName":                 s.table,
+			"KeyConditionExpression":    "#k = :k",
+			"ProjectionExpression":      "#i",
+			"ExpressionAttributeNames":  map[string]string{"#k": "key", "#i": "id"},
+			"ExpressionAttributeValues": map[string]dynamoValue{":k": dynamoString(key)},
+		}
+		if start != nil {
+			query["ExclusiveStartKey"] = start
+		}
+		if err := s.call(ctx, "Query", query, &res); err != nil {
+			return err
+		}
+		for len(res.Items) > 0 {
+			n := len(res.Items)
+			if n > dynamoBatch {
+				n = dynamoBatch
+			}
+			batch := make([]dynamoRequest, 0, n)
+			for _, item := range res.Items[:n] {
+				if id := item["id"].S; id != nil {
+					batch = append(batch, dynamoDelete(key, *id))
+				}
+			}
+			if err := s.batchWrite(ctx, batch); err != nil {
+				return err
+			}
+			res.Items = res.Items[n:]
+		}
+		if len(res.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		start = res.LastEvaluatedKey
+	}
+}
+
+// call sends a signed request of an action of the DynamoDB API.
+func (s *dynamoSink) call(ctx context.Context, action string,
+	input, output interface{},
+) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	rd := bytes.NewReader(body)
+	req, err := http.NewRequestWithContext(ctx, "POST", s.endpoint, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+action)
+	_, err = s.signer.Sign(req, rd, "dynamodb", s.region, time.Now())
+	if err != nil {
+		return err
+	}
+	return send(http.DefaultClient, req, output)
+}
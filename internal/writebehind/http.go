@@ -0,0 +1,68 @@
+package writebehind
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// httpSink posts the records as a JSON array to a service that stores them.
+type httpSink struct {
+	url string
+}
+
+func newHTTPSink(url string) *httpSink {
+	return &httpSink{url: url}
+}
+
+func (s *httpSink) Write(ctx context.Context, recs []Record) error {
+	body, err := json.Marshal(recs)
+	if err != nil {
+		return err
+	}
+	return do(ctx, "POST", s.url, body, map[string]string{
+		"Content-Type": "application/json",
+	}, nil)
+}
+
+// do sends a request, and reads the JSON of the response into res, if any.
+// A response that isn't 2xx is an error.
+func do(ctx context.Context, method, url string, body []byte,
+	header map[string]string, res interface{},
+) error {
+	req, err := http.NewRequestWithContext(ctx, method, url,
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return send(http.DefaultClient, req, res)
+}
+
+// send sends a request with a client, and reads the JSON of the response
+// into res, if any.
+func send(client *http.Client, req *http.Request, res interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: %s %s", req.URL.Host, resp.Status,
+			bytes.TrimSpace(data))
+	}
+	if res == nil {
+		return nil
+	}
+	return json.Unmarshal(data, res)
+}
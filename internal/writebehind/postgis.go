@@ -0,0 +1,115 @@
+package writebehind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// postgisSink writes the records to a PostGIS table through a PostgREST
+// server. The table has a primary key of the key and id text columns, an
+// object jsonb column with the GeoJSON, a fields jsonb column and a time
+// timestamptz column. A geometry column can be generated from the GeoJSON
+// with ST_GeomFromGeoJSON.
+type postgisSink struct {
+	url string // of the table
+}
+
+func newPostGISSink(u *url.URL) (*postgisSink, error) {
+	table := strings.Trim(u.Path, "/")
+	if u.Host == "" || table == "" || strings.Contains(table, "/") {
+		return nil, errors.New("invalid postgis url")
+	}
+	scheme := "http"
+	if u.Query().Get("ssl") == "true" {
+		scheme = "https"
+	}
+	return &postgisSink{
+		url: scheme + "://" + u.Host + "/" + url.PathEscape(table),
+	}, nil
+}
+
+// postgisRow is a row of the table.
+type postgisRow struct {
+	Key    string             `json:"key"`
+	ID     string             `json:"id"`
+	Object json.RawMessage    `json:"object"`
+	Fields map[string]float64 `json:"fields"`
+	Time   string             `json:"time"`
+}
+
+// Write upserts the objects that were set and deletes the others. The
+// records are written in runs of the same operation, in order. An upsert
+// can't have a row twice, so only the last record of an object in a run is
+// kept.
+func (s *postgisSink) Write(ctx context.Context, recs []Record) error {
+	for len(recs) > 0 {
+		n := 1
+		for n < len(recs) && recs[n].Op == recs[0].Op &&
+			(recs[0].Op != OpDrop && recs[n].Key == recs[0].Key) {
+			n++
+		}
+		var err error
+		switch recs[0].Op {
+		case OpSet:
+			err = s.upsert(ctx, recs[:n])
+		case OpDel:
+			err = s.delete(ctx, recs[:n])
+		case OpDrop:
+			err = do(ctx, "DELETE",
+				s.url+"?key=eq."+url.QueryEscape(recs[0].Key), nil, nil, nil)
+		}
+		if err != nil {
+			return err
+		}
+		recs = recs[n:]
+	}
+	return nil
+}
+
+func (s *postgisSink) upsert(ctx context.Context, recs []Record) error {
+	idx := make(map[string]int)
+	rows := make([]postgisRow, 0, len(recs))
+	for _, rec := range recs {
+		row := postgisRow{
+			Key:    rec.Key,
+			ID:     rec.ID,
+			Object: rec.Object,
+			Fields: rec.Fields,
+			Time:   rec.Time.UTC().Format("2006-01-02T15:04:05.999999Z"),
+		}
+		if i, ok := idx[rec.ID]; ok {
+			rows[i] = row
+		} else {
+			idx[rec.ID] = len(rows)
+			rows = append(rows, row)
+		}
+	}
+	body, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	return do(ctx, "POST", s.url+"?on_conflict=key,id", body,
+		map[string]string{
+			"Content-Type": "application/json",
+			"Prefer":       "resolution=merge-duplicates",
+		}, nil)
+}
+
+func (s *postgisSink) delete(ctx context.Context, recs []Record) error {
+	ids := make([]string, len(recs))
+	for i, rec := range recs {
+		ids[i] = pgQuote(rec.ID)
+	}
+	return do(ctx, "DELETE", s.url+"?key=eq."+url.QueryEscape(recs[0].Key)+
+		"&id=in."+url.QueryEscape("("+strings.Join(ids, ",")+")"),
+		nil, nil, nil)
+}
+
+// pgQuote quotes a value of a PostgREST in filter.
+func pgQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,234 @@
+// Package writebehind mirrors the objects of keys to external stores, such
+// as PostGIS, DynamoDB or BigQuery. The writes are queued and sent to the
+// store in batches in the background, and a batch that fails is retried.
+package writebehind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// The operations of the records.
+const (
+	OpSet  = "set"  // the object was set
+	OpDel  = "del"  // the object was deleted
+	OpDrop = "drop" // all of the objects of the key were deleted
+)
+
+// Record is a write to mirror.
+type Record struct {
+	Op     string             `json:"op"`
+	Key    string             `json:"key"`
+	ID     string             `json:"id,omitempty"`
+	Object json.RawMessage    `json:"object,omitempty"` // GeoJSON, for OpSet
+	Fields map[string]float64 `json:"fields,omitempty"`
+	Time   time.Time          `json:"time"`
+}
+
+// Sink is an external store.
+type Sink interface {
+	// Write stores the records, in order.
+	Write(ctx context.Context, recs []Record) error
+}
+
+// Open returns the sink of a url:
+//
+//	http://host/path, https://host/path
+//	postgis://host:port/table[?ssl=true]
+//	dynamodb://region/table[?endpoint=url&credpath=file&credprofile=name]
+//	bigquery://project/dataset/table[?endpoint=url&credpath=file]
+func Open(rawurl string) (Sink, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return newHTTPSink(rawurl), nil
+	case "postgis":
+		return newPostGISSink(u)
+	case "dynamodb":
+		return newDynamoDBSink(u)
+	case "bigquery":
+		return newBigQuerySink(u)
+	}
+	return nil, errors.New("unsupported write-behind url")
+}
+
+// Options of a Writer.
+type Options struct {
+	Batch    int           // most records in a write
+	Interval time.Duration // longest time that a record waits to be written
+	Retries  int           // of a write that failed, then its records are lost
+	Pending  int           // most records in the queue, the oldest are lost
+}
+
+// DefaultOptions are the options that aren't set.
+var DefaultOptions = Options{
+	Batch:    100,
+	Interval: time.Second,
+	Retries:  3,
+	Pending:  100000,
+}
+
+// writeTimeout is the longest time of a write to the sink.
+const writeTimeout = 30 * time.Second
+
+// retryDelay is the wait before the first retry of a write, which doubles for
+// each retry after, up to maxRetryDelay.
+var retryDelay = 250 * time.Millisecond
+
+const maxRetryDelay = 30 * time.Second
+
+// Stats are the counts of the records of a Writer.
+type Stats struct {
+	Pending   int    // queued
+	Sent      int    // written to the sink
+	Failed    int    // not written after the retries
+	Dropped   int    // left out of a full queue
+	LastError string // of the last write that failed
+}
+
+// Writer queues the records of a key and writes them to a sink.
+type Writer struct {
+	sink   Sink
+	opts   Options
+	wake   chan struct{}
+	done   chan struct{}
+	mu     sync.Mutex
+	queue  []Record
+	stats  Stats
+	closed bool
+}
+
+// NewWriter returns a Writer, which writes in the background until it's
+// closed. The sizes and the interval that aren't set are the defaults.
+func NewWriter(sink Sink, opts Options) *Writer {
+	if opts.Batch <= 0 {
+		opts.Batch = DefaultOptions.Batch
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultOptions.Interval
+	}
+	if opts.Pending <= 0 {
+		opts.Pending = DefaultOptions.Pending
+	}
+	w := &Writer{
+		sink: sink,
+		opts: opts,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Add queues records. When the queue is full, the oldest records are lost.
+func (w *Writer) Add(recs ...Record) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.queue = append(w.queue, recs...)
+	if n := len(w.queue) - w.opts.Pending; n > 0 {
+		w.queue = append(w.queue[:0], w.queue[n:]...)
+		w.stats.Dropped += n
+	}
+	full := len(w.queue) >= w.opts.Batch
+	w.mu.Unlock()
+	if full {
+		w.signal()
+	}
+}
+
+func (w *Writer) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stats returns the counts of the records.
+func (w *Writer) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := w.stats
+	stats.Pending = len(w.queue)
+	return stats
+}
+
+// Close stops the writer after the queued records are written, without
+// waiting for them.
+func (w *Writer) Close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.signal()
+}
+
+// Done is closed when the writer stopped.
+func (w *Writer) Done() <-chan struct{} {
+	return w.done
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	t := time.NewTicker(w.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.wake:
+		case <-t.C:
+		}
+		for {
+			w.mu.Lock()
+			n := len(w.queue)
+			if n > w.opts.Batch {
+				n = w.opts.Batch
+			}
+			batch := append([]Record(nil), w.queue[:n]...)
+			w.queue = append(w.queue[:0], w.queue[n:]...)
+			closed := w.closed
+			w.mu.Unlock()
+			if len(batch) == 0 {
+				if closed {
+					return
+				}
+				break
+			}
+			w.write(batch)
+		}
+	}
+}
+
+// write writes a batch, with retries.
+func (w *Writer) write(batch []Record) {
+	delay := retryDelay
+	for i := 0; ; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err := w.sink.Write(ctx, batch)
+		cancel()
+		w.mu.Lock()
+		if err == nil {
+			w.stats.Sent += len(batch)
+		} else {
+			w.stats.LastError = err.Error()
+			if i == w.opts.Retries {
+				w.stats.Failed += len(batch)
+			}
+		}
+		w.mu.Unlock()
+		if err == nil || i == w.opts.Retries {
+			return
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
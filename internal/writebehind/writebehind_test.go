@@ -0,0 +1,276 @@
+package writebehind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSink keeps the batches that were written, and fails the first writes.
+type testSink struct {
+	mu      sync.Mutex
+	fails   int
+	batches [][]Record
+}
+
+func (s *testSink) Write(ctx context.Context, recs []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("unavailable")
+	}
+	s.batches = append(s.batches, append([]Record(nil), recs...))
+	return nil
+}
+
+func (s *testSink) ids() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var batches []string
+	for _, batch := range s.batches {
+		var ids []string
+		for _, rec := range batch {
+			ids = append(ids, rec.ID)
+		}
+		batches = append(batches, strings.Join(ids, ","))
+	}
+	return strings.Join(batches, " ")
+}
+
+func set(id string) Record {
+	return Record{Op: OpSet, Key: "fleet", ID: id,
+		Object: json.RawMessage(`{"type":"Point","coordinates":[1,2]}`),
+		Fields: map[string]float64{"speed": 10},
+		Time:   time.Unix(1600000000, 0)}
+}
+
+func del(id string) Record {
+	return Record{Op: OpDel, Key: "fleet", ID: id,
+		Time: time.Unix(1600000000, 0)}
+}
+
+func TestWriter(t *testing.T) {
+	retryDelay = time.Millisecond
+	sink := &testSink{}
+	w := NewWriter(sink, Options{Batch: 2, Interval: time.Hour})
+	w.Add(set("a"))
+	w.Add(set("b"), set("c"))
+	w.Close()
+	w.Add(set("d"))
+	<-w.Done()
+	if ids := sink.ids(); ids != "a,b c" {
+		t.Fatalf("expected 'a,b c', got '%s'", ids)
+	}
+	stats := w.Stats()
+	if stats.Sent != 3 || stats.Pending != 0 || stats.Failed != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// the interval sends the batches that aren't full
+	sink = &testSink{fails: 2}
+	w = NewWriter(sink, Options{Batch: 10, Interval: 10 * time.Millisecond,
+		Retries: 2})
+	w.Add(set("a"))
+	time.Sleep(100 * time.Millisecond)
+	if ids := sink.ids(); ids != "a" {
+		t.Fatalf("expected 'a', got '%s'", ids)
+	}
+	if stats := w.Stats(); stats.Sent != 1 || stats.LastError != "unavailable" {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	w.Close()
+	<-w.Done()
+
+	// the records are lost after the retries, and the oldest of a full queue
+	sink = &testSink{fails: 2}
+	w = NewWriter(sink, Options{Batch: 10, Interval: time.Hour, Retries: 1,
+		Pending: 2})
+	w.Add(set("a"), set("b"), set("c"))
+	w.Close()
+	<-w.Done()
+	stats = w.Stats()
+	if stats.Failed != 2 || stats.Dropped != 1 || stats.Sent != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, url := range []string{
+		"http://localhost:8080/objects",
+		"postgis://localhost:3000/objects",
+		"dynamodb://us-east-1/objects",
+		"bigquery://project/dataset/objects",
+	} {
+		if _, err := Open(url); err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+	}
+	for _, url := range []string{
+		"ftp://localhost/objects",
+		"postgis://localhost:3000",
+		"dynamodb:///objects",
+		"bigquery://project/objects",
+	} {
+		if _, err := Open(url); err == nil {
+			t.Fatalf("%s: expected an error", url)
+		}
+	}
+}
+
+// testServer returns a server that keeps the requests, and the requests as
+// "METHOD path?query body" lines.
+func testServer(t *testing.T, reply string) (*httptest.Server, func() string) {
+	var mu sync.Mutex
+	var reqs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		line := r.Method + " " + r.URL.RequestURI()
+		if target := r.Header.Get("X-Amz-Target"); target != "" {
+			line += " " + target
+		}
+		if len(body) > 0 {
+			line += " " + string(body)
+		}
+		mu.Lock()
+		reqs = append(reqs, line)
+		mu.Unlock()
+		w.Write([]byte(reply))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(reqs, "\n")
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	ts, reqs := testServer(t, "")
+	sink, _ := Open(ts.URL + "/objects")
+	if err := sink.Write(context.Background(),
+		[]Record{set("a"), del("b")}); err != nil {
+		t.Fatal(err)
+	}
+	expect := `POST /objects [{"op":"set","key":"fleet","id":"a",` +
+		`"object":{"type":"Point","coordinates":[1,2]},"fields":{"speed":10},` +
+		`"time":"` + time.Unix(1600000000, 0).Format(time.RFC3339) + `"},` +
+		`{"op":"del","key":"fleet","id":"b",` +
+		`"time":"` + time.Unix(1600000000, 0).Format(time.RFC3339) + `"}]`
+	if reqs() != expect {
+		t.Fatalf("expected\n%s\ngot\n%s", expect, reqs())
+	}
+}
+
+func TestPostGISSink(t *testing.T) {
+	ts, reqs := testServer(t, "")
+	sink, _ := Open("postgis://" + ts.Listener.Addr().String() + "/objects")
+	a := set("a")
+	a.Fields = nil
+	err := sink.Write(context.Background(), []Record{
+		set("a"), set("b"), a, del("a"), del(`c"d`),
+		{Op: OpDrop, Key: "fleet"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `POST /objects?on_conflict=key,id [` +
+		`{"key":"fleet","id":"a","object":{"type":"Point","coordinates":[1,2]},` +
+		`"fields":null,"time":"2020-09-13T12:26:40Z"},` +
+		`{"key":"fleet","id":"b","object":{"type":"Point","coordinates":[1,2]},` +
+		`"fields":{"speed":10},"time":"2020-09-13T12:26:40Z"}]` + "\n" +
+		`DELETE /objects?key=eq.fleet&id=in.%28%22a%22%2C%22c%5C%22d%22%29` + "\n" +
+		`DELETE /objects?key=eq.fleet`
+	if reqs() != expect {
+		t.Fatalf("expected\n%s\ngot\n%s", expect, reqs())
+	}
+}
+
+func TestDynamoDBSink(t *testing.T) {
+	ts, reqs := testServer(t, `{"Items":[{"id":{"S":"x"}}]}`)
+	credPath := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(credPath, []byte("[default]\n"+
+		"aws_access_key_id = id\naws_secret_access_key = secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, err := Open("dynamodb://us-east-1/objects?endpoint=" + ts.URL +
+		"&credpath=" + credPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sink.Write(context.Background(), []Record{
+		set("a"), del("a"), {Op: OpDrop, Key: "fleet"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `POST / DynamoDB_20120810.BatchWriteItem {"RequestItems":{"objects":[` +
+		`{"PutRequest":{"Item":{"fields":{"M":{"speed":{"N":"10"}}},` +
+		`"id":{"S":"a"},"key":{"S":"fleet"},` +
+		`"object":{"S":"{\"type\":\"Point\",\"coordinates\":[1,2]}"},` +
+		`"time":{"S":"2020-09-13T12:26:40Z"}}}}]}}` + "\n" +
+		`POST / DynamoDB_20120810.BatchWriteItem {"RequestItems":{"objects":[` +
+		`{"DeleteRequest":{"Key":{"id":{"S":"a"},"key":{"S":"fleet"}}}}]}}` + "\n" +
+		`POST / DynamoDB_20120810.Query {"ExpressionAttributeNames":` +
+		`{"#i":"id","#k":"key"},"ExpressionAttributeValues":{":k":{"S":"fleet"}},` +
+		`"KeyConditionExpression":"#k = :k","ProjectionExpression":"#i",` +
+		`"TableName":"objects"}` + "\n" +
+		`POST / DynamoDB_20120810.BatchWriteItem {"RequestItems":{"objects":[` +
+		`{"DeleteRequest":{"Key":{"id":{"S":"x"},"key":{"S":"fleet"}}}}]}}`
+	if reqs() != expect {
+		t.Fatalf("expected\n%s\ngot\n%s", expect, reqs())
+	}
+}
+
+func TestBigQuerySink(t *testing.T) {
+	ts, reqs := testServer(t, `{}`)
+	sink, _ := Open("bigquery://project/dataset/objects?endpoint=" + ts.URL)
+	err := sink.Write(context.Background(), []Record{set("a"), del("a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reqs()
+	prefix := "POST /bigquery/v2/projects/project/datasets/dataset/tables/" +
+		"objects/insertAll "
+	if !strings.HasPrefix(got, prefix) {
+		t.Fatalf("expected %s, got %s", prefix, got)
+	}
+	var body struct {
+		Rows []struct {
+			InsertID string `json:"insertId"`
+			JSON     map[string]string
+		}
+	}
+	if err := json.Unmarshal([]byte(got[len(prefix):]), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Rows) != 2 || body.Rows[0].InsertID == body.Rows[1].InsertID {
+		t.Fatalf("unexpected rows %s", got)
+	}
+	row, _ := json.Marshal(body.Rows[0].JSON)
+	expect := `{"fields":"{\"speed\":10}","id":"a","key":"fleet",` +
+		`"object":"{\"type\":\"Point\",\"coordinates\":[1,2]}","op":"set",` +
+		`"time":"2020-09-13 12:26:40"}`
+	if string(row) != expect {
+		t.Fatalf("expected %s, got %s", expect, row)
+	}
+
+	ts, _ = testServer(t, `{"insertErrors":[{"index":0,`+
+		`"errors":[{"message":"no such field"}]}]}`)
+	sink, _ = Open("bigquery://project/dataset/objects?endpoint=" + ts.URL)
+	err = sink.Write(context.Background(), []Record{set("a")})
+	if err == nil || err.Error() != "bigquery: row not inserted: no such field" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tidwall/tile38/internal/cdc"
+)
+
+func subTestCDC(t *testing.T, mc *mockServer) {
+	runStep(t, mc, "stream", cdc_stream_test)
+}
+
+func cdc_stream_test(mc *mockServer) error {
+	conn, err := net.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := fmt.Fprintf(conn, "CDC 0\r\n"); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	rd := bufio.NewReader(conn)
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if line != "+OK\r\n" {
+		return fmt.Errorf("expected OK, got '%v'", line)
+	}
+	rec, err := cdc.ReadRecord(rd)
+	if err != nil {
+		return err
+	}
+	if rec.Header == nil || rec.Header.Version != cdc.Version {
+		return fmt.Errorf("expected a header, got %+v", rec)
+	}
+
+	if err := mc.DoBatch([][]interface{}{
+		{"SET", "cdc", "marker", "STRING", "start"}, {"OK"},
+		{"SET", "cdc", "truck1", "FIELD", "speed", 10, "POINT", 33, -115}, {"OK"},
+		{"MSET", "cdc", "ID", "truck2", "POINT", 34, -115, "ID", "truck3", "STRING", "hi"}, {2},
+		{"FSET", "cdc", "truck1", "speed", 20}, {1},
+		{"EXPIRE", "cdc", "truck1", 10}, {1},
+		{"PERSIST", "cdc", "truck1"}, {1},
+		{"DEL", "cdc", "truck2"}, {1},
+		{"RENAME", "cdc", "cdc2"}, {"OK"},
+		{"SETCHAN", "cdcchan", "NEARBY", "cdc2", "FENCE", "POINT", 33, -115, 100}, {1},
+		{"DROP", "cdc2"}, {1},
+	}); err != nil {
+		return err
+	}
+	expect := []cdc.Record{
+		{Set: &cdc.Set{Key: "cdc", ID: "truck1",
+			Object: `{"type":"Point","coordinates":[-115,33]}`,
+			Fields: map[string]float64{"speed": 10}}},
+		{Set: &cdc.Set{Key: "cdc", ID: "truck2",
+			Object: `{"type":"Point","coordinates":[-115,34]}`}},
+		{Set: &cdc.Set{Key: "cdc", ID: "truck3", Object: `"hi"`}},
+		{FSet: &cdc.FSet{Key: "cdc", ID: "truck1",
+			Fields: map[string]float64{"speed": 20}}},
+		{Expire: &cdc.Expire{Key: "cdc", ID: "truck1", Seconds: 10}},
+		{Persist: &cdc.Persist{Key: "cdc", ID: "truck1"}},
+		{Del: &cdc.Del{Key: "cdc", ID: "truck2"}},
+		{Rename: &cdc.Rename{Key: "cdc", NewKey: "cdc2"}},
+		{Command: &cdc.Command{Args: []string{"SETCHAN", "cdcchan", "NEARBY",
+			"cdc2", "FENCE", "POINT", "33", "-115", "100"}}},
+		{Drop: &cdc.Drop{Key: "cdc2"}},
+	}
+
+	// skip the records of the changes before the marker
+	for rec.Set == nil || rec.Set.ID != "marker" {
+		if rec, err = cdc.ReadRecord(rd); err != nil {
+			return err
+		}
+	}
+	offset := rec.Offset
+	for i := 0; i < len(expect); {
+		if rec, err = cdc.ReadRecord(rd); err != nil {
+			return err
+		}
+		if rec.Offset < offset {
+			return fmt.Errorf("offset %d is before %d", rec.Offset, offset)
+		}
+		offset = rec.Offset
+		if rec.Checkpoint != nil {
+			continue
+		}
+		rec.Offset = 0
+		if !reflect.DeepEqual(rec, expect[i]) {
+			return fmt.Errorf("%d: expected %+v, got %+v", i, expect[i], rec)
+		}
+		i++
+	}
+	return nil
+}
@@ -1,17 +1,32 @@
 package tests
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/websocket"
+	"github.com/klauspost/compress/zstd"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/tile38/core"
 )
 
 func subTestClient(t *testing.T, mc *mockServer) {
 	runStep(t, mc, "valid json", client_valid_json_test)
 	runStep(t, mc, "valid client count", info_valid_client_count_test)
+	runStep(t, mc, "compressed replies", client_compress_test)
+	runStep(t, mc, "web ui", client_webui_test)
+	runStep(t, mc, "jwt auth", client_jwt_auth_test)
+	runStep(t, mc, "output limits", client_output_limit_test)
 }
 
 func client_valid_json_test(mc *mockServer) error {
@@ -75,3 +90,270 @@ func info_valid_client_count_test(mc *mockServer) error {
 	}
 	return nil
 }
+
+func client_compress_test(mc *mockServer) error {
+	for i := 0; i < 300; i++ {
+		_, err := mc.Do("SET", "mykey", fmt.Sprintf("id%d", i),
+			"POINT", 33.1+float64(i)/1000, -112.1)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := mc.Do("OUTPUT", "json"); err != nil {
+		return err
+	}
+	expect, err := redis.Bytes(mc.Do("SCAN", "mykey", "LIMIT", 300, "IDS"))
+	if err != nil {
+		return err
+	}
+	// remove the elapsed member
+	expect = expect[:bytes.LastIndex(expect, []byte(`,"elapsed"`))]
+	if _, err := mc.Do("OUTPUT", "json", "COMPRESS", "gzip"); err != nil {
+		return err
+	}
+	data, err := redis.Bytes(mc.Do("SCAN", "mykey", "LIMIT", 300, "IDS"))
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(data, []byte("gzip:")) {
+		return errors.New("expected a gzip reply")
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data[5:]))
+	if err != nil {
+		return err
+	}
+	frame, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(frame, expect) {
+		return fmt.Errorf("expected '%s', got '%s'", expect, frame)
+	}
+	// the small replies aren't compressed, but they're marked too
+	data, err = redis.Bytes(mc.Do("SCAN", "mykey", "LIMIT", 1, "IDS"))
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(data, []byte("none:$")) ||
+		!bytes.Contains(data, []byte(`"ids":["id0"]`)) {
+		return fmt.Errorf("expected an uncompressed reply, got '%s'", data)
+	}
+
+	// HTTP
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("http://localhost:%d/SCAN+mykey+LIMIT+300+IDS", mc.port), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept-Encoding", "gzip, zstd")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if enc := res.Header.Get("Content-Encoding"); enc != "zstd" {
+		return fmt.Errorf("expected '%s', got '%s'", "zstd", enc)
+	}
+	zr, err := zstd.NewReader(res.Body)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	body, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+	if gjson.GetBytes(body, "ids.#").Int() != 300 {
+		return fmt.Errorf("expected 300 ids, got '%s'", body)
+	}
+
+	// a q of zero refuses an encoding
+	for header, expect := range map[string]string{
+		"gzip;q=0.0, zstd;q=0.000": "",
+		"zstd;q=0, gzip;q=0.5":     "gzip",
+		"zstd; q=0.001":            "zstd",
+		"zstd;q=none":              "",
+	} {
+		req.Header.Set("Accept-Encoding", header)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if enc := res.Header.Get("Content-Encoding"); enc != expect {
+			return fmt.Errorf("expected '%s' for '%s', got '%s'", expect,
+				header, enc)
+		}
+	}
+	return nil
+}
+
+func client_webui_test(mc *mockServer) error {
+	get := func() (int, string, error) {
+		res, err := http.Get(fmt.Sprintf("http://localhost:%d/ui", mc.port))
+		if err != nil {
+			return 0, "", err
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(body), err
+	}
+	if status, _, err := get(); err != nil || status != 404 {
+		return fmt.Errorf("expected status code 404, got: %d %v", status, err)
+	}
+	core.WebUI = true
+	defer func() { core.WebUI = false }()
+	status, page, err := get()
+	if err != nil || status != 200 ||
+		!strings.Contains(page, "<title>Tile38 Console</title>") {
+		return fmt.Errorf("expected the console page, got: %d %v", status, err)
+	}
+
+	// the console sends the password of websockets as a protocol
+	if err := mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "requirepass", "web pass"}, {"OK"},
+		{"AUTH", "web pass"}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	defer mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "requirepass", ""}, {"OK"},
+	})
+	wsurl := fmt.Sprintf("ws://localhost:%d/KEYS+*", mc.port)
+	protocol := "auth-" + base64.RawURLEncoding.EncodeToString([]byte("web pass"))
+	for _, protocols := range [][]string{nil, {protocol}} {
+		dialer := websocket.Dialer{Subprotocols: protocols}
+		conn, _, err := dialer.Dial(wsurl, nil)
+		if err != nil {
+			return err
+		}
+		_, msg, err := conn.ReadMessage()
+		conn.Close()
+		if err != nil {
+			return err
+		}
+		ok := gjson.GetBytes(msg, "ok").Bool()
+		if ok != (protocols != nil) {
+			return fmt.Errorf("unexpected message: %s", msg)
+		}
+	}
+	return nil
+}
+
+// hs256 returns a JWT of the claims that's signed with a secret.
+func hs256(secret, claims string) string {
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func client_jwt_auth_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	token := hs256("jwt secret", `{"sub":"ann","iss":"idp"}`)
+	if err := mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "ldap-url", "http://ldap"}, {"ERR Invalid argument 'http://ldap' for CONFIG SET 'ldap-url'"},
+		{"CONFIG", "SET", "jwt-issuer", "idp"}, {"OK"},
+		{"CONFIG", "SET", "jwt-secret", "jwt secret"}, {"OK"},
+		{"AUTH", hs256("wrong", `{"sub":"ann","iss":"idp"}`)}, {"ERR invalid token"},
+		{"AUTH", hs256("jwt secret", `{"sub":"ann","iss":"other"}`)}, {"ERR invalid token issuer"},
+		{"AUTH", token}, {"OK"},
+		{"SET", "jwtfleet", "t1", "POINT", 33, -115}, {"OK"},
+		{"NSSET", "jwtns"}, {"OK"},
+		{"CONFIG", "SET", "jwt-namespace-claim", "ns"}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	defer mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "jwt-secret", ""}, {"OK"},
+		{"CONFIG", "SET", "jwt-issuer", ""}, {"OK"},
+		{"CONFIG", "SET", "jwt-namespace-claim", ""}, {"OK"},
+		{"NSDEL", "jwtns"}, {1},
+	})
+	// the namespace claim binds the connection to the namespace
+	conn, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Do("KEYS", "*"); err == nil ||
+		err.Error() != "ERR authentication required" {
+		return fmt.Errorf("expected 'authentication required', got '%v'", err)
+	}
+	if _, err := conn.Do("AUTH", token); err == nil ||
+		err.Error() != "ERR token has no 'ns' claim" {
+		return fmt.Errorf("expected a missing claim, got '%v'", err)
+	}
+	nsToken := hs256("jwt secret", `{"sub":"ann","iss":"idp","ns":"jwtns"}`)
+	if _, err := conn.Do("AUTH", nsToken); err != nil {
+		return err
+	}
+	keys, err := redis.Strings(conn.Do("KEYS", "*"))
+	if err != nil || len(keys) != 0 {
+		return fmt.Errorf("expected no keys, got %v %v", keys, err)
+	}
+	if _, err := conn.Do("SELECT", "default"); err == nil {
+		return errors.New("expected the connection to be bound")
+	}
+	// the token of an HTTP request is a bearer token
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("http://localhost:%d/KEYS+*", mc.port), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+nsToken)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if !gjson.GetBytes(body, "ok").Bool() ||
+		gjson.GetBytes(body, "keys.#").Int() != 0 {
+		return fmt.Errorf("expected no keys, got %s", body)
+	}
+	return nil
+}
+
+func client_output_limit_test(mc *mockServer) error {
+	if err := mc.DoBatch([][]interface{}{
+		{"CONFIG", "GET", "output-limit-pubsub"}, {"[output-limit-pubsub 32mb 8mb 60]"},
+		{"CONFIG", "SET", "output-limit-normal", "1kb 0 0 drop-oldest"}, {"ERR Invalid argument '1kb 0 0 drop-oldest' for CONFIG SET 'output-limit-normal'"},
+		{"CONFIG", "SET", "output-limit-pubsub", "1kb 0 0 wait"}, {"ERR Invalid argument '1kb 0 0 wait' for CONFIG SET 'output-limit-pubsub'"},
+		{"CONFIG", "SET", "output-limit-pubsub", "1mb 512kb 10 drop-oldest"}, {"OK"},
+		{"CONFIG", "GET", "output-limit-pubsub"}, {"[output-limit-pubsub 1mb 512kb 10 drop-oldest]"},
+		{"CONFIG", "SET", "output-limit-pubsub", ""}, {"OK"},
+		{"CONFIG", "SET", "output-limit-normal", "4kb 0 0"}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	defer mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "output-limit-normal", ""}, {"OK"},
+		{"CONFIG", "GET", "output-limit-normal"}, {"[output-limit-normal 0 0 0]"},
+		{"DROP", "outfleet"}, {1},
+	})
+	for i := 0; i < 200; i++ {
+		if _, err := mc.conn.Do("SET", "outfleet", fmt.Sprintf("truck%d", i),
+			"POINT", 33, -115); err != nil {
+			return err
+		}
+	}
+	// a reply over the hard limit disconnects the client
+	conn, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Do("SCAN", "outfleet", "LIMIT", 10, "IDS"); err != nil {
+		return err
+	}
+	if _, err := conn.Do("SCAN", "outfleet", "LIMIT", 1000); err == nil {
+		return errors.New("expected the connection to be closed")
+	}
+	return nil
+}
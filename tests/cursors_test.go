@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/tidwall/gjson"
+)
+
+func subTestCursors(t *testing.T, mc *mockServer) {
+	runStep(t, mc, "kill", cursors_kill_test)
+	runStep(t, mc, "ttl", cursors_ttl_test)
+}
+
+// openCursors returns the open cursors of a key.
+func openCursors(mc *mockServer, key string) ([]gjson.Result, error) {
+	if _, err := mc.Do("OUTPUT", "json"); err != nil {
+		return nil, err
+	}
+	defer mc.Do("OUTPUT", "resp")
+	res, err := redis.String(mc.Do("CURSORS", "LIST"))
+	if err != nil {
+		return nil, err
+	}
+	var list []gjson.Result
+	for _, c := range gjson.Get(res, "cursors").Array() {
+		if c.Get("key").String() == key {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+func cursors_kill_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	if err := mc.DoBatch([][]interface{}{
+		{"SET", "cfleet", "t1", "POINT", 33, -115}, {"OK"},
+		{"SET", "cfleet", "t2", "POINT", 33.002, -115}, {"OK"},
+		{"SET", "cfleet", "t3", "POINT", 33.003, -115}, {"OK"},
+		{"SET", "cfleet", "t4", "POINT", 33.004, -115}, {"OK"},
+		{"SET", "cfleet", "t5", "POINT", 33.005, -115}, {"OK"},
+		{"SCAN", "cfleet", "LIMIT", 2, "IDS"}, {"[2 [t1 t2]]"},
+		{"SCAN", "cfleet", "CURSOR", 2, "LIMIT", 2, "IDS"}, {"[4 [t3 t4]]"},
+	}); err != nil {
+		return err
+	}
+	list, err := openCursors(mc, "cfleet")
+	if err != nil {
+		return err
+	}
+	if len(list) != 1 || list[0].Get("command").String() != "scan" ||
+		list[0].Get("cursor").Int() != 4 {
+		return fmt.Errorf("expected an open scan cursor at 4, got %v", list)
+	}
+	id := list[0].Get("id").Int()
+	if err := mc.DoBatch([][]interface{}{
+		// the last page closes the cursor
+		{"SCAN", "cfleet", "CURSOR", 4, "LIMIT", 2, "IDS"}, {"[0 [t5]]"},
+		{"CURSORS", "KILL", id}, {0},
+		{"CURSORS", "KILL", "abc"}, {"ERR invalid argument 'abc'"},
+		{"NEARBY", "cfleet", "LIMIT", 2, "IDS", "POINT", 33, -115}, {"[2 [t1 t2]]"},
+	}); err != nil {
+		return err
+	}
+	list, err = openCursors(mc, "cfleet")
+	if err != nil {
+		return err
+	}
+	if len(list) != 1 || list[0].Get("command").String() != "nearby" {
+		return fmt.Errorf("expected an open nearby cursor, got %v", list)
+	}
+	return mc.DoBatch([][]interface{}{
+		{"CURSORS", "KILL", list[0].Get("id").Int()}, {1},
+		{"SCAN", "cfleet", "CURSOR", 2, "LIMIT", 2, "IDS"}, {"[4 [t3 t4]]"},
+		{"NEARBY", "cfleet", "CURSOR", 2, "LIMIT", 2, "IDS", "POINT", 33, -115}, {"ERR cursor killed"},
+		{"NEARBY", "cfleet", "CURSOR", 2, "LIMIT", 2, "IDS", "POINT", 33, -115}, {"[4 [t3 t4]]"},
+	})
+}
+
+func cursors_ttl_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	defer mc.Do("CONFIG", "SET", "cursor-ttl", "")
+	if err := mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "cursor-ttl", 0}, {"ERR Invalid argument '0' for CONFIG SET 'cursor-ttl'"},
+		{"CONFIG", "SET", "cursor-ttl", 1}, {"OK"},
+		{"SET", "tfleet", "t1", "POINT", 33, -115}, {"OK"},
+		{"SET", "tfleet", "t2", "POINT", 33, -115}, {"OK"},
+		{"SCAN", "tfleet", "LIMIT", 1, "IDS"}, {"[1 [t1]]"},
+	}); err != nil {
+		return err
+	}
+	for start := time.Now(); ; time.Sleep(100 * time.Millisecond) {
+		list, err := openCursors(mc, "tfleet")
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		if time.Since(start) > 5*time.Second {
+			return fmt.Errorf("expected the cursor to expire, got %v", list)
+		}
+	}
+}
@@ -261,6 +261,7 @@ func cleanMessage(body []byte) string {
 	msg, _ := sjson.Delete(string(body), "group")
 	msg, _ = sjson.Delete(msg, "time")
 	msg, _ = sjson.Delete(msg, "hook")
+	msg, _ = sjson.Delete(msg, "seq")
 	msg = string(pretty.Ugly([]byte(msg)))
 	return msg
 }
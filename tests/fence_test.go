@@ -8,6 +8,9 @@ import (
 	"io"
 	"log"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -36,6 +39,18 @@ func subTestFence(t *testing.T, mc *mockServer) {
 
 	// various
 	runStep(t, mc, "detect eecio", fence_eecio_test)
+	runStep(t, mc, "subscribe filter", fence_subscribe_filter_test)
+	runStep(t, mc, "channel seq", fence_channel_seq_test)
+	runStep(t, mc, "map matching", fence_map_matching_test)
+	runStep(t, mc, "areas", fence_areas_test)
+	runStep(t, mc, "viewport", fence_viewport_test)
+	runStep(t, mc, "expired", fence_expired_test)
+	runStep(t, mc, "expired field", fence_expired_field_test)
+	runStep(t, mc, "inactive", fence_inactive_test)
+	runStep(t, mc, "notify", fence_notify_test)
+	runStep(t, mc, "hook export", fence_hook_export_test)
+	runStep(t, mc, "occupancy", fence_occupancy_test)
+	runStep(t, mc, "threshold", fence_threshold_test)
 }
 
 type fenceReader struct {
@@ -360,6 +375,295 @@ func doTile38(c redis.Conn, cmd string, args ...interface{}) (string, error) {
 	return js, err
 }
 
+func fence_subscribe_filter_test(mc *mockServer) error {
+	conn, err := dialTile38(mc.port)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := doTile38(conn, "SUBSCRIBE", "subf", "FILTER", "WHERE"); err == nil ||
+		err.Error() != "invalid number of arguments" {
+		return fmt.Errorf("expected an error, got '%v'", err)
+	}
+	if _, err := doTile38(conn, "SUBSCRIBE", "subf", "FILTER",
+		"DETECT", "enter,exit", "MATCH", "truck*",
+		"WHERE", "speed", 10, "+inf"); err != nil {
+		return err
+	}
+	msg := func(detect, id string, speed int) string {
+		return fmt.Sprintf(`{"command":"set","detect":"%s","key":"fleet",`+
+			`"id":"%s","object":{"type":"Point","coordinates":[0,0]},`+
+			`"fields":{"speed":%d}}`, detect, id, speed)
+	}
+	if err := mc.DoBatch([][]interface{}{
+		{"PUBLISH", "subf", msg("enter", "truck1", 20)}, {1},
+		{"PUBLISH", "subf", msg("inside", "truck1", 20)}, {0},
+		{"PUBLISH", "subf", msg("enter", "car1", 20)}, {0},
+		{"PUBLISH", "subf", msg("exit", "truck2", 5)}, {0},
+		{"PUBLISH", "subf", "hello"}, {0},
+		{"PUBLISH", "subf", msg("exit", "truck2", 10)}, {1},
+	}); err != nil {
+		return err
+	}
+	var ids []string
+	for i := 0; i < 2; i++ {
+		js, err := redis.String(conn.Receive())
+		if err != nil {
+			return err
+		}
+		ids = append(ids, gjson.Get(js, "detect").String()+":"+
+			gjson.Get(js, "id").String())
+	}
+	if strings.Join(ids, ",") != "enter:truck1,exit:truck2" {
+		return fmt.Errorf("unexpected messages %v", ids)
+	}
+	return nil
+}
+
+func fence_channel_seq_test(mc *mockServer) error {
+	setchan := []interface{}{"SETCHAN", "seqchan", "NEARBY", "seqfleet",
+		"FENCE", "DETECT", "enter,exit", "POINT", 33, -115, 1000}
+	if err := mc.DoBatch([][]interface{}{setchan, {1}}); err != nil {
+		return err
+	}
+	conn, err := dialTile38(mc.port)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := doTile38(conn, "SUBSCRIBE", "seqchan"); err != nil {
+		return err
+	}
+	if err := mc.DoBatch([][]interface{}{
+		{"SET", "seqfleet", "t1", "POINT", 33, -115}, {"OK"},
+		{"SET", "seqfleet", "t1", "POINT", 34, -115}, {"OK"},
+		{"SET", "seqfleet", "t2", "POINT", 33, -115}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	var seqs []string
+	for i := 0; i < 3; i++ {
+		js, err := redis.String(redis.ReceiveWithTimeout(conn, 5*time.Second))
+		if err != nil {
+			return err
+		}
+		seqs = append(seqs, gjson.Get(js, "detect").String()+":"+
+			gjson.Get(js, "seq").String())
+	}
+	if strings.Join(seqs, ",") != "enter:1,exit:2,enter:3" {
+		return fmt.Errorf("unexpected messages %v", seqs)
+	}
+	return mc.DoBatch([][]interface{}{
+		{"CHANACK", "seqchan", "c1", 2}, {1},
+		{"CHANACK", "seqchan", "c1", 1}, {0},
+		{"CHANACK", "seqchan", "c2", 3}, {1},
+		{"CHANACK", "seqchan", "c1", 4}, {"ERR invalid argument '4'"},
+		{"CHANACK", "nochan", "c1", 1}, {"ERR unknown channel"},
+		{"CHANACKS", "seqchan"}, {"[3 [[c1 2] [c2 3]]]"},
+		// the sequence continues when the channel is set again
+		{"DELCHAN", "seqchan"}, {1},
+		setchan, {1},
+		{"CHANACKS", "seqchan"}, {"[3 [[c1 2] [c2 3]]]"},
+		{"DELCHAN", "seqchan"}, {1},
+	})
+}
+
+func fence_notify_test(mc *mockServer) error {
+	posts := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		posts <- string(body)
+	}))
+	defer ts.Close()
+	if err := mc.DoBatch([][]interface{}{
+		{"SETCHAN", "notechan", "META", "team", "red", "NEARBY", "notefleet",
+			"FENCE", "POINT", 33, -115, 1000}, {1},
+		{"SETHOOK", "notehook", ts.URL, "NEARBY", "notefleet", "FENCE",
+			"POINT", 33, -115, 1000}, {1},
+	}); err != nil {
+		return err
+	}
+	conn, err := dialTile38(mc.port)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := doTile38(conn, "SUBSCRIBE", "notechan"); err != nil {
+		return err
+	}
+	if err := mc.DoBatch([][]interface{}{
+		{"NOTIFY", "note*", "alert", `{"level":"high"}`}, {2},
+		{"NOTIFY", "nochan", "alert", `{"level":"high"}`}, {0},
+		{"NOTIFY", "note*", "alert", `{"level"`}, {`ERR invalid argument '{"level"'`},
+		{"NOTIFY", "note*", "alert"}, {"ERR wrong number of arguments for 'notify' command"},
+	}); err != nil {
+		return err
+	}
+	js, err := redis.String(redis.ReceiveWithTimeout(conn, 5*time.Second))
+	if err != nil {
+		return err
+	}
+	expect := `{"command":"notify","type":"alert","meta":{"team":"red"},` +
+		`"key":"notefleet","data":{"level":"high"}}`
+	if msg := cleanMessage([]byte(js)); msg != expect ||
+		gjson.Get(js, "hook").String() != "notechan" ||
+		gjson.Get(js, "seq").Int() != 1 {
+		return fmt.Errorf("expected '%s', got '%s'", expect, js)
+	}
+	select {
+	case js = <-posts:
+	case <-time.After(5 * time.Second):
+		return errors.New("timeout waiting for the hook")
+	}
+	if gjson.Get(js, "command").String() != "notify" ||
+		gjson.Get(js, "hook").String() != "notehook" ||
+		gjson.Get(js, "data.level").String() != "high" {
+		return fmt.Errorf("unexpected message '%s'", js)
+	}
+	return mc.DoBatch([][]interface{}{
+		{"DELCHAN", "notechan"}, {1},
+		{"DELHOOK", "notehook"}, {1},
+	})
+}
+
+func fence_hook_export_test(mc *mockServer) error {
+	doc := `{"hooks":[` +
+		`{"name":"exchan","channel":true,"meta":{"team":"red"},` +
+		`"command":["NEARBY","exfleet","FENCE","POINT","33","-115","1000"]},` +
+		`{"name":"exhook","endpoints":["http://127.0.0.1:9999/x"],` +
+		`"inactive":30,` +
+		`"command":["WITHIN","exfleet","FENCE","BOUNDS","32","-116","34","-114"]}]}`
+	return mc.DoBatch([][]interface{}{
+		{"SETCHAN", "exchan", "META", "team", "red", "NEARBY", "exfleet",
+			"FENCE", "POINT", 33, -115, 1000}, {1},
+		{"SETHOOK", "exhook", "http://127.0.0.1:9999/x", "INACTIVE", 30,
+			"WITHIN", "exfleet", "FENCE", "BOUNDS", 32, -116, 34, -114}, {1},
+		{"HOOKEXPORT", "ex*"}, {doc},
+		{"HOOKEXPORT", "nohook*"}, {`{"hooks":[]}`},
+		{"HOOKIMPORT", doc}, {"ERR hook 'exchan' already exists"},
+		{"HOOKIMPORT", doc, "SKIP"}, {0},
+		{"HOOKIMPORT", doc, "REPLACE"}, {2},
+		{"HOOKIMPORT", doc, "MERGE"}, {"ERR invalid argument 'merge'"},
+		{"HOOKIMPORT", "{"}, {"ERR invalid argument '{'"},
+		{"HOOKIMPORT", `{"hooks":[{"name":"exhook2","command":["NEARBY"]}]}`}, {"ERR invalid hook definition"},
+		{"DELCHAN", "exchan"}, {1},
+		{"DELHOOK", "exhook"}, {1},
+		{"HOOKIMPORT", doc}, {2},
+		{"HOOKEXPORT", "ex*"}, {doc},
+		{"HOOKS", "exhook"}, {"[[exhook exfleet [http://127.0.0.1:9999/x] [WITHIN exfleet FENCE BOUNDS 32 -116 34 -114] []]]"},
+		{"DELCHAN", "exchan"}, {1},
+		{"DELHOOK", "exhook"}, {1},
+	})
+}
+
+func fence_occupancy_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "occfleet", "truck1", "POINT", 33, -115}, {"OK"},
+		{"SET", "occfleet", "truck2", "POINT", 10, 10}, {"OK"},
+		{"SETCHAN", "occchan", "OCCUPANCY", 0, "WITHIN", "occfleet", "FENCE",
+			"BOUNDS", 32, -116, 34, -114}, {"ERR invalid argument '0'"},
+		{"SETCHAN", "occchan", "OCCUPANCY", 15, "NEARBY", "occfleet", "FENCE", "ROAM", "occfleet", "*", 1000}, {"ERR OCCUPANCY is not allowed with ROAM"},
+		{"SETCHAN", "occchan", "OCCUPANCY", 15, "WITHIN", "occfleet", "FENCE",
+			"BOUNDS", 32, -116, 34, -114}, {1},
+		{"SETCHAN", "otherchan", "WITHIN", "occfleet", "FENCE",
+			"BOUNDS", 32, -116, 34, -114}, {1},
+		// the objects inside of a new fence didn't enter it
+		{"OCCUPANCY", "*"}, {"[[occchan 1 0 0]]"},
+		{"SET", "occfleet", "truck2", "POINT", 33.5, -115.5}, {"OK"},
+		{"SET", "occfleet", "truck3", "POINT", 33.1, -115.1}, {"OK"},
+		{"SET", "occfleet", "truck3", "POINT", 33.2, -115.2}, {"OK"},
+		{"SET", "occfleet", "truck1", "POINT", 10, 10}, {"OK"},
+		{"DEL", "occfleet", "truck2"}, {1},
+		{"OCCUPANCY", "occchan"}, {"[[occchan 1 2 2]]"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"OCCUPANCY", "occ*"}, {`{"ok":true,"occupancy":[{"name":"occchan","key":"occfleet","inside":1,"entered":2,"exited":2,"minutes":15}]}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"DROP", "occfleet"}, {1},
+		{"OCCUPANCY", "occchan"}, {"[[occchan 0 2 3]]"},
+		{"DELCHAN", "occchan"}, {1},
+		{"DELCHAN", "otherchan"}, {1},
+		{"OCCUPANCY", "*"}, {"[]"},
+	})
+}
+
+func fence_threshold_test(mc *mockServer) error {
+	bc, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+	for _, cmd := range []string{
+		"SETCHAN speedchan THRESHOLD speed 90 100 WITHIN speedkey FENCE BOUNDS 30 -120 40 -100",
+		"SETCHAN speedchan THRESHOLD speed fast 90 WITHIN speedkey FENCE BOUNDS 30 -120 40 -100",
+		"SETCHAN speedchan WITHIN speedkey FENCE DETECT rising BOUNDS 30 -120 40 -100",
+	} {
+		if _, err := do(bc, cmd); err == nil {
+			return fmt.Errorf("expected an error for '%s'", cmd)
+		}
+	}
+	if _, err := do(bc, "SET speedkey truck2 FIELD speed 120 POINT 33 -115"); err != nil {
+		return err
+	}
+	if _, err := do(bc, "SETCHAN speedchan THRESHOLD speed 100 90 WITHIN speedkey FENCE DETECT rising,falling BOUNDS 30 -120 40 -100"); err != nil {
+		return err
+	}
+	defer do(bc, "DROP speedkey")
+	defer do(bc, "DELCHAN speedchan")
+
+	sc, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port),
+		redis.DialReadTimeout(time.Second*2))
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+	psc := redis.PubSubConn{Conn: sc}
+	if err := psc.Subscribe("speedchan"); err != nil {
+		return err
+	}
+	receiveExpect := func(valex ...string) error {
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				for i := 0; i < len(valex); i += 2 {
+					if gjson.GetBytes(v.Data, valex[i]).String() != valex[i+1] {
+						return fmt.Errorf("expected '%s'='%s', got '%s'", valex[i],
+							valex[i+1], gjson.GetBytes(v.Data, valex[i]).String())
+					}
+				}
+				return nil
+			case error:
+				return v
+			}
+		}
+	}
+	for _, cmd := range []string{
+		// truck2 was above the threshold when the channel was set
+		"FSET speedkey truck2 speed 110",
+		"SET speedkey truck1 FIELD speed 80 POINT 33 -115",
+		"FSET speedkey truck1 speed 95",
+		// outside of the fence
+		"SET speedkey truck3 FIELD speed 150 POINT 10 10",
+		"FSET speedkey truck1 speed 105",
+		"FSET speedkey truck1 speed 95",
+		"FSET speedkey truck1 speed 120",
+		"FSET speedkey truck2 speed 85",
+	} {
+		if _, err := do(bc, cmd); err != nil {
+			return err
+		}
+	}
+	for _, expect := range [][]string{
+		{"id", "truck1", "detect", "rising", "fields.speed", "105"},
+		{"id", "truck2", "detect", "falling", "fields.speed", "85"},
+	} {
+		if err := receiveExpect(expect...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func fence_eecio_test(mc *mockServer) error {
 	// simulates issue #578
 	var wg sync.WaitGroup
@@ -517,3 +821,401 @@ func fence_eecio_test(mc *mockServer) error {
 
 	return nil
 }
+
+func fence_map_matching_test(mc *mockServer) error {
+	path, err := writeRoadNetwork()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	if err := mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "roadnetwork", path}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	defer mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "roadnetwork", ""}, {"OK"},
+	})
+
+	conn, err := net.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = fmt.Fprintf(conn, "NEARBY roadkey FENCE POINT 33 -115 5000\r\n")
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return err
+	}
+	if res := string(buf[:n]); res != "+OK\r\n" {
+		return fmt.Errorf("expected OK, got '%v'", res)
+	}
+	rd := &fenceReader{conn, bufio.NewReader(conn)}
+
+	c, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if _, err := c.Do("SET", "roadkey", "myid1", "MATCH", "POINT", 33.0001, -114.999); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set",
+		"detect", "enter",
+		"id", "myid1",
+		"object.coordinates.1", "33",
+		"road", "east"); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set",
+		"detect", "inside",
+		"road", "east"); err != nil {
+		return err
+	}
+	if _, err := c.Do("SET", "roadkey", "myid1", "POINT", 33.0001, -114.999); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set",
+		"detect", "inside",
+		"road", ""); err != nil {
+		return err
+	}
+	return nil
+}
+
+func fence_areas_test(mc *mockServer) error {
+	dir, err := writeZonesLayer()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "layerdir", dir}, {"OK"},
+		{"AREACONFIG", "areakey", "zones"}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	defer mc.DoBatch([][]interface{}{
+		{"AREADEL", "areakey"}, {1},
+		{"CONFIG", "SET", "layerdir", ""}, {"OK"},
+	})
+
+	conn, err := net.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = fmt.Fprintf(conn, "INTERSECTS areakey FENCE BOUNDS 30 -120 40 -100\r\n")
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return err
+	}
+	if res := string(buf[:n]); res != "+OK\r\n" {
+		return fmt.Errorf("expected OK, got '%v'", res)
+	}
+	rd := &fenceReader{conn, bufio.NewReader(conn)}
+
+	c, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if _, err := c.Do("SET", "areakey", "myid1", "POINT", 33.5, -112.5); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set",
+		"detect", "enter",
+		"areas", `["85004","county","downtown"]`); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set",
+		"detect", "inside",
+		"areas", `["85004","county","downtown"]`); err != nil {
+		return err
+	}
+	if _, err := c.Do("MSET", "areakey", "ID", "myid1", "POINT", 36, -112.5); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set",
+		"detect", "inside",
+		"areas", `[]`); err != nil {
+		return err
+	}
+	return nil
+}
+
+func fence_viewport_test(mc *mockServer) error {
+	conn, err := net.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = fmt.Fprintf(conn, "WITHIN vpkey FENCE DETECT inside BOUNDS 33 -116 34 -115\r\n")
+	if err != nil {
+		return err
+	}
+	rd := &fenceReader{conn, bufio.NewReader(conn)}
+	expectLine := func(expect string) error {
+		if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			return err
+		}
+		line, err := rd.rd.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, expect) {
+			return fmt.Errorf("expected '%v', got '%v'", expect, line)
+		}
+		return nil
+	}
+	if err := expectLine("+OK\r\n"); err != nil {
+		return err
+	}
+
+	c, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if _, err := c.Do("SET", "vpkey", "myid1", "POINT", 33.5, -115.5); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("detect", "inside", "id", "myid1"); err != nil {
+		return err
+	}
+
+	// pan the map to the north
+	if _, err := fmt.Fprintf(conn, "VIEWPORT BOUNDS 35 -116 36 -115\r\n"); err != nil {
+		return err
+	}
+	if err := expectLine("+OK\r\n"); err != nil {
+		return err
+	}
+	if _, err := c.Do("SET", "vpkey", "myid1", "POINT", 33.6, -115.5); err != nil {
+		return err
+	}
+	if _, err := c.Do("SET", "vpkey", "myid2", "POINT", 35.5, -115.5); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("detect", "inside", "id", "myid2"); err != nil {
+		return err
+	}
+
+	// zoom out to a tile
+	if _, err := fmt.Fprintf(conn, "VIEWPORT TILE 1 3 3\r\n"); err != nil {
+		return err
+	}
+	if err := expectLine("+OK\r\n"); err != nil {
+		return err
+	}
+	if _, err := c.Do("SET", "vpkey", "myid1", "POINT", 33.7, -115.5); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("detect", "inside", "id", "myid1"); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "VIEWPORT BOUNDS 35 -116\r\n"); err != nil {
+		return err
+	}
+	if err := expectLine("-ERR invalid number of arguments"); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "VIEWPORT POINT 35 -116\r\n"); err != nil {
+		return err
+	}
+	if err := expectLine("-ERR "); err != nil {
+		return err
+	}
+	_, err = c.Do("DROP", "vpkey")
+	return err
+}
+
+func fence_expired_test(mc *mockServer) error {
+	conn, err := net.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = fmt.Fprintf(conn, "INTERSECTS expkey FENCE BOUNDS 30 -120 40 -100\r\n")
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return err
+	}
+	if res := string(buf[:n]); res != "+OK\r\n" {
+		return fmt.Errorf("expected OK, got '%v'", res)
+	}
+	rd := &fenceReader{conn, bufio.NewReader(conn)}
+
+	c, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if _, err := c.Do("SET", "expkey", "far", "EX", 0.2, "POINT", 50, 0); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set", "detect", "outside"); err != nil {
+		return err
+	}
+	if _, err := c.Do("SET", "expkey", "near", "FIELD", "speed", 10,
+		"EX", 0.5, "POINT", 33.5, -112.5); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set", "detect", "enter"); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set", "detect", "inside"); err != nil {
+		return err
+	}
+	// the object outside of the fence is only deleted
+	if err := rd.receiveExpect("command", "del", "id", "far",
+		"detect", ""); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "del", "id", "near",
+		"detect", "expired", "fields.speed", "10",
+		"object", `{"type":"Point","coordinates":[-112.5,33.5]}`); err != nil {
+		return err
+	}
+	return nil
+}
+
+func fence_expired_field_test(mc *mockServer) error {
+	conn, err := net.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = fmt.Fprintf(conn, "INTERSECTS fexkey FENCE BOUNDS 30 -120 40 -100\r\n")
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return err
+	}
+	if res := string(buf[:n]); res != "+OK\r\n" {
+		return fmt.Errorf("expected OK, got '%v'", res)
+	}
+	rd := &fenceReader{conn, bufio.NewReader(conn)}
+
+	c, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if _, err := c.Do("SET", "fexkey", "truck", "POINT", 33.5, -112.5); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set", "detect", "enter"); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "set", "detect", "inside"); err != nil {
+		return err
+	}
+	if _, err := c.Do("FSET", "fexkey", "truck", "panic", 1, "EX", 0.2); err != nil {
+		return err
+	}
+	if err := rd.receiveExpect("command", "fset", "fields.panic", "1"); err != nil {
+		return err
+	}
+	// the field is cleared when it expires
+	if err := rd.receiveExpect("command", "fset", "id", "truck",
+		"fields.panic", ""); err != nil {
+		return err
+	}
+	_, err = c.Do("DROP", "fexkey")
+	return err
+}
+
+func fence_inactive_test(mc *mockServer) error {
+	bc, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+	if _, err := do(bc, "SETCHAN idlechan INACTIVE 0 INTERSECTS idlekey FENCE BOUNDS 30 -120 40 -100"); err == nil {
+		return errors.New("expected an error")
+	}
+	if _, err := do(bc, "SETCHAN idlechan INACTIVE 0.3 INTERSECTS idlekey FENCE DETECT enter BOUNDS 30 -120 40 -100"); err != nil {
+		return err
+	}
+	defer do(bc, "DELCHAN idlechan")
+
+	sc, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port),
+		redis.DialReadTimeout(time.Second*2))
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+	psc := redis.PubSubConn{Conn: sc}
+	if err := psc.Subscribe("idlechan"); err != nil {
+		return err
+	}
+	receiveExpect := func(valex ...string) error {
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				for i := 0; i < len(valex); i += 2 {
+					if gjson.GetBytes(v.Data, valex[i]).String() != valex[i+1] {
+						return fmt.Errorf("expected '%s'='%s', got '%s'", valex[i],
+							valex[i+1], gjson.GetBytes(v.Data, valex[i]).String())
+					}
+				}
+				return nil
+			case error:
+				return v
+			}
+		}
+	}
+
+	if _, err := do(bc, "SET idlekey truck POINT 33.5 -112.5"); err != nil {
+		return err
+	}
+	if err := receiveExpect("detect", "enter", "id", "truck"); err != nil {
+		return err
+	}
+	if err := receiveExpect("command", "inactive", "detect", "inactive",
+		"id", "truck"); err != nil {
+		return err
+	}
+	if _, err := do(bc, "SET idlekey truck POINT 33.6 -112.5"); err != nil {
+		return err
+	}
+	if err := receiveExpect("command", "set", "detect", "active",
+		"id", "truck", "object.coordinates", "[-112.5,33.6]"); err != nil {
+		return err
+	}
+	// objects outside of the fence aren't watched
+	if _, err := do(bc, "SET idlekey truck POINT 50 0"); err != nil {
+		return err
+	}
+	time.Sleep(time.Second / 2)
+	if _, err := do(bc, "SET idlekey car POINT 33.5 -112.5"); err != nil {
+		return err
+	}
+	if err := receiveExpect("detect", "enter", "id", "car"); err != nil {
+		return err
+	}
+	_, err = do(bc, "DROP idlekey")
+	return err
+}
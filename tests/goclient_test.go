@@ -0,0 +1,237 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tidwall/tile38/client"
+)
+
+func subTestGoClient(t *testing.T, mc *mockServer) {
+	runStep(t, mc, "crud", goclient_crud_test)
+	runStep(t, mc, "search", goclient_search_test)
+	runStep(t, mc, "fence", goclient_fence_test)
+	runStep(t, mc, "subscribe reconnect", goclient_subscribe_test)
+}
+
+func goclient_crud_test(mc *mockServer) error {
+	c, err := client.Dial(fmt.Sprintf("localhost:%d", mc.port), nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Set("gofleet", "truck1", client.Point(33, -115),
+		map[string]float64{"speed": 10}); err != nil {
+		return err
+	}
+	o, err := c.Get("gofleet", "truck1")
+	if err != nil {
+		return err
+	}
+	if o.Object.String() != client.Point(33, -115).String() ||
+		o.Fields["speed"] != 10 {
+		return fmt.Errorf("got %v %v", o.Object, o.Fields)
+	}
+	if _, err := c.Get("gofleet", "truck2"); err != client.ErrNotFound {
+		return fmt.Errorf("expected '%v', got '%v'", client.ErrNotFound, err)
+	}
+	if _, err := c.Get("gofleet2", "truck1"); err != client.ErrNotFound {
+		return fmt.Errorf("expected '%v', got '%v'", client.ErrNotFound, err)
+	}
+	if ok, err := c.Del("gofleet", "truck1"); !ok || err != nil {
+		return fmt.Errorf("expected true, got %v %v", ok, err)
+	}
+	if ok, err := c.Del("gofleet", "truck1"); ok || err != nil {
+		return fmt.Errorf("expected false, got %v %v", ok, err)
+	}
+	if ok, err := c.Drop("gofleet"); ok || err != nil {
+		return fmt.Errorf("expected false, got %v %v", ok, err)
+	}
+	return nil
+}
+
+func goclient_search_test(mc *mockServer) error {
+	c, err := client.Dial(fmt.Sprintf("localhost:%d", mc.port), nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer c.Drop("gofleet")
+	// more than one page of results
+	for i := 0; i < 250; i++ {
+		if err := c.Set("gofleet", fmt.Sprintf("truck%d", i),
+			client.Point(33+float64(i)/1000, -115),
+			map[string]float64{"speed": float64(i)}); err != nil {
+			return err
+		}
+	}
+	objs, err := c.SearchWithin("gofleet", client.Bounds(32, -116, 34, -114))
+	if err != nil {
+		return err
+	}
+	if len(objs) != 250 {
+		return fmt.Errorf("expected 250 objects, got %d", len(objs))
+	}
+	objs, err = c.SearchIntersects("gofleet", client.Bounds(33, -116, 33.0095, -114))
+	if err != nil {
+		return err
+	}
+	if len(objs) != 10 {
+		return fmt.Errorf("expected 10 objects, got %d", len(objs))
+	}
+	objs, err = c.SearchNearby("gofleet", 33.1, -115, 1000)
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 || objs[0].ID != "truck100" || objs[0].Distance != 0 ||
+		objs[0].Fields["speed"] != 100 || objs[1].Distance == 0 {
+		return fmt.Errorf("unexpected nearby objects: %v", objs[:2])
+	}
+	return nil
+}
+
+func goclient_fence_test(mc *mockServer) error {
+	c, err := client.Dial(fmt.Sprintf("localhost:%d", mc.port), nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	defer c.Drop("gofleet")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.Fence(ctx, "gofleet", client.Bounds(33, -116, 34, -114),
+		&client.FenceOptions{Detect: []string{"enter", "exit"}})
+	if err != nil {
+		return err
+	}
+	c.Set("gofleet", "truck1", client.Point(33.5, -115), map[string]float64{"speed": 5})
+	c.Set("gofleet", "truck1", client.Point(35, -115), nil)
+	for _, detect := range []string{"enter", "exit"} {
+		select {
+		case ev := <-events:
+			if ev.Detect != detect || ev.ID != "truck1" || ev.Key != "gofleet" ||
+				ev.Fields["speed"] != 5 || ev.Object == nil || ev.Time.IsZero() {
+				return fmt.Errorf("unexpected event: %s", ev.JSON)
+			}
+		case <-time.After(time.Second):
+			return errors.New("timeout")
+		}
+	}
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			return errors.New("expected a closed channel")
+		}
+	case <-time.After(time.Second):
+		return errors.New("timeout")
+	}
+	return nil
+}
+
+// goProxy forwards connections to the server, and it can break them.
+type goProxy struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newGoProxy(addr string) (*goProxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &goProxy{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			sconn, err := net.Dial("tcp", addr)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			p.mu.Lock()
+			p.conns = append(p.conns, conn, sconn)
+			p.mu.Unlock()
+			go io.Copy(conn, sconn)
+			go io.Copy(sconn, conn)
+		}
+	}()
+	return p, nil
+}
+
+func (p *goProxy) breakConns() {
+	p.mu.Lock()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+	p.mu.Unlock()
+}
+
+func goclient_subscribe_test(mc *mockServer) error {
+	p, err := newGoProxy(fmt.Sprintf("localhost:%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer p.ln.Close()
+	c, err := client.Dial(p.ln.Addr().String(), &client.Options{
+		ReconnectDelay: time.Millisecond * 50,
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := mc.DoBatch([][]interface{}{
+		{"SETCHAN", "gochan", "WITHIN", "gofleet", "FENCE", "DETECT", "enter",
+			"BOUNDS", 33, -116, 34, -114}, {1},
+	}); err != nil {
+		return err
+	}
+	defer mc.Do("DELCHAN", "gochan")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.Subscribe(ctx, "gochan")
+	if err != nil {
+		return err
+	}
+	expect := func(id string) error {
+		// sets the object until the subscription is back after a reconnect
+		for i := 0; i < 40; i++ {
+			mc.Do("SET", "gofleet", id, "POINT", 35, -115)
+			mc.Do("SET", "gofleet", id, "POINT", 33.5, -115)
+			select {
+			case ev := <-events:
+				if ev.ID != id {
+					// left over from before the reconnect
+					continue
+				}
+				if ev.Channel != "gochan" || ev.Hook != "gochan" ||
+					ev.Detect != "enter" {
+					return fmt.Errorf("unexpected event: %s", ev.JSON)
+				}
+				return nil
+			case <-time.After(time.Millisecond * 50):
+			}
+		}
+		return errors.New("timeout")
+	}
+	if err := expect("truck1"); err != nil {
+		return err
+	}
+	p.breakConns()
+	if err := expect("truck2"); err != nil {
+		return err
+	}
+	_, err = mc.Do("DROP", "gofleet")
+	return err
+}
@@ -6,6 +6,9 @@ func subTestJSON(t *testing.T, mc *mockServer) {
 	runStep(t, mc, "basic", json_JSET_basic_test)
 	runStep(t, mc, "geojson", json_JSET_geojson_test)
 	runStep(t, mc, "number", json_JSET_number_test)
+	runStep(t, mc, "jsonpath", json_JSONPath_test)
+	runStep(t, mc, "incr", json_JINCRBY_test)
+	runStep(t, mc, "array", json_JAPPEND_test)
 
 }
 func json_JSET_basic_test(mc *mockServer) error {
@@ -54,3 +57,55 @@ func json_JSET_number_test(mc *mockServer) error {
 		{"JGET", "mykey", "myid1"}, {`{"hello":1.0e10}`},
 	})
 }
+
+func json_JSONPath_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"JSET", "mykey", "myid2", "trucks", `[{"id":"t1","speed":20},{"id":"t2","speed":65},{"id":"t3","speed":80}]`, "RAW"}, {"OK"},
+		{"JGET", "mykey", "myid2", "$.trucks[?(@.speed>50)].id"}, {`["t2","t3"]`},
+		{"JGET", "mykey", "myid2", "$.trucks[-1:].speed", "RAW"}, {`[80]`},
+		{"JGET", "mykey", "myid2", "$.trucks[?(@.speed>100)]"}, {nil},
+		{"JGET", "mykey", "myid2", "$.trucks[?(@.speed>"}, {"ERR invalid jsonpath"},
+		{"JSET", "mykey", "myid2", "$.trucks[?(@.speed>50)].fast", "true"}, {"OK"},
+		{"JGET", "mykey", "myid2", "$..fast"}, {`[true,true]`},
+		{"JSET", "mykey", "myid2", "$.trucks[?(@.speed>100)].fast", "true"}, {"ERR path not found"},
+		{"JDEL", "mykey", "myid2", "$.trucks[?(@.fast)]"}, {1},
+		{"JGET", "mykey", "myid2"}, {`{"trucks":[{"id":"t1","speed":20}]}`},
+		{"JDEL", "mykey", "myid2", "$.trucks[?(@.fast)]"}, {0},
+	})
+}
+
+func json_JINCRBY_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"JINCRBY", "mykey", "myid3", "stats.count", 5}, {"5"},
+		{"JINCRBY", "mykey", "myid3", "stats.count", 2}, {"7"},
+		{"JDECRBY", "mykey", "myid3", "stats.count", 10}, {"-3"},
+		{"JINCRBY", "mykey", "myid3", "stats.count", 0.5}, {"-2.5"},
+		{"JINCRBY", "mykey", "myid3", "stats.big", "9007199254740993"}, {"9007199254740993"},
+		{"JINCRBY", "mykey", "myid3", "stats.big", 1}, {"9007199254740994"},
+		{"JGET", "mykey", "myid3"}, {`{"stats":{"count":-2.5,"big":9007199254740994}}`},
+		{"JSET", "mykey", "myid3", "name", "tom"}, {"OK"},
+		{"JINCRBY", "mykey", "myid3", "name", 1}, {"ERR value is not a number"},
+		{"JINCRBY", "mykey", "myid3", "stats.count", "one"}, {"ERR invalid argument 'one'"},
+		{"SET", "mykey", "myid4", "POINT", 33, -115}, {"OK"},
+		{"JINCRBY", "mykey", "myid4", "coordinates.1", 1}, {"34"},
+		{"GET", "mykey", "myid4", "POINT"}, {"[34 -115]"},
+	})
+}
+
+func json_JAPPEND_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"JAPPEND", "mykey", "myid5", "tags", "hot"}, {1},
+		{"JAPPEND", "mykey", "myid5", "tags", 10}, {2},
+		{"JAPPEND", "mykey", "myid5", "tags", `{"a":1}`, "RAW"}, {3},
+		{"JAPPEND", "mykey", "myid5", "tags", `{"a":`, "RAW"}, {`ERR invalid argument '{"a":'`},
+		{"JINSERT", "mykey", "myid5", "tags", 0, "first"}, {4},
+		{"JINSERT", "mykey", "myid5", "tags", -1, "10", "STR"}, {5},
+		{"JINSERT", "mykey", "myid5", "tags", 5, "last"}, {6},
+		{"JINSERT", "mykey", "myid5", "tags", 7, "last"}, {"ERR index out of range"},
+		{"JGET", "mykey", "myid5"}, {`{"tags":["first","hot",10,"10",{"a":1},"last"]}`},
+		{"JSET", "mykey", "myid5", "name", "tom"}, {"OK"},
+		{"JAPPEND", "mykey", "myid5", "name", "x"}, {"ERR value is not an array"},
+		{"JINSERT", "mykey", "myid5", "empty", 0, "x"}, {1},
+		{"JGET", "mykey", "myid5", "empty"}, {`["x"]`},
+	})
+}
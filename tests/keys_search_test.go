@@ -1,10 +1,17 @@
 package tests
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
 	"sort"
 	"testing"
 	"time"
@@ -20,6 +27,7 @@ func subTestSearch(t *testing.T, mc *mockServer) {
 	runStep(t, mc, "NEARBY_SPARSE", keys_NEARBY_SPARSE_test)
 	runStep(t, mc, "WITHIN_CIRCLE", keys_WITHIN_CIRCLE_test)
 	runStep(t, mc, "INTERSECTS_CIRCLE", keys_INTERSECTS_CIRCLE_test)
+	runStep(t, mc, "CIRCLE_OBJECT", keys_CIRCLE_OBJECT_test)
 	runStep(t, mc, "WITHIN", keys_WITHIN_test)
 	runStep(t, mc, "WITHIN_CURSOR", keys_WITHIN_CURSOR_test)
 	runStep(t, mc, "WITHIN_CLIPBY", keys_WITHIN_CLIPBY_test)
@@ -28,8 +36,27 @@ func subTestSearch(t *testing.T, mc *mockServer) {
 	runStep(t, mc, "INTERSECTS_CLIPBY", keys_INTERSECTS_CLIPBY_test)
 	runStep(t, mc, "SCAN_CURSOR", keys_SCAN_CURSOR_test)
 	runStep(t, mc, "SEARCH_CURSOR", keys_SEARCH_CURSOR_test)
+	runStep(t, mc, "WITHINCOMPLETE", keys_WITHINCOMPLETE_test)
+	runStep(t, mc, "COUNT_BOUNDS", keys_COUNT_BOUNDS_test)
 	runStep(t, mc, "MATCH", keys_MATCH_test)
 	runStep(t, mc, "FIELDS", keys_FIELDS_search_test)
+	runStep(t, mc, "NDJSON", keys_NDJSON_test)
+	runStep(t, mc, "PRECISION_SIMPLIFY", keys_PRECISION_SIMPLIFY_test)
+	runStep(t, mc, "FLATGEOBUF", keys_FLATGEOBUF_test)
+	runStep(t, mc, "SELECT", keys_SELECT_test)
+	runStep(t, mc, "SNAPSHOT", keys_SNAPSHOT_test)
+	runStep(t, mc, "WARMUP", keys_WARMUP_test)
+	runStep(t, mc, "MREAD", keys_MREAD_test)
+	runStep(t, mc, "ZM", keys_ZM_test)
+	runStep(t, mc, "CLUSTER", keys_CLUSTER_test)
+	runStep(t, mc, "HULL", keys_HULL_test)
+	runStep(t, mc, "CENTROID", keys_CENTROID_test)
+	runStep(t, mc, "DENSITY", keys_DENSITY_test)
+	runStep(t, mc, "ROUTE", keys_ROUTE_test)
+	runStep(t, mc, "ROUTE MATRIX", keys_ROUTE_MATRIX_test)
+	runStep(t, mc, "LOOKUP", keys_LOOKUP_test)
+	runStep(t, mc, "AREACONFIG", keys_AREACONFIG_test)
+	runStep(t, mc, "PREPARE", keys_PREPARE_test)
 }
 
 func keys_KNN_basic_test(mc *mockServer) error {
@@ -497,6 +524,25 @@ func keys_INTERSECTS_CIRCLE_test(mc *mockServer) error {
 	})
 }
 
+func keys_CIRCLE_OBJECT_test(mc *mockServer) error {
+	circle := `{"type":"Feature","geometry":{"type":"Point","coordinates":[10,70]},"properties":{"type":"Circle","radius":100000,"radius_units":"m"}}`
+	return mc.DoBatch([][]interface{}{
+		{"SET", "ckey", "zone", "OBJECT", circle}, {"OK"},
+		{"GET", "ckey", "zone"}, {circle},
+		// the widest point of the circle is north of its center
+		{"INTERSECTS", "ckey", "IDS", "BOUNDS", 70.019, 12.629, 70.02, 12.6295}, {"[0 [zone]]"},
+		{"INTERSECTS", "ckey", "IDS", "BOUNDS", 70.019, 12.631, 70.02, 12.632}, {"[0 []]"},
+		{"WITHIN", "ckey", "IDS", "BOUNDS", 69, 7, 71, 13}, {"[0 [zone]]"},
+		{"WITHIN", "ckey", "IDS", "BOUNDS", 69, 7, 71, 12.629}, {"[0 []]"},
+		{"WITHIN", "ckey", "IDS", "CIRCLE", 70, 10, 101000}, {"[0 [zone]]"},
+		{"NEARBY", "ckey", "IDS", "POINT", 70.019, 12.629, 1000}, {"[0 [zone]]"},
+		{"SET", "ckey", "pt", "POINT", 70, 10}, {"OK"},
+		{"DEL", "ckey", "zone"}, {1},
+		{"INTERSECTS", "ckey", "IDS", "BOUNDS", 69, 7, 71, 13}, {"[0 [pt]]"},
+		{"DROP", "ckey"}, {1},
+	})
+}
+
 func keys_SCAN_CURSOR_test(mc *mockServer) error {
 	return mc.DoBatch([][]interface{}{
 		{"SET", "mykey", "id1", "FIELD", "foo", 1, "STRING", "bar1"}, {"OK"},
@@ -654,6 +700,56 @@ func keys_FIELDS_search_test(mc *mockServer) error {
 	})
 }
 
+func keys_NDJSON_test(mc *mockServer) error {
+	if err := mc.DoBatch([][]interface{}{
+		{"SET", "mykey", "1", "FIELD", "speed", 10, "FIELD", "wind.max", 3, "POINT", 33, -112}, {"OK"},
+		{"SET", "mykey", "2", "POINT", 34, -113}, {"OK"},
+		{"SET", "mykey", "3", "OBJECT", `{"type":"Feature","geometry":{"type":"Point","coordinates":[-114,35]},"properties":{"name":"three"}}`}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("http://localhost:%d/SCAN+mykey", mc.port), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		return fmt.Errorf("expected '%s', got '%s'", "application/x-ndjson", ct)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	expect := `{"type":"Feature","id":"1","geometry":{"type":"Point","coordinates":[-112,33]},"properties":{"speed":10,"wind.max":3}}` + "\n" +
+		`{"type":"Feature","id":"2","geometry":{"type":"Point","coordinates":[-113,34]},"properties":{"speed":0,"wind.max":0}}` + "\n" +
+		`{"type":"Feature","id":"3","geometry":{"type":"Point","coordinates":[-114,35]},"properties":{"name":"three","speed":0,"wind.max":0}}` + "\n"
+	if string(data) != expect {
+		return fmt.Errorf("expected '%s', got '%s'", expect, data)
+	}
+	return nil
+}
+
+func keys_PRECISION_SIMPLIFY_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "mykey", "1", "POINT", 33.123456789, -112.987654321}, {"OK"},
+		{"SET", "mykey", "2", "OBJECT", `{"type":"LineString","coordinates":[[-112,33],[-111.5,33.0001],[-111,33]]}`}, {"OK"},
+		{"SCAN", "mykey", "PRECISION", 3, "OBJECTS"}, {
+			`[0 [[1 {"type":"Point","coordinates":[-112.988,33.123]}] [2 {"type":"LineString","coordinates":[[-112,33],[-111.5,33],[-111,33]]}]]]`},
+		{"SCAN", "mykey", "SIMPLIFY", 0.01, "OBJECTS"}, {
+			`[0 [[1 {"type":"Point","coordinates":[-112.987654321,33.123456789]}] [2 {"type":"LineString","coordinates":[[-112,33],[-111,33]]}]]]`},
+		{"SCAN", "mykey", "PRECISION", 2, "POINTS"}, {
+			`[0 [[1 [33.12 -112.99]] [2 [33 -111.5]]]]`},
+		{"SCAN", "mykey", "PRECISION", 16, "OBJECTS"}, {"ERR invalid argument '16'"},
+		{"SCAN", "mykey", "SIMPLIFY", -1, "OBJECTS"}, {"ERR invalid argument '-1'"},
+	})
+}
+
 // match sorts the response and compares to the expected input
 func match(expectIn string) func(org, v interface{}) (resp, expect interface{}) {
 	return func(v, org interface{}) (resp, expect interface{}) {
@@ -680,3 +776,451 @@ func keys_KNN_bench(mc *mockServer) error {
 		"POINT", lat, lon)
 	return err
 }
+
+func keys_FLATGEOBUF_test(mc *mockServer) error {
+	if err := mc.DoBatch([][]interface{}{
+		{"SET", "fgb", "1", "FIELD", "speed", 10, "POINT", 33, -112}, {"OK"},
+		{"SET", "fgb", "2", "POINT", 34, -113}, {"OK"},
+		{"SET", "fgb", "3", "STRING", "hello"}, {"OK"},
+		{"NEARBY", "fgb", "FENCE", "FLATGEOBUF", "POINT", 33, -112, 1000}, {"ERR invalid argument 'FLATGEOBUF'"},
+	}); err != nil {
+		return err
+	}
+	magic := []byte{0x66, 0x67, 0x62, 0x03, 0x66, 0x67, 0x62, 0x00}
+	check := func(data []byte) error {
+		if !bytes.HasPrefix(data, magic) {
+			return fmt.Errorf("expected flatgeobuf magic, got '%v'", data)
+		}
+		// the string is not a geometry and is left out
+		hsize := binary.LittleEndian.Uint32(data[8:])
+		// 2 leaves and the root
+		features := data[12+hsize+3*40:]
+		var n int
+		for len(features) > 0 {
+			fsize := binary.LittleEndian.Uint32(features)
+			features = features[4+fsize:]
+			n++
+		}
+		if n != 2 {
+			return fmt.Errorf("expected 2 features, got %d", n)
+		}
+		return nil
+	}
+	v, err := redis.Values(mc.Do("SCAN", "fgb", "FLATGEOBUF"))
+	if err != nil {
+		return err
+	}
+	if len(v) != 2 {
+		return fmt.Errorf("expected 2 values, got %d", len(v))
+	}
+	data, _ := v[1].([]byte)
+	if err := check(data); err != nil {
+		return err
+	}
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/SCAN+fgb+FLATGEOBUF", mc.port))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	jdata, err := base64.StdEncoding.DecodeString(
+		gjson.GetBytes(body, "flatgeobuf").String())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(jdata, data) {
+		return fmt.Errorf("expected json and resp output to match")
+	}
+	return nil
+}
+
+func keys_SELECT_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "sel", "1", "FIELD", "speed", 10, "FIELD", "heading", 90, "FIELD", "fuel", 50, "POINT", 33, -112}, {"OK"},
+		{"SET", "sel", "2", "FIELD", "fuel", 20, "POINT", 34, -113}, {"OK"},
+		{"SCAN", "sel", "SELECT", "fuel,speed", "POINTS"}, {
+			`[0 [[1 [33 -112] [fuel 50 speed 10]] [2 [34 -113] [fuel 20]]]]`},
+		{"SCAN", "sel", "SELECT", "heading", "IDS"}, {
+			`[0 [[1 [heading 90]] [2]]]`},
+		{"SCAN", "sel", "SELECT", "speed, missing", "IDS"}, {
+			`[0 [[1 [speed 10]] [2]]]`},
+		{"SCAN", "sel", "IDS"}, {`[0 [1 2]]`},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"SCAN", "sel", "SELECT", "fuel", "IDS"}, {
+			`{"ok":true,"fields":["fuel"],"ids":[{"id":"1","fields":[50]},{"id":"2","fields":[20]}],"count":2,"cursor":0}`},
+		{"OUTPUT", "resp"}, {`OK`},
+		{"SCAN", "sel", "SELECT", "speed", "NOFIELDS", "IDS"}, {"ERR SELECT cannot be used with NOFIELDS"},
+		{"SCAN", "sel", "SELECT", "speed,", "IDS"}, {"ERR invalid argument 'speed,'"},
+		{"SCAN", "sel", "SELECT", "speed", "SELECT", "fuel", "IDS"}, {"ERR duplicate argument 'SELECT'"},
+	})
+}
+
+func keys_SNAPSHOT_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "snapshotepoch", 50}, {"OK"},
+		{"CONFIG", "GET", "snapshotepoch"}, {"[snapshotepoch 50]"},
+		{"SET", "snap", "1", "POINT", 33, -115}, {"OK"},
+		{"WITHIN", "snap", "IDS", "BOUNDS", 32, -116, 34, -114}, {"[0 [1]]"},
+		{"SET", "snap", "2", "POINT", 33.5, -115.5}, {"OK"},
+		// scans are not served by snapshots
+		{"SCAN", "snap", "IDS"}, {"[0 [1 2]]"},
+		{time.Millisecond * 500}, {},
+		{"WITHIN", "snap", "IDS", "BOUNDS", 32, -116, 34, -114}, {"[0 [1 2]]"},
+		{"NEARBY", "snap", "IDS", "POINT", 33, -115}, {"[0 [1 2]]"},
+		{"CONFIG", "SET", "snapshotepoch", "-1"}, {"ERR Invalid argument '-1' for CONFIG SET 'snapshotepoch'"},
+		{"CONFIG", "SET", "snapshotepoch", 0}, {"OK"},
+	})
+}
+
+func keys_WITHINCOMPLETE_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "mykey", "a", "POINT", 33, -115}, {"OK"},
+		{"SET", "mykey", "b", "POINT", 33.001, -115}, {"OK"},
+		{"SET", "mykey", "c", "POINT", 33.002, -115}, {"OK"},
+		{"SCAN", "mykey", "WITHINCOMPLETE", 60000, "IDS"}, {"[0 [a b c] 0]"},
+		// a budget that's over after the first object
+		{"SCAN", "mykey", "WITHINCOMPLETE", 0.000001, "IDS"}, {"[1 [a] 1]"},
+		{"SCAN", "mykey", "CURSOR", 1, "WITHINCOMPLETE", 0.000001, "IDS"}, {"[2 [b] 1]"},
+		{"SCAN", "mykey", "CURSOR", 2, "WITHINCOMPLETE", 0.000001, "IDS"}, {"[0 [c] 0]"},
+		{"WITHIN", "mykey", "WITHINCOMPLETE", 0.000001, "IDS", "BOUNDS", 32, -116, 34, -114}, {"[1 [a] 1]"},
+		{"NEARBY", "mykey", "WITHINCOMPLETE", 0.000001, "IDS", "POINT", 33.002, -115}, {"[1 [c] 1]"},
+		{"NEARBY", "mykey", "CURSOR", 1, "WITHINCOMPLETE", 60000, "IDS", "POINT", 33.002, -115}, {"[0 [b a] 0]"},
+		{"SCAN", "mykey", "WITHINCOMPLETE", 0, "IDS"}, {"ERR invalid argument '0'"},
+		{"SCAN", "mykey", "WITHINCOMPLETE", 1, "COUNT"}, {"ERR WITHINCOMPLETE is only allowed for IDS, OBJECTS, POINTS, BOUNDS and HASHES"},
+		{"WITHIN", "mykey", "FENCE", "WITHINCOMPLETE", 1, "BOUNDS", 32, -116, 34, -114}, {"ERR WITHINCOMPLETE is not allowed when FENCE is specified"},
+		{"DELWHERE", "SCAN", "mykey", "WITHINCOMPLETE", 1}, {"ERR WITHINCOMPLETE is not allowed for DELWHERE"},
+		{"DROP", "mykey"}, {1},
+	})
+}
+
+func keys_COUNT_BOUNDS_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "mykey", "a", "POINT", 33, -115}, {"OK"},
+		{"SET", "mykey", "b", "POINT", 34, -116}, {"OK"},
+		{"SET", "mykey", "c", "OBJECT", `{"type":"LineString","coordinates":[[-117,35],[-118,36]]}`}, {"OK"},
+		{"SET", "mykey", "d", "STRING", "hello"}, {"OK"},
+		{"INTERSECTS", "mykey", "COUNT", "BOUNDS", -90, -180, 90, 180}, {"3"},
+		{"WITHIN", "mykey", "COUNT", "BOUNDS", -90, -180, 90, 180}, {"3"},
+		{"WITHIN", "mykey", "COUNT", "BOUNDS", 32, -117, 35, -114}, {"2"},
+		{"WITHIN", "mykey", "WHERE", "speed", 1, 2, "COUNT", "BOUNDS", -90, -180, 90, 180}, {"0"},
+		{"WITHIN", "mykey", "MATCH", "a", "COUNT", "BOUNDS", -90, -180, 90, 180}, {"1"},
+		{"DROP", "mykey"}, {1},
+	})
+}
+
+func keys_WARMUP_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "wu:a", "1", "POINT", 33, -115}, {"OK"},
+		{"SET", "wu:a", "2", "POINT", 33.5, -115.5}, {"OK"},
+		{"SET", "wu:b", "1", "STRING", "hello"}, {"OK"},
+		{"WARMUP", "wu:*"}, {2},
+		{"WARMUP", "wu:a", "CLUSTERS"}, {1},
+		{"WARMUP", "wu:x"}, {0},
+		{"WARMUP", "wu:*", "CLUSTERS", "x"}, {"ERR wrong number of arguments for 'warmup' command"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"WARMUP", "wu:*"}, {`{"ok":true,"keys":2,"objects":3}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		// the snapshot of a warmed up key is made by the warmup
+		{"CONFIG", "SET", "snapshotepoch", 10000}, {"OK"},
+		{"WARMUP", "wu:a"}, {1},
+		{"SET", "wu:a", "3", "POINT", 33.2, -115.2}, {"OK"},
+		{"WITHIN", "wu:a", "COUNT", "BOUNDS", 32, -116, 34, -114}, {2},
+		{"CONFIG", "SET", "snapshotepoch", 0}, {"OK"},
+		{"CONFIG", "SET", "warmup", "wu:* zones"}, {"OK"},
+		{"CONFIG", "GET", "warmup"}, {"[warmup wu:* zones]"},
+		{"CONFIG", "SET", "warmup", ""}, {"OK"},
+		{"DROP", "wu:a"}, {1},
+		{"DROP", "wu:b"}, {1},
+	})
+}
+
+func keys_MREAD_test(mc *mockServer) error {
+	if err := mc.DoBatch([][]interface{}{
+		{"SET", "mr:vehicles", "v1", "POINT", 33, -115}, {"OK"},
+		{"SET", "mr:vehicles", "v2", "POINT", 33.5, -115.5}, {"OK"},
+		{"SET", "mr:zones", "z1", "BOUNDS", 32, -116, 34, -114}, {"OK"},
+		{"MREAD", "NEARBY", "mr:vehicles", "IDS", "POINT", 33, -115, ";",
+			"SCAN", "mr:zones", "IDS", ";",
+			"WITHIN", "mr:vehicles", "COUNT", "BOUNDS", 32, -116, 34, -114, ";",
+			"SCAN", "mr:missing", "IDS"}, {"[[0 [v1 v2]] [0 [z1]] 2 [0 []]]"},
+		{"MREAD", "SCAN", "mr:zones", "IDS", ";", "SCAN", "mr:zones", "LIMIT", "x"}, {"[[0 [z1]] invalid argument 'x']"},
+		{"MREAD"}, {"ERR wrong number of arguments for 'mread' command"},
+		{"MREAD", "SCAN", "mr:zones", ";", ";", "SCAN", "mr:zones"}, {"ERR wrong number of arguments for 'mread' command"},
+		{"MREAD", "SET", "mr:zones", "z2", "POINT", 1, 2}, {"ERR invalid argument 'SET'"},
+		{"MREAD", "WITHIN", "mr:vehicles", "IDS", "GET", "mr:zones", "z1"}, {"ERR GET is not allowed in MREAD"},
+		{"MREAD", "WITHIN", "mr:vehicles", "FENCE", "BOUNDS", 32, -116, 34, -114}, {"ERR FENCE is not allowed in MREAD"},
+	}); err != nil {
+		return err
+	}
+	if _, err := mc.Do("OUTPUT", "json"); err != nil {
+		return err
+	}
+	defer mc.Do("OUTPUT", "resp")
+	res, err := redis.String(mc.Do("MREAD", "SCAN", "mr:vehicles", "IDS", ";",
+		"SCAN", "mr:zones", "LIMIT", "x"))
+	if err != nil {
+		return err
+	}
+	if gjson.Get(res, "results.#").Int() != 2 ||
+		gjson.Get(res, "results.0.ids").String() != `["v1","v2"]` ||
+		gjson.Get(res, "results.1.ok").Bool() ||
+		gjson.Get(res, "results.1.err").String() != "invalid argument 'x'" {
+		return fmt.Errorf("unexpected MREAD result: %s", res)
+	}
+	return nil
+}
+
+func keys_ZM_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "zm", "path1", "OBJECT", `{"type":"LineString","coordinates":[[0,0,10,100],[4,0,50,500]]}`}, {"OK"},
+		{"SET", "zm", "path2", "OBJECT", `{"type":"LineString","coordinates":[[0,1,1000],[4,1,1000]]}`}, {"OK"},
+		{"SET", "zm", "pt", "POINT", 0.5, 0.5, 20}, {"OK"},
+		{"GET", "zm", "path1"}, {`{"type":"LineString","coordinates":[[0,0,10,100],[4,0,50,500]]}`},
+		{"INTERSECTS", "zm", "CLIP", "OBJECTS", "BOUNDS", -1, 1, 0.5, 3}, {
+			`[0 [[path1 {"type":"LineString","coordinates":[[1,0,20,200],[3,0,40,400]]}]]]`},
+		{"INTERSECTS", "zm", "WHERE", "z", 500, 2000, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [path2]]"},
+		{"INTERSECTS", "zm", "WHERE", "z", 15, 25, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [pt]]"},
+		{"INTERSECTS", "zm", "WHERE", "z", 40, 60, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [path1]]"},
+
+		// the same searches with the altitude index
+		{"KEYCONFIG", "SET", "zm", "zindex", "on"}, {"OK"},
+		{"KEYCONFIG", "GET", "zm"}, {"[zindex on]"},
+		{"INTERSECTS", "zm", "WHERE", "z", 500, 2000, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [path2]]"},
+		{"INTERSECTS", "zm", "WHERE", "z", 15, 25, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [pt]]"},
+		{"INTERSECTS", "zm", "WHERE", "z", 40, 60, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [path1]]"},
+		{"INTERSECTS", "zm", "WHERE", "z", "(20", 60, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [path1]]"},
+		{"WITHIN", "zm", "WHERE", "z", 0, 30, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [path1 pt]]"},
+		{"SET", "zm", "pt", "POINT", 0.5, 0.5, 1500}, {"OK"},
+		{"WITHIN", "zm", "WHERE", "z", 1000, 2000, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [path2 pt]]"},
+		{"DEL", "zm", "path2"}, {"1"},
+		{"WITHIN", "zm", "WHERE", "z", 1000, 2000, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [pt]]"},
+		{"KEYCONFIG", "SET", "zm", "zindex", "maybe"}, {"ERR invalid argument 'maybe'"},
+		{"KEYCONFIG", "DEL", "zm", "zindex"}, {"OK"},
+		{"WITHIN", "zm", "WHERE", "z", 1000, 2000, "IDS", "BOUNDS", -10, -10, 10, 10}, {"[0 [pt]]"},
+	})
+}
+
+func keys_CLUSTER_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "cl", "a", "FIELD", "speed", 10, "POINT", 33.001, -112.001}, {"OK"},
+		{"SET", "cl", "b", "POINT", 33.002, -112.002}, {"OK"},
+		{"SET", "cl", "c", "FIELD", "speed", 20, "POINT", 33.003, -112.003}, {"OK"},
+		{"SET", "cl", "d", "POINT", 50, 10}, {"OK"},
+		{"WITHIN", "cl", "CLUSTER", 4, "BOUNDS", -90, -180, 90, 180}, {"[0 [[<nil> 3 [33.00200000377839 -112.00200000000001] [[33.001 -112.003] [33.003 -112.001]] 14] [d 1 [50 10] [[50 10] [50 10]] 0]]]"},
+		{"INTERSECTS", "cl", "CLUSTER", 4, "BOUNDS", 40, 0, 60, 20}, {"[0 [[d 1 [50 10] [[50 10] [50 10]] 0]]]"},
+		{"WITHIN", "cl", "CLUSTER", 20, "BOUNDS", 33, -113, 34, -112}, {"[0 [[a 1 [33.001 -112.001] [[33.001 -112.001] [33.001 -112.001]] 0] [b 1 [33.002 -112.002] [[33.002 -112.002] [33.002 -112.002]] 0] [c 1 [33.003 -112.003] [[33.003 -112.003] [33.003 -112.003]] 0]]]"},
+		{"SET", "cl", "e", "POINT", 50.0001, 10.0001}, {"OK"},
+		{"INTERSECTS", "cl", "CLUSTER", 4, "BOUNDS", 40, 0, 60, 20}, {"[0 [[<nil> 2 [50.00005000002599 10.000050000000025] [[50 10] [50.0001 10.0001]] 17]]]"},
+		{"WITHIN", "cl", "WHERE", "speed", 1, 100, "CLUSTER", 4, "BOUNDS", -90, -180, 90, 180}, {"[0 [[<nil> 2 [33.002000005667576 -112.00200000000001] [[33.001 -112.003] [33.003 -112.001]] 14]]]"},
+		{"WITHIN", "cl", "CLUSTER", 31, "BOUNDS", -90, -180, 90, 180}, {"ERR invalid argument '31'"},
+		{"WITHIN", "cl", "CLUSTER", "BOUNDS", -90, -180, 90, 180}, {"ERR invalid argument 'BOUNDS'"},
+		{"NEARBY", "cl", "CLUSTER", 4, "POINT", 33, -112}, {"ERR invalid argument 'CLUSTER'"},
+		{"SCAN", "cl", "CLUSTER", 4}, {"ERR invalid argument 'CLUSTER'"},
+	})
+}
+
+func keys_HULL_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "hl", "a", "POINT", 0, 0}, {"OK"},
+		{"SET", "hl", "b", "POINT", 1, 0}, {"OK"},
+		{"SET", "hl", "c", "POINT", 1, 1}, {"OK"},
+		{"SET", "hl", "d", "POINT", 0.5, 0.5}, {"OK"},
+		{"SCAN", "hl", "HULL"}, {`[0 {"type":"Polygon","coordinates":[[[0,0],[1,1],[0,1],[0,0]]]}]`},
+		{"WITHIN", "hl", "HULL", "BOUNDS", 0, 0, 0.6, 0.6}, {`[0 {"type":"LineString","coordinates":[[0,0],[0.5,0.5]]}]`},
+		{"NEARBY", "hl", "LIMIT", 1, "HULL", "POINT", 0, 0}, {`[0 {"type":"Point","coordinates":[0,0]}]`},
+		{"INTERSECTS", "hl", "HULL", "BOUNDS", 10, 10, 11, 11}, {"[0 nil]"},
+		// a C, which the concave hull follows
+		{"SET", "hl", "e", "OBJECT", `{"type":"Polygon","coordinates":[[[0,2],[3,2],[3,3],[1,3],[1,4],[3,4],[3,5],[0,5],[0,2]]]}`}, {"OK"},
+		{"INTERSECTS", "hl", "HULL", "GET", "hl", "e"}, {`[0 {"type":"Polygon","coordinates":[[[0,2],[3,2],[3,5],[0,5],[0,2]]]}]`},
+		{"INTERSECTS", "hl", "HULL", "CONCAVE", 100000, "GET", "hl", "e"}, {`[0 {"type":"Polygon","coordinates":[[[0,2],[0.75,2],[1.5,2],[2.25,2],[3,2],` +
+			`[3,2.5],[3,3],[2.3333333333333335,3],[1.6666666666666667,3],[1,3.5],[1.6666666666666665,4],` +
+			`[2.333333333333333,4],[3,4],[3,4.5],[3,5],[2.25,5],[1.5,5],[0.75,5],[0,5],[0,4.25],[0,3.5],[0,2.75],[0,2]]]}]`},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"SCAN", "hl", "HULL"}, {`{"ok":true,"hull":{"type":"Polygon","coordinates":[[[0,0],[3,2],[3,5],[0,5],[0,0]]]},"count":5,"cursor":0}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"SCAN", "hl", "HULL", "CONCAVE", 0}, {"ERR invalid argument '0'"},
+		{"SCAN", "hl", "HULL", "CONCAVE"}, {"ERR wrong number of arguments for 'scan' command"},
+		{"NEARBY", "hl", "FENCE", "HULL", "POINT", 0, 0, 100}, {"ERR invalid argument 'HULL'"},
+	})
+}
+
+func keys_CENTROID_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "ct", "a", "FIELD", "riders", 1, "POINT", 0, 0}, {"OK"},
+		{"SET", "ct", "b", "FIELD", "riders", 3, "POINT", 0, 2}, {"OK"},
+		{"SET", "ct", "c", "STRING", "depot"}, {"OK"},
+		{"SCAN", "ct", "PRECISION", 6, "CENTROID"}, {`[0 {"type":"Point","coordinates":[1,0]} 2]`},
+		{"SCAN", "ct", "PRECISION", 6, "CENTROID", "WEIGHT", "riders"}, {`[0 {"type":"Point","coordinates":[1.500038,0]} 4]`},
+		{"NEARBY", "ct", "LIMIT", 1, "CENTROID", "POINT", 0, 0}, {`[0 {"type":"Point","coordinates":[0,0]} 1]`},
+		{"WITHIN", "ct", "CENTROID", "BOUNDS", 10, 10, 11, 11}, {"[0 nil 0]"},
+		{"SET", "am", "a", "POINT", 10, 179}, {"OK"},
+		{"SET", "am", "b", "POINT", 10, -179}, {"OK"},
+		{"SCAN", "am", "PRECISION", 6, "CENTROID"}, {`[0 {"type":"Point","coordinates":[180,10.001493]} 2]`},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"SCAN", "ct", "PRECISION", 6, "CENTROID", "WEIGHT", "riders"}, {`{"ok":true,"centroid":{"type":"Point","coordinates":[1.500038,0]},"weight":4,"count":3,"cursor":0}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"SCAN", "ct", "CENTROID", "WEIGHT"}, {"ERR wrong number of arguments for 'scan' command"},
+		{"NEARBY", "ct", "FENCE", "CENTROID", "POINT", 0, 0, 100}, {"ERR invalid argument 'CENTROID'"},
+	})
+}
+
+func keys_DENSITY_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "dn", "a", "FIELD", "speed", 10, "POINT", 33.75, -112.75}, {"OK"},
+		{"SET", "dn", "b", "FIELD", "speed", 5, "POINT", 33.75, -112.8}, {"OK"},
+		{"SET", "dn", "c", "POINT", 33.25, -112.25}, {"OK"},
+		{"SET", "dn", "d", "POINT", 40, 10}, {"OK"},
+		{"DENSITY", "dn", "GRID", 2, 2, "BOUNDS", 33, -113, 34, -112}, {"[[2 0] [0 1]]"},
+		{"DENSITY", "dn", "FIELD", "speed", "GRID", 2, 2, "BOUNDS", 33, -113, 34, -112}, {"[[15 0] [0 0]]"},
+		{"DENSITY", "dn", "WHERE", "speed", 6, "+inf", "GRID", 1, 2, "BOUNDS", 33, -113, 34, -112}, {"[[1 0]]"},
+		{"DENSITY", "nokey", "GRID", 1, 1, "BOUNDS", 33, -113, 34, -112}, {"[[0]]"},
+		{"DENSITY", "dn", "BOUNDS", 33, -113, 34, -112}, {"ERR wrong number of arguments for 'density' command"},
+		{"DENSITY", "dn", "GRID", 0, 2, "BOUNDS", 33, -113, 34, -112}, {"ERR invalid argument '0'"},
+		{"DENSITY", "dn", "GRID", 2000, 2000, "BOUNDS", 33, -113, 34, -112}, {"ERR invalid argument '2000'"},
+		{"DENSITY", "dn", "GRID", 1, 1, "CIRCLE", 33, -113, 1000}, {"ERR invalid argument 'CIRCLE'"},
+	})
+}
+
+func keys_ROUTE_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "rt", "r1", "OBJECT", `{"type":"LineString","coordinates":[[0,0],[0,1],[0,2]]}`}, {"OK"},
+		{"SET", "rt", "p1", "POINT", 1.5, 0.01}, {"OK"},
+		{"SET", "rt", "p2", "POINT", 0, 0}, {"OK"},
+		{"SET", "rt", "p3", "POINT", 1, 0}, {"OK"},
+		{"ROUTE", "DISTANCE", "rt", "p2", "rt", "p3"}, {"[111194.92664455874 0]"},
+		{"ROUTE", "DISTANCE", "rt", "p3", "rt", "p2"}, {"[111194.92664455874 180]"},
+		{"ROUTE", "INTERPOLATE", "rt", "r1", "AT", 0, 55597.46332227937, 1000000}, {"[[0 0] [0.5 0] [2 0]]"},
+		{"ROUTE", "INTERPOLATE", "rt", "r1", "STEP", 100000}, {"[[0 0] [0.8993216059187303 0] [1.7986432118374611 0] [2 0]]"},
+		{"ROUTE", "ALONG", "rt", "r1", "rt", "p1"}, {"[166792.38996683812 1111.5682292025624 [1.5 0]]"},
+		{"ROUTE", "DISTANCE", "rt", "p2", "rt", "p4"}, {"ERR id not found"},
+		{"ROUTE", "ALONG", "rt", "p1", "rt", "p2"}, {"ERR not a linestring"},
+		{"ROUTE", "INTERPOLATE", "rt", "r1", "STEP", 0}, {"ERR invalid argument '0'"},
+		{"ROUTE", "INTERPOLATE", "rt", "r1", "STEP", 1}, {"ERR invalid argument '1'"},
+		{"ROUTE", "INTERPOLATE", "rt", "r1"}, {"ERR wrong number of arguments for 'route' command"},
+		{"ROUTE", "NOPE"}, {"ERR unknown command 'ROUTE NOPE'"},
+	})
+}
+
+func keys_ROUTE_MATRIX_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "depots", "d1", "POINT", 0, 0}, {"OK"},
+		{"SET", "depots", "d2", "POINT", 0, 2}, {"OK"},
+		{"SET", "jobs", "j1", "POINT", 0, 1}, {"OK"},
+		{"SET", "jobs", "j2", "POINT", 0, 3}, {"OK"},
+		// the bounds are nearer to d1 than j1, but the center isn't
+		{"SET", "jobs", "j3", "OBJECT", `{"type":"Polygon","coordinates":[[[0.5,-5],[10,-5],[10,5],[0.5,5],[0.5,-5]]]}`}, {"OK"},
+		{"ROUTE", "MATRIX", "depots", "TO", "jobs", "IDS", "j1,j2"}, {"[[d1 d2] [j1 j2] [[111194.92664455874 333584.7799336762] [111194.92664455874 111194.92664455876]]]"},
+		{"ROUTE", "MATRIX", "depots", "IDS", "d2", "TO", "jobs"}, {"[[d2] [j1 j2 j3] [[111194.92664455874 111194.92664455876 361383.5115948159]]]"},
+		{"ROUTE", "MATRIX", "depots", "TO", "jobs", "TOPK", 2}, {"[[d1 d2] [[[j1 111194.92664455874] [j2 333584.7799336762]] [[j1 111194.92664455874] [j2 111194.92664455876]]]]"},
+		{"ROUTE", "MATRIX", "depots", "TO", "jobs", "IDS", "j3,j2", "TOPK", 1}, {"[[d1 d2] [[[j2 333584.7799336762]] [[j2 111194.92664455876]]]]"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"ROUTE", "MATRIX", "depots", "IDS", "d1", "TO", "jobs", "TOPK", 1}, {`{"ok":true,"sources":["d1"],"nearest":[[{"id":"j1","distance":111194.92664455874}]]}`},
+		{"ROUTE", "MATRIX", "depots", "IDS", "d1", "TO", "jobs", "IDS", "j1"}, {`{"ok":true,"sources":["d1"],"targets":["j1"],"distances":[[111194.92664455874]]}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"ROUTE", "MATRIX", "depots", "TO", "nokey"}, {"ERR key not found"},
+		{"ROUTE", "MATRIX", "depots", "TO", "jobs", "IDS", "j9"}, {"ERR id not found"},
+		{"ROUTE", "MATRIX", "depots", "IDS", "d1,", "TO", "jobs"}, {"ERR invalid argument 'd1,'"},
+		{"ROUTE", "MATRIX", "depots", "FROM", "jobs"}, {"ERR invalid argument 'FROM'"},
+		{"ROUTE", "MATRIX", "depots", "TO", "jobs", "TOPK", 0}, {"ERR invalid argument '0'"},
+		{"ROUTE", "MATRIX", "depots", "TO", "jobs", "TOPK"}, {"ERR wrong number of arguments for 'route' command"},
+	})
+}
+
+const keysZonesLayer = `{"type":"FeatureCollection","features":[
+	{"type":"Feature","id":"downtown","properties":{"tz":"America/Phoenix"},
+	 "geometry":{"type":"Polygon","coordinates":[[[-113,33],[-112,33],[-112,34],[-113,34],[-113,33]]]}},
+	{"type":"Feature","properties":{"name":"county"},
+	 "geometry":{"type":"Polygon","coordinates":[[[-114,32],[-111,32],[-111,35],[-114,35],[-114,32]]]}},
+	{"type":"Feature","id":85004,"properties":{},
+	 "geometry":{"type":"Polygon","coordinates":[[[-112.6,33.4],[-112.4,33.4],[-112.4,33.6],[-112.6,33.6],[-112.6,33.4]]]}}
+]}`
+
+// writeZonesLayer writes keysZonesLayer to the "zones" layer of a temporary
+// layer directory.
+func writeZonesLayer() (string, error) {
+	dir, err := ioutil.TempDir("", "layers")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "zones.geojson")
+	if err := ioutil.WriteFile(path, []byte(keysZonesLayer), 0666); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+func keys_LOOKUP_test(mc *mockServer) error {
+	dir, err := writeZonesLayer()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	return mc.DoBatch([][]interface{}{
+		{"LAYERS"}, {"[]"},
+		{"LOOKUP", "zones", 33.5, -112.5}, {"ERR layer not found: zones"},
+		{"CONFIG", "SET", "layerdir", dir}, {"OK"},
+		{"CONFIG", "GET", "layerdir"}, {"[layerdir " + dir + "]"},
+		{"LAYERS"}, {"[[zones 3]]"},
+		{"LOOKUP", "zones", 33.5, -112.5}, {`[[85004 {}] [county {"name":"county"}] [downtown {"tz":"America/Phoenix"}]]`},
+		{"LOOKUP", "zones", 34.5, -112.5}, {`[[county {"name":"county"}]]`},
+		{"LOOKUP", "zones", 10, 10}, {"[]"},
+		{"LOOKUP", "zones", 91, 10}, {"ERR invalid argument '91'"},
+		{"LOOKUP", "zones", 10}, {"ERR wrong number of arguments for 'lookup' command"},
+		{"CONFIG", "SET", "layerdir", ""}, {"OK"},
+		{"LAYERS"}, {"[]"},
+	})
+}
+
+func keys_AREACONFIG_test(mc *mockServer) error {
+	dir, err := writeZonesLayer()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	return mc.DoBatch([][]interface{}{
+		{"AREACONFIG", "az", "zones"}, {"ERR layer not found: zones"},
+		{"CONFIG", "SET", "layerdir", dir}, {"OK"},
+		{"AREACONFIG", "az", "zones", "FIELD", "z"}, {"ERR invalid argument 'z'"},
+		{"AREACONFIG", "az", "zones", "NOPE", "tz"}, {"ERR invalid argument 'NOPE'"},
+		{"AREACONFIG", "az", "zones", "FIELD", "zone"}, {"OK"},
+		{"SET", "az", "a", "POINT", 33.5, -112.5}, {"OK"},
+		{"GET", "az", "a", "WITHFIELDS", "POINT"}, {"[[33.5 -112.5] [zone 85004]]"},
+		{"SET", "az", "a", "POINT", 33.7, -112.5}, {"OK"},
+		{"GET", "az", "a", "WITHFIELDS", "POINT"}, {"[[33.7 -112.5]]"},
+		{"SET", "az", "b", "FIELD", "zone", 1, "POINT", 33.5, -112.5}, {"OK"},
+		{"GET", "az", "b", "WITHFIELDS", "POINT"}, {"[[33.5 -112.5] [zone 1]]"},
+		{"AREADEL", "az"}, {1},
+		{"AREADEL", "az"}, {0},
+		{"CONFIG", "SET", "layerdir", ""}, {"OK"},
+	})
+}
+
+func keys_PREPARE_test(mc *mockServer) error {
+	area := `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]]]}`
+	return mc.DoBatch([][]interface{}{
+		{"SET", "pfleet", "a", "FIELD", "speed", 10, "POINT", 5, 5}, {"OK"},
+		{"SET", "pfleet", "b", "FIELD", "speed", 20, "POINT", 6, 6}, {"OK"},
+		{"SET", "pfleet", "c", "FIELD", "speed", 30, "POINT", 50, 50}, {"OK"},
+		{"PREPARE", "q1", "SET", "pfleet", "a"}, {"ERR invalid argument 'SET'"},
+		{"PREPARE", "q1", "WITHIN", "pfleet", "OBJECT", "{"}, {"ERR invalid data"},
+		{"PREPARE", "q1", "WITHIN", "pfleet", "WHERE", "speed", "$1", "$2",
+			"IDS", "OBJECT", area}, {"OK"},
+		{"EXECUTE", "q1", 0, 15}, {"[0 [a]]"},
+		{"EXECUTE", "q1", 0, "+inf"}, {"[0 [a b]]"},
+		{"EXECUTE", "q1", 0}, {"ERR wrong number of arguments for 'execute' command"},
+		{"EXECUTE", "q2"}, {"ERR unknown prepared query"},
+		{"PREPARE", "q2", "SCAN", "$1", "IDS"}, {"OK"},
+		{"EXECUTE", "q2", "pfleet"}, {"[0 [a b c]]"},
+		{"PREPARE", "q2", "INTERSECTS", "pfleet", "IDS", "OBJECT", area}, {"OK"},
+		{"EXECUTE", "q2"}, {"[0 [a b]]"},
+		{"DEALLOCATE", "q1"}, {1},
+		{"DEALLOCATE", "q1"}, {0},
+		{"EXECUTE", "q1", 0, 15}, {"ERR unknown prepared query"},
+		{"EXECUTE", "q2"}, {"[0 [a b]]"},
+		{"DEALLOCATE", "q2"}, {1},
+	})
+}
@@ -3,15 +3,21 @@ package tests
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/tile38/core"
 )
 
 func subTestKeys(t *testing.T, mc *mockServer) {
@@ -22,17 +28,54 @@ func subTestKeys(t *testing.T, mc *mockServer) {
 	runStep(t, mc, "RENAMENX", keys_RENAMENX_test)
 	runStep(t, mc, "EXPIRE", keys_EXPIRE_test)
 	runStep(t, mc, "FSET", keys_FSET_test)
+	runStep(t, mc, "FSET EX", keys_FSET_EX_test)
+	runStep(t, mc, "FINCRBY", keys_FINCRBY_test)
+	runStep(t, mc, "IDEMPOTENT", keys_IDEMPOTENT_test)
+	runStep(t, mc, "VERIFY", keys_VERIFY_test)
 	runStep(t, mc, "GET", keys_GET_test)
+	runStep(t, mc, "MGET", keys_MGET_test)
 	runStep(t, mc, "KEYS", keys_KEYS_test)
 	runStep(t, mc, "PERSIST", keys_PERSIST_test)
 	runStep(t, mc, "SET", keys_SET_test)
 	runStep(t, mc, "STATS", keys_STATS_test)
+	runStep(t, mc, "HOTKEYS", keys_HOTKEYS_test)
+	runStep(t, mc, "DEBUG", keys_DEBUG_test)
 	runStep(t, mc, "TTL", keys_TTL_test)
+	runStep(t, mc, "TTLCONFIG", keys_TTLCONFIG_test)
+	runStep(t, mc, "ORDERCONFIG", keys_ORDERCONFIG_test)
+	runStep(t, mc, "KEYCONFIG", keys_KEYCONFIG_test)
+	runStep(t, mc, "KEYCONFIG SNAP", keys_KEYCONFIG_SNAP_test)
+	runStep(t, mc, "KEYCONFIG COALESCE", keys_KEYCONFIG_COALESCE_test)
+	runStep(t, mc, "KEYCONFIG AOFSHRINK", keys_KEYCONFIG_AOFSHRINK_test)
+	runStep(t, mc, "HISTORYCONFIG", keys_HISTORYCONFIG_test)
+	runStep(t, mc, "FLEETSTATS", keys_FLEETSTATS_test)
+	runStep(t, mc, "WRITEBEHIND", keys_WRITEBEHIND_test)
+	runStep(t, mc, "READTHROUGH", keys_READTHROUGH_test)
+	runStep(t, mc, "EXPIREAT", keys_EXPIREAT_test)
+	runStep(t, mc, "WITHTTL", keys_WITHTTL_test)
+	runStep(t, mc, "TAG", keys_TAG_test)
+	runStep(t, mc, "META", keys_META_test)
+	runStep(t, mc, "SFIELD", keys_SFIELD_test)
+	runStep(t, mc, "TOMBSTONES", keys_TOMBSTONES_test)
 	runStep(t, mc, "SET EX", keys_SET_EX_test)
 	runStep(t, mc, "PDEL", keys_PDEL_test)
+	runStep(t, mc, "DELWHERE", keys_DELWHERE_test)
+	runStep(t, mc, "COPY", keys_COPY_test)
 	runStep(t, mc, "FIELDS", keys_FIELDS_test)
 	runStep(t, mc, "WHEREIN", keys_WHEREIN_test)
 	runStep(t, mc, "WHEREEVAL", keys_WHEREEVAL_test)
+	runStep(t, mc, "CONCURRENT", keys_CONCURRENT_test)
+	runStep(t, mc, "MAXMEMORY", keys_MAXMEMORY_test)
+	runStep(t, mc, "SHARED", keys_SHARED_test)
+	runStep(t, mc, "PACKED", keys_PACKED_test)
+	runStep(t, mc, "MSET", keys_MSET_test)
+	runStep(t, mc, "MFSET", keys_MFSET_test)
+	runStep(t, mc, "TRACK", keys_TRACK_test)
+	runStep(t, mc, "SET MATCH", keys_SET_MATCH_test)
+	runStep(t, mc, "SET CRS", keys_SET_CRS_test)
+	runStep(t, mc, "SET REPAIR", keys_SET_REPAIR_test)
+	runStep(t, mc, "SET IF", keys_SET_IF_test)
+	runStep(t, mc, "SCHEMA", keys_SCHEMA_test)
 }
 
 func keys_BOUNDS_test(mc *mockServer) error {
@@ -131,6 +174,106 @@ func keys_FSET_test(mc *mockServer) error {
 		{"GET", "mykey", "myid"}, {nil},
 	})
 }
+func keys_FSET_EX_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "mykey", "myid", "POINT", 33, -115}, {"OK"},
+		{"FSET", "mykey", "myid", "panic", 1, "EX"}, {"ERR wrong number of arguments for 'fset' command"},
+		{"FSET", "mykey", "myid", "panic", 1, "EX", 0}, {"ERR invalid argument '0'"},
+		{"FSET", "mykey", "myid", "panic", 1, "speed", 10, "EX", 0.25}, {2},
+		{"FSET", "mykey", "myid", "speed", 20}, {1},
+		{"GET", "mykey", "myid", "WITHFIELDS", "POINT"}, {"[[33 -115] [panic 1 speed 20]]"},
+		{time.Second / 2}, {}, // sleep
+		{"GET", "mykey", "myid", "WITHFIELDS", "POINT"}, {"[[33 -115] [speed 20]]"},
+		{"FSET", "mykey", "myid", "panic", 1, "EX", 0.25}, {1},
+		{"DEL", "mykey", "myid"}, {1},
+		{"SET", "mykey", "myid", "POINT", 33, -115}, {"OK"},
+		{time.Second / 2}, {}, // sleep
+		{"GET", "mykey", "myid", "POINT"}, {"[33 -115]"},
+		{"DROP", "mykey"}, {1},
+	})
+}
+func keys_FINCRBY_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"FINCRBY", "mykey", "myid", "f1", 1}, {"ERR key not found"},
+		{"SET", "mykey", "myid", "POINT", 33, -115}, {"OK"},
+		{"FINCRBY", "mykey", "myid2", "f1", 1}, {"ERR id not found"},
+		{"FINCRBY", "mykey", "myid", "f1", "x"}, {"ERR invalid argument 'x'"},
+		{"FINCRBY", "mykey", "myid", "f1"}, {"ERR wrong number of arguments for 'fincrby' command"},
+		{"FINCRBY", "mykey", "myid", "f1", 1.5}, {"1.5"},
+		{"FINCRBY", "mykey", "myid", "f1", 2}, {"3.5"},
+		{"FDECRBY", "mykey", "myid", "f1", 1}, {"2.5"},
+		{"GET", "mykey", "myid", "WITHFIELDS", "POINT"}, {"[[33 -115] [f1 2.5]]"},
+		{"FMIN", "mykey", "myid", "f1", 5}, {"2.5"},
+		{"FMIN", "mykey", "myid", "f1", 1}, {"1"},
+		{"FMAX", "mykey", "myid", "f1", 0.5}, {"1"},
+		{"FMAX", "mykey", "myid", "f2", 7}, {"7"},
+		{"FMAX", "mykey", "myid", "f2", 9}, {"9"},
+		{"GET", "mykey", "myid", "WITHFIELDS", "POINT"}, {"[[33 -115] [f1 1 f2 9]]"},
+		{"FDECRBY", "mykey", "myid", "f1", 1}, {"0"},
+		{"GET", "mykey", "myid", "WITHFIELDS", "POINT"}, {"[[33 -115] [f2 9]]"},
+		{"DROP", "mykey"}, {1},
+	})
+}
+func keys_IDEMPOTENT_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "mykey", "myid", "POINT", 33, -115}, {"OK"},
+		{"IDEMPOTENT", "t1", "FINCRBY", "mykey", "myid", "f1", 1}, {"1"},
+		{"IDEMPOTENT", "t1", "FINCRBY", "mykey", "myid", "f1", 1}, {"1"},
+		{"IDEMPOTENT", "t2", "FINCRBY", "mykey", "myid", "f1", 1}, {"2"},
+		{"FINCRBY", "mykey", "myid", "f1", 1}, {"3"},
+		{"IDEMPOTENT", "t3", "DEL", "mykey", "myid"}, {1},
+		{"IDEMPOTENT", "t3", "DEL", "mykey", "myid"}, {1},
+		{"IDEMPOTENT", "t1", "SET", "mykey", "myid", "POINT", 33, -115}, {"1"},
+		{"GET", "mykey", "myid"}, {nil},
+		{"IDEMPOTENT", "t4", "GET", "mykey", "myid"}, {"ERR IDEMPOTENT is only for the writes of a single key"},
+		{"IDEMPOTENT", "t4"}, {"ERR wrong number of arguments for 'idempotent' command"},
+		{"DROP", "mykey"}, {0},
+	})
+}
+func keys_VERIFY_test(mc *mockServer) error {
+	var digest, first string
+	return mc.DoBatch([][]interface{}{
+		{"SET", "mykey", "a", "POINT", 33, -115}, {"OK"},
+		{"SET", "mykey", "b", "FIELD", "speed", 10, "POINT", 33, -116}, {"OK"},
+		{"SET", "mykey", "c", "POINT", 33, -117}, {"OK"},
+		{"SET", "mykey", "d", "STRING", "hello"}, {"OK"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"VERIFY", "mykey"}, {func(s string) bool {
+			digest = gjson.Get(s, "ranges.0.digest").String()
+			return gjson.Get(s, "ranges.#").Int() == 1 &&
+				gjson.Get(s, "ranges.0.count").Int() == 4 && len(digest) == 32
+		}},
+		{"VERIFY", "mykey", "RANGE", "", ""}, {func(s string) bool {
+			return gjson.Get(s, "ranges.0.digest").String() == digest
+		}},
+		{"VERIFY", "mykey", "SPLIT", 2}, {func(s string) bool {
+			first = gjson.Get(s, "ranges.0.digest").String()
+			return gjson.Get(s, `ranges.#.start`).String() == `["","c"]` &&
+				gjson.Get(s, `ranges.#.end`).String() == `["c",""]` &&
+				gjson.Get(s, `ranges.#.count`).String() == `[2,2]`
+		}},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"SET", "mykey", "c", "POINT", 34, -117}, {"OK"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"VERIFY", "mykey"}, {func(s string) bool {
+			return gjson.Get(s, "ranges.0.digest").String() != digest
+		}},
+		{"VERIFY", "mykey", "RANGE", "", "c"}, {func(s string) bool {
+			return gjson.Get(s, "ranges.0.digest").String() == first
+		}},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"VERIFY", "mykey", "RANGE", "c", "", "REPAIR", "b", "c", "x"}, {3},
+		{"VERIFY", "mykey", "RANGE", "e", "f"}, {"[[e f 0 d41d8cd98f00b204e9800998ecf8427e]]"},
+		{"VERIFY", "nokey"}, {"[[  0 d41d8cd98f00b204e9800998ecf8427e]]"},
+		{"VERIFY", "mykey", "LEADER"}, {"ERR not a follower"},
+		{"VERIFY", "mykey", "SPLIT", 0}, {"ERR invalid argument '0'"},
+		{"VERIFY", "mykey", "RANGE", "b", "a"}, {"ERR invalid argument 'a'"},
+		{"VERIFY", "mykey", "SPLIT", 2, "REPAIR"}, {"ERR invalid argument 'REPAIR'"},
+		{"VERIFY"}, {"ERR wrong number of arguments for 'verify' command"},
+		{"DROP", "mykey"}, {1},
+	})
+}
+
 func keys_GET_test(mc *mockServer) error {
 	return mc.DoBatch([][]interface{}{
 		{"SET", "mykey", "myid", "STRING", "value"}, {"OK"},
@@ -232,19 +375,75 @@ func keys_STATS_test(mc *mockServer) error {
 	return mc.DoBatch([][]interface{}{
 		{"STATS", "mykey"}, {"[nil]"},
 		{"SET", "mykey", "myid", "STRING", "value"}, {"OK"},
-		{"STATS", "mykey"}, {"[[in_memory_size 9 num_objects 1 num_points 0 num_strings 1]]"},
+		{"STATS", "mykey"}, {"[[bytes_in 23 bytes_out 5 in_memory_size 9 num_objects 1 num_points 0 num_strings 1 reads 0 searches 0 writes 1]]"},
 		{"SET", "mykey", "myid2", "STRING", "value"}, {"OK"},
-		{"STATS", "mykey"}, {"[[in_memory_size 19 num_objects 2 num_points 0 num_strings 2]]"},
+		{"STATS", "mykey"}, {"[[bytes_in 47 bytes_out 10 in_memory_size 19 num_objects 2 num_points 0 num_strings 2 reads 0 searches 0 writes 2]]"},
 		{"SET", "mykey", "myid3", "OBJECT", `{"type":"Point","coordinates":[-115,33]}`}, {"OK"},
-		{"STATS", "mykey"}, {"[[in_memory_size 40 num_objects 3 num_points 1 num_strings 2]]"},
+		{"STATS", "mykey"}, {"[[bytes_in 106 bytes_out 15 in_memory_size 40 num_objects 3 num_points 1 num_strings 2 reads 0 searches 0 writes 3]]"},
 		{"DEL", "mykey", "myid"}, {1},
-		{"STATS", "mykey"}, {"[[in_memory_size 31 num_objects 2 num_points 1 num_strings 1]]"},
+		{"STATS", "mykey"}, {"[[bytes_in 118 bytes_out 19 in_memory_size 31 num_objects 2 num_points 1 num_strings 1 reads 0 searches 0 writes 4]]"},
 		{"DEL", "mykey", "myid3"}, {1},
-		{"STATS", "mykey"}, {"[[in_memory_size 10 num_objects 1 num_points 0 num_strings 1]]"},
-		{"STATS", "mykey", "mykey2"}, {"[[in_memory_size 10 num_objects 1 num_points 0 num_strings 1] nil]"},
+		{"STATS", "mykey"}, {"[[bytes_in 131 bytes_out 23 in_memory_size 10 num_objects 1 num_points 0 num_strings 1 reads 0 searches 0 writes 5]]"},
+		{"GET", "mykey", "myid2"}, {"value"},
+		{"SCAN", "mykey", "COUNT"}, {1},
+		{"GET", "mykey2", "myid2"}, {nil},
+		{"STATS", "mykey", "mykey2"}, {"[[bytes_in 158 bytes_out 38 in_memory_size 10 num_objects 1 num_points 0 num_strings 1 reads 1 searches 1 writes 5] nil]"},
 		{"DEL", "mykey", "myid2"}, {1},
 		{"STATS", "mykey"}, {"[nil]"},
 		{"STATS", "mykey", "mykey2"}, {"[nil nil]"},
+		// the later commands are counted too
+		{"SET", "mykey", "myid", "FIELD", "f1", 1, "POINT", 33, -115}, {"OK"},
+		{"FINCRBY", "mykey", "myid", "f1", 1}, {"2"},
+		{"MGET", "mykey", "myid"}, {`[{"type":"Point","coordinates":[-115,33]}]`},
+		{"STATS", "mykey"}, {"[[bytes_in 63 bytes_out 63 in_memory_size 28 num_objects 1 num_points 1 num_strings 0 reads 1 searches 0 writes 2]]"},
+		{"DEL", "mykey", "myid"}, {1},
+	})
+}
+func keys_HOTKEYS_test(mc *mockServer) error {
+	poly := `{"type":"Polygon","coordinates":[[[-116,32],[-114,32],[-114,34],[-116,34],[-116,32]]]}`
+	return mc.DoBatch([][]interface{}{
+		{"HOTKEYS", "RESET"}, {"OK"},
+		{"HOTKEYS"}, {"[]"},
+		{"SET", "hot", "p1", "OBJECT", poly}, {"OK"},
+		{"SET", "hot", "p2", "POINT", 33, -115}, {"OK"},
+		{"GET", "hot", "p1", "BOUNDS"}, {"[[32 -116] [34 -114]]"},
+		{"GET", "hot", "p1", "BOUNDS"}, {"[[32 -116] [34 -114]]"},
+		{"GET", "hot", "p1", "BOUNDS"}, {"[[32 -116] [34 -114]]"},
+		{"SET", "cold", "c1", "POINT", 33, -115}, {"OK"},
+		{"INTERSECTS", "cold", "IDS", "GET", "hot", "p1"}, {"[0 [c1]]"},
+		{"HOTKEYS"}, {"[[hot 5] [cold 2]]"},
+		{"HOTKEYS", "OBJECTS", "COUNT", 2}, {"[[hot p1 5] [cold c1 1]]"},
+		{"HOTKEYS", "COUNT", 0}, {"ERR invalid argument '0'"},
+		{"DROP", "hot"}, {1},
+		{"DROP", "cold"}, {1},
+	})
+}
+
+func keys_DEBUG_test(mc *mockServer) error {
+	if err := mc.DoBatch([][]interface{}{
+		{"SET", "dbgkey", "pt", "EX", 100, "FIELD", "speed", 10, "POINT", 33, -115}, {"OK"},
+		{"DEBUG", "OBJECT", "dbgkey", "pt"}, {"ERR DEBUG command not allowed, the server must be started with --enable-debug"},
+	}); err != nil {
+		return err
+	}
+	core.EnableDebug = "local"
+	defer func() { core.EnableDebug = "no" }()
+	return mc.DoBatch([][]interface{}{
+		{"SET", "dbgkey", "str", "STRING", "hello"}, {"OK"},
+		{"DEBUG", "OBJECT", "dbgkey", "pt"}, {"encoding:geojson weight:26 points:1 fields:1 ttl:99"},
+		{"DEBUG", "OBJECT", "dbgkey", "str"}, {"encoding:string weight:8 points:0 fields:0 ttl:-1"},
+		{"DEBUG", "OBJECT", "dbgkey", "none"}, {"ERR id not found"},
+		{"DEBUG", "SLEEP", 0.01}, {"OK"},
+		{"DEBUG", "SET-ACTIVE-EXPIRE", 0}, {"OK"},
+		{"PEXPIRE", "dbgkey", "str", 1}, {1},
+		{time.Second / 4}, {}, // sleep
+		{"SCAN", "dbgkey", "COUNT"}, {2},
+		{"DEBUG", "SET-ACTIVE-EXPIRE", 1}, {"OK"},
+		{time.Second / 4}, {}, // sleep
+		{"SCAN", "dbgkey", "COUNT"}, {1},
+		{"DEBUG", "SET-ACTIVE-EXPIRE", 2}, {"ERR invalid argument '2'"},
+		{"DEBUG", "NOPE"}, {"ERR invalid argument 'NOPE'"},
+		{"DROP", "dbgkey"}, {1},
 	})
 }
 func keys_TTL_test(mc *mockServer) error {
@@ -255,6 +454,704 @@ func keys_TTL_test(mc *mockServer) error {
 		{"TTL", "mykey", "myid"}, {1},
 	})
 }
+func keys_EXPIREAT_test(mc *mockServer) error {
+	at := time.Now().Add(time.Hour).Unix()
+	return mc.DoBatch([][]interface{}{
+		{"SET", "exkey", "a", "POINT", 33, -115}, {"OK"},
+		{"SET", "exkey", "b", "POINT", 33, -115}, {"OK"},
+		{"SET", "exkey", "c", "POINT", 33, -115}, {"OK"},
+		{"PTTL", "exkey", "a"}, {-1},
+		{"PTTL", "exkey", "d"}, {-2},
+		{"EXPIREAT", "exkey", "a", at}, {1},
+		{"PEXPIREAT", "exkey", "b", at * 1000}, {1},
+		{"SCAN", "exkey", "WITHEXPIRES", "IDS"}, {
+			fmt.Sprintf("[0 [[a %d] [b %d] [c -1]]]", at*1000, at*1000)},
+		{"SCAN", "exkey", "WITHEXPIRES", "WITHEXPIRES", "IDS"}, {"ERR duplicate argument 'WITHEXPIRES'"},
+		{"NEARBY", "exkey", "WITHEXPIRES", "FENCE", "POINT", 33, -115, 10}, {"ERR WITHEXPIRES is not allowed when FENCE is specified"},
+		{"TTL", "exkey", "b"}, {func(v string) bool {
+			n, _ := strconv.Atoi(v)
+			return n > 3500 && n <= 3600
+		}},
+		{"PEXPIRE", "exkey", "c", 100}, {1},
+		{"PEXPIRE", "exkey", "d", 100}, {0},
+		{"EXPIREAT", "exkey", "a", "soon"}, {"ERR invalid argument 'soon'"},
+		{time.Second / 2}, {}, // sleep
+		{"GET", "exkey", "c"}, {nil},
+		{"PEXPIREAT", "exkey", "a", 1}, {1},
+		{time.Second / 2}, {}, // sleep
+		{"GET", "exkey", "a"}, {nil},
+		{"DROP", "exkey"}, {1},
+	})
+}
+func keys_WITHTTL_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "exkey", "a", "EX", 100, "POINT", 33, -115}, {"OK"},
+		{"SET", "exkey", "b", "EX", 5, "POINT", 33, -115}, {"OK"},
+		{"SET", "exkey", "c", "POINT", 33, -115}, {"OK"},
+		{"SCAN", "exkey", "WITHTTL", "IDS"}, {"[0 [[a 99] [b 4] [c -1]]]"},
+		{"SCAN", "exkey", "MINTTL", 10, "IDS"}, {"[0 [a c]]"},
+		{"NEARBY", "exkey", "MINTTL", 10, "WITHTTL", "IDS", "POINT", 33, -115, 10}, {"[0 [[a 99] [c -1]]]"},
+		{"INTERSECTS", "exkey", "MINTTL", 1, "COUNT", "BOUNDS", 32, -116, 34, -114}, {"3"},
+		{"SCAN", "exkey", "MINTTL", 0, "IDS"}, {"ERR invalid argument '0'"},
+		{"SCAN", "exkey", "WITHTTL", "WITHTTL", "IDS"}, {"ERR duplicate argument 'WITHTTL'"},
+		{"NEARBY", "exkey", "MINTTL", 10, "FENCE", "POINT", 33, -115, 10}, {"ERR WITHTTL and MINTTL are not allowed when FENCE is specified"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"SCAN", "exkey", "WITHTTL", "MINTTL", 50, "IDS"}, {func(v string) bool {
+			return strings.Contains(v, `"ids":[{"id":"a","ttl":99.`) &&
+				!strings.Contains(v, `"id":"b"`) && strings.Contains(v, `{"id":"c"}`)
+		}},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"DROP", "exkey"}, {1},
+	})
+}
+
+func keys_TAG_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "tagkey", "a", "TAG", "red", "TAG", "big", "TAG", "red", "POINT", 33, -115}, {"OK"},
+		{"SET", "tagkey", "b", "TAG", "red", "POINT", 33, -115}, {"OK"},
+		{"SET", "tagkey", "c", "POINT", 33, -115}, {"OK"},
+		{"TAGS", "tagkey", "a"}, {"[big red]"},
+		{"TAGS", "tagkey", "c"}, {"[]"},
+		{"TAGS", "tagkey", "d"}, {nil},
+		{"TAG", "tagkey", "c", "big", "blue"}, {2},
+		{"TAG", "tagkey", "c", "big"}, {0},
+		{"TAG", "tagkey", "d", "big"}, {"ERR id not found"},
+		{"TAG", "nokey", "d", "big"}, {"ERR key not found"},
+		{"TAG", "tagkey", "c"}, {"ERR wrong number of arguments for 'tag' command"},
+		{"SCAN", "tagkey", "TAGGED", "red", "IDS"}, {"[0 [a b]]"},
+		{"SCAN", "tagkey", "TAGGED", "red", "TAGGED", "big", "IDS"}, {"[0 [a]]"},
+		{"SCAN", "tagkey", "TAGGED", "big", "DESC", "IDS"}, {"[0 [c a]]"},
+		{"SCAN", "tagkey", "TAGGED", "green", "COUNT"}, {"0"},
+		{"NEARBY", "tagkey", "TAGGED", "big", "IDS", "POINT", 33, -115, 10}, {"[0 [a c]]"},
+		{"INTERSECTS", "tagkey", "TAGGED", "blue", "COUNT", "BOUNDS", 32, -116, 34, -114}, {"1"},
+		{"NEARBY", "tagkey", "TAGGED", "big", "FENCE", "POINT", 33, -115, 10}, {"ERR TAGGED is not allowed when FENCE is specified"},
+		{"UNTAG", "tagkey", "a", "red", "green"}, {1},
+		{"SCAN", "tagkey", "TAGGED", "red", "IDS"}, {"[0 [b]]"},
+		{"SET", "tagkey", "b", "POINT", 34, -116}, {"OK"},
+		{"TAGS", "tagkey", "b"}, {"[red]"},
+		{"SET", "tagkey", "b", "TAG", "green", "POINT", 34, -116}, {"OK"},
+		{"TAGS", "tagkey", "b"}, {"[green]"},
+		{"DEL", "tagkey", "b"}, {1},
+		{"SET", "tagkey", "b", "POINT", 34, -116}, {"OK"},
+		{"TAGS", "tagkey", "b"}, {"[]"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"TAGS", "tagkey", "c"}, {`{"ok":true,"tags":["big","blue"]}`},
+		{"TAG", "tagkey", "c", "red"}, {`{"ok":true,"count":1}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"DROP", "tagkey"}, {1},
+	})
+}
+
+func keys_MGET_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"MGET", "mykey", "a"}, {"[nil]"},
+		{"SET", "mykey", "a", "FIELD", "speed", 10, "POINT", 33, -115}, {"OK"},
+		{"SET", "mykey", "b", "EX", 60, "STRING", "hello"}, {"OK"},
+		{"MGET", "mykey"}, {"ERR wrong number of arguments for 'mget' command"},
+		{"MGET", "mykey", "WITHFIELDS"}, {"ERR wrong number of arguments for 'mget' command"},
+		{"MGET", "mykey", "b", "x", "a"}, {`[hello nil {"type":"Point","coordinates":[-115,33]}]`},
+		{"MGET", "mykey", "WITHFIELDS", "a", "b"}, {`[[{"type":"Point","coordinates":[-115,33]} [speed 10]] [hello []]]`},
+		{"MGET", "mykey", "WITHTTL", "a"}, {`[[{"type":"Point","coordinates":[-115,33]} -1]]`},
+		{"MGET", "mykey", "WITHTTL", "b"}, {func(v string) bool {
+			var ttl int
+			_, err := fmt.Sscanf(v, "[[hello %d]]", &ttl)
+			return err == nil && ttl > 55 && ttl <= 60
+		}},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"MGET", "mykey", "WITHFIELDS", "a", "x"}, {`{"ok":true,"objects":[{"id":"a","object":{"type":"Point","coordinates":[-115,33]},"fields":{"speed":10}},null]}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"DROP", "mykey"}, {1},
+	})
+}
+
+func keys_TOMBSTONES_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "tkey", "a", "FIELD", "speed", 10, "TAG", "red", "POINT", 33, -115}, {"OK"},
+		{"UNDELETE", "tkey", "a"}, {"ERR tombstones are not enabled for the key"},
+		{"KEYCONFIG", "SET", "tkey", "TOMBSTONES", 0}, {"ERR invalid argument '0'"},
+		{"KEYCONFIG", "SET", "tkey", "TOMBSTONES", 60}, {"OK"},
+		{"KEYCONFIG", "GET", "tkey"}, {"[tombstones 60]"},
+		{"SET", "tkey", "b", "POINT", 34, -116}, {"OK"},
+		{"SET", "tkey", "c", "POINT", 35, -117}, {"OK"},
+		{"DEL", "tkey", "a"}, {1},
+		{"PDEL", "tkey", "b*"}, {1},
+		{"SCAN", "tkey", "IDS"}, {"[0 [c]]"},
+		{"TOMBSTONES", "tkey"}, {"[a b]"},
+		{"TOMBSTONES", "tkey", "a*"}, {"[a]"},
+		{"UNDELETE", "tkey", "a"}, {1},
+		{"GET", "tkey", "a", "WITHFIELDS"}, {`[{"type":"Point","coordinates":[-115,33]} [speed 10]]`},
+		{"TAGS", "tkey", "a"}, {"[red]"},
+		{"UNDELETE", "tkey", "a"}, {0},
+		// an id that's set again isn't undeleted
+		{"SET", "tkey", "b", "POINT", 1, 1}, {"OK"},
+		{"UNDELETE", "tkey", "b"}, {0},
+		{"GET", "tkey", "b", "POINT"}, {"[1 1]"},
+		{"TOMBSTONES", "tkey"}, {"[]"},
+		// the last object of the key
+		{"DROP", "tkey"}, {1},
+		{"SET", "tkey", "d", "STRING", "hello"}, {"OK"},
+		{"DEL", "tkey", "d"}, {1},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"TOMBSTONES", "tkey"}, {`{"ok":true,"ids":["d"]}`},
+		{"UNDELETE", "tkey", "d"}, {`{"ok":true,"undeleted":true}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"GET", "tkey", "d"}, {"hello"},
+		// the tombstones are purged after the retention
+		{"KEYCONFIG", "SET", "tkey", "TOMBSTONES", 0.1}, {"OK"},
+		{"DEL", "tkey", "d"}, {1},
+		{time.Second / 5}, {}, // sleep
+		{"TOMBSTONES", "tkey"}, {"[]"},
+		{"UNDELETE", "tkey", "d"}, {0},
+		{"SET", "tkey", "e", "POINT", 33, -115}, {"OK"},
+		{"DEL", "tkey", "e"}, {1},
+		{"KEYCONFIG", "DEL", "tkey"}, {"OK"},
+		{"TOMBSTONES", "tkey"}, {"ERR tombstones are not enabled for the key"},
+	})
+}
+
+func keys_META_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "metakey", "a", "META", `{"driver":"bob"}`, "POINT", 33, -115}, {"OK"},
+		{"SET", "metakey", "b", "POINT", 33, -115}, {"OK"},
+		{"GETMETA", "metakey", "a"}, {`{"driver":"bob"}`},
+		{"GETMETA", "metakey", "b"}, {nil},
+		{"GETMETA", "metakey", "c"}, {nil},
+		{"SET", "metakey", "a", "META", "x", "META", "y", "POINT", 33, -115}, {"ERR invalid argument 'META'"},
+		{"SETMETA", "metakey", "b", "hello"}, {"OK"},
+		{"SETMETA", "metakey", "c", "hello"}, {"ERR id not found"},
+		{"SETMETA", "nokey", "c", "hello"}, {"ERR key not found"},
+		{"SETMETA", "metakey", "b"}, {"ERR wrong number of arguments for 'setmeta' command"},
+		{"SET", "metakey", "b", "FIELD", "speed", 10, "POINT", 34, -116}, {"OK"},
+		{"GETMETA", "metakey", "b"}, {"hello"},
+		{"COPY", "metakey", "metacopy", "SCAN"}, {2},
+		{"GETMETA", "metacopy", "b"}, {"hello"},
+		{"CONFIG", "SET", "meta-max-size", 0}, {"ERR Invalid argument '0' for CONFIG SET 'meta-max-size'"},
+		{"CONFIG", "SET", "meta-max-size", 8}, {"OK"},
+		{"CONFIG", "GET", "meta-max-size"}, {"[meta-max-size 8]"},
+		{"SETMETA", "metakey", "b", "123456789"}, {"ERR meta is larger than the meta-max-size of 8 bytes"},
+		{"MSET", "metakey", "ID", "c", "META", "123456789", "POINT", 1, 1}, {"ERR meta is larger than the meta-max-size of 8 bytes"},
+		{"SETMETA", "metakey", "b", "12345678"}, {"OK"},
+		{"CONFIG", "SET", "meta-max-size", ""}, {"OK"},
+		{"SETMETA", "metakey", "b", ""}, {"OK"},
+		{"GETMETA", "metakey", "b"}, {nil},
+		{"DEL", "metakey", "a"}, {1},
+		{"SET", "metakey", "a", "POINT", 33, -115}, {"OK"},
+		{"GETMETA", "metakey", "a"}, {nil},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"GETMETA", "metakey", "a"}, {`{"ok":true,"meta":""}`},
+		{"GETMETA", "metacopy", "a"}, {`{"ok":true,"meta":"{\"driver\":\"bob\"}"}`},
+		{"GETMETA", "metacopy", "c"}, {`{"ok":false,"err":"id not found"}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"DROP", "metakey"}, {1},
+		{"DROP", "metacopy"}, {1},
+	})
+}
+
+func keys_SFIELD_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "sfkey", "a", "SFIELD", "plate", "ABC123", "FIELD", "speed", 10, "POINT", 33, -115}, {"OK"},
+		{"SET", "sfkey", "b", "SFIELD", "plate", "123", "POINT", 33.1, -115.1}, {"OK"},
+		{"SET", "sfkey", "c", "POINT", 33.2, -115.2}, {"OK"},
+		{"SFGET", "sfkey", "a", "plate"}, {"ABC123"},
+		{"SFGET", "sfkey", "a", "color"}, {nil},
+		{"SFGET", "sfkey", "d", "plate"}, {nil},
+		{"GET", "sfkey", "a", "WITHFIELDS"}, {`[{"type":"Point","coordinates":[-115,33]} [speed 10]]`},
+		{"SET", "sfkey", "a", "SFIELD", "z", "x", "POINT", 33, -115}, {"ERR invalid argument 'z'"},
+		{"SET", "sfkey", "a", "SFIELD", "plate"}, {"ERR wrong number of arguments for 'set' command"},
+		{"SFSET", "sfkey", "c", "plate", "XYZ", "color", "red"}, {2},
+		{"SFSET", "sfkey", "c", "plate", "XYZ"}, {0},
+		{"SFSET", "sfkey", "d", "plate", "XYZ"}, {"ERR id not found"},
+		{"SFSET", "nokey", "d", "plate", "XYZ"}, {"ERR key not found"},
+		{"SFSET", "sfkey", "c", "plate"}, {"ERR wrong number of arguments for 'sfset' command"},
+		// the string fields are kept when the object is set again
+		{"SET", "sfkey", "a", "FIELD", "speed", 20, "POINT", 33, -115}, {"OK"},
+		{"SFGET", "sfkey", "a", "plate"}, {"ABC123"},
+		{"SCAN", "sfkey", "WHERE", "plate", "=", "ABC123", "IDS"}, {"[0 [a]]"},
+		{"SCAN", "sfkey", "WHERE", "plate", "=", "123", "IDS"}, {"[0 [b]]"},
+		{"SCAN", "sfkey", "WHERE", "plate", "=", "", "IDS"}, {"[0 []]"},
+		{"SCAN", "sfkey", "WHERE", "color", "=", "", "IDS"}, {"[0 [a b]]"},
+		{"SCAN", "sfkey", "WHERE", "plate", "=", "XYZ", "WHERE", "color", "=", "red", "IDS"}, {"[0 [c]]"},
+		{"SCAN", "sfkey", "WHERE", "plate", "=", "XYZ", "WHERE", "color", "=", "blue", "IDS"}, {"[0 []]"},
+		{"SCAN", "sfkey", "WHERE", "plate", "=", "ABC123", "COUNT"}, {1},
+		{"WITHIN", "sfkey", "WHERE", "plate", "=", "ABC123", "WHERE", "speed", 15, 25, "IDS", "BOUNDS", 32, -116, 34, -114}, {"[0 [a]]"},
+		{"NEARBY", "sfkey", "WHERE", "plate", "=", "123", "IDS", "POINT", 33, -115}, {"[0 [b]]"},
+		{"SCAN", "sfkey", "WHERE", "plate", "="}, {"ERR wrong number of arguments for 'scan' command"},
+		{"COPY", "sfkey", "sfcopy", "SCAN"}, {3},
+		{"SFGET", "sfcopy", "c", "color"}, {"red"},
+		// an empty value removes the field
+		{"SFSET", "sfkey", "c", "color", ""}, {1},
+		{"SFGET", "sfkey", "c", "color"}, {nil},
+		{"DEL", "sfkey", "a"}, {1},
+		{"SET", "sfkey", "a", "POINT", 33, -115}, {"OK"},
+		{"SFGET", "sfkey", "a", "plate"}, {nil},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"SFGET", "sfkey", "b", "plate"}, {`{"ok":true,"value":"123"}`},
+		{"SFGET", "sfkey", "a", "plate"}, {`{"ok":true,"value":""}`},
+		{"SFGET", "sfkey", "d", "plate"}, {`{"ok":false,"err":"id not found"}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"DROP", "sfkey"}, {1},
+		{"DROP", "sfcopy"}, {1},
+	})
+}
+
+func keys_ORDERCONFIG_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"ORDERCONFIG", "fleet", "MERGE"}, {"ERR invalid argument 'MERGE'"},
+		{"ORDERCONFIG", "fleet", "FIELD"}, {"ERR wrong number of arguments for 'orderconfig' command"},
+		{"ORDERCONFIG", "fleet", "FIELD", "ts"}, {"OK"},
+		{"SET", "fleet", "truck1", "FIELD", "ts", 100, "POINT", 33, -115}, {"OK"},
+		{"SET", "fleet", "truck1", "FIELD", "ts", 90, "POINT", 34, -116}, {nil},
+		{"SET", "fleet", "truck1", "FIELD", "ts", 100, "FIELD", "speed", 10, "POINT", 35, -117}, {"OK"},
+		{"SET", "fleet", "truck1", "POINT", 36, -118}, {"OK"},
+		{"GET", "fleet", "truck1", "WITHFIELDS", "POINT"}, {"[[36 -118] [speed 10 ts 100]]"},
+		{"SET", "fleet", "truck1", "FIELD", "ts", 110, "POINT", 33, -115}, {"OK"},
+		{"MSET", "fleet", "ID", "truck1", "FIELD", "ts", 105, "POINT", 1, 1,
+			"ID", "truck2", "FIELD", "ts", 105, "POINT", 2, 2}, {1},
+		{"GET", "fleet", "truck1", "WITHFIELDS", "POINT"}, {"[[33 -115] [speed 10 ts 110]]"},
+		{"ORDERCONFIG", "fleet", "FIELD", "ts", "MERGE"}, {"OK"},
+		{"SET", "fleet", "truck1", "FIELD", "ts", 100, "FIELD", "speed", 20, "FIELD", "fuel", 50, "POINT", 34, -116}, {"OK"},
+		{"GET", "fleet", "truck1", "WITHFIELDS", "POINT"}, {"[[33 -115] [fuel 50 speed 10 ts 110]]"},
+		{"ORDERCONFIG", "fleet"}, {"OK"},
+		{"SET", "fleet", "truck1", "FIELD", "ts", 1, "POINT", 34, -116}, {"OK"},
+		{"GET", "fleet", "truck1", "WITHFIELDS", "POINT"}, {"[[34 -116] [fuel 50 speed 10 ts 1]]"},
+		{"DROP", "fleet"}, {1},
+	})
+}
+
+func keys_KEYCONFIG_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"KEYCONFIG", "GET", "fleet"}, {"[]"},
+		{"KEYCONFIG", "SET", "fleet", "packed"}, {"ERR wrong number of arguments for 'keyconfig' command"},
+		{"KEYCONFIG", "SET", "fleet", "packed", "maybe"}, {"ERR invalid argument 'maybe'"},
+		{"KEYCONFIG", "SET", "fleet", "colour", "red"}, {"ERR invalid argument 'colour'"},
+		{"KEYCONFIG", "PUT", "fleet"}, {"ERR invalid argument 'PUT'"},
+		{"KEYCONFIG", "SET", "fleet", "packed", "true", "validate", "strict", "timestamps", "on"}, {"OK"},
+		{"KEYCONFIG", "GET", "fleet"}, {"[packed true validate strict timestamps on]"},
+		{"SET", "fleet", "bad", "OBJECT", `{"type":"Point","coordinates":[200,100]}`}, {"ERR invalid coordinates"},
+		{"SET", "other", "bad", "OBJECT", `{"type":"Point","coordinates":[200,100]}`}, {"OK"},
+		{"SET", "fleet", "truck", "POINT", 33, -115}, {"OK"},
+		{"GET", "fleet", "truck", "WITHFIELDS", "POINT"}, {func(v string) bool {
+			var ts int64
+			fmt.Sscanf(v, "[[33 -115] [timestamp %d]]", &ts)
+			return ts > time.Now().Add(-time.Minute).UnixNano()/int64(time.Millisecond)
+		}},
+		{"SET", "fleet", "truck", "FIELD", "timestamp", 5, "POINT", 33, -115}, {"OK"},
+		{"GET", "fleet", "truck", "WITHFIELDS", "POINT"}, {"[[33 -115] [timestamp 5]]"},
+		{"KEYCONFIG", "DEL", "fleet", "timestamps", "packed"}, {"OK"},
+		{"KEYCONFIG", "GET", "fleet"}, {"[validate strict]"},
+		{"SET", "fleet", "truck2", "POINT", 33, -115}, {"OK"},
+		{"GET", "fleet", "truck2", "WITHFIELDS", "POINT"}, {"[[33 -115]]"},
+		{"KEYCONFIG", "DEL", "fleet"}, {"OK"},
+		{"SET", "fleet", "bad", "OBJECT", `{"type":"Point","coordinates":[200,100]}`}, {"OK"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"KEYCONFIG", "SET", "fleet", "packed", "no", "validate", "loose"}, {`{"ok":true}`},
+		{"KEYCONFIG", "GET", "fleet"}, {`{"ok":true,"config":{"packed":false,"validate":"loose"}}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"KEYCONFIG", "DEL", "fleet"}, {"OK"},
+		{"DROP", "fleet"}, {1},
+		{"DROP", "other"}, {1},
+	})
+}
+
+func keys_KEYCONFIG_AOFSHRINK_test(mc *mockServer) error {
+	coords := make([]string, 100)
+	for i := range coords {
+		coords[i] = fmt.Sprintf("[%d.000001,33.000001]", -112+i)
+	}
+	route := `{"type":"LineString","coordinates":[` + strings.Join(coords, ",") + `]}`
+	// 16 bytes a point when it's not packed
+	packed := func(v string) bool {
+		fields := strings.Fields(strings.Trim(v, "[]"))
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == "in_memory_size" {
+				size, _ := strconv.Atoi(fields[i+1])
+				return size > 0 && size < 100*16/2
+			}
+		}
+		return false
+	}
+	if err := mc.DoBatch([][]interface{}{
+		{"KEYCONFIG", "SET", "shrunk", "packed", "true"}, {"OK"},
+		{"SET", "shrunk", "route", "OBJECT", route}, {"OK"},
+		{"SET", "shrunk", "gone", "POINT", 33, -112}, {"OK"},
+		{"DEL", "shrunk", "gone"}, {1},
+		{"STATS", "shrunk"}, {packed},
+		{"AOFSHRINK"}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	// the shrunk aof has no deleted objects
+	start := time.Now()
+	for {
+		info, err := redis.String(mc.Do("INFO", "persistence"))
+		if err != nil {
+			return err
+		}
+		aof, err := ioutil.ReadFile(core.AppendFileName)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(info, "aof_rewrite_in_progress:0") &&
+			!strings.Contains(string(aof), "gone") {
+			break
+		}
+		if time.Since(start) > time.Second*5 {
+			return errTimeout
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	restarted, err := mockRestartServer(mc)
+	if err != nil {
+		return err
+	}
+	defer restarted.Close()
+	if err := restarted.DoBatch([][]interface{}{
+		{"KEYCONFIG", "GET", "shrunk"}, {"[packed true]"},
+		{"GET", "shrunk", "route"}, {route},
+		{"STATS", "shrunk"}, {packed},
+	}); err != nil {
+		return err
+	}
+	return mc.DoBatch([][]interface{}{
+		{"KEYCONFIG", "DEL", "shrunk"}, {"OK"},
+		{"DROP", "shrunk"}, {1},
+	})
+}
+
+func keys_KEYCONFIG_COALESCE_test(mc *mockServer) error {
+	skipped := func() int64 {
+		v, err := redis.String(mc.conn.Do("SERVER"))
+		if err != nil {
+			return -1
+		}
+		return gjson.Get(v, "stats.coalesce_skipped").Int()
+	}
+	if _, err := mc.conn.Do("OUTPUT", "json"); err != nil {
+		return err
+	}
+	before := skipped()
+	if _, err := mc.conn.Do("OUTPUT", "resp"); err != nil {
+		return err
+	}
+	err := mc.DoBatch([][]interface{}{
+		{"KEYCONFIG", "SET", "ckey", "coalesce", 0}, {"ERR invalid argument '0'"},
+		{"KEYCONFIG", "SET", "ckey", "coalescelog", "maybe"}, {"ERR invalid argument 'maybe'"},
+		{"KEYCONFIG", "SET", "ckey", "coalesce", 0.2, "coalescelog", "on"}, {"OK"},
+		{"KEYCONFIG", "GET", "ckey"}, {"[coalesce 0.2 coalescelog on]"},
+		{"SET", "ckey", "a", "POINT", 1, 1}, {"OK"},
+		{"SET", "ckey", "a", "POINT", 2, 2}, {"OK"},
+		{"SET", "ckey", "b", "POINT", 3, 3}, {"OK"},
+		// the SETs are held
+		{"KEYS", "ck*"}, {"[]"},
+		{time.Second / 2}, {},
+		{"KEYS", "ck*"}, {"[ckey]"},
+		{"GET", "ckey", "a", "POINT"}, {"[2 2]"},
+		{"GET", "ckey", "b", "POINT"}, {"[3 3]"},
+		// another command on the key applies them first
+		{"SET", "ckey", "a", "POINT", 4, 4}, {"OK"},
+		{"GET", "ckey", "a", "POINT"}, {"[4 4]"},
+		{"SET", "ckey", "b", "POINT", 5, 5}, {"OK"},
+		{"DEL", "ckey", "b"}, {1},
+		{"GET", "ckey", "b"}, {nil},
+		{"SET", "ckey", "c", "NX", "POINT", 6, 6}, {"OK"},
+		{"SCAN", "ckey", "IDS"}, {"[0 [a c]]"},
+		{"KEYCONFIG", "DEL", "ckey", "coalescelog"}, {"OK"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"KEYCONFIG", "GET", "ckey"}, {`{"ok":true,"config":{"coalesce":0.2}}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"KEYCONFIG", "DEL", "ckey"}, {"OK"},
+		{"SET", "ckey", "d", "POINT", 7, 7}, {"OK"},
+		{"SCAN", "ckey", "IDS"}, {"[0 [a c d]]"},
+		{"DROP", "ckey"}, {1},
+		// a SET that would fail isn't held, and returns its error
+		{"SCHEMASET", "cz", "FIELD", "speed", "integer", "REQUIRED"}, {"OK"},
+		{"KEYCONFIG", "SET", "cz", "coalesce", 10}, {"OK"},
+		{"SET", "cz", "a", "POINT", 1, 1}, {"ERR missing required field 'speed'"},
+		{"SET", "cz", "a", "FIELD", "speed", 1.5, "POINT", 1, 1}, {"ERR field 'speed' must be an integer"},
+		{"SET", "cz", "a", "FIELD", "speed", 1, "POINT", 1, 1}, {"OK"},
+		{"KEYS", "c*"}, {"[]"},
+		{"GET", "cz", "a", "POINT"}, {"[1 1]"},
+		{"SCHEMADEL", "cz"}, {1},
+		{"KEYCONFIG", "DEL", "cz"}, {"OK"},
+		{"DROP", "cz"}, {1},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := mc.conn.Do("OUTPUT", "json"); err != nil {
+		return err
+	}
+	after := skipped()
+	if _, err := mc.conn.Do("OUTPUT", "resp"); err != nil {
+		return err
+	}
+	if after-before != 1 {
+		return fmt.Errorf("expected 1 skipped SET, got %d", after-before)
+	}
+	return nil
+}
+
+func keys_KEYCONFIG_SNAP_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"KEYCONFIG", "SET", "fleet", "snap", 0}, {"ERR invalid argument '0'"},
+		{"KEYCONFIG", "SET", "fleet", "snap", 2}, {"ERR invalid argument '2'"},
+		{"KEYCONFIG", "SET", "fleet", "snap", 0.001}, {"OK"},
+		{"KEYCONFIG", "GET", "fleet"}, {"[snap 0.001]"},
+		{"HISTORYCONFIG", "fleet", "VERSIONS", 10}, {"OK"},
+		{"SET", "fleet", "truck", "FIELD", "speed", 10, "POINT", 33.00012, -115.00049}, {"OK"},
+		{"GET", "fleet", "truck", "POINT"}, {"[33 -115]"},
+		{"SET", "fleet", "truck", "FIELD", "speed", 10, "POINT", 32.9996, -114.9998}, {"OK"},
+		{"MSET", "fleet", "ID", "truck", "POINT", 33, -115, "ID", "van", "POINT", 1, 1}, {1},
+		{"MSET", "fleet", "ID", "truck", "POINT", 33, -115, "ID", "van", "POINT", 1, 1}, {0},
+		{"GET", "fleet", "truck", "HISTORY"}, {func(v string) bool {
+			return strings.Count(v, "Point") == 1
+		}},
+		{"SET", "fleet", "truck", "FIELD", "speed", 20, "POINT", 33, -115}, {"OK"},
+		{"SET", "fleet", "truck", "EX", 10, "FIELD", "speed", 20, "POINT", 33, -115}, {"OK"},
+		{"SET", "fleet", "truck", "FIELD", "speed", 20, "POINT", 33.0016, -115}, {"OK"},
+		{"GET", "fleet", "truck", "WITHFIELDS", "POINT"}, {"[[33.002 -115] [speed 20]]"},
+		{"GET", "fleet", "truck", "HISTORY"}, {func(v string) bool {
+			return strings.Count(v, "Point") == 4
+		}},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"KEYCONFIG", "GET", "fleet"}, {`{"ok":true,"config":{"snap":0.001}}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"KEYCONFIG", "DEL", "fleet", "snap"}, {"OK"},
+		{"SET", "fleet", "truck", "POINT", 33.0016, -115}, {"OK"},
+		{"GET", "fleet", "truck", "POINT"}, {"[33.0016 -115]"},
+		{"HISTORYCONFIG", "fleet"}, {"OK"},
+		{"DROP", "fleet"}, {1},
+	})
+}
+
+func keys_HISTORYCONFIG_test(mc *mockServer) error {
+	// the objects of the versions, without the times
+	objects := func(expect string) func(v string) bool {
+		return func(v string) bool {
+			var objs []string
+			for _, part := range strings.Split(v, "] [") {
+				if i := strings.Index(part, " "); i != -1 {
+					objs = append(objs, strings.Trim(part[i+1:], "[]"))
+				}
+			}
+			return "["+strings.Join(objs, " ")+"]" == expect
+		}
+	}
+	return mc.DoBatch([][]interface{}{
+		{"GET", "fleet", "truck", "HISTORY"}, {"ERR history is not enabled for the key"},
+		{"HISTORYCONFIG", "fleet", "VERSIONS", 0}, {"ERR invalid argument '0'"},
+		{"HISTORYCONFIG", "fleet", "AGE"}, {"ERR wrong number of arguments for 'historyconfig' command"},
+		{"HISTORYCONFIG", "fleet", "SIZE", 1}, {"ERR invalid argument 'SIZE'"},
+		{"HISTORYCONFIG", "fleet", "VERSIONS", 3}, {"OK"},
+		{"GET", "fleet", "truck", "HISTORY"}, {"[]"},
+		{"SET", "fleet", "truck", "POINT", 33, -115}, {"OK"},
+		{"SET", "fleet", "truck", "POINT", 34, -116}, {"OK"},
+		{"MSET", "fleet", "ID", "truck", "POINT", 35, -117, "ID", "van", "POINT", 1, 1}, {2},
+		{"SET", "fleet", "truck", "POINT", 36, -118}, {"OK"},
+		{"GET", "fleet", "truck", "HISTORY"}, {objects(`[{"type":"Point","coordinates":[-118,36]} ` +
+			`{"type":"Point","coordinates":[-117,35]} {"type":"Point","coordinates":[-116,34]}]`)},
+		{"GET", "fleet", "van", "HISTORY"}, {objects(`[{"type":"Point","coordinates":[1,1]}]`)},
+		{"GET", "fleet", "truck", "HISTORY", "POINT"}, {"ERR wrong number of arguments for 'get' command"},
+		{"DEL", "fleet", "truck"}, {1},
+		{"GET", "fleet", "truck", "HISTORY"}, {"[]"},
+		{"HISTORYCONFIG", "fleet", "AGE", 0.25}, {"OK"},
+		{"SET", "fleet", "truck", "POINT", 33, -115}, {"OK"},
+		{time.Second / 2}, {}, // sleep
+		{"SET", "fleet", "truck", "POINT", 34, -116}, {"OK"},
+		{"GET", "fleet", "truck", "HISTORY"}, {objects(`[{"type":"Point","coordinates":[-116,34]}]`)},
+		{"GET", "fleet", "van", "HISTORY"}, {"[]"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"GET", "fleet", "truck", "HISTORY"}, {func(v string) bool {
+			return strings.HasPrefix(v, `{"ok":true,"history":[{"time":`) &&
+				strings.Contains(v, `"object":{"type":"Point","coordinates":[-116,34]}}]`)
+		}},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"HISTORYCONFIG", "fleet"}, {"OK"},
+		{"GET", "fleet", "truck", "HISTORY"}, {"ERR history is not enabled for the key"},
+		{"DROP", "fleet"}, {1},
+	})
+}
+
+func keys_FLEETSTATS_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"FLEETSTATS", "fleet"}, {"ERR fleet stats are not enabled for the key"},
+		{"FLEETCONFIG", "fleet", "WINDOW", 0}, {"ERR invalid argument '0'"},
+		{"FLEETCONFIG", "fleet", "SPEED", "speed"}, {"ERR wrong number of arguments for 'fleetconfig' command"},
+		{"FLEETCONFIG", "fleet", "WINDOW", 0.5, "SPEED", "speed"}, {"OK"},
+		{"FLEETSTATS", "fleet"}, {"[count 0 active 0 stalled 0 update_rate 0 avg_speed 0]"},
+		{"SET", "fleet", "truck", "FIELD", "speed", 10, "POINT", 33, -115}, {"OK"},
+		{"SET", "fleet", "van", "FIELD", "speed", 30, "POINT", 34, -116}, {"OK"},
+		{time.Second}, {}, // sleep
+		{"SET", "fleet", "truck", "FIELD", "speed", 20, "POINT", 35, -117}, {"OK"},
+		{"SET", "fleet", "car", "POINT", 36, -118}, {"OK"},
+		{"FLEETSTATS", "fleet"}, {"[count 3 active 2 stalled 1 update_rate 4 avg_speed 20 bounds [[-118 34] [-116 36]]]"},
+		{"DEL", "fleet", "truck"}, {1},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"FLEETSTATS", "fleet"}, {`{"ok":true,"stats":{"count":2,"active":1,"stalled":1,` +
+			`"update_rate":4,"avg_speed":20,"bounds":[-118,34,-116,36]}}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"FLEETCONFIG", "fleet"}, {"OK"},
+		{"FLEETSTATS", "fleet"}, {"ERR fleet stats are not enabled for the key"},
+		{"DROP", "fleet"}, {1},
+	})
+}
+
+func keys_WRITEBEHIND_test(mc *mockServer) error {
+	// a store that keeps the ops and ids of the records
+	var mu sync.Mutex
+	var writes []string
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var ops []string
+		gjson.ParseBytes(body).ForEach(func(_, rec gjson.Result) bool {
+			ops = append(ops, rec.Get("op").String()+":"+rec.Get("id").String())
+			return true
+		})
+		mu.Lock()
+		writes = append(writes, strings.Join(ops, ","))
+		mu.Unlock()
+	}))
+	defer store.Close()
+	stored := func(expect string) func() error {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			if got := strings.Join(writes, " "); got != expect {
+				return fmt.Errorf("expected '%s', got '%s'", expect, got)
+			}
+			writes = nil
+			return nil
+		}
+	}
+	err := mc.DoBatch([][]interface{}{
+		{"WRITEBEHINDSTATS", "fleet"}, {"ERR write-behind is not enabled for the key"},
+		{"WRITEBEHIND", "fleet", "ftp://localhost"}, {"ERR invalid argument 'ftp://localhost'"},
+		{"WRITEBEHIND", "fleet", store.URL, "BATCH", 0}, {"ERR invalid argument '0'"},
+		{"WRITEBEHIND", "fleet", store.URL, "RETRIES"}, {"ERR wrong number of arguments for 'writebehind' command"},
+		{"SET", "fleet", "before", "POINT", 33, -115}, {"OK"},
+		{"WRITEBEHIND", "fleet", store.URL, "BATCH", 5, "INTERVAL", 60}, {"OK"},
+		{"SET", "fleet", "truck", "FIELD", "speed", 10, "POINT", 33, -115}, {"OK"},
+		{"SET", "other", "truck", "POINT", 33, -115}, {"OK"},
+		{"FSET", "fleet", "truck", "speed", 20}, {1},
+		{"MSET", "fleet", "ID", "van", "POINT", 34, -116, "ID", "car", "POINT", 35, -117}, {2},
+		{"DEL", "fleet", "van"}, {1},
+		{time.Second / 5}, {}, // sleep
+	})
+	if err != nil {
+		return err
+	}
+	if err := stored("set:truck,set:truck,set:van,set:car,del:van")(); err != nil {
+		return err
+	}
+	err = mc.DoBatch([][]interface{}{
+		{"DROP", "fleet"}, {1},
+		{"WRITEBEHINDSTATS", "fleet"}, {"[url " + store.URL + " pending 1 sent 5 failed 0 dropped 0 last_error ]"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"WRITEBEHINDSTATS", "fleet"}, {`{"ok":true,"stats":{"url":"` + store.URL +
+			`","pending":1,"sent":5,"failed":0,"dropped":0,"last_error":""}}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		// the queued drop is sent when the write-behind is removed
+		{"WRITEBEHIND", "fleet"}, {"OK"},
+		{"WRITEBEHINDSTATS", "fleet"}, {"ERR write-behind is not enabled for the key"},
+		{time.Second / 5}, {}, // sleep
+		{"DROP", "other"}, {1},
+	})
+	if err != nil {
+		return err
+	}
+	return stored("drop:")()
+}
+
+func keys_READTHROUGH_test(mc *mockServer) error {
+	// a store that has truck1, and counts the loads
+	var mu sync.Mutex
+	var loads []string
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		id := r.URL.Query().Get("id")
+		mu.Lock()
+		loads = append(loads, r.URL.Query().Get("key")+":"+id)
+		mu.Unlock()
+		switch id {
+		case "truck1":
+			w.Write([]byte(`{"object":{"type":"Point","coordinates":[-115,33]},` +
+				`"fields":{"speed":10}}`))
+		case "broken":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer store.Close()
+	loaded := func(expect string) func() error {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			if got := strings.Join(loads, " "); got != expect {
+				return fmt.Errorf("expected '%s', got '%s'", expect, got)
+			}
+			loads = nil
+			return nil
+		}
+	}
+	err := mc.DoBatch([][]interface{}{
+		{"READTHROUGHSTATS", "cold"}, {"ERR read-through is not enabled for the key"},
+		{"READTHROUGH", "cold", "ftp://localhost"}, {"ERR invalid argument 'ftp://localhost'"},
+		{"READTHROUGH", "cold", store.URL, "TIMEOUT", 0}, {"ERR invalid argument '0'"},
+		{"READTHROUGH", "cold", store.URL, "EX"}, {"ERR wrong number of arguments for 'readthrough' command"},
+		{"GET", "cold", "truck1"}, {nil},
+		{"READTHROUGH", "cold", store.URL, "EX", 60}, {"OK"},
+		{"GET", "cold", "truck1", "WITHFIELDS"}, {`[{"type":"Point","coordinates":[-115,33]} [speed 10]]`},
+		{"GET", "cold", "truck1"}, {`{"type":"Point","coordinates":[-115,33]}`},
+		{"TTL", "cold", "truck1"}, {func(v string) bool {
+			n, _ := strconv.Atoi(v)
+			return n > 55 && n <= 60
+		}},
+		{"GET", "cold", "truck2"}, {nil},
+		{"GET", "cold", "broken"}, {"ERR read-through: " + store.URL[len("http://"):] + ": 503 Service Unavailable unavailable"},
+		{"SCAN", "cold", "IDS"}, {"[0 [truck1]]"},
+		{"READTHROUGHSTATS", "cold"}, {"[url " + store.URL + " loaded 1 missed 1 failed 1]"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"READTHROUGHSTATS", "cold"}, {`{"ok":true,"stats":{"url":"` + store.URL +
+			`","loaded":1,"missed":1,"failed":1}}`},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"READTHROUGH", "cold"}, {"OK"},
+		{"GET", "cold", "truck2"}, {nil},
+		{"DROP", "cold"}, {1},
+	})
+	if err != nil {
+		return err
+	}
+	return loaded("cold:truck1 cold:truck2 cold:broken")()
+}
+
+func keys_TTLCONFIG_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"TTLCONFIG", "slkey", -1}, {"ERR invalid argument '-1'"},
+		{"TTLCONFIG", "slkey", 1, "NOPE"}, {"ERR invalid argument 'NOPE'"},
+		{"TTLCONFIG", "slkey", 1, "ONREAD"}, {"OK"},
+		{"SET", "slkey", "read", "POINT", 33, -115}, {"OK"},
+		{"SET", "slkey", "touched", "POINT", 33, -115}, {"OK"},
+		{"SET", "slkey", "idle", "POINT", 33, -115}, {"OK"},
+		{"SET", "slkey", "explicit", "EX", 10, "POINT", 33, -115}, {"OK"},
+		{"TTL", "slkey", "idle"}, {0},
+		{"TTL", "slkey", "explicit"}, {9},
+		{time.Second * 3 / 4}, {}, // sleep
+		{"GET", "slkey", "read", "POINT"}, {"[33 -115]"},
+		{"TOUCH", "slkey", "touched", "missing"}, {1},
+		{time.Second * 3 / 4}, {}, // sleep
+		{"GET", "slkey", "read", "POINT"}, {"[33 -115]"},
+		{"GET", "slkey", "touched", "POINT"}, {"[33 -115]"},
+		{"GET", "slkey", "idle"}, {nil},
+		{"GET", "slkey", "explicit", "POINT"}, {"[33 -115]"},
+		{"TTLCONFIG", "slkey", 0}, {"OK"},
+		{"SET", "slkey", "idle", "POINT", 33, -115}, {"OK"},
+		{"TTL", "slkey", "idle"}, {-1},
+		{"TOUCH", "slkey"}, {"ERR wrong number of arguments for 'touch' command"},
+		{"DROP", "slkey"}, {1},
+	})
+}
 
 type PSAUX struct {
 	User    string
@@ -344,6 +1241,10 @@ func keys_SET_EX_test(mc *mockServer) (err error) {
 	if gjson.Get(json, "stats.num_objects").Int() > 0 {
 		return errors.New("items left in database")
 	}
+	json, _ = redis.String(mc.conn.Do("INFO", "stats"))
+	if n := gjson.Get(json, "info.expired_keys").Int(); n < 20000 {
+		return fmt.Errorf("expected at least 20000 expired, got %d", n)
+	}
 	mc.conn.Do("FLUSHDB")
 	return nil
 }
@@ -384,6 +1285,62 @@ func keys_PDEL_test(mc *mockServer) error {
 	})
 }
 
+func keys_DELWHERE_test(mc *mockServer) error {
+	var cmds [][]interface{}
+	for i := 0; i < 20; i++ {
+		cmds = append(cmds, []interface{}{"SET", "dfleet", fmt.Sprintf("t%02d", i),
+			"FIELD", "speed", i, "POINT", 33 + float64(i)/10, -115}, []interface{}{"OK"})
+	}
+	if err := mc.DoBatch(cmds); err != nil {
+		return err
+	}
+	return mc.DoBatch([][]interface{}{
+		{"DELWHERE", "dfleet"}, {"ERR invalid argument 'dfleet'"},
+		{"DELWHERE", "BATCH", 0, "SCAN", "dfleet"}, {"ERR invalid argument '0'"},
+		{"DELWHERE", "SCAN", "dfleet", "CURSOR", 5}, {"ERR CURSOR is not allowed for DELWHERE"},
+		{"DELWHERE", "DRYRUN", "SCAN", "dfleet", "WHERE", "speed", 0, 4}, {5},
+		{"DELWHERE", "DRYRUN", "WITHIN", "dfleet", "BOUNDS", 32.9, -115.1, 33.45, -114.9}, {5},
+		{"SCAN", "dfleet", "COUNT"}, {"20"},
+		{"DELWHERE", "BATCH", 2, "WITHIN", "dfleet", "WHERE", "speed", 1, 10,
+			"BOUNDS", 32.9, -115.1, 33.45, -114.9}, {4},
+		{"DELWHERE", "BATCH", 2, "SCAN", "dfleet", "MATCH", "t1*", "LIMIT", 3}, {3},
+		{"SCAN", "dfleet", "IDS"}, {"[0 [t00 t05 t06 t07 t08 t09 t13 t14 t15 t16 t17 t18 t19]]"},
+		{"DELWHERE", "INTERSECTS", "dfleet", "WHERE", "speed", 0, 0, "BOUNDS", 32.9, -115.1, 33.45, -114.9}, {1},
+		{"DELWHERE", "SCAN", "dfleet"}, {12},
+		{"DELWHERE", "SCAN", "dfleet"}, {0},
+		{"SCAN", "dfleet", "COUNT"}, {"0"},
+	})
+}
+
+func keys_COPY_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "csrc", "a", "FIELD", "speed", 10, "POINT", 33, -115}, {"OK"},
+		{"SET", "csrc", "b", "FIELD", "speed", 20, "EX", 100, "POINT", 33.1, -115}, {"OK"},
+		{"SET", "csrc", "c", "FIELD", "speed", 30, "POINT", 40, -115}, {"OK"},
+		{"SET", "csrc", "d", "STRING", "hello"}, {"OK"},
+		{"COPY", "csrc", "csrc", "SCAN"}, {"ERR source and destination keys are the same"},
+		{"COPY", "csrc", "cdst", "SCAN", "CURSOR", 1}, {"ERR CURSOR is not allowed for COPY"},
+		{"COPY", "csrc", "cdst", "DRYRUN", "WITHIN", "BOUNDS", 32.9, -115.1, 33.2, -114.9}, {2},
+		{"KEYS", "cdst"}, {"[]"},
+		{"COPY", "csrc", "cdst", "BATCH", 1, "WITHIN", "BOUNDS", 32.9, -115.1, 33.2, -114.9}, {2},
+		{"GET", "cdst", "b", "WITHFIELDS", "POINT"}, {"[[33.1 -115] [speed 20]]"},
+		{"TTL", "cdst", "a"}, {-1},
+		{"TTL", "cdst", "b"}, {func(v string) bool {
+			n, _ := strconv.Atoi(v)
+			return n > 90 && n <= 100
+		}},
+		{"SCAN", "csrc", "COUNT"}, {"4"},
+		{"MOVE", "csrc", "cdst", "SCAN", "WHERE", "speed", 25, 50}, {1},
+		{"MOVE", "csrc", "cdst", "SCAN", "MATCH", "d"}, {1},
+		{"SCAN", "csrc", "IDS"}, {"[0 [a b]]"},
+		{"SCAN", "cdst", "IDS"}, {"[0 [a b c d]]"},
+		{"GET", "cdst", "d"}, {"hello"},
+		{"MOVE", "csrc", "cdst", "SCAN"}, {2},
+		{"KEYS", "csrc"}, {"[]"},
+		{"GET", "cdst", "a", "WITHFIELDS", "POINT"}, {"[[33 -115] [speed 10]]"},
+	})
+}
+
 func keys_WHEREIN_test(mc *mockServer) error {
 	return mc.DoBatch([][]interface{}{
 		{"SET", "mykey", "myid_a1", "FIELD", "a", 1, "POINT", 33, -115}, {"OK"},
@@ -411,3 +1368,375 @@ func keys_WHEREEVAL_test(mc *mockServer) error {
 		{"WITHIN", "mykey", "WHEREEVAL", "return FIELDS.a > tonumber(ARGV[1]) and FIELDS.a ~= tonumber(ARGV[2])", 2, 0.5, 3, "BOUNDS", 32.8, -115.2, 33.2, -114.8}, {`[0 [[myid_a1 {"type":"Point","coordinates":[-115,33]} [a 1]] [myid_a2 {"type":"Point","coordinates":[-115,32.99]} [a 2]]]]`},
 	})
 }
+
+func keys_CONCURRENT_test(mc *mockServer) error {
+	// Writers on their own keys, which are repeatedly created and dropped,
+	// run alongside readers on all of the keys.
+	const n = 4
+	const iters = 200
+	errs := make(chan error, n*2)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("conc%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			conn, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+			for j := 0; j < iters; j++ {
+				id := strconv.Itoa(j % 10)
+				if _, err := conn.Do("SET", key, id, "FIELD", "n", j,
+					"POINT", 33, -115); err != nil {
+					errs <- err
+					return
+				}
+				if j%10 == 9 {
+					if _, err := conn.Do("PDEL", key, "*"); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+			errs <- nil
+		}()
+		go func() {
+			defer wg.Done()
+			conn, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+			for j := 0; j < iters; j++ {
+				other := fmt.Sprintf("conc%d", j%n)
+				if _, err := conn.Do("SCAN", other, "COUNT"); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := conn.Do("KEYS", "conc*"); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < n*2; i++ {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	return mc.DoBatch([][]interface{}{
+		{"KEYS", "conc*"}, {"[]"},
+	})
+}
+
+func keys_MAXMEMORY_test(mc *mockServer) error {
+	// The heap of the test server is always above 1kb, so the policy is
+	// applied on the next out of memory check, which runs every 2 seconds.
+	defer mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "maxmemory", 0}, {"OK"},
+		{"CONFIG", "SET", "maxmemory-policy", "reject-writes"}, {"OK"},
+	})
+	if err := mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "maxmemory-policy", "bogus"}, {"ERR Invalid argument 'bogus' for CONFIG SET 'maxmemory-policy'"},
+		{"CONFIG", "GET", "maxmemory-policy"}, {"[maxmemory-policy reject-writes]"},
+		{"SET", "fleet", "truck1", "EX", 1000, "POINT", 33, -115}, {"OK"},
+		{"SET", "fleet", "truck2", "POINT", 33, -115}, {"OK"},
+		{"SET", "other", "truck3", "POINT", 33, -115}, {"OK"},
+		{"CONFIG", "SET", "maxmemory-policy", "evict-oldest-ttl"}, {"OK"},
+		{"CONFIG", "SET", "maxmemory", "1kb"}, {"OK"},
+		{time.Second * 3}, {}, // sleep
+		// only objects with a ttl are evicted, after that writes fail
+		{"GET", "fleet", "truck1"}, {nil},
+		{"GET", "fleet", "truck2"}, {`{"type":"Point","coordinates":[-115,33]}`},
+		{"SET", "fleet", "truck4", "POINT", 33, -115}, {"ERR OOM command not allowed when used memory > 'maxmemory'"},
+		{"CONFIG", "SET", "maxmemory-policy", "evict-coldest-key"}, {"OK"},
+		{time.Second * 3}, {}, // sleep
+		{"KEYS", "*"}, {"[]"},
+	}); err != nil {
+		return err
+	}
+	mc.conn.Do("OUTPUT", "json")
+	defer mc.conn.Do("OUTPUT", "resp")
+	json, _ := redis.String(mc.conn.Do("INFO", "stats"))
+	if n := gjson.Get(json, "info.evicted_keys").Int(); n < 3 {
+		return fmt.Errorf("expected at least 3 evicted, got %d", n)
+	}
+	return nil
+}
+
+func keys_SHARED_test(mc *mockServer) error {
+	poly := `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]]]}`
+	if err := mc.DoBatch([][]interface{}{
+		{"SET", "fences", "a", "OBJECT", poly}, {"OK"},
+		{"SET", "fences", "b", "OBJECT", poly}, {"OK"},
+		{"SET", "fences", "c", "OBJECT", poly}, {"OK"},
+		{"GET", "fences", "b"}, {poly},
+		{"DEL", "fences", "a"}, {1},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+	}); err != nil {
+		return err
+	}
+	defer mc.conn.Do("OUTPUT", "resp")
+	json, _ := redis.String(mc.conn.Do("SERVER"))
+	if n := gjson.Get(json, "stats.num_shared_geometries").Int(); n != 1 {
+		return fmt.Errorf("expected 1 shared geometry, got %d", n)
+	}
+	// two ids and one polygon
+	if n := gjson.Get(json, "stats.in_memory_size").Int(); n != 2+5*16 {
+		return fmt.Errorf("expected %d, got %d", 2+5*16, n)
+	}
+	return nil
+}
+
+func keys_PACKED_test(mc *mockServer) error {
+	var coords []string
+	for i := 0; i < 100; i++ {
+		coords = append(coords, fmt.Sprintf("[%s,%s]",
+			strconv.FormatFloat(float64(-112000000+i*123)/1e6, 'f', -1, 64),
+			strconv.FormatFloat(float64(33000000+i*321)/1e6, 'f', -1, 64)))
+	}
+	route := `{"type":"LineString","coordinates":[` + strings.Join(coords, ",") + `]}`
+	defer mc.conn.Do("CONFIG", "SET", "geometry-codec", "none")
+	return mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "geometry-codec", "delta"}, {"OK"},
+		{"CONFIG", "GET", "geometry-codec"}, {"[geometry-codec delta]"},
+		{"SET", "routes", "r1", "OBJECT", route}, {"OK"},
+		{"GET", "routes", "r1"}, {route},
+		{"INTERSECTS", "routes", "IDS", "BOUNDS", 33, -112.01, 33.01, -111.99}, {"[0 [r1]]"},
+		{"CONFIG", "SET", "geometry-codec", "zip"}, {"ERR Invalid argument 'zip' for CONFIG SET 'geometry-codec'"},
+	})
+}
+
+func keys_MSET_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"MSET", "mykey"}, {"ERR wrong number of arguments for 'mset' command"},
+		{"MSET", "mykey", "myid1", "POINT", 33, -115}, {"ERR invalid argument 'myid1'"},
+		{"MSET", "mykey",
+			"ID", "myid1", "POINT", 33, -115,
+			"ID", "myid2", "FIELD", "speed", 10, "POINT", 34, -112, 5,
+			"ID", "myid3", "STRING", "value",
+		}, {3},
+		{"GET", "mykey", "myid1", "POINT"}, {"[33 -115]"},
+		{"GET", "mykey", "myid2", "WITHFIELDS", "POINT"}, {"[[34 -112 5] [speed 10]]"},
+		{"GET", "mykey", "myid3"}, {"value"},
+		{"MSET", "mykey",
+			"ID", "myid1", "NX", "POINT", 1, 2,
+			"ID", "myid4", "NX", "POINT", 35, -110,
+			"ID", "myid5", "XX", "POINT", 35, -110,
+		}, {1},
+		{"GET", "mykey", "myid1", "POINT"}, {"[33 -115]"},
+		{"GET", "mykey", "myid4", "POINT"}, {"[35 -110]"},
+		{"GET", "mykey", "myid5"}, {nil},
+		{"MSET", "mykey", "ID", "myid6", "POINT", 1, 2, "ID"}, {"ERR wrong number of arguments for 'mset' command"},
+		{"GET", "mykey", "myid6"}, {nil},
+		{"MSET", "mykey2", "ID", "myid1", "XX", "POINT", 1, 2}, {0},
+		{"KEYS", "*"}, {"[mykey]"},
+	})
+}
+func keys_MFSET_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"MSET", "mykey", "ID", "myid1", "POINT", 33, -115, "ID", "myid2", "POINT", 34, -112}, {2},
+		{"MFSET", "mykey", "ID", "myid1", "f1", 1, "ID", "myid2", "f1", 2, "f2", 3}, {3},
+		{"GET", "mykey", "myid1", "WITHFIELDS", "POINT"}, {"[[33 -115] [f1 1]]"},
+		{"GET", "mykey", "myid2", "WITHFIELDS", "POINT"}, {"[[34 -112] [f1 2 f2 3]]"},
+		{"MFSET", "mykey", "ID", "myid1", "f1", 5, "ID", "myid3", "f1", 5}, {"ERR id not found"},
+		{"GET", "mykey", "myid1", "WITHFIELDS", "POINT"}, {"[[33 -115] [f1 1]]"},
+		{"MFSET", "mykey", "XX", "ID", "myid1", "f1", 5, "ID", "myid3", "f1", 5}, {1},
+		{"GET", "mykey", "myid1", "WITHFIELDS", "POINT"}, {"[[33 -115] [f1 5]]"},
+		{"MFSET", "mykey", "ID", "myid1"}, {"ERR wrong number of arguments for 'mfset' command"},
+		{"MFSET", "mykey2", "ID", "myid1", "f1", 1}, {"ERR key not found"},
+	})
+}
+func keys_TRACK_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"TRACK", "fleet", "truck1", "TIMESTAMP", 100, "POINT", 33, -115}, {1},
+		{"TRACK", "fleet", "truck1", "TIMESTAMP", 110, "POINT", 33, -113}, {1},
+		{"TRACK", "fleet", "truck1", "TIMESTAMP", 105, "POINT", 33, -114}, {1},
+		{"TRACK", "fleet", "truck2", "TIMESTAMP", 100, "POINT", 35, -115}, {1},
+		{"TRACK", "fleet", "truck2", "TIMESTAMP", 120, "POINT", 35, -110, 10}, {1},
+		{"TRACK", "fleet", "truck2", "POINT", 35, -110}, {1},
+		{"TRACK", "fleet", "truck2", "TIMESTAMP", 100, "BOUNDS", 35, -110, 36, -109}, {"ERR invalid argument 'BOUNDS'"},
+		{"TRAJECTORY", "fleet", "truck1"}, {"[[100 33 -115] [105 33 -114] [110 33 -113]]"},
+		{"TRAJECTORY", "fleet", "truck1", "START", 101, "END", 110}, {"[[105 33 -114] [110 33 -113]]"},
+		{"TRAJECTORY", "fleet", "truck2", "END", 120}, {"[[100 35 -115] [120 35 -110 10]]"},
+		{"TRAJECTORY", "fleet", "truck3"}, {nil},
+		{"PASSED", "fleet", "BOUNDS", 32, -114.5, 34, -114.4}, {"[truck1]"},
+		{"PASSED", "fleet", "START", 106, "BOUNDS", 32, -114.5, 34, -114.4}, {"[]"},
+		{"PASSED", "fleet", "END", 120, "BOUNDS", 30, -112, 40, -111}, {"[truck2]"},
+		{"TRACKCONFIG", "fleet", "MAXPOINTS", 2}, {"OK"},
+		{"TRAJECTORY", "fleet", "truck1"}, {"[[105 33 -114] [110 33 -113]]"},
+		{"TRACKCONFIG", "fleet", "INTERVAL", 10}, {"OK"},
+		{"TRACK", "fleet", "truck1", "TIMESTAMP", 115, "POINT", 33, -112}, {0},
+		{"TRACK", "fleet", "truck1", "TIMESTAMP", 120, "POINT", 33, -112}, {1},
+		{"TRACKCONFIG", "fleet", "FOO", 2}, {"ERR invalid argument 'FOO'"},
+		{"TRACKDEL", "fleet", "truck1"}, {1},
+		{"TRAJECTORY", "fleet", "truck1"}, {nil},
+		{"TRACKDEL", "fleet"}, {1},
+		{"TRAJECTORY", "fleet", "truck2"}, {nil},
+	})
+}
+
+const keysRoadNetwork = `{"type":"FeatureCollection","features":[
+	{"type":"Feature","id":"north","properties":{},"geometry":{
+		"type":"LineString","coordinates":[[-115,33],[-115,34]]}},
+	{"type":"Feature","id":"east","properties":{},"geometry":{
+		"type":"LineString","coordinates":[[-115,33],[-114,33]]}}
+]}`
+
+// writeRoadNetwork writes keysRoadNetwork to a temporary file.
+func writeRoadNetwork() (string, error) {
+	f, err := ioutil.TempFile("", "roads")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(keysRoadNetwork); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func keys_SET_MATCH_test(mc *mockServer) error {
+	path, err := writeRoadNetwork()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	return mc.DoBatch([][]interface{}{
+		{"SET", "fleet", "truck1", "MATCH", "POINT", 33, -115}, {"ERR road network not loaded"},
+		{"CONFIG", "SET", "roadnetwork", path + ".missing"}, {"ERR open " + path + ".missing: no such file or directory"},
+		{"CONFIG", "SET", "roadnetwork", path}, {"OK"},
+		{"CONFIG", "SET", "mapmatch-distance", 100}, {"OK"},
+		{"CONFIG", "GET", "mapmatch-distance"}, {"[mapmatch-distance 100]"},
+		{"SET", "fleet", "truck1", "MATCH", "POINT", 32.9999, -115.0001}, {"OK"},
+		{"GET", "fleet", "truck1", "POINT"}, {"[33 -115]"},
+		{"SET", "fleet", "truck2", "MATCH", "POINT", 34.0005, -115.0001, 10}, {"OK"},
+		{"GET", "fleet", "truck2"}, {`{"type":"Point","coordinates":[-115,34,10]}`},
+		{"SET", "fleet", "truck3", "MATCH", "POINT", 33.5, -114.5}, {"OK"},
+		{"GET", "fleet", "truck3", "POINT"}, {"[33.5 -114.5]"},
+		{"SET", "fleet", "truck4", "MATCH", "BOUNDS", 33, -115, 34, -114}, {"ERR invalid argument 'BOUNDS'"},
+		{"MSET", "fleet", "ID", "truck5", "MATCH", "POINT", 32.9999, -113.9999, "ID", "truck6", "POINT", 1, 1}, {2},
+		{"GET", "fleet", "truck5", "POINT"}, {"[33 -114]"},
+		{"GET", "fleet", "truck6", "POINT"}, {"[1 1]"},
+		{"CONFIG", "SET", "roadnetwork", ""}, {"OK"},
+		{"CONFIG", "SET", "mapmatch-distance", ""}, {"OK"},
+		{"SET", "fleet", "truck1", "MATCH", "POINT", 33, -115}, {"ERR road network not loaded"},
+	})
+}
+
+func keys_SET_CRS_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "utm", "a", "CRS", "EPSG:32633", "POINT", 0, 500000}, {"OK"},
+		{"GET", "utm", "a", "POINT"}, {"[0 15]"},
+		{"GET", "utm", "a", "CRS", 32633, "POINT"}, {"[0 500000]"},
+		{"GET", "utm", "a", "CRS", 32633}, {`{"type":"Point","coordinates":[500000,0]}`},
+		{"GET", "utm", "a", "CRS", 4326}, {`{"type":"Point","coordinates":[15,0]}`},
+		{"SET", "utm", "b", "CRS", 32633, "OBJECT", `{"type":"Point","coordinates":[500000,0,10]}`}, {"OK"},
+		{"GET", "utm", "b"}, {`{"type":"Point","coordinates":[15,0,10]}`},
+		{"SET", "utm", "c", "CRS", 32633, "BOUNDS", 0, 500000, 0, 500000}, {"OK"},
+		{"GET", "utm", "c", "BOUNDS"}, {"[[0 15] [0 15]]"},
+		{"SET", "utm", "d", "CRS", 32633, "HASH", "9q8yy"}, {"ERR invalid argument 'HASH'"},
+		{"SET", "utm", "d", "CRS", 1234, "POINT", 0, 0}, {"ERR unsupported crs '1234'"},
+		{"GET", "utm", "a", "CRS", "EPSG:x"}, {"ERR invalid crs 'EPSG:x'"},
+		{"SCAN", "utm", "CRS", 32633, "LIMIT", 1, "POINTS"}, {"[1 [[a [0 500000]]]]"},
+		{"SCAN", "utm", "CRS", 32633, "HASHES", 5}, {"ERR HASHES is not allowed when CRS is specified"},
+		{"MSET", "utm", "ID", "e", "CRS", 32633, "POINT", 0, 500000, "ID", "f", "POINT", 1, 2}, {2},
+		{"GET", "utm", "e", "POINT"}, {"[0 15]"},
+		{"GET", "utm", "f", "POINT"}, {"[1 2]"},
+	})
+}
+
+func keys_SET_REPAIR_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "rp", "a", "REPAIR", "OBJECT", `{"type":"Polygon","coordinates":[[[0,0],[2,2],[2,0],[0,2],[0,0]]]}`}, {
+			"[duplicates 0 closed 0 reoriented 1 split 1 dropped 0]"},
+		{"GET", "rp", "a"}, {`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,1],[0,2],[0,0]]],[[[2,0],[2,2],[1,1],[2,0]]]]}`},
+		{"SET", "rp", "b", "REPAIR", "OBJECT", `{"type":"Polygon","coordinates":[[[0,0],[0,1],[0,1],[1,1],[1,0],[0,0]]]}`}, {
+			"[duplicates 1 closed 0 reoriented 1 split 0 dropped 0]"},
+		{"GET", "rp", "b"}, {`{"type":"Polygon","coordinates":[[[1,0],[1,1],[0,1],[0,0],[1,0]]]}`},
+		{"SET", "rp", "c", "REPAIR", "POINT", 1, 2}, {"[duplicates 0 closed 0 reoriented 0 split 0 dropped 0]"},
+		{"INTERSECTS", "rp", "IDS", "BOUNDS", 1, 1.8, 1, 1.8}, {"[0 [a]]"},
+		{"MSET", "rp", "ID", "d", "REPAIR", "OBJECT", `{"type":"Polygon","coordinates":[[[0,0],[0,1],[1,1],[1,0],[0,0]]]}`,
+			"ID", "e", "REPAIR", "OBJECT", `{"type":"Polygon","coordinates":[[[0,0],[0,1],[1,1],[1,0],[0,0]]]}`}, {2},
+		{"GET", "rp", "e"}, {`{"type":"Polygon","coordinates":[[[1,0],[1,1],[0,1],[0,0],[1,0]]]}`},
+	})
+}
+
+func keys_SET_IF_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "mykey", "myid", "IF", "FIELD", "ts", "<", 100, "FIELD", "ts", 100, "POINT", 33, -115}, {"OK"},
+		{"SET", "mykey", "myid", "FIELD", "ts", 90, "IF", "FIELD", "ts", "<", 90, "POINT", 34, -116}, {nil},
+		{"GET", "mykey", "myid", "WITHFIELDS", "POINT"}, {"[[33 -115] [ts 100]]"},
+		{"SET", "mykey", "myid", "FIELD", "ts", 110, "IF", "FIELD", "ts", "<", 110, "POINT", 35, -117}, {"OK"},
+		{"GET", "mykey", "myid", "WITHFIELDS", "POINT"}, {"[[35 -117] [ts 110]]"},
+		{"SET", "mykey", "myid", "XX", "IF", "FIELD", "ts", "==", 110, "IF", "FIELD", "battery", ">", 0, "POINT", 36, -118}, {nil},
+		{"SET", "mykey", "myid", "XX", "IF", "FIELD", "ts", "==", 110, "IF", "FIELD", "battery", "<=", 0, "POINT", 36, -118}, {"OK"},
+		{"SET", "mykey", "myid2", "NX", "IF", "FIELD", "ts", "!=", 1, "POINT", 33, -115}, {"OK"},
+		{"SET", "mykey", "myid3", "IF", "FIELD", "ts", ">=", 1, "POINT", 33, -115}, {nil},
+		{"GET", "mykey", "myid3"}, {nil},
+		{"SET", "mykey2", "myid", "IF", "FIELD", "ts", ">", 0, "POINT", 33, -115}, {nil},
+		{"KEYS", "mykey2"}, {"[]"},
+		{"SET", "mykey", "myid", "IF", "FIELD", "ts", "=", 1, "POINT", 33, -115}, {"ERR invalid argument '='"},
+		{"SET", "mykey", "myid", "IF", "ts", "<", 1, "POINT", 33, -115}, {"ERR invalid argument 'ts'"},
+		{"SET", "mykey", "myid", "IF", "FIELD", "ts", "<", "x", "POINT", 33, -115}, {"ERR invalid argument 'x'"},
+		{"MSET", "mykey", "ID", "myid", "IF", "FIELD", "ts", "<", 100, "POINT", 1, 1,
+			"ID", "myid2", "IF", "FIELD", "ts", "<", 100, "POINT", 2, 2}, {1},
+		{"GET", "mykey", "myid2", "POINT"}, {"[2 2]"},
+		{"OUTPUT", "json"}, {`{"ok":true}`},
+		{"SET", "mykey", "myid", "IF", "FIELD", "ts", "<", 100, "POINT", 33, -115}, {
+			func(v string) bool { return strings.Contains(v, `"err":"condition not met"`) }},
+		{"OUTPUT", "resp"}, {"OK"},
+		{"DROP", "mykey"}, {1},
+	})
+}
+
+func keys_SCHEMA_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"SET", "schkey", "old", "FIELD", "other", 1, "POINT", 33, -115}, {"OK"},
+		{"SCHEMASET", "schkey", "FIELD", "speed", "STRING"}, {"ERR invalid argument 'STRING'"},
+		{"SCHEMASET", "schkey", "GEOMETRY", "Circle"}, {"ERR invalid argument 'Circle'"},
+		{"SCHEMASET", "schkey", "FIELD", "speed", "number", "FIELD", "speed", "integer"}, {"ERR duplicate argument 'speed'"},
+		{"SCHEMAGET", "schkey"}, {nil},
+		{"SCHEMASET", "schkey",
+			"FIELD", "speed", "number", "REQUIRED",
+			"FIELD", "lanes", "integer",
+			"FIELD", "active", "boolean",
+			"GEOMETRY", "point", "LineString"}, {"OK"},
+		{"SCHEMAGET", "schkey"}, {"[FIELD active BOOLEAN FIELD lanes INTEGER FIELD speed NUMBER REQUIRED GEOMETRY Point LineString]"},
+		{"SET", "schkey", "truck1", "POINT", 33, -115}, {"ERR missing required field 'speed'"},
+		{"SET", "schkey", "truck1", "FIELD", "sped", 10, "POINT", 33, -115}, {"ERR field 'sped' is not in the schema of schkey"},
+		{"SET", "schkey", "truck1", "FIELD", "speed", 10, "FIELD", "lanes", 1.5, "POINT", 33, -115}, {"ERR field 'lanes' must be an integer"},
+		{"SET", "schkey", "truck1", "FIELD", "speed", 10, "FIELD", "active", 2, "POINT", 33, -115}, {"ERR field 'active' must be a boolean"},
+		{"SET", "schkey", "truck1", "FIELD", "speed", 10, "BOUNDS", 33, -115, 34, -114}, {"ERR geometry 'Polygon' is not allowed in schkey"},
+		{"SET", "schkey", "truck1", "FIELD", "speed", 10, "STRING", "hello"}, {"ERR geometry 'String' is not allowed in schkey"},
+		{"SET", "schkey", "truck1", "FIELD", "speed", 10, "OBJECT", `{"type":"Feature","geometry":{"type":"LineString","coordinates":[[-115,33],[-114,34]]},"properties":{}}`}, {"OK"},
+		{"SET", "schkey", "truck1", "FIELD", "speed", 10, "FIELD", "active", 1, "POINT", 33, -115}, {"OK"},
+		{"SET", "schkey", "truck1", "POINT", 34, -115}, {"OK"},
+		{"SET", "schkey", "old", "POINT", 34, -115}, {"OK"}, // keeps its fields
+		{"FSET", "schkey", "truck1", "lanes", 2}, {1},
+		{"FSET", "schkey", "truck1", "lane", 2}, {"ERR field 'lane' is not in the schema of schkey"},
+		{"MSET", "schkey", "ID", "truck2", "FIELD", "speed", 5, "POINT", 33, -115, "ID", "truck3", "POINT", 33, -115}, {"ERR missing required field 'speed'"},
+		{"GET", "schkey", "truck2"}, {nil},
+		{"MFSET", "schkey", "ID", "truck1", "active", 0.5}, {"ERR field 'active' must be a boolean"},
+		{"JSET", "schkey", "doc", "name", "tom"}, {"ERR geometry 'String' is not allowed in schkey"},
+		{"JSET", "schkey", "truck1", "coordinates.0", -114}, {"OK"},
+		{"SCHEMASET", "schkey", "GEOMETRY", "String"}, {"OK"},
+		{"SET", "schkey", "doc", "FIELD", "anything", 1, "STRING", "hello"}, {"OK"},
+		{"SCHEMADEL", "schkey"}, {1},
+		{"SCHEMADEL", "schkey"}, {0},
+		{"SET", "schkey", "truck1", "BOUNDS", 33, -115, 34, -114}, {"OK"},
+		{"DROP", "schkey"}, {1},
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+
+	"github.com/tidwall/tile38/core"
 )
 
 func downloadURLWithStatusCode(t *testing.T, u string) (int, string) {
@@ -53,4 +55,16 @@ func subTestMetrics(t *testing.T, mc *mockServer) {
 			t.Fatalf("wanted metric: %s, got: %s", want, metrics)
 		}
 	}
+
+	// the pprof endpoints need --enable-debug
+	status, _ = downloadURLWithStatusCode(t, "http://127.0.0.1:4321/debug/pprof/")
+	if status != 404 {
+		t.Fatalf("Expected status code 404, got: %d", status)
+	}
+	core.EnableDebug = "local"
+	defer func() { core.EnableDebug = "no" }()
+	status, _ = downloadURLWithStatusCode(t, "http://127.0.0.1:4321/debug/pprof/")
+	if status != 200 {
+		t.Fatalf("Expected status code 200, got: %d", status)
+	}
 }
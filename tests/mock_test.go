@@ -7,6 +7,7 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -69,6 +70,42 @@ func mockOpenServer(silent bool) (*mockServer, error) {
 	return s, nil
 }
 
+// mockRestartServer starts a server from a copy of the AOF of another one,
+// like the server after a restart. The other server keeps running, and so
+// the new one has its own port and directory.
+func mockRestartServer(mc *mockServer) (*mockServer, error) {
+	port := mc.port + 1
+	dir := fmt.Sprintf("data-mock-%d", port)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	aof, err := ioutil.ReadFile(core.AppendFileName)
+	if err != nil {
+		return nil, err
+	}
+	appendFileName := filepath.Join(dir, "appendonly.aof")
+	if err := ioutil.WriteFile(appendFileName, aof, 0600); err != nil {
+		return nil, err
+	}
+	// the files of the server are set once, by the first server
+	defer func(appendFileName, queueFileName string) {
+		core.AppendFileName, core.QueueFileName = appendFileName, queueFileName
+	}(core.AppendFileName, core.QueueFileName)
+	core.AppendFileName = appendFileName
+	core.QueueFileName = filepath.Join(dir, "queue.db")
+	s := &mockServer{port: port}
+	go func() {
+		if err := server.Serve("localhost", port, dir, true, ""); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	if err := s.waitForStartup(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
 func (s *mockServer) waitForStartup() error {
 	var lerr error
 	start := time.Now()
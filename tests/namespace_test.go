@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/tidwall/gjson"
+)
+
+func subTestNamespace(t *testing.T, mc *mockServer) {
+	runStep(t, mc, "basic", namespace_basic_test)
+	runStep(t, mc, "quotas", namespace_quotas_test)
+	runStep(t, mc, "fence", namespace_fence_test)
+	runStep(t, mc, "tenant rate", namespace_tenant_rate_test)
+}
+
+func namespace_basic_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	if err := mc.DoBatch([][]interface{}{
+		{"NSSET", "bad:name"}, {"ERR invalid argument 'bad:name'"},
+		{"NSSET", "acme", "PASSWORD", "secret"}, {"OK"},
+		{"SET", "fleet", "t0", "POINT", 1, 1}, {"OK"},
+		{"AUTH", "acme", "nope"}, {"ERR invalid password"},
+		{"AUTH", "acme", "secret"}, {"OK"},
+		{"KEYS", "*"}, {"[]"},
+		{"SET", "fleet", "t1", "POINT", 33, -115}, {"OK"},
+		{"KEYS", "*"}, {"[fleet]"},
+		{"GET", "fleet", "t1", "POINT"}, {"[33 -115]"},
+		{"GET", "fleet", "t0"}, {nil},
+		{"FLUSHDB"}, {"ERR not allowed in a namespace"},
+		{"SELECT", "default"}, {"ERR not allowed in a namespace"},
+	}); err != nil {
+		return err
+	}
+	res, err := redis.String(mc.Do("OUTPUT", "json"))
+	if err != nil {
+		return err
+	}
+	if res, err = redis.String(mc.Do("NSINFO")); err != nil {
+		return err
+	}
+	if gjson.Get(res, "namespaces.#").Int() != 1 ||
+		gjson.Get(res, "namespaces.0.name").String() != "acme" ||
+		gjson.Get(res, "namespaces.0.num_objects").Int() != 1 ||
+		gjson.Get(res, "namespaces.0.num_collections").Int() != 1 {
+		return fmt.Errorf("unexpected nsinfo: %s", res)
+	}
+	mc.ResetConn()
+	return mc.DoBatch([][]interface{}{
+		{"KEYS", "*"}, {"[acme:fleet fleet]"},
+		{"SELECT", "nope"}, {"ERR namespace not found: nope"},
+		{"SELECT", "acme"}, {"OK"},
+		{"KEYS", "*"}, {"[fleet]"},
+		{"SELECT", "default"}, {"OK"},
+		{"GET", "acme:fleet", "t1", "POINT"}, {"[33 -115]"},
+		{"DROP", "acme:fleet"}, {1},
+		{"DROP", "fleet"}, {1},
+		{"NSDEL", "acme"}, {1},
+		{"NSDEL", "acme"}, {0},
+	})
+}
+
+func namespace_quotas_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	admin, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+	if _, err := admin.Do("NSSET", "q", "PASSWORD", "pw", "MAXOBJECTS", 2, "MAXHOOKS", 1); err != nil {
+		return err
+	}
+	// the SETs of a key with quotas aren't held by COALESCE, see coalesce.go
+	if _, err := admin.Do("KEYCONFIG", "SET", "q:fleet", "coalesce", 10); err != nil {
+		return err
+	}
+	defer func() {
+		admin.Do("KEYCONFIG", "DEL", "q:fleet")
+		admin.Do("DELHOOK", "q:h1")
+		admin.Do("DROP", "q:fleet")
+		admin.Do("NSDEL", "q")
+	}()
+	if err := mc.DoBatch([][]interface{}{
+		{"AUTH", "q", "pw"}, {"OK"},
+		{"SET", "fleet", "a", "POINT", 33, -115}, {"OK"},
+		{"MSET", "fleet", "ID", "b", "POINT", 33, -115, "ID", "c", "POINT", 33, -115}, {"ERR namespace object quota exceeded"},
+		{"SET", "fleet", "b", "POINT", 33, -115}, {"OK"},
+		{"SET", "fleet", "c", "POINT", 33, -115}, {"ERR namespace object quota exceeded"},
+		{"SET", "fleet", "a", "POINT", 34, -115}, {"OK"},
+		{"DEL", "fleet", "a"}, {1},
+		{"SET", "fleet", "c", "POINT", 33, -115}, {"OK"},
+		{"SETHOOK", "h1", "http://127.0.0.1:9999/x", "NEARBY", "fleet", "FENCE", "POINT", 33, -115, 100}, {1},
+		{"SETHOOK", "h2", "http://127.0.0.1:9999/x", "NEARBY", "fleet", "FENCE", "POINT", 33, -115, 100}, {"ERR namespace hook quota exceeded"},
+		{"HOOKS", "*"}, {"[[h1 fleet [http://127.0.0.1:9999/x] [NEARBY fleet FENCE POINT 33 -115 100] []]]"},
+	}); err != nil {
+		return err
+	}
+	if _, err := admin.Do("NSSET", "q", "PASSWORD", "pw", "MAXMEMORY", 1); err != nil {
+		return err
+	}
+	return mc.DoBatch([][]interface{}{
+		{"SET", "fleet", "d", "POINT", 33, -115}, {"ERR namespace memory quota exceeded"},
+		{"DEL", "fleet", "c"}, {1},
+	})
+}
+
+func namespace_fence_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	if err := mc.DoBatch([][]interface{}{
+		{"NSSET", "f", "PASSWORD", "pw"}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		mc.ResetConn()
+		mc.Do("DROP", "f:fleet")
+		mc.Do("NSDEL", "f")
+	}()
+	conn, err := net.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	rd := &fenceReader{conn, bufio.NewReader(conn)}
+	for _, cmd := range []string{"AUTH f pw", "NEARBY fleet FENCE POINT 33 -115 5000"} {
+		if _, err := fmt.Fprintf(conn, "%s\r\n", cmd); err != nil {
+			return err
+		}
+		line, err := rd.rd.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if line != "+OK\r\n" {
+			return fmt.Errorf("expected OK, got '%v'", line)
+		}
+	}
+	if err := mc.DoBatch([][]interface{}{
+		{"AUTH", "f", "pw"}, {"OK"},
+		{"SET", "fleet", "t1", "POINT", 33, -115}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	return rd.receiveExpect("command", "set", "detect", "enter",
+		"key", "fleet", "id", "t1")
+}
+
+func namespace_tenant_rate_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	admin, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+	if err := mc.DoBatch([][]interface{}{
+		{"CONFIG", "SET", "tenant-weights", "scan=1"}, {"ERR Invalid argument 'scan=1' for CONFIG SET 'tenant-weights'"},
+		{"CONFIG", "SET", "tenant-rate", -1}, {"ERR Invalid argument '-1' for CONFIG SET 'tenant-rate'"},
+		{"NSSET", "r", "PASSWORD", "pw"}, {"OK"},
+		{"CONFIG", "SET", "tenant-rate", 1}, {"OK"},
+		{"CONFIG", "SET", "tenant-burst", 2}, {"OK"},
+		{"CONFIG", "SET", "tenant-weights", "search=5"}, {"OK"},
+		{"CONFIG", "GET", "tenant-weights"}, {"[tenant-weights read=1,write=1,search=5]"},
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		admin.Do("CONFIG", "SET", "tenant-rate", "")
+		admin.Do("CONFIG", "SET", "tenant-burst", "")
+		admin.Do("CONFIG", "SET", "tenant-weights", "")
+		admin.Do("NSDEL", "r")
+	}()
+	if err := mc.DoBatch([][]interface{}{
+		{"AUTH", "r", "pw"}, {"OK"},
+		{"GET", "fleet", "a"}, {nil},
+		{"GET", "fleet", "a"}, {nil},
+		// the bucket is empty, and a search would wait two seconds
+		{"SCAN", "fleet", "IDS"}, {"ERR tenant rate limit exceeded"},
+	}); err != nil {
+		return err
+	}
+	// the other clients aren't limited
+	for i := 0; i < 5; i++ {
+		if _, err := admin.Do("SCAN", "fleet", "IDS"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
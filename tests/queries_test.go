@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/tidwall/gjson"
+)
+
+func subTestQueries(t *testing.T, mc *mockServer) {
+	runStep(t, mc, "kill", queries_kill_test)
+	runStep(t, mc, "delwhere", queries_delwhere_test)
+}
+
+// slowScan starts a scan that takes seconds on another connection, and
+// returns its running query once it's listed. Each object only takes a short
+// loop, so that the scan, which checks if it's canceled every 64 objects,
+// stops soon after it's killed, even with the race detector.
+func slowScan(mc *mockServer, key string) (query gjson.Result,
+	done chan error, err error) {
+	conn, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return query, nil, err
+	}
+	done = make(chan error, 1)
+	go func() {
+		defer conn.Close()
+		_, err := conn.Do("SCAN", key, "LIMIT", 1000000, "WHEREEVAL",
+			"local i = 0 while i < 2000 do i = i + 1 end return true", 0,
+			"IDS")
+		done <- err
+	}()
+	if _, err := mc.Do("OUTPUT", "json"); err != nil {
+		return query, nil, err
+	}
+	defer mc.Do("OUTPUT", "resp")
+	for start := time.Now(); time.Since(start) < 5*time.Second; {
+		res, err := redis.String(mc.Do("QUERIES", "LIST"))
+		if err != nil {
+			return query, nil, err
+		}
+		for _, q := range gjson.Get(res, "queries").Array() {
+			if q.Get("command").String() == "scan" &&
+				q.Get("key").String() == key {
+				return q, done, nil
+			}
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return query, nil, errors.New("the scan isn't listed")
+}
+
+// expectCanceled waits for a killed scan.
+func expectCanceled(done chan error) error {
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "ERR canceled" {
+			return fmt.Errorf("expected 'ERR canceled', got '%v'", err)
+		}
+		return nil
+	case <-time.After(5 * time.Second):
+		return errors.New("the scan wasn't canceled")
+	}
+}
+
+func queries_kill_test(mc *mockServer) error {
+	// enough objects for the scan to take seconds
+	for i := 0; i < 20000; i += 1000 {
+		args := []interface{}{"qfleet"}
+		for j := i; j < i+1000; j++ {
+			args = append(args, "ID", fmt.Sprintf("t%d", j), "POINT", 33, -115)
+		}
+		if _, err := mc.Do("MSET", args...); err != nil {
+			return err
+		}
+	}
+	q, done, err := slowScan(mc, "qfleet")
+	if err != nil {
+		return err
+	}
+	if err := mc.DoBatch([][]interface{}{
+		{"QUERIES", "KILL", "abc"}, {"ERR invalid argument 'abc'"},
+		{"QUERIES", "KILL", q.Get("id").Int() + 1000}, {0},
+		{"QUERIES", "KILL", q.Get("id").Int()}, {1},
+	}); err != nil {
+		return err
+	}
+	if err := expectCanceled(done); err != nil {
+		return err
+	}
+	q, done, err = slowScan(mc, "qfleet")
+	if err != nil {
+		return err
+	}
+	if err := mc.DoBatch([][]interface{}{
+		{"QUERIES", "KILL", "CLIENT", q.Get("client").Int()}, {1},
+	}); err != nil {
+		return err
+	}
+	if err := expectCanceled(done); err != nil {
+		return err
+	}
+	return mc.DoBatch([][]interface{}{
+		{"SCAN", "qfleet", "COUNT"}, {"20000"},
+	})
+}
+
+func queries_delwhere_test(mc *mockServer) error {
+	for i := 0; i < 50; i++ {
+		if _, err := mc.Do("SET", "qdfleet", fmt.Sprintf("t%d", i),
+			"POINT", 33, -115); err != nil {
+			return err
+		}
+	}
+	conn, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer conn.Close()
+		_, err := conn.Do("DELWHERE", "BATCH", 1, "SCAN", "qdfleet",
+			"WHEREEVAL",
+			"local i = 0 while i < 2000000 do i = i + 1 end return true", 0)
+		done <- err
+	}()
+	if _, err := mc.Do("OUTPUT", "json"); err != nil {
+		return err
+	}
+	defer mc.Do("OUTPUT", "resp")
+	var q gjson.Result
+	for start := time.Now(); !q.Exists(); {
+		if time.Since(start) > 5*time.Second {
+			return errors.New("the delete isn't listed")
+		}
+		res, err := redis.String(mc.Do("QUERIES", "LIST"))
+		if err != nil {
+			return err
+		}
+		for _, r := range gjson.Get(res, "queries").Array() {
+			if r.Get("command").String() == "delwhere" &&
+				r.Get("key").String() == "qdfleet" && r.Get("done").Int() > 0 {
+				q = r
+			}
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	res, err := redis.String(mc.Do("QUERIES", "KILL", q.Get("id").Int()))
+	if err != nil {
+		return err
+	}
+	if gjson.Get(res, "killed").Int() != 1 {
+		return fmt.Errorf("expected 1 killed, got '%s'", res)
+	}
+	if err := expectCanceled(done); err != nil {
+		return err
+	}
+	res, err = redis.String(mc.Do("SCAN", "qdfleet", "COUNT"))
+	if err != nil {
+		return err
+	}
+	if n := gjson.Get(res, "count").Int(); n == 0 || n == 50 {
+		return fmt.Errorf("expected some objects to be deleted, got %d left", n)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/tidwall/gjson"
+)
+
+func subTestRoles(t *testing.T, mc *mockServer) {
+	runStep(t, mc, "redact", roles_redact_test)
+	runStep(t, mc, "del", roles_del_test)
+}
+
+func roles_redact_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	admin, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+	defer func() {
+		admin.Do("DROP", "fleet")
+		admin.Do("DROP", "zones")
+		admin.Do("ROLEDEL", "partner")
+	}()
+	if err := mc.DoBatch([][]interface{}{
+		{"ROLESET", "partner", "HIDE", "driver"}, {"ERR invalid argument 'HIDE'"},
+		{"ROLESET", "partner", "REDACT", "fleet", "PRECISION", 16}, {"ERR invalid argument '16'"},
+		{"ROLESET", "partner", "PASSWORD", "pw",
+			"REDACT", "fleet", "HIDE", "driver", "PRECISION", 2}, {"OK"},
+		{"ROLES"}, {"[[partner [[fleet [driver] 2]]]]"},
+		{"SET", "fleet", "t1", "FIELD", "driver", 7, "FIELD", "speed", 50,
+			"POINT", 33.12345, -115.12345}, {"OK"},
+		{"SET", "zones", "z1", "POINT", 1.23456, 2.34567}, {"OK"},
+		{"AUTH", "partner", "nope"}, {"ERR invalid password"},
+		{"AUTH", "partner", "pw"}, {"OK"},
+		{"GET", "fleet", "t1", "POINT"}, {"[33.12 -115.12]"},
+		{"GET", "fleet", "t1", "WITHFIELDS", "POINT"}, {"[[33.12 -115.12] [speed 50]]"},
+		{"GET", "fleet", "t1", "HASH", 12}, {"9myhkmk1vx6r"},
+		{"GET", "zones", "z1", "POINT"}, {"[1.23456 2.34567]"},
+		{"SCAN", "fleet", "POINTS"}, {"[0 [[t1 [33.12 -115.12] [speed 50]]]]"},
+		{"SCAN", "fleet", "OBJECTS"}, {`[0 [[t1 {"type":"Point","coordinates":[-115.12,33.12]} [speed 50]]]]`},
+		{"NEARBY", "fleet", "DISTANCE", "POINTS", "POINT", 33.13, -115.12345, 10000},
+		{"[0 [[t1 [33.12 -115.12] [speed 50] 1113.2]]]"},
+		{"SCAN", "fleet", "WHERE", "driver", 0, 10, "IDS"}, {"ERR field is redacted: driver"},
+		{"SCAN", "fleet", "SELECT", "driver", "IDS"}, {"ERR field is redacted: driver"},
+		{"SCAN", "fleet", "WHERE", "speed", 0, 100, "IDS"}, {"[0 [t1]]"},
+		{"MREAD", "SCAN", "fleet", "POINTS", ";", "SCAN", "zones", "POINTS"},
+		{"[[0 [[t1 [33.12 -115.12] [speed 50]]]] [0 [[z1 [1.23456 2.34567]]]]]"},
+		{"NEARBY", "fleet", "FENCE", "POINT", 33, -115, 1000}, {"ERR not allowed for role"},
+		{"SET", "fleet", "t2", "POINT", 0, 0}, {"ERR not allowed for role"},
+		{"ROLES"}, {"ERR not allowed for role"},
+		{"KEYS", "*"}, {"[fleet zones]"},
+	}); err != nil {
+		return err
+	}
+	res, err := redis.String(admin.Do("OUTPUT", "json"))
+	if err != nil {
+		return err
+	}
+	if res, err = redis.String(admin.Do("ROLES", "partner")); err != nil {
+		return err
+	}
+	if gjson.Get(res, "roles.#").Int() != 1 ||
+		gjson.Get(res, "roles.0.rules.0.pattern").String() != "fleet" ||
+		gjson.Get(res, "roles.0.rules.0.hide.0").String() != "driver" ||
+		gjson.Get(res, "roles.0.rules.0.precision").Int() != 2 ||
+		gjson.Get(res, "roles.0.password").Exists() {
+		return fmt.Errorf("unexpected roles: %s", res)
+	}
+	return nil
+}
+
+func roles_del_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	admin, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+	if _, err := admin.Do("ROLESET", "partner", "PASSWORD", "pw"); err != nil {
+		return err
+	}
+	if err := mc.DoBatch([][]interface{}{
+		{"AUTH", "partner", "pw"}, {"OK"},
+		{"KEYS", "*"}, {"[]"},
+	}); err != nil {
+		return err
+	}
+	if _, err := admin.Do("ROLEDEL", "partner"); err != nil {
+		return err
+	}
+	return mc.DoBatch([][]interface{}{
+		{"KEYS", "*"}, {"ERR role not found: partner"},
+	})
+}
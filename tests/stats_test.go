@@ -2,6 +2,7 @@ package tests
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/tidwall/gjson"
@@ -9,6 +10,7 @@ import (
 
 func subTestInfo(t *testing.T, mc *mockServer) {
 	runStep(t, mc, "valid json", info_valid_json_test)
+	runStep(t, mc, "BENCH", info_BENCH_test)
 }
 
 func info_valid_json_test(mc *mockServer) error {
@@ -33,3 +35,66 @@ func info_valid_json_test(mc *mockServer) error {
 	}
 	return nil
 }
+
+func info_BENCH_test(mc *mockServer) error {
+	if err := mc.DoBatch([][]interface{}{
+		{"BENCH", "OBJECTS", 0}, {"ERR OBJECTS and CLIENTS must be greater than zero"},
+		{"BENCH", "UPDATES", 101}, {"ERR invalid argument '101'"},
+		{"BENCH", "BOGUS", 1}, {"ERR invalid argument 'BOGUS'"},
+		{"BENCH", "OBJECTS"}, {"ERR wrong number of arguments for 'bench' command"},
+	}); err != nil {
+		return err
+	}
+	if _, err := mc.Do("OUTPUT", "JSON"); err != nil {
+		return err
+	}
+	aofSize := func() int64 {
+		res, err := mc.Do("SERVER")
+		if err != nil {
+			return -1
+		}
+		return gjson.GetBytes(res.([]byte), "stats.aof_size").Int()
+	}
+	before := aofSize()
+	res, err := mc.Do("BENCH", "OBJECTS", 1000, "FENCES", 10, "CLIENTS", 4,
+		"REQUESTS", 2000, "UPDATES", 50)
+	if err != nil {
+		return err
+	}
+	json := string(res.([]byte))
+	if !gjson.Get(json, "ok").Bool() {
+		return fmt.Errorf("expected ok, got '%s'", json)
+	}
+	var count int64
+	for _, cmd := range []string{"set", "nearby", "within", "intersects"} {
+		stats := gjson.Get(json, "bench."+cmd)
+		count += stats.Get("count").Int()
+		if stats.Get("count").Int() > 0 &&
+			stats.Get("p50_us").Float() > stats.Get("max_us").Float() {
+			return fmt.Errorf("bad stats for %s: %s", cmd, stats.Raw)
+		}
+	}
+	if count != 2000 {
+		return fmt.Errorf("expected 2000 requests, got %d", count)
+	}
+	// nothing is written to the aof
+	if after := aofSize(); after != before {
+		return fmt.Errorf("expected an aof of %d bytes, got %d", before, after)
+	}
+	// the benchmark stops at the timeout, with the requests done by then
+	res, err = mc.Do("TIMEOUT", 0.2, "BENCH", "REQUESTS", 100000000)
+	if err != nil {
+		return err
+	}
+	json = string(res.([]byte))
+	if n := gjson.Get(json, "bench.requests").Int(); !gjson.Get(json, "ok").Bool() ||
+		n == 0 || n >= 100000000 {
+		return fmt.Errorf("expected a partial benchmark, got '%s'", json)
+	}
+	// the temporary key and fences are removed
+	return mc.DoBatch([][]interface{}{
+		{"OUTPUT", "RESP"}, {"OK"},
+		{"KEYS", "*"}, {"[]"},
+		{"CHANS", "*"}, {"[]"},
+	})
+}
@@ -10,6 +10,7 @@ func subTestTestCmd(t *testing.T, mc *mockServer) {
 	runStep(t, mc, "INTERSECTS_CLIP", testcmd_INTERSECTS_CLIP_test)
 	runStep(t, mc, "ExpressionErrors", testcmd_expressionErrors_test)
 	runStep(t, mc, "Expressions", testcmd_expression_test)
+	runStep(t, mc, "GEOHASH", testcmd_GEOHASH_test)
 }
 
 func testcmd_WITHIN_test(mc *mockServer) error {
@@ -211,3 +212,17 @@ func testcmd_expression_test(mc *mockServer) error {
 		{"TEST", "OBJECT", poly9, "WITHIN", "NOT", "GET", "mykey", "line3"}, {"1"},
 	})
 }
+
+func testcmd_GEOHASH_test(mc *mockServer) error {
+	return mc.DoBatch([][]interface{}{
+		{"GEOHASH", "ENCODE", 33, -115}, {"9my5xp7wcvbg"},
+		{"GEOHASH", "ENCODE", 33, -115, 7}, {"9my5xp7"},
+		{"GEOHASH", "ENCODE", 33, -115, 13}, {"ERR invalid argument '13'"},
+		{"GEOHASH", "ENCODE", 91, -115}, {"ERR invalid argument '91'"},
+		{"GEOHASH", "DECODE", "9my5"}, {"[[32.958984375 -115.13671875] [[32.87109375 -115.3125] [33.046875 -114.9609375]]]"},
+		{"GEOHASH", "DECODE", "9my5a"}, {"ERR invalid argument '9my5a'"},
+		{"GEOHASH", "NEIGHBORS", "9my5"}, {"[n 9myh ne 9myk e 9my7 se 9my6 s 9my4 sw 9mvf w 9mvg nw 9mvu]"},
+		{"GEOHASH", "NEIGHBORS", "b"}, {"[e c se 9 s 8 sw x w z]"},
+		{"GEOHASH", "FOO"}, {"ERR unknown command 'GEOHASH FOO'"},
+	})
+}
@@ -51,8 +51,15 @@ func TestAll(t *testing.T) {
 	runSubTest(t, "scripts", mc, subTestScripts)
 	runSubTest(t, "info", mc, subTestInfo)
 	runSubTest(t, "client", mc, subTestClient)
+	runSubTest(t, "go client", mc, subTestGoClient)
+	runSubTest(t, "namespace", mc, subTestNamespace)
+	runSubTest(t, "writes", mc, subTestWrites)
+	runSubTest(t, "roles", mc, subTestRoles)
+	runSubTest(t, "cursors", mc, subTestCursors)
+	runSubTest(t, "queries", mc, subTestQueries)
 	runSubTest(t, "timeouts", mc, subTestTimeout)
 	runSubTest(t, "metrics", mc, subTestMetrics)
+	runSubTest(t, "cdc", mc, subTestCDC)
 }
 
 func runSubTest(t *testing.T, name string, mc *mockServer, test func(t *testing.T, mc *mockServer)) {
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/tidwall/gjson"
+)
+
+func subTestWrites(t *testing.T, mc *mockServer) {
+	runStep(t, mc, "reject", writes_reject_test)
+	runStep(t, mc, "queue", writes_queue_test)
+}
+
+func writes_reject_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	defer mc.Do("WRITES", "RESUME")
+	if err := mc.DoBatch([][]interface{}{
+		{"SET", "fleet", "truck1", "POINT", 33, -115}, {"OK"},
+		{"WRITES", "STATUS"}, {"[paused false]"},
+		{"WRITES", "PAUSE", "REJECT", "TIMEOUT", 1}, {"ERR TIMEOUT is only for QUEUE"},
+		{"WRITES", "PAUSE", "FAST"}, {"ERR invalid argument 'FAST'"},
+		{"WRITES", "PAUSE", "MESSAGE", "backup running"}, {"OK"},
+		{"SET", "fleet", "truck2", "POINT", 34, -115}, {"ERR backup running"},
+		{"DEL", "fleet", "truck1"}, {"ERR backup running"},
+		{"DROP", "fleet"}, {"ERR backup running"},
+		{"EVAL", "return tile38.call('set', KEYS[1], 'truck2', 'point', 1, 2)", 1, "fleet"}, {"ERR backup running"},
+		{"AOFSHRINK"}, {"ERR backup running"},
+		{"EVALNA", "return tile38.call('set', KEYS[1], 'truck2', 'point', 1, 2)", 1, "fleet"}, {
+			func(v interface{}) (resp, expect interface{}) {
+				s := fmt.Sprintf("%v", v)
+				if strings.Contains(s, "ERR backup running") {
+					return v, v
+				}
+				return v, "A lua stack containing 'ERR backup running'"
+			},
+		},
+		{"GET", "fleet", "truck1", "POINT"}, {"[33 -115]"},
+		{"SCAN", "fleet", "IDS"}, {"[0 [truck1]]"},
+		{"WRITES", "RESUME"}, {"OK"},
+		{"SET", "fleet", "truck2", "POINT", 34, -115}, {"OK"},
+		{"WRITES", "STATUS"}, {"[paused false]"},
+	}); err != nil {
+		return err
+	}
+	if _, err := mc.Do("WRITES", "PAUSE"); err != nil {
+		return err
+	}
+	if _, err := mc.Do("OUTPUT", "json"); err != nil {
+		return err
+	}
+	res, err := redis.String(mc.Do("WRITES", "STATUS"))
+	if err != nil {
+		return err
+	}
+	if !gjson.Get(res, "writes.paused").Bool() ||
+		gjson.Get(res, "writes.mode").String() != "reject" ||
+		gjson.Get(res, "writes.message").String() != "writes paused" {
+		return fmt.Errorf("unexpected status: %s", res)
+	}
+	res, err = redis.String(mc.Do("SERVER"))
+	if err != nil {
+		return err
+	}
+	if !gjson.Get(res, "stats.writes_paused").Bool() {
+		return fmt.Errorf("expected writes_paused, got %s", res)
+	}
+	return nil
+}
+
+func writes_queue_test(mc *mockServer) error {
+	defer mc.ResetConn()
+	defer mc.Do("WRITES", "RESUME")
+	if err := mc.DoBatch([][]interface{}{
+		{"WRITES", "PAUSE", "QUEUE", "TIMEOUT", 0.2}, {"OK"},
+		{"SET", "queued", "truck1", "POINT", 33, -115}, {"ERR writes paused"},
+		{"WRITES", "PAUSE", "QUEUE"}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	conn, err := redis.Dial("tcp", fmt.Sprintf(":%d", mc.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Do("SET", "queued", "truck1", "POINT", 33, -115)
+		done <- err
+	}()
+	// the write waits while the reads go on
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		res, err := redis.Values(mc.Do("WRITES", "STATUS"))
+		if err != nil {
+			return err
+		}
+		if len(res) == 12 && string(res[7].([]byte)) == "1" {
+			break
+		}
+		if time.Since(start) > time.Second {
+			return fmt.Errorf("expected a queued write, got %s", res)
+		}
+	}
+	if err := mc.DoBatch([][]interface{}{
+		{"GET", "queued", "truck1"}, {nil},
+		{"WRITES", "RESUME"}, {"OK"},
+	}); err != nil {
+		return err
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+	case <-time.After(time.Second):
+		return errors.New("queued write didn't run")
+	}
+	return mc.DoBatch([][]interface{}{
+		{"GET", "queued", "truck1", "POINT"}, {"[33 -115]"},
+	})
+}
@@ -0,0 +1,229 @@
+// Package tile38 runs the Tile38 geospatial index inside of a Go program,
+// without the network server. The objects are kept in memory only.
+//
+//	db, _ := tile38.Open()
+//	defer db.Close()
+//	db.Set("fleet", "truck1", tile38.Point(33.5123, -112.2693), nil)
+//
+// Geofences deliver their events on a channel:
+//
+//	f, _ := db.Fence("fleet", area, nil)
+//	for ev := range f.C {
+//		fmt.Println(ev.Detect, ev.ID)
+//	}
+package tile38
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/tidwall/geojson"
+	"github.com/tidwall/geojson/geometry"
+	"github.com/tidwall/tile38/internal/collection"
+)
+
+var (
+	// ErrClosed is returned when the database is closed.
+	ErrClosed = errors.New("database closed")
+	// ErrNotSpatial is returned for an object that isn't a geometry, such
+	// as nil.
+	ErrNotSpatial = errors.New("object is not spatial")
+	// ErrInvalidDetect is returned for an unknown detect value of a fence.
+	ErrInvalidDetect = errors.New("invalid detect")
+)
+
+// DB is an in-process Tile38 database. It's safe for concurrent use.
+type DB struct {
+	mu     sync.RWMutex
+	cols   map[string]*collection.Collection
+	fences map[string]map[*Fence]bool // by key
+	closed bool
+}
+
+// Open returns a new empty database.
+func Open() (*DB, error) {
+	return &DB{
+		cols:   make(map[string]*collection.Collection),
+		fences: make(map[string]map[*Fence]bool),
+	}, nil
+}
+
+// Close closes the database and all of its fences.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+	db.closed = true
+	for _, fences := range db.fences {
+		for f := range fences {
+			f.stop()
+		}
+	}
+	db.fences = nil
+	db.cols = nil
+	return nil
+}
+
+// Point returns a point object.
+func Point(lat, lon float64) geojson.Object {
+	return geojson.NewPoint(geometry.Point{X: lon, Y: lat})
+}
+
+// Bounds returns a rectangle object.
+func Bounds(minLat, minLon, maxLat, maxLon float64) geojson.Object {
+	return geojson.NewRect(geometry.Rect{
+		Min: geometry.Point{X: minLon, Y: minLat},
+		Max: geometry.Point{X: maxLon, Y: maxLat},
+	})
+}
+
+// Set stores an object with its fields. The fields that aren't in the map
+// keep their current values when the object is replaced.
+func (db *DB) Set(key, id string, obj geojson.Object,
+	fields map[string]float64,
+) error {
+	if obj == nil || obj.Empty() {
+		return ErrNotSpatial
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrClosed
+	}
+	col := db.cols[key]
+	if col == nil {
+		col = collection.New()
+		db.cols[key] = col
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]float64, len(names))
+	for i, name := range names {
+		values[i] = fields[name]
+	}
+	oldObj, _, newValues := col.Set(id, obj, names, values, 0)
+	db.fenceSet(key, id, oldObj, obj, fieldMap(col, newValues))
+	return nil
+}
+
+// Get returns an object and its fields.
+func (db *DB) Get(key, id string) (geojson.Object, map[string]float64, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	col := db.cols[key]
+	if col == nil {
+		return nil, nil, false
+	}
+	obj, values, _, ok := col.Get(id)
+	if !ok {
+		return nil, nil, false
+	}
+	return obj, fieldMap(col, values), true
+}
+
+// Delete removes an object. Returns false when the object doesn't exist.
+func (db *DB) Delete(key, id string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	col := db.cols[key]
+	if col == nil {
+		return false
+	}
+	obj, values, ok := col.Delete(id)
+	if !ok {
+		return false
+	}
+	if col.Count() == 0 {
+		delete(db.cols, key)
+	}
+	db.fenceDelete(key, id, obj, fieldMap(col, values))
+	return true
+}
+
+// Drop removes a key and all of its objects. Returns false when the key
+// doesn't exist.
+func (db *DB) Drop(key string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.cols[key] == nil {
+		return false
+	}
+	delete(db.cols, key)
+	db.fenceDrop(key)
+	return true
+}
+
+// Keys returns the keys of the database, sorted.
+func (db *DB) Keys() []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	keys := make([]string, 0, len(db.cols))
+	for key := range db.cols {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Count returns the number of objects of a key.
+func (db *DB) Count(key string) int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if col := db.cols[key]; col != nil {
+		return col.Count()
+	}
+	return 0
+}
+
+// SearchWithin iterates over the objects of a key that are fully contained
+// within an area. Return false from the iterator to stop.
+func (db *DB) SearchWithin(key string, area geojson.Object,
+	iter func(id string, obj geojson.Object, fields map[string]float64) bool,
+) {
+	db.search(key, area, false, iter)
+}
+
+// SearchIntersects iterates over the objects of a key that intersect an
+// area. Return false from the iterator to stop.
+func (db *DB) SearchIntersects(key string, area geojson.Object,
+	iter func(id string, obj geojson.Object, fields map[string]float64) bool,
+) {
+	db.search(key, area, true, iter)
+}
+
+func (db *DB) search(key string, area geojson.Object, intersects bool,
+	iter func(id string, obj geojson.Object, fields map[string]float64) bool,
+) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	col := db.cols[key]
+	if col == nil || area == nil {
+		return
+	}
+	fn := func(id string, obj geojson.Object, values []float64) bool {
+		return iter(id, obj, fieldMap(col, values))
+	}
+	if intersects {
+		col.Intersects(area, 0, nil, nil, fn)
+	} else {
+		col.Within(area, 0, nil, nil, fn)
+	}
+}
+
+// fieldMap returns the fields of an object by name. Fields that are zero
+// are left out, like the fields of the server.
+func fieldMap(col *collection.Collection, values []float64) map[string]float64 {
+	fields := make(map[string]float64)
+	for name, idx := range col.FieldMap() {
+		if idx < len(values) && values[idx] != 0 {
+			fields[name] = values[idx]
+		}
+	}
+	return fields
+}
@@ -0,0 +1,155 @@
+package tile38
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tidwall/geojson"
+)
+
+func expectEvent(t *testing.T, f *Fence, command, detect, id string) Event {
+	t.Helper()
+	select {
+	case ev := <-f.C:
+		if ev.Command != command || ev.Detect != detect || ev.ID != id {
+			t.Fatalf("expected %s/%s/%s, got %s/%s/%s", command, detect, id,
+				ev.Command, ev.Detect, ev.ID)
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatalf("expected %s/%s/%s, got nothing", command, detect, id)
+	}
+	return Event{}
+}
+
+func TestDB(t *testing.T) {
+	db, err := Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Set("fleet", "truck1", Point(33, -115),
+		map[string]float64{"speed": 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set("fleet", "truck2", Point(34, -112), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set("fleet", "truck3", nil, nil); err != ErrNotSpatial {
+		t.Fatalf("expected '%v', got '%v'", ErrNotSpatial, err)
+	}
+	obj, fields, ok := db.Get("fleet", "truck1")
+	if !ok || obj.String() != Point(33, -115).String() || fields["speed"] != 10 {
+		t.Fatalf("got %v %v %v", obj, fields, ok)
+	}
+	// fields are kept when they're not set
+	db.Set("fleet", "truck1", Point(33.1, -115), nil)
+	if _, fields, _ = db.Get("fleet", "truck1"); fields["speed"] != 10 {
+		t.Fatalf("expected 10, got %v", fields["speed"])
+	}
+	var ids []string
+	db.SearchWithin("fleet", Bounds(32, -116, 34, -114),
+		func(id string, obj geojson.Object, fields map[string]float64) bool {
+			ids = append(ids, id)
+			return true
+		})
+	if len(ids) != 1 || ids[0] != "truck1" {
+		t.Fatalf("expected [truck1], got %v", ids)
+	}
+	ids = nil
+	db.SearchIntersects("fleet", Bounds(32, -120, 35, -110),
+		func(id string, obj geojson.Object, fields map[string]float64) bool {
+			ids = append(ids, id)
+			return true
+		})
+	if len(ids) != 2 {
+		t.Fatalf("expected 2 ids, got %v", ids)
+	}
+	if keys := db.Keys(); len(keys) != 1 || keys[0] != "fleet" {
+		t.Fatalf("expected [fleet], got %v", keys)
+	}
+	if !db.Delete("fleet", "truck2") || db.Delete("fleet", "truck2") {
+		t.Fatal("expected one delete")
+	}
+	if db.Count("fleet") != 1 {
+		t.Fatalf("expected 1, got %d", db.Count("fleet"))
+	}
+	if !db.Drop("fleet") || db.Drop("fleet") {
+		t.Fatal("expected one drop")
+	}
+	if len(db.Keys()) != 0 {
+		t.Fatalf("expected no keys, got %v", db.Keys())
+	}
+}
+
+func TestDBFence(t *testing.T) {
+	db, _ := Open()
+	defer db.Close()
+	area := Bounds(33, -116, 34, -115)
+	f, err := db.Fence("fleet", area, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Set("fleet", "truck1", Point(30, -115.5), nil)
+	expectEvent(t, f, "set", "outside", "truck1")
+	db.Set("fleet", "truck1", Point(33.5, -115.5), map[string]float64{"speed": 5})
+	ev := expectEvent(t, f, "set", "enter", "truck1")
+	if ev.Fields["speed"] != 5 || ev.Key != "fleet" {
+		t.Fatalf("got %v", ev)
+	}
+	expectEvent(t, f, "set", "inside", "truck1")
+	db.Set("fleet", "truck1", Point(36, -115.5), nil)
+	expectEvent(t, f, "set", "exit", "truck1")
+	expectEvent(t, f, "set", "outside", "truck1")
+	db.Set("fleet", "truck1", Point(30, -115.5), nil)
+	expectEvent(t, f, "set", "cross", "truck1")
+	expectEvent(t, f, "set", "outside", "truck1")
+	db.Delete("fleet", "truck1")
+	expectEvent(t, f, "del", "", "truck1")
+	f.Close()
+	if _, ok := <-f.C; ok {
+		t.Fatal("expected a closed channel")
+	}
+
+	// only enter and exit
+	f, err = db.Fence("fleet", area, &FenceOptions{Detect: []string{"enter", "exit"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for i := 0; i < 100; i++ {
+		// the events are queued while nothing is receiving
+		db.Set("fleet", "truck2", Point(33.5, -115.5), nil)
+		db.Set("fleet", "truck2", Point(36, -115.5), nil)
+	}
+	for i := 0; i < 100; i++ {
+		expectEvent(t, f, "set", "enter", "truck2")
+		expectEvent(t, f, "set", "exit", "truck2")
+	}
+	db.Drop("fleet")
+	expectEvent(t, f, "drop", "", "")
+
+	if _, err := db.Fence("fleet", area, &FenceOptions{
+		Detect: []string{"roam"},
+	}); err != ErrInvalidDetect {
+		t.Fatalf("expected '%v', got '%v'", ErrInvalidDetect, err)
+	}
+}
+
+func TestDBClose(t *testing.T) {
+	db, _ := Open()
+	f, _ := db.Fence("fleet", Bounds(33, -116, 34, -115), nil)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-f.C; ok {
+		t.Fatal("expected a closed channel")
+	}
+	f.Close()
+	if err := db.Set("fleet", "truck1", Point(33, -115), nil); err != ErrClosed {
+		t.Fatalf("expected '%v', got '%v'", ErrClosed, err)
+	}
+	if err := db.Close(); err != ErrClosed {
+		t.Fatalf("expected '%v', got '%v'", ErrClosed, err)
+	}
+}